@@ -0,0 +1,81 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+)
+
+// Authenticator authenticates requests to a host.
+type Authenticator interface {
+	// Authenticate adds credentials to req. It is called once all other
+	// headers are set.
+	Authenticate(req *http.Request) error
+}
+
+// authenticatorFunc adapts a function to the Authenticator interface.
+type authenticatorFunc func(*http.Request) error
+
+func (f authenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// WithHostAuthenticator makes the client authenticate requests to host, a
+// host name without port, with a rather than with the account ID and
+// license key. This is meant for mirrors requiring other credentials.
+func WithHostAuthenticator(host string, a Authenticator) Option {
+	return func(c *Client) {
+		if c.authenticators == nil {
+			c.authenticators = map[string]Authenticator{}
+		}
+		c.authenticators[host] = a
+	}
+}
+
+// BasicAuth returns an Authenticator using HTTP basic authentication.
+func BasicAuth(username, password string) Authenticator {
+	return authenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// BearerAuth returns an Authenticator sending token as a bearer token.
+func BearerAuth(token string) Authenticator {
+	return HeaderAuth("Authorization", "Bearer "+token)
+}
+
+// HeaderAuth returns an Authenticator setting the header name to value.
+func HeaderAuth(name, value string) Authenticator {
+	return authenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(name, value)
+		return nil
+	})
+}
+
+// SigV4Auth returns an Authenticator signing requests for Amazon S3 in
+// region with AWS Signature Version 4. sessionToken is only needed for
+// temporary credentials.
+func SigV4Auth(region, accessKeyID, secretAccessKey, sessionToken string) Authenticator {
+	return &sigv4.Signer{
+		Region:          region,
+		Service:         "s3",
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+	}
+}
+
+// authenticate adds the credentials for the host of req to it.
+func (c *Client) authenticate(req *http.Request) error {
+	a, ok := c.authenticators[req.URL.Hostname()]
+	if !ok {
+		a = BasicAuth(strconv.Itoa(c.accountID), c.licenseKey)
+	}
+	if err := a.Authenticate(req); err != nil {
+		return fmt.Errorf("authenticating request: %w", err)
+	}
+	return nil
+}
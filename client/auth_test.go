@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHostAuthenticator checks that requests are authenticated with the
+// authenticator of their host, and with the account ID and license key
+// otherwise.
+func TestHostAuthenticator(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	c, err := New(42, "000000000001", WithEndpoint(server.URL))
+	require.NoError(t, err)
+	_, err = c.getMetadata(context.Background(), "GeoIP2-City")
+	require.Error(t, err)
+	require.Equal(t, "Basic NDI6MDAwMDAwMDAwMDAx", authorization)
+
+	c, err = New(
+		42,
+		"000000000001",
+		WithEndpoint(server.URL),
+		WithHostAuthenticator(serverURL.Hostname(), BearerAuth("token")),
+	)
+	require.NoError(t, err)
+	_, err = c.getMetadata(context.Background(), "GeoIP2-City")
+	require.Error(t, err)
+	require.Equal(t, "Bearer token", authorization)
+
+	c, err = New(
+		42,
+		"000000000001",
+		WithEndpoint(server.URL),
+		WithHostAuthenticator("mirror.example.com", BearerAuth("token")),
+	)
+	require.NoError(t, err)
+	_, err = c.getMetadata(context.Background(), "GeoIP2-City")
+	require.Error(t, err)
+	require.Equal(t, "Basic NDI6MDAwMDAwMDAwMDAx", authorization)
+}
@@ -5,16 +5,27 @@ package client
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // Client downloads GeoIP2 and GeoLite2 MMDB databases.
 //
 // After creation, it is valid for concurrent use.
 type Client struct {
-	accountID  int
-	endpoint   string
-	httpClient *http.Client
-	licenseKey string
+	accountID int
+	// authenticators are the authenticators of hosts not using the account
+	// ID and license key, keyed by host name.
+	authenticators map[string]Authenticator
+	endpoint       string
+	httpClient     *http.Client
+	licenseKey     string
+	// metadataCache is shared by the copies of the Client.
+	metadataCache *metadataCache
+	// peers are the base URLs of the peers databases are downloaded from.
+	peers     []string
+	resumeDir string
+	// trace, if not nil, receives the events of the requests.
+	trace *traceWriter
 }
 
 // Option is an option for configuring Client.
@@ -36,6 +47,28 @@ func WithHTTPClient(httpClient *http.Client) Option {
 	}
 }
 
+// WithResumeDirectory makes the client keep the compressed content of
+// downloads in dir while they are in progress. A download that is
+// interrupted, e.g., by a network error or the process being killed, is
+// resumed from where it stopped by the next Download call for the same
+// database build. By default downloads are not resumable.
+func WithResumeDirectory(dir string) Option {
+	return func(c *Client) {
+		c.resumeDir = dir
+	}
+}
+
+// WithMetadataCache makes the client keep the metadata of editions, i.e.,
+// their latest builds, in file for ttl, so that the following clients,
+// e.g., of the next runs, don't request it again. By default the metadata
+// is only kept for the lifetime of the client.
+func WithMetadataCache(file string, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.metadataCache.file = file
+		c.metadataCache.ttl = ttl
+	}
+}
+
 // New creates a Client.
 func New(
 	accountID int,
@@ -55,6 +88,10 @@ func New(
 		endpoint:   "https://updates.maxmind.com",
 		httpClient: http.DefaultClient,
 		licenseKey: licenseKey,
+		metadataCache: &metadataCache{
+			batch:   map[string]bool{},
+			entries: map[string]cachedMetadata{},
+		},
 	}
 
 	for _, opt := range options {
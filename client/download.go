@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"strconv"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,6 +21,32 @@ import (
 
 // DownloadResponse describes the result of a Download call.
 type DownloadResponse struct {
+	// ArchiveSize is the size of the compressed archive the database is
+	// extracted from, e.g., to bound the size of the database. It will only
+	// be set if UpdateAvailable is true and the size is known.
+	ArchiveSize int64
+
+	// BuildDate is the publication date of the database according to the
+	// metadata endpoint, at midnight UTC. It will only be set if
+	// UpdateAvailable is true.
+	BuildDate time.Time
+
+	// FromPeers is true if the database was downloaded from the peers set
+	// with WithPeers rather than from the MaxMind servers.
+	FromPeers bool
+
+	// Header is the header of the HTTP response the database was downloaded
+	// with, e.g., to investigate corrupted downloads. It will only be set if
+	// UpdateAvailable is true and the database was downloaded from the
+	// MaxMind servers.
+	Header http.Header
+
+	// Host is the host the database was downloaded from, e.g.,
+	// updates.maxmind.com. It will only be set if UpdateAvailable is true,
+	// and is empty if FromPeers is true, as the download may be spread over
+	// several peers.
+	Host string
+
 	// LastModified is the date that the database was last modified. It will
 	// only be set if UpdateAvailable is true.
 	LastModified time.Time
@@ -27,6 +55,11 @@ type DownloadResponse struct {
 	// if UpdateAvailable is true.
 	MD5 string
 
+	// ServerTime is the time of the MaxMind servers when they were asked
+	// for the latest build, according to the Date header of their
+	// response, e.g., to detect a skewed local clock. It is zero if unknown.
+	ServerTime time.Time
+
 	// Reader can be read to access the database itself. It will only contain a
 	// database if UpdateAvailable is true.
 	//
@@ -59,7 +92,13 @@ func (c Client) Download(
 	ctx context.Context,
 	editionID,
 	md5 string,
-) (DownloadResponse, error) {
+) (_ DownloadResponse, err error) {
+	if c.trace != nil {
+		trace := c.trace.start(editionID)
+		ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+		defer func() { trace.done(err) }()
+	}
+
 	metadata, err := c.getMetadata(ctx, editionID)
 	if err != nil {
 		return DownloadResponse{}, err
@@ -68,30 +107,65 @@ func (c Client) Download(
 	if metadata.MD5 == md5 {
 		return DownloadResponse{
 			Reader:          io.NopCloser(strings.NewReader("")),
+			ServerTime:      metadata.serverTime,
 			UpdateAvailable: false,
 		}, nil
 	}
 
-	reader, modifiedTime, err := c.download(ctx, editionID, metadata.Date)
+	if len(c.peers) > 0 {
+		res, err := c.downloadFromPeers(ctx, editionID, metadata)
+		if err == nil {
+			res.BuildDate = metadata.buildDate()
+			res.ServerTime = metadata.serverTime
+			return res, nil
+		}
+		// Fall back to the MaxMind servers, unless the download is
+		// canceled.
+		if ctx.Err() != nil {
+			return DownloadResponse{}, err
+		}
+	}
+
+	reader, modifiedTime, err := c.download(ctx, editionID, metadata.Date, metadata.MD5)
 	if err != nil {
+		// The build may have been replaced since its metadata was cached.
+		if c.metadataCache != nil {
+			c.metadataCache.forget(editionID)
+		}
 		return DownloadResponse{}, err
 	}
 
 	return DownloadResponse{
+		ArchiveSize:     reader.archiveSize,
+		BuildDate:       metadata.buildDate(),
+		Header:          reader.header,
+		Host:            urlHost(c.endpoint),
 		LastModified:    modifiedTime,
 		MD5:             metadata.MD5,
 		Reader:          reader,
+		ServerTime:      metadata.serverTime,
 		UpdateAvailable: true,
 	}, nil
 }
 
 const downloadEndpoint = "%s/geoip/databases/%s/download?"
 
+// urlHost returns the host of rawURL, or rawURL itself if it can't be
+// parsed.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
+
 func (c *Client) download(
 	ctx context.Context,
 	editionID,
-	date string,
-) (io.ReadCloser, time.Time, error) {
+	date,
+	md5 string,
+) (_ *editionReader, _ time.Time, err error) {
 	date = strings.ReplaceAll(date, "-", "")
 
 	params := url.Values{}
@@ -106,7 +180,28 @@ func (c *Client) download(
 		return nil, time.Time{}, fmt.Errorf("creating download request: %w", err)
 	}
 	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
-	req.SetBasicAuth(strconv.Itoa(c.accountID), c.licenseKey)
+
+	var partial *partialDownload
+	// decodeErr is set if the downloaded content turns out to be unusable.
+	var decodeErr error
+	if c.resumeDir != "" {
+		partial, err = openPartialDownload(c.resumeDir, editionID, md5)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		defer func() {
+			if err != nil {
+				_ = partial.close(false, decodeErr)
+			}
+		}()
+		if r := partial.rangeHeader(); r != "" {
+			req.Header.Set("Range", r)
+		}
+	}
+
+	if err := c.authenticate(req); err != nil {
+		return nil, time.Time{}, err
+	}
 
 	response, err := c.httpClient.Do(req)
 	if err != nil {
@@ -121,7 +216,30 @@ func (c *Client) download(
 		}
 	}()
 
-	if response.StatusCode != http.StatusOK {
+	body := io.Reader(response.Body)
+	resumed := false
+	if partial != nil {
+		resumed = partial.resumes(response.StatusCode, response.Header.Get("Content-Range"))
+		if !resumed {
+			switch response.StatusCode {
+			case http.StatusOK:
+				// The server sent the whole file.
+				if err = partial.reset(); err != nil {
+					return nil, time.Time{}, err
+				}
+			case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+				// The partial download doesn't match what the server has.
+				// Start over on the next attempt.
+				if err = partial.reset(); err != nil {
+					return nil, time.Time{}, err
+				}
+				return nil, time.Time{}, errors.New("unable to resume partial download")
+			}
+		}
+		body = partial.reader(response.Body)
+	}
+
+	if response.StatusCode != http.StatusOK && !resumed {
 		// TODO(horgh): Should we fully consume the body?
 		//nolint:errcheck // we are already returning an error.
 		buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
@@ -132,9 +250,10 @@ func (c *Client) download(
 		return nil, time.Time{}, fmt.Errorf("unexpected HTTP status code: %w", httpErr)
 	}
 
-	gzReader, err := gzip.NewReader(response.Body)
+	gzReader, tarReader, err := openArchive(body)
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("encountered an error creating GZIP reader: %w", err)
+		decodeErr = err
+		return nil, time.Time{}, err
 	}
 	defer func() {
 		if err != nil {
@@ -142,35 +261,99 @@ func (c *Client) download(
 		}
 	}()
 
-	tarReader := tar.NewReader(gzReader)
+	lastModified, err := parseTime(response.Header.Get("Last-Modified"))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("reading Last-Modified header: %w", err)
+	}
+
+	// The size of a resumed download is that of its remaining part.
+	var archiveSize int64
+	if !resumed {
+		archiveSize = max(response.ContentLength, 0)
+	}
+
+	return &editionReader{
+			Reader:         tarReader,
+			archiveSize:    archiveSize,
+			gzCloser:       gzReader,
+			header:         response.Header,
+			partial:        partial,
+			responseCloser: response.Body,
+		},
+		lastModified,
+		nil
+}
+
+// openArchive opens the tar.gz archive of a database read from r, returning
+// its gzip reader and the tar reader positioned at the MMDB file.
+func openArchive(r io.Reader) (*gzip.Reader, *tar.Reader, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encountered an error creating GZIP reader: %w", err)
+	}
+
+	tarReader, err := findMMDB(gzReader)
+	if err != nil {
+		gzReader.Close()
+		return nil, nil, err
+	}
+	return gzReader, tarReader, nil
+}
+
+// findMMDB returns the reader of the tar archive read from r positioned at
+// its MMDB file. Archives with members that are unsafe to extract are
+// rejected, as they may come from a mirror or a bucket rather than from
+// MaxMind.
+func findMMDB(r io.Reader) (*tar.Reader, error) {
+	tarReader := tar.NewReader(r)
 
 	// iterate through the tar archive to extract the mmdb file
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
-			return nil, time.Time{}, errors.New("tar archive does not contain an mmdb file")
+			return nil, errors.New("tar archive does not contain an mmdb file")
 		}
 		if err != nil {
-			return nil, time.Time{}, fmt.Errorf("reading tar archive: %w", err)
+			return nil, fmt.Errorf("reading tar archive: %w", err)
+		}
+
+		if err := checkArchiveMember(header); err != nil {
+			return nil, err
 		}
 
 		if strings.HasSuffix(header.Name, ".mmdb") {
-			break
+			return tarReader, nil
 		}
 	}
+}
 
-	lastModified, err := parseTime(response.Header.Get("Last-Modified"))
-	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("reading Last-Modified header: %w", err)
+// checkArchiveMember returns an error if the member of a database archive
+// described by header escapes the directory it would be extracted to, or
+// is anything but a regular file or a directory, e.g., a link or a device
+// node.
+func checkArchiveMember(header *tar.Header) error {
+	name := header.Name
+	if path.IsAbs(name) || filepath.IsAbs(name) || strings.HasPrefix(name, `\`) {
+		return fmt.Errorf("tar archive member %q has an absolute path", name)
+	}
+	for _, elem := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		if elem == ".." {
+			return fmt.Errorf("tar archive member %q is outside of the archive", name)
+		}
 	}
 
-	return editionReader{
-			Reader:         tarReader,
-			gzCloser:       gzReader,
-			responseCloser: response.Body,
-		},
-		lastModified,
-		nil
+	switch header.Typeflag {
+	case tar.TypeReg, tar.TypeDir, tar.TypeXGlobalHeader:
+		return nil
+	case tar.TypeSymlink, tar.TypeLink:
+		return fmt.Errorf("tar archive member %q is a link to %q", name, header.Linkname)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return fmt.Errorf("tar archive member %q is a device node or a FIFO", name)
+	default:
+		return fmt.Errorf("tar archive member %q has unsupported type %q", name, header.Typeflag)
+	}
 }
 
 // parseTime parses a string representation of a time into time.Time according to the
@@ -187,13 +370,40 @@ func parseTime(s string) (time.Time, error) {
 // editionReader embeds a tar.Reader and holds references to other readers to close.
 type editionReader struct {
 	*tar.Reader
+	// archiveSize is the size of the archive, if known.
+	archiveSize    int64
 	gzCloser       io.Closer
+	header         http.Header
+	partial        *partialDownload
 	responseCloser io.Closer
+	// completed is true once the database has been read in full.
+	completed bool
+	// readErr is the first error other than io.EOF encountered reading.
+	readErr error
+}
+
+// Read reads from the database, keeping track of whether it has been
+// read in full.
+func (e *editionReader) Read(p []byte) (int, error) {
+	n, err := e.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		e.completed = true
+	} else if err != nil && e.readErr == nil {
+		e.readErr = err
+	}
+	return n, err
 }
 
 // Close closes the additional referenced readers.
-func (e editionReader) Close() error {
+func (e *editionReader) Close() error {
 	var err error
+	if e.partial != nil {
+		partialErr := e.partial.close(e.completed, e.readErr)
+		if partialErr != nil {
+			err = errors.Join(err, partialErr)
+		}
+	}
+
 	if e.gzCloser != nil {
 		gzErr := e.gzCloser.Close()
 		if gzErr != nil {
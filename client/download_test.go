@@ -102,6 +102,9 @@ func TestDownload(t *testing.T) {
 				require.Equal(t, dbContent, string(c))
 				require.Equal(t, "618dd27a10de24809ec160d6807f363f", res.MD5)
 				require.Equal(t, lastModified, res.LastModified)
+				require.Equal(t, lastModified, res.BuildDate)
+				require.Positive(t, res.ArchiveSize)
+				require.Equal(t, "application/gzip", res.Header.Get("Content-Type"))
 			},
 		},
 		{
@@ -259,3 +262,157 @@ func TestDownload(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenArchive(t *testing.T) {
+	dbContent := "edition-1 content"
+	database := &tar.Header{
+		Name:     "edition-1_20240223/edition-1.mmdb",
+		Typeflag: tar.TypeReg,
+		Size:     int64(len(dbContent)),
+		Mode:     0o644,
+	}
+
+	tests := []struct {
+		description string
+		members     []*tar.Header
+		err         string
+	}{
+		{
+			description: "release archive",
+			members: []*tar.Header{
+				{Name: "edition-1_20240223/", Typeflag: tar.TypeDir, Mode: 0o755},
+				{Name: "edition-1_20240223/LICENSE.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+				database,
+			},
+		},
+		{
+			description: "parent directory",
+			members: []*tar.Header{
+				{Name: "edition-1_20240223/../../etc/cron.d/x", Typeflag: tar.TypeReg, Mode: 0o644},
+				database,
+			},
+			err: `tar archive member "edition-1_20240223/../../etc/cron.d/x" is outside of the archive`,
+		},
+		{
+			description: "parent directory with backslashes",
+			members: []*tar.Header{
+				{Name: `..\edition-1.mmdb`, Typeflag: tar.TypeReg, Mode: 0o644},
+			},
+			err: `tar archive member "..\\edition-1.mmdb" is outside of the archive`,
+		},
+		{
+			description: "absolute path",
+			members: []*tar.Header{
+				{Name: "/var/lib/GeoIP/edition-1.mmdb", Typeflag: tar.TypeReg, Mode: 0o644},
+			},
+			err: `tar archive member "/var/lib/GeoIP/edition-1.mmdb" has an absolute path`,
+		},
+		{
+			description: "symlink",
+			members: []*tar.Header{
+				{
+					Name:     "edition-1_20240223/edition-1.mmdb",
+					Typeflag: tar.TypeSymlink,
+					Linkname: "/etc/passwd",
+					Mode:     0o777,
+				},
+			},
+			err: `tar archive member "edition-1_20240223/edition-1.mmdb" is a link to "/etc/passwd"`,
+		},
+		{
+			description: "hard link",
+			members: []*tar.Header{
+				{
+					Name:     "edition-1_20240223/COPYRIGHT.txt",
+					Typeflag: tar.TypeLink,
+					Linkname: "edition-1_20240223/edition-1.mmdb",
+					Mode:     0o644,
+				},
+				database,
+			},
+			err: `tar archive member "edition-1_20240223/COPYRIGHT.txt" is a link to "edition-1_20240223/edition-1.mmdb"`,
+		},
+		{
+			description: "device node",
+			members: []*tar.Header{
+				{Name: "edition-1_20240223/edition-1.mmdb", Typeflag: tar.TypeChar, Mode: 0o644},
+			},
+			err: `tar archive member "edition-1_20240223/edition-1.mmdb" is a device node or a FIFO`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(gw)
+			for _, header := range test.members {
+				require.NoError(t, tw.WriteHeader(header))
+				if header.Size > 0 {
+					_, err := tw.Write([]byte(dbContent))
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, tw.Close())
+			require.NoError(t, gw.Close())
+
+			gzReader, tarReader, err := openArchive(&buf)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			defer gzReader.Close()
+
+			c, err := io.ReadAll(tarReader)
+			require.NoError(t, err)
+			require.Equal(t, dbContent, string(c))
+		})
+	}
+}
+
+// BenchmarkDownload measures the throughput of downloads from a local
+// server, which excludes the network, e.g., to compare transport settings.
+func BenchmarkDownload(b *testing.B) {
+	dbContent := bytes.Repeat([]byte("edition-1 content "), 1<<16)
+
+	var archive bytes.Buffer
+	gw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gw)
+	require.NoError(b, tw.WriteHeader(&tar.Header{
+		Name: "edition-1.mmdb",
+		Size: int64(len(dbContent)),
+		Mode: 0o644,
+	}))
+	_, err := tw.Write(dbContent)
+	require.NoError(b, err)
+	require.NoError(b, tw.Close())
+	require.NoError(b, gw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/geoip/updates/metadata") {
+			_, _ = w.Write([]byte(`{"databases":[{"edition_id":"edition-1","md5":"a","date":"2024-02-23"}]}`))
+			return
+		}
+		w.Header().Set("Last-Modified", "Fri, 23 Feb 2024 00:00:00 GMT")
+		_, _ = w.Write(archive.Bytes())
+	}))
+	defer server.Close()
+
+	c, err := New(10, "license", WithEndpoint(server.URL))
+	require.NoError(b, err)
+
+	ctx := context.Background()
+	b.SetBytes(int64(len(dbContent)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := c.Download(ctx, "edition-1", "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, res.Reader); err != nil {
+			b.Fatal(err)
+		}
+		res.Reader.Close()
+	}
+}
@@ -3,11 +3,12 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
+	"time"
 
 	"github.com/maxmind/geoipupdate/v7/internal"
 	"github.com/maxmind/geoipupdate/v7/internal/vars"
@@ -15,20 +16,94 @@ import (
 
 const metadataEndpoint = "%s/geoip/updates/metadata?"
 
+// ErrEditionUnavailable is wrapped in the errors of editions the metadata
+// endpoint doesn't list, e.g., because they were removed from the account
+// or their subscription expired.
+var ErrEditionUnavailable = errors.New("the edition is not available to the account")
+
+// metadataBatchSize is the maximum number of editions whose metadata is
+// requested at once, keeping the URLs of the requests short.
+const metadataBatchSize = 50
+
 // metadata represents the metadata content for a certain database returned by the
 // metadata endpoint.
 type metadata struct {
 	Date      string `json:"date"`
 	EditionID string `json:"edition_id"`
 	MD5       string `json:"md5"`
+	// serverTime is the time of the response according to its Date
+	// header. It is zero if the header is missing or invalid.
+	serverTime time.Time
 }
 
+// getMetadata returns the metadata of editionID, from the cache if it was
+// already fetched, e.g., along with the editions set with BatchMetadata.
 func (c *Client) getMetadata(
 	ctx context.Context,
 	editionID string,
 ) (*metadata, error) {
+	if c.metadataCache != nil {
+		return c.metadataCache.get(ctx, editionID, c.fetchMetadata)
+	}
+	editions, err := c.fetchMetadata(ctx, []string{editionID})
+	if err != nil {
+		return nil, err
+	}
+	edition, ok := editions[editionID]
+	if !ok {
+		return nil, fmt.Errorf("response does not contain edition %s: %w", editionID, ErrEditionUnavailable)
+	}
+	return edition, nil
+}
+
+// fetchMetadata requests the metadata of editionIDs, keyed by edition ID,
+// with a request per metadataBatchSize editions. Editions left out of a
+// response that does contain others, e.g., because the server limits the
+// size of its responses, are requested again. Editions the server doesn't
+// know are left out of the result.
+func (c *Client) fetchMetadata(
+	ctx context.Context,
+	editionIDs []string,
+) (map[string]*metadata, error) {
+	editions := map[string]*metadata{}
+	for len(editionIDs) > 0 {
+		batch := editionIDs[:min(len(editionIDs), metadataBatchSize)]
+		editionIDs = editionIDs[len(batch):]
+
+		for len(batch) > 0 {
+			received, err := c.requestMetadata(ctx, batch)
+			if err != nil {
+				return nil, err
+			}
+			var missing []string
+			for _, editionID := range batch {
+				m, ok := received[editionID]
+				if !ok {
+					missing = append(missing, editionID)
+					continue
+				}
+				editions[editionID] = m
+			}
+			// Only truncated responses are worth requesting again.
+			if len(missing) == len(batch) {
+				break
+			}
+			batch = missing
+		}
+	}
+	return editions, nil
+}
+
+// requestMetadata requests the metadata of editionIDs in one request,
+// keyed by edition ID.
+func (c *Client) requestMetadata(
+	ctx context.Context,
+	editionIDs []string,
+) (map[string]*metadata, error) {
 	params := url.Values{}
-	params.Add("edition_id", editionID)
+	for _, editionID := range editionIDs {
+		params.Add("edition_id", editionID)
+	}
 
 	metadataRequestURL := fmt.Sprintf(metadataEndpoint, c.endpoint) + params.Encode()
 
@@ -37,7 +112,9 @@ func (c *Client) getMetadata(
 		return nil, fmt.Errorf("creating metadata request: %w", err)
 	}
 	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
-	req.SetBasicAuth(strconv.Itoa(c.accountID), c.licenseKey)
+	if err := c.authenticate(req); err != nil {
+		return nil, err
+	}
 
 	response, err := c.httpClient.Do(req)
 	if err != nil {
@@ -66,11 +143,41 @@ func (c *Client) getMetadata(
 		return nil, fmt.Errorf("parsing metadata body: %w", err)
 	}
 
-	if len(metadataResponse.Databases) != 1 {
-		return nil, fmt.Errorf("response does not contain edition %s", editionID)
+	//nolint:errcheck // the server time is optional.
+	serverTime, _ := http.ParseTime(response.Header.Get("Date"))
+
+	editions := map[string]*metadata{}
+	for _, edition := range metadataResponse.Databases {
+		edition := edition
+		edition.serverTime = serverTime
+		editions[edition.EditionID] = &edition
 	}
+	return editions, nil
+}
+
+// buildDate returns the publication date of the build, at midnight UTC as
+// the metadata endpoint doesn't give a time zone. It is zero if the date
+// is missing or invalid.
+func (m metadata) buildDate() time.Time {
+	//nolint:errcheck // the build date is optional.
+	date, _ := time.ParseInLocation("2006-01-02", m.Date, time.UTC)
+	return date
+}
 
-	edition := metadataResponse.Databases[0]
+// Build describes the latest build of an edition.
+type Build struct {
+	// Date is the publication date of the build, e.g., 2024-02-23.
+	Date string
+	// MD5 is the MD5 sum of the database.
+	MD5 string
+}
 
-	return &edition, nil
+// LatestBuild returns the latest build of the edition, e.g., to tell whether
+// an update is available without downloading it.
+func (c Client) LatestBuild(ctx context.Context, editionID string) (Build, error) {
+	m, err := c.getMetadata(ctx, editionID)
+	if err != nil {
+		return Build{}, err
+	}
+	return Build{Date: m.Date, MD5: m.MD5}, nil
 }
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataCache keeps the metadata fetched by a client, so that editions
+// are only checked once per run, and, if file is set, by the runs within
+// ttl of each other.
+type metadataCache struct {
+	mu sync.Mutex
+	// batch are the editions set with BatchMetadata whose metadata wasn't
+	// fetched yet.
+	batch   map[string]bool
+	entries map[string]cachedMetadata
+	file    string
+	ttl     time.Duration
+	loaded  bool
+	// batchMu is held while fetching the metadata of batch, so that the
+	// editions waiting for it don't request it separately.
+	batchMu sync.Mutex
+}
+
+// cachedMetadata is the metadata of an edition along with when it was
+// fetched.
+type cachedMetadata struct {
+	Date       string    `json:"date"`
+	EditionID  string    `json:"edition_id"`
+	MD5        string    `json:"md5"`
+	ServerTime time.Time `json:"server_time"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+// BatchMetadata makes the metadata of editionIDs be fetched in a single
+// request when that of any of them is first needed, e.g., by Download,
+// rather than with a request per edition.
+func (c Client) BatchMetadata(editionIDs []string) {
+	c.metadataCache.mu.Lock()
+	defer c.metadataCache.mu.Unlock()
+	for _, editionID := range editionIDs {
+		c.metadataCache.batch[editionID] = true
+	}
+}
+
+// get returns the metadata of editionID, using fetch to request it along
+// with the rest of the batch if it isn't cached.
+func (mc *metadataCache) get(
+	ctx context.Context,
+	editionID string,
+	fetch func(context.Context, []string) (map[string]*metadata, error),
+) (*metadata, error) {
+	// The batch is only emptied once its metadata is stored, so it is
+	// checked first.
+	if mc.inBatch(editionID) {
+		mc.batchMu.Lock()
+		// The batch may have been fetched while waiting.
+		if _, ok := mc.lookup(editionID); !ok && mc.inBatch(editionID) {
+			// If the batch fails, e.g., because of an edition the account
+			// doesn't have access to, the editions are fetched separately.
+			if editions, err := fetch(ctx, mc.pendingBatch()); err == nil {
+				mc.store(editions)
+			}
+			mc.clearBatch()
+		}
+		mc.batchMu.Unlock()
+	}
+	if m, ok := mc.lookup(editionID); ok {
+		return m, nil
+	}
+
+	editions, err := fetch(ctx, []string{editionID})
+	if err != nil {
+		return nil, err
+	}
+	mc.store(editions)
+	m, ok := editions[editionID]
+	if !ok {
+		return nil, fmt.Errorf("response does not contain edition %s: %w", editionID, ErrEditionUnavailable)
+	}
+	return m, nil
+}
+
+// lookup returns the cached metadata of editionID. Its server time is
+// moved forward by the time since it was fetched.
+func (mc *metadataCache) lookup(editionID string) (*metadata, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.load()
+	e, ok := mc.entries[editionID]
+	if !ok {
+		return nil, false
+	}
+	m := &metadata{Date: e.Date, EditionID: e.EditionID, MD5: e.MD5}
+	if !e.ServerTime.IsZero() {
+		m.serverTime = e.ServerTime.Add(time.Since(e.FetchedAt))
+	}
+	return m, true
+}
+
+func (mc *metadataCache) inBatch(editionID string) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.batch[editionID]
+}
+
+// pendingBatch returns the sorted editions of the batch that aren't
+// cached.
+func (mc *metadataCache) pendingBatch() []string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	var editionIDs []string
+	for editionID := range mc.batch {
+		if _, ok := mc.entries[editionID]; !ok {
+			editionIDs = append(editionIDs, editionID)
+		}
+	}
+	slices.Sort(editionIDs)
+	return editionIDs
+}
+
+func (mc *metadataCache) clearBatch() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.batch = map[string]bool{}
+}
+
+// store caches editions and saves them to the file.
+func (mc *metadataCache) store(editions map[string]*metadata) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.load()
+	now := time.Now()
+	for editionID, m := range editions {
+		mc.entries[editionID] = cachedMetadata{
+			Date:       m.Date,
+			EditionID:  m.EditionID,
+			MD5:        m.MD5,
+			ServerTime: m.serverTime,
+			FetchedAt:  now,
+		}
+	}
+	mc.save()
+}
+
+// forget removes the metadata of editionID from the cache, e.g., as it
+// may be outdated.
+func (mc *metadataCache) forget(editionID string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if _, ok := mc.entries[editionID]; !ok {
+		return
+	}
+	delete(mc.entries, editionID)
+	mc.save()
+}
+
+// load reads the entries of the file fetched within ttl, once. The file is
+// only a cache, so errors reading it are ignored.
+func (mc *metadataCache) load() {
+	if mc.loaded || mc.file == "" {
+		return
+	}
+	mc.loaded = true
+
+	//nolint:gosec // we really need to read this file.
+	data, err := os.ReadFile(mc.file)
+	if err != nil {
+		return
+	}
+	var entries []cachedMetadata
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+	for _, e := range entries {
+		if _, ok := mc.entries[e.EditionID]; ok {
+			continue
+		}
+		if age := time.Since(e.FetchedAt); age >= 0 && age < mc.ttl {
+			mc.entries[e.EditionID] = e
+		}
+	}
+}
+
+// save writes the entries fetched within ttl to the file. Errors are
+// ignored as the metadata is fetched again when missing.
+func (mc *metadataCache) save() {
+	if mc.file == "" {
+		return
+	}
+	entries := []cachedMetadata{}
+	for _, e := range mc.entries {
+		if age := time.Since(e.FetchedAt); age >= 0 && age < mc.ttl {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortFunc(entries, func(a, b cachedMetadata) int {
+		return strings.Compare(a.EditionID, b.EditionID)
+	})
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(mc.file), filepath.Base(mc.file)+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), mc.file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
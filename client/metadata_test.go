@@ -2,9 +2,15 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,6 +37,7 @@ func TestGetMetadata(t *testing.T) {
 }
 `
 					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Date", "Fri, 23 Feb 2024 12:00:00 GMT")
 					w.WriteHeader(http.StatusOK)
 					_, err := w.Write([]byte(jsonData))
 					assert.NoError(t, err)
@@ -41,7 +48,10 @@ func TestGetMetadata(t *testing.T) {
 				require.NoError(t, err)
 
 				expectedMetadata := &metadata{
-					EditionID: "edition-1", MD5: "123456", Date: "2024-02-23",
+					EditionID:  "edition-1",
+					MD5:        "123456",
+					Date:       "2024-02-23",
+					serverTime: time.Date(2024, 2, 23, 12, 0, 0, 0, time.UTC),
 				}
 				require.Equal(t, expectedMetadata, receivedMetadata)
 			},
@@ -61,6 +71,22 @@ func TestGetMetadata(t *testing.T) {
 				require.Regexp(t, "^unexpected HTTP status code", err.Error())
 			},
 		},
+		{
+			description:      "edition not in the response",
+			preserveFileTime: false,
+			server: func(t *testing.T) *httptest.Server {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					_, err := w.Write([]byte(`{"databases": []}`))
+					assert.NoError(t, err)
+				}))
+				return server
+			},
+			checkResult: func(t *testing.T, receivedMetadata *metadata, err error) {
+				require.Nil(t, receivedMetadata)
+				require.ErrorIs(t, err, ErrEditionUnavailable)
+			},
+		},
 	}
 
 	ctx := context.Background()
@@ -85,3 +111,139 @@ func TestGetMetadata(t *testing.T) {
 		})
 	}
 }
+
+// TestBatchMetadata tests that the metadata of the editions set with
+// BatchMetadata is fetched with a single request.
+func TestBatchMetadata(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		editionIDs := r.URL.Query()["edition_id"]
+		mu.Lock()
+		requests = append(requests, editionIDs)
+		mu.Unlock()
+
+		// The account doesn't have access to edition-4.
+		if slices.Contains(editionIDs, "edition-4") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var databases []string
+		for _, editionID := range editionIDs {
+			databases = append(databases, fmt.Sprintf(
+				`{"edition_id": %q, "md5": "md5-%s", "date": "2024-02-23"}`,
+				editionID,
+				editionID,
+			))
+		}
+		_, err := fmt.Fprintf(w, `{"databases": [%s]}`, strings.Join(databases, ","))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	c, err := New(10, "license", WithEndpoint(server.URL))
+	require.NoError(t, err)
+
+	c.BatchMetadata([]string{"edition-1", "edition-2", "edition-3"})
+	var wg sync.WaitGroup
+	for _, editionID := range []string{"edition-1", "edition-2", "edition-3"} {
+		editionID := editionID
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := c.getMetadata(ctx, editionID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "md5-"+editionID, m.MD5)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, [][]string{{"edition-1", "edition-2", "edition-3"}}, requests)
+
+	// Editions outside of the batch are fetched separately.
+	_, err = c.getMetadata(ctx, "edition-5")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"edition-5"}, requests[1])
+
+	// When the batch fails, the editions are fetched separately.
+	requests = nil
+	c.BatchMetadata([]string{"edition-4", "edition-6"})
+	_, err = c.getMetadata(ctx, "edition-6")
+	require.NoError(t, err)
+	_, err = c.getMetadata(ctx, "edition-4")
+	require.ErrorContains(t, err, "unexpected HTTP status code")
+	assert.Equal(t, [][]string{{"edition-4", "edition-6"}, {"edition-6"}, {"edition-4"}}, requests)
+}
+
+// TestMetadataCacheFile tests that the metadata is reused by the clients
+// created within the TTL of the cache.
+func TestMetadataCacheFile(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		_, err := w.Write([]byte(`{"databases": [{"edition_id": "edition-1", "md5": "123456", "date": "2024-02-23"}]}`))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), ".geoipupdate.metadata")
+	newClient := func(ttl time.Duration) *Client {
+		c, err := New(10, "license", WithEndpoint(server.URL), WithMetadataCache(file, ttl))
+		require.NoError(t, err)
+		return &c
+	}
+
+	_, err := newClient(time.Hour).getMetadata(ctx, "edition-1")
+	require.NoError(t, err)
+	m, err := newClient(time.Hour).getMetadata(ctx, "edition-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, "123456", m.MD5)
+	assert.WithinDuration(t, time.Now(), m.serverTime, 2*time.Second)
+
+	// The metadata is requested again once the TTL is over.
+	_, err = newClient(time.Nanosecond).getMetadata(ctx, "edition-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+// TestFetchMetadataBatches tests that the metadata of many editions is
+// requested in batches, and that truncated responses are followed up.
+func TestFetchMetadataBatches(t *testing.T) {
+	var requestSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		editionIDs := r.URL.Query()["edition_id"]
+		requestSizes = append(requestSizes, len(editionIDs))
+
+		// The server returns at most 30 editions, and doesn't know
+		// edition-unknown.
+		var databases []string
+		for _, editionID := range editionIDs {
+			if editionID == "edition-unknown" || len(databases) == 30 {
+				continue
+			}
+			databases = append(databases, fmt.Sprintf(`{"edition_id": %q, "md5": "md5"}`, editionID))
+		}
+		_, err := fmt.Fprintf(w, `{"databases": [%s]}`, strings.Join(databases, ","))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	c, err := New(10, "license", WithEndpoint(server.URL))
+	require.NoError(t, err)
+
+	var editionIDs []string
+	for i := 0; i < 119; i++ {
+		editionIDs = append(editionIDs, fmt.Sprintf("edition-%d", i))
+	}
+	editionIDs = append(editionIDs, "edition-unknown")
+
+	editions, err := c.fetchMetadata(context.Background(), editionIDs)
+	require.NoError(t, err)
+	assert.Len(t, editions, 119)
+	assert.NotContains(t, editions, "edition-unknown")
+	assert.Equal(t, []int{50, 20, 50, 20, 20, 1}, requestSizes)
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+// Downloader downloads the databases of editions, e.g., Client.
+type Downloader interface {
+	Download(ctx context.Context, editionID, md5 string) (DownloadResponse, error)
+}
+
+// DownloaderFunc is an adapter to use a function as a Downloader.
+type DownloaderFunc func(ctx context.Context, editionID, md5 string) (DownloadResponse, error)
+
+// Download calls f.
+func (f DownloaderFunc) Download(ctx context.Context, editionID, md5 string) (DownloadResponse, error) {
+	return f(ctx, editionID, md5)
+}
+
+// Middleware wraps a Downloader to add a behavior to it, e.g., to throttle
+// downloads, rather than adding it to the Downloader itself.
+type Middleware func(Downloader) Downloader
+
+// Chain returns d wrapped by middlewares. The first middleware is the
+// outermost one, i.e., the first to be called and the last to see the
+// response.
+func Chain(d Downloader, middlewares ...Middleware) Downloader {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		d = middlewares[i](d)
+	}
+	return d
+}
+
+// VerifyMD5 returns a Middleware checking the MD5 sum of the databases
+// downloaded. Reading a database fails at its end if its MD5 sum doesn't
+// match the MD5 of the response, e.g., for writers that don't check it.
+func VerifyMD5() Middleware {
+	return func(next Downloader) Downloader {
+		return DownloaderFunc(func(ctx context.Context, editionID, md5sum string) (DownloadResponse, error) {
+			res, err := next.Download(ctx, editionID, md5sum)
+			if err != nil || !res.UpdateAvailable {
+				return res, err
+			}
+			res.Reader = &md5Reader{
+				ReadCloser: res.Reader,
+				editionID:  editionID,
+				expected:   res.MD5,
+				hash:       md5.New(),
+			}
+			return res, nil
+		})
+	}
+}
+
+// md5Reader fails at the end of the wrapped reader if its MD5 sum isn't the
+// expected one.
+type md5Reader struct {
+	io.ReadCloser
+	editionID string
+	expected  string
+	hash      hash.Hash
+}
+
+func (r *md5Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		actual := hex.EncodeToString(r.hash.Sum(nil))
+		if !strings.EqualFold(actual, r.expected) {
+			return n, fmt.Errorf(
+				"the database of %s has the MD5 sum %s rather than %s",
+				r.editionID,
+				actual,
+				r.expected,
+			)
+		}
+	}
+	return n, err
+}
+
+// Throttle returns a Middleware limiting the rate each database is
+// downloaded at to bytesPerSecond, e.g., not to saturate a slow link.
+func Throttle(bytesPerSecond int64) Middleware {
+	return func(next Downloader) Downloader {
+		return DownloaderFunc(func(ctx context.Context, editionID, md5sum string) (DownloadResponse, error) {
+			res, err := next.Download(ctx, editionID, md5sum)
+			if err != nil || !res.UpdateAvailable {
+				return res, err
+			}
+			res.Reader = &throttledReader{
+				ReadCloser: res.Reader,
+				ctx:        ctx,
+				rate:       bytesPerSecond,
+			}
+			return res, nil
+		})
+	}
+}
+
+// throttledReader reads from the wrapped reader at rate bytes per second at
+// most.
+type throttledReader struct {
+	io.ReadCloser
+	ctx   context.Context
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	// Reading at most a second's worth at once keeps the rate smooth.
+	if int64(len(p)) > r.rate {
+		p = p[:r.rate]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	due := time.Duration(float64(r.n) / float64(r.rate) * float64(time.Second))
+	if wait := due - time.Since(r.start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, nil
+}
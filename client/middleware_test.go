@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChain tests that middlewares are called in order, the first one
+// being the outermost.
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Downloader) Downloader {
+			return DownloaderFunc(func(ctx context.Context, editionID, md5 string) (DownloadResponse, error) {
+				calls = append(calls, name)
+				return next.Download(ctx, editionID, md5)
+			})
+		}
+	}
+	d := Chain(
+		DownloaderFunc(func(context.Context, string, string) (DownloadResponse, error) {
+			calls = append(calls, "downloader")
+			return DownloadResponse{}, nil
+		}),
+		record("first"),
+		record("second"),
+	)
+
+	_, err := d.Download(context.Background(), "GeoIP2-City", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "downloader"}, calls)
+}
+
+func staticDownloader(content, md5 string) Downloader {
+	return DownloaderFunc(func(context.Context, string, string) (DownloadResponse, error) {
+		return DownloadResponse{
+			MD5:             md5,
+			Reader:          io.NopCloser(strings.NewReader(content)),
+			UpdateAvailable: true,
+		}, nil
+	})
+}
+
+func TestVerifyMD5(t *testing.T) {
+	ctx := context.Background()
+
+	d := Chain(staticDownloader("database", "11E0EED8D3696C0A632F822DF385AB3C"), VerifyMD5())
+	res, err := d.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	content, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, "database", string(content))
+
+	d = Chain(staticDownloader("corrupted", "11e0eed8d3696c0a632f822df385ab3c"), VerifyMD5())
+	res, err = d.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	_, err = io.ReadAll(res.Reader)
+	require.ErrorContains(
+		t,
+		err,
+		"the database of GeoIP2-City has the MD5 sum ",
+	)
+	require.ErrorContains(t, err, " rather than 11e0eed8d3696c0a632f822df385ab3c")
+}
+
+func TestThrottle(t *testing.T) {
+	d := Chain(staticDownloader(strings.Repeat("x", 300), ""), Throttle(1000))
+	res, err := d.Download(context.Background(), "GeoIP2-City", "")
+	require.NoError(t, err)
+
+	start := time.Now()
+	content, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	assert.Len(t, content, 300)
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d = Chain(staticDownloader(strings.Repeat("x", 300), ""), Throttle(100))
+	res, err = d.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	_, err = io.ReadAll(res.Reader)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// peerChunkSize is the size of the chunks databases are downloaded from
+// peers in.
+const peerChunkSize = 4 << 20
+
+// maxPeerDownloads is the maximum number of chunks downloaded from peers
+// at the same time.
+const maxPeerDownloads = 4
+
+// WithPeers makes the client download databases from peers, the base URLs
+// of servers such as geoipupdate seed, rather than from the MaxMind
+// servers. Databases are downloaded in chunks spread over the peers, and
+// checked against the MD5 sum from the MaxMind servers. If that fails, e.g.,
+// because no peer has the latest build yet, the database is downloaded from
+// the MaxMind servers.
+func WithPeers(peers []string) Option {
+	return func(c *Client) {
+		c.peers = peers
+	}
+}
+
+// downloadFromPeers downloads the build of editionID that metadata
+// describes from the peers.
+func (c *Client) downloadFromPeers(
+	ctx context.Context,
+	editionID string,
+	metadata *metadata,
+) (_ DownloadResponse, err error) {
+	lastModified, err := time.ParseInLocation("2006-01-02", metadata.Date, time.UTC)
+	if err != nil {
+		return DownloadResponse{}, fmt.Errorf("parsing database date: %w", err)
+	}
+
+	name := url.PathEscape(editionID + ".mmdb")
+	size, err := c.peerFileSize(ctx, name)
+	if err != nil {
+		return DownloadResponse{}, err
+	}
+
+	dir := c.resumeDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	f, err := os.CreateTemp(dir, editionID+"-*.peer")
+	if err != nil {
+		return DownloadResponse{}, fmt.Errorf("creating file for peer download: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			//nolint:errcheck // we are already returning an error.
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(min(len(c.peers), maxPeerDownloads))
+	for offset := int64(0); offset < size; offset += peerChunkSize {
+		offset := offset
+		length := min(peerChunkSize, size-offset)
+		g.Go(func() error {
+			// Chunks are spread over the peers, each chunk being tried on
+			// all of them if needed.
+			first := int(offset / peerChunkSize)
+			var errs error
+			for i := range c.peers {
+				peer := c.peers[(first+i)%len(c.peers)]
+				err := c.fetchChunk(gctx, peer, name, f, offset, length, size)
+				if err == nil {
+					return nil
+				}
+				errs = errors.Join(errs, err)
+			}
+			return errs
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return DownloadResponse{}, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return DownloadResponse{}, fmt.Errorf("seeking peer download: %w", err)
+	}
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return DownloadResponse{}, fmt.Errorf("hashing peer download: %w", err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != metadata.MD5 {
+		return DownloadResponse{}, fmt.Errorf(
+			"the database from the peers has the MD5 sum %s rather than %s",
+			sum,
+			metadata.MD5,
+		)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return DownloadResponse{}, fmt.Errorf("seeking peer download: %w", err)
+	}
+
+	return DownloadResponse{
+		FromPeers:       true,
+		LastModified:    lastModified,
+		MD5:             metadata.MD5,
+		Reader:          &tempFile{File: f},
+		UpdateAvailable: true,
+	}, nil
+}
+
+// peerFileSize returns the size of the file name according to the first
+// peer having it.
+func (c *Client) peerFileSize(ctx context.Context, name string) (int64, error) {
+	var errs error
+	for _, peer := range c.peers {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, peerURL(peer, name), nil)
+		if err != nil {
+			return 0, fmt.Errorf("creating peer request: %w", err)
+		}
+		req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+
+		response, err := c.httpClient.Do(req)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("getting %s from %s: %w", name, peer, err))
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || response.ContentLength <= 0 {
+			errs = errors.Join(errs, fmt.Errorf(
+				"getting %s from %s: unexpected HTTP status code %d",
+				name,
+				peer,
+				response.StatusCode,
+			))
+			continue
+		}
+		return response.ContentLength, nil
+	}
+	return 0, errs
+}
+
+// fetchChunk writes length bytes of the file name, at offset, from peer to
+// f. size is the expected size of the file.
+func (c *Client) fetchChunk(
+	ctx context.Context,
+	peer,
+	name string,
+	f *os.File,
+	offset,
+	length,
+	size int64,
+) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peerURL(peer, name), nil)
+	if err != nil {
+		return fmt.Errorf("creating peer request: %w", err)
+	}
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	response, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("getting %s from %s: %w", name, peer, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf(
+			"getting %s from %s: unexpected HTTP status code %d",
+			name,
+			peer,
+			response.StatusCode,
+		)
+	}
+	// A peer with another build of the database may have another size.
+	wantRange := fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size)
+	if got := response.Header.Get("Content-Range"); got != wantRange {
+		return fmt.Errorf("getting %s from %s: unexpected range '%s'", name, peer, got)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(response.Body, buf); err != nil {
+		return fmt.Errorf("getting %s from %s: %w", name, peer, err)
+	}
+	if _, err := f.WriteAt(buf, offset); err != nil {
+		return fmt.Errorf("writing peer download: %w", err)
+	}
+	return nil
+}
+
+func peerURL(peer, name string) string {
+	return strings.TrimSuffix(peer, "/") + "/" + name
+}
+
+// tempFile is a temporary file removed once closed.
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	return errors.Join(t.File.Close(), os.Remove(t.File.Name()))
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadFromPeers(t *testing.T) {
+	// The database spans several chunks.
+	dbContent := strings.Repeat("edition-1 content ", peerChunkSize/8)
+	sum := md5.Sum([]byte(dbContent))
+	dbMD5 := hex.EncodeToString(sum[:])
+	otherContent := strings.Repeat("edition-1 CONTENT ", peerChunkSize/8)
+
+	var archive bytes.Buffer
+	gw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: "edition-1.mmdb",
+		Size: int64(len(dbContent)),
+	}))
+	_, err := tw.Write([]byte(dbContent))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	lastModified := time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC)
+	maxmind := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/geoip/updates/metadata") {
+			_, err := w.Write([]byte(`{"databases": [{"edition_id": "edition-1", "md5": "` +
+				dbMD5 + `", "date": "2024-02-23"}]}`))
+			assert.NoError(t, err)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified.Format(time.RFC1123))
+		_, err := w.Write(archive.Bytes())
+		assert.NoError(t, err)
+	}))
+	defer maxmind.Close()
+
+	peer := func(content string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/edition-1.mmdb" {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+		}))
+	}
+	seed := peer(dbContent)
+	defer seed.Close()
+	stale := peer(otherContent)
+	defer stale.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	tests := []struct {
+		description string
+		peers       []string
+		fromPeers   bool
+	}{
+		{
+			description: "all chunks from the peers",
+			peers:       []string{seed.URL, seed.URL + "/"},
+			fromPeers:   true,
+		},
+		{
+			description: "chunks failing on a peer are downloaded from another",
+			peers:       []string{broken.URL, seed.URL},
+			fromPeers:   true,
+		},
+		{
+			description: "fall back to MaxMind if the peers have another build",
+			peers:       []string{stale.URL},
+			fromPeers:   false,
+		},
+		{
+			description: "fall back to MaxMind if no peer is reachable",
+			peers:       []string{broken.URL},
+			fromPeers:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c, err := New(
+				10,
+				"license",
+				WithEndpoint(maxmind.URL),
+				WithPeers(test.peers),
+				WithResumeDirectory(t.TempDir()),
+			)
+			require.NoError(t, err)
+
+			res, err := c.Download(context.Background(), "edition-1", "")
+			require.NoError(t, err)
+			assert.True(t, res.UpdateAvailable)
+			assert.Equal(t, test.fromPeers, res.FromPeers)
+			assert.Equal(t, dbMD5, res.MD5)
+
+			content, err := io.ReadAll(res.Reader)
+			require.NoError(t, err)
+			require.NoError(t, res.Reader.Close())
+			assert.Equal(t, dbContent, string(content))
+		})
+	}
+}
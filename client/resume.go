@@ -0,0 +1,136 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const partialExtension = ".tar.gz.partial"
+
+// partialDownload keeps the compressed content of a database download on
+// disk so that the download can be resumed if it is interrupted.
+type partialDownload struct {
+	file *os.File
+	// size is the number of bytes downloaded by previous attempts.
+	size int64
+	// bodyErr is the first error encountered reading the response body.
+	bodyErr error
+}
+
+// openPartialDownload opens the partial download of the given build of an
+// edition in dir. Partial downloads of other builds of the edition are
+// removed as they can't be resumed anymore.
+func openPartialDownload(dir, editionID, md5 string) (*partialDownload, error) {
+	path := filepath.Join(dir, editionID+"-"+md5+partialExtension)
+
+	stale, err := filepath.Glob(filepath.Join(dir, editionID+"-*"+partialExtension))
+	if err != nil {
+		return nil, fmt.Errorf("listing partial downloads: %w", err)
+	}
+	for _, p := range stale {
+		if p != path {
+			_ = os.Remove(p)
+		}
+	}
+
+	//nolint:gosec // we really need to read this file.
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening partial download: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("reading partial download information: %w", err)
+	}
+
+	return &partialDownload{
+		file: file,
+		size: info.Size(),
+	}, nil
+}
+
+// rangeHeader returns the value of the Range header requesting the rest of
+// the download, or an empty string if there is nothing to resume.
+func (p *partialDownload) rangeHeader() string {
+	if p.size == 0 {
+		return ""
+	}
+	return fmt.Sprintf("bytes=%d-", p.size)
+}
+
+// resumes reports whether a response with the given status code and
+// Content-Range header continues the partial download.
+func (p *partialDownload) resumes(statusCode int, contentRange string) bool {
+	if p.size == 0 || statusCode != http.StatusPartialContent {
+		return false
+	}
+	// The header looks like "bytes 1000-1999/2000".
+	start, _, ok := strings.Cut(strings.TrimPrefix(contentRange, "bytes "), "-")
+	if !ok {
+		return false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return err == nil && n == p.size
+}
+
+// reset discards the content downloaded by previous attempts.
+func (p *partialDownload) reset() error {
+	if err := p.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncating partial download: %w", err)
+	}
+	p.size = 0
+	return nil
+}
+
+// reader returns a reader of the complete download: the content downloaded
+// by previous attempts followed by body. Content read from body is appended
+// to the partial download.
+func (p *partialDownload) reader(body io.Reader) io.Reader {
+	return io.MultiReader(
+		io.NewSectionReader(p.file, 0, p.size),
+		io.TeeReader(
+			&bodyReader{Reader: body, err: &p.bodyErr},
+			io.NewOffsetWriter(p.file, p.size),
+		),
+	)
+}
+
+// close closes the partial download. It is removed if the download
+// completed or if its content turned out to be unusable, i.e., reading it
+// failed for reasons other than a failing response body.
+func (p *partialDownload) close(completed bool, readErr error) error {
+	err := p.file.Close()
+	if completed || (readErr != nil && p.bodyErr == nil) {
+		if removeErr := os.Remove(p.file.Name()); removeErr != nil &&
+			!errors.Is(removeErr, os.ErrNotExist) {
+			err = errors.Join(err, removeErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("closing partial download: %w", err)
+	}
+	return nil
+}
+
+// bodyReader records the first error other than io.EOF returned by the
+// wrapped reader.
+type bodyReader struct {
+	io.Reader
+	err *error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && *b.err == nil {
+		*b.err = err
+	}
+	return n, err
+}
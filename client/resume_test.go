@@ -0,0 +1,100 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDownloadResume tests that an interrupted download is resumed from
+// where it stopped.
+func TestDownloadResume(t *testing.T) {
+	dbContent := strings.Repeat("edition-1 content ", 1000)
+	lastModified := time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&buf, gzip.NoCompression)
+	require.NoError(t, err)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: "edition-1.mmdb",
+		Size: int64(len(dbContent)),
+	}))
+	_, err = tw.Write([]byte(dbContent))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	archive := buf.Bytes()
+
+	requests := 0
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/geoip/updates/metadata") {
+			_, err := w.Write([]byte(`{"databases":[{"edition_id":"edition-1",` +
+				`"md5":"618dd27a10de24809ec160d6807f363f","date":"2024-02-23"}]}`))
+			assert.NoError(t, err)
+			return
+		}
+
+		requests++
+		ranges = append(ranges, r.Header.Get("Range"))
+		if requests == 1 {
+			// Interrupt the first download halfway.
+			w.Header().Set("Content-Length", "99999999")
+			w.Header().Set("Last-Modified", lastModified.Format(time.RFC1123))
+			_, err := w.Write(archive[:len(archive)/2])
+			assert.NoError(t, err)
+			return
+		}
+
+		http.ServeContent(w, r, "", lastModified, bytes.NewReader(archive))
+	}))
+	defer server.Close()
+
+	resumeDir := t.TempDir()
+	c, err := New(
+		10,
+		"license",
+		WithEndpoint(server.URL),
+		WithResumeDirectory(resumeDir),
+	)
+	require.NoError(t, err)
+
+	res, err := c.Download(context.Background(), "edition-1", "")
+	require.NoError(t, err)
+	_, err = io.ReadAll(res.Reader)
+	require.Error(t, err)
+	// Closing reports the truncated stream as well.
+	_ = res.Reader.Close()
+
+	partial := filepath.Join(resumeDir, "edition-1-618dd27a10de24809ec160d6807f363f"+partialExtension)
+	info, err := os.Stat(partial)
+	require.NoError(t, err)
+	require.Positive(t, info.Size())
+
+	res, err = c.Download(context.Background(), "edition-1", "")
+	require.NoError(t, err)
+	content, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	require.NoError(t, res.Reader.Close())
+	require.Equal(t, dbContent, string(content))
+	require.True(t, lastModified.Equal(res.LastModified))
+
+	require.Equal(t, []string{"", fmt.Sprintf("bytes=%d-", info.Size())}, ranges)
+
+	_, err = os.Stat(partial)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
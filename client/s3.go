@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// S3ManifestKey is the key, relative to the mirror URL, of the manifest of
+// an S3 mirror.
+const S3ManifestKey = "manifest.json"
+
+// S3Manifest describes the databases held by an S3 mirror.
+type S3Manifest struct {
+	Databases []S3ManifestEntry `json:"databases"`
+}
+
+// S3ManifestEntry describes the current build of an edition in an S3
+// mirror.
+type S3ManifestEntry struct {
+	EditionID string `json:"edition_id"`
+	// MD5 is the MD5 sum of the MMDB file.
+	MD5 string `json:"md5"`
+	// ModifiedAt is when the build was published by MaxMind.
+	ModifiedAt time.Time `json:"modified_at"`
+	// Object is the key, relative to the mirror URL, of the tar archive
+	// holding the MMDB file, as served by MaxMind. Its extension, .tar.gz,
+	// .tar.zst, or .tar, gives its compression, followed by .enc if it is
+	// encrypted.
+	Object string `json:"object"`
+	// Encryption is the encryption of the archive, if any, e.g.,
+	// aes-256-gcm. Archives are compressed before being encrypted.
+	Encryption string `json:"encryption,omitempty"`
+	// EncryptionKey is the ID of the key the archive is encrypted with.
+	EncryptionKey string `json:"encryption_key,omitempty"`
+	// EncryptionKEK identifies the key-encryption key that wrapped the data
+	// key of an archive encrypted with a key management service.
+	EncryptionKEK string `json:"encryption_kek,omitempty"`
+	// EncryptionWrappedKey is the base64-encoded wrapped data key of an
+	// archive encrypted with a key management service.
+	EncryptionWrappedKey string `json:"encryption_wrapped_key,omitempty"`
+}
+
+// S3Decrypter returns a reader decrypting body, the encrypted archive of
+// entry.
+type S3Decrypter func(ctx context.Context, entry S3ManifestEntry, body io.Reader) (io.Reader, error)
+
+// S3Reader downloads databases from an Amazon S3 bucket holding a mirror of
+// the MaxMind databases. It authenticates with AWS credentials, so that
+// hosts using it don't need MaxMind credentials.
+//
+// The mirror is made of the S3Manifest object, at S3ManifestKey, and of the
+// archives it references.
+//
+// After creation, it is valid for concurrent use.
+type S3Reader struct {
+	auth Authenticator
+	// decrypter, if not nil, decrypts the encrypted archives.
+	decrypter  S3Decrypter
+	httpClient *http.Client
+	mirrorURL  string
+}
+
+// NewS3Reader creates an S3Reader for the mirror at mirrorURL, e.g.,
+// https://bucket.s3.us-east-1.amazonaws.com/prefix, authenticating
+// requests with auth, typically a SigV4Auth.
+func NewS3Reader(mirrorURL string, auth Authenticator, httpClient *http.Client) S3Reader {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return S3Reader{
+		auth:       auth,
+		httpClient: httpClient,
+		mirrorURL:  strings.TrimSuffix(mirrorURL, "/"),
+	}
+}
+
+// WithDecrypter returns a copy of r decrypting the encrypted archives of
+// the mirror with d. Without one, encrypted archives can't be downloaded.
+func (r S3Reader) WithDecrypter(d S3Decrypter) S3Reader {
+	r.decrypter = d
+	return r
+}
+
+// Download attempts to download the edition from the mirror. It behaves
+// like Client.Download.
+func (r S3Reader) Download(
+	ctx context.Context,
+	editionID,
+	md5 string,
+) (DownloadResponse, error) {
+	entry, err := r.manifestEntry(ctx, editionID)
+	if err != nil {
+		return DownloadResponse{}, err
+	}
+
+	if entry.MD5 == md5 {
+		return DownloadResponse{
+			Reader:          io.NopCloser(strings.NewReader("")),
+			UpdateAvailable: false,
+		}, nil
+	}
+
+	if entry.Encryption != "" && r.decrypter == nil {
+		return DownloadResponse{}, fmt.Errorf(
+			"the database of %s is encrypted, but no encryption key is set",
+			editionID,
+		)
+	}
+
+	body, size, err := r.getObject(ctx, entry.Object)
+	if err != nil {
+		return DownloadResponse{}, err
+	}
+
+	var archive io.Reader = body
+	if entry.Encryption != "" {
+		archive, err = r.decrypter(ctx, *entry, body)
+		if err != nil {
+			body.Close()
+			return DownloadResponse{}, fmt.Errorf("decrypting %s: %w", entry.Object, err)
+		}
+	}
+	decoder, err := s3ArchiveEncoding(strings.TrimSuffix(entry.Object, ".enc")).NewReader(archive)
+	if err != nil {
+		body.Close()
+		return DownloadResponse{}, fmt.Errorf("reading %s: %w", entry.Object, err)
+	}
+	tarReader, err := findMMDB(decoder)
+	if err != nil {
+		decoder.Close()
+		body.Close()
+		return DownloadResponse{}, fmt.Errorf("reading %s: %w", entry.Object, err)
+	}
+
+	return DownloadResponse{
+		ArchiveSize:  size,
+		Host:         urlHost(r.mirrorURL),
+		LastModified: entry.ModifiedAt.In(time.UTC),
+		MD5:          entry.MD5,
+		Reader: &editionReader{
+			Reader:         tarReader,
+			gzCloser:       decoder,
+			responseCloser: body,
+		},
+		UpdateAvailable: true,
+	}, nil
+}
+
+// EditionIDs returns the IDs of the editions the mirror holds, in the
+// order of its manifest.
+func (r S3Reader) EditionIDs(ctx context.Context) ([]string, error) {
+	manifest, err := r.manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	editionIDs := make([]string, 0, len(manifest.Databases))
+	for _, entry := range manifest.Databases {
+		editionIDs = append(editionIDs, entry.EditionID)
+	}
+	return editionIDs, nil
+}
+
+// manifest returns the manifest of the mirror.
+func (r S3Reader) manifest(ctx context.Context) (*S3Manifest, error) {
+	body, _, err := r.getObject(ctx, S3ManifestKey)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest S3Manifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("parsing mirror manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// manifestEntry returns the manifest entry of editionID.
+func (r S3Reader) manifestEntry(ctx context.Context, editionID string) (*S3ManifestEntry, error) {
+	manifest, err := r.manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range manifest.Databases {
+		if entry.EditionID == editionID {
+			if entry.Object == "" {
+				return nil, fmt.Errorf("mirror manifest has no object for edition %s", editionID)
+			}
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("mirror manifest does not contain edition %s", editionID)
+}
+
+// s3ArchiveEncoding returns the compression of the archive at key, from its
+// extension. Archives are compressed with gzip unless their key ends with
+// .tar or the .tar extension of another encoding, e.g., .tar.zst.
+func s3ArchiveEncoding(key string) encoding.Encoding {
+	for _, name := range encoding.Names() {
+		// The names come from the encoding package.
+		e, _ := encoding.Lookup(name)
+		if strings.HasSuffix(key, ".tar"+e.Extension) {
+			return e
+		}
+	}
+	e, _ := encoding.Lookup(encoding.Gzip)
+	return e
+}
+
+// getObject returns the content of the object at key and its size, if
+// known. The caller must close it.
+func (r S3Reader) getObject(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	objectURL := r.mirrorURL + "/" + strings.Join(segments, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request for %s: %w", key, err)
+	}
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+	if r.auth != nil {
+		if err := r.auth.Authenticate(req); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	response, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting %s: %w", key, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		//nolint:errcheck // we are already returning an error.
+		buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		httpErr := internal.HTTPError{
+			Body:       string(buf),
+			StatusCode: response.StatusCode,
+		}
+		return nil, 0, fmt.Errorf("getting %s: unexpected HTTP status code: %w", key, httpErr)
+	}
+
+	return response.Body, max(response.ContentLength, 0), nil
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Reader(t *testing.T) {
+	dbContent := "edition-1 content"
+
+	var archive bytes.Buffer
+	gw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: "edition-1_20240223/edition-1.mmdb",
+		Size: int64(len(dbContent)),
+	}))
+	_, err := tw.Write([]byte(dbContent))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	manifest := `{
+  "databases": [
+    {
+      "edition_id": "edition-1",
+      "md5": "618dd27a10de24809ec160d6807f363f",
+      "modified_at": "2024-02-23T10:00:00Z",
+      "object": "edition-1/edition-1_20240223.tar.gz"
+    }
+  ]
+}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/mirror/manifest.json":
+			_, err := w.Write([]byte(manifest))
+			assert.NoError(t, err)
+		case "/mirror/edition-1/edition-1_20240223.tar.gz":
+			_, err := w.Write(archive.Bytes())
+			assert.NoError(t, err)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	reader := NewS3Reader(server.URL+"/mirror/", BearerAuth("token"), nil)
+
+	res, err := reader.Download(ctx, "edition-1", "")
+	require.NoError(t, err)
+	require.True(t, res.UpdateAvailable)
+	c, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	require.NoError(t, res.Reader.Close())
+	assert.Equal(t, dbContent, string(c))
+	assert.Equal(t, "618dd27a10de24809ec160d6807f363f", res.MD5)
+	assert.Equal(t, time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC), res.LastModified)
+	assert.Equal(t, int64(archive.Len()), res.ArchiveSize)
+
+	res, err = reader.Download(ctx, "edition-1", "618dd27a10de24809ec160d6807f363f")
+	require.NoError(t, err)
+	assert.False(t, res.UpdateAvailable)
+
+	editionIDs, err := reader.EditionIDs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"edition-1"}, editionIDs)
+
+	_, err = reader.Download(ctx, "edition-2", "")
+	require.EqualError(t, err, "mirror manifest does not contain edition edition-2")
+
+	_, err = NewS3Reader(server.URL+"/mirror", nil, nil).Download(ctx, "edition-1", "")
+	require.ErrorContains(t, err, "getting manifest.json: unexpected HTTP status code")
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// WithTrace makes the client write to w a JSON object per line for each
+// stage of the requests of its downloads: resolve, connect, tls,
+// first_byte, and done once a Download call completed. Each object has the
+// elapsed_seconds since the start of the call and the duration_seconds of
+// the stage, so that performance can be compared across versions. The
+// attempt is the number of the call for the edition, counting the retries.
+func WithTrace(w io.Writer) Option {
+	return func(c *Client) {
+		c.trace = &traceWriter{
+			enc:      json.NewEncoder(w),
+			attempts: map[string]int{},
+		}
+	}
+}
+
+// traceWriter writes trace events as JSON lines, one at a time as a Client
+// may be used concurrently. It is shared by the copies of the Client.
+type traceWriter struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	attempts map[string]int
+}
+
+// traceEvent is a stage of a request made by an attempt at downloading an
+// edition.
+type traceEvent struct {
+	Time      time.Time `json:"time"`
+	EditionID string    `json:"edition_id"`
+	Attempt   int       `json:"attempt"`
+	Stage     string    `json:"stage"`
+	// Host is the host and port of the request, and Addr the address
+	// connected to.
+	Host     string  `json:"host,omitempty"`
+	Addr     string  `json:"addr,omitempty"`
+	Elapsed  float64 `json:"elapsed_seconds"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// start starts tracing a Download call for editionID.
+func (w *traceWriter) start(editionID string) *requestTrace {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts[editionID]++
+	return &requestTrace{
+		out:          w,
+		editionID:    editionID,
+		attempt:      w.attempts[editionID],
+		start:        time.Now(),
+		connectStart: map[string]time.Time{},
+	}
+}
+
+func (w *traceWriter) write(event traceEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.enc.Encode(event) //nolint:errcheck // tracing mustn't fail downloads.
+}
+
+// requestTrace traces the requests of a Download call.
+type requestTrace struct {
+	out       *traceWriter
+	editionID string
+	attempt   int
+	start     time.Time
+
+	mu           sync.Mutex
+	host         string
+	dnsStart     time.Time
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+}
+
+// clientTrace returns the hooks emitting the events of the requests.
+// Connections to several addresses may be attempted concurrently.
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.host = hostPort
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.emit("resolve", "", t.dnsStart, info.Err)
+		},
+		ConnectStart: func(_, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connectStart[addr] = time.Now()
+		},
+		ConnectDone: func(_, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.emit("connect", addr, t.connectStart[addr], err)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.emit("tls", "", t.tlsStart, err)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.emit("first_byte", "", t.wroteRequest, nil)
+		},
+	}
+}
+
+// done emits the done event of the call, which failed with err if not
+// nil.
+func (t *requestTrace) done(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.emit("done", "", t.start, err)
+}
+
+// emit emits the event of stage, which started at since. t.mu must be held.
+func (t *requestTrace) emit(stage, addr string, since time.Time, err error) {
+	now := time.Now()
+	event := traceEvent{
+		Time:      now.UTC(),
+		EditionID: t.editionID,
+		Attempt:   t.attempt,
+		Stage:     stage,
+		Host:      t.host,
+		Addr:      addr,
+		Elapsed:   now.Sub(t.start).Seconds(),
+	}
+	if !since.IsZero() {
+		event.Duration = now.Sub(since).Seconds()
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	t.out.write(event)
+}
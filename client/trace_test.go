@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTrace(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"databases":[{"edition_id":"GeoLite2-City","md5":"a","date":"2024-02-23"}]}`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	c, err := New(42, "000000000001", WithEndpoint(server.URL), WithTrace(&out))
+	require.NoError(t, err)
+
+	_, err = c.Download(context.Background(), "GeoLite2-City", "a")
+	require.Error(t, err)
+	res, err := c.Download(context.Background(), "GeoLite2-City", "a")
+	require.NoError(t, err)
+	require.False(t, res.UpdateAvailable)
+
+	var events []traceEvent
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event traceEvent
+		require.NoError(t, decoder.Decode(&event))
+		require.Equal(t, "GeoLite2-City", event.EditionID)
+		require.GreaterOrEqual(t, event.Elapsed, event.Duration)
+		events = append(events, event)
+	}
+
+	var stages []string
+	for _, event := range events {
+		stages = append(stages, event.Stage)
+	}
+	// The connection is reused by the second call.
+	require.Equal(t, []string{"connect", "first_byte", "done", "first_byte", "done"}, stages)
+	require.Equal(t, 1, events[2].Attempt)
+	require.NotEmpty(t, events[2].Error)
+	require.Equal(t, 2, events[4].Attempt)
+	require.Empty(t, events[4].Error)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// benchOptions are the flags of the bench command.
+type benchOptions struct {
+	configFile string
+	downloads  int
+	json       bool
+	verbose    bool
+}
+
+// newBenchCommand returns the bench command, which is hidden as it is meant
+// for comparing configurations rather than for regular use.
+func newBenchCommand() *command {
+	var opts benchOptions
+
+	return &command{
+		name:   "bench",
+		args:   "*EDITION_ID*",
+		short:  "Measure the downloads of an edition",
+		hidden: true,
+		long: "Download the given edition several times with the HTTP client, " +
+			"proxy and host of the configuration, and report the percentiles of " +
+			"the latency, until the response is received, and of the duration, " +
+			"until the database is received, along with the throughput, e.g., to " +
+			"compare proxy configurations or mirrors. The databases are discarded " +
+			"and the installed ones are left untouched. Set `GEOIPUPDATE_HOST` to " +
+			"benchmark a local server instead, e.g., a mock of the MaxMind " +
+			"servers. With `--json`, the result is written as a JSON " +
+			"object, with durations in nanoseconds.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.IntVarP(&opts.downloads, "downloads", "n", 10, "Number of downloads")
+			annotate(fs, "downloads", metavarAnnotation, "N")
+			fs.BoolVar(&opts.json, "json", false, "Output the result in JSON format")
+			fs.BoolVarP(&opts.verbose, "verbose", "v", false, "Use verbose output")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) != 1 {
+				return newUsageError("an edition ID is required")
+			}
+			return runBench(&opts, args[0])
+		},
+		complete: completeEditionIDs,
+	}
+}
+
+// runBench benchmarks the downloads of editionID.
+func runBench(opts *benchOptions, editionID string) error {
+	options := []geoipupdate.Option{geoipupdate.WithConfigFile(opts.configFile)}
+	if opts.verbose {
+		options = append(options, geoipupdate.WithVerbose)
+	}
+	config, err := geoipupdate.NewConfig(options...)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	u, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return fmt.Errorf("initializing updater: %w", err)
+	}
+
+	res, err := u.Bench(context.Background(), editionID, opts.downloads)
+	if err != nil {
+		return fmt.Errorf("benchmarking downloads: %w", err)
+	}
+
+	if opts.json {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling result: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf(
+		"%s: %d downloads of %d bytes, %.1f MiB/s\n\n",
+		res.EditionID,
+		res.Downloads,
+		res.Bytes,
+		res.Throughput/(1<<20),
+	)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tP50\tP90\tP99\tMAX")
+	for _, row := range []struct {
+		name string
+		p    geoipupdate.BenchPercentiles
+	}{
+		{"latency", res.Latency},
+		{"duration", res.Duration},
+	} {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\n",
+			row.name,
+			row.p.P50.Round(time.Millisecond),
+			row.p.P90.Round(time.Millisecond),
+			row.p.P99.Round(time.Millisecond),
+			row.p.Max.Round(time.Millisecond),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// ciCacheMaxAge is the CacheMaxAge used with --ci when the configuration
+// doesn't set one.
+const ciCacheMaxAge = time.Hour
+
+// ciWriter writes the workflow commands of GitHub Actions. Other CI systems
+// show them as regular output.
+type ciWriter struct {
+	w io.Writer
+}
+
+// group starts a collapsible group of output lines named title.
+func (c ciWriter) group(title string) {
+	fmt.Fprintf(c.w, "::group::%s\n", ciEscape(title))
+}
+
+// endGroup ends the current group.
+func (c ciWriter) endGroup() {
+	fmt.Fprintln(c.w, "::endgroup::")
+}
+
+// annotate creates an annotation of the given level, error or warning.
+func (c ciWriter) annotate(level, title, message string) {
+	fmt.Fprintf(c.w, "::%s title=%s::%s\n", level, ciEscapeProperty(title), ciEscape(message))
+}
+
+// ciEscape escapes the data of a workflow command.
+func ciEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// ciEscapeProperty escapes a property value of a workflow command.
+func ciEscapeProperty(s string) string {
+	return strings.NewReplacer(
+		"%", "%25",
+		"\r", "%0D",
+		"\n", "%0A",
+		":", "%3A",
+		",", "%2C",
+	).Replace(s)
+}
+
+// writeCIOutputs sets the step outputs of a run that processed editions:
+// updated, true if any edition was updated, and updated-editions, the
+// space-separated IDs of those editions. It does nothing outside of GitHub
+// Actions, i.e., if GITHUB_OUTPUT is not set.
+func writeCIOutputs(editions []database.ReadResult) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+
+	var updated []string
+	for _, edition := range editions {
+		if edition.NewHash != edition.OldHash {
+			updated = append(updated, edition.EditionID)
+		}
+	}
+
+	//nolint:gosec // the runner owns this file.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening step output file: %w", err)
+	}
+	_, err = fmt.Fprintf(
+		f,
+		"updated=%t\nupdated-editions=%s\n",
+		len(updated) > 0,
+		strings.Join(updated, " "),
+	)
+	if err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("writing step outputs: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing step output file: %w", err)
+	}
+	return nil
+}
+
+// runCIUpdate runs u in CI mode: its output is grouped, failures and
+// warnings, starting with the config warnings, are annotated, and the step
+// outputs are set.
+func runCIUpdate(u *geoipupdate.Updater, warnings []geoipupdate.Warning) error {
+	ci := ciWriter{w: os.Stdout}
+	for _, w := range warnings {
+		ci.annotate("warning", w.Code, w.Message)
+	}
+	defer func() {
+		for _, w := range u.Warnings() {
+			// Deprecated options are also config warnings.
+			if !slices.Contains(warnings, w) {
+				ci.annotate("warning", w.Code, w.Message)
+			}
+		}
+	}()
+
+	ci.group("Updating GeoIP databases")
+	editions, err := u.RunEditions(context.Background())
+	ci.endGroup()
+	if err != nil {
+		ci.annotate("error", "geoipupdate", err.Error())
+		return fmt.Errorf("retrieving updates: %w", err)
+	}
+
+	for _, edition := range editions {
+		if edition.NewHash != edition.OldHash {
+			fmt.Fprintf(os.Stdout, "Updated %s\n", edition.EditionID)
+		}
+	}
+	return writeCIOutputs(editions)
+}
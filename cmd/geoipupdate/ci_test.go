@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestCIWriter(t *testing.T) {
+	var buf bytes.Buffer
+	ci := ciWriter{w: &buf}
+
+	ci.group("Updating")
+	ci.annotate("warning", "proxy-credentials-in-url", "100% risky:\nreally")
+	ci.endGroup()
+
+	require.Equal(
+		t,
+		"::group::Updating\n"+
+			"::warning title=proxy-credentials-in-url::100%25 risky:%0Areally\n"+
+			"::endgroup::\n",
+		buf.String(),
+	)
+}
+
+func TestWriteCIOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	require.NoError(t, writeCIOutputs([]database.ReadResult{
+		{EditionID: "GeoLite2-ASN", OldHash: "A", NewHash: "A"},
+	}))
+	require.NoError(t, writeCIOutputs([]database.ReadResult{
+		{EditionID: "GeoLite2-ASN", OldHash: "A", NewHash: "A"},
+		{EditionID: "GeoLite2-City", OldHash: "B", NewHash: "C"},
+		{EditionID: "GeoLite2-Country", OldHash: "D", NewHash: "E"},
+	}))
+
+	outputs, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"updated=false\nupdated-editions=\n"+
+			"updated=true\nupdated-editions=GeoLite2-City GeoLite2-Country\n",
+		string(outputs),
+	)
+}
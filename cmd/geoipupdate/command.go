@@ -0,0 +1,365 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// Flag annotations used to document flags beyond their usage string.
+const (
+	// docAnnotation is the longer description used in the man page.
+	docAnnotation = "doc"
+	// metavarAnnotation is the name of the flag value in synopses.
+	metavarAnnotation = "metavar"
+)
+
+// command is a node of the command tree. The help output, the man page, and
+// the shell completion are all derived from the tree.
+type command struct {
+	name string
+	// args is the synopsis of the positional arguments, with placeholders
+	// emphasized as in the man page, e.g., "[*EDITION_ID*...]".
+	args string
+	// short is a one-line description.
+	short string
+	// long is a description made of paragraphs separated by blank lines. It
+	// may use the man page's markdown and the CONFFILE and DATADIR
+	// placeholders.
+	long   string
+	hidden bool
+	// rawArgs disables flag parsing, passing all arguments to run.
+	rawArgs bool
+	// flags defines the flags of the command.
+	flags func(*flag.FlagSet)
+	// run runs the command. It is nil for commands only grouping
+	// subcommands.
+	run func(c *command, args []string) error
+	// complete returns the suggestions for positional arguments, given the
+	// flags parsed so far.
+	complete    func(*flag.FlagSet) []string
+	subcommands []*command
+	parent      *command
+}
+
+// usageError is returned by commands called with invalid arguments.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func newUsageError(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// exitError is returned by commands that must exit with a specific status,
+// e.g., because the update succeeded with warnings.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+// setParents links the subcommands of c to their parents.
+func (c *command) setParents() {
+	for _, sub := range c.subcommands {
+		sub.parent = c
+		sub.setParents()
+	}
+}
+
+// path returns the space-separated names of c and its ancestors.
+func (c *command) path() string {
+	if c.parent == nil {
+		return c.name
+	}
+	return c.parent.path() + " " + c.name
+}
+
+func (c *command) subcommand(name string) *command {
+	for _, sub := range c.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// visibleSubcommands returns the subcommands shown in help output.
+func (c *command) visibleSubcommands() []*command {
+	var subs []*command
+	for _, sub := range c.subcommands {
+		if !sub.hidden {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// flagSet returns a new flag set with the flags of c.
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.path(), flag.ContinueOnError)
+	fs.SortFlags = false
+	fs.SetOutput(io.Discard)
+	if c.flags != nil {
+		c.flags(fs)
+	}
+	if fs.Lookup("config-file") != nil {
+		fs.Bool("user", false, "Use the configuration file and database directory of the current user")
+	}
+	fs.BoolP("help", "h", false, "Display help and exit")
+	if c.parent == nil {
+		fs.Bool("help-all", false, "Display help for all commands and exit")
+	}
+	return fs
+}
+
+// find returns the command args designate, along with the remaining
+// arguments.
+func (c *command) find(args []string) (*command, []string) {
+	for len(args) > 0 {
+		sub := c.subcommand(args[0])
+		if sub == nil {
+			break
+		}
+		c = sub
+		args = args[1:]
+	}
+	return c, args
+}
+
+// execute runs the command designated by args.
+func (c *command) execute(args []string) error {
+	cmd, args := c.find(args)
+
+	if cmd.rawArgs {
+		return cmd.run(cmd, args)
+	}
+
+	fs := cmd.flagSet()
+	if fs.Lookup("user") != nil && hasUserFlag(args) {
+		if err := vars.UseUserDefaults(); err != nil {
+			return fmt.Errorf("finding the directories of the current user: %w", err)
+		}
+		// The defaults of the flags are set when they are defined.
+		fs = cmd.flagSet()
+	}
+	if err := fs.Parse(args); err != nil {
+		cmd.printHelp(os.Stderr)
+		return usageError{err}
+	}
+
+	if helpAll, _ := fs.GetBool("help-all"); helpAll {
+		cmd.printHelpAll(os.Stdout)
+		return nil
+	}
+	if help, _ := fs.GetBool("help"); help {
+		cmd.printHelp(os.Stdout)
+		return nil
+	}
+
+	if cmd.run == nil {
+		cmd.printHelp(os.Stderr)
+		if fs.NArg() > 0 {
+			return newUsageError("unknown command %q", fs.Arg(0))
+		}
+		return newUsageError("a command is required")
+	}
+
+	err := cmd.run(cmd, fs.Args())
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		cmd.printHelp(os.Stderr)
+	}
+	return err
+}
+
+// hasUserFlag returns whether args set --user, which must be known before
+// parsing them as it changes the defaults of other flags.
+func hasUserFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == "--user" {
+			return true
+		}
+		if value, ok := strings.CutPrefix(arg, "--user="); ok {
+			user, err := strconv.ParseBool(value)
+			return err == nil && user
+		}
+	}
+	return false
+}
+
+// printHelp prints the help of c.
+func (c *command) printHelp(w io.Writer) {
+	fs := c.flagSet()
+
+	fmt.Fprintf(w, "Usage: %s\n", plainText(c.synopsis(fs)))
+
+	if c.long != "" {
+		fmt.Fprintf(w, "\n%s\n", wrap(plainText(c.long), "", 80))
+	} else if c.short != "" {
+		fmt.Fprintf(w, "\n%s\n", c.short)
+	}
+
+	if subs := c.visibleSubcommands(); len(subs) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		width := 0
+		for _, sub := range subs {
+			width = max(width, len(sub.name))
+		}
+		for _, sub := range subs {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, sub.name, sub.short)
+		}
+	}
+
+	fmt.Fprintf(w, "\nFlags:\n%s", fs.FlagUsages())
+
+	if len(c.visibleSubcommands()) > 0 {
+		fmt.Fprintf(w, "\nRun '%s help <command>' for help on a command.\n", c.root().name)
+	}
+}
+
+// printHelpAll prints the help of c and all of its visible descendants.
+func (c *command) printHelpAll(w io.Writer) {
+	c.printHelp(w)
+	for _, sub := range c.visibleSubcommands() {
+		fmt.Fprintln(w)
+		sub.printHelpAll(w)
+	}
+}
+
+func (c *command) root() *command {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// synopsis returns the synopsis of c, in the man page's markdown.
+func (c *command) synopsis(fs *flag.FlagSet) string {
+	return strings.Join(c.synopsisParts(fs), " ")
+}
+
+// synopsisParts returns the parts of the synopsis of c, which are not to be
+// broken across lines.
+func (c *command) synopsisParts(fs *flag.FlagSet) []string {
+	parts := []string{"**" + c.path() + "**"}
+
+	if c.run == nil {
+		return append(parts, "*COMMAND*")
+	}
+
+	var shortBools []string
+	var others []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "help-all" {
+			return
+		}
+		if f.Value.Type() == "bool" {
+			if f.Shorthand != "" {
+				shortBools = append(shortBools, f.Shorthand)
+			} else {
+				others = append(others, "[--"+f.Name+"]")
+			}
+			return
+		}
+		name := "--" + f.Name
+		if f.Shorthand != "" {
+			name = "-" + f.Shorthand
+		}
+		others = append(others, fmt.Sprintf("[%s *%s*]", name, metavar(f)))
+	})
+
+	if len(shortBools) > 0 {
+		parts = append(parts, "[-"+strings.Join(shortBools, "")+"]")
+	}
+	parts = append(parts, others...)
+	if c.args != "" {
+		parts = append(parts, c.args)
+	}
+	return parts
+}
+
+// metavar returns the name of the value of f in synopses.
+func metavar(f *flag.Flag) string {
+	if v, ok := f.Annotations[metavarAnnotation]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return strings.ToUpper(f.Value.Type())
+}
+
+// annotate sets an annotation of a flag defined in fs.
+func annotate(fs *flag.FlagSet, name, key, value string) {
+	// SetAnnotation only fails for unknown flags.
+	if err := fs.SetAnnotation(name, key, []string{value}); err != nil {
+		panic(err)
+	}
+}
+
+// plainText converts the man page's markdown and placeholders for display
+// in a terminal. Emphasis markers are removed, but not the asterisks of
+// code spans, e.g., `GeoIP2-*`.
+func plainText(s string) string {
+	var b strings.Builder
+	code := false
+	for _, r := range s {
+		switch {
+		case r == '`':
+			code = !code
+		case r == '*' && !code:
+			// An emphasis marker.
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.NewReplacer(
+		"CONFFILE", vars.DefaultConfigFile,
+		"DATADIR", vars.DefaultDatabaseDirectory,
+	).Replace(b.String())
+}
+
+// wrap wraps each paragraph of s at width columns, prefixing lines with
+// indent.
+func wrap(s, indent string, width int) string {
+	paragraphs := strings.Split(strings.TrimSpace(s), "\n\n")
+	wrapped := make([]string, 0, len(paragraphs))
+	for _, p := range paragraphs {
+		wrapped = append(wrapped, wrapWords(strings.Fields(p), indent, width))
+	}
+	return strings.Join(wrapped, "\n\n")
+}
+
+// wrapWords joins words into lines of at most width columns, unless a word
+// is longer, prefixing lines with indent.
+func wrapWords(words []string, indent string, width int) string {
+	var lines []string
+	line := indent
+	for _, word := range words {
+		if line != indent && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += word
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n")
+}
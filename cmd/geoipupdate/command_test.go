@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+func TestHasUserFlag(t *testing.T) {
+	tests := []struct {
+		description string
+		args        []string
+		expected    bool
+	}{
+		{
+			description: "no flags",
+			args:        nil,
+			expected:    false,
+		},
+		{
+			description: "--user",
+			args:        []string{"-v", "--user", "GeoLite2-City"},
+			expected:    true,
+		},
+		{
+			description: "--user=true",
+			args:        []string{"--user=true"},
+			expected:    true,
+		},
+		{
+			description: "--user=false",
+			args:        []string{"--user=false"},
+			expected:    false,
+		},
+		{
+			description: "after --",
+			args:        []string{"--", "--user"},
+			expected:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expected, hasUserFlag(test.args))
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := map[string]string{
+		"**geoipupdate** [*EDITION_ID*...]":        "geoipupdate [EDITION_ID...]",
+		"patterns, e.g., `GeoIP2-*`.":              "patterns, e.g., GeoIP2-*.",
+		"*emphasis* and `code with **asterisks**`": "emphasis and code with **asterisks**",
+	}
+	for markdown, expected := range tests {
+		assert.Equal(t, expected, plainText(markdown))
+	}
+}
+
+func TestHelpKeepsCodeSpans(t *testing.T) {
+	var buf bytes.Buffer
+	newCommandTree().printHelp(&buf)
+	assert.Contains(t, buf.String(), "Usage: geoipupdate [")
+	assert.Contains(t, buf.String(), "e.g., GeoIP2-*.")
+}
+
+func TestUserFlag(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the XDG directories are only used on Linux")
+	}
+
+	configHome := t.TempDir()
+	dataHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	// Restored by Setenv at the end of the test.
+	t.Setenv("GEOIPUPDATE_CONF_FILE", "")
+	require.NoError(t, os.Unsetenv("GEOIPUPDATE_CONF_FILE"))
+
+	configFile := filepath.Join(configHome, "geoipupdate", "config")
+	require.NoError(t, os.MkdirAll(filepath.Dir(configFile), 0o750))
+	require.NoError(t, os.WriteFile(configFile, []byte("AccountID 123\n"), 0o600))
+
+	defaultConfigFile := vars.DefaultConfigFile
+	defaultDatabaseDirectory := vars.DefaultDatabaseDirectory
+	t.Cleanup(func() {
+		vars.DefaultConfigFile = defaultConfigFile
+		vars.DefaultDatabaseDirectory = defaultDatabaseDirectory
+	})
+
+	var gotConfigFile string
+	cmd := &command{
+		name: "geoipupdate",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(&gotConfigFile, "config-file", "f", configFileDefault(), "Configuration file")
+		},
+		run: func(*command, []string) error {
+			return nil
+		},
+	}
+
+	require.NoError(t, cmd.execute([]string{"--user"}))
+	assert.Equal(t, configFile, gotConfigFile)
+	assert.Equal(t, filepath.Join(dataHome, "geoipupdate"), vars.DefaultDatabaseDirectory)
+
+	// An explicit config file still wins.
+	require.NoError(t, cmd.execute([]string{"--user", "-f", "/etc/GeoIP.conf"}))
+	assert.Equal(t, "/etc/GeoIP.conf", gotConfigFile)
+}
@@ -0,0 +1,849 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// newCommandTree returns the command line surface of geoipupdate.
+func newCommandTree() *command {
+	var opts updateOptions
+
+	root := &command{
+		name:  "geoipupdate",
+		args:  "[*EDITION_ID*...]",
+		short: "Update GeoIP2 and GeoLite2 databases",
+		long: "`geoipupdate` automatically updates GeoIP2 and GeoLite2 databases. " +
+			"The program connects to the MaxMind GeoIP Update server to check for " +
+			"new databases. If a new database is available, the program will " +
+			"download and install it. If edition IDs are given as arguments, only " +
+			"these editions are updated rather than those configured with " +
+			"`EditionIDs`. Like `EditionIDs`, the arguments may be edition groups " +
+			"or patterns, e.g., `GeoIP2-*`.\n\n" +
+			"If you are using a firewall, you must have the DNS and HTTPS ports open.",
+		flags: updateFlags(&opts),
+		run: func(_ *command, args []string) error {
+			return runUpdate(&opts, args)
+		},
+		complete: completeEditionIDs,
+		subcommands: []*command{
+			newApplyCommand(),
+			newCompletionCommand(),
+			newConfigCommand(),
+			newCtlCommand(),
+			newDaemonCommand(),
+			newFetchCommand(),
+			newFleetStatusCommand(),
+			newHelpCommand(),
+			newHistoryCommand(),
+			newInstallScheduleCommand(),
+			newLambdaCommand(),
+			newPlanCommand(),
+			newSeedCommand(),
+			newSelfUpdateCommand(),
+			newUninstallScheduleCommand(),
+			newBenchCommand(),
+			newCompleteCommand(),
+		},
+	}
+
+	root.setParents()
+	return root
+}
+
+func newConfigCommand() *command {
+	var migrate migrateOptions
+	var show showOptions
+	var validate validateOptions
+
+	return &command{
+		name:  "config",
+		short: "Manage the configuration file",
+		subcommands: []*command{
+			{
+				name:  "migrate",
+				short: "Convert a GeoIP.conf file to the YAML format",
+				long: "Convert the configuration file given by `-f`, which defaults " +
+					"to CONFFILE, to the YAML format described in `GeoIP.conf`(5). " +
+					"Deprecated settings, GeoIP Legacy product IDs, and update hosts " +
+					"equivalent to the default are dropped with a warning. The result " +
+					"is written to stdout, or to the file given by `-o`, which must " +
+					"not exist yet.",
+				flags: func(fs *flag.FlagSet) {
+					fs.StringVarP(
+						&migrate.configFile,
+						"config-file",
+						"f",
+						vars.DefaultConfigFile,
+						"GeoIP.conf file to migrate",
+					)
+					annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+					fs.StringVarP(
+						&migrate.outputFile,
+						"output-file",
+						"o",
+						"",
+						"Write the YAML configuration to this file rather than to stdout",
+					)
+					annotate(fs, "output-file", metavarAnnotation, "OUTPUT_FILE")
+				},
+				run: func(_ *command, args []string) error {
+					if len(args) > 0 {
+						return newUsageError("unexpected argument %q", args[0])
+					}
+					return runConfigMigrate(&migrate)
+				},
+			},
+			{
+				name:  "show",
+				args:  "[*EDITION_ID*...]",
+				short: "Display the effective configuration and where each value comes from",
+				long: "Display the configuration a run with the same flags and " +
+					"environment would use, along with the source of each value: " +
+					"`default`, `file` for the configuration file given by `-f`, " +
+					"`env` for the environment, or `flag` for the command line. " +
+					"Later sources take precedence over earlier ones, in this order. " +
+					"Values computed from others, e.g., the default `LockFile`, are " +
+					"reported as defaults. The keys are those of the YAML format " +
+					"described in `GeoIP.conf`(5), and secrets such as the license " +
+					"key are redacted.",
+				flags: func(fs *flag.FlagSet) {
+					fs.StringVarP(
+						&show.configFile,
+						"config-file",
+						"f",
+						configFileDefault(),
+						"Configuration file",
+					)
+					annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+					fs.StringVarP(
+						&show.databaseDirectory,
+						"database-directory",
+						"d",
+						"",
+						"Store databases in this directory (uses config if not specified)",
+					)
+					annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+					fs.IntVar(&show.parallelism, "parallelism", 0, "Set the number of parallel database downloads")
+					annotate(fs, "parallelism", metavarAnnotation, "N")
+					fs.BoolVar(&show.json, "json", false, "Output the configuration in JSON format")
+				},
+				run: func(_ *command, args []string) error {
+					return runConfigShow(&show, args)
+				},
+			},
+			{
+				name:  "validate",
+				short: "Validate the configuration and report risky settings",
+				long: "Load the configuration, from the file given by `-f` and the " +
+					"environment, and report whether it is valid. Risky settings are " +
+					"reported as warnings, each identified by a code: " +
+					"`world-readable-license-key` when the configuration file contains " +
+					"a license key and is readable by all users, " +
+					"`proxy-credentials-in-url` when the proxy URL contains " +
+					"credentials, `parallelism-exceeds-editions` when `Parallelism` is " +
+					"greater than the number of editions, " +
+					"`preserve-file-times-freshness` when `PreserveFileTimes` is set, " +
+					"as freshness checks based on modification times then see release " +
+					"dates, `deprecated-option` for each deprecated setting of the " +
+					"configuration file, and `alerts-without-notify` when " +
+					"`AlertAfterFailures` is set without `Notify`. With `--json`, the warnings are written to stdout as a " +
+					"JSON object. Warnings are also logged by regular runs in verbose " +
+					"mode.",
+				flags: func(fs *flag.FlagSet) {
+					fs.StringVarP(
+						&validate.configFile,
+						"config-file",
+						"f",
+						configFileDefault(),
+						"Configuration file to validate",
+					)
+					annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+					fs.BoolVar(&validate.json, "json", false, "Output the result in JSON format")
+				},
+				run: func(_ *command, args []string) error {
+					if len(args) > 0 {
+						return newUsageError("unexpected argument %q", args[0])
+					}
+					return runConfigValidate(&validate)
+				},
+			},
+		},
+	}
+}
+
+func newCtlCommand() *command {
+	var opts ctlOptions
+
+	flags := func(fs *flag.FlagSet) {
+		fs.StringVarP(
+			&opts.configFile,
+			"config-file",
+			"f",
+			configFileDefault(),
+			"Configuration file of the daemon",
+		)
+		annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+		fs.StringVar(&opts.socket, "socket", "", "Control socket of the daemon")
+		annotate(fs, "socket", metavarAnnotation, "SOCKET")
+		annotate(
+			fs,
+			"socket",
+			docAnnotation,
+			"Control socket of the daemon. It defaults to the `LockFile` of the "+
+				"configuration file given by `-f` followed by `.sock`.",
+		)
+		fs.StringVar(&opts.profile, "profile", "", "Profile of the daemon to control")
+		annotate(fs, "profile", metavarAnnotation, "NAME")
+		annotate(
+			fs,
+			"profile",
+			docAnnotation,
+			"Profile of the daemon to control. It is required if the daemon "+
+				"manages several profiles.",
+		)
+	}
+	request := func(name, short, long, method, path string) *command {
+		return &command{
+			name:  name,
+			short: short,
+			long:  long,
+			flags: flags,
+			run: func(_ *command, args []string) error {
+				if len(args) > 0 {
+					return newUsageError("unexpected argument %q", args[0])
+				}
+				return runCtl(&opts, method, path)
+			},
+		}
+	}
+
+	return &command{
+		name:  "ctl",
+		short: "Control a running daemon",
+		long: "Send a request to the control socket of a running `daemon`. The " +
+			"response is written to stdout as a JSON object.",
+		subcommands: []*command{
+			request(
+				"last-report",
+				"Print the report of the last successful run",
+				"Print the report, as with the `report` value of `OutputFormat`, "+
+					"of the last successful run of the daemon.",
+				http.MethodGet,
+				"/report",
+			),
+			request(
+				"profiles",
+				"Print the status of every profile",
+				"Print the status, as with `status`, of every profile of the daemon.",
+				http.MethodGet,
+				"/profiles",
+			),
+			request(
+				"reload-config",
+				"Reload the configuration of the daemon",
+				"Make the daemon reload its configuration, which is used from the "+
+					"next run on. The current configuration is kept if the new one is "+
+					"invalid.",
+				http.MethodPost,
+				"/reload",
+			),
+			request(
+				"run-now",
+				"Trigger a run of the daemon",
+				"Make the daemon run an update as soon as the current run, if any, "+
+					"is done, rather than waiting for the next scheduled run.",
+				http.MethodPost,
+				"/run",
+			),
+			request(
+				"status",
+				"Print the status of the daemon",
+				"Print whether the daemon is running an update, when its last runs "+
+					"started, finished, and succeeded, the error of the last run if it "+
+					"failed, when the next run is scheduled, and when the configuration "+
+					"was loaded.",
+				http.MethodGet,
+				"/status",
+			),
+		},
+	}
+}
+
+func newDaemonCommand() *command {
+	var opts daemonOptions
+
+	return &command{
+		name:  "daemon",
+		short: "Update databases periodically",
+		long: "Update the databases immediately, then on the `Schedule` " +
+			"described in `GeoIP.conf`(5), or every `--interval`, until " +
+			"interrupted by SIGTERM. The configuration is reloaded on SIGHUP. " +
+			"Failed runs are retried at the next run. An error " +
+			"repeated by consecutive runs is logged once an hour, with the " +
+			"number of runs it failed, and every failed run is counted in the " +
+			"`runs_failed` of the status. The warnings of the last run, e.g., " +
+			"editions with no build as recent as their `ExpectedCadence` " +
+			"implies, are in the `warnings` of the status. The daemon " +
+			"listens on a Unix socket, readable only by its user, for the " +
+			"requests of `ctl`: triggering a run, querying its status or the " +
+			"report of its last run, and reloading its configuration. The API is " +
+			"HTTP with JSON responses: `POST /run`, `GET /status`, `GET /report`, " +
+			"`POST /reload`, and `GET /profiles`. Requests take the profile, if " +
+			"any, as the `profile` query parameter. Windows supports Unix sockets " +
+			"from Windows 10 version 1803 on. If `PIDFile` is set, the daemon " +
+			"writes its process ID to it while it runs.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.StringVarP(
+				&opts.databaseDirectory,
+				"database-directory",
+				"d",
+				"",
+				"Store databases in this directory (uses config if not specified)",
+			)
+			annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+			fs.DurationVar(&opts.interval, "interval", 0, "Time between two runs (uses config if not specified)")
+			annotate(
+				fs,
+				"interval",
+				docAnnotation,
+				"Run every given duration, e.g., `6h`, rather than on the "+
+					"`Schedule` of the configuration, which defaults to every 12 "+
+					"hours. `ScheduleJitter` still applies.",
+			)
+			fs.BoolVar(&opts.checkOnly, "check-only", false, "Check the databases without updating them")
+			annotate(
+				fs,
+				"check-only",
+				docAnnotation,
+				"Only check whether the installed databases are the latest builds, "+
+					"without ever downloading or writing databases, e.g., on hosts "+
+					"where they are deployed by other means. Editions that aren't "+
+					"current raise an `outdated-edition` warning. The outcome of the "+
+					"checks is recorded in the `StateFile`, exposed by the "+
+					"`geoipupdate_edition_outdated` and "+
+					"`geoipupdate_edition_last_check_timestamp_seconds` gauges of the "+
+					"`MetricsFile`, and announced to the `Notify` targets with an "+
+					"`outdated` event when an edition stops being current, and a "+
+					"`current` event once it is again. It isn't supported with "+
+					"`S3Mirror` or `OCIMirror`.",
+			)
+			fs.StringVar(&opts.socket, "socket", "", "Control socket to listen on")
+			annotate(fs, "socket", metavarAnnotation, "SOCKET")
+			annotate(
+				fs,
+				"socket",
+				docAnnotation,
+				"Control socket to listen on. It defaults to the `LockFile` "+
+					"followed by `.sock`. It is required with several profiles.",
+			)
+			fs.StringArrayVar(&opts.profiles, "profile", nil, "Manage this profile, as NAME=CONFIG_FILE")
+			annotate(fs, "profile", metavarAnnotation, "NAME=CONFIG_FILE")
+			annotate(
+				fs,
+				"profile",
+				docAnnotation,
+				"Manage the profile *NAME*, whose configuration file is "+
+					"*CONFIG_FILE*, rather than the configuration given by `-f`. "+
+					"It may be repeated to manage several profiles, e.g., different "+
+					"accounts or database directories, in one process. Each profile "+
+					"runs on its own schedule, and its name labels its log messages, "+
+					"metrics, and reports. Profiles must use distinct lock files.",
+			)
+			fs.StringArrayVar(
+				&opts.profileIntervals,
+				"profile-interval",
+				nil,
+				"Set the interval of a profile, as NAME=DURATION",
+			)
+			annotate(fs, "profile-interval", metavarAnnotation, "NAME=DURATION")
+			annotate(
+				fs,
+				"profile-interval",
+				docAnnotation,
+				"Run the profile *NAME* every *DURATION* rather than every "+
+					"`--interval` or on its `Schedule`. It may be repeated.",
+			)
+			fs.StringVar(&opts.grpcListen, "grpc-listen", "", "Address to serve the gRPC admin API on")
+			annotate(fs, "grpc-listen", metavarAnnotation, "ADDRESS")
+			annotate(
+				fs,
+				"grpc-listen",
+				docAnnotation,
+				"Also serve the operations of the control socket as a gRPC service, "+
+					"over TLS, on the given *HOST*:*PORT*. The service is defined by "+
+					"`internal/admin/adminpb/admin.proto` in the source tree. It "+
+					"requires `--grpc-cert` and `--grpc-key`.",
+			)
+			fs.StringVar(&opts.grpcCert, "grpc-cert", "", "TLS certificate of the gRPC admin API")
+			annotate(fs, "grpc-cert", metavarAnnotation, "FILE")
+			fs.StringVar(&opts.grpcKey, "grpc-key", "", "TLS key of the gRPC admin API")
+			annotate(fs, "grpc-key", metavarAnnotation, "FILE")
+			fs.StringVar(
+				&opts.grpcClientCA,
+				"grpc-client-ca",
+				"",
+				"Require client certificates signed by the CAs in this file",
+			)
+			annotate(fs, "grpc-client-ca", metavarAnnotation, "FILE")
+			annotate(
+				fs,
+				"grpc-client-ca",
+				docAnnotation,
+				"Require gRPC clients to present a certificate signed by one of the "+
+					"CAs in the given PEM file, i.e., use mutual TLS. Without it, any "+
+					"client able to connect can control the daemon.",
+			)
+			fs.StringVar(&opts.pprofListen, "pprof-listen", "", "Address to serve the pprof endpoints on")
+			annotate(fs, "pprof-listen", metavarAnnotation, "ADDRESS")
+			annotate(
+				fs,
+				"pprof-listen",
+				docAnnotation,
+				"Serve the Go profiling endpoints under `/debug/pprof/` on the "+
+					"given *HOST*:*PORT*, e.g., `localhost:6060`, to diagnose the "+
+					"memory use or the CPU usage of the daemon with `go tool pprof`. "+
+					"The endpoints aren't authenticated and expose the command line "+
+					"of the daemon, so bind them to a loopback address.",
+			)
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runDaemon(&opts)
+		},
+	}
+}
+
+func newFleetStatusCommand() *command {
+	var opts fleetStatusOptions
+
+	return &command{
+		name:  "fleet-status",
+		short: "Show which build of each edition the hosts have",
+		long: "Show which build of each edition is installed on the hosts that " +
+			"upload the reports of their runs, with `ReportURL`, to the `seed` " +
+			"instance given by `--server`, so outdated hosts stand out. Builds " +
+			"are listed from the most recent one. The seed only keeps the last " +
+			"report of each host, in memory. With `--json`, the status of the " +
+			"fleet is written as returned by the `/fleet-status` endpoint of the " +
+			"seed, including the version and the last report time of each host.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&opts.server, "server", "", "URL of the seed instance")
+			annotate(fs, "server", metavarAnnotation, "URL")
+			fs.BoolVar(&opts.json, "json", false, "Output the status in JSON format")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			if opts.server == "" {
+				return newUsageError("the --server flag is required")
+			}
+			return runFleetStatus(&opts)
+		},
+	}
+}
+
+func newHelpCommand() *command {
+	var man bool
+
+	return &command{
+		name:  "help",
+		args:  "[*COMMAND*...]",
+		short: "Display help for a command",
+		long: "Display help for the given command, or for `geoipupdate` itself if " +
+			"none is given. With `--man`, print the `geoipupdate`(1) manual page, in " +
+			"markdown, instead.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&man, "man", false, "Print the manual page in markdown")
+		},
+		run: func(c *command, args []string) error {
+			root := c.root()
+			if man {
+				if len(args) > 0 {
+					return newUsageError("unexpected argument %q", args[0])
+				}
+				return writeManPage(os.Stdout, root)
+			}
+
+			cmd, rest := root.find(args)
+			if len(rest) > 0 || cmd.hidden {
+				return newUsageError("unknown command %q", strings.Join(args, " "))
+			}
+			cmd.printHelp(os.Stdout)
+			return nil
+		},
+	}
+}
+
+func newHistoryCommand() *command {
+	var opts historyOptions
+
+	return &command{
+		name:  "history",
+		args:  "[*EDITION_ID*]",
+		short: "List the past updates of the databases",
+		long: "List the past updates of the given edition, or of every edition, " +
+			"most recent first, from the `StateFile` of the configuration: when " +
+			"each database was installed and built, the MD5 sums of the replaced " +
+			"and new databases, the size of the new one, how long the update " +
+			"took, and the host it was downloaded from. The last 50 updates of " +
+			"each edition are kept. With `--json`, the updates are written as a " +
+			"JSON array.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.BoolVar(&opts.json, "json", false, "Output the history in JSON format")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 1 {
+				return newUsageError("unexpected argument %q", args[1])
+			}
+			editionID := ""
+			if len(args) == 1 {
+				editionID = args[0]
+			}
+			return runHistory(&opts, editionID)
+		},
+		complete: completeEditionIDs,
+	}
+}
+
+func newInstallScheduleCommand() *command {
+	var opts installScheduleOptions
+
+	return &command{
+		name:  "install-schedule",
+		short: "Schedule geoipupdate to run periodically",
+		long: "Schedule `geoipupdate` to run periodically using the scheduler " +
+			"native to the platform: a systemd timer on Linux, a launchd job on " +
+			"macOS, or a scheduled task on Windows. System-wide entries are " +
+			"created when run as root, and per-user entries otherwise. Any " +
+			"existing entry is replaced. The scheduled runs use the `-f` and `-d` " +
+			"values given to this command.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				"",
+				"Configuration file the scheduled runs use",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.StringVarP(
+				&opts.databaseDirectory,
+				"database-directory",
+				"d",
+				"",
+				"Database directory the scheduled runs use",
+			)
+			annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+			fs.DurationVar(&opts.interval, "interval", 12*time.Hour, "Time between two runs")
+			fs.DurationVar(&opts.splay, "splay", time.Hour, "Maximum random delay added to each run")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runInstallSchedule(&opts)
+		},
+	}
+}
+
+func newApplyCommand() *command {
+	var opts applyOptions
+
+	return &command{
+		name:  "apply",
+		short: "Perform the downloads of a plan",
+		long: "Perform exactly the downloads of the plan given by `--plan`, as " +
+			"written by `plan`, e.g., once it has been reviewed and approved. " +
+			"The configuration is loaded as by `geoipupdate` itself. Applying " +
+			"fails, leaving the remaining databases untouched, if an installed " +
+			"database or the latest build of an edition changed since the plan " +
+			"was made, in which case a new plan must be made.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.StringVarP(
+				&opts.databaseDirectory,
+				"database-directory",
+				"d",
+				"",
+				"Store databases in this directory (uses config if not specified)",
+			)
+			annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+			fs.StringVar(&opts.planFile, "plan", "", "Plan file to apply")
+			annotate(fs, "plan", metavarAnnotation, "PLAN_FILE")
+			fs.BoolVarP(&opts.verbose, "verbose", "v", false, "Use verbose output")
+			fs.BoolVarP(&opts.output, "output", "o", false, "Output download/update results in JSON format")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runApply(&opts)
+		},
+	}
+}
+
+func newPlanCommand() *command {
+	var opts planOptions
+
+	return &command{
+		name:  "plan",
+		args:  "[*EDITION_ID*...]",
+		short: "Write the downloads an update would perform",
+		long: "Check for updates of the configured editions, or of the given " +
+			"ones, without downloading them, and write the resulting plan to " +
+			"stdout, or to the file given by `-o`, as a JSON object. The plan " +
+			"lists each database to download with the MD5 sums of the installed " +
+			"and new databases and the publication date of the latter, so it can " +
+			"be reviewed before being performed with `apply`. Editions that are " +
+			"up to date are not listed. Plans are not supported with `S3Mirror` " +
+			"or `OCIMirror`.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.StringVarP(
+				&opts.databaseDirectory,
+				"database-directory",
+				"d",
+				"",
+				"Store databases in this directory (uses config if not specified)",
+			)
+			annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+			fs.StringVarP(
+				&opts.outputFile,
+				"output-file",
+				"o",
+				"",
+				"Write the plan to this file rather than to stdout",
+			)
+			annotate(fs, "output-file", metavarAnnotation, "PLAN_FILE")
+			fs.BoolVar(
+				&opts.checkDelivery,
+				"check-delivery",
+				false,
+				"Also check that the databases could be delivered",
+			)
+			annotate(
+				fs,
+				"check-delivery",
+				docAnnotation,
+				"Also check, without writing any database, that the update could "+
+					"deliver the databases: that the `DatabaseDirectory`, and the "+
+					"`TempDirectory` and `ArchiveDirectory` if used, are writable, by "+
+					"creating and removing a file in each, and that the `OCIPush` "+
+					"repository, if any, accepts pushes with the configured "+
+					"credentials, by starting and canceling an upload. No plan is "+
+					"written if a check fails.",
+			)
+		},
+		run: func(_ *command, args []string) error {
+			return runPlan(&opts, args)
+		},
+		complete: completeEditionIDs,
+	}
+}
+
+func newSeedCommand() *command {
+	var opts seedOptions
+
+	return &command{
+		name:  "seed",
+		short: "Serve the installed databases to peers",
+		long: "Serve the installed databases of the configured editions over " +
+			"HTTP, for instances whose `Peers` setting lists this one, until " +
+			"interrupted. The databases are served at `/EDITION_ID.mmdb`. Peers " +
+			"spread their downloads over all the listed instances and check the " +
+			"result against the MD5 sum from the MaxMind servers, downloading " +
+			"from them instead if that fails. Run regular updates on the seeding " +
+			"instance, e.g., with `install-schedule`, to keep the databases " +
+			"current. Access to the listening address should be restricted to " +
+			"the peers, as MaxMind databases are licensed. Instances whose " +
+			"`ReportURL` is the `/reports` endpoint of the seed, e.g., " +
+			"`http://seed-1:8080/reports`, upload the reports of their runs to " +
+			"it, authenticated with the `ReportToken` the seed is also " +
+			"configured with, and the seed serves which build of each edition " +
+			"every host has at `/fleet-status`, as shown by `fleet-status`.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.StringVar(&opts.listen, "listen", ":8080", "Address to listen on")
+			annotate(fs, "listen", metavarAnnotation, "ADDRESS")
+			annotate(
+				fs,
+				"listen",
+				docAnnotation,
+				"Address to listen on, as *HOST*:*PORT*. The default is `:8080`, "+
+					"which listens on all interfaces.",
+			)
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runSeed(&opts)
+		},
+	}
+}
+
+func newLambdaCommand() *command {
+	var opts lambdaOptions
+
+	return &command{
+		name:  "lambda",
+		short: "Run as an AWS Lambda function",
+		long: "Update the databases with each invocation of an AWS Lambda " +
+			"function using a custom runtime, e.g., `provided.al2023`, so that a " +
+			"schedule, e.g., an EventBridge rule, can replace hosts running " +
+			"`geoipupdate` from cron. The configuration is loaded once, when the " +
+			"function starts, and reused by later invocations, and an invalid " +
+			"configuration fails the function at its start. The payload of the " +
+			"invocations is ignored, and each responds with the processed " +
+			"`editions`, as in the `json` output, and the `warnings` of its run. " +
+			"A failed run fails the invocation, and each run is bounded by the " +
+			"timeout of the function. This command runs when `geoipupdate` is " +
+			"installed as the `bootstrap` of the function, in which case the " +
+			"configuration comes from the file given by `GEOIPUPDATE_CONF_FILE` " +
+			"and from the environment. Only `/tmp` is writable in Lambda, so set " +
+			"the `DatabaseDirectory` to a directory of `/tmp`, which is lost when " +
+			"Lambda stops the function, or of an Amazon EFS file system mounted by " +
+			"the function, which keeps the databases between runs. Set " +
+			"`S3Push` to publish the databases to an Amazon S3 bucket, e.g., for " +
+			"other hosts using `S3Mirror`.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.StringVarP(
+				&opts.databaseDirectory,
+				"database-directory",
+				"d",
+				"",
+				"Store databases in this directory (uses config if not specified)",
+			)
+			annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+			fs.BoolVarP(&opts.verbose, "verbose", "v", false, "Use verbose output")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runLambda(&opts)
+		},
+	}
+}
+
+func newSelfUpdateCommand() *command {
+	var opts selfUpdateOptions
+
+	return &command{
+		name:  "self-update",
+		short: "Update geoipupdate to the latest release",
+		long: "Replace the running `geoipupdate` binary with the latest release " +
+			"published on GitHub, if it is more recent. The checksums file of the " +
+			"release must carry a valid signature from the MaxMind release key, " +
+			"and the downloaded archive must match its checksum. The binary is " +
+			"replaced atomically. The proxy settings of the configuration file " +
+			"given by `-f` are used, and setting `DisableSelfUpdate` there " +
+			"disables this command, e.g., where `geoipupdate` is managed by a " +
+			"package manager or configuration management. Distribution packages " +
+			"are built without the release key, so this command is not available " +
+			"for them.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			fs.BoolVar(&opts.check, "check", false, "Only report whether a more recent release is available")
+			fs.BoolVar(
+				&opts.force,
+				"force",
+				false,
+				"Install the latest release even if it is not more recent",
+			)
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runSelfUpdate(&opts)
+		},
+	}
+}
+
+func newUninstallScheduleCommand() *command {
+	return &command{
+		name:  "uninstall-schedule",
+		short: "Remove the schedule created by install-schedule",
+		long:  "Remove the entry created by `install-schedule`.",
+		run: func(_ *command, args []string) error {
+			if len(args) > 0 {
+				return newUsageError("unexpected argument %q", args[0])
+			}
+			return runUninstallSchedule()
+		},
+	}
+}
+
+// completeEditionIDs suggests the edition IDs known from the configuration
+// file given by the -f flag and the state file.
+func completeEditionIDs(fs *flag.FlagSet) []string {
+	configFile, err := fs.GetString("config-file")
+	if err != nil {
+		configFile = configFileDefault()
+	}
+	return geoipupdate.KnownEditionIDs(configFile)
+}
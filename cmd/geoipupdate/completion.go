@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// completeCommand is the hidden command the completion scripts call to get
+// suggestions.
+const completeCommand = "__complete"
+
+// completionShells are the shells completion scripts are provided for.
+var completionShells = []string{"bash", "fish", "powershell", "zsh"}
+
+func newCompletionCommand() *command {
+	return &command{
+		name:  "completion",
+		args:  strings.Join(completionShells, "|"),
+		short: "Print the completion script for a shell",
+		long: "Print the completion script for the given shell. Besides commands " +
+			"and flags, edition IDs are suggested from the configuration file and " +
+			"the state file. For example, with bash: " +
+			"`source <(geoipupdate completion bash)`.",
+		run: func(_ *command, args []string) error {
+			if len(args) != 1 {
+				return newUsageError("a shell is required")
+			}
+			script, ok := completionScripts[args[0]]
+			if !ok {
+				return newUsageError(
+					"unsupported shell %q: use bash, fish, powershell, or zsh",
+					args[0],
+				)
+			}
+			fmt.Print(script)
+			return nil
+		},
+		complete: func(*flag.FlagSet) []string {
+			return completionShells
+		},
+	}
+}
+
+func newCompleteCommand() *command {
+	return &command{
+		name:    completeCommand,
+		hidden:  true,
+		rawArgs: true,
+		run: func(c *command, args []string) error {
+			for _, s := range complete(c.root(), args) {
+				fmt.Println(s)
+			}
+			return nil
+		},
+	}
+}
+
+// complete returns the suggestions for the last of words, the arguments
+// following the program name. Nothing is suggested for flag values, so
+// that shells fall back to completing file names.
+func complete(root *command, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	cmd := root
+	fs := cmd.flagSet()
+	positional := false
+	for i := 0; i < len(words)-1; i++ {
+		word := words[i]
+		if f := lookupFlag(fs, word); f != nil && f.Value.Type() != "bool" {
+			if i+1 == len(words)-1 {
+				// Completing a flag value.
+				return nil
+			}
+			//nolint:errcheck // an invalid value leaves the default in place.
+			_ = fs.Set(f.Name, words[i+1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if sub := cmd.subcommand(word); sub != nil && !sub.hidden && !positional {
+			cmd = sub
+			fs = cmd.flagSet()
+			continue
+		}
+		positional = true
+	}
+
+	var candidates []string
+	if strings.HasPrefix(current, "-") {
+		fs.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "--"+f.Name)
+			if f.Shorthand != "" {
+				candidates = append(candidates, "-"+f.Shorthand)
+			}
+		})
+	} else {
+		if !positional {
+			for _, sub := range cmd.visibleSubcommands() {
+				candidates = append(candidates, sub.name)
+			}
+		}
+		if cmd.complete != nil {
+			candidates = append(candidates, cmd.complete(fs)...)
+		}
+	}
+
+	var suggestions []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, current) {
+			suggestions = append(suggestions, c)
+		}
+	}
+	return suggestions
+}
+
+// lookupFlag returns the flag of fs that word, e.g., "--config-file" or
+// "-f", designates.
+func lookupFlag(fs *flag.FlagSet, word string) *flag.Flag {
+	if name, ok := strings.CutPrefix(word, "--"); ok {
+		return fs.Lookup(name)
+	}
+	if shorthand, ok := strings.CutPrefix(word, "-"); ok && len(shorthand) == 1 {
+		return fs.ShorthandLookup(shorthand)
+	}
+	return nil
+}
+
+// completionScripts are the completion scripts for each supported shell.
+// They all delegate to the __complete command.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for geoipupdate
+_geoipupdate() {
+    local IFS=$'\n'
+    COMPREPLY=($(geoipupdate __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _geoipupdate geoipupdate
+`,
+	"zsh": `#compdef geoipupdate
+# zsh completion for geoipupdate
+_geoipupdate() {
+    local -a suggestions
+    suggestions=("${(@f)$(geoipupdate __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ ${#suggestions[@]} -eq 1 && -z ${suggestions[1]} ]]; then
+        _files
+        return
+    fi
+    compadd -- "${suggestions[@]}"
+}
+compdef _geoipupdate geoipupdate
+`,
+	"fish": `# fish completion for geoipupdate
+function __geoipupdate_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    geoipupdate __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c geoipupdate -a '(__geoipupdate_complete)'
+`,
+	"powershell": `# PowerShell completion for geoipupdate
+Register-ArgumentCompleter -Native -CommandName geoipupdate -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    geoipupdate __complete @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplete(t *testing.T) {
+	var configFile string
+	editionIDs := func(fs *flag.FlagSet) []string {
+		configFile, _ = fs.GetString("config-file")
+		return []string{"GeoLite2-ASN", "GeoLite2-City"}
+	}
+
+	tests := []struct {
+		description string
+		words       []string
+		expected    []string
+		configFile  string
+	}{
+		{
+			description: "subcommands and edition IDs",
+			words:       []string{""},
+			expected: []string{
+				"apply",
+				"completion",
+				"config",
+				"ctl",
+				"daemon",
+				"fetch",
+				"fleet-status",
+				"help",
+				"history",
+				"install-schedule",
+				"lambda",
+				"plan",
+				"seed",
+				"self-update",
+				"uninstall-schedule",
+				"GeoLite2-ASN",
+				"GeoLite2-City",
+			},
+		},
+		{
+			description: "edition IDs from the given config file",
+			words:       []string{"-f", "/etc/GeoIP.conf", "GeoLite2-C"},
+			expected:    []string{"GeoLite2-City"},
+			configFile:  "/etc/GeoIP.conf",
+		},
+		{
+			description: "edition IDs after an edition ID",
+			words:       []string{"GeoLite2-City", "c"},
+			expected:    nil,
+		},
+		{
+			description: "flag value",
+			words:       []string{"--config-file", ""},
+			expected:    nil,
+		},
+		{
+			description: "nested subcommand flags",
+			words:       []string{"config", "validate", "--"},
+			expected:    []string{"--config-file", "--json", "--user", "--help"},
+		},
+		{
+			description: "subcommand arguments",
+			words:       []string{"completion", "p"},
+			expected:    []string{"powershell"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			configFile = ""
+			root := newCommandTree()
+			root.complete = editionIDs
+			require.Equal(t, test.expected, complete(root, test.words))
+			if test.configFile != "" {
+				require.Equal(t, test.configFile, configFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// migrateOptions are the flags of the config migrate command.
+type migrateOptions struct {
+	configFile string
+	outputFile string
+}
+
+// showOptions are the flags of the config show command.
+type showOptions struct {
+	configFile        string
+	databaseDirectory string
+	json              bool
+	parallelism       int
+}
+
+// validateOptions are the flags of the config validate command.
+type validateOptions struct {
+	configFile string
+	json       bool
+}
+
+// runConfigMigrate converts a GeoIP.conf file to the YAML format.
+func runConfigMigrate(opts *migrateOptions) error {
+	//nolint:gosec // we really need to read this file.
+	legacy, err := os.ReadFile(opts.configFile)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	migrated, warnings, err := geoipupdate.MigrateConfig(bytes.NewReader(legacy))
+	if err != nil {
+		return fmt.Errorf("migrating %s: %w", opts.configFile, err)
+	}
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+
+	if opts.outputFile == "" {
+		if _, err := os.Stdout.Write(migrated); err != nil {
+			return fmt.Errorf("writing configuration: %w", err)
+		}
+		return nil
+	}
+
+	// The configuration contains the license key and must not replace an
+	// existing file by accident.
+	f, err := os.OpenFile(opts.outputFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("writing configuration: %s already exists", opts.outputFile)
+		}
+		return fmt.Errorf("writing configuration: %w", err)
+	}
+	if _, err := f.Write(migrated); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing configuration: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing configuration: %w", err)
+	}
+	return nil
+}
+
+// runConfigShow displays the effective configuration, for the editions
+// editionIDs if given, along with the source of each value.
+func runConfigShow(opts *showOptions, editionIDs []string) error {
+	values, err := geoipupdate.ShowConfig(
+		geoipupdate.WithConfigFile(opts.configFile),
+		geoipupdate.WithDatabaseDirectory(opts.databaseDirectory),
+		geoipupdate.WithEditionIDs(editionIDs),
+		geoipupdate.WithParallelism(opts.parallelism),
+	)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	if opts.json {
+		result, err := json.Marshal(values)
+		if err != nil {
+			return fmt.Errorf("marshaling configuration: %w", err)
+		}
+		fmt.Println(string(result))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, v := range values {
+		value, ok := v.Value.(string)
+		if !ok {
+			encoded, err := json.Marshal(v.Value)
+			if err != nil {
+				return fmt.Errorf("marshaling %s: %w", v.Key, err)
+			}
+			value = string(encoded)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, value, v.Source)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing configuration: %w", err)
+	}
+	return nil
+}
+
+// runConfigValidate loads the configuration and reports risky settings.
+func runConfigValidate(opts *validateOptions) error {
+	config, err := geoipupdate.NewConfig(geoipupdate.WithConfigFile(opts.configFile))
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	warnings := geoipupdate.LintConfig(config, opts.configFile)
+
+	if opts.json {
+		if warnings == nil {
+			warnings = []geoipupdate.Warning{}
+		}
+		result, err := json.Marshal(struct {
+			Warnings []geoipupdate.Warning `json:"warnings"`
+		}{warnings})
+		if err != nil {
+			return fmt.Errorf("marshaling result: %w", err)
+		}
+		fmt.Println(string(result))
+		return nil
+	}
+
+	for _, w := range warnings {
+		log.Printf("Warning [%s]: %s", w.Code, w.Message)
+	}
+	log.Printf("Configuration is valid")
+	return nil
+}
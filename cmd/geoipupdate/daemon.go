@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/maxmind/geoipupdate/v7/internal/admin"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// daemonOptions are the flags of the daemon command.
+type daemonOptions struct {
+	checkOnly         bool
+	configFile        string
+	databaseDirectory string
+	// flagOptions are options of the configuration set by other flags,
+	// e.g., those of --daemon.
+	flagOptions  []geoipupdate.Option
+	grpcCert     string
+	grpcClientCA string
+	grpcKey      string
+	grpcListen   string
+	interval     time.Duration
+	pprofListen  string
+	// profiles are the NAME=CONFIG_FILE values of --profile.
+	profiles []string
+	// profileIntervals are the NAME=DURATION values of --profile-interval.
+	profileIntervals []string
+	socket           string
+}
+
+// runDaemon runs updates periodically, serving the control API, until
+// interrupted.
+func runDaemon(opts *daemonOptions) error {
+	if opts.grpcListen != "" && (opts.grpcCert == "" || opts.grpcKey == "") {
+		return newUsageError("--grpc-listen requires --grpc-cert and --grpc-key")
+	}
+
+	profiles, err := daemonProfiles(opts)
+	if err != nil {
+		return err
+	}
+	d, err := geoipupdate.NewDaemon(profiles)
+	if err != nil {
+		return err
+	}
+
+	socket := opts.socket
+	if socket == "" {
+		socket = d.ControlSocket()
+	}
+	listener, err := geoipupdate.ListenControl(socket)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range d.PIDFiles() {
+		if err := geoipupdate.WritePIDFile(path); err != nil {
+			listener.Close()
+			return err
+		}
+		defer removePIDFile(path)
+	}
+
+	// The control socket is kept by the user the daemon switches to, so
+	// that it can remove it on exit.
+	if err := d.DropPrivileges(socket); err != nil {
+		listener.Close()
+		return fmt.Errorf("dropping privileges: %w", err)
+	}
+	if err := d.Sandbox(append(d.PIDFiles(), socket)...); err != nil {
+		listener.Close()
+		return fmt.Errorf("sandboxing: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload:
+				reloadProfiles(d)
+			}
+		}
+	}()
+
+	server := &http.Server{
+		Handler:           d.ControlHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	if opts.grpcListen != "" {
+		grpcServer, err := serveGRPC(d, opts)
+		if err != nil {
+			server.Close()
+			return err
+		}
+		defer grpcServer.GracefulStop()
+	}
+
+	if opts.pprofListen != "" {
+		pprofServer, err := servePprof(opts.pprofListen)
+		if err != nil {
+			server.Close()
+			return err
+		}
+		defer pprofServer.Close()
+	}
+
+	operation := "Updating"
+	if opts.checkOnly {
+		operation = "Checking"
+	}
+	var schedules []string
+	for _, status := range d.Statuses() {
+		if status.Profile == "" {
+			schedules = append(schedules, status.Schedule)
+		} else {
+			schedules = append(schedules, status.Profile+"="+status.Schedule)
+		}
+	}
+	log.Printf(
+		"%s databases on the schedule %s, control socket %s",
+		operation,
+		strings.Join(schedules, ", "),
+		socket,
+	)
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		stop()
+		<-done
+		return fmt.Errorf("serving control API: %w", err)
+	}
+	<-done
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down control API: %w", err)
+	}
+	return nil
+}
+
+// reloadProfiles reloads the configuration of every profile of d, e.g., on
+// SIGHUP. The current configuration of a profile is kept if its new one is
+// invalid.
+func reloadProfiles(d *geoipupdate.Daemon) {
+	log.Printf("Reloading configuration")
+	for _, status := range d.Statuses() {
+		if err := d.Reload(status.Profile); err != nil {
+			if status.Profile != "" {
+				err = fmt.Errorf("profile '%s': %w", status.Profile, err)
+			}
+			log.Printf("Error %s", err)
+		}
+	}
+}
+
+// removePIDFile removes the PID file at path written by the daemon, logging
+// failures as the daemon is exiting anyway.
+func removePIDFile(path string) {
+	if err := geoipupdate.RemovePIDFile(path); err != nil {
+		log.Print(err)
+	}
+}
+
+// daemonProfiles returns the profiles given by the flags: those of
+// --profile if any, or a single unnamed profile using -f and -d otherwise.
+func daemonProfiles(opts *daemonOptions) ([]geoipupdate.Profile, error) {
+	load := func(configFile, databaseDirectory string) func() (*geoipupdate.Config, error) {
+		return func() (*geoipupdate.Config, error) {
+			flagOptions := append([]geoipupdate.Option{
+				geoipupdate.WithConfigFile(configFile),
+				geoipupdate.WithDatabaseDirectory(databaseDirectory),
+			}, opts.flagOptions...)
+			if opts.checkOnly {
+				flagOptions = append(flagOptions, geoipupdate.WithCheckOnly)
+			}
+			config, err := geoipupdate.NewConfig(flagOptions...)
+			if err != nil {
+				return nil, fmt.Errorf("loading configuration: %w", err)
+			}
+			return config, nil
+		}
+	}
+
+	if len(opts.profiles) == 0 {
+		if len(opts.profileIntervals) > 0 {
+			return nil, newUsageError("--profile-interval requires --profile")
+		}
+		return []geoipupdate.Profile{{
+			Load:     load(opts.configFile, opts.databaseDirectory),
+			Interval: opts.interval,
+		}}, nil
+	}
+	if opts.databaseDirectory != "" {
+		return nil, newUsageError("--database-directory can't be used with --profile")
+	}
+	if len(opts.profiles) > 1 && opts.socket == "" {
+		return nil, newUsageError("--socket is required with several profiles")
+	}
+
+	var profiles []geoipupdate.Profile
+	for _, value := range opts.profiles {
+		name, configFile, ok := strings.Cut(value, "=")
+		if !ok || name == "" || configFile == "" {
+			return nil, newUsageError("invalid profile %q, expected NAME=CONFIG_FILE", value)
+		}
+		profiles = append(profiles, geoipupdate.Profile{
+			Name:     name,
+			Load:     load(configFile, ""),
+			Interval: opts.interval,
+		})
+	}
+
+	for _, value := range opts.profileIntervals {
+		name, duration, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, newUsageError("invalid profile interval %q, expected NAME=DURATION", value)
+		}
+		interval, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, newUsageError("invalid interval of profile %q: %s", name, err)
+		}
+		i := slices.IndexFunc(profiles, func(p geoipupdate.Profile) bool { return p.Name == name })
+		if i < 0 {
+			return nil, newUsageError("--profile-interval given for unknown profile %q", name)
+		}
+		profiles[i].Interval = interval
+	}
+	return profiles, nil
+}
+
+// serveGRPC serves the gRPC admin API of d in the background.
+func serveGRPC(d *geoipupdate.Daemon, opts *daemonOptions) (*grpc.Server, error) {
+	tlsConfig, err := admin.TLSConfig(opts.grpcCert, opts.grpcKey, opts.grpcClientCA)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", opts.grpcListen)
+	if err != nil {
+		return nil, fmt.Errorf("listening for the gRPC admin API: %w", err)
+	}
+
+	grpcServer := admin.NewServer(d, tlsConfig)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Printf("serving gRPC admin API: %s", err)
+		}
+	}()
+	log.Printf("Serving the gRPC admin API on %s", opts.grpcListen)
+	return grpcServer, nil
+}
+
+// ctlOptions are the flags of the ctl subcommands.
+type ctlOptions struct {
+	configFile string
+	profile    string
+	socket     string
+}
+
+// runCtl sends a control request to the daemon and writes the response to
+// stdout.
+func runCtl(opts *ctlOptions, method, path string) error {
+	socket := opts.socket
+	if socket == "" {
+		config, err := geoipupdate.NewConfig(geoipupdate.WithConfigFile(opts.configFile))
+		if err != nil {
+			return fmt.Errorf("loading configuration: %w", err)
+		}
+		socket = geoipupdate.ControlSocket(config)
+	}
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: 30 * time.Second,
+	}
+
+	if opts.profile != "" {
+		path += "?" + url.Values{"profile": {opts.profile}}.Encode()
+	}
+	// The host is ignored as requests go to the socket.
+	req, err := http.NewRequestWithContext(context.Background(), method, "http://daemon"+path, nil)
+	if err != nil {
+		return fmt.Errorf("creating control request: %w", err)
+	}
+	response, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("connecting to the daemon on %s: %w", socket, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("reading control response: %w", err)
+	}
+	if response.StatusCode >= 300 {
+		var controlErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &controlErr); err != nil || controlErr.Error == "" {
+			return fmt.Errorf("unexpected HTTP status code %d from the daemon", response.StatusCode)
+		}
+		return errors.New(controlErr.Error)
+	}
+
+	if _, err := os.Stdout.Write(body); err != nil {
+		return fmt.Errorf("writing control response: %w", err)
+	}
+	return nil
+}
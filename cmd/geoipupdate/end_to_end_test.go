@@ -158,7 +158,7 @@ func TestUpdater(t *testing.T) {
 	out, err := io.ReadAll(r)
 	require.NoError(t, err)
 	//nolint:lll
-	expectedOutput := `\[{"edition_id":"edition\-1","old_hash":"618dd27a10de24809ec160d6807f363f","new_hash":"618dd27a10de24809ec160d6807f363f","checked_at":\d+},{"edition_id":"edition\-2","old_hash":"2242f06b3b2d147987b67017cb7a5ab8","new_hash":"c9bbf7cb507370339633b44001bae038","modified_at":1708646400,"checked_at":\d+}]`
+	expectedOutput := `\[{"edition_id":"edition\-1","old_hash":"618dd27a10de24809ec160d6807f363f","new_hash":"618dd27a10de24809ec160d6807f363f","checked_at":\d+},{"edition_id":"edition\-2","old_hash":"2242f06b3b2d147987b67017cb7a5ab8","new_hash":"c9bbf7cb507370339633b44001bae038","update_id":"106eab58\-820e\-5685\-8274\-09364b8618e4","modified_at":1708646400,"checked_at":\d+,"propagation_lag_seconds":\d+}]`
 	require.Regexp(t, expectedOutput, string(out))
 
 	for _, editionID := range config.EditionIDs {
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// fetchOptions are the flags of the fetch command.
+type fetchOptions struct {
+	configFile string
+	output     string
+	verbose    bool
+}
+
+func newFetchCommand() *command {
+	var opts fetchOptions
+
+	return &command{
+		name:  "fetch",
+		args:  "*EDITION_ID*",
+		short: "Download an edition to a file or stdout",
+		long: "Download the latest build of the given edition and write its " +
+			"database to the file given by `--output`, `EDITION_ID.mmdb` in the " +
+			"current directory by default, or to stdout if it is `-`, e.g., to " +
+			"pipe it into `kubectl cp`, `ssh`, or the CLI of an object " +
+			"storage. The database is written as it is downloaded, without a " +
+			"temporary file, except for its last 128 KiB, which hold its " +
+			"metadata and are only written once the database is checked " +
+			"against its MD5 sum: a database failing the check is cut short " +
+			"and can't be opened, and the command fails. The file is replaced " +
+			"atomically, and stdout can't be a terminal. The installed " +
+			"databases, the lock, and the state file are left untouched, and " +
+			"no configuration file is needed: the account ID and license key " +
+			"may be given by the `GEOIPUPDATE_ACCOUNT_ID` and " +
+			"`GEOIPUPDATE_LICENSE_KEY` environment variables, e.g., in scripts " +
+			"and CI pipelines. Logs, including those of `--verbose`, are " +
+			"written to stderr.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVarP(
+				&opts.configFile,
+				"config-file",
+				"f",
+				configFileDefault(),
+				"Configuration file",
+			)
+			annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+			annotate(
+				fs,
+				"config-file",
+				docAnnotation,
+				"The configuration file to use, if any. It defaults to the "+
+					"environment variable `GEOIPUPDATE_CONF_FILE` if it is set, or "+
+					"CONFFILE if it exists.",
+			)
+			fs.StringVarP(&opts.output, "output", "o", "", "Write the database to this file, or - for stdout")
+			annotate(fs, "output", metavarAnnotation, "FILE")
+			fs.BoolVarP(&opts.verbose, "verbose", "v", false, "Use verbose output")
+		},
+		run: func(_ *command, args []string) error {
+			if len(args) != 1 {
+				return newUsageError("an edition ID is required")
+			}
+			return runFetch(&opts, args[0])
+		},
+		complete: completeEditionIDs,
+	}
+}
+
+// runFetch downloads editionID to the output of opts.
+func runFetch(opts *fetchOptions, editionID string) error {
+	if strings.ContainsAny(editionID, "*?[") {
+		return newUsageError("fetch downloads a single edition, not the pattern %q", editionID)
+	}
+	if opts.output == "-" && isTerminal(os.Stdout) {
+		return newUsageError("not writing a database to a terminal, redirect stdout or use --output FILE")
+	}
+
+	options := []geoipupdate.Option{
+		geoipupdate.WithConfigFile(opts.configFile),
+		geoipupdate.WithEditionIDs([]string{editionID}),
+		geoipupdate.WithoutResume,
+	}
+	if opts.verbose {
+		options = append(options, geoipupdate.WithVerbose)
+	}
+	config, err := geoipupdate.NewConfig(options...)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if len(config.EditionIDs) != 1 {
+		return newUsageError("fetch downloads a single edition, but %q is an edition group", editionID)
+	}
+	// A legacy edition ID may have been replaced by its current one.
+	editionID = config.EditionIDs[0]
+
+	u, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return fmt.Errorf("initializing updater: %w", err)
+	}
+
+	ctx := context.Background()
+	switch opts.output {
+	case "-":
+		return u.Fetch(ctx, editionID, os.Stdout)
+	case "":
+		return fetchToFile(ctx, u, editionID, editionID+".mmdb")
+	default:
+		return fetchToFile(ctx, u, editionID, opts.output)
+	}
+}
+
+// isTerminal returns whether f is a terminal, i.e., a character device other
+// than the null device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
+
+// fetchToFile downloads editionID to path, which is only replaced once the
+// database is fully written.
+func fetchToFile(ctx context.Context, u *geoipupdate.Updater, editionID, path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			//nolint:errcheck // we are already returning an error, and the file may be closed.
+			f.Close()
+			err = errors.Join(err, os.Remove(f.Name()))
+		}
+	}()
+
+	if err := u.Fetch(ctx, editionID, f); err != nil {
+		return err
+	}
+	// The temporary file is only readable by its owner, unlike the
+	// databases.
+	if err := f.Chmod(0o644); err != nil {
+		return fmt.Errorf("setting permissions of %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("syncing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("installing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// fleetStatusOptions are the flags of the fleet-status command.
+type fleetStatusOptions struct {
+	server string
+	json   bool
+}
+
+// runFleetStatus writes which build of each edition the hosts reporting to
+// a seed instance have.
+func runFleetStatus(opts *fleetStatusOptions) error {
+	client := &http.Client{Timeout: time.Minute}
+	response, err := client.Get(strings.TrimSuffix(opts.server, "/") + "/fleet-status")
+	if err != nil {
+		return fmt.Errorf("requesting fleet status: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status code %d from %s", response.StatusCode, opts.server)
+	}
+
+	if opts.json {
+		if _, err := io.Copy(os.Stdout, response.Body); err != nil {
+			return fmt.Errorf("writing fleet status: %w", err)
+		}
+		return nil
+	}
+
+	var status geoipupdate.FleetStatus
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		return fmt.Errorf("parsing fleet status: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EDITION\tMD5\tBUILT\tHOSTS")
+	for _, e := range status.Editions {
+		for _, b := range e.Builds {
+			built := "-"
+			if !b.BuildDate.IsZero() {
+				built = b.BuildDate.In(time.UTC).Format(time.DateOnly)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.EditionID, b.MD5, built, strings.Join(b.Hosts, ","))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing fleet status: %w", err)
+	}
+	return nil
+}
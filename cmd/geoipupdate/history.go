@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// historyOptions are the flags of the history command.
+type historyOptions struct {
+	configFile string
+	json       bool
+}
+
+// runHistory writes the past updates of editionID, or of every edition if
+// empty.
+func runHistory(opts *historyOptions, editionID string) error {
+	config, err := geoipupdate.NewConfig(geoipupdate.WithConfigFile(opts.configFile))
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	entries, err := geoipupdate.History(config, editionID)
+	if err != nil {
+		return fmt.Errorf("reading history: %w", err)
+	}
+
+	if opts.json {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling history: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EDITION\tINSTALLED\tBUILT\tOLD MD5\tNEW MD5\tSIZE\tDURATION\tHOST")
+	for _, e := range entries {
+		oldHash := e.OldHash
+		if oldHash == "" {
+			oldHash = "-"
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.EditionID,
+			e.Date.In(time.UTC).Format(time.RFC3339),
+			e.BuildDate.In(time.UTC).Format(time.DateOnly),
+			oldHash,
+			e.NewHash,
+			e.Size,
+			e.Duration.Round(time.Millisecond),
+			e.Host,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
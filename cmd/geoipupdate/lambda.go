@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/lambda"
+)
+
+// lambdaBootstrap is the name of the executable of Lambda custom runtimes.
+// geoipupdate runs the lambda command when installed under this name.
+const lambdaBootstrap = "bootstrap"
+
+// lambdaOptions are the flags of the lambda command.
+type lambdaOptions struct {
+	configFile        string
+	databaseDirectory string
+	verbose           bool
+}
+
+// lambdaResponse is the response to an invocation of the function.
+type lambdaResponse struct {
+	Editions []database.ReadResult `json:"editions"`
+	Warnings []geoipupdate.Warning `json:"warnings"`
+}
+
+// lambdaArgs returns the arguments to run geoipupdate with: those of the
+// lambda command if it runs as the bootstrap of a Lambda function, and
+// otherwise args.
+func lambdaArgs(executable string, args []string) []string {
+	if filepath.Base(executable) != lambdaBootstrap || os.Getenv(lambda.RuntimeAPIEnv) == "" {
+		return args
+	}
+	return append([]string{"lambda"}, args...)
+}
+
+// runLambda runs an update with each invocation of the Lambda function. The
+// configuration is loaded and the updater created once, when the function
+// starts, and reused by the invocations until Lambda stops the
+// environment.
+func runLambda(opts *lambdaOptions) error {
+	api := os.Getenv(lambda.RuntimeAPIEnv)
+	if api == "" {
+		return fmt.Errorf("%s is not set; the lambda command must run in AWS Lambda", lambda.RuntimeAPIEnv)
+	}
+	runtime := lambda.NewRuntime(api, nil)
+	ctx := context.Background()
+
+	u, err := newLambdaUpdater(opts)
+	if err != nil {
+		// Lambda reports the error of the invocation that started the
+		// function.
+		if postErr := runtime.InitError(ctx, err); postErr != nil {
+			return errors.Join(err, postErr)
+		}
+		return err
+	}
+
+	return runtime.Serve(ctx, func(ctx context.Context, _ json.RawMessage) (any, error) {
+		editions, err := u.RunEditions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving updates: %w", err)
+		}
+		if editions == nil {
+			editions = []database.ReadResult{}
+		}
+		return lambdaResponse{Editions: editions, Warnings: u.Warnings()}, nil
+	})
+}
+
+// newLambdaUpdater creates the updater of the Lambda function.
+func newLambdaUpdater(opts *lambdaOptions) (*geoipupdate.Updater, error) {
+	options := []geoipupdate.Option{
+		geoipupdate.WithConfigFile(opts.configFile),
+		geoipupdate.WithDatabaseDirectory(opts.databaseDirectory),
+	}
+	if opts.verbose {
+		options = append(options, geoipupdate.WithVerbose)
+	}
+	config, err := geoipupdate.NewConfig(options...)
+	if err != nil {
+		return nil, fmt.Errorf("loading configuration: %w", err)
+	}
+	if config.Verbose {
+		log.Printf("geoipupdate version %s", version)
+		log.Printf("Using database directory %s", config.DatabaseDirectory)
+	}
+
+	u, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return nil, fmt.Errorf("initializing updater: %w", err)
+	}
+	return u, nil
+}
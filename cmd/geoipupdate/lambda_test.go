@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxmind/geoipupdate/v7/internal/lambda"
+)
+
+func TestLambdaArgs(t *testing.T) {
+	assert.Equal(t, []string{"-v"}, lambdaArgs("/var/task/bootstrap", []string{"-v"}))
+	assert.Equal(t, []string{"-v"}, lambdaArgs("/usr/bin/geoipupdate", []string{"-v"}))
+
+	t.Setenv(lambda.RuntimeAPIEnv, "127.0.0.1:9001")
+	assert.Equal(t, []string{"lambda", "-v"}, lambdaArgs("/var/task/bootstrap", []string{"-v"}))
+	assert.Equal(t, []string{"-v"}, lambdaArgs("/usr/bin/geoipupdate", []string{"-v"}))
+}
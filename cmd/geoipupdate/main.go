@@ -2,10 +2,10 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"log"
+	"os"
 
-	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
 	"github.com/maxmind/geoipupdate/v7/internal/vars"
 )
 
@@ -17,6 +17,9 @@ var (
 	version                  = unknownVersion
 	defaultConfigFile        string
 	defaultDatabaseDirectory string
+	// releasePublicKey is the base64-encoded ed25519 key release checksums
+	// are signed with. self-update is unavailable in builds without it.
+	releasePublicKey string
 )
 
 func main() {
@@ -30,39 +33,21 @@ func main() {
 		vars.DefaultDatabaseDirectory = defaultDatabaseDirectory
 	}
 
-	args := getArgs()
-
-	opts := []geoipupdate.Option{
-		geoipupdate.WithConfigFile(args.ConfigFile),
-		geoipupdate.WithDatabaseDirectory(args.DatabaseDirectory),
-		geoipupdate.WithParallelism(args.Parallelism),
-	}
-
-	if args.Output {
-		opts = append(opts, geoipupdate.WithOutput)
-	}
-
-	if args.Verbose {
-		opts = append(opts, geoipupdate.WithVerbose)
-	}
-
-	config, err := geoipupdate.NewConfig(opts...)
-	if err != nil {
-		log.Fatalf("Error loading configuration: %s", err)
-	}
-
-	if config.Verbose {
-		log.Printf("geoipupdate version %s", version)
-		log.Printf("Using config file %s", args.ConfigFile)
-		log.Printf("Using database directory %s", config.DatabaseDirectory)
-	}
-
-	u, err := geoipupdate.NewUpdater(config)
-	if err != nil {
-		log.Fatalf("Error initializing updater: %s", err)
+	if vars.PreferUserDefaults() {
+		//nolint:errcheck // without a home directory, the system-wide defaults are kept.
+		_ = vars.UseUserDefaults()
 	}
 
-	if err = u.Run(context.Background()); err != nil {
-		log.Fatalf("Error retrieving updates: %s", err)
+	if err := newCommandTree().execute(lambdaArgs(os.Args[0], os.Args[1:])); err != nil {
+		var exitErr exitError
+		if errors.As(err, &exitErr) {
+			log.Printf("%s", err)
+			os.Exit(exitErr.code)
+		}
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			log.Fatalf("Error: %s", err)
+		}
+		log.Fatalf("Error %s", err)
 	}
 }
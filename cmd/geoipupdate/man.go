@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// manWidth is the column at which the man page's paragraphs are wrapped.
+const manWidth = 76
+
+// writeManPage writes the geoipupdate(1) man page, in the markdown
+// doc/geoipupdate.md is written in, derived from the command tree.
+func writeManPage(w io.Writer, root *command) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# NAME\n\n%s - GeoIP2 and GeoLite2 Update Program\n\n", root.name)
+
+	fmt.Fprintf(bw, "# SYNOPSIS\n\n")
+	for _, c := range root.runnableCommands() {
+		fmt.Fprintf(bw, "%s\n\n", wrapWords(c.synopsisParts(c.flagSet()), "", manWidth))
+	}
+
+	fmt.Fprintf(bw, "# DESCRIPTION\n\n%s\n\n", wrap(root.long, "", manWidth))
+
+	fmt.Fprintf(bw, "# OPTIONS\n\n")
+	writeManFlags(bw, root.flagSet(), true)
+
+	fmt.Fprintf(bw, "# COMMANDS\n\n")
+	for _, c := range root.runnableCommands() {
+		if c == root {
+			continue
+		}
+		fs := c.flagSet()
+		fmt.Fprintf(bw, "## %s\n\n", strings.TrimPrefix(c.path(), root.name+" "))
+		fmt.Fprintf(bw, "%s\n\n", wrapWords(c.synopsisParts(fs), "", manWidth))
+		fmt.Fprintf(bw, "%s\n\n", wrap(c.long, "", manWidth))
+		writeManFlags(bw, fs, false)
+	}
+
+	fmt.Fprint(bw, manPageTrailer)
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing man page: %w", err)
+	}
+	return nil
+}
+
+// runnableCommands returns c and its visible descendants that can be run, in
+// the order the man page documents them.
+func (c *command) runnableCommands() []*command {
+	var commands []*command
+	if c.run != nil && !c.hidden {
+		commands = append(commands, c)
+	}
+	for _, sub := range c.visibleSubcommands() {
+		commands = append(commands, sub.runnableCommands()...)
+	}
+	return commands
+}
+
+// writeManFlags writes the flags of fs as a definition list. The help flags
+// are only documented once, with the options of geoipupdate itself.
+func writeManFlags(w io.Writer, fs *flag.FlagSet, withHelp bool) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if !withHelp && f.Name == "help" {
+			return
+		}
+
+		term := "`--" + f.Name + "`"
+		if f.Shorthand != "" {
+			term = "`-" + f.Shorthand + "`, " + term
+		}
+
+		doc := f.Usage + "."
+		if v, ok := f.Annotations[docAnnotation]; ok && len(v) > 0 {
+			doc = v[0]
+		}
+		if hasDocumentedDefault(f) {
+			doc += fmt.Sprintf(" The default is `%s`.", defaultValue(f))
+		}
+
+		// The definition starts on the line of the ":" marker.
+		definition := strings.TrimPrefix(wrap(doc, "    ", manWidth), "    ")
+		fmt.Fprintf(w, "%s\n\n:   %s\n\n", term, definition)
+	})
+}
+
+// hasDocumentedDefault returns whether the default value of f is worth
+// documenting. String defaults depend on the environment and are documented
+// by hand where relevant.
+func hasDocumentedDefault(f *flag.Flag) bool {
+	switch f.Value.Type() {
+	case "bool", "string":
+		return false
+	}
+	switch f.DefValue {
+	case "0", "0s", "[]":
+		return false
+	}
+	return true
+}
+
+// defaultValue returns the default value of f as users would write it, e.g.,
+// "12h" rather than "12h0m0s".
+func defaultValue(f *flag.Flag) string {
+	v := f.DefValue
+	if f.Value.Type() != "duration" {
+		return v
+	}
+	if strings.HasSuffix(v, "m0s") {
+		v = strings.TrimSuffix(v, "0s")
+	}
+	if strings.HasSuffix(v, "h0m") {
+		v = strings.TrimSuffix(v, "0m")
+	}
+	return v
+}
+
+// manPageTrailer holds the sections of the man page not derived from the
+// command tree.
+const manPageTrailer = `# EXIT STATUS
+
+` + "`geoipupdate`" + ` returns 0 on success and 1 on error. With
+` + "`--warning-exit-code`" + `, an update that succeeds but raises warnings
+returns the given status instead of 0.
+
+# NOTES
+
+Typically you should run ` + "`geoipupdate`" + ` at least twice a week. Consult
+our
+[database release schedule](https://support.maxmind.com/hc/en-us/articles/4408216129947-Download-and-Update-Databases#h_01G3XX402XKD3J1CMWKNKMDYYZ)
+for more information.
+
+The ` + "`install-schedule`" + ` command sets this up for you. Alternatively, on most
+Unix-like systems, this can be achieved by using cron. You can find
+[an example crontab file on our Developer Portal](https://dev.maxmind.com/geoip/updating-databases#3-run-geoip-update).
+
+To use with a proxy server, update your ` + "`GeoIP.conf`" + ` file as specified in
+the ` + "`GeoIP.conf`" + ` man page. Alternatively, set the ` + "`GEOIPUPDATE_PROXY`" + ` or
+` + "`http_proxy`" + ` environment variable.
+
+To run without privileges, use ` + "`--user`" + `. The configuration file then
+defaults to ` + "`$XDG_CONFIG_HOME/geoipupdate/config`" + `, i.e.,
+` + "`~/.config/geoipupdate/config`" + `, and the database directory, which also
+holds the lock and state files, to ` + "`$XDG_DATA_HOME/geoipupdate`" + `, i.e.,
+` + "`~/.local/share/geoipupdate`" + `. On macOS, the configuration file is in
+` + "`~/Library/Application Support`" + ` instead, and on Windows, they are in
+` + "`%AppData%`" + ` and ` + "`%LocalAppData%`" + `. This is the default for users other
+than root when CONFFILE doesn't exist.
+
+Each setting is taken from the first of these that gives it: the command
+line, the ` + "`GEOIPUPDATE_*`" + ` environment variables, the configuration
+file, and the defaults. ` + "`config show`" + ` lists where each setting comes from.
+
+# BUGS
+
+Report bugs to [support@maxmind.com](mailto:support@maxmind.com).
+
+# AUTHORS
+
+Written by William Storey.
+
+This software is Copyright (c) 2018-2024 by MaxMind, Inc.
+
+This is free software, licensed under the Apache License, Version 2.0 or
+the MIT License, at your option.
+
+# MORE INFORMATION
+
+Visit [our website](https://www.maxmind.com/en/geoip2-services-and-databases)
+to learn more about the GeoIP2 databases or to sign up for a subscription.
+
+# SEE ALSO
+
+` + "`GeoIP.conf`" + `(5)
+`
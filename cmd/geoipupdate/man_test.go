@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestManPageInSync makes sure doc/geoipupdate.md is regenerated whenever
+// the commands change, with:
+//
+//	go run ./cmd/geoipupdate help --man > doc/geoipupdate.md
+func TestManPageInSync(t *testing.T) {
+	expected, err := os.ReadFile(filepath.Join("..", "..", "doc", "geoipupdate.md"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeManPage(&buf, newCommandTree()))
+
+	require.Equal(t, string(expected), buf.String())
+}
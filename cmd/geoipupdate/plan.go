@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// planOptions are the flags of the plan command.
+type planOptions struct {
+	checkDelivery     bool
+	configFile        string
+	databaseDirectory string
+	outputFile        string
+}
+
+// runPlan writes the plan of an update of editionIDs, or of the configured
+// editions if empty.
+func runPlan(opts *planOptions, editionIDs []string) error {
+	config, err := geoipupdate.NewConfig(
+		geoipupdate.WithConfigFile(opts.configFile),
+		geoipupdate.WithDatabaseDirectory(opts.databaseDirectory),
+		geoipupdate.WithEditionIDs(editionIDs),
+	)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	u, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return fmt.Errorf("initializing updater: %w", err)
+	}
+	plan, err := u.Plan(context.Background())
+	if err != nil {
+		return fmt.Errorf("planning updates: %w", err)
+	}
+	if opts.checkDelivery {
+		if err := u.CheckDelivery(context.Background()); err != nil {
+			return fmt.Errorf("checking delivery: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling plan: %w", err)
+	}
+	data = append(data, '\n')
+
+	if opts.outputFile == "" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			return fmt.Errorf("writing plan: %w", err)
+		}
+		return nil
+	}
+	//nolint:gosec // plans aren't sensitive.
+	if err := os.WriteFile(opts.outputFile, data, 0o644); err != nil {
+		return fmt.Errorf("writing plan: %w", err)
+	}
+	return nil
+}
+
+// applyOptions are the flags of the apply command.
+type applyOptions struct {
+	configFile        string
+	databaseDirectory string
+	output            bool
+	planFile          string
+	verbose           bool
+}
+
+// runApply performs the downloads of the plan given by --plan.
+func runApply(opts *applyOptions) error {
+	if opts.planFile == "" {
+		return newUsageError("--plan is required")
+	}
+	plan, err := geoipupdate.ReadPlan(opts.planFile)
+	if err != nil {
+		return err
+	}
+
+	options := []geoipupdate.Option{
+		geoipupdate.WithConfigFile(opts.configFile),
+		geoipupdate.WithDatabaseDirectory(opts.databaseDirectory),
+	}
+	if opts.output {
+		options = append(options, geoipupdate.WithOutput)
+	}
+	if opts.verbose {
+		options = append(options, geoipupdate.WithVerbose)
+	}
+	config, err := geoipupdate.NewConfig(options...)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	u, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return fmt.Errorf("initializing updater: %w", err)
+	}
+	if _, err := u.Apply(context.Background(), plan); err != nil {
+		return fmt.Errorf("applying plan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+)
+
+// profiler writes the CPU and heap profiles of a run, as given by the
+// --cpuprofile and --memprofile flags.
+type profiler struct {
+	cpu *os.File
+	mem *os.File
+}
+
+// startProfiling starts profiling the CPU to cpuPath and creates memPath,
+// where the heap profile is written once stopped, either being optional.
+// The files are created right away, so that they can be written after
+// privileges were dropped and the process sandboxed.
+func startProfiling(cpuPath, memPath string) (*profiler, error) {
+	p := &profiler{}
+	if memPath != "" {
+		f, err := os.Create(filepath.Clean(memPath))
+		if err != nil {
+			return nil, fmt.Errorf("creating heap profile: %w", err)
+		}
+		p.mem = f
+	}
+	if cpuPath != "" {
+		f, err := os.Create(filepath.Clean(cpuPath))
+		if err != nil {
+			p.stop()
+			return nil, fmt.Errorf("creating CPU profile: %w", err)
+		}
+		p.cpu = f
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			p.stop()
+			return nil, fmt.Errorf("starting CPU profile: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// stop stops profiling, writing the heap profile. Errors are logged, as they
+// mustn't fail the run being profiled.
+func (p *profiler) stop() {
+	if p.cpu != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			log.Printf("Writing CPU profile: %s", err)
+		}
+		p.cpu = nil
+	}
+	if p.mem != nil {
+		// The profile reflects the heap as of the last garbage collection.
+		runtime.GC()
+		if err := runtimepprof.WriteHeapProfile(p.mem); err != nil {
+			log.Printf("Writing heap profile: %s", err)
+		}
+		if err := p.mem.Close(); err != nil {
+			log.Printf("Writing heap profile: %s", err)
+		}
+		p.mem = nil
+	}
+}
+
+// servePprof serves the pprof endpoints, under /debug/pprof/, in the
+// background.
+func servePprof(address string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("listening for pprof: %w", err)
+	}
+
+	server := &http.Server{
+		Handler:           pprofHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("serving pprof: %s", err)
+		}
+	}()
+	log.Printf("Serving pprof on %s", listener.Addr())
+	return server, nil
+}
+
+// pprofHandler returns the handler of the pprof endpoints. They are
+// registered on their own mux rather than on http.DefaultServeMux, which
+// importing net/http/pprof populates.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
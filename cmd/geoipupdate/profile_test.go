@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartProfiling(t *testing.T) {
+	tempDir := t.TempDir()
+	cpuPath := filepath.Join(tempDir, "cpu.pprof")
+	memPath := filepath.Join(tempDir, "mem.pprof")
+
+	p, err := startProfiling(cpuPath, memPath)
+	require.NoError(t, err)
+	p.stop()
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Positive(t, info.Size(), path)
+	}
+
+	_, err = startProfiling(filepath.Join(tempDir, "missing", "cpu.pprof"), "")
+	require.ErrorContains(t, err, "creating CPU profile")
+}
+
+func TestPprofHandler(t *testing.T) {
+	server := httptest.NewServer(pprofHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/pprof/heap?debug=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "heap profile")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/schedule"
+)
+
+// installScheduleOptions are the flags of the install-schedule command.
+type installScheduleOptions struct {
+	configFile        string
+	databaseDirectory string
+	interval          time.Duration
+	splay             time.Duration
+}
+
+// runInstallSchedule schedules geoipupdate to run periodically.
+func runInstallSchedule(opts *installScheduleOptions) error {
+	scheduler, err := schedule.New()
+	if err != nil {
+		return fmt.Errorf("initializing scheduler: %w", err)
+	}
+
+	binary, err := executable()
+	if err != nil {
+		return fmt.Errorf("locating geoipupdate: %w", err)
+	}
+
+	// Scheduled runs don't share our working directory.
+	var args []string
+	if opts.configFile != "" {
+		path, err := filepath.Abs(opts.configFile)
+		if err != nil {
+			return fmt.Errorf("resolving config file path: %w", err)
+		}
+		args = append(args, "--config-file", path)
+	}
+	if opts.databaseDirectory != "" {
+		path, err := filepath.Abs(opts.databaseDirectory)
+		if err != nil {
+			return fmt.Errorf("resolving database directory path: %w", err)
+		}
+		args = append(args, "--database-directory", path)
+	}
+
+	err = scheduler.Install(schedule.Options{
+		Binary:   binary,
+		Args:     args,
+		Interval: opts.interval,
+		Splay:    opts.splay,
+	})
+	if err != nil {
+		return fmt.Errorf("installing schedule: %w", err)
+	}
+	log.Printf("Scheduled geoipupdate to run every %s", opts.interval)
+	return nil
+}
+
+// runUninstallSchedule removes the schedule created by install-schedule.
+func runUninstallSchedule() error {
+	scheduler, err := schedule.New()
+	if err != nil {
+		return fmt.Errorf("initializing scheduler: %w", err)
+	}
+
+	if err := scheduler.Uninstall(); err != nil {
+		return fmt.Errorf("removing schedule: %w", err)
+	}
+	log.Print("Schedule removed")
+	return nil
+}
+
+// executable returns the absolute path of the running binary.
+func executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("getting executable path: %w", err)
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving executable path: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// seedOptions are the flags of the seed command.
+type seedOptions struct {
+	configFile string
+	listen     string
+}
+
+// runSeed serves the installed databases to peers until it fails.
+func runSeed(opts *seedOptions) error {
+	config, err := geoipupdate.NewConfig(geoipupdate.WithConfigFile(opts.configFile))
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              opts.listen,
+		Handler:           geoipupdate.NewSeedHandler(config),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Serving databases from %s to peers on %s", config.DatabaseDirectory, opts.listen)
+	if err := server.ListenAndServe(); err != nil {
+		return fmt.Errorf("serving databases: %w", err)
+	}
+	return nil
+}
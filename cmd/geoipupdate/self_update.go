@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+	"github.com/maxmind/geoipupdate/v7/internal/selfupdate"
+)
+
+// selfUpdateOptions are the flags of the self-update command.
+type selfUpdateOptions struct {
+	check      bool
+	configFile string
+	force      bool
+}
+
+// runSelfUpdate replaces the running binary with the latest release.
+func runSelfUpdate(opts *selfUpdateOptions) error {
+	config, err := geoipupdate.NewConfig(geoipupdate.WithConfigFile(opts.configFile))
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if config.DisableSelfUpdate && !opts.check {
+		return errors.New("self-update is disabled by the DisableSelfUpdate setting")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != nil {
+		transport.Proxy = http.ProxyURL(config.Proxy)
+	}
+
+	updater, err := selfupdate.New(
+		releasePublicKey,
+		selfupdate.WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	if err != nil {
+		if errors.Is(err, selfupdate.ErrNoPublicKey) {
+			return fmt.Errorf("%w; update geoipupdate the way it was installed", err)
+		}
+		return fmt.Errorf("initializing self-update: %w", err)
+	}
+
+	ctx := context.Background()
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	newer, err := selfupdate.IsNewer(version, release.Version)
+	if err != nil && !opts.force {
+		return fmt.Errorf("comparing versions: %w; use --force to install %s anyway",
+			err, release.Version)
+	}
+	if !newer && !opts.force {
+		log.Printf("geoipupdate %s is up to date", version)
+		return nil
+	}
+	if opts.check {
+		log.Printf("geoipupdate %s is available (current version: %s)", release.Version, version)
+		return nil
+	}
+
+	binary, err := executable()
+	if err != nil {
+		return fmt.Errorf("locating geoipupdate: %w", err)
+	}
+	if err := updater.Install(ctx, release, binary); err != nil {
+		return fmt.Errorf("installing geoipupdate %s: %w", release.Version, err)
+	}
+	log.Printf("Updated geoipupdate from %s to %s", version, release.Version)
+	return nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// updateOptions are the flags of the update command, i.e., of geoipupdate
+// itself.
+type updateOptions struct {
+	allowDowngrade    bool
+	ci                bool
+	configFile        string
+	daemon            bool
+	cpuProfile        string
+	databaseDirectory string
+	displayVersion    bool
+	editionIDs        []string
+	memProfile        string
+	output            bool
+	parallelism       int
+	skip              []string
+	splay             time.Duration
+	strictConfig      bool
+	traceFile         string
+	verbose           bool
+	warningExitCode   int
+}
+
+// updateFlags returns the function defining the update flags, bound to opts.
+func updateFlags(opts *updateOptions) func(*flag.FlagSet) {
+	return func(fs *flag.FlagSet) {
+		fs.StringVarP(
+			&opts.databaseDirectory,
+			"database-directory",
+			"d",
+			"",
+			"Store databases in this directory (uses config if not specified)",
+		)
+		annotate(fs, "database-directory", metavarAnnotation, "TARGET_DIRECTORY")
+		annotate(
+			fs,
+			"database-directory",
+			docAnnotation,
+			"Install databases to a custom directory. This is optional. If "+
+				"provided, it overrides the `DatabaseDirectory` value from the "+
+				"configuration file and the `GEOIPUPDATE_DB_DIR` environment variable.",
+		)
+
+		fs.StringVarP(
+			&opts.configFile,
+			"config-file",
+			"f",
+			configFileDefault(),
+			"Configuration file",
+		)
+		annotate(fs, "config-file", metavarAnnotation, "CONFIG_FILE")
+		annotate(
+			fs,
+			"config-file",
+			docAnnotation,
+			"The configuration file to use. See `GeoIP.conf` and its "+
+				"documentation for more information. This is optional. It defaults "+
+				"to the environment variable `GEOIPUPDATE_CONF_FILE` if it is set, "+
+				"or CONFFILE otherwise.",
+		)
+
+		fs.IntVar(&opts.parallelism, "parallelism", 0, "Set the number of parallel database downloads")
+		annotate(fs, "parallelism", metavarAnnotation, "N")
+
+		fs.BoolVar(
+			&opts.strictConfig,
+			"strict-config",
+			false,
+			"Reject deprecated and unknown options in the configuration file",
+		)
+		annotate(
+			fs,
+			"strict-config",
+			docAnnotation,
+			"Reject the deprecated settings listed in `GeoIP.conf`(5) rather "+
+				"than ignoring them, and name the offending setting along with its "+
+				"line number in the error for both deprecated and unknown settings.",
+		)
+
+		fs.StringArrayVar(&opts.editionIDs, "edition-id", nil, "Update this edition rather than the configured ones")
+		annotate(fs, "edition-id", metavarAnnotation, "EDITION_ID")
+		annotate(
+			fs,
+			"edition-id",
+			docAnnotation,
+			"Update the given edition, edition group, or pattern, e.g., "+
+				"`GeoIP2-*`, this time rather than those of the `EditionIDs` "+
+				"setting and the `GEOIPUPDATE_EDITION_IDS` environment variable. "+
+				"It can be given multiple times, and adds to the edition IDs given "+
+				"as arguments. Along with `--database-directory`, it pulls an "+
+				"edition into a scratch directory without changing the "+
+				"configuration file.",
+		)
+
+		fs.StringArrayVar(&opts.skip, "skip", nil, "Don't update this edition")
+		annotate(fs, "skip", metavarAnnotation, "EDITION_ID")
+		annotate(
+			fs,
+			"skip",
+			docAnnotation,
+			"Don't update the given edition, or the editions matching the given "+
+				"pattern, e.g., `GeoIP2-*`, this time, even though they are "+
+				"configured. It can be given multiple times, and adds to the "+
+				"`ExcludeEditionIDs` setting.",
+		)
+
+		fs.DurationVar(
+			&opts.splay,
+			"splay",
+			0,
+			"Wait a random delay of up to this duration before updating",
+		)
+		annotate(
+			fs,
+			"splay",
+			docAnnotation,
+			"Wait a random delay of up to the given duration, e.g., `30m`, "+
+				"before updating. This spreads the load when many hosts are "+
+				"scheduled to update at the same time.",
+		)
+
+		fs.BoolVar(
+			&opts.allowDowngrade,
+			"allow-downgrade",
+			false,
+			"Allow replacing databases with older builds",
+		)
+		annotate(
+			fs,
+			"allow-downgrade",
+			docAnnotation,
+			"Replace databases even with databases built before them. By default, "+
+				"an edition whose download is older than the installed database, "+
+				"e.g., because a mirror or a CDN cache served a stale file, fails to "+
+				"update and the installed database is kept.",
+		)
+
+		fs.BoolVar(&opts.ci, "ci", false, "Use output suited to CI pipelines")
+		annotate(
+			fs,
+			"ci",
+			docAnnotation,
+			"Run in a CI pipeline. The output is grouped, configuration warnings "+
+				"and failures are reported as annotations, and on GitHub Actions the "+
+				"`updated` step output is set to `true` or `false`, along with "+
+				"`updated-editions`, the space-separated IDs of the updated editions, "+
+				"so later steps can run only when databases changed. Unless "+
+				"`CacheMaxAge` is set, editions checked less than an hour ago are not "+
+				"checked again, so keep the database directory, which holds the "+
+				"state file, in the cache of the pipeline.",
+		)
+
+		fs.BoolVar(&opts.daemon, "daemon", false, "Keep running, updating on the configured schedule")
+		annotate(
+			fs,
+			"daemon",
+			docAnnotation,
+			"Keep running, and update the databases immediately and then on the "+
+				"`Schedule` described in `GeoIP.conf`(5), every 12 hours by default, "+
+				"with a random delay of up to `ScheduleJitter`, as the `daemon` "+
+				"command does. This is the default when `RunMode` is `daemon`. The "+
+				"configuration is reloaded on SIGHUP, and the daemon exits on "+
+				"SIGTERM, leaving the databases of an interrupted run as they were. "+
+				"It can't be used with `--ci`, `--splay`, `--output`, "+
+				"`--cpuprofile`, or `--memprofile`.",
+		)
+
+		fs.IntVar(
+			&opts.warningExitCode,
+			"warning-exit-code",
+			0,
+			"Exit with this status when the update succeeds with warnings",
+		)
+		annotate(fs, "warning-exit-code", metavarAnnotation, "STATUS")
+		annotate(
+			fs,
+			"warning-exit-code",
+			docAnnotation,
+			"Exit with the given status, e.g., `2`, rather than 0 when the "+
+				"update succeeds but raises warnings, such as a wrong local clock, "+
+				"a deprecated setting, or an edition whose installed build is more "+
+				"than two weeks old while no newer build is available. Errors "+
+				"still exit with status 1. The warnings are also listed in the "+
+				"`report` output.",
+		)
+
+		fs.StringVar(&opts.cpuProfile, "cpuprofile", "", "Write a CPU profile of the run to this file")
+		annotate(fs, "cpuprofile", metavarAnnotation, "FILE")
+		annotate(
+			fs,
+			"cpuprofile",
+			docAnnotation,
+			"Write a CPU profile of the run to the given file, in the format of "+
+				"`go tool pprof`, e.g., to diagnose a slow update.",
+		)
+
+		fs.StringVar(&opts.memProfile, "memprofile", "", "Write a heap profile of the run to this file")
+		annotate(fs, "memprofile", metavarAnnotation, "FILE")
+		annotate(
+			fs,
+			"memprofile",
+			docAnnotation,
+			"Write a heap profile to the given file at the end of the run, in "+
+				"the format of `go tool pprof`, e.g., to diagnose the memory use "+
+				"of a high `--parallelism`. The allocations of the whole run are "+
+				"included.",
+		)
+
+		fs.StringVar(&opts.traceFile, "trace-file", "", "Append the stages of the requests to this file")
+		annotate(fs, "trace-file", metavarAnnotation, "FILE")
+		annotate(
+			fs,
+			"trace-file",
+			docAnnotation,
+			"Append a JSON object per line to the given file for each stage of "+
+				"the requests to the MaxMind servers, e.g., to find out whether "+
+				"a slow download is spent resolving, connecting or waiting for "+
+				"the server. If provided, it overrides the `TraceFile` setting "+
+				"described in `GeoIP.conf`(5).",
+		)
+
+		fs.BoolVarP(&opts.displayVersion, "version", "V", false, "Display the version and exit")
+
+		fs.BoolVarP(&opts.verbose, "verbose", "v", false, "Use verbose output")
+		annotate(
+			fs,
+			"verbose",
+			docAnnotation,
+			"Enable verbose mode. Prints out the steps that `geoipupdate` takes. "+
+				"If provided, it overrides any `GEOIPUPDATE_VERBOSE` environment "+
+				"variable.",
+		)
+
+		fs.BoolVarP(&opts.output, "output", "o", false, "Output download/update results in JSON format")
+		annotate(
+			fs,
+			"output",
+			docAnnotation,
+			"Output download/update results in JSON format. The format is set "+
+				"by the `OutputFormat` setting described in `GeoIP.conf`(5).",
+		)
+	}
+}
+
+// runUpdate updates the databases.
+func runUpdate(opts *updateOptions, editionIDs []string) error {
+	if opts.displayVersion {
+		log.Printf("geoipupdate %s", version)
+		return nil
+	}
+
+	if opts.parallelism < 0 {
+		return newUsageError("parallelism must be a positive number")
+	}
+	if opts.splay < 0 {
+		return newUsageError("splay must not be negative")
+	}
+	if opts.warningExitCode < 0 || opts.warningExitCode > 125 {
+		return newUsageError("warning-exit-code must be between 0 and 125")
+	}
+
+	flagOptions := []geoipupdate.Option{
+		geoipupdate.WithConfigFile(opts.configFile),
+		geoipupdate.WithDatabaseDirectory(opts.databaseDirectory),
+		geoipupdate.WithEditionIDs(append(opts.editionIDs, editionIDs...)),
+		geoipupdate.WithParallelism(opts.parallelism),
+		geoipupdate.WithSkippedEditionIDs(opts.skip),
+		geoipupdate.WithTraceFile(opts.traceFile),
+	}
+
+	if opts.output {
+		flagOptions = append(flagOptions, geoipupdate.WithOutput)
+	}
+
+	if opts.verbose {
+		flagOptions = append(flagOptions, geoipupdate.WithVerbose)
+	}
+
+	if opts.strictConfig {
+		flagOptions = append(flagOptions, geoipupdate.WithStrictConfig)
+	}
+
+	if opts.allowDowngrade {
+		flagOptions = append(flagOptions, geoipupdate.WithAllowDowngrade)
+	}
+
+	config, err := geoipupdate.NewConfig(flagOptions...)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	if opts.daemon || config.RunMode == geoipupdate.RunModeDaemon {
+		return runUpdateDaemon(opts, flagOptions)
+	}
+
+	if config.Verbose {
+		log.Printf("geoipupdate version %s", version)
+		log.Printf("Using config file %s", opts.configFile)
+		log.Printf("Using database directory %s", config.DatabaseDirectory)
+		for _, w := range geoipupdate.LintConfig(config, opts.configFile) {
+			log.Printf("Warning [%s]: %s", w.Code, w.Message)
+		}
+	}
+
+	// The profiles are created before privileges are dropped, as the
+	// sandbox may not allow writing them.
+	prof, err := startProfiling(opts.cpuProfile, opts.memProfile)
+	if err != nil {
+		return err
+	}
+	defer prof.stop()
+
+	if err := geoipupdate.DropPrivileges([]*geoipupdate.Config{config}); err != nil {
+		return fmt.Errorf("dropping privileges: %w", err)
+	}
+	if err := geoipupdate.Sandbox([]*geoipupdate.Config{config}); err != nil {
+		return fmt.Errorf("sandboxing: %w", err)
+	}
+
+	if opts.ci && config.CacheMaxAge == 0 {
+		config.CacheMaxAge = ciCacheMaxAge
+	}
+
+	if opts.splay > 0 {
+		//nolint:gosec // the delay doesn't need to be cryptographically random.
+		delay := time.Duration(rand.Int63n(int64(opts.splay)))
+		if config.Verbose {
+			log.Printf("Waiting %s before updating", delay)
+		}
+		time.Sleep(delay)
+	}
+
+	u, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return fmt.Errorf("initializing updater: %w", err)
+	}
+
+	if opts.ci {
+		err = runCIUpdate(u, geoipupdate.LintConfig(config, opts.configFile))
+	} else if err = u.Run(context.Background()); err != nil {
+		err = fmt.Errorf("retrieving updates: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	return warningExit(u.Warnings(), opts.warningExitCode)
+}
+
+// runUpdateDaemon runs the daemon with the configuration given by the update
+// flags, as with --daemon.
+func runUpdateDaemon(opts *updateOptions, flagOptions []geoipupdate.Option) error {
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"--ci", opts.ci},
+		{"--splay", opts.splay > 0},
+		{"--output", opts.output},
+		{"--cpuprofile", opts.cpuProfile != ""},
+		{"--memprofile", opts.memProfile != ""},
+	} {
+		if f.set {
+			return newUsageError("%s can't be used in daemon mode", f.name)
+		}
+	}
+	return runDaemon(&daemonOptions{
+		configFile:        opts.configFile,
+		databaseDirectory: opts.databaseDirectory,
+		flagOptions:       flagOptions,
+	})
+}
+
+// warningExit returns the exitError of a successful update that raised
+// warnings, if code is set.
+func warningExit(warnings []geoipupdate.Warning, code int) error {
+	if code == 0 || len(warnings) == 0 {
+		return nil
+	}
+	return exitError{
+		err:  fmt.Errorf("the update succeeded with %d warnings", len(warnings)),
+		code: code,
+	}
+}
+
+// configFileDefault returns the config file used when none is given on the
+// command line.
+func configFileDefault() string {
+	confFileDefault := vars.DefaultConfigFile
+	// Set the default config file only if it exists.
+	// Otherwise, geoipupdate requires the user to specify the config file
+	// even if all the other arguments are set via the environment variables.
+	if _, err := os.Stat(confFileDefault); errors.Is(err, os.ErrNotExist) {
+		confFileDefault = ""
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_CONF_FILE"); ok {
+		confFileDefault = value
+	}
+
+	return confFileDefault
+}
@@ -10,6 +10,11 @@ if [[ -z ${GITHUB_TOKEN:-} ]]; then
     exit 1
 fi
 
+if [[ -z ${RELEASE_SIGNING_KEY:-} || -z ${RELEASE_PUBLIC_KEY:-} ]]; then
+    echo 'RELEASE_SIGNING_KEY and RELEASE_PUBLIC_KEY must be set for self-update!'
+    exit 1
+fi
+
 regex='
 ## ([0-9]+\.[0-9]+\.[0-9]+) \(([0-9]{4}-[0-9]{2}-[0-9]{2})\)
 
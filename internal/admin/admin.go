@@ -0,0 +1,148 @@
+// Package admin serves the admin API of the daemon over gRPC, as defined in
+// adminpb/admin.proto.
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/maxmind/geoipupdate/v7/internal/admin/adminpb"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// NewServer returns a gRPC server offering the admin API of d over TLS.
+func NewServer(d *geoipupdate.Daemon, tlsConfig *tls.Config) *grpc.Server {
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	adminpb.RegisterAdminServer(s, &server{daemon: d})
+	return s
+}
+
+// TLSConfig returns the TLS configuration of the server using the
+// certificate and key at certFile and keyFile. If clientCAFile is set,
+// clients must present a certificate signed by one of the CAs it holds.
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("the client CA file holds no PEM certificate")
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+type server struct {
+	adminpb.UnimplementedAdminServer
+	daemon *geoipupdate.Daemon
+}
+
+func (s *server) RunNow(
+	_ context.Context,
+	req *adminpb.RunNowRequest,
+) (*adminpb.RunNowResponse, error) {
+	if err := s.daemon.RunNow(req.GetProfile()); err != nil {
+		return nil, statusError(err)
+	}
+	daemonStatus, err := s.daemon.Status(req.GetProfile())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &adminpb.RunNowResponse{Status: newStatus(daemonStatus)}, nil
+}
+
+func (s *server) GetStatus(
+	_ context.Context,
+	req *adminpb.GetStatusRequest,
+) (*adminpb.GetStatusResponse, error) {
+	daemonStatus, err := s.daemon.Status(req.GetProfile())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &adminpb.GetStatusResponse{Status: newStatus(daemonStatus)}, nil
+}
+
+func (s *server) ReloadConfig(
+	_ context.Context,
+	req *adminpb.ReloadConfigRequest,
+) (*adminpb.ReloadConfigResponse, error) {
+	if err := s.daemon.Reload(req.GetProfile()); err != nil {
+		return nil, statusError(err)
+	}
+	daemonStatus, err := s.daemon.Status(req.GetProfile())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &adminpb.ReloadConfigResponse{Status: newStatus(daemonStatus)}, nil
+}
+
+func (s *server) ListProfiles(
+	context.Context,
+	*adminpb.ListProfilesRequest,
+) (*adminpb.ListProfilesResponse, error) {
+	var profiles []*adminpb.Status
+	for _, daemonStatus := range s.daemon.Statuses() {
+		profiles = append(profiles, newStatus(daemonStatus))
+	}
+	return &adminpb.ListProfilesResponse{Profiles: profiles}, nil
+}
+
+// statusError returns the gRPC status of err, an error of the daemon.
+func statusError(err error) error {
+	code := codes.FailedPrecondition
+	switch {
+	case errors.Is(err, geoipupdate.ErrProfileRequired):
+		code = codes.InvalidArgument
+	case errors.Is(err, geoipupdate.ErrUnknownProfile):
+		code = codes.NotFound
+	}
+	return status.Error(code, err.Error())
+}
+
+func newStatus(s geoipupdate.DaemonStatus) *adminpb.Status {
+	return &adminpb.Status{
+		Running:       s.Running,
+		LastStarted:   timestamp(s.LastStarted),
+		LastFinished:  timestamp(s.LastFinished),
+		LastSuccess:   timestamp(s.LastSuccess),
+		LastError:     s.LastError,
+		NextRun:       timestamp(s.NextRun),
+		ConfigLoaded:  timestamp(s.ConfigLoaded),
+		EditionIds:    s.EditionIDs,
+		RunsCompleted: int64(s.RunsCompleted),
+		Profile:       s.Profile,
+	}
+}
+
+// timestamp converts t, leaving zero times unset.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
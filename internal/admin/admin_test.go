@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/maxmind/geoipupdate/v7/internal/admin/adminpb"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// testCA issues certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns the PEM certificate and key of a leaf certificate.
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+		return path
+	}
+	serverCert, serverKey := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	tlsConfig, err := TLSConfig(
+		write("server.pem", serverCert),
+		write("server-key.pem", serverKey),
+		write("ca.pem", ca.pem),
+	)
+	require.NoError(t, err)
+
+	editionIDs := []string{"GeoLite2-City"}
+	var loadErr error
+	d, err := geoipupdate.NewDaemon([]geoipupdate.Profile{
+		{
+			Name: "edge",
+			Load: func() (*geoipupdate.Config, error) {
+				if loadErr != nil {
+					return nil, loadErr
+				}
+				return &geoipupdate.Config{EditionIDs: editionIDs, LockFile: "edge.lock"}, nil
+			},
+			Interval: time.Hour,
+		},
+		{
+			Name: "ci",
+			Load: func() (*geoipupdate.Config, error) {
+				return &geoipupdate.Config{EditionIDs: []string{"GeoLite2-ASN"}, LockFile: "ci.lock"}, nil
+			},
+			Interval: time.Hour,
+		},
+	})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := NewServer(d, tlsConfig)
+	go func() {
+		assert.NoError(t, s.Serve(listener))
+	}()
+	defer s.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	dial := func(certificates []tls.Certificate) adminpb.AdminClient {
+		conn, err := grpc.NewClient(
+			listener.Addr().String(),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				Certificates: certificates,
+				MinVersion:   tls.VersionTLS12,
+				RootCAs:      roots,
+			})),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return adminpb.NewAdminClient(conn)
+	}
+
+	clientCert, clientKey := ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+	client := dial([]tls.Certificate{cert})
+	ctx := context.Background()
+
+	// The daemon has several profiles.
+	_, err = client.GetStatus(ctx, &adminpb.GetStatusRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetStatus(ctx, &adminpb.GetStatusRequest{Profile: "other"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err := client.GetStatus(ctx, &adminpb.GetStatusRequest{Profile: "edge"})
+	require.NoError(t, err)
+	assert.Equal(t, "edge", res.GetStatus().GetProfile())
+	assert.Equal(t, []string{"GeoLite2-City"}, res.GetStatus().GetEditionIds())
+	assert.False(t, res.GetStatus().GetRunning())
+	assert.Nil(t, res.GetStatus().GetLastStarted())
+	assert.NotNil(t, res.GetStatus().GetConfigLoaded())
+
+	_, err = client.RunNow(ctx, &adminpb.RunNowRequest{Profile: "edge"})
+	require.NoError(t, err)
+
+	loadErr = errors.New("invalid")
+	_, err = client.ReloadConfig(ctx, &adminpb.ReloadConfigRequest{Profile: "edge"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	loadErr = nil
+	editionIDs = []string{"GeoIP2-City"}
+	reloaded, err := client.ReloadConfig(ctx, &adminpb.ReloadConfigRequest{Profile: "edge"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoIP2-City"}, reloaded.GetStatus().GetEditionIds())
+
+	profiles, err := client.ListProfiles(ctx, &adminpb.ListProfilesRequest{})
+	require.NoError(t, err)
+	require.Len(t, profiles.GetProfiles(), 2)
+	assert.Equal(t, "ci", profiles.GetProfiles()[1].GetProfile())
+
+	// Clients without a certificate signed by the client CA are rejected.
+	_, err = dial(nil).ListProfiles(ctx, &adminpb.ListProfilesRequest{})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,5 @@
+// Package adminpb contains the code generated from admin.proto, the
+// definition of the gRPC admin API of the daemon.
+package adminpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative admin.proto
@@ -0,0 +1,380 @@
+// Package awscreds finds AWS credentials as the AWS SDKs do: in the
+// environment, from a web identity token, from the credentials endpoint of
+// ECS containers, or from the instance metadata service of EC2 instances.
+package awscreds
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+const (
+	// ecsEndpoint is the host of the credentials endpoint of ECS
+	// containers, which AWS_CONTAINER_CREDENTIALS_RELATIVE_URI is relative
+	// to.
+	ecsEndpoint = "http://169.254.170.2"
+	// imdsEndpoint is the instance metadata service of EC2 instances.
+	imdsEndpoint = "http://169.254.169.254"
+	// imdsTokenTTL is how long the session tokens of IMDSv2 are valid.
+	imdsTokenTTL = 6 * time.Hour
+	// metadataTimeout bounds the requests to the ECS and EC2 endpoints,
+	// which are local, so that geoipupdate doesn't hang outside of AWS.
+	metadataTimeout = 5 * time.Second
+	// expiryWindow is how long before they expire credentials are renewed.
+	expiryWindow = 5 * time.Minute
+)
+
+// Chain provides the credentials of the first source configured in the
+// environment, in order:
+//
+//   - the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+//     environment variables.
+//   - the web identity token of AWS_WEB_IDENTITY_TOKEN_FILE, exchanged with
+//     AWS STS for the credentials of the role AWS_ROLE_ARN, as on EKS.
+//   - the endpoint of AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or
+//     AWS_CONTAINER_CREDENTIALS_FULL_URI, as on ECS.
+//   - the instance metadata service, with IMDSv2, as on EC2, unless
+//     AWS_EC2_METADATA_DISABLED is true.
+//
+// Temporary credentials are cached, and renewed before they expire.
+type Chain struct {
+	source func(ctx context.Context) (sigv4.Credentials, time.Time, error)
+	now    func() time.Time
+
+	mu      sync.Mutex
+	current sigv4.Credentials
+	// expiry is the expiration of current, which is zero if it doesn't
+	// expire.
+	expiry time.Time
+	cached bool
+}
+
+// New returns the Chain of the environment. httpClient sends the requests
+// to AWS STS. Those to the ECS and EC2 endpoints, which are local, never go
+// through a proxy.
+func New(httpClient *http.Client) (*Chain, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Chain{now: time.Now}
+
+	accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKeyID != "" || secretAccessKey != "" {
+		if accessKeyID == "" || secretAccessKey == "" {
+			return nil, errors.New(
+				"the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment " +
+					"variables must be set together",
+			)
+		}
+		creds := sigv4.Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		c.source = func(context.Context) (sigv4.Credentials, time.Time, error) {
+			return creds, time.Time{}, nil
+		}
+		return c, nil
+	}
+
+	if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" {
+		roleARN := os.Getenv("AWS_ROLE_ARN")
+		if roleARN == "" {
+			return nil, errors.New(
+				"the AWS_ROLE_ARN environment variable is required with AWS_WEB_IDENTITY_TOKEN_FILE",
+			)
+		}
+		w := &webIdentity{
+			httpClient:  httpClient,
+			endpoint:    stsEndpoint(),
+			tokenFile:   tokenFile,
+			roleARN:     roleARN,
+			sessionName: os.Getenv("AWS_ROLE_SESSION_NAME"),
+		}
+		if w.sessionName == "" {
+			w.sessionName = "geoipupdate-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		}
+		c.source = w.credentials
+		return c, nil
+	}
+
+	metadataClient := &http.Client{
+		Transport: &http.Transport{Proxy: nil},
+		Timeout:   metadataTimeout,
+	}
+
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		e := &ecsCredentials{httpClient: metadataClient, url: ecsEndpoint + uri}
+		c.source = e.credentials
+		return c, nil
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		e := &ecsCredentials{httpClient: metadataClient, url: uri}
+		c.source = e.credentials
+		return c, nil
+	}
+
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, errors.New(
+			"no AWS credentials found: set the AWS_ACCESS_KEY_ID and " +
+				"AWS_SECRET_ACCESS_KEY environment variables, or run with an IAM role",
+		)
+	}
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = imdsEndpoint
+	}
+	i := &imds{httpClient: metadataClient, endpoint: strings.TrimSuffix(endpoint, "/")}
+	c.source = i.credentials
+	return c, nil
+}
+
+// Credentials returns credentials valid for at least another few minutes.
+func (c *Chain) Credentials(ctx context.Context) (sigv4.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached && (c.expiry.IsZero() || c.now().Add(expiryWindow).Before(c.expiry)) {
+		return c.current, nil
+	}
+
+	creds, expiry, err := c.source(ctx)
+	if err != nil {
+		return sigv4.Credentials{}, err
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return sigv4.Credentials{}, errors.New("no AWS credentials returned")
+	}
+	c.current = creds
+	c.expiry = expiry
+	c.cached = true
+	return creds, nil
+}
+
+// stsEndpoint returns the AWS STS endpoint: AWS_ENDPOINT_URL_STS if set,
+// else the regional endpoint of AWS_REGION or AWS_DEFAULT_REGION, else the
+// global one.
+func stsEndpoint() string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_STS"); endpoint != "" {
+		return endpoint
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return "https://sts.amazonaws.com/"
+	}
+	return "https://sts." + region + ".amazonaws.com/"
+}
+
+// webIdentity exchanges a web identity token for the credentials of a role
+// with AssumeRoleWithWebIdentity, which doesn't need credentials itself.
+type webIdentity struct {
+	httpClient  *http.Client
+	endpoint    string
+	tokenFile   string
+	roleARN     string
+	sessionName string
+}
+
+func (w *webIdentity) credentials(ctx context.Context) (sigv4.Credentials, time.Time, error) {
+	// The token is read again every time, as it is rotated.
+	//nolint:gosec // the path is given by the environment.
+	token, err := os.ReadFile(w.tokenFile)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("reading AWS_WEB_IDENTITY_TOKEN_FILE: %w", err)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {w.roleARN},
+		"RoleSessionName":  {w.sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("creating AWS STS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "geoipupdate/"+vars.Version)
+
+	const action = "assuming role with web identity"
+	response, err := w.httpClient.Do(req)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("%s: %w", action, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return sigv4.Credentials{}, time.Time{}, statusError(action, response)
+	}
+
+	var body struct {
+		Result struct {
+			Credentials struct {
+				AccessKeyID     string `xml:"AccessKeyId"`
+				SecretAccessKey string
+				SessionToken    string
+				Expiration      time.Time
+			}
+		} `xml:"AssumeRoleWithWebIdentityResult"`
+	}
+	if err := xml.NewDecoder(response.Body).Decode(&body); err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("parsing AWS STS response: %w", err)
+	}
+	creds := body.Result.Credentials
+	return sigv4.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}, creds.Expiration, nil
+}
+
+// ecsCredentials gets the credentials of the task role of an ECS container.
+type ecsCredentials struct {
+	httpClient *http.Client
+	url        string
+}
+
+func (e *ecsCredentials) credentials(ctx context.Context) (sigv4.Credentials, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url, nil)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("creating ECS credentials request: %w", err)
+	}
+	// The token, if any, is read again every time, as it is rotated.
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if path := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); path != "" {
+		//nolint:gosec // the path is given by the environment.
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return sigv4.Credentials{}, time.Time{},
+				fmt.Errorf("reading AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	return getJSONCredentials(e.httpClient, req, "getting ECS container credentials")
+}
+
+// imds gets the credentials of the instance profile of an EC2 instance
+// from the instance metadata service, with IMDSv2 session tokens.
+type imds struct {
+	httpClient *http.Client
+	endpoint   string
+}
+
+func (i *imds) credentials(ctx context.Context) (sigv4.Credentials, time.Time, error) {
+	const action = "getting EC2 instance profile credentials"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, i.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("creating IMDS request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(int(imdsTokenTTL.Seconds())))
+	token, err := i.get(req, action)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, err
+	}
+
+	const path = "/latest/meta-data/iam/security-credentials/"
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, i.endpoint+path, nil)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("creating IMDS request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	roles, err := i.get(req, action)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, err
+	}
+	role, _, _ := strings.Cut(roles, "\n")
+	if role == "" {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("%s: the instance has no IAM role", action)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, i.endpoint+path+url.PathEscape(role), nil)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("creating IMDS request: %w", err)
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return getJSONCredentials(i.httpClient, req, action)
+}
+
+// get sends req, returning the body of the response.
+func (i *imds) get(req *http.Request, action string) (string, error) {
+	response, err := i.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", action, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", statusError(action, response)
+	}
+	b, err := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", action, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// getJSONCredentials sends req, returning the credentials of the JSON
+// response, in the format of the ECS and EC2 endpoints.
+func getJSONCredentials(
+	httpClient *http.Client,
+	req *http.Request,
+	action string,
+) (sigv4.Credentials, time.Time, error) {
+	response, err := httpClient.Do(req)
+	if err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("%s: %w", action, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return sigv4.Credentials{}, time.Time{}, statusError(action, response)
+	}
+
+	var body struct {
+		// Code is only set by EC2.
+		Code            string
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("%s: parsing response: %w", action, err)
+	}
+	if body.Code != "" && body.Code != "Success" {
+		return sigv4.Credentials{}, time.Time{}, fmt.Errorf("%s: %s", action, body.Code)
+	}
+	return sigv4.Credentials{
+		AccessKeyID:     body.AccessKeyID,
+		SecretAccessKey: body.SecretAccessKey,
+		SessionToken:    body.Token,
+	}, body.Expiration, nil
+}
+
+// statusError returns the error of an unexpected response.
+func statusError(action string, response *http.Response) error {
+	//nolint:errcheck // we are already returning an error.
+	buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+	return fmt.Errorf("%s: unexpected HTTP status code: %w", action, internal.HTTPError{
+		Body:       strings.TrimSpace(string(buf)),
+		StatusCode: response.StatusCode,
+	})
+}
@@ -0,0 +1,218 @@
+package awscreds
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+)
+
+// clearEnv unsets the environment variables of all the sources.
+func clearEnv(t *testing.T) {
+	for _, name := range []string{
+		"AWS_ACCESS_KEY_ID",
+		"AWS_SECRET_ACCESS_KEY",
+		"AWS_SESSION_TOKEN",
+		"AWS_WEB_IDENTITY_TOKEN_FILE",
+		"AWS_ROLE_ARN",
+		"AWS_ROLE_SESSION_NAME",
+		"AWS_ENDPOINT_URL_STS",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+		"AWS_EC2_METADATA_DISABLED",
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestChainEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	c, err := New(nil)
+	require.NoError(t, err)
+	creds, err := c.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, sigv4.Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+	}, creds)
+
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	_, err = New(nil)
+	require.EqualError(
+		t,
+		err,
+		"the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables must be set together",
+	)
+}
+
+func TestChainWebIdentity(t *testing.T) {
+	clearEnv(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("jwt\n"), 0o600))
+
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRoleWithWebIdentity", r.Form.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/geoip", r.Form.Get("RoleArn"))
+		assert.Equal(t, "geoip-session", r.Form.Get("RoleSessionName"))
+		assert.Equal(t, "jwt", r.Form.Get("WebIdentityToken"))
+		fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse>
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAEXAMPLE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`, expiration.Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/geoip")
+	t.Setenv("AWS_ROLE_SESSION_NAME", "geoip-session")
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+
+	c, err := New(server.Client())
+	require.NoError(t, err)
+	creds, err := c.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, sigv4.Credentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+	}, creds)
+	assert.True(t, expiration.Equal(c.expiry))
+
+	t.Setenv("AWS_ROLE_ARN", "")
+	_, err = New(nil)
+	require.EqualError(
+		t,
+		err,
+		"the AWS_ROLE_ARN environment variable is required with AWS_WEB_IDENTITY_TOKEN_FILE",
+	)
+}
+
+func TestChainECS(t *testing.T) {
+	clearEnv(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("ecs-token"), 0o600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/credentials/id", r.URL.Path)
+		assert.Equal(t, "ecs-token", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{
+  "AccessKeyId": "ASIAEXAMPLE",
+  "SecretAccessKey": "secret",
+  "Token": "session",
+  "Expiration": "2100-01-01T00:00:00Z"
+}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/v2/credentials/id")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", tokenFile)
+
+	c, err := New(nil)
+	require.NoError(t, err)
+	creds, err := c.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, sigv4.Credentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+	}, creds)
+}
+
+func TestChainIMDS(t *testing.T) {
+	clearEnv(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/latest/api/token" {
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.Equal(t, "21600", r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			fmt.Fprint(w, "imds-token")
+			return
+		}
+		// IMDSv1 requests, without the session token, are rejected.
+		if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			fmt.Fprint(w, "geoip-role\n")
+		case "/latest/meta-data/iam/security-credentials/geoip-role":
+			fmt.Fprintf(w, `{
+  "Code": "Success",
+  "AccessKeyId": "ASIAEXAMPLE",
+  "SecretAccessKey": "secret",
+  "Token": "session-%d",
+  "Expiration": "2024-01-02T12:00:00Z"
+}`, requests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL+"/")
+
+	c, err := New(nil)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 2, 11, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	creds, err := c.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, sigv4.Credentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session-3",
+	}, creds)
+
+	// The credentials are cached until they are about to expire.
+	creds, err = c.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "session-3", creds.SessionToken)
+	assert.Equal(t, 3, requests)
+
+	now = now.Add(57 * time.Minute)
+	creds, err = c.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "session-6", creds.SessionToken)
+}
+
+func TestChainIMDSDisabled(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	_, err := New(nil)
+	require.EqualError(
+		t,
+		err,
+		"no AWS credentials found: set the AWS_ACCESS_KEY_ID and "+
+			"AWS_SECRET_ACCESS_KEY environment variables, or run with an IAM role",
+	)
+}
@@ -0,0 +1,227 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// azureVersion is the version of the Blob Storage API used.
+const azureVersion = "2021-08-06"
+
+// azureBucket stores objects in an Azure Blob Storage container.
+type azureBucket struct {
+	httpClient *http.Client
+	// container is the URL of the container, and prefix the path of the
+	// objects in it.
+	container *url.URL
+	prefix    string
+	account   string
+	// Either key, the decoded shared key, or sasToken is set.
+	key      []byte
+	sasToken string
+	now      func() time.Time
+}
+
+func newAzureBucket(u *url.URL, httpClient *http.Client) (*azureBucket, error) {
+	account, prefix, err := azureContainer(u)
+	if err != nil {
+		return nil, err
+	}
+	container := *u
+	container.Path = "/" + strings.TrimSuffix(strings.TrimSuffix(strings.Trim(u.Path, "/"), prefix), "/")
+	container.RawPath = ""
+	b := &azureBucket{
+		httpClient: httpClient,
+		container:  &container,
+		prefix:     prefix,
+		account:    account,
+		now:        time.Now,
+	}
+
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		b.key, err = base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, errors.New("AZURE_STORAGE_KEY must be a base64-encoded key")
+		}
+		return b, nil
+	}
+	if token := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); token != "" {
+		b.sasToken = strings.TrimPrefix(token, "?")
+		return b, nil
+	}
+	return nil, errors.New(
+		"the AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN environment variable " +
+			"is required to write to Azure Blob Storage",
+	)
+}
+
+// azureContainer returns the storage account of u, the URL of a container,
+// and the prefix of the objects in the container. The account is the first
+// label of the host, e.g., https://account.blob.core.windows.net/container,
+// or else the first segment of the path, as with the Azurite emulator,
+// e.g., http://127.0.0.1:10000/account/container.
+func azureContainer(u *url.URL) (string, string, error) {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	account, _, _ := strings.Cut(u.Hostname(), ".")
+	if !strings.HasSuffix(u.Hostname(), ".blob.core.windows.net") {
+		account = segments[0]
+		segments = segments[1:]
+	}
+	if account == "" || len(segments) == 0 || segments[0] == "" {
+		return "", "", errors.New("expected the URL of an Azure Blob Storage container")
+	}
+	return account, strings.Join(segments[1:], "/"), nil
+}
+
+func (b *azureBucket) Put(ctx context.Context, name string, body io.Reader, size int64, md5sum []byte) error {
+	name = objectName(b.prefix, name)
+	req, err := b.newRequest(ctx, http.MethodPut, name, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum))
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	if err := b.authenticate(req); err != nil {
+		return err
+	}
+
+	response, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", name, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		return statusError("uploading "+name, response)
+	}
+	return nil
+}
+
+func (b *azureBucket) MD5(ctx context.Context, name string) ([]byte, error) {
+	name = objectName(b.prefix, name)
+	req, err := b.newRequest(ctx, http.MethodHead, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.authenticate(req); err != nil {
+		return nil, err
+	}
+	response, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("checking %s: %w", name, err)
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, statusError("checking "+name, response)
+	}
+
+	value := response.Header.Get("Content-MD5")
+	if value == "" {
+		return nil, nil
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("checking %s: invalid MD5 sum '%s'", name, value)
+	}
+	return sum, nil
+}
+
+func (b *azureBucket) newRequest(ctx context.Context, method, name string, body io.Reader) (*http.Request, error) {
+	u := *b.container
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	u.RawPath = strings.TrimSuffix(b.container.EscapedPath(), "/") + "/" + escapePath(name)
+	if b.sasToken != "" {
+		u.RawQuery = b.sasToken
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("creating Blob Storage request: %w", err)
+	}
+	req.Header.Set("User-Agent", "geoipupdate/"+vars.Version)
+	req.Header.Set("X-Ms-Date", b.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("X-Ms-Version", azureVersion)
+	return req, nil
+}
+
+// authenticate signs req with the shared key, if any, adding its
+// Authorization header. It must be called once all other headers are set.
+func (b *azureBucket) authenticate(req *http.Request) error {
+	if b.key == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, b.key)
+	if _, err := mac.Write([]byte(b.stringToSign(req))); err != nil {
+		return fmt.Errorf("signing Blob Storage request: %w", err)
+	}
+	req.Header.Set(
+		"Authorization",
+		"SharedKey "+b.account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+	)
+	return nil
+}
+
+// stringToSign returns the string signed with the shared key for req, as
+// described by
+// https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key.
+func (b *azureBucket) stringToSign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	lines := []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		// The date is given by X-Ms-Date.
+		"",
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}
+
+	var headers []string
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			headers = append(headers, name+":"+strings.Join(values, ","))
+		}
+	}
+	slices.Sort(headers)
+	lines = append(lines, headers...)
+
+	resource := "/" + b.account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		slices.Sort(values)
+		params = append(params, strings.ToLower(name)+":"+strings.Join(values, ","))
+	}
+	slices.Sort(params)
+	lines = append(lines, resource)
+	lines = append(lines, params...)
+	return strings.Join(lines, "\n")
+}
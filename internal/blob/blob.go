@@ -0,0 +1,113 @@
+// Package blob writes objects to object storage, Google Cloud Storage and
+// Azure Blob Storage, with their HTTP APIs.
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+)
+
+// Bucket stores objects under the prefix of a destination.
+type Bucket interface {
+	// Put uploads the size bytes of body as the object name. The upload
+	// fails if their MD5 sum isn't md5sum.
+	Put(ctx context.Context, name string, body io.Reader, size int64, md5sum []byte) error
+	// MD5 returns the MD5 sum of the object name, or nil if it doesn't
+	// exist or its MD5 sum isn't known, e.g., for composite objects.
+	MD5(ctx context.Context, name string) ([]byte, error)
+}
+
+// New returns the Bucket of destination, which is one of:
+//
+//   - gs://bucket[/prefix], for Google Cloud Storage, authenticating with
+//     the access token in GOOGLE_OAUTH_ACCESS_TOKEN, the service account
+//     key file in GOOGLE_APPLICATION_CREDENTIALS, or else the service
+//     account of the instance, from the metadata server.
+//   - azblob://account/container[/prefix], or the URL of the container,
+//     e.g., https://account.blob.core.windows.net/container[/prefix], for
+//     Azure Blob Storage, authenticating with the shared key in
+//     AZURE_STORAGE_KEY or the SAS token in AZURE_STORAGE_SAS_TOKEN.
+func New(destination string, httpClient *http.Client) (Bucket, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	u, err := parse(destination)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "gs" {
+		return newGCSBucket(u, httpClient)
+	}
+	return newAzureBucket(u, httpClient)
+}
+
+// Validate checks that destination is a valid destination, without
+// requiring the credentials it may need.
+func Validate(destination string) error {
+	_, err := parse(destination)
+	return err
+}
+
+// parse parses destination, returning an azblob:// URL as the URL of the
+// container.
+func parse(destination string) (*url.URL, error) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid destination '%s'", destination)
+	}
+	switch u.Scheme {
+	case "gs":
+		return u, nil
+	case "azblob":
+		if strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("the destination '%s' has no container", destination)
+		}
+		u.Scheme = "https"
+		u.Host += ".blob.core.windows.net"
+		return u, nil
+	case "http", "https":
+		if _, _, err := azureContainer(u); err != nil {
+			return nil, fmt.Errorf("invalid destination '%s': %w", destination, err)
+		}
+		return u, nil
+	default:
+		return nil, fmt.Errorf("unsupported destination '%s', expected gs://, azblob://, or an Azure container URL", destination)
+	}
+}
+
+// objectName returns the name of the object name under prefix.
+func objectName(prefix, name string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// escapePath escapes the segments of name for the path of a URL, keeping
+// its slashes.
+func escapePath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// statusError returns the error of the unexpected response to a request to
+// operation, e.g., "uploading GeoIP2-City.mmdb".
+func statusError(operation string, response *http.Response) error {
+	//nolint:errcheck // we are already returning an error.
+	buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+	httpErr := internal.HTTPError{
+		Body:       string(buf),
+		StatusCode: response.StatusCode,
+	}
+	return fmt.Errorf("%s: unexpected HTTP status code: %w", operation, httpErr)
+}
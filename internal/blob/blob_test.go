@@ -0,0 +1,345 @@
+package blob
+
+import (
+	"context"
+	"crypto"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		Destination string
+		Err         string
+	}{
+		{Destination: "gs://bucket"},
+		{Destination: "gs://bucket/geoip/"},
+		{Destination: "azblob://account/container"},
+		{Destination: "azblob://account/container/geoip"},
+		{Destination: "https://account.blob.core.windows.net/container/geoip"},
+		{Destination: "http://127.0.0.1:10000/devstoreaccount1/container"},
+		{
+			Destination: "/var/lib/GeoIP",
+			Err:         "invalid destination '/var/lib/GeoIP'",
+		},
+		{
+			Destination: "s3://bucket",
+			Err:         "unsupported destination 's3://bucket', expected gs://, azblob://, or an Azure container URL",
+		},
+		{
+			Destination: "azblob://account",
+			Err:         "the destination 'azblob://account' has no container",
+		},
+		{
+			Destination: "https://account.blob.core.windows.net/",
+			Err: "invalid destination 'https://account.blob.core.windows.net/': " +
+				"expected the URL of an Azure Blob Storage container",
+		},
+		{
+			Destination: "gs://bucket?sig=secret",
+			Err:         "invalid destination 'gs://bucket?sig=secret'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Destination, func(t *testing.T) {
+			err := Validate(test.Destination)
+			if test.Err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.Err)
+			}
+		})
+	}
+}
+
+func TestNewRequiresCredentials(t *testing.T) {
+	t.Setenv("AZURE_STORAGE_KEY", "")
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "")
+	_, err := New("azblob://account/container", nil)
+	assert.EqualError(
+		t,
+		err,
+		"the AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN environment variable "+
+			"is required to write to Azure Blob Storage",
+	)
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	t.Setenv("STORAGE_EMULATOR_HOST", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+	_, err = New("gs://bucket", nil)
+	assert.ErrorContains(t, err, "reading GOOGLE_APPLICATION_CREDENTIALS")
+}
+
+// fakeBucket serves the objects PUT to it and answers HEAD requests with
+// their MD5 sums in the header set by hashHeader.
+type fakeBucket struct {
+	t          *testing.T
+	putStatus  int
+	hashHeader func(http.Header, []byte)
+	objects    map[string][]byte
+	requests   []*http.Request
+}
+
+func (f *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r)
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(f.t, err)
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+		w.WriteHeader(f.putStatus)
+	case http.MethodHead:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sum := md5.Sum(body)
+		f.hashHeader(w.Header(), sum[:])
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGCSBucket(t *testing.T) {
+	fake := &fakeBucket{
+		t:         t,
+		putStatus: http.StatusOK,
+		hashHeader: func(h http.Header, sum []byte) {
+			h.Add("X-Goog-Hash", "crc32c=n03x6A==")
+			h.Add("X-Goog-Hash", "md5="+base64.StdEncoding.EncodeToString(sum))
+		},
+		objects: map[string][]byte{},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	b, err := New("gs://bucket/geoip/", nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	sum, err := b.MD5(ctx, "GeoIP2-City.mmdb")
+	require.NoError(t, err)
+	assert.Nil(t, sum)
+
+	body := "database content"
+	want := md5.Sum([]byte(body))
+	err = b.Put(ctx, "GeoIP2-City.mmdb", strings.NewReader(body), int64(len(body)), want[:])
+	require.NoError(t, err)
+	assert.Equal(t, body, string(fake.objects["/bucket/geoip/GeoIP2-City.mmdb"]))
+	assert.Empty(t, fake.requests[1].Header.Get("Authorization"))
+
+	sum, err = b.MD5(ctx, "GeoIP2-City.mmdb")
+	require.NoError(t, err)
+	assert.Equal(t, want[:], sum)
+
+	wrong := md5.Sum([]byte("other content"))
+	err = b.Put(ctx, "GeoIP2-City.mmdb", strings.NewReader(body), int64(len(body)), wrong[:])
+	assert.EqualError(
+		t,
+		err,
+		"uploading geoip/GeoIP2-City.mmdb: unexpected HTTP status code: received HTTP status code: 400: ",
+	)
+}
+
+func TestGCSServiceAccountToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var claims []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+		claimSet, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		claims = append(claims, string(claimSet))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = io.WriteString(w, `{"access_token":"ya29.token","expires_in":3600}`)
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyFile, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "updater@project.iam.gserviceaccount.com",
+		"private_key_id": "0123456789abcdef",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      server.URL + "/token",
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.json")
+	require.NoError(t, os.WriteFile(path, keyFile, 0o600))
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+	s, err := newGCSTokenSource(server.Client())
+	require.NoError(t, err)
+	now := time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		token, err := s.token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "ya29.token", token)
+	}
+	require.Len(t, claims, 1, "the token is cached")
+	assert.JSONEq(
+		t,
+		`{"iss":"updater@project.iam.gserviceaccount.com",`+
+			`"scope":"https://www.googleapis.com/auth/devstorage.read_write",`+
+			`"aud":"`+server.URL+`/token","iat":1708646400,"exp":1708650000}`,
+		claims[0],
+	)
+
+	// Less than a minute before the token expires, another one is requested.
+	now = now.Add(59*time.Minute + time.Second)
+	_, err = s.token(ctx)
+	require.NoError(t, err)
+	assert.Len(t, claims, 2)
+}
+
+func TestGCSMetadataToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/computeMetadata/v1/instance/service-accounts/default/token", r.URL.Path)
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, err := io.WriteString(w, `{"access_token":"ya29.instance","expires_in":3600}`)
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	s, err := newGCSTokenSource(server.Client())
+	require.NoError(t, err)
+
+	token, err := s.token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ya29.instance", token)
+}
+
+func TestAzureBucket(t *testing.T) {
+	fake := &fakeBucket{
+		t:         t,
+		putStatus: http.StatusCreated,
+		hashHeader: func(h http.Header, sum []byte) {
+			h.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum))
+		},
+		objects: map[string][]byte{},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	t.Setenv("AZURE_STORAGE_KEY", base64.StdEncoding.EncodeToString([]byte("shared key")))
+	b, err := New(server.URL+"/devstoreaccount1/container/geoip/", nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	sum, err := b.MD5(ctx, "GeoIP2-City.mmdb")
+	require.NoError(t, err)
+	assert.Nil(t, sum)
+
+	body := "database content"
+	want := md5.Sum([]byte(body))
+	err = b.Put(ctx, "GeoIP2-City.mmdb", strings.NewReader(body), int64(len(body)), want[:])
+	require.NoError(t, err)
+	assert.Equal(t, body, string(fake.objects["/devstoreaccount1/container/geoip/GeoIP2-City.mmdb"]))
+
+	put := fake.requests[1]
+	assert.Equal(t, "BlockBlob", put.Header.Get("X-Ms-Blob-Type"))
+	assert.Equal(t, azureVersion, put.Header.Get("X-Ms-Version"))
+	assert.True(t, strings.HasPrefix(put.Header.Get("Authorization"), "SharedKey devstoreaccount1:"))
+
+	sum, err = b.MD5(ctx, "GeoIP2-City.mmdb")
+	require.NoError(t, err)
+	assert.Equal(t, want[:], sum)
+}
+
+func TestAzureStringToSign(t *testing.T) {
+	t.Setenv("AZURE_STORAGE_KEY", base64.StdEncoding.EncodeToString([]byte("shared key")))
+	u, err := parse("azblob://account/container/geoip")
+	require.NoError(t, err)
+	b, err := newAzureBucket(u, nil)
+	require.NoError(t, err)
+	b.now = func() time.Time { return time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC) }
+
+	req, err := b.newRequest(context.Background(), http.MethodPut, "geoip/GeoIP2 City.mmdb", nil)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"https://account.blob.core.windows.net/container/geoip/GeoIP2%20City.mmdb",
+		req.URL.String(),
+	)
+	req.ContentLength = 16
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-MD5", "1B2M2Y8AsgTpgAmY7PhCfg==")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	assert.Equal(
+		t,
+		"PUT\n\n\n16\n1B2M2Y8AsgTpgAmY7PhCfg==\napplication/octet-stream\n\n\n\n\n\n\n"+
+			"x-ms-blob-type:BlockBlob\n"+
+			"x-ms-date:Fri, 23 Feb 2024 00:00:00 GMT\n"+
+			"x-ms-version:2021-08-06\n"+
+			"/account/container/geoip/GeoIP2%20City.mmdb",
+		b.stringToSign(req),
+	)
+}
+
+func TestAzureSASToken(t *testing.T) {
+	t.Setenv("AZURE_STORAGE_KEY", "")
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "?sv=2021-08-06&sig=secret")
+	u, err := parse("azblob://account/container")
+	require.NoError(t, err)
+	b, err := newAzureBucket(u, nil)
+	require.NoError(t, err)
+
+	req, err := b.newRequest(context.Background(), http.MethodHead, "GeoIP2-City.mmdb", nil)
+	require.NoError(t, err)
+	require.NoError(t, b.authenticate(req))
+	assert.Equal(
+		t,
+		"https://account.blob.core.windows.net/container/GeoIP2-City.mmdb?sv=2021-08-06&sig=secret",
+		req.URL.String(),
+	)
+	assert.Empty(t, req.Header.Get("Authorization"))
+}
@@ -0,0 +1,338 @@
+package blob
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// gcsScope is the OAuth 2.0 scope of the access tokens to Cloud Storage.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// gcsBucket stores objects in a Cloud Storage bucket, with the XML API.
+type gcsBucket struct {
+	httpClient *http.Client
+	// endpoint is the URL of the bucket, e.g.,
+	// https://storage.googleapis.com/bucket.
+	endpoint string
+	prefix   string
+	// tokens is nil with an emulator, which doesn't need credentials.
+	tokens *gcsTokenSource
+}
+
+func newGCSBucket(u *url.URL, httpClient *http.Client) (*gcsBucket, error) {
+	b := &gcsBucket{
+		httpClient: httpClient,
+		endpoint:   "https://storage.googleapis.com/" + u.Host,
+		prefix:     u.Path,
+	}
+	// STORAGE_EMULATOR_HOST is the address of an emulator, as with the
+	// Cloud Storage client libraries.
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		if !strings.Contains(emulator, "://") {
+			emulator = "http://" + emulator
+		}
+		b.endpoint = strings.TrimSuffix(emulator, "/") + "/" + u.Host
+		return b, nil
+	}
+
+	tokens, err := newGCSTokenSource(httpClient)
+	if err != nil {
+		return nil, err
+	}
+	b.tokens = tokens
+	return b, nil
+}
+
+func (b *gcsBucket) Put(ctx context.Context, name string, body io.Reader, size int64, md5sum []byte) error {
+	name = objectName(b.prefix, name)
+	req, err := b.newRequest(ctx, http.MethodPut, name, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum))
+
+	response, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", name, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return statusError("uploading "+name, response)
+	}
+	return nil
+}
+
+func (b *gcsBucket) MD5(ctx context.Context, name string) ([]byte, error) {
+	name = objectName(b.prefix, name)
+	req, err := b.newRequest(ctx, http.MethodHead, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("checking %s: %w", name, err)
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, statusError("checking "+name, response)
+	}
+
+	// The hashes are given as, e.g., crc32c=n03x6A==, md5=Ojk9c3dhfxgoKVVHYwFbHQ==,
+	// in one header or several.
+	for _, header := range response.Header.Values("X-Goog-Hash") {
+		for _, hash := range strings.Split(header, ",") {
+			value, ok := strings.CutPrefix(strings.TrimSpace(hash), "md5=")
+			if !ok {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("checking %s: invalid MD5 sum '%s'", name, value)
+			}
+			return sum, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *gcsBucket) newRequest(ctx context.Context, method, name string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+"/"+escapePath(name), body)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Storage request: %w", err)
+	}
+	req.Header.Set("User-Agent", "geoipupdate/"+vars.Version)
+	if b.tokens != nil {
+		token, err := b.tokens.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// gcsTokenSource gets, and caches, the OAuth 2.0 access tokens to Cloud
+// Storage.
+type gcsTokenSource struct {
+	httpClient *http.Client
+	// key is the service account key, if any. Without one, the tokens are
+	// those of the service account of the instance.
+	key *serviceAccountKey
+	// metadataHost is the host of the metadata server.
+	metadataHost string
+	now          func() time.Time
+
+	mu      sync.Mutex
+	current string
+	expiry  time.Time
+}
+
+// serviceAccountKey is a key file of a service account, as created by the
+// Google Cloud console.
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	signer       *rsa.PrivateKey
+}
+
+func newGCSTokenSource(httpClient *http.Client) (*gcsTokenSource, error) {
+	s := &gcsTokenSource{
+		httpClient:   httpClient,
+		metadataHost: "metadata.google.internal",
+		now:          time.Now,
+	}
+	// GOOGLE_OAUTH_ACCESS_TOKEN holds a token, e.g., from
+	// `gcloud auth print-access-token`, as with Terraform.
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		s.current = token
+		s.expiry = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		return s, nil
+	}
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		s.metadataHost = host
+	}
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		key, err := readServiceAccountKey(path)
+		if err != nil {
+			return nil, err
+		}
+		s.key = key
+	}
+	return s, nil
+}
+
+// readServiceAccountKey reads the service account key file at path.
+func readServiceAccountKey(path string) (*serviceAccountKey, error) {
+	//nolint:gosec // the path is given by the user.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, fmt.Errorf("parsing GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf(
+			"GOOGLE_APPLICATION_CREDENTIALS must be a service account key file, got the type '%s'",
+			key.Type,
+		)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("parsing GOOGLE_APPLICATION_CREDENTIALS: no private key found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the private key of GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	signer, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key of GOOGLE_APPLICATION_CREDENTIALS must be an RSA key")
+	}
+	key.signer = signer
+	return &key, nil
+}
+
+// token returns an access token valid for at least another minute.
+func (s *gcsTokenSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != "" && s.now().Add(time.Minute).Before(s.expiry) {
+		return s.current, nil
+	}
+
+	var req *http.Request
+	var err error
+	if s.key != nil {
+		req, err = s.serviceAccountRequest(ctx)
+	} else {
+		req, err = s.metadataRequest(ctx)
+	}
+	if err != nil {
+		return "", fmt.Errorf("creating Google Cloud access token request: %w", err)
+	}
+
+	response, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("getting a Google Cloud access token: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", statusError("getting a Google Cloud access token", response)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("parsing Google Cloud access token: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", errors.New("getting a Google Cloud access token: no token returned")
+	}
+	s.current = body.AccessToken
+	s.expiry = s.now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	return s.current, nil
+}
+
+// metadataRequest returns the request getting an access token of the
+// service account of the instance from the metadata server.
+func (s *gcsTokenSource) metadataRequest(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		"http://"+s.metadataHost+"/computeMetadata/v1/instance/service-accounts/default/token",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return req, nil
+}
+
+// serviceAccountRequest returns the request exchanging a JWT signed with the
+// service account key for an access token.
+func (s *gcsTokenSource) serviceAccountRequest(ctx context.Context) (*http.Request, error) {
+	assertion, err := s.key.assertion(s.now())
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		s.key.TokenURI,
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// assertion returns the JWT, signed with k, requesting an access token to
+// Cloud Storage issued at now.
+func (k *serviceAccountKey) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": k.PrivateKeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   k.ClientEmail,
+		"scope": gcsScope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing Google Cloud access token request: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
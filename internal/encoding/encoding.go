@@ -0,0 +1,118 @@
+// Package encoding implements the encodings, such as gzip compression,
+// databases can be stored with by writers.
+package encoding
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Names of the encodings.
+const (
+	// None stores databases as is.
+	None = "none"
+	// Gzip stores databases compressed with gzip.
+	Gzip = "gzip"
+	// Zstd stores databases compressed with Zstandard.
+	Zstd = "zstd"
+)
+
+// Encoding is an encoding of databases.
+type Encoding struct {
+	// Name is the name of the encoding in configurations and media types,
+	// e.g., "gzip".
+	Name string
+	// Extension is appended to the name of encoded files, e.g., ".gz".
+	Extension string
+	// NewWriter returns a writer encoding what is written to it to w. It
+	// must be closed to flush the encoded content.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decoding what it reads from r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// encodings are the supported encodings by name.
+var encodings = map[string]Encoding{
+	None: {
+		Name: None,
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return nopWriteCloser{w}, nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	},
+	Gzip: {
+		Name:      Gzip,
+		Extension: ".gz",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			// The header is left empty so that encoding the same database
+			// always gives the same content.
+			return gzip.NewWriter(w), nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, fmt.Errorf("reading gzip header: %w", err)
+			}
+			return zr, nil
+		},
+	},
+	Zstd: {
+		Name:      Zstd,
+		Extension: ".zst",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			// A single goroutine encodes, so that encoding the same
+			// database always gives the same content.
+			zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				return nil, fmt.Errorf("creating zstd writer: %w", err)
+			}
+			return zw, nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, fmt.Errorf("creating zstd reader: %w", err)
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+}
+
+// Lookup returns the encoding named name. The empty name is None.
+func Lookup(name string) (Encoding, error) {
+	if name == "" {
+		name = None
+	}
+	if e, ok := encodings[name]; ok {
+		return e, nil
+	}
+	return Encoding{}, fmt.Errorf("unknown encoding '%s', expected one of %v", name, Names())
+}
+
+// Names returns the names of the supported encodings, sorted.
+func Names() []string {
+	names := make([]string, 0, len(encodings))
+	for name := range encodings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// IsNone reports whether e leaves databases as is.
+func (e Encoding) IsNone() bool {
+	return e.Name == "" || e.Name == None
+}
+
+// nopWriteCloser adds a no-op Close method to an io.Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
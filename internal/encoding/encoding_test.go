@@ -0,0 +1,51 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	content := bytes.Repeat([]byte("database content"), 100)
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			e, err := Lookup(name)
+			require.NoError(t, err)
+
+			var encoded bytes.Buffer
+			w, err := e.NewWriter(&encoded)
+			require.NoError(t, err)
+			_, err = w.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			r, err := e.NewReader(&encoded)
+			require.NoError(t, err)
+			decoded, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, content, decoded)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	e, err := Lookup("")
+	require.NoError(t, err)
+	assert.True(t, e.IsNone())
+
+	e, err = Lookup(Gzip)
+	require.NoError(t, err)
+	assert.Equal(t, ".gz", e.Extension)
+
+	e, err = Lookup(Zstd)
+	require.NoError(t, err)
+	assert.Equal(t, ".zst", e.Extension)
+
+	_, err = Lookup("brotli")
+	require.EqualError(t, err, "unknown encoding 'brotli', expected one of [gzip none zstd]")
+}
@@ -0,0 +1,257 @@
+// Package encryption encrypts the databases stored where the storage is
+// shared, e.g., in OCI registries, with AES-256-GCM, so that only the hosts
+// holding the key can read them.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Algorithm is the name of the encryption in annotations.
+const Algorithm = "aes-256-gcm"
+
+// KeySize is the size in bytes of the keys.
+const KeySize = 32
+
+// The encrypted content starts with a header made of magic and a random
+// nonce prefix, followed by chunks of up to chunkSize bytes of the
+// plaintext, each sealed with a nonce made of the prefix, the index of the
+// chunk, and whether it is the last one, so that chunks can't be
+// reordered or dropped.
+const (
+	magic       = "GEOIPENC\x01"
+	prefixSize  = 7
+	headerSize  = len(magic) + prefixSize
+	chunkSize   = 64 << 10
+	lastChunk   = 1
+	maxChunks   = 1<<32 - 1
+	counterSize = 4
+)
+
+// errNotEncrypted is returned when the content doesn't start with the
+// header of encrypted content.
+var errNotEncrypted = errors.New("the content is not encrypted with a supported format")
+
+// Key is a key databases are encrypted with.
+type Key struct {
+	// ID identifies the key without revealing it, e.g., to tell which key
+	// a database was encrypted with.
+	ID  string
+	key []byte
+}
+
+// ParseKey parses data, a base64-encoded key of KeySize bytes, e.g., as
+// generated by `openssl rand -base64 32`.
+func ParseKey(data []byte) (Key, error) {
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != KeySize {
+		return Key{}, fmt.Errorf("the key must be %d bytes encoded in base64", KeySize)
+	}
+	return newKey(key), nil
+}
+
+// newKey returns the Key of the raw key.
+func newKey(key []byte) Key {
+	sum := sha256.Sum256(key)
+	return Key{ID: hex.EncodeToString(sum[:8]), key: key}
+}
+
+// ReadKeyFile reads the key in the file at path, as parsed by ParseKey.
+func ReadKeyFile(path string) (Key, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Key{}, fmt.Errorf("reading encryption key: %w", err)
+	}
+	k, err := ParseKey(data)
+	if err != nil {
+		return Key{}, fmt.Errorf("parsing encryption key %s: %w", path, err)
+	}
+	return k, nil
+}
+
+func (k Key) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// NewWriter returns a writer encrypting what is written to it to w. It must
+// be closed to write the last chunk.
+func (k Key) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("writing encryption header: %w", err)
+	}
+	return &writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+type writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	sealed  []byte
+	counter uint64
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once there is more to write, as the
+		// last chunk is sealed by Close.
+		if len(w.buf) == chunkSize {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (w *writer) Close() error {
+	return w.seal(true)
+}
+
+func (w *writer) seal(last bool) error {
+	if w.counter > maxChunks {
+		return errors.New("the content is too large to be encrypted")
+	}
+	nonce := chunkNonce(w.header, w.counter, last)
+	w.sealed = w.aead.Seal(w.sealed[:0], nonce, w.buf, w.header)
+	if _, err := w.w.Write(w.sealed); err != nil {
+		return fmt.Errorf("writing encrypted content: %w", err)
+	}
+	w.buf = w.buf[:0]
+	w.counter++
+	return nil
+}
+
+// NewReader returns a reader decrypting what it reads from r. Reading fails
+// if the content was not encrypted with the key or was altered.
+func (k Key) NewReader(r io.Reader) (io.Reader, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errNotEncrypted
+		}
+		return nil, fmt.Errorf("reading encryption header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errNotEncrypted
+	}
+	return &reader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+type reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	plain   []byte
+	opened  []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.open()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// open decrypts the next chunk.
+func (r *reader) open() error {
+	n, err := io.ReadFull(r.r, r.buf)
+	last := false
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case errors.Is(err, io.EOF):
+		return errors.New("the encrypted content is truncated")
+	case err != nil:
+		return fmt.Errorf("reading encrypted content: %w", err)
+	default:
+		if _, err := r.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return fmt.Errorf("reading encrypted content: %w", err)
+		}
+	}
+
+	nonce := chunkNonce(r.header, r.counter, last)
+	r.opened, err = r.aead.Open(r.opened[:0], nonce, r.buf[:n], r.header)
+	if err != nil {
+		return errors.New("decrypting: the key is wrong or the content was altered")
+	}
+	r.plain = r.opened
+	r.done = last
+	r.counter++
+	return nil
+}
+
+// chunkNonce returns the nonce of the chunk counter of the content with
+// header.
+func chunkNonce(header []byte, counter uint64, last bool) []byte {
+	nonce := make([]byte, prefixSize+counterSize+1)
+	copy(nonce, header[len(magic):])
+	binary.BigEndian.PutUint32(nonce[prefixSize:], uint32(counter))
+	if last {
+		nonce[prefixSize+counterSize] = lastChunk
+	}
+	return nonce
+}
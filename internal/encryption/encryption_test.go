@@ -0,0 +1,102 @@
+package encryption
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func encrypt(t *testing.T, k Key, content []byte) []byte {
+	var buf bytes.Buffer
+	w, err := k.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	k, err := ParseKey([]byte(testKey + "\n"))
+	require.NoError(t, err)
+	assert.Len(t, k.ID, 16)
+
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		content := []byte(strings.Repeat("x", size))
+		encrypted := encrypt(t, k, content)
+		assert.NotContains(t, string(encrypted), strings.Repeat("x", 16))
+
+		r, err := k.NewReader(bytes.NewReader(encrypted))
+		require.NoError(t, err)
+		decrypted, err := io.ReadAll(r)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, content, decrypted, "size %d", size)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	k, err := ParseKey([]byte(testKey))
+	require.NoError(t, err)
+	encrypted := encrypt(t, k, []byte(strings.Repeat("x", 2*chunkSize+10)))
+
+	other, err := ParseKey([]byte("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="))
+	require.NoError(t, err)
+	assert.NotEqual(t, k.ID, other.ID)
+
+	tests := []struct {
+		Description string
+		Key         Key
+		Content     []byte
+		Err         string
+	}{
+		{
+			Description: "wrong key",
+			Key:         other,
+			Content:     encrypted,
+			Err:         "decrypting: the key is wrong or the content was altered",
+		},
+		{
+			Description: "last chunk dropped",
+			Key:         k,
+			Content:     encrypted[:len(encrypted)-26],
+			Err:         "decrypting: the key is wrong or the content was altered",
+		},
+		{
+			Description: "truncated at a chunk boundary",
+			Key:         k,
+			Content:     encrypted[:headerSize],
+			Err:         "the encrypted content is truncated",
+		},
+		{
+			Description: "altered",
+			Key:         k,
+			Content:     append(slices.Clone(encrypted[:headerSize+5]), append([]byte{'!'}, encrypted[headerSize+6:]...)...),
+			Err:         "decrypting: the key is wrong or the content was altered",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			r, err := test.Key.NewReader(bytes.NewReader(test.Content))
+			require.NoError(t, err)
+			_, err = io.ReadAll(r)
+			require.EqualError(t, err, test.Err)
+		})
+	}
+
+	_, err = k.NewReader(strings.NewReader("database"))
+	require.ErrorIs(t, err, errNotEncrypted)
+}
+
+func TestParseKey(t *testing.T) {
+	_, err := ParseKey([]byte("c2hvcnQ="))
+	require.EqualError(t, err, "the key must be 32 bytes encoded in base64")
+	_, err = ParseKey([]byte("not base64"))
+	require.EqualError(t, err, "the key must be 32 bytes encoded in base64")
+}
@@ -0,0 +1,402 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/awscreds"
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// KeyWrapper wraps the data keys databases are encrypted with, i.e.,
+// encrypts them with a key-encryption key held by a key management service,
+// so that no static key has to be configured. Each database is encrypted
+// with a new data key, stored wrapped along with it.
+type KeyWrapper interface {
+	Wrap(ctx context.Context, dataKey []byte) (WrappedKey, error)
+	Unwrap(ctx context.Context, wrapped WrappedKey) ([]byte, error)
+}
+
+// WrappedKey is a data key encrypted by a key management service.
+type WrappedKey struct {
+	// KEK identifies the key-encryption key, including its version when
+	// the service tells it, e.g., to tell whether it was rotated since.
+	KEK        string
+	Ciphertext []byte
+}
+
+// NewDataKey returns a new random data key, along with the key wrapped by
+// w, to be stored with the content encrypted with it.
+func NewDataKey(ctx context.Context, w KeyWrapper) (Key, WrappedKey, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return Key{}, WrappedKey{}, fmt.Errorf("generating data key: %w", err)
+	}
+	wrapped, err := w.Wrap(ctx, key)
+	if err != nil {
+		return Key{}, WrappedKey{}, fmt.Errorf("wrapping data key: %w", err)
+	}
+	return newKey(key), wrapped, nil
+}
+
+// UnwrapKey returns the data key wrapped by w.
+func UnwrapKey(ctx context.Context, w KeyWrapper, wrapped WrappedKey) (Key, error) {
+	key, err := w.Unwrap(ctx, wrapped)
+	if err != nil {
+		return Key{}, fmt.Errorf("unwrapping data key: %w", err)
+	}
+	if len(key) != KeySize {
+		return Key{}, fmt.Errorf("unwrapping data key: got %d bytes rather than %d", len(key), KeySize)
+	}
+	return newKey(key), nil
+}
+
+// NewKeyWrapper returns the KeyWrapper of target, which is one of:
+//
+//   - an AWS KMS key ARN, e.g.,
+//     arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab,
+//     used with the AWS credentials of the environment, as found by
+//     awscreds.New.
+//   - gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k, a Google
+//     Cloud KMS key, used with the access token of the
+//     GOOGLE_OAUTH_ACCESS_TOKEN environment variable, or else of the
+//     default service account from the metadata server.
+//   - vault://host[:port]/mount/key, a key of a HashiCorp Vault transit
+//     secrets engine, used with the token of the VAULT_TOKEN environment
+//     variable. Vault is accessed over HTTPS unless the scheme is
+//     vault+http.
+func NewKeyWrapper(target string, httpClient *http.Client) (KeyWrapper, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	w, err := parseKeyWrapper(target, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	switch w := w.(type) {
+	case *awsKMS:
+		creds, err := awscreds.New(httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("using AWS KMS: %w", err)
+		}
+		w.signer.Provider = creds
+	case *vaultTransit:
+		w.token = os.Getenv("VAULT_TOKEN")
+		if w.token == "" {
+			return nil, errors.New("the VAULT_TOKEN environment variable is required to use Vault")
+		}
+	}
+	return w, nil
+}
+
+// ValidateKeyWrapper checks that target is a valid target of NewKeyWrapper,
+// without requiring the credentials it may need.
+func ValidateKeyWrapper(target string) error {
+	_, err := parseKeyWrapper(target, http.DefaultClient)
+	return err
+}
+
+// parseKeyWrapper returns the KeyWrapper of target, without its
+// credentials.
+func parseKeyWrapper(target string, httpClient *http.Client) (KeyWrapper, error) {
+	if strings.HasPrefix(target, "arn:") {
+		return newAWSKMS(target, httpClient)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key management service '%s'", target)
+	}
+	switch u.Scheme {
+	case "gcpkms":
+		name := u.Host + u.Path
+		parts := strings.Split(name, "/")
+		if len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" ||
+			parts[4] != "keyRings" || parts[6] != "cryptoKeys" {
+			return nil, fmt.Errorf("invalid Google Cloud KMS key '%s'", target)
+		}
+		return &gcpKMS{
+			httpClient: httpClient,
+			endpoint:   "https://cloudkms.googleapis.com/v1/",
+			name:       name,
+		}, nil
+	case "vault", "vault+http":
+		mount, key, ok := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		if u.Host == "" || !ok || mount == "" || key == "" || strings.Contains(key, "/") {
+			return nil, fmt.Errorf("invalid Vault transit key '%s'", target)
+		}
+		scheme := "https"
+		if u.Scheme == "vault+http" {
+			scheme = "http"
+		}
+		return &vaultTransit{
+			httpClient: httpClient,
+			address:    scheme + "://" + u.Host,
+			mount:      mount,
+			key:        key,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key management service '%s'", target)
+	}
+}
+
+// awsKMS wraps keys with an AWS KMS key.
+type awsKMS struct {
+	httpClient *http.Client
+	endpoint   string
+	keyID      string
+	signer     *sigv4.Signer
+}
+
+// newAWSKMS returns the awsKMS of the key arn, without credentials.
+func newAWSKMS(arn string, httpClient *http.Client) (*awsKMS, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || !strings.HasPrefix(parts[1], "aws") || parts[2] != "kms" ||
+		parts[3] == "" || parts[4] == "" || parts[5] == "" {
+		return nil, fmt.Errorf("invalid AWS KMS key ARN '%s'", arn)
+	}
+	return &awsKMS{
+		httpClient: httpClient,
+		endpoint:   "https://kms." + parts[3] + ".amazonaws.com/",
+		keyID:      arn,
+		signer:     &sigv4.Signer{Region: parts[3], Service: "kms"},
+	}, nil
+}
+
+func (k *awsKMS) Wrap(ctx context.Context, dataKey []byte) (WrappedKey, error) {
+	var res struct {
+		CiphertextBlob []byte
+		KeyID          string `json:"KeyId"`
+	}
+	err := k.call(ctx, "Encrypt", map[string]any{"KeyId": k.keyID, "Plaintext": dataKey}, &res)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+	return WrappedKey{KEK: res.KeyID, Ciphertext: res.CiphertextBlob}, nil
+}
+
+func (k *awsKMS) Unwrap(ctx context.Context, wrapped WrappedKey) ([]byte, error) {
+	var res struct {
+		Plaintext []byte
+	}
+	err := k.call(ctx, "Decrypt", map[string]any{"KeyId": k.keyID, "CiphertextBlob": wrapped.Ciphertext}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+// call calls the action of the KMS API with params, decoding the response
+// into res.
+func (k *awsKMS) call(ctx context.Context, action string, params, res any) error {
+	return postJSON(ctx, k.httpClient, k.endpoint, params, res, func(req *http.Request, body []byte) error {
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService."+action)
+		if err := k.signer.AuthenticatePayload(req, body); err != nil {
+			return fmt.Errorf("signing KMS request: %w", err)
+		}
+		return nil
+	})
+}
+
+// gcpKMS wraps keys with a Google Cloud KMS key.
+type gcpKMS struct {
+	httpClient *http.Client
+	endpoint   string
+	// name is the resource name of the key.
+	name string
+	// tokenURL is the metadata server URL of the access token of the
+	// default service account, used if GOOGLE_OAUTH_ACCESS_TOKEN isn't set.
+	tokenURL string
+}
+
+// gcpMetadataTokenURL is the URL of the access token of the default
+// service account on Google Cloud.
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+func (k *gcpKMS) Wrap(ctx context.Context, dataKey []byte) (WrappedKey, error) {
+	var res struct {
+		// Name is the name of the key version used.
+		Name       string `json:"name"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := k.call(ctx, "encrypt", map[string]any{"plaintext": dataKey}, &res); err != nil {
+		return WrappedKey{}, err
+	}
+	return WrappedKey{KEK: res.Name, Ciphertext: res.Ciphertext}, nil
+}
+
+func (k *gcpKMS) Unwrap(ctx context.Context, wrapped WrappedKey) ([]byte, error) {
+	var res struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := k.call(ctx, "decrypt", map[string]any{"ciphertext": wrapped.Ciphertext}, &res); err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+// call calls the method of the key, e.g., encrypt, with params, decoding
+// the response into res.
+func (k *gcpKMS) call(ctx context.Context, method string, params, res any) error {
+	token, err := k.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, k.httpClient, k.endpoint+k.name+":"+method, params, res, func(req *http.Request, _ []byte) error {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// accessToken returns the access token of GOOGLE_OAUTH_ACCESS_TOKEN, or else
+// of the default service account.
+func (k *gcpKMS) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	tokenURL := k.tokenURL
+	if tokenURL == "" {
+		tokenURL = gcpMetadataTokenURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	response, err := k.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf(
+			"getting an access token from the metadata server, "+
+				"as GOOGLE_OAUTH_ACCESS_TOKEN isn't set: %w",
+			err,
+		)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(
+			"getting an access token from the metadata server: unexpected HTTP status code %d",
+			response.StatusCode,
+		)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<16)).Decode(&token); err != nil {
+		return "", fmt.Errorf("parsing access token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+// vaultTransit wraps keys with a key of a Vault transit secrets engine.
+type vaultTransit struct {
+	httpClient *http.Client
+	address    string
+	mount      string
+	key        string
+	token      string
+}
+
+func (v *vaultTransit) Wrap(ctx context.Context, dataKey []byte) (WrappedKey, error) {
+	var res struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+			KeyVersion int    `json:"key_version"`
+		} `json:"data"`
+	}
+	params := map[string]any{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := v.call(ctx, "encrypt", params, &res); err != nil {
+		return WrappedKey{}, err
+	}
+	return WrappedKey{
+		KEK:        fmt.Sprintf("%s/%s/%s@v%d", v.address, v.mount, v.key, res.Data.KeyVersion),
+		Ciphertext: []byte(res.Data.Ciphertext),
+	}, nil
+}
+
+func (v *vaultTransit) Unwrap(ctx context.Context, wrapped WrappedKey) ([]byte, error) {
+	var res struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	params := map[string]any{"ciphertext": string(wrapped.Ciphertext)}
+	if err := v.call(ctx, "decrypt", params, &res); err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(res.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Vault response: %w", err)
+	}
+	return key, nil
+}
+
+// call calls the operation of the key, e.g., encrypt, with params, decoding
+// the response into res.
+func (v *vaultTransit) call(ctx context.Context, operation string, params, res any) error {
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", v.address, v.mount, operation, url.PathEscape(v.key))
+	return postJSON(ctx, v.httpClient, endpoint, params, res, func(req *http.Request, _ []byte) error {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Vault-Token", v.token)
+		return nil
+	})
+}
+
+// postJSON posts params encoded as JSON to endpoint, with the request
+// prepared by prepare, decoding the response into res.
+func postJSON(
+	ctx context.Context,
+	httpClient *http.Client,
+	endpoint string,
+	params any,
+	res any,
+	prepare func(req *http.Request, body []byte) error,
+) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+	if err := prepare(req, body); err != nil {
+		return err
+	}
+
+	response, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting %s: %w", req.URL.Host, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		//nolint:errcheck // we are already returning an error.
+		buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		httpErr := internal.HTTPError{
+			Body:       string(buf),
+			StatusCode: response.StatusCode,
+		}
+		return fmt.Errorf("requesting %s: unexpected HTTP status code: %w", req.URL.Host, httpErr)
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(res); err != nil {
+		return fmt.Errorf("parsing response of %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +10,10 @@ import (
 	"github.com/gofrs/flock"
 )
 
+// ErrLockHeld is returned by FileLock.Acquire when another process holds
+// the lock.
+var ErrLockHeld = errors.New("already acquired by another process")
+
 // FileLock provides a file lock mechanism based on flock.
 type FileLock struct {
 	lock    *flock.Flock
@@ -53,7 +58,7 @@ func (f *FileLock) Acquire() error {
 		return fmt.Errorf("acquiring file lock at %s: %w", f.lock.Path(), err)
 	}
 	if !ok {
-		return fmt.Errorf("lock %s already acquired by another process", f.lock.Path())
+		return fmt.Errorf("lock %s %w", f.lock.Path(), ErrLockHeld)
 	}
 	if f.verbose {
 		log.Printf("Acquired lock file at %s", f.lock.Path())
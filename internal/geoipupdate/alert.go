@@ -0,0 +1,85 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+)
+
+// recordFailure records in e that a run failed to update the edition with
+// err at now.
+func recordFailure(e *state.Edition, err error, now time.Time) {
+	if e.ConsecutiveFailures == 0 {
+		e.FailingSince = now.In(time.UTC)
+	}
+	e.ConsecutiveFailures++
+	e.LastError = err.Error()
+}
+
+// alert announces to the Notify targets the editions that failed to update
+// for AlertAfterFailures runs in a row, and those that were updated again
+// after such an announcement. Like updates, alerts that fail are made again
+// by the next runs until they succeed.
+func (u *Updater) alert(ctx context.Context, store *state.Store, runID string) error {
+	if u.config.AlertAfterFailures == 0 || len(u.notifiers) == 0 {
+		return nil
+	}
+	// Alerts are also made when the run timed out.
+	ctx = context.WithoutCancel(ctx)
+
+	var errs error
+	for _, editionID := range u.editionIDs {
+		s := store.Edition(editionID)
+
+		var a notify.Announcement
+		switch {
+		case !s.Alerted && s.ConsecutiveFailures >= u.config.AlertAfterFailures:
+			a = notify.Announcement{
+				Event:    notify.EventFailing,
+				Failures: s.ConsecutiveFailures,
+				Error:    s.LastError,
+			}
+		case s.Alerted && s.ConsecutiveFailures == 0:
+			a = notify.Announcement{
+				Event: notify.EventRecovered,
+				MD5:   s.Hash,
+			}
+		default:
+			continue
+		}
+		a.RunID = runID
+		// The ID is the same for every attempt at alerting about the same
+		// series of failures.
+		a.UpdateID = updateID(editionID, a.Event+":"+s.FailingSince.Format(time.RFC3339Nano))
+		a.EditionID = editionID
+		a.Date = s.FailingSince
+		a.Labels = u.config.Labels
+
+		failed := false
+		for i, notifier := range u.notifiers {
+			if err := notifier.Notify(ctx, a); err != nil {
+				failed = true
+				errs = errors.Join(errs, fmt.Errorf(
+					"alerting about %s to %s: %w",
+					editionID,
+					notify.Redact(u.config.Notify[i]),
+					err,
+				))
+			}
+		}
+		if failed {
+			continue
+		}
+		err := store.Update(editionID, func(e *state.Edition) {
+			e.Alerted = a.Event == notify.EventFailing
+		})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("updating state of %s: %w", editionID, err))
+		}
+	}
+	return errs
+}
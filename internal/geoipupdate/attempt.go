@@ -0,0 +1,116 @@
+package geoipupdate
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// newAttempt describes an attempt that took d and failed with err, if not
+// nil. body is the database received, if any.
+func newAttempt(d time.Duration, body *readErrorRecorder, err error) state.Attempt {
+	a := state.Attempt{Duration: d}
+	if body != nil {
+		a.Bytes = body.n
+	}
+	if err == nil {
+		// The client only returns responses with this status code.
+		a.StatusCode = http.StatusOK
+		return a
+	}
+
+	var httpErr internal.HTTPError
+	if errors.As(err, &httpErr) {
+		a.StatusCode = httpErr.StatusCode
+	} else if body != nil {
+		a.StatusCode = http.StatusOK
+	}
+	a.Reason = attemptReason(body, err)
+	return a
+}
+
+// attemptReason classifies err, the error of an attempt, so that failures
+// on the side of MaxMind, of a proxy, or of the host can be told apart. The
+// reasons are the classes of errors of RetryPolicy.
+func attemptReason(body *readErrorRecorder, err error) string {
+	var httpErr internal.HTTPError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var mismatchErr *database.ChecksumMismatchError
+	var downgradeErr *database.DowngradeError
+	var diskErr *database.DiskUsageError
+	switch {
+	case errors.As(err, &httpErr):
+		return fmt.Sprintf("http_%d", httpErr.StatusCode)
+	case errors.As(err, &mismatchErr):
+		return "checksum"
+	case errors.As(err, &downgradeErr):
+		return "downgrade"
+	case errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return "proxy"
+	case errors.As(err, &dnsErr) && !dnsErr.IsTimeout:
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case isTLSError(err):
+		return "tls"
+	case errors.Is(err, syscall.ENOSPC), errors.As(err, &diskErr):
+		return "disk"
+	case body != nil && body.err != nil:
+		return "read"
+	case body != nil:
+		return "write"
+	case errors.As(err, &netErr):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+// isTLSError returns whether err is an error of the TLS handshake, e.g., an
+// untrusted certificate.
+func isTLSError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verificationErr) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// logAttempt logs the attempt number n at updating editionID in verbose
+// mode.
+func (u *Updater) logAttempt(editionID string, n int, a state.Attempt, err error) {
+	if !u.config.Verbose {
+		return
+	}
+	if err == nil {
+		u.logf(
+			"Attempt %d for %s succeeded in %s, %d bytes received",
+			n, editionID, a.Duration, a.Bytes,
+		)
+		return
+	}
+	u.logf(
+		"Attempt %d for %s failed in %s, %d bytes received, status %d, reason %s: %s",
+		n, editionID, a.Duration, a.Bytes, a.StatusCode, a.Reason, err,
+	)
+}
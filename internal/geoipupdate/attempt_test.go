@@ -0,0 +1,97 @@
+package geoipupdate
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+func TestNewAttempt(t *testing.T) {
+	received := &readErrorRecorder{n: 512}
+	interrupted := &readErrorRecorder{n: 256, err: io.ErrUnexpectedEOF}
+
+	tests := []struct {
+		description string
+		body        *readErrorRecorder
+		err         error
+		expected    state.Attempt
+	}{
+		{
+			description: "success",
+			body:        received,
+			expected:    state.Attempt{StatusCode: http.StatusOK, Bytes: 512},
+		},
+		{
+			description: "HTTP error",
+			err: fmt.Errorf(
+				"unexpected HTTP status code: %w",
+				internal.HTTPError{StatusCode: http.StatusBadGateway},
+			),
+			expected: state.Attempt{StatusCode: http.StatusBadGateway, Reason: "http_502"},
+		},
+		{
+			description: "proxy",
+			err:         &net.OpError{Op: "proxyconnect", Err: errors.New("refused")},
+			expected:    state.Attempt{Reason: "proxy"},
+		},
+		{
+			description: "timeout",
+			err:         fmt.Errorf("performing request: %w", context.DeadlineExceeded),
+			expected:    state.Attempt{Reason: "timeout"},
+		},
+		{
+			description: "DNS",
+			err:         &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host"}},
+			expected:    state.Attempt{Reason: "dns"},
+		},
+		{
+			description: "TLS",
+			err:         &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}},
+			expected:    state.Attempt{Reason: "tls"},
+		},
+		{
+			description: "disk",
+			body:        received,
+			err:         &os.PathError{Op: "write", Err: syscall.ENOSPC},
+			expected:    state.Attempt{StatusCode: http.StatusOK, Bytes: 512, Reason: "disk"},
+		},
+		{
+			description: "network",
+			err:         &net.OpError{Op: "dial", Err: errors.New("refused")},
+			expected:    state.Attempt{Reason: "network"},
+		},
+		{
+			description: "read",
+			body:        interrupted,
+			err:         io.ErrUnexpectedEOF,
+			expected:    state.Attempt{StatusCode: http.StatusOK, Bytes: 256, Reason: "read"},
+		},
+		{
+			description: "write",
+			body:        received,
+			err:         errors.New("no space left on device"),
+			expected:    state.Attempt{StatusCode: http.StatusOK, Bytes: 512, Reason: "write"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			test.expected.Duration = time.Second
+			require.Equal(t, test.expected, newAttempt(time.Second, test.body, test.err))
+		})
+	}
+}
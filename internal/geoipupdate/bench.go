@@ -0,0 +1,100 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// BenchResult is the result of benchmarking the downloads of an edition.
+type BenchResult struct {
+	EditionID string `json:"edition_id"`
+	Downloads int    `json:"downloads"`
+	// Bytes is the size of the database, as received by the last
+	// download.
+	Bytes int64 `json:"bytes"`
+	// Latency is the time until the response of the download is received,
+	// including the requests preceding it, e.g., for the metadata.
+	Latency BenchPercentiles `json:"latency"`
+	// Duration is the time until the database is fully received.
+	Duration BenchPercentiles `json:"duration"`
+	// Throughput is the mean number of bytes received per second.
+	Throughput float64 `json:"throughput_bytes_per_second"`
+}
+
+// BenchPercentiles are percentiles of the durations of the downloads.
+type BenchPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Bench downloads editionID n times, with the HTTP client, proxy and host
+// of the configuration, and measures the downloads, e.g., to compare proxy
+// configurations. The databases are always downloaded in full and are
+// discarded, leaving the installed ones untouched.
+func (u *Updater) Bench(ctx context.Context, editionID string, n int) (*BenchResult, error) {
+	if n <= 0 {
+		return nil, errors.New("the number of downloads must be positive")
+	}
+
+	result := &BenchResult{EditionID: editionID, Downloads: n}
+	latencies := make([]time.Duration, 0, n)
+	durations := make([]time.Duration, 0, n)
+	var total time.Duration
+	var received int64
+	for i := 1; i <= n; i++ {
+		start := time.Now()
+		// Without a hash, the database is downloaded even if it is
+		// installed.
+		res, err := u.updateClient.Download(ctx, editionID, "")
+		if err != nil {
+			return nil, fmt.Errorf("download %d of %s: %w", i, editionID, err)
+		}
+		latency := time.Since(start)
+		size, err := io.Copy(io.Discard, res.Reader)
+		res.Reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("download %d of %s: reading database: %w", i, editionID, err)
+		}
+		duration := time.Since(start)
+
+		if u.config.Verbose {
+			u.logf("Download %d of %s: %d bytes in %s", i, editionID, size, duration)
+		}
+		latencies = append(latencies, latency)
+		durations = append(durations, duration)
+		total += duration
+		received += size
+		result.Bytes = size
+	}
+
+	result.Latency = newBenchPercentiles(latencies)
+	result.Duration = newBenchPercentiles(durations)
+	if total > 0 {
+		result.Throughput = float64(received) / total.Seconds()
+	}
+	return result, nil
+}
+
+// newBenchPercentiles returns the percentiles of durations, using the
+// nearest-rank method.
+func newBenchPercentiles(durations []time.Duration) BenchPercentiles {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+	return BenchPercentiles{
+		P50: rank(0.5),
+		P90: rank(0.9),
+		P99: rank(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
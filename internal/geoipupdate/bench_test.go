@@ -0,0 +1,66 @@
+package geoipupdate
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+)
+
+// benchClient returns the same database for every download.
+type benchClient struct {
+	content   string
+	downloads int
+	hashes    []string
+}
+
+func (c *benchClient) Download(_ context.Context, _, hash string) (client.DownloadResponse, error) {
+	c.downloads++
+	c.hashes = append(c.hashes, hash)
+	return client.DownloadResponse{
+		Reader:          io.NopCloser(strings.NewReader(c.content)),
+		UpdateAvailable: true,
+	}, nil
+}
+
+func TestUpdaterBench(t *testing.T) {
+	bc := &benchClient{content: "GeoLite2-City content"}
+	u := &Updater{config: &Config{}, updateClient: bc}
+
+	res, err := u.Bench(context.Background(), "GeoLite2-City", 3)
+	require.NoError(t, err)
+	require.Equal(t, "GeoLite2-City", res.EditionID)
+	require.Equal(t, 3, res.Downloads)
+	require.Equal(t, int64(len(bc.content)), res.Bytes)
+	require.Equal(t, []string{"", "", ""}, bc.hashes)
+	require.LessOrEqual(t, res.Latency.P50, res.Duration.P50)
+	require.LessOrEqual(t, res.Duration.P50, res.Duration.Max)
+
+	_, err = u.Bench(context.Background(), "GeoLite2-City", 0)
+	require.EqualError(t, err, "the number of downloads must be positive")
+}
+
+func TestNewBenchPercentiles(t *testing.T) {
+	durations := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, BenchPercentiles{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}, newBenchPercentiles(durations))
+
+	require.Equal(t, BenchPercentiles{
+		P50: time.Second,
+		P90: time.Second,
+		P99: time.Second,
+		Max: time.Second,
+	}, newBenchPercentiles([]time.Duration{time.Second}))
+}
@@ -0,0 +1,258 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// activeLink is the name of the symbolic link of the DatabaseDirectory to the
+// active directory of DatabaseDirectories.
+const activeLink = "active"
+
+// parseDatabaseDirectories parses the value of the setting name, a
+// space-separated pair of directories.
+func parseDatabaseDirectories(name, value string) ([]string, error) {
+	// Replacing a symbolic link to a directory isn't atomic on Windows.
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("`%s' is not supported on Windows", name)
+	}
+	dirs := strings.Fields(value)
+	if len(dirs) != 2 {
+		return nil, fmt.Errorf("`%s' must be two directories, got '%s'", name, value)
+	}
+	for i, dir := range dirs {
+		dirs[i] = filepath.Clean(dir)
+	}
+	if dirs[0] == dirs[1] {
+		return nil, fmt.Errorf("`%s' must be two different directories, got '%s'", name, value)
+	}
+	return dirs, nil
+}
+
+// activeDirectory returns the directory consumers read the databases from:
+// the active symbolic link if DatabaseDirectories is set, and otherwise the
+// DatabaseDirectory.
+func (c *Config) activeDirectory() string {
+	if len(c.DatabaseDirectories) == 0 {
+		return c.DatabaseDirectory
+	}
+	return filepath.Join(c.DatabaseDirectory, activeLink)
+}
+
+// blueGreen updates the databases alternately in the two directories of
+// DatabaseDirectories, so that consumers that read a directory once, e.g.,
+// at startup, always find a consistent set of editions from the same run.
+// Each run first copies the databases of the active directory that the
+// inactive one doesn't have, then updates the inactive one. Once the run
+// succeeds, and every database in it is valid, the active symbolic link is
+// flipped to it with a rename, which is atomic.
+type blueGreen struct {
+	link    string
+	dirs    []string
+	writers map[string]database.Writer
+	verbose bool
+	// active is the directory the link points to, if any.
+	active string
+	// dir is the directory of the current run, or the active one between
+	// runs.
+	dir string
+}
+
+// newBlueGreen returns the blueGreen of config, writing to each directory
+// with the writer returned by newWriter.
+func newBlueGreen(
+	config *Config,
+	newWriter func(dir string) (database.Writer, error),
+) (*blueGreen, error) {
+	b := &blueGreen{
+		link:    config.activeDirectory(),
+		dirs:    config.DatabaseDirectories,
+		writers: map[string]database.Writer{},
+		verbose: config.Verbose,
+	}
+	for _, dir := range b.dirs {
+		writer, err := newWriter(dir)
+		if err != nil {
+			return nil, err
+		}
+		b.writers[dir] = writer
+	}
+	active, err := b.readActive()
+	if err != nil {
+		return nil, err
+	}
+	b.active = active
+	b.end()
+	return b, nil
+}
+
+// readActive returns the directory the link points to, or an empty string
+// if there is no link yet.
+func (b *blueGreen) readActive() (string, error) {
+	target, err := os.Readlink(b.link)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading active database directory: %w", err)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(b.link), target)
+	}
+	target = filepath.Clean(target)
+	for _, dir := range b.dirs {
+		if dir == target {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%s points to %s, which isn't one of the `DatabaseDirectories'", b.link, target)
+}
+
+func (b *blueGreen) current() string {
+	return b.dir
+}
+
+func (b *blueGreen) writer() database.Writer {
+	return b.writers[b.dir]
+}
+
+// begin makes the inactive directory that of the run, after copying the
+// databases of editionIDs in the active directory to it.
+func (b *blueGreen) begin(ctx context.Context, editionIDs []string) error {
+	active, err := b.readActive()
+	if err != nil {
+		return err
+	}
+	b.active = active
+	inactive := b.dirs[0]
+	if active == b.dirs[0] {
+		inactive = b.dirs[1]
+	}
+	if err := os.MkdirAll(inactive, 0o750); err != nil {
+		return fmt.Errorf("creating database directory: %w", err)
+	}
+	if active != "" {
+		for _, editionID := range editionIDs {
+			err := syncDatabase(
+				ctx,
+				database.FilePath(active, editionID),
+				database.FilePath(inactive, editionID),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	b.dir = inactive
+	if b.verbose {
+		log.Printf("Updating the databases in %s", inactive)
+	}
+	return nil
+}
+
+// end makes the active directory the current one again after a run.
+func (b *blueGreen) end() {
+	b.dir = b.active
+	if b.dir == "" {
+		b.dir = b.dirs[0]
+	}
+}
+
+// publish flips the link to the directory of the run, unless none of
+// editions changed, once every database in it is valid.
+func (b *blueGreen) publish(_ context.Context, editions []database.ReadResult) error {
+	changed := b.active == ""
+	for _, edition := range editions {
+		changed = changed || edition.NewHash != edition.OldHash || edition.Unavailable
+	}
+	if !changed {
+		return nil
+	}
+
+	for _, edition := range editions {
+		path := database.FilePath(b.dir, edition.EditionID)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := database.Validate(path); err != nil {
+			return fmt.Errorf("validating %s before activating %s: %w", path, b.dir, err)
+		}
+	}
+
+	tmp := b.link + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", tmp, err)
+	}
+	if err := os.Symlink(b.dir, tmp); err != nil {
+		return fmt.Errorf("linking %s: %w", b.dir, err)
+	}
+	if err := os.Rename(tmp, b.link); err != nil {
+		return fmt.Errorf("activating %s: %w", b.dir, err)
+	}
+	b.active = b.dir
+	if b.verbose {
+		log.Printf("Activated the databases in %s", b.dir)
+	}
+	return nil
+}
+
+// syncDatabase makes the database at dst a copy of the one at src, with the
+// same modification time, unless it already has its size and modification
+// time. It is removed if there is no database at src.
+func syncDatabase(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	srcInfo, err := os.Stat(src)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing %s: %w", dst, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", src, err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err == nil && dstInfo.Size() == srcInfo.Size() && dstInfo.ModTime().Equal(srcInfo.ModTime()) {
+		return nil
+	}
+
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", src, err)
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*")
+	if err != nil {
+		return fmt.Errorf("creating copy of %s: %w", src, err)
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("syncing copy of %s: %w", src, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("closing copy of %s: %w", src, err)
+	}
+	if err := os.Chtimes(out.Name(), srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+		return fmt.Errorf("setting times of copy of %s: %w", src, err)
+	}
+	if err := os.Rename(out.Name(), dst); err != nil {
+		return fmt.Errorf("moving copy of %s into place: %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package geoipupdate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestParseDatabaseDirectories(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		_, err := parseDatabaseDirectories("DatabaseDirectories", "/tmp/blue /tmp/green")
+		require.EqualError(t, err, "`DatabaseDirectories' is not supported on Windows")
+		return
+	}
+
+	dirs, err := parseDatabaseDirectories("DatabaseDirectories", "/tmp/blue/ /tmp/green")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/blue", "/tmp/green"}, dirs)
+
+	_, err = parseDatabaseDirectories("DatabaseDirectories", "/tmp/blue")
+	require.EqualError(t, err, "`DatabaseDirectories' must be two directories, got '/tmp/blue'")
+
+	_, err = parseDatabaseDirectories("GEOIPUPDATE_DB_DIRS", "/tmp/blue /tmp/blue/")
+	require.EqualError(t, err, "`GEOIPUPDATE_DB_DIRS' must be two different directories, got '/tmp/blue /tmp/blue/'")
+}
+
+func TestBlueGreen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("DatabaseDirectories is not supported on Windows")
+	}
+
+	tempDir := t.TempDir()
+	blue := filepath.Join(tempDir, "blue")
+	green := filepath.Join(tempDir, "green")
+	config := &Config{
+		DatabaseDirectory:   tempDir,
+		DatabaseDirectories: []string{blue, green},
+	}
+	b, err := newBlueGreen(config, func(string) (database.Writer, error) { return nil, nil })
+	require.NoError(t, err)
+	assert.Equal(t, blue, b.current())
+
+	// The first run updates the first directory, and activates it.
+	editionIDs := []string{"GeoLite2-City", "GeoLite2-ASN"}
+	require.NoError(t, b.begin(context.Background(), editionIDs))
+	assert.Equal(t, blue, b.current())
+	writeEmptyMMDB(t, database.FilePath(blue, "GeoLite2-City"))
+	writeEmptyMMDB(t, database.FilePath(blue, "GeoLite2-ASN"))
+	require.NoError(t, b.publish(context.Background(), []database.ReadResult{
+		{EditionID: "GeoLite2-City", NewHash: "city"},
+		{EditionID: "GeoLite2-ASN", NewHash: "asn"},
+	}))
+	b.end()
+	assertActive(t, config, blue)
+
+	// The next run updates the other directory, copied from the active
+	// one, which stays active unless something changed.
+	require.NoError(t, b.begin(context.Background(), editionIDs))
+	assert.Equal(t, green, b.current())
+	for _, editionID := range editionIDs {
+		want, err := os.Stat(database.FilePath(blue, editionID))
+		require.NoError(t, err)
+		got, err := os.Stat(database.FilePath(green, editionID))
+		require.NoError(t, err)
+		assert.Equal(t, want.Size(), got.Size())
+		assert.Equal(t, want.ModTime(), got.ModTime())
+	}
+	require.NoError(t, b.publish(context.Background(), []database.ReadResult{
+		{EditionID: "GeoLite2-City", OldHash: "city", NewHash: "city"},
+		{EditionID: "GeoLite2-ASN", OldHash: "asn", NewHash: "asn"},
+	}))
+	b.end()
+	assertActive(t, config, blue)
+
+	// A set with an invalid database isn't activated.
+	require.NoError(t, b.begin(context.Background(), editionIDs))
+	require.NoError(t, os.WriteFile(database.FilePath(green, "GeoLite2-ASN"), []byte("corrupt"), 0o600))
+	err = b.publish(context.Background(), []database.ReadResult{
+		{EditionID: "GeoLite2-City", OldHash: "city", NewHash: "city"},
+		{EditionID: "GeoLite2-ASN", OldHash: "asn", NewHash: "new-asn"},
+	})
+	require.ErrorContains(t, err, "validating "+database.FilePath(green, "GeoLite2-ASN"))
+	b.end()
+	assertActive(t, config, blue)
+
+	// The next run reverts the invalid database, and a valid set is
+	// activated.
+	require.NoError(t, b.begin(context.Background(), editionIDs))
+	require.NoError(t, database.Validate(database.FilePath(green, "GeoLite2-ASN")))
+	require.NoError(t, b.publish(context.Background(), []database.ReadResult{
+		{EditionID: "GeoLite2-City", OldHash: "city", NewHash: "city"},
+		{EditionID: "GeoLite2-ASN", OldHash: "asn", NewHash: "new-asn"},
+	}))
+	b.end()
+	assertActive(t, config, green)
+	assert.Equal(t, green, b.current())
+}
+
+// assertActive asserts that the active link of config points to dir.
+func assertActive(t *testing.T, config *Config, dir string) {
+	target, err := os.Readlink(config.activeDirectory())
+	require.NoError(t, err)
+	assert.Equal(t, dir, target)
+}
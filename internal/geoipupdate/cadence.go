@@ -0,0 +1,138 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// cadenceGrace is how late a build may be published before it is
+	// reported as missing.
+	cadenceGrace = 48 * time.Hour
+	// cadenceBuildLead is how long before its publication a build may be
+	// dated.
+	cadenceBuildLead = 24 * time.Hour
+)
+
+// weekdayNames are the abbreviations of the weekdays in cadences, indexed
+// by time.Weekday.
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Cadence is the expected publication schedule of an edition: either every
+// given interval, or on given days of the week.
+type Cadence struct {
+	// Every is the interval between two builds, e.g., 24 hours for daily
+	// builds. It is 0 if Weekdays is set.
+	Every time.Duration
+	// Weekdays are the days of the week builds are published on, in UTC.
+	Weekdays []time.Weekday
+}
+
+// parseCadence parses a cadence: daily, weekly, or a comma-separated list
+// of weekdays, e.g., tue,fri.
+func parseCadence(value string) (Cadence, error) {
+	switch value {
+	case "daily":
+		return Cadence{Every: 24 * time.Hour}, nil
+	case "weekly":
+		return Cadence{Every: 7 * 24 * time.Hour}, nil
+	}
+	var c Cadence
+	for _, name := range strings.Split(value, ",") {
+		day := slices.Index(weekdayNames, strings.ToLower(name))
+		if day < 0 {
+			return Cadence{}, fmt.Errorf("invalid cadence '%s': expected daily, weekly, or weekdays such as tue,fri", value)
+		}
+		if !slices.Contains(c.Weekdays, time.Weekday(day)) {
+			c.Weekdays = append(c.Weekdays, time.Weekday(day))
+		}
+	}
+	slices.Sort(c.Weekdays)
+	return c, nil
+}
+
+// String returns the cadence as parsed by parseCadence.
+func (c Cadence) String() string {
+	switch c.Every {
+	case 0:
+	case 24 * time.Hour:
+		return "daily"
+	case 7 * 24 * time.Hour:
+		return "weekly"
+	default:
+		return c.Every.String()
+	}
+	names := make([]string, len(c.Weekdays))
+	for i, day := range c.Weekdays {
+		names[i] = weekdayNames[day]
+	}
+	return strings.Join(names, ",")
+}
+
+// nextBuild returns when the build following the one dated buildDate is
+// expected to be published.
+func (c Cadence) nextBuild(buildDate time.Time) time.Time {
+	if c.Every > 0 {
+		return buildDate.Add(c.Every)
+	}
+	// The build may be dated the day before its publication, which must not
+	// be mistaken for the next one.
+	published := buildDate.Add(cadenceBuildLead).In(time.UTC)
+	day := time.Date(published.Year(), published.Month(), published.Day(), 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 7; i++ {
+		next := day.AddDate(0, 0, i)
+		if slices.Contains(c.Weekdays, next.Weekday()) {
+			return next
+		}
+	}
+	return day.AddDate(0, 0, 7)
+}
+
+// parseExpectedCadence parses the value of the setting name, a
+// space-separated list of EditionID=cadence pairs.
+func parseExpectedCadence(name, value string) (map[string]Cadence, error) {
+	cadences := map[string]Cadence{}
+	for _, entry := range strings.Fields(value) {
+		editionID, cadence, ok := strings.Cut(entry, "=")
+		if !ok || editionID == "" {
+			return nil, fmt.Errorf("`%s' must be a list of EditionID=cadence pairs, got '%s'", name, entry)
+		}
+		c, err := parseCadence(cadence)
+		if err != nil {
+			return nil, fmt.Errorf("`%s': %w", name, err)
+		}
+		cadences[editionID] = c
+	}
+	return cadences, nil
+}
+
+// warnCadence raises a WarningCadenceAnomaly if no build of editionID
+// followed the one dated buildDate as of now, although one was expected
+// by its cadence. It returns false if editionID has no expected cadence.
+func (u *Updater) warnCadence(editionID string, buildDate, now time.Time) bool {
+	c, ok := u.config.ExpectedCadence[editionID]
+	if !ok {
+		return false
+	}
+	if buildDate.IsZero() {
+		return true
+	}
+	next := c.nextBuild(buildDate)
+	if now.Sub(next) <= cadenceGrace {
+		return true
+	}
+	u.warn(Warning{
+		Code:      WarningCadenceAnomaly,
+		EditionID: editionID,
+		Message: fmt.Sprintf(
+			"no new build of %s in %d days; with a %s cadence, one was expected by %s",
+			editionID,
+			int(now.Sub(buildDate)/(24*time.Hour)),
+			c,
+			next.In(time.UTC).Format(time.DateOnly),
+		),
+	})
+	return true
+}
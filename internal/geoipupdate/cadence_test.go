@@ -0,0 +1,115 @@
+package geoipupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCadence(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected Cadence
+		Err      string
+	}{
+		{Input: "daily", Expected: Cadence{Every: 24 * time.Hour}},
+		{Input: "weekly", Expected: Cadence{Every: 7 * 24 * time.Hour}},
+		{Input: "tue", Expected: Cadence{Weekdays: []time.Weekday{time.Tuesday}}},
+		{
+			Input:    "fri,TUE,fri",
+			Expected: Cadence{Weekdays: []time.Weekday{time.Tuesday, time.Friday}},
+		},
+		{
+			Input: "tue,",
+			Err:   "invalid cadence 'tue,': expected daily, weekly, or weekdays such as tue,fri",
+		},
+		{
+			Input: "monthly",
+			Err:   "invalid cadence 'monthly': expected daily, weekly, or weekdays such as tue,fri",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			c, err := parseCadence(test.Input)
+			if test.Err != "" {
+				require.EqualError(t, err, test.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, c)
+		})
+	}
+}
+
+func TestCadenceNextBuild(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	tests := []struct {
+		Description string
+		Cadence     string
+		BuildDate   time.Time
+		Expected    time.Time
+	}{
+		{
+			Description: "daily",
+			Cadence:     "daily",
+			BuildDate:   time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC),
+			Expected:    time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			Description: "build dated on its publication day",
+			Cadence:     "tue",
+			BuildDate:   time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+			Expected:    time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Description: "build dated the day before its publication",
+			Cadence:     "tue",
+			BuildDate:   time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC),
+			Expected:    time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Description: "several days a week",
+			Cadence:     "tue,fri",
+			BuildDate:   time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+			Expected:    time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			c, err := parseCadence(test.Cadence)
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, c.nextBuild(test.BuildDate))
+		})
+	}
+}
+
+func TestUpdaterWarnCadence(t *testing.T) {
+	u := &Updater{
+		config: &Config{
+			ExpectedCadence: map[string]Cadence{
+				"GeoIP2-City": {Weekdays: []time.Weekday{time.Tuesday}},
+			},
+		},
+		warnings: &warningList{},
+	}
+	buildDate := time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC)
+
+	// The next build is expected on 2024-01-09 and may be up to
+	// cadenceGrace late.
+	assert.True(t, u.warnCadence("GeoIP2-City", buildDate, time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)))
+	assert.Empty(t, u.Warnings())
+
+	assert.True(t, u.warnCadence("GeoIP2-City", buildDate, time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []Warning{{
+		Code:      WarningCadenceAnomaly,
+		EditionID: "GeoIP2-City",
+		Message:   "no new build of GeoIP2-City in 15 days; with a tue cadence, one was expected by 2024-01-09",
+	}}, u.Warnings())
+
+	// Editions without a cadence are left to warnStaleEdition.
+	assert.False(t, u.warnCadence("GeoIP2-Country", buildDate, time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,189 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+)
+
+// WarningOutdatedEdition is raised by check-only runs when the installed
+// database of an edition is not its latest build.
+const WarningOutdatedEdition = "outdated-edition"
+
+// Check tells whether the installed database of each configured edition is
+// its latest build, without downloading or writing any database, e.g., on
+// hosts where the databases are deployed by other means. The outcome is
+// recorded in the StateFile, and so in the MetricsFile, and the editions
+// that became outdated, and those that are current again, are announced to
+// the Notify targets. The results have OldHash and NewHash set to the MD5
+// sum of the installed database, and Outdated set if it isn't the latest
+// build.
+func (u *Updater) Check(ctx context.Context) ([]database.ReadResult, error) {
+	bc, ok := u.updateClient.(buildClient)
+	if !ok {
+		return nil, errors.New("checks are not supported with `S3Mirror' or `OCIMirror'")
+	}
+
+	runID := runIDFrom(ctx)
+	u.warnings = &warningList{}
+	store, err := state.Open(u.config.StateFile)
+	if err != nil {
+		// A broken state file must not prevent checks.
+		u.logf("Ignoring state file: %s", err)
+		store = state.New(u.config.StateFile)
+	}
+	if u.hashCache != nil {
+		u.hashCache.set(store)
+		defer u.hashCache.set(nil)
+	}
+
+	u.editionIDs, err = u.resolveEditionIDs(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := u.updateClient.(metadataBatcher); ok {
+		b.BatchMetadata(u.editionIDs)
+	}
+
+	var editions []database.ReadResult
+	var errs error
+	for _, editionID := range u.editionIDs {
+		edition, err := u.checkEdition(ctx, bc, store, editionID)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		editions = append(editions, *edition)
+	}
+
+	if u.config.MetricsFile != "" {
+		err := store.WriteMetrics(
+			u.config.MetricsFile,
+			u.editionIDs,
+			u.metricsLabels(),
+			configHash(u.config),
+		)
+		if err != nil {
+			u.logf("%s", err)
+		}
+	}
+
+	if err := u.announceChecks(ctx, store, runID); err != nil {
+		u.logf("%s", err)
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	return editions, nil
+}
+
+// checkEdition compares the installed database of editionID with its latest
+// build and records the outcome in store.
+func (u *Updater) checkEdition(
+	ctx context.Context,
+	bc buildClient,
+	store *state.Store,
+	editionID string,
+) (*database.ReadResult, error) {
+	hash, err := u.writer.GetHash(ctx, editionID)
+	if err != nil {
+		return nil, fmt.Errorf("getting current hash of %s: %w", editionID, err)
+	}
+	build, err := bc.LatestBuild(ctx, editionID)
+	if err != nil {
+		return nil, fmt.Errorf("checking the latest build of %s: %w", editionID, err)
+	}
+
+	checkedAt := u.config.getClock().Now().In(time.UTC)
+	outdated := build.MD5 != hash
+	err = store.Update(editionID, func(e *state.Edition) {
+		e.LastCheck = checkedAt
+		e.LatestHash = build.MD5
+		e.Outdated = outdated
+	})
+	if err != nil {
+		return nil, fmt.Errorf("updating state of %s: %w", editionID, err)
+	}
+
+	if outdated {
+		u.warn(Warning{
+			Code:      WarningOutdatedEdition,
+			EditionID: editionID,
+			Message: fmt.Sprintf(
+				"the installed database of %s is not its latest build, published on %s",
+				editionID,
+				build.Date,
+			),
+		})
+	} else if u.config.Verbose {
+		u.logf("Database %s is the latest build", editionID)
+	}
+
+	return &database.ReadResult{
+		EditionID: editionID,
+		OldHash:   hash,
+		NewHash:   hash,
+		CheckedAt: checkedAt,
+		Outdated:  outdated,
+	}, nil
+}
+
+// announceChecks announces to the Notify targets the editions that became
+// outdated, and those that are current again after such an announcement.
+// Like alerts, announcements that fail are made again by the next runs.
+func (u *Updater) announceChecks(ctx context.Context, store *state.Store, runID string) error {
+	if len(u.notifiers) == 0 {
+		return nil
+	}
+
+	var errs error
+	for _, editionID := range u.editionIDs {
+		s := store.Edition(editionID)
+		if s.LastCheck.IsZero() || s.Outdated == s.OutdatedAnnounced {
+			continue
+		}
+
+		event := notify.EventCurrent
+		if s.Outdated {
+			event = notify.EventOutdated
+		}
+		a := notify.Announcement{
+			Event:     event,
+			RunID:     runID,
+			UpdateID:  updateID(editionID, event+":"+s.LatestHash),
+			EditionID: editionID,
+			MD5:       s.LatestHash,
+			Date:      s.LastCheck,
+			Labels:    u.config.Labels,
+		}
+
+		failed := false
+		for i, notifier := range u.notifiers {
+			if err := notifier.Notify(ctx, a); err != nil {
+				failed = true
+				errs = errors.Join(errs, fmt.Errorf(
+					"announcing the check of %s to %s: %w",
+					editionID,
+					notify.Redact(u.config.Notify[i]),
+					err,
+				))
+			}
+		}
+		if failed {
+			continue
+		}
+		err := store.Update(editionID, func(e *state.Edition) {
+			e.OutdatedAnnounced = s.Outdated
+		})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("updating state of %s: %w", editionID, err))
+		}
+	}
+	return errs
+}
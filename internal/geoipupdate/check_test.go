@@ -0,0 +1,83 @@
+package geoipupdate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+)
+
+// TestUpdaterCheck tests that check-only runs report whether the installed
+// databases are current without downloading them, and announce the
+// editions becoming outdated and current again once.
+func TestUpdaterCheck(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := &Config{
+		EditionIDs:  []string{"GeoLite2-ASN", "GeoLite2-City"},
+		LockFile:    filepath.Join(tempDir, ".geoipupdate.lock"),
+		MetricsFile: filepath.Join(tempDir, "geoipupdate.prom"),
+		Notify:      []string{"https://hooks.example.com"},
+		Parallelism: 1,
+		StateFile:   filepath.Join(tempDir, ".geoipupdate.state"),
+	}
+	bc := &mockBuildClient{builds: map[string]client.Build{
+		"GeoLite2-ASN":  {Date: "2024-02-23", MD5: "A"},
+		"GeoLite2-City": {Date: "2024-02-23", MD5: "C"},
+	}}
+	writer := &mockWriter{md5s: map[string]string{"GeoLite2-ASN": "A", "GeoLite2-City": "B"}}
+	notifier := &mockNotifier{}
+	check := func() *Updater {
+		u := &Updater{
+			config:       config,
+			notifiers:    []notify.Notifier{notifier},
+			updateClient: bc,
+			writer:       writer,
+		}
+		editions, err := u.Check(context.Background())
+		require.NoError(t, err)
+		require.Len(t, editions, 2)
+		assert.False(t, editions[0].Outdated)
+		assert.Equal(t, editions[1].Outdated, writer.md5s["GeoLite2-City"] != "C")
+		for _, edition := range editions {
+			assert.Equal(t, edition.OldHash, edition.NewHash)
+		}
+		return u
+	}
+
+	u := check()
+	require.Empty(t, bc.downloads)
+	warnings := u.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarningOutdatedEdition, warnings[0].Code)
+	assert.Equal(t, "GeoLite2-City", warnings[0].EditionID)
+
+	require.Len(t, notifier.announcements, 1)
+	outdated := notifier.announcements[0]
+	assert.Equal(t, notify.EventOutdated, outdated.Event)
+	assert.Equal(t, "GeoLite2-City", outdated.EditionID)
+	assert.Equal(t, "C", outdated.MD5)
+
+	metrics, err := os.ReadFile(config.MetricsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(metrics), `geoipupdate_edition_outdated{edition_id="GeoLite2-ASN"} 0`)
+	assert.Contains(t, string(metrics), `geoipupdate_edition_outdated{edition_id="GeoLite2-City"} 1`)
+
+	// The outdated edition is announced once.
+	check()
+	require.Len(t, notifier.announcements, 1)
+
+	// The database was deployed by other means.
+	writer.md5s["GeoLite2-City"] = "C"
+	u = check()
+	assert.Empty(t, u.Warnings())
+	require.Len(t, notifier.announcements, 2)
+	assert.Equal(t, notify.EventCurrent, notifier.announcements[1].Event)
+	assert.NotEqual(t, outdated.UpdateID, notifier.announcements[1].UpdateID)
+}
@@ -0,0 +1,181 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// Clock is the source of the time of updates: retries wait with it,
+// freshness checks, e.g., of CacheMaxAge, compare its time with that of
+// the state, and daemons schedule their runs with it. It is set with
+// WithClock, e.g., to a SimulatedClock in tests. Durations measured for
+// reporting, e.g., of attempts, always use the system clock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer returns a Timer firing once d has elapsed.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer of a Clock.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if it already
+	// fired or was stopped.
+	Stop() bool
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// systemTimer is the Timer of the systemClock.
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// SimulatedClock is a Clock whose time only passes when it is waited for or
+// advanced, making tests of retries and schedules deterministic and free of
+// real sleeps. Each timer moves the time forward to when it fires, and
+// fires right away, so that, e.g., a RetryFor of 10 minutes is spent in a
+// few exponential backoffs without waiting for them. As each wait adds to
+// the time, runs waiting concurrently, e.g., with a Parallelism above 1,
+// see it advance by the sum of their waits.
+type SimulatedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewSimulatedClock returns a SimulatedClock starting at start.
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start}
+}
+
+// Now returns the simulated time.
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the simulated time forward by d, e.g., to age the state
+// between runs.
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// NewTimer advances the simulated time by d, and returns a timer that
+// already fired.
+func (c *SimulatedClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return &simulatedTimer{c: ch}
+}
+
+// simulatedTimer is the Timer of a SimulatedClock, which fires as soon as
+// it is created.
+type simulatedTimer struct {
+	c chan time.Time
+}
+
+func (t *simulatedTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *simulatedTimer) Stop() bool {
+	return false
+}
+
+// backoffTimer makes a Clock the backoff.Timer of retries.
+type backoffTimer struct {
+	clock Clock
+	timer Timer
+}
+
+var _ backoff.Timer = (*backoffTimer)(nil)
+
+func (t *backoffTimer) Start(d time.Duration) {
+	t.timer = t.clock.NewTimer(d)
+}
+
+func (t *backoffTimer) Stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+func (t *backoffTimer) C() <-chan time.Time {
+	return t.timer.C()
+}
+
+// getClock returns the Clock set with WithClock, or else that of the
+// system.
+func (c *Config) getClock() Clock {
+	if c.clock == nil {
+		return systemClock{}
+	}
+	return c.clock
+}
+
+// maxClockSkew is the difference between the clock of the MaxMind servers
+// and the local clock beyond which the local clock is considered wrong.
+const maxClockSkew = 5 * time.Minute
+
+// serverNow returns now, the time of the local clock, corrected by skew, the
+// difference between the clock of the server and the local clock, if the
+// local clock is wrong. Freshness decisions use it so that a wrong local
+// clock doesn't make databases look fresher than they are.
+func serverNow(now time.Time, skew time.Duration) time.Time {
+	if clockSkewed(skew) {
+		now = now.Add(skew)
+	}
+	return now.In(time.UTC)
+}
+
+// clockSkewed returns whether skew exceeds maxClockSkew.
+func clockSkewed(skew time.Duration) bool {
+	return skew > maxClockSkew || skew < -maxClockSkew
+}
+
+// warnClockSkew raises a WarningClockSkew if skew, measured while updating
+// editionID, exceeds maxClockSkew.
+func (u *Updater) warnClockSkew(editionID string, skew time.Duration) {
+	if !clockSkewed(skew) {
+		return
+	}
+	direction := "behind"
+	if skew < 0 {
+		direction = "ahead of"
+		skew = -skew
+	}
+	u.warn(Warning{
+		Code:      WarningClockSkew,
+		EditionID: editionID,
+		Message: fmt.Sprintf(
+			"the local clock is %s %s the server while updating %s; using the server time",
+			skew.Round(time.Second), direction, editionID,
+		),
+	})
+}
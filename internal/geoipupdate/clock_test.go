@@ -0,0 +1,62 @@
+package geoipupdate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+func TestSimulatedClock(t *testing.T) {
+	start := time.Date(2024, 4, 8, 12, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	require.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Hour)
+	require.Equal(t, start.Add(time.Hour), clock.Now())
+
+	// Timers fire right away, at the time they are due.
+	timer := clock.NewTimer(time.Minute)
+	select {
+	case fired := <-timer.C():
+		assert.Equal(t, start.Add(time.Hour+time.Minute), fired)
+	default:
+		t.Fatal("timer didn't fire")
+	}
+	assert.False(t, timer.Stop())
+	assert.Equal(t, start.Add(time.Hour+time.Minute), clock.Now())
+}
+
+// TestSimulatedRetryFor tests that the retries of RetryFor wait with the
+// Clock of the config, and so spend the whole budget without real sleeps.
+func TestSimulatedRetryFor(t *testing.T) {
+	start := time.Date(2024, 4, 8, 12, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	config := &Config{RetryFor: time.Hour}
+	require.NoError(t, WithClock(clock)(config))
+
+	u := &Updater{
+		config:       config,
+		updateClient: &mockUpdateClient{},
+		writer:       &mockWriter{},
+	}
+
+	var attempts []state.Attempt
+	began := time.Now()
+	_, err := u.downloadEdition(
+		context.Background(),
+		"GeoLite2-City",
+		u.updateClient,
+		u.writer,
+		func(a state.Attempt) { attempts = append(attempts, a) },
+	)
+	require.Error(t, err)
+	assert.Greater(t, len(attempts), 10)
+	// The last wait is truncated to what is left of the budget.
+	assert.Equal(t, start.Add(time.Hour), clock.Now())
+	assert.Less(t, time.Since(began), 10*time.Second)
+}
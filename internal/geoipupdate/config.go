@@ -2,16 +2,27 @@ package geoipupdate
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/blob"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/resolve"
 	"github.com/maxmind/geoipupdate/v7/internal/vars"
 )
 
@@ -21,42 +32,327 @@ const schemeHTTPS = "https"
 type Config struct {
 	// AccountID is the account ID.
 	AccountID int
+	// AllowDowngrade allows databases to be replaced with databases built
+	// before them. It is not a setting of the configuration file.
+	AllowDowngrade bool
+	// ArchiveDirectory is where databases are copied to before being
+	// replaced by a new version. Archiving is disabled if it is empty.
+	ArchiveDirectory string
+	// ArchiveS3 is the s3://bucket[/prefix] URL of an Amazon S3 bucket
+	// databases are uploaded to before being replaced, named as in
+	// ArchiveDirectory.
+	ArchiveS3 string
+	// ArchiveS3StorageClass is the storage class of the uploads to
+	// ArchiveS3. It defaults to GLACIER.
+	ArchiveS3StorageClass string
+	// CacheMaxAge is how long the installed database of an edition is used
+	// without contacting the API once it has been checked, so frequent runs
+	// don't each check for updates. Every run checks for updates if it is 0.
+	CacheMaxAge time.Duration
+	// CheckOnly makes the Daemon check whether the installed databases are
+	// the latest builds, with Updater.Check, rather than update them. It is
+	// not a setting of the configuration file.
+	CheckOnly bool
+	// ChecksumForensics keeps downloaded databases whose MD5 sum doesn't
+	// match, with a .bad suffix, and logs and reports how they were
+	// downloaded, so corrupted downloads can be reported with evidence.
+	ChecksumForensics bool
+	// ConsistentSet makes runs install the databases they update only once
+	// every edition succeeded. See stagedSet.
+	ConsistentSet bool
+	// ConsumerLockTimeout is how long to wait for consumers to release
+	// their shared lock on a database's sentinel file before replacing
+	// the database. The consumer lock protocol is disabled if it is 0.
+	ConsumerLockTimeout time.Duration
 	// confFile is the path to any configuration file used when
 	// potentially populating Config fields.
 	configFile string
 	// DatabaseDirectory is where database files are going to be
 	// stored.
 	DatabaseDirectory string
-	// EditionIDs are the database editions to be updated.
+	// DatabaseDirectories are the two directories the databases are
+	// alternately updated in, each run updating the inactive one before
+	// the active symbolic link of DatabaseDirectory is flipped to it. See
+	// blueGreen.
+	DatabaseDirectories []string
+	// Destination is the object storage the databases are also written
+	// to as they are installed, for fleets to fetch them from: a
+	// gs://bucket[/prefix] Google Cloud Storage URL, or an
+	// azblob://account/container[/prefix] or container URL of Azure Blob
+	// Storage. See destinationWriter.
+	Destination string
+	// DestinationEncoding is the encoding, e.g., zstd, of the databases
+	// written to Destination. By default, they are written as is.
+	DestinationEncoding string
+	// DisableSelfUpdate makes the self-update command refuse to replace
+	// the binary, e.g., when it is managed by a package manager.
+	DisableSelfUpdate bool
+	// EditionDependencies are the editions each edition is updated after,
+	// by edition ID, for consumers that rely on the relative freshness of
+	// editions, e.g., GeoIP2-City never being newer than GeoIP2-ISP.
+	EditionDependencies map[string][]string
+	// EditionGroups are named lists of edition IDs and patterns that
+	// EditionIDs can refer to by name.
+	EditionGroups map[string][]string
+	// EditionIDs are the database editions to be updated. Once the
+	// configuration is loaded, groups are replaced by their editions, and
+	// patterns are moved to EditionPatterns.
 	EditionIDs []string
+	// EditionOrder is the order in which editions are updated: config,
+	// the default, largest-first or smallest-first, by the sizes of their
+	// installed databases.
+	EditionOrder string
+	// EditionPatterns are the patterns of EditionIDs, e.g., GeoIP2-*,
+	// matched against the editions available at the start of each run.
+	EditionPatterns []string
+	// ExcludeEditionIDs are edition IDs and patterns that are not updated
+	// even though EditionIDs include them, e.g., to hold back an edition
+	// whose new build breaks a consumer without editing EditionIDs.
+	ExcludeEditionIDs []string
+	// EncryptionKeyFile is the path of the file holding the base64-encoded
+	// AES-256 key the databases pushed to OCIPush and S3Push are encrypted
+	// with, as the storage is shared, and the encrypted databases
+	// downloaded from OCIMirror and S3Mirror are decrypted with. Databases
+	// are not encrypted if it is empty.
+	EncryptionKeyFile string
+	// EncryptionKMS is the key of a key management service, e.g., an AWS
+	// KMS key ARN, wrapping a new data key for each database pushed to
+	// OCIPush or S3Push, rather than a static EncryptionKeyFile. See
+	// encryption.NewKeyWrapper.
+	EncryptionKMS string
+	// ExpectedCadence is when builds of editions are expected to be
+	// published, by edition ID. Runs warn when an edition has no build as
+	// recent as its cadence implies, e.g., because of an upstream or
+	// account problem.
+	ExpectedCadence map[string]Cadence
+	// FailFastThreshold is the number of editions that, when they are the
+	// first to finish and all fail with the same kind of error, e.g., an
+	// authentication or proxy error, cause the remaining editions to be
+	// skipped. It is disabled if it is 0.
+	FailFastThreshold int
+	// HostAuth is how requests to hosts other than the MaxMind servers,
+	// e.g., mirrors, are authenticated, by host name. Requests to other
+	// hosts use the account ID and license key.
+	HostAuth map[string]HostAuth
+	// IntegrityFile is the path of a manifest of the installed databases,
+	// with their size, modification time and SHA-256 sum in the mtree(5)
+	// format, written after each successful run for file integrity
+	// monitoring.
+	IntegrityFile string
+	// Labels are name and value pairs, e.g., env=prod, attached to the
+	// metrics, the announcements, and the report of a run so telemetry
+	// from many hosts can be aggregated and sliced.
+	Labels map[string]string
+	// LayerFile is the path of a tar archive of the installed databases
+	// written after each successful run, e.g., to be added to container
+	// images. The same databases always result in the same archive.
+	LayerFile string
 	// LicenseKey is the license attached to the account.
 	LicenseKey string
 	// LockFile is the path of a lock file that ensures that only one
 	// geoipupdate process can run at a time.
 	LockFile string
+	// LockType is how the LockFile is locked, one of the internal.LockType
+	// constants, e.g., because the lock file is on a network file system.
+	LockType string
+	// MaxDecompressedSize is the size in bytes beyond which a database is
+	// rejected while it is extracted, so that a corrupt or malicious
+	// archive can't fill the disk. If it is 0, databases can be up to
+	// defaultDecompressionRatio times larger than their archives, or
+	// defaultMaxDecompressedSize when the size of the archives is unknown.
+	MaxDecompressedSize int64
+	// MaxDiskUsage is the number of bytes the database directory and the
+	// archive directory can use. The oldest archived databases are removed
+	// as needed to write new databases within it. It is disabled if it is 0.
+	MaxDiskUsage int64
+	// MetadataCacheTTL is how long the latest builds of the editions, as
+	// returned by the MaxMind servers, are reused by the following runs,
+	// which don't request them again. It is disabled if it is 0, in which
+	// case they are only requested once per run.
+	MetadataCacheTTL time.Duration
+	// Middleware are the middlewares the downloads and the databases
+	// written go through, e.g., verify-md5 or throttle:1M. See
+	// middleware.go. They are stacked in order, the first being the
+	// outermost, before those set with WithDownloadMiddleware and
+	// WithWriterMiddleware.
+	Middleware []string
+	// downloadMiddleware are the download middlewares set with
+	// WithDownloadMiddleware.
+	downloadMiddleware []client.Middleware
+	// writerMiddleware are the writer middlewares set with
+	// WithWriterMiddleware.
+	writerMiddleware []database.WriterMiddleware
+	// clock is the Clock set with WithClock.
+	clock Clock
+	// editionsFunc is the EditionsFunc set with WithEditionsFunc.
+	editionsFunc EditionsFunc
+	// sources records the configuration after each source for ShowConfig.
+	sources *resolve.Provenance
+	// MinUpdateInterval is how long after an update an edition is checked
+	// for updates again, by edition ID, e.g., so that large editions whose
+	// freshness matters little are only downloaded monthly. Editions
+	// without one are checked on every run.
+	MinUpdateInterval map[string]time.Duration
+	// Notify are the targets updated databases are announced to, e.g., NATS
+	// subjects, SNS topics, or webhooks. See notify.New.
+	Notify []string
+	// AlertAfterFailures is the number of consecutive runs that must fail
+	// to update an edition for an alert to be sent to the Notify targets,
+	// followed by another once the edition is updated again. It is
+	// disabled if it is 0.
+	AlertAfterFailures int
+	// Peers are the base URLs of instances serving databases with the seed
+	// command. If set, databases are downloaded from them, falling back to
+	// URL.
+	Peers []string
+	// PIDFile is the path the daemon writes its process ID to while it
+	// runs, for init scripts and monitoring tools to supervise it.
+	PIDFile string
+	// Profile is the name of the configuration when the daemon manages
+	// several. It labels logs, metrics, and reports. It is not a setting of
+	// the configuration file.
+	Profile string
 	// PreserveFileTimes sets whether database modification times
 	// are preserved across downloads.
 	PreserveFileTimes bool
+	// OCIMirror is the OCI registry repository, e.g.,
+	// registry.example.com/geoip, to download databases from instead of
+	// URL. AccountID and LicenseKey are not needed if it is set.
+	OCIMirror string
+	// OCIPush is the OCI registry repository databases are pushed to after
+	// being updated.
+	OCIPush string
+	// OCIPushEncoding is the encoding, e.g., gzip, of the databases pushed to
+	// OCIPush. By default, they are pushed as is.
+	OCIPushEncoding string
+	// OCIPushVerify reads back each database pushed to OCIPush and checks
+	// its MD5 sum before the update succeeds.
+	OCIPushVerify bool
 	// Parallelism defines the number of concurrent downloads that
 	// can be triggered at the same time. It defaults to 1, which
 	// wouldn't change the existing behavior of downloading files
 	// sequentially.
 	Parallelism int
+	// PostUpdateCommand is the command, with space-separated arguments, run
+	// for each updated edition with the path of its new database as last
+	// argument, e.g., to convert it to the legacy format of a consumer that
+	// can't read MaxMind DB files. See postUpdate.
+	PostUpdateCommand string
 	// Proxy is host name or IP address of a proxy server.
 	Proxy *url.URL
 	// proxyURL is the host value of Proxy
 	proxyURL string
 	// proxyUserInfo is the userinfo value of Proxy
 	proxyUserInfo string
+	// ReportToken is the bearer token reports are uploaded to ReportURL
+	// with, and that a seed instance requires to accept them.
+	ReportToken string
+	// ReportURL is the http or https URL the report of each successful run
+	// is uploaded to, e.g., the /reports endpoint of a seed instance, whose
+	// /fleet-status endpoint then shows which build each host has.
+	ReportURL string
+	// RunTimeout is the maximum duration of a run. Once exceeded, no new
+	// edition is started and in-flight ones are canceled. It is disabled
+	// if it is 0.
+	RunTimeout time.Duration
+	// RunMode is either RunModeOnce, the default, to run a single update, or
+	// RunModeDaemon to keep running updates on the Schedule, as the daemon
+	// command does.
+	RunMode string
+	// Schedule is when a daemon runs updates. It defaults to
+	// DefaultSchedule.
+	Schedule Schedule
+	// ScheduleJitter is the maximum random delay added to each run of a
+	// daemon after the first one, so that many hosts don't update at the
+	// same time.
+	ScheduleJitter time.Duration
+	// RunAsUser is the user, by name or ID, to switch to before updating
+	// when running as root. The files and directories the updates write to
+	// are given to it first. See DropPrivileges.
+	RunAsUser string
+	// RunAsGroup is the group, by name or ID, to switch to before updating
+	// when running as root. It defaults to the primary group of RunAsUser.
+	RunAsGroup string
+	// S3Mirror is the s3://bucket[/prefix] URL of an Amazon S3 mirror of
+	// the databases. If set, databases are downloaded from it with AWS
+	// credentials, and AccountID and LicenseKey are not needed.
+	S3Mirror string
+	// S3Push is the s3://bucket[/prefix] URL of an Amazon S3 mirror the
+	// installed databases are published to after each successful run, in
+	// the layout S3Mirror downloads from.
+	S3Push string
+	// S3PushEncoding is the compression, e.g., zstd, of the archives pushed
+	// to S3Push. It defaults to gzip, as with the archives served by
+	// MaxMind. See s3PushEncoding.
+	S3PushEncoding string
+	// S3Region is the AWS region of S3Mirror, S3Push, and ArchiveS3. It
+	// defaults to us-east-1.
+	S3Region string
+	// Sandbox restricts the process on Linux before updating, so that a
+	// compromised dependency can only write to the directories updates
+	// write to, and can't use some dangerous system calls. See Sandbox.
+	Sandbox bool
+	// SelfCheckIP is the IP address, or the http or https URL of an endpoint
+	// responding with the IP address of the host, looked up in each updated
+	// database. See newSelfCheck.
+	SelfCheckIP string
+	// SkipIfRunning makes a run that finds the lock file held by another
+	// instance succeed without doing anything, rather than fail.
+	SkipIfRunning bool
+	// deprecatedOptions are the deprecated directives of the config file.
+	deprecatedOptions []string
+	// legacyEditionIDs are the GeoIP Legacy product IDs of EditionIDs.
+	legacyEditionIDs []legacyEditionID
+	// strictConfig makes deprecated directives in the config file an error
+	// rather than being ignored.
+	strictConfig bool
+	// withoutEnvironment makes NewConfig ignore the environment variables.
+	withoutEnvironment bool
+	// withoutResume makes the downloads start over rather than resume from
+	// partial downloads kept in DatabaseDirectory.
+	withoutResume bool
+	// StateFile is the path of the file where information about past
+	// runs is kept.
+	StateFile string
 	// RetryFor is the retry timeout for HTTP requests. It defaults
 	// to 5 minutes.
 	RetryFor time.Duration
+	// RetryPolicy is how each class of errors is retried, e.g., http_429 or
+	// disk, overriding the defaults of defaultRetryPolicy.
+	RetryPolicy map[string]RetryStrategy
+	// TempDirectory is where databases are written to before being
+	// copied to the DatabaseDirectory when WriteStrategy is "copy". It
+	// defaults to the system's directory for temporary files.
+	TempDirectory string
+	// TraceFile is the path of a file where the stages of the requests to
+	// the MaxMind servers, or URL, are appended as JSON lines, as written
+	// by client.WithTrace. Tracing is disabled if it is empty.
+	TraceFile string
+	// UnavailableEditionPolicy is what is done with the databases of the
+	// editions that are no longer available to the account, one of the
+	// UnavailableEdition constants. They are kept if it is empty.
+	UnavailableEditionPolicy string
 	// URL points to maxmind servers.
 	URL string
 	// Verbose turns on debug statements.
 	Verbose bool
+	// MetricsFile is the path of a file where metrics about the editions
+	// are written after each run, in the Prometheus text format. Metrics are
+	// disabled if it is empty.
+	MetricsFile string
 	// Output turns on sending the download/update result to stdout as JSON.
 	Output bool
+	// OutputFormat is the format of the JSON output. It is either
+	// "editions", the default, "report" or "stream".
+	OutputFormat string
+	// WriteRetryFor is the retry timeout for errors encountered while
+	// writing databases. It defaults to RetryFor.
+	WriteRetryFor time.Duration
+	// writeRetryForSet is whether WriteRetryFor was explicitly set.
+	writeRetryForSet bool
+	// WriteStrategy is how databases are moved into the DatabaseDirectory.
+	// It is either "rename", the default, or "copy".
+	WriteStrategy string
 }
 
 // Option is a function type that modifies a configuration object.
@@ -83,7 +379,45 @@ func WithParallelism(i int) Option {
 func WithDatabaseDirectory(dir string) Option {
 	return func(c *Config) error {
 		if dir != "" {
-			c.DatabaseDirectory = filepath.Clean(dir)
+			c.DatabaseDirectory = normalizePath(dir)
+		}
+		return nil
+	}
+}
+
+// WithEditionIDs returns an Option that sets the EditionIDs value of a
+// config, restricting a run to these editions.
+func WithEditionIDs(editionIDs []string) Option {
+	return func(c *Config) error {
+		if len(editionIDs) > 0 {
+			c.EditionIDs = editionIDs
+		}
+		return nil
+	}
+}
+
+// WithSkippedEditionIDs returns an Option that adds editionIDs, which may
+// be patterns, to the ExcludeEditionIDs of a config.
+func WithSkippedEditionIDs(editionIDs []string) Option {
+	return func(c *Config) error {
+		for _, id := range editionIDs {
+			if _, err := path.Match(id, ""); err != nil {
+				return fmt.Errorf("invalid edition pattern '%s'", id)
+			}
+			if !slices.Contains(c.ExcludeEditionIDs, id) {
+				c.ExcludeEditionIDs = append(c.ExcludeEditionIDs, id)
+			}
+		}
+		return nil
+	}
+}
+
+// WithTraceFile returns an Option that sets the TraceFile value of a
+// config.
+func WithTraceFile(file string) Option {
+	return func(c *Config) error {
+		if file != "" {
+			c.TraceFile = file
 		}
 		return nil
 	}
@@ -101,12 +435,83 @@ func WithOutput(c *Config) error {
 	return nil
 }
 
+// WithAllowDowngrade allows databases to be replaced with older builds.
+func WithAllowDowngrade(c *Config) error {
+	c.AllowDowngrade = true
+	return nil
+}
+
+// WithCheckOnly makes the Daemon check the databases rather than update
+// them.
+func WithCheckOnly(c *Config) error {
+	c.CheckOnly = true
+	return nil
+}
+
+// WithDownloadMiddleware returns an Option that stacks middlewares over the
+// downloads, after those of the Middleware setting.
+func WithDownloadMiddleware(middlewares ...client.Middleware) Option {
+	return func(c *Config) error {
+		c.downloadMiddleware = append(c.downloadMiddleware, middlewares...)
+		return nil
+	}
+}
+
+// WithWriterMiddleware returns an Option that stacks middlewares over the
+// writer of the databases, after those of the Middleware setting.
+func WithWriterMiddleware(middlewares ...database.WriterMiddleware) Option {
+	return func(c *Config) error {
+		c.writerMiddleware = append(c.writerMiddleware, middlewares...)
+		return nil
+	}
+}
+
+// WithClock returns an Option that makes updates use clock rather than the
+// system clock, e.g., a SimulatedClock to test RetryFor without waiting.
+func WithClock(clock Clock) Option {
+	return func(c *Config) error {
+		c.clock = clock
+		return nil
+	}
+}
+
+// WithoutEnvironment makes NewConfig ignore the environment variables, e.g.,
+// for programs embedding updates that are configured by other means.
+func WithoutEnvironment(c *Config) error {
+	c.withoutEnvironment = true
+	return nil
+}
+
+// WithoutResume makes the updater download databases in full rather than
+// keep partial downloads in DatabaseDirectory to resume them, e.g., for
+// Fetch not to depend on DatabaseDirectory.
+func WithoutResume(c *Config) error {
+	c.withoutResume = true
+	return nil
+}
+
+// WithProxy returns an Option that sets the proxy, as with the Proxy
+// directive.
+func WithProxy(proxy string) Option {
+	return func(c *Config) error {
+		c.proxyURL = proxy
+		return nil
+	}
+}
+
+// WithStrictConfig makes deprecated directives in the config file an error
+// rather than being ignored.
+func WithStrictConfig(c *Config) error {
+	c.strictConfig = true
+	return nil
+}
+
 // WithConfigFile returns an Option that sets the configuration
 // file to be used.
 func WithConfigFile(file string) Option {
 	return func(c *Config) error {
 		if file != "" {
-			c.configFile = filepath.Clean(file)
+			c.configFile = normalizePath(file)
 		}
 		return nil
 	}
@@ -115,41 +520,33 @@ func WithConfigFile(file string) Option {
 // NewConfig creates a new configuration and populates it based on an optional
 // config file pointed to by an option set with WithConfigFile, then by various
 // environment variables, and then finally by flag overrides provided by
-// flagOptions. Values from the later override the former.
+// flagOptions. Values from the later override the former, for every setting,
+// as described by the resolve package.
 func NewConfig(
 	flagOptions ...Option,
 ) (*Config, error) {
-	// config defaults
-	config := &Config{
-		URL:               "https://updates.maxmind.com",
-		DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
-		RetryFor:          5 * time.Minute,
-		Parallelism:       1,
-	}
-
-	// Potentially populate config.configFilePath. We will rerun this function
-	// again later to ensure the flag values override env variables.
-	err := setConfigFromFlags(config, flagOptions...)
-	if err != nil {
-		return nil, err
-	}
-
-	// Override config with values from the config file.
-	if confFile := config.configFile; confFile != "" {
-		err = setConfigFromFile(config, confFile)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Override config with values from environment variables.
-	err = setConfigFromEnv(config)
-	if err != nil {
-		return nil, err
-	}
-
-	// Override config with values from option flags.
-	err = setConfigFromFlags(config, flagOptions...)
+	config := &Config{}
+	err := resolve.New[Config]().
+		Add(resolve.Default, setConfigDefaults).
+		Add(resolve.File, func(c *Config) error {
+			if c.configFile == "" {
+				return nil
+			}
+			return setConfigFromFile(c, c.configFile)
+		}).
+		Add(resolve.Env, func(c *Config) error {
+			if c.withoutEnvironment {
+				return nil
+			}
+			return setConfigFromEnv(c)
+		}).
+		Add(resolve.Flag, func(c *Config) error {
+			return setConfigFromFlags(c, flagOptions...)
+		}).
+		// The flags may give the config file.
+		ApplyEarly(resolve.Flag).
+		Observe(recordSource).
+		Resolve(config)
 	if err != nil {
 		return nil, err
 	}
@@ -164,10 +561,64 @@ func NewConfig(
 		return nil, err
 	}
 
+	// Paths may start with ~, have trailing separators, or, on Windows,
+	// forward slashes, whatever their source.
+	paths := []*string{
+		&config.ArchiveDirectory,
+		&config.DatabaseDirectory,
+		&config.EncryptionKeyFile,
+		&config.IntegrityFile,
+		&config.LayerFile,
+		&config.LockFile,
+		&config.MetricsFile,
+		&config.PIDFile,
+		&config.StateFile,
+		&config.TempDirectory,
+		&config.TraceFile,
+	}
+	for i := range config.DatabaseDirectories {
+		paths = append(paths, &config.DatabaseDirectories[i])
+	}
+	for _, path := range paths {
+		*path = normalizePath(*path)
+	}
+
 	if config.LockFile == "" {
 		config.LockFile = filepath.Join(config.DatabaseDirectory, ".geoipupdate.lock")
 	}
 
+	if config.StateFile == "" {
+		config.StateFile = filepath.Join(config.DatabaseDirectory, ".geoipupdate.state")
+	}
+
+	if !config.writeRetryForSet {
+		config.WriteRetryFor = config.RetryFor
+	}
+
+	// The editions given on the command line may refer to groups too.
+	if err := expandEditionIDs(config); err != nil {
+		return nil, err
+	}
+	if err := aliasLegacyEditionIDs(config); err != nil {
+		return nil, err
+	}
+
+	// Long paths and UNC paths need the extended-length form on Windows.
+	for _, path := range []*string{
+		&config.ArchiveDirectory,
+		&config.DatabaseDirectory,
+		&config.IntegrityFile,
+		&config.LayerFile,
+		&config.LockFile,
+		&config.MetricsFile,
+		&config.PIDFile,
+		&config.StateFile,
+		&config.TempDirectory,
+		&config.TraceFile,
+	} {
+		*path = longPath(*path)
+	}
+
 	// Validate config values now that all config sources have been considered and
 	// any value that may need to be created from other values has been set.
 
@@ -180,13 +631,32 @@ func NewConfig(
 	// config overrides.
 
 	config.configFile = ""
+	config.sources = nil
 	config.proxyURL = ""
 	config.proxyUserInfo = ""
+	config.strictConfig = false
+	config.withoutEnvironment = false
+	config.writeRetryForSet = false
 
 	return config, nil
 }
 
-// setConfigFromFile sets Config fields based on the configuration file.
+// setConfigDefaults sets the Config fields whose default isn't their zero
+// value.
+func setConfigDefaults(config *Config) error {
+	config.URL = "https://updates.maxmind.com"
+	config.DatabaseDirectory = filepath.Clean(vars.DefaultDatabaseDirectory)
+	config.RetryFor = 5 * time.Minute
+	config.Parallelism = 1
+	config.WriteStrategy = database.WriteStrategyRename
+	config.OutputFormat = OutputFormatEditions
+	config.LockType = internal.LockTypeFlock
+	return nil
+}
+
+// setConfigFromFile sets Config fields based on the configuration file. Files
+// with a .yaml or .yml extension use the YAML format, others the GeoIP.conf
+// one.
 func setConfigFromFile(config *Config, path string) error {
 	fh, err := os.Open(filepath.Clean(path))
 	if err != nil {
@@ -195,84 +665,80 @@ func setConfigFromFile(config *Config, path string) error {
 
 	defer fh.Close()
 
-	scanner := bufio.NewScanner(fh)
+	r, err := utf8ConfigReader(fh)
+	if err != nil {
+		return err
+	}
+	if isYAMLConfig(path) {
+		return setConfigFromYAML(config, r)
+	}
+	return setConfigFromGeoIPConf(config, r)
+}
+
+// utf8ConfigReader returns a reader of the configuration file read from r
+// without its UTF-8 byte order mark, if any, as added by some Windows
+// editors. Files encoded in UTF-16, e.g., saved as "Unicode" by Notepad,
+// are rejected with an error telling so, rather than failing to parse.
+func utf8ConfigReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	bom, err := br.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(bom, []byte{0xEF, 0xBB, 0xBF}):
+		_, _ = br.Discard(3) //nolint:errcheck // the bytes were peeked
+	case bytes.HasPrefix(bom, []byte{0xFF, 0xFE}), bytes.HasPrefix(bom, []byte{0xFE, 0xFF}):
+		return nil, errors.New("the file is encoded in UTF-16; save it as UTF-8")
+	}
+	return br, nil
+}
+
+// setConfigFromGeoIPConf sets Config fields based on a configuration file
+// in the GeoIP.conf format read from r. See tokenizeConfigLine for its
+// syntax.
+func setConfigFromGeoIPConf(config *Config, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
 	lineNumber := 0
 	keysSeen := map[string]struct{}{}
 	for scanner.Scan() {
 		lineNumber++
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || line[0] == '#' {
-			continue
+		key, value, err := parseConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("invalid format on line %d: %w", lineNumber, err)
 		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			return fmt.Errorf("invalid format on line %d", lineNumber)
+		if key == "" {
+			continue
 		}
-		key := fields[0]
-		value := strings.Join(fields[1:], " ")
 
-		if _, ok := keysSeen[key]; ok {
+		// There is a directive per edition group and per dependent edition.
+		if _, ok := keysSeen[key]; ok && key != "EditionGroup" && key != "EditionDependency" {
 			return fmt.Errorf("`%s' is in the config multiple times", key)
 		}
 		keysSeen[key] = struct{}{}
 
 		switch key {
 		case "AccountID", "UserId":
-			accountID, err := strconv.Atoi(value)
-			if err != nil {
-				return errors.New("invalid account ID format")
-			}
-			config.AccountID = accountID
 			keysSeen["AccountID"] = struct{}{}
 			keysSeen["UserId"] = struct{}{}
-		case "DatabaseDirectory":
-			config.DatabaseDirectory = filepath.Clean(value)
 		case "EditionIDs", "ProductIds":
-			config.EditionIDs = strings.Fields(value)
 			keysSeen["EditionIDs"] = struct{}{}
 			keysSeen["ProductIds"] = struct{}{}
-		case "Host":
-			u, err := url.Parse(value)
-			if err != nil {
-				return fmt.Errorf("failed to parse Host: %w", err)
-			}
-			if u.Scheme == "" {
-				u.Scheme = schemeHTTPS
-			}
-			config.URL = u.String()
-		case "LicenseKey":
-			config.LicenseKey = value
-		case "LockFile":
-			config.LockFile = filepath.Clean(value)
-		case "PreserveFileTimes":
-			if value != "0" && value != "1" {
-				return errors.New("`PreserveFileTimes' must be 0 or 1")
-			}
-			config.PreserveFileTimes = value == "1"
-		case "Proxy":
-			config.proxyURL = value
-		case "ProxyUserPassword":
-			config.proxyUserInfo = value
 		case "Protocol", "SkipHostnameVerification", "SkipPeerVerification":
-			// Deprecated.
-		case "RetryFor":
-			dur, err := time.ParseDuration(value)
-			if err != nil || dur < 0 {
-				return fmt.Errorf("'%s' is not a valid duration", value)
-			}
-			config.RetryFor = dur
-		case "Parallelism":
-			parallelism, err := strconv.Atoi(value)
-			if err != nil {
-				return fmt.Errorf("'%s' is not a valid parallelism value: %w", value, err)
+			if config.strictConfig {
+				return fmt.Errorf("deprecated option `%s' on line %d", key, lineNumber)
 			}
-			if parallelism <= 0 {
-				return fmt.Errorf("parallelism should be greater than 0, got '%d'", parallelism)
+			config.deprecatedOptions = append(config.deprecatedOptions, key)
+		}
+
+		if err := setConfigFromDirective(config, key, value); err != nil {
+			if errors.Is(err, errUnknownDirective) {
+				if config.strictConfig {
+					return fmt.Errorf("unknown option `%s' on line %d", key, lineNumber)
+				}
+				return fmt.Errorf("unknown option on line %d", lineNumber)
 			}
-			config.Parallelism = parallelism
-		default:
-			return fmt.Errorf("unknown option on line %d", lineNumber)
+			return err
 		}
 	}
 
@@ -283,6 +749,363 @@ func setConfigFromFile(config *Config, path string) error {
 	return nil
 }
 
+// errUnknownDirective is returned by setConfigFromDirective for directives
+// it doesn't know about.
+var errUnknownDirective = errors.New("unknown directive")
+
+// setConfigFromDirective sets the Config field corresponding to a
+// configuration file directive, as named in GeoIP.conf.
+func setConfigFromDirective(config *Config, key, value string) error {
+	switch key {
+	case "AccountID", "UserId":
+		accountID, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("invalid account ID format")
+		}
+		config.AccountID = accountID
+	case "AlertAfterFailures":
+		failures, err := strconv.Atoi(value)
+		if err != nil || failures < 0 {
+			return fmt.Errorf("'%s' is not a valid number of failures", value)
+		}
+		config.AlertAfterFailures = failures
+	case "ArchiveDirectory":
+		config.ArchiveDirectory = filepath.Clean(value)
+	case "ArchiveS3":
+		archive, err := parseS3URL("ArchiveS3", value)
+		if err != nil {
+			return err
+		}
+		config.ArchiveS3 = archive
+	case "ArchiveS3StorageClass":
+		if err := checkStorageClass("ArchiveS3StorageClass", value); err != nil {
+			return err
+		}
+		config.ArchiveS3StorageClass = value
+	case "CacheMaxAge":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.CacheMaxAge = dur
+	case "ChecksumForensics":
+		if value != "0" && value != "1" {
+			return errors.New("`ChecksumForensics' must be 0 or 1")
+		}
+		config.ChecksumForensics = value == "1"
+	case "ConsistentSet":
+		if value != "0" && value != "1" {
+			return errors.New("`ConsistentSet' must be 0 or 1")
+		}
+		config.ConsistentSet = value == "1"
+	case "ConsumerLockTimeout":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.ConsumerLockTimeout = dur
+	case "DatabaseDirectory":
+		config.DatabaseDirectory = filepath.Clean(value)
+	case "DatabaseDirectories":
+		dirs, err := parseDatabaseDirectories("DatabaseDirectories", value)
+		if err != nil {
+			return err
+		}
+		config.DatabaseDirectories = dirs
+	case "Destination":
+		if err := validateDestination("Destination", value); err != nil {
+			return err
+		}
+		config.Destination = value
+	case "DestinationEncoding":
+		if err := validateEncoding("DestinationEncoding", value); err != nil {
+			return err
+		}
+		config.DestinationEncoding = value
+	case "DisableSelfUpdate":
+		if value != "0" && value != "1" {
+			return errors.New("`DisableSelfUpdate' must be 0 or 1")
+		}
+		config.DisableSelfUpdate = value == "1"
+	case "EditionDependency":
+		editionID, dependencies, _ := strings.Cut(value, " ")
+		if err := addEditionDependency(config, editionID, strings.Fields(dependencies)); err != nil {
+			return err
+		}
+	case "EditionGroup":
+		name, members, _ := strings.Cut(value, " ")
+		if err := addEditionGroup(config, name, strings.Fields(members)); err != nil {
+			return err
+		}
+	case "EditionIDs", "ProductIds":
+		config.EditionIDs = strings.Fields(value)
+	case "EditionOrder":
+		if err := validateEditionOrder("EditionOrder", value); err != nil {
+			return err
+		}
+		config.EditionOrder = value
+	case "EncryptionKeyFile":
+		config.EncryptionKeyFile = filepath.Clean(value)
+	case "EncryptionKMS":
+		if err := validateEncryptionKMS("EncryptionKMS", value); err != nil {
+			return err
+		}
+		config.EncryptionKMS = value
+	case "ExcludeEditionIDs":
+		excluded, err := parseExcludeEditionIDs(key, value)
+		if err != nil {
+			return err
+		}
+		config.ExcludeEditionIDs = excluded
+	case "ExpectedCadence":
+		cadences, err := parseExpectedCadence("ExpectedCadence", value)
+		if err != nil {
+			return err
+		}
+		config.ExpectedCadence = cadences
+	case "FailFastThreshold":
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 0 {
+			return fmt.Errorf("'%s' is not a valid fail fast threshold", value)
+		}
+		config.FailFastThreshold = threshold
+	case "Host":
+		u, err := url.Parse(value)
+		if err != nil {
+			return fmt.Errorf("failed to parse Host: %w", err)
+		}
+		if u.Scheme == "" {
+			u.Scheme = schemeHTTPS
+		}
+		config.URL = u.String()
+	case "HostAuth":
+		hostAuth, err := parseHostAuth(value)
+		if err != nil {
+			return err
+		}
+		config.HostAuth = hostAuth
+	case "IntegrityFile":
+		config.IntegrityFile = filepath.Clean(value)
+	case "Labels":
+		labels, err := parseLabels("Labels", value)
+		if err != nil {
+			return err
+		}
+		config.Labels = labels
+	case "LayerFile":
+		config.LayerFile = filepath.Clean(value)
+	case "LicenseKey":
+		config.LicenseKey = value
+	case "LockFile":
+		config.LockFile = filepath.Clean(value)
+	case "LockType":
+		if err := internal.ValidateLockType(value); err != nil {
+			return err
+		}
+		config.LockType = value
+	case "MaxDecompressedSize":
+		size, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+		config.MaxDecompressedSize = size
+	case "MaxDiskUsage":
+		size, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+		config.MaxDiskUsage = size
+	case "MetadataCacheTTL":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.MetadataCacheTTL = dur
+	case "MetricsFile":
+		config.MetricsFile = filepath.Clean(value)
+	case "Middleware":
+		entries, err := parseMiddleware("Middleware", value)
+		if err != nil {
+			return err
+		}
+		config.Middleware = entries
+	case "MinUpdateInterval":
+		intervals, err := parseMinUpdateInterval(key, value)
+		if err != nil {
+			return err
+		}
+		config.MinUpdateInterval = intervals
+	case "Notify":
+		targets, err := parseNotify("Notify", value)
+		if err != nil {
+			return err
+		}
+		config.Notify = targets
+	case "OCIMirror":
+		if err := validateOCIRepository("OCIMirror", value); err != nil {
+			return err
+		}
+		config.OCIMirror = value
+	case "OCIPush":
+		if err := validateOCIRepository("OCIPush", value); err != nil {
+			return err
+		}
+		config.OCIPush = value
+	case "OCIPushEncoding":
+		if err := validateEncoding("OCIPushEncoding", value); err != nil {
+			return err
+		}
+		config.OCIPushEncoding = value
+	case "OCIPushVerify":
+		if value != "0" && value != "1" {
+			return errors.New("`OCIPushVerify' must be 0 or 1")
+		}
+		config.OCIPushVerify = value == "1"
+	case "OutputFormat":
+		if err := validateOutputFormat(value); err != nil {
+			return err
+		}
+		config.OutputFormat = value
+	case "Peers":
+		peers, err := parsePeers("Peers", value)
+		if err != nil {
+			return err
+		}
+		config.Peers = peers
+	case "PIDFile":
+		config.PIDFile = filepath.Clean(value)
+	case "PostUpdateCommand":
+		config.PostUpdateCommand = strings.TrimSpace(value)
+	case "PreserveFileTimes":
+		if value != "0" && value != "1" {
+			return errors.New("`PreserveFileTimes' must be 0 or 1")
+		}
+		config.PreserveFileTimes = value == "1"
+	case "Proxy":
+		config.proxyURL = value
+	case "ProxyUserPassword":
+		config.proxyUserInfo = value
+	case "Protocol", "SkipHostnameVerification", "SkipPeerVerification":
+		// Deprecated.
+	case "ReportToken":
+		config.ReportToken = value
+	case "ReportURL":
+		if err := validateReportURL("ReportURL", value); err != nil {
+			return err
+		}
+		config.ReportURL = value
+	case "RetryFor":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.RetryFor = dur
+	case "RetryPolicy":
+		policy, err := parseRetryPolicy("RetryPolicy", value)
+		if err != nil {
+			return err
+		}
+		config.RetryPolicy = policy
+	case "RunAsGroup":
+		config.RunAsGroup = value
+	case "RunAsUser":
+		config.RunAsUser = value
+	case "RunTimeout":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.RunTimeout = dur
+	case "RunMode":
+		if err := validateRunMode(value); err != nil {
+			return err
+		}
+		config.RunMode = value
+	case "Schedule":
+		schedule, err := parseSchedule(value)
+		if err != nil {
+			return err
+		}
+		config.Schedule = schedule
+	case "ScheduleJitter":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.ScheduleJitter = dur
+	case "S3Mirror":
+		mirror, err := parseS3URL("S3Mirror", value)
+		if err != nil {
+			return err
+		}
+		config.S3Mirror = mirror
+	case "S3Push":
+		push, err := parseS3URL("S3Push", value)
+		if err != nil {
+			return err
+		}
+		config.S3Push = push
+	case "S3PushEncoding":
+		if err := validateEncoding("S3PushEncoding", value); err != nil {
+			return err
+		}
+		config.S3PushEncoding = value
+	case "S3Region":
+		config.S3Region = value
+	case "Sandbox":
+		if value != "0" && value != "1" {
+			return errors.New("`Sandbox' must be 0 or 1")
+		}
+		config.Sandbox = value == "1"
+	case "SelfCheckIP":
+		ip, err := parseSelfCheckIP("SelfCheckIP", value)
+		if err != nil {
+			return err
+		}
+		config.SelfCheckIP = ip
+	case "SkipIfRunning":
+		if value != "0" && value != "1" {
+			return errors.New("`SkipIfRunning' must be 0 or 1")
+		}
+		config.SkipIfRunning = value == "1"
+	case "StateFile":
+		config.StateFile = filepath.Clean(value)
+	case "TempDirectory":
+		config.TempDirectory = filepath.Clean(value)
+	case "TraceFile":
+		config.TraceFile = filepath.Clean(value)
+	case "UnavailableEditionPolicy":
+		if err := validateUnavailableEditionPolicy("UnavailableEditionPolicy", value); err != nil {
+			return err
+		}
+		config.UnavailableEditionPolicy = value
+	case "WriteRetryFor":
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.WriteRetryFor = dur
+		config.writeRetryForSet = true
+	case "WriteStrategy":
+		if err := validateWriteStrategy(value); err != nil {
+			return err
+		}
+		config.WriteStrategy = value
+	case "Parallelism":
+		parallelism, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid parallelism value: %w", value, err)
+		}
+		if parallelism <= 0 {
+			return fmt.Errorf("parallelism should be greater than 0, got '%d'", parallelism)
+		}
+		config.Parallelism = parallelism
+	default:
+		return errUnknownDirective
+	}
+	return nil
+}
+
 // setConfigFromEnv sets Config fields based on environment variables.
 func setConfigFromEnv(config *Config) error {
 	if value, ok := os.LookupEnv("GEOIPUPDATE_ACCOUNT_ID"); ok {
@@ -296,7 +1119,7 @@ func setConfigFromEnv(config *Config) error {
 	if value := os.Getenv("GEOIPUPDATE_ACCOUNT_ID_FILE"); value != "" {
 		var err error
 
-		accountID, err := os.ReadFile(filepath.Clean(value))
+		accountID, err := os.ReadFile(normalizePath(value))
 		if err != nil {
 			return fmt.Errorf("failed to open GEOIPUPDATE_ACCOUNT_ID_FILE: %w", err)
 		}
@@ -307,14 +1130,142 @@ func setConfigFromEnv(config *Config) error {
 		}
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_ALERT_AFTER_FAILURES"); ok {
+		failures, err := strconv.Atoi(value)
+		if err != nil || failures < 0 {
+			return fmt.Errorf("'%s' is not a valid number of failures", value)
+		}
+		config.AlertAfterFailures = failures
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_ARCHIVE_DIR"); ok {
+		config.ArchiveDirectory = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_ARCHIVE_S3"); ok {
+		archive, err := parseS3URL("GEOIPUPDATE_ARCHIVE_S3", value)
+		if err != nil {
+			return err
+		}
+		config.ArchiveS3 = archive
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_ARCHIVE_S3_STORAGE_CLASS"); ok {
+		if err := checkStorageClass("GEOIPUPDATE_ARCHIVE_S3_STORAGE_CLASS", value); err != nil {
+			return err
+		}
+		config.ArchiveS3StorageClass = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_CACHE_MAX_AGE"); ok {
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.CacheMaxAge = dur
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_CHECKSUM_FORENSICS"); ok {
+		if value != "0" && value != "1" {
+			return errors.New("`GEOIPUPDATE_CHECKSUM_FORENSICS' must be 0 or 1")
+		}
+		config.ChecksumForensics = value == "1"
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_CONSISTENT_SET"); ok {
+		if value != "0" && value != "1" {
+			return errors.New("`GEOIPUPDATE_CONSISTENT_SET' must be 0 or 1")
+		}
+		config.ConsistentSet = value == "1"
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_CONSUMER_LOCK_TIMEOUT"); ok {
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.ConsumerLockTimeout = dur
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_DB_DIR"); ok {
 		config.DatabaseDirectory = value
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_DB_DIRS"); ok {
+		dirs, err := parseDatabaseDirectories("GEOIPUPDATE_DB_DIRS", value)
+		if err != nil {
+			return err
+		}
+		config.DatabaseDirectories = dirs
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_DESTINATION"); ok {
+		if err := validateDestination("GEOIPUPDATE_DESTINATION", value); err != nil {
+			return err
+		}
+		config.Destination = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_DESTINATION_ENCODING"); ok {
+		if err := validateEncoding("GEOIPUPDATE_DESTINATION_ENCODING", value); err != nil {
+			return err
+		}
+		config.DestinationEncoding = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_DISABLE_SELF_UPDATE"); ok {
+		if value != "0" && value != "1" {
+			return errors.New("`GEOIPUPDATE_DISABLE_SELF_UPDATE' must be 0 or 1")
+		}
+		config.DisableSelfUpdate = value == "1"
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_EDITION_IDS"); ok {
 		config.EditionIDs = strings.Fields(value)
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_EDITION_ORDER"); ok {
+		if err := validateEditionOrder("GEOIPUPDATE_EDITION_ORDER", value); err != nil {
+			return err
+		}
+		config.EditionOrder = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_ENCRYPTION_KEY_FILE"); ok {
+		config.EncryptionKeyFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_ENCRYPTION_KMS"); ok {
+		if err := validateEncryptionKMS("GEOIPUPDATE_ENCRYPTION_KMS", value); err != nil {
+			return err
+		}
+		config.EncryptionKMS = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_EXCLUDE_EDITION_IDS"); ok {
+		excluded, err := parseExcludeEditionIDs("GEOIPUPDATE_EXCLUDE_EDITION_IDS", value)
+		if err != nil {
+			return err
+		}
+		config.ExcludeEditionIDs = excluded
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_EXPECTED_CADENCE"); ok {
+		cadences, err := parseExpectedCadence("GEOIPUPDATE_EXPECTED_CADENCE", value)
+		if err != nil {
+			return err
+		}
+		config.ExpectedCadence = cadences
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_FAIL_FAST_THRESHOLD"); ok {
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 0 {
+			return fmt.Errorf("'%s' is not a valid fail fast threshold", value)
+		}
+		config.FailFastThreshold = threshold
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_HOST"); ok {
 		u, err := url.Parse(value)
 		if err != nil {
@@ -326,6 +1277,14 @@ func setConfigFromEnv(config *Config) error {
 		config.URL = u.String()
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_HOST_AUTH"); ok {
+		hostAuth, err := parseHostAuth(value)
+		if err != nil {
+			return err
+		}
+		config.HostAuth = hostAuth
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_LICENSE_KEY"); ok {
 		config.LicenseKey = value
 	}
@@ -333,7 +1292,7 @@ func setConfigFromEnv(config *Config) error {
 	if value := os.Getenv("GEOIPUPDATE_LICENSE_KEY_FILE"); value != "" {
 		var err error
 
-		licenseKey, err := os.ReadFile(filepath.Clean(value))
+		licenseKey, err := os.ReadFile(normalizePath(value))
 		if err != nil {
 			return fmt.Errorf("failed to open GEOIPUPDATE_LICENSE_KEY_FILE: %w", err)
 		}
@@ -345,6 +1304,116 @@ func setConfigFromEnv(config *Config) error {
 		config.LockFile = value
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_LOCK_TYPE"); ok {
+		if err := internal.ValidateLockType(value); err != nil {
+			return err
+		}
+		config.LockType = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_MAX_DECOMPRESSED_SIZE"); ok {
+		size, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+		config.MaxDecompressedSize = size
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_MAX_DISK_USAGE"); ok {
+		size, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+		config.MaxDiskUsage = size
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_INTEGRITY_FILE"); ok {
+		config.IntegrityFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_LABELS"); ok {
+		labels, err := parseLabels("GEOIPUPDATE_LABELS", value)
+		if err != nil {
+			return err
+		}
+		config.Labels = labels
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_LAYER_FILE"); ok {
+		config.LayerFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_METADATA_CACHE_TTL"); ok {
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.MetadataCacheTTL = dur
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_METRICS_FILE"); ok {
+		config.MetricsFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_MIDDLEWARE"); ok {
+		entries, err := parseMiddleware("GEOIPUPDATE_MIDDLEWARE", value)
+		if err != nil {
+			return err
+		}
+		config.Middleware = entries
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_MIN_UPDATE_INTERVAL"); ok {
+		intervals, err := parseMinUpdateInterval("GEOIPUPDATE_MIN_UPDATE_INTERVAL", value)
+		if err != nil {
+			return err
+		}
+		config.MinUpdateInterval = intervals
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_NOTIFY"); ok {
+		targets, err := parseNotify("GEOIPUPDATE_NOTIFY", value)
+		if err != nil {
+			return err
+		}
+		config.Notify = targets
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_OCI_MIRROR"); ok {
+		if err := validateOCIRepository("GEOIPUPDATE_OCI_MIRROR", value); err != nil {
+			return err
+		}
+		config.OCIMirror = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_OCI_PUSH"); ok {
+		if err := validateOCIRepository("GEOIPUPDATE_OCI_PUSH", value); err != nil {
+			return err
+		}
+		config.OCIPush = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_OCI_PUSH_ENCODING"); ok {
+		if err := validateEncoding("GEOIPUPDATE_OCI_PUSH_ENCODING", value); err != nil {
+			return err
+		}
+		config.OCIPushEncoding = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_OCI_PUSH_VERIFY"); ok {
+		if value != "0" && value != "1" {
+			return errors.New("`GEOIPUPDATE_OCI_PUSH_VERIFY' must be 0 or 1")
+		}
+		config.OCIPushVerify = value == "1"
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_OUTPUT_FORMAT"); ok {
+		if err := validateOutputFormat(value); err != nil {
+			return err
+		}
+		config.OutputFormat = value
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_PARALLELISM"); ok {
 		parallelism, err := strconv.Atoi(value)
 		if err != nil {
@@ -356,6 +1425,22 @@ func setConfigFromEnv(config *Config) error {
 		config.Parallelism = parallelism
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_PEERS"); ok {
+		peers, err := parsePeers("GEOIPUPDATE_PEERS", value)
+		if err != nil {
+			return err
+		}
+		config.Peers = peers
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_PID_FILE"); ok {
+		config.PIDFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_POST_UPDATE_COMMAND"); ok {
+		config.PostUpdateCommand = strings.TrimSpace(value)
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_PRESERVE_FILE_TIMES"); ok {
 		if value != "0" && value != "1" {
 			return errors.New("`GEOIPUPDATE_PRESERVE_FILE_TIMES' must be 0 or 1")
@@ -371,6 +1456,17 @@ func setConfigFromEnv(config *Config) error {
 		config.proxyUserInfo = value
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_REPORT_TOKEN"); ok {
+		config.ReportToken = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_REPORT_URL"); ok {
+		if err := validateReportURL("GEOIPUPDATE_REPORT_URL", value); err != nil {
+			return err
+		}
+		config.ReportURL = value
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_RETRY_FOR"); ok {
 		dur, err := time.ParseDuration(value)
 		if err != nil || dur < 0 {
@@ -379,6 +1475,122 @@ func setConfigFromEnv(config *Config) error {
 		config.RetryFor = dur
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_RETRY_POLICY"); ok {
+		policy, err := parseRetryPolicy("GEOIPUPDATE_RETRY_POLICY", value)
+		if err != nil {
+			return err
+		}
+		config.RetryPolicy = policy
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_RUN_AS_GROUP"); ok {
+		config.RunAsGroup = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_RUN_AS_USER"); ok {
+		config.RunAsUser = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_RUN_TIMEOUT"); ok {
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.RunTimeout = dur
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_RUN_MODE"); ok {
+		if err := validateRunMode(value); err != nil {
+			return err
+		}
+		config.RunMode = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_SCHEDULE"); ok {
+		schedule, err := parseSchedule(value)
+		if err != nil {
+			return err
+		}
+		config.Schedule = schedule
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_SCHEDULE_JITTER"); ok {
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.ScheduleJitter = dur
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_S3_MIRROR"); ok {
+		mirror, err := parseS3URL("S3Mirror", value)
+		if err != nil {
+			return err
+		}
+		config.S3Mirror = mirror
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_S3_PUSH"); ok {
+		push, err := parseS3URL("GEOIPUPDATE_S3_PUSH", value)
+		if err != nil {
+			return err
+		}
+		config.S3Push = push
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_S3_PUSH_ENCODING"); ok {
+		if err := validateEncoding("GEOIPUPDATE_S3_PUSH_ENCODING", value); err != nil {
+			return err
+		}
+		config.S3PushEncoding = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_S3_REGION"); ok {
+		config.S3Region = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_SANDBOX"); ok {
+		if value != "0" && value != "1" {
+			return errors.New("`GEOIPUPDATE_SANDBOX' must be 0 or 1")
+		}
+		config.Sandbox = value == "1"
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_SELF_CHECK_IP"); ok {
+		ip, err := parseSelfCheckIP("GEOIPUPDATE_SELF_CHECK_IP", value)
+		if err != nil {
+			return err
+		}
+		config.SelfCheckIP = ip
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_SKIP_IF_RUNNING"); ok {
+		if value != "0" && value != "1" {
+			return errors.New("`GEOIPUPDATE_SKIP_IF_RUNNING' must be 0 or 1")
+		}
+		config.SkipIfRunning = value == "1"
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_STATE_FILE"); ok {
+		config.StateFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_TEMP_DIR"); ok {
+		config.TempDirectory = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_TRACE_FILE"); ok {
+		config.TraceFile = value
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_UNAVAILABLE_EDITION_POLICY"); ok {
+		err := validateUnavailableEditionPolicy("GEOIPUPDATE_UNAVAILABLE_EDITION_POLICY", value)
+		if err != nil {
+			return err
+		}
+		config.UnavailableEditionPolicy = value
+	}
+
 	if value, ok := os.LookupEnv("GEOIPUPDATE_VERBOSE"); ok {
 		if value != "0" && value != "1" {
 			return errors.New("`GEOIPUPDATE_VERBOSE' must be 0 or 1")
@@ -386,6 +1598,22 @@ func setConfigFromEnv(config *Config) error {
 		config.Verbose = value == "1"
 	}
 
+	if value, ok := os.LookupEnv("GEOIPUPDATE_WRITE_RETRY_FOR"); ok {
+		dur, err := time.ParseDuration(value)
+		if err != nil || dur < 0 {
+			return fmt.Errorf("'%s' is not a valid duration", value)
+		}
+		config.WriteRetryFor = dur
+		config.writeRetryForSet = true
+	}
+
+	if value, ok := os.LookupEnv("GEOIPUPDATE_WRITE_STRATEGY"); ok {
+		if err := validateWriteStrategy(value); err != nil {
+			return err
+		}
+		config.WriteStrategy = value
+	}
+
 	return nil
 }
 
@@ -399,6 +1627,22 @@ func setConfigFromFlags(config *Config, flagOptions ...Option) error {
 	return nil
 }
 
+// validateEncryptionKMS checks that value, the value of the setting name,
+// is a key of a supported key management service.
+func validateEncryptionKMS(name, value string) error {
+	if err := encryption.ValidateKeyWrapper(value); err != nil {
+		return fmt.Errorf("`%s' must be the key of a supported key management service: %w", name, err)
+	}
+	return nil
+}
+
+func validateDestination(name, value string) error {
+	if err := blob.Validate(value); err != nil {
+		return fmt.Errorf("`%s' must be the URL of a supported object storage: %w", name, err)
+	}
+	return nil
+}
+
 func validateConfig(config *Config) error {
 	// We used to recommend using 999999 / 000000000000 for free downloads
 	// and many people still use this combination. With a real account id
@@ -408,10 +1652,34 @@ func validateConfig(config *Config) error {
 		return errors.New("geoipupdate requires a valid AccountID and LicenseKey combination")
 	}
 
-	if len(config.EditionIDs) == 0 {
+	if len(config.EditionIDs) == 0 && len(config.EditionPatterns) == 0 && config.editionsFunc == nil {
 		return errors.New("the `EditionIDs` option is required")
 	}
 
+	if config.S3Mirror != "" && config.OCIMirror != "" {
+		return errors.New("only one of `S3Mirror` and `OCIMirror` can be set")
+	}
+
+	if config.S3Push != "" && config.S3Push == config.S3Mirror {
+		return errors.New("`S3Push` can't be the `S3Mirror` databases are downloaded from")
+	}
+
+	if config.EncryptionKeyFile != "" && config.EncryptionKMS != "" {
+		return errors.New("only one of `EncryptionKeyFile` and `EncryptionKMS` can be set")
+	}
+
+	// Nothing reads the databases of a Destination back to decrypt them,
+	// so they would be written unencrypted.
+	if config.Destination != "" && (config.EncryptionKeyFile != "" || config.EncryptionKMS != "") {
+		return errors.New("`Destination` can't be set with `EncryptionKeyFile` or `EncryptionKMS`, " +
+			"as the databases written to it aren't encrypted")
+	}
+
+	// Downloads from an S3 or OCI mirror use other credentials.
+	if config.S3Mirror != "" || config.OCIMirror != "" {
+		return nil
+	}
+
 	if config.AccountID == 0 {
 		return errors.New("the `AccountID` option is required")
 	}
@@ -423,6 +1691,101 @@ func validateConfig(config *Config) error {
 	return nil
 }
 
+// parsePeers parses the value of the setting name, a space-separated list of
+// peer URLs.
+func parsePeers(name, value string) ([]string, error) {
+	peers := strings.Fields(value)
+	for _, peer := range peers {
+		u, err := url.Parse(peer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != schemeHTTPS) || u.Host == "" {
+			return nil, fmt.Errorf("`%s' must be a list of HTTP URLs, got '%s'", name, peer)
+		}
+	}
+	return peers, nil
+}
+
+// parseMinUpdateInterval parses the value of the setting name, a
+// space-separated list of EditionID=duration pairs.
+func parseMinUpdateInterval(name, value string) (map[string]time.Duration, error) {
+	intervals := map[string]time.Duration{}
+	for _, entry := range strings.Fields(value) {
+		editionID, interval, ok := strings.Cut(entry, "=")
+		if !ok || editionID == "" {
+			return nil, fmt.Errorf("`%s' must be a list of EditionID=duration pairs, got '%s'", name, entry)
+		}
+		dur, err := time.ParseDuration(interval)
+		if err != nil || dur < 0 {
+			return nil, fmt.Errorf("`%s': '%s' is not a valid duration", name, interval)
+		}
+		intervals[editionID] = dur
+	}
+	return intervals, nil
+}
+
+// sizeUnits are the multipliers of the suffixes of sizes.
+var sizeUnits = map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+
+// parseSize parses a size in bytes, optionally suffixed with K, M or G for
+// KiB, MiB or GiB, e.g., 2G.
+func parseSize(value string) (int64, error) {
+	number, unit := value, int64(1)
+	if n := len(value); n > 0 {
+		if u, ok := sizeUnits[strings.ToUpper(value[n-1:])]; ok {
+			number, unit = value[:n-1], u
+		}
+	}
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 || size > math.MaxInt64/unit {
+		return 0, fmt.Errorf("'%s' is not a valid size", value)
+	}
+	return size * unit, nil
+}
+
+// labelNameRE matches the label names Prometheus accepts.
+var labelNameRE = regexp.MustCompile(`\A[a-zA-Z_][a-zA-Z0-9_]*\z`)
+
+// reservedLabels are the label names set by geoipupdate itself.
+var reservedLabels = []string{"edition_id", "profile"}
+
+// parseLabels parses the value of the setting name, a space-separated list
+// of name=value pairs.
+func parseLabels(name, value string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, entry := range strings.Fields(value) {
+		label, labelValue, ok := strings.Cut(entry, "=")
+		if !ok || !labelNameRE.MatchString(label) || strings.HasPrefix(label, "__") {
+			return nil, fmt.Errorf("`%s' must be a list of name=value pairs, got '%s'", name, entry)
+		}
+		if slices.Contains(reservedLabels, label) {
+			return nil, fmt.Errorf("`%s' can't set the reserved label '%s'", name, label)
+		}
+		labels[label] = labelValue
+	}
+	return labels, nil
+}
+
+// validateRunMode checks that mode is one of the RunMode constants.
+func validateRunMode(mode string) error {
+	if mode != RunModeOnce && mode != RunModeDaemon {
+		return fmt.Errorf("invalid run mode '%s', expected %s or %s", mode, RunModeOnce, RunModeDaemon)
+	}
+	return nil
+}
+
+func validateWriteStrategy(strategy string) error {
+	switch strategy {
+	case database.WriteStrategyRename, database.WriteStrategyCopy:
+		return nil
+	default:
+		return fmt.Errorf(
+			"`WriteStrategy' must be %s or %s, got '%s'",
+			database.WriteStrategyRename,
+			database.WriteStrategyCopy,
+			strategy,
+		)
+	}
+}
+
 var schemeRE = regexp.MustCompile(`(?i)\A([a-z][a-z0-9+\-.]*)://`)
 
 func parseProxy(
@@ -0,0 +1,111 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// LintConfig returns warnings about risky settings in config, which was
+// loaded from configFile, if not empty.
+func LintConfig(config *Config, configFile string) []Warning {
+	var warnings []Warning
+	warn := func(code, format string, args ...any) {
+		warnings = append(warnings, Warning{
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	var fileConfig Config
+	if configFile != "" {
+		// The file was already validated when loading config.
+		if err := setConfigFromFile(&fileConfig, configFile); err != nil {
+			fileConfig = Config{}
+		}
+
+		// File modes don't reflect access control on Windows.
+		info, err := os.Stat(configFile)
+		if err == nil && runtime.GOOS != "windows" &&
+			fileConfig.LicenseKey != "" && info.Mode().Perm()&0o004 != 0 {
+			warn(
+				"world-readable-license-key",
+				"%s contains a license key but is readable by all users; restrict its permissions, e.g., chmod 600",
+				configFile,
+			)
+		}
+	}
+
+	proxy := fileConfig.proxyURL
+	if value, ok := os.LookupEnv("GEOIPUPDATE_PROXY"); ok {
+		proxy = value
+	}
+	if hasCredentials(proxy) {
+		warn(
+			"proxy-credentials-in-url",
+			"the proxy URL contains credentials, which may end up in logs and process listings; "+
+				"use ProxyUserPassword or GEOIPUPDATE_PROXY_USER_PASSWORD instead",
+		)
+	}
+
+	// The number of editions matching patterns is only known at run time.
+	if len(config.EditionIDs) > 0 && len(config.EditionPatterns) == 0 &&
+		config.Parallelism > len(config.EditionIDs) {
+		warn(
+			"parallelism-exceeds-editions",
+			"Parallelism is %d but only %d editions are configured; the extra workers are never used",
+			config.Parallelism,
+			len(config.EditionIDs),
+		)
+	}
+
+	if config.PreserveFileTimes {
+		warn(
+			"preserve-file-times-freshness",
+			"PreserveFileTimes sets database modification times to their release dates; "+
+				"freshness checks based on modification times may report up-to-date databases as stale",
+		)
+	}
+
+	warnings = append(warnings, deprecationWarnings(config)...)
+
+	if config.AlertAfterFailures > 0 && len(config.Notify) == 0 {
+		warn(
+			"alerts-without-notify",
+			"AlertAfterFailures is set but Notify is empty; no alert is ever sent",
+		)
+	}
+
+	return warnings
+}
+
+// hasCredentials returns whether the raw proxy URL includes user info.
+func hasCredentials(proxy string) bool {
+	if proxy == "" {
+		return false
+	}
+	if i := strings.Index(proxy, "://"); i >= 0 {
+		proxy = proxy[i+3:]
+	}
+	if i := strings.IndexAny(proxy, "/?#"); i >= 0 {
+		proxy = proxy[:i]
+	}
+	return strings.Contains(proxy, "@")
+}
+
+// deprecationWarnings returns a WarningDeprecatedOption for each deprecated
+// setting of the configuration file of config.
+func deprecationWarnings(config *Config) []Warning {
+	var warnings []Warning
+	for _, option := range config.deprecatedOptions {
+		warnings = append(warnings, Warning{
+			Code:    WarningDeprecatedOption,
+			Message: fmt.Sprintf("`%s' is deprecated and ignored; remove it from the configuration file", option),
+		})
+	}
+	for _, l := range config.legacyEditionIDs {
+		warnings = append(warnings, Warning{Code: WarningDeprecatedOption, Message: l.warning()})
+	}
+	return warnings
+}
@@ -0,0 +1,182 @@
+package geoipupdate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// legacyUpdateHosts are update hosts used by older configurations that are
+// equivalent to the default one.
+var legacyUpdateHosts = map[string]struct{}{
+	"updates.maxmind.com":  {},
+	"geoip.maxmind.com":    {},
+	"download.maxmind.com": {},
+}
+
+// MigrateConfig converts a configuration file in the GeoIP.conf format,
+// including deprecated options, to the YAML format. It returns the YAML
+// configuration along with warnings about the options it dropped.
+func MigrateConfig(r io.Reader) ([]byte, []string, error) {
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	values := map[string]string{}
+	lines := map[string]int{}
+
+	// Values are also applied to a scratch Config to validate them.
+	var scratch Config
+
+	r, err := utf8ConfigReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		key, value, err := parseConfigLine(scanner.Text())
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid format on line %d: %w", lineNumber, err)
+		}
+		if key == "" {
+			continue
+		}
+
+		switch key {
+		case "UserId":
+			key = "AccountID"
+		case "ProductIds":
+			key = "EditionIDs"
+			value = migrateProductIDs(value, lineNumber, warn)
+			if value == "" {
+				continue
+			}
+		case "Protocol":
+			warn("dropped `Protocol' on line %d: databases are always downloaded over HTTPS", lineNumber)
+			continue
+		case "SkipHostnameVerification", "SkipPeerVerification":
+			warn("dropped `%s' on line %d: TLS certificates are always verified", key, lineNumber)
+			continue
+		case "Host":
+			if isLegacyUpdateHost(value) {
+				warn("dropped `Host' on line %d: %s is served by the default update host", lineNumber, value)
+				continue
+			}
+		}
+
+		d, ok := yamlDirectiveForName(key)
+		if !ok {
+			warn("dropped unknown option `%s' on line %d", key, lineNumber)
+			continue
+		}
+
+		if err := setConfigFromDirective(&scratch, key, value); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		if previous, ok := lines[key]; ok {
+			// There is a directive per edition group and per dependent
+			// edition.
+			if d.kind == kindMap {
+				value = values[key] + "\n" + value
+			} else {
+				warn("`%s' on line %d overrides the value on line %d", key, lineNumber, previous)
+			}
+		}
+		values[key] = value
+		lines[key] = lineNumber
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	if values["LicenseKey"] == "000000000000" {
+		warn("the free GeoLite license key 000000000000 no longer works; " +
+			"sign up for a GeoLite account to get a license key")
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, d := range yamlDirectives {
+		value, ok := values[d.directive]
+		if !ok {
+			continue
+		}
+		root.Content = append(
+			root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: d.key},
+			d.yamlNode(value),
+		)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if len(root.Content) > 0 {
+		if err := enc.Encode(root); err != nil {
+			return nil, nil, fmt.Errorf("encoding YAML: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("encoding YAML: %w", err)
+	}
+
+	return buf.Bytes(), warnings, nil
+}
+
+// migrateProductIDs replaces the product IDs of GeoIP Legacy databases of a
+// ProductIds value by the editions replacing them, and drops those without
+// any.
+func migrateProductIDs(value string, lineNumber int, warn func(string, ...any)) string {
+	var editionIDs []string
+	for _, id := range strings.Fields(value) {
+		productID, ok := legacyProductID(id)
+		if !ok {
+			editionIDs = append(editionIDs, id)
+			continue
+		}
+		editionID, ok := legacyProductIDs[productID]
+		if !ok {
+			warn("dropped product ID %s on line %d: GeoIP Legacy databases are no longer available", id, lineNumber)
+			continue
+		}
+		warn("replaced product ID %s on line %d with %s", id, lineNumber, editionID)
+		if !slices.Contains(editionIDs, editionID) {
+			editionIDs = append(editionIDs, editionID)
+		}
+	}
+	return strings.Join(editionIDs, " ")
+}
+
+func isLegacyUpdateHost(value string) bool {
+	host := value
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil || (u.Path != "" && u.Path != "/") {
+		return false
+	}
+	if u.Port() != "" && u.Port() != "443" {
+		return false
+	}
+	_, ok := legacyUpdateHosts[strings.ToLower(u.Hostname())]
+	return ok
+}
+
+func yamlDirectiveForName(directive string) (yamlDirective, bool) {
+	for _, d := range yamlDirectives {
+		if d.directive == directive {
+			return d, true
+		}
+	}
+	return yamlDirective{}, false
+}
@@ -0,0 +1,196 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		Description string
+		Input       string
+		Output      string
+		Warnings    []string
+		Err         string
+	}{
+		{
+			Description: "Current options",
+			Input: `# My config
+AccountID 42
+LicenseKey 000000000001
+EditionIDs GeoIP2-City GeoIP2-Country
+DatabaseDirectory /var/lib/GeoIP
+Host https://mirror.example.com
+PreserveFileTimes 1
+Parallelism 2
+RetryFor 10m
+`,
+			Output: `account_id: 42
+license_key: "000000000001"
+edition_ids:
+  - GeoIP2-City
+  - GeoIP2-Country
+database_directory: /var/lib/GeoIP
+host: https://mirror.example.com
+preserve_file_times: true
+parallelism: 2
+retry_for: 10m
+`,
+		},
+		{
+			Description: "Legacy options",
+			Input: `UserId 42
+LicenseKey 000000000000
+ProductIds 106 999 GeoLite2-City
+Protocol http
+Host updates.maxmind.com
+SkipHostnameVerification 0
+SkipPeerVerification 0
+UnknownOption 1
+`,
+			Output: `account_id: 42
+license_key: "000000000000"
+edition_ids:
+  - GeoIP2-Country
+  - GeoLite2-City
+`,
+			Warnings: []string{
+				"replaced product ID 106 on line 3 with GeoIP2-Country",
+				"dropped product ID 999 on line 3: GeoIP Legacy databases are no longer available",
+				"dropped `Protocol' on line 4: databases are always downloaded over HTTPS",
+				"dropped `Host' on line 5: updates.maxmind.com is served by the default update host",
+				"dropped `SkipHostnameVerification' on line 6: TLS certificates are always verified",
+				"dropped `SkipPeerVerification' on line 7: TLS certificates are always verified",
+				"dropped unknown option `UnknownOption' on line 8",
+				"the free GeoLite license key 000000000000 no longer works; " +
+					"sign up for a GeoLite account to get a license key",
+			},
+		},
+		{
+			Description: "Repeated option",
+			Input: `AccountID 1
+AccountID 2
+`,
+			Output: "account_id: 2\n",
+			Warnings: []string{
+				"`AccountID' on line 2 overrides the value on line 1",
+			},
+		},
+		{
+			Description: "Invalid value",
+			Input:       "RetryFor 5",
+			Err:         "line 1: '5' is not a valid duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			output, warnings, err := MigrateConfig(strings.NewReader(test.Input))
+			if test.Err != "" {
+				require.EqualError(t, err, test.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Output, string(output))
+			assert.Equal(t, test.Warnings, warnings)
+		})
+	}
+}
+
+// TestMigrateConfigEquivalence tests that a migrated configuration results
+// in the same Config as the original one.
+func TestMigrateConfigEquivalence(t *testing.T) {
+	legacy := `AccountID 1
+AlertAfterFailures 3
+ArchiveDirectory /tmp/archive
+ArchiveS3 s3://geoip-archive
+ArchiveS3StorageClass DEEP_ARCHIVE
+CacheMaxAge 15m
+ChecksumForensics 1
+ConsistentSet 1
+ConsumerLockTimeout 30s
+DatabaseDirectory /tmp/db
+Destination gs://geoip-fleet/databases
+DestinationEncoding zstd
+DisableSelfUpdate 1
+EditionDependency GeoIP2-City GeoIP2-ISP GeoLite2-ASN
+EditionGroup geolite GeoLite2-ASN GeoLite2-City
+EditionGroup paid GeoIP2-* GeoIP2-ISP
+EditionIDs GeoLite2-Country GeoLite2-City
+EditionOrder smallest-first
+EncryptionKeyFile /tmp/encryption.key
+ExcludeEditionIDs GeoLite2-City
+ExpectedCadence GeoLite2-Country=tue GeoLite2-City=daily
+FailFastThreshold 3
+Host https://mirror.example.com
+HostAuth mirror.example.com=bearer:token
+IntegrityFile /tmp/databases.mtree
+Labels env=prod service=edge
+LayerFile /tmp/databases.tar
+LicenseKey 000000000001
+LockFile /tmp/lock
+LockType mtime
+MaxDecompressedSize 512M
+MaxDiskUsage 4G
+MetadataCacheTTL 10m
+MetricsFile /tmp/geoipupdate.prom
+Middleware verify-md5 throttle:1M
+MinUpdateInterval GeoIP2-ISP=720h GeoLite2-City=168h
+Notify nats://localhost/geoip.updates https://hooks.example.com/geoip
+OCIPush registry.example.com/geoip
+OCIPushEncoding gzip
+OCIPushVerify 1
+OutputFormat report
+Parallelism 2
+Peers http://seed-1:8080 https://seed-2
+PIDFile /tmp/geoipupdate.pid
+PostUpdateCommand /usr/local/bin/mmdb2dat
+PreserveFileTimes 1
+Proxy 127.0.0.1:8888
+ProxyUserPassword username:password
+ReportToken secret
+ReportURL http://seed-1:8080/reports
+RetryFor 1m
+RetryPolicy http_429=backoff:2m disk=once
+RunAsGroup geoip
+RunAsUser geoipupdate
+RunMode daemon
+RunTimeout 20m
+S3Mirror s3://geoip-mirror/databases
+S3Push s3://geoip-edge/databases
+S3PushEncoding zstd
+S3Region eu-west-1
+Sandbox 1
+Schedule 30 3 * * mon-fri
+ScheduleJitter 10m
+SelfCheckIP 203.0.113.7
+SkipIfRunning 1
+StateFile /tmp/state
+TempDirectory /tmp/staging
+TraceFile /tmp/trace.jsonl
+UnavailableEditionPolicy quarantine
+WriteRetryFor 2m
+WriteStrategy copy
+`
+	migrated, warnings, err := MigrateConfig(strings.NewReader(legacy))
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	tempDir := t.TempDir()
+	legacyPath := filepath.Join(tempDir, "GeoIP.conf")
+	require.NoError(t, os.WriteFile(legacyPath, []byte(legacy), 0o600))
+	yamlPath := filepath.Join(tempDir, "geoipupdate.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, migrated, 0o600))
+
+	var legacyConfig, yamlConfig Config
+	require.NoError(t, setConfigFromFile(&legacyConfig, legacyPath))
+	require.NoError(t, setConfigFromFile(&yamlConfig, yamlPath))
+	require.Equal(t, legacyConfig, yamlConfig)
+	require.Equal(t, 20*time.Minute, yamlConfig.RunTimeout)
+}
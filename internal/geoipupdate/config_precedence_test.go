@@ -0,0 +1,253 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// precedenceTests has, for every setting of the configuration file, a value
+// in the file and another in its environment variable.
+var precedenceTests = []struct {
+	key      string
+	file     string
+	env      string
+	envValue string
+	// extra are the lines the file needs for the setting to be reported.
+	extra string
+}{
+	{"account_id", "123", "GEOIPUPDATE_ACCOUNT_ID", "456", ""},
+	{"alert_after_failures", "2", "GEOIPUPDATE_ALERT_AFTER_FAILURES", "3", ""},
+	{"archive_directory", "/tmp/archive-file", "GEOIPUPDATE_ARCHIVE_DIR", "/tmp/archive-env", ""},
+	{"archive_s3", "s3://archive-file", "GEOIPUPDATE_ARCHIVE_S3", "s3://archive-env", ""},
+	{
+		"archive_s3_storage_class", "GLACIER", "GEOIPUPDATE_ARCHIVE_S3_STORAGE_CLASS", "DEEP_ARCHIVE",
+		"ArchiveS3 s3://archive\n",
+	},
+	{"cache_max_age", "1h", "GEOIPUPDATE_CACHE_MAX_AGE", "2h", ""},
+	{"checksum_forensics", "1", "GEOIPUPDATE_CHECKSUM_FORENSICS", "0", ""},
+	{"consistent_set", "1", "GEOIPUPDATE_CONSISTENT_SET", "0", ""},
+	{"consumer_lock_timeout", "10s", "GEOIPUPDATE_CONSUMER_LOCK_TIMEOUT", "20s", ""},
+	{"database_directories", "/tmp/blue /tmp/green", "GEOIPUPDATE_DB_DIRS", "/tmp/a /tmp/b", ""},
+	{"database_directory", "/tmp/db-file", "GEOIPUPDATE_DB_DIR", "/tmp/db-env", ""},
+	{"destination", "gs://file", "GEOIPUPDATE_DESTINATION", "gs://env", ""},
+	{"destination_encoding", "zstd", "GEOIPUPDATE_DESTINATION_ENCODING", "gzip", "Destination gs://fleet\n"},
+	{"disable_self_update", "1", "GEOIPUPDATE_DISABLE_SELF_UPDATE", "0", ""},
+	{"edition_ids", "GeoLite2-Country", "GEOIPUPDATE_EDITION_IDS", "GeoLite2-ASN", ""},
+	{"edition_order", "smallest-first", "GEOIPUPDATE_EDITION_ORDER", "largest-first", ""},
+	{"encryption_key_file", "/tmp/file.key", "GEOIPUPDATE_ENCRYPTION_KEY_FILE", "/tmp/env.key", ""},
+	{
+		"encryption_kms",
+		"arn:aws:kms:us-east-1:123456789012:alias/file",
+		"GEOIPUPDATE_ENCRYPTION_KMS",
+		"arn:aws:kms:us-east-1:123456789012:alias/env",
+		"",
+	},
+	{"exclude_edition_ids", "GeoLite2-Country", "GEOIPUPDATE_EXCLUDE_EDITION_IDS", "GeoLite2-ASN", ""},
+	{"expected_cadence", "GeoLite2-City=weekly", "GEOIPUPDATE_EXPECTED_CADENCE", "GeoLite2-City=daily", ""},
+	{"fail_fast_threshold", "2", "GEOIPUPDATE_FAIL_FAST_THRESHOLD", "3", ""},
+	{"host", "file.example.com", "GEOIPUPDATE_HOST", "env.example.com", ""},
+	{
+		"host_auth",
+		"mirror.example.com=header:X-Api-Key:file",
+		"GEOIPUPDATE_HOST_AUTH",
+		"mirror.example.com=header:X-Api-Key:env",
+		"",
+	},
+	{"integrity_file", "/tmp/file.mtree", "GEOIPUPDATE_INTEGRITY_FILE", "/tmp/env.mtree", ""},
+	{"labels", "env=file", "GEOIPUPDATE_LABELS", "env=env", ""},
+	{"layer_file", "/tmp/file.tar", "GEOIPUPDATE_LAYER_FILE", "/tmp/env.tar", ""},
+	{"license_key", "000000000002", "GEOIPUPDATE_LICENSE_KEY", "000000000003", ""},
+	{"lock_file", "/tmp/file.lock", "GEOIPUPDATE_LOCK_FILE", "/tmp/env.lock", ""},
+	{"lock_type", "mtime", "GEOIPUPDATE_LOCK_TYPE", "flock", ""},
+	{"max_decompressed_size", "1g", "GEOIPUPDATE_MAX_DECOMPRESSED_SIZE", "2g", ""},
+	{"max_disk_usage", "8g", "GEOIPUPDATE_MAX_DISK_USAGE", "9g", ""},
+	{"metadata_cache_ttl", "5m", "GEOIPUPDATE_METADATA_CACHE_TTL", "10m", ""},
+	{"metrics_file", "/tmp/file.prom", "GEOIPUPDATE_METRICS_FILE", "/tmp/env.prom", ""},
+	{"middleware", "throttle:512k", "GEOIPUPDATE_MIDDLEWARE", "throttle:1m", ""},
+	{"min_update_interval", "GeoLite2-City=24h", "GEOIPUPDATE_MIN_UPDATE_INTERVAL", "GeoLite2-City=48h", ""},
+	{
+		"notify",
+		"arn:aws:sns:us-east-1:123456789012:file",
+		"GEOIPUPDATE_NOTIFY",
+		"arn:aws:sns:us-east-1:123456789012:env",
+		"",
+	},
+	{"oci_mirror", "registry.example.com/file", "GEOIPUPDATE_OCI_MIRROR", "registry.example.com/env", ""},
+	{"oci_push", "registry.example.com/file", "GEOIPUPDATE_OCI_PUSH", "registry.example.com/env", ""},
+	{"oci_push_encoding", "gzip", "GEOIPUPDATE_OCI_PUSH_ENCODING", "none", "OCIPush registry.example.com/geoip\n"},
+	{"oci_push_verify", "1", "GEOIPUPDATE_OCI_PUSH_VERIFY", "0", "OCIPush registry.example.com/geoip\n"},
+	{"output_format", "report", "GEOIPUPDATE_OUTPUT_FORMAT", "stream", ""},
+	{"parallelism", "2", "GEOIPUPDATE_PARALLELISM", "3", ""},
+	{"peers", "http://file:8080", "GEOIPUPDATE_PEERS", "http://env:8080", ""},
+	{"pid_file", "/tmp/file.pid", "GEOIPUPDATE_PID_FILE", "/tmp/env.pid", ""},
+	{"post_update_command", "/bin/file", "GEOIPUPDATE_POST_UPDATE_COMMAND", "/bin/env", ""},
+	{"preserve_file_times", "1", "GEOIPUPDATE_PRESERVE_FILE_TIMES", "0", ""},
+	{"proxy", "file.example.com", "GEOIPUPDATE_PROXY", "env.example.com", ""},
+	{
+		"proxy_user_password",
+		"user:file",
+		"GEOIPUPDATE_PROXY_USER_PASSWORD",
+		"user:env",
+		"Proxy proxy.example.com\n",
+	},
+	{"report_token", "file-token", "GEOIPUPDATE_REPORT_TOKEN", "env-token", ""},
+	{"report_url", "https://file.example.com/reports", "GEOIPUPDATE_REPORT_URL", "https://env.example.com/reports", ""},
+	{"retry_for", "10m", "GEOIPUPDATE_RETRY_FOR", "15m", ""},
+	{"retry_policy", "http_5xx=never", "GEOIPUPDATE_RETRY_POLICY", "http_5xx=once", ""},
+	{"run_as_group", "65534", "GEOIPUPDATE_RUN_AS_GROUP", "65533", ""},
+	{"run_as_user", "65534", "GEOIPUPDATE_RUN_AS_USER", "65533", ""},
+	{"run_mode", "daemon", "GEOIPUPDATE_RUN_MODE", "once", ""},
+	{"run_timeout", "10m", "GEOIPUPDATE_RUN_TIMEOUT", "20m", ""},
+	{"s3_mirror", "s3://file", "GEOIPUPDATE_S3_MIRROR", "s3://env", ""},
+	{"s3_push", "s3://file", "GEOIPUPDATE_S3_PUSH", "s3://env", ""},
+	{"s3_push_encoding", "zstd", "GEOIPUPDATE_S3_PUSH_ENCODING", "none", "S3Push s3://edge\n"},
+	{"s3_region", "eu-west-1", "GEOIPUPDATE_S3_REGION", "us-east-1", "S3Push s3://edge\n"},
+	{"sandbox", "1", "GEOIPUPDATE_SANDBOX", "0", ""},
+	{"schedule", "6h", "GEOIPUPDATE_SCHEDULE", "30 3 * * *", ""},
+	{"schedule_jitter", "10m", "GEOIPUPDATE_SCHEDULE_JITTER", "20m", ""},
+	{"self_check_ip", "192.0.2.1", "GEOIPUPDATE_SELF_CHECK_IP", "192.0.2.2", ""},
+	{"skip_if_running", "1", "GEOIPUPDATE_SKIP_IF_RUNNING", "0", ""},
+	{"state_file", "/tmp/file.state", "GEOIPUPDATE_STATE_FILE", "/tmp/env.state", ""},
+	{"temp_directory", "/tmp/file", "GEOIPUPDATE_TEMP_DIR", "/tmp/env", ""},
+	{"trace_file", "/tmp/file.jsonl", "GEOIPUPDATE_TRACE_FILE", "/tmp/env.jsonl", ""},
+	{"unavailable_edition_policy", "quarantine", "GEOIPUPDATE_UNAVAILABLE_EDITION_POLICY", "delete", ""},
+	{"write_retry_for", "2m", "GEOIPUPDATE_WRITE_RETRY_FOR", "3m", ""},
+	{"write_strategy", "copy", "GEOIPUPDATE_WRITE_STRATEGY", "rename", ""},
+}
+
+// fileOnlySettings are the settings of the configuration file without an
+// environment variable, as they are repeated once per name.
+var fileOnlySettings = []string{"edition_dependencies", "edition_groups"}
+
+// envOnlySettings are the environment variables without a setting of the
+// configuration file.
+var envOnlySettings = []string{
+	// They take precedence over GEOIPUPDATE_ACCOUNT_ID and
+	// GEOIPUPDATE_LICENSE_KEY.
+	"GEOIPUPDATE_ACCOUNT_ID_FILE",
+	"GEOIPUPDATE_LICENSE_KEY_FILE",
+	// Verbose mode is a flag.
+	"GEOIPUPDATE_VERBOSE",
+}
+
+// TestPrecedenceCoversSettings makes sure that every setting added to the
+// configuration is in precedenceTests.
+func TestPrecedenceCoversSettings(t *testing.T) {
+	keys := map[string]bool{}
+	envs := map[string]bool{}
+	for _, test := range precedenceTests {
+		keys[test.key] = true
+		envs[test.env] = true
+	}
+	for _, key := range fileOnlySettings {
+		keys[key] = true
+	}
+	for _, env := range envOnlySettings {
+		envs[env] = true
+	}
+
+	for _, d := range yamlDirectives {
+		assert.True(t, keys[d.key], "%s is missing from precedenceTests", d.key)
+	}
+
+	source, err := os.ReadFile("config.go")
+	require.NoError(t, err)
+	for _, env := range regexp.MustCompile(`"(GEOIPUPDATE_[A-Z0-9_]+)"`).FindAllSubmatch(source, -1) {
+		assert.True(t, envs[string(env[1])], "%s is missing from precedenceTests", env[1])
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	for _, test := range precedenceTests {
+		t.Run(test.key, func(t *testing.T) {
+			d, ok := yamlDirectiveFor(test.key)
+			require.True(t, ok)
+
+			var base string
+			for directive, value := range map[string]string{
+				"AccountID":  "1",
+				"LicenseKey": "000000000001",
+				"EditionIDs": "GeoLite2-City",
+			} {
+				if directive != d.directive {
+					base += directive + " " + value + "\n"
+				}
+			}
+			configFile := filepath.Join(t.TempDir(), "GeoIP.conf")
+			require.NoError(t, os.WriteFile(
+				configFile,
+				[]byte(base+test.extra+d.directive+" "+test.file+"\n"),
+				0o600,
+			))
+
+			assert.Equal(
+				t,
+				ConfigSourceFile,
+				showSource(t, test.key, WithConfigFile(configFile)),
+				"the file overrides the default",
+			)
+
+			t.Setenv(test.env, test.envValue)
+			assert.Equal(
+				t,
+				ConfigSourceEnv,
+				showSource(t, test.key, WithConfigFile(configFile)),
+				"the environment overrides the file",
+			)
+		})
+	}
+}
+
+func TestPrecedenceFlags(t *testing.T) {
+	tests := []struct {
+		key    string
+		env    string
+		value  string
+		option Option
+	}{
+		{"database_directory", "GEOIPUPDATE_DB_DIR", "/tmp/db-env", WithDatabaseDirectory("/tmp/db-flag")},
+		{"edition_ids", "GEOIPUPDATE_EDITION_IDS", "GeoLite2-ASN", WithEditionIDs([]string{"GeoLite2-Country"})},
+		{"parallelism", "GEOIPUPDATE_PARALLELISM", "2", WithParallelism(3)},
+		{"proxy", "GEOIPUPDATE_PROXY", "env.example.com", WithProxy("flag.example.com")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "GeoIP.conf")
+			require.NoError(t, os.WriteFile(configFile, []byte(
+				"AccountID 1\n"+
+					"LicenseKey 000000000001\n"+
+					"EditionIDs GeoLite2-City\n"+
+					"DatabaseDirectory /tmp/db-file\n"+
+					"Parallelism 4\n"+
+					"Proxy file.example.com\n",
+			), 0o600))
+			t.Setenv(test.env, test.value)
+
+			assert.Equal(
+				t,
+				ConfigSourceFlag,
+				showSource(t, test.key, WithConfigFile(configFile), test.option),
+				"the flag overrides the environment",
+			)
+		})
+	}
+}
+
+// showSource returns the source ShowConfig gives for the setting key.
+func showSource(t *testing.T, key string, options ...Option) string {
+	values, err := ShowConfig(options...)
+	require.NoError(t, err)
+	for _, v := range values {
+		if v.Key == key {
+			return v.Source
+		}
+	}
+	require.Failf(t, "setting not shown", "%s is not in the configuration", key)
+	return ""
+}
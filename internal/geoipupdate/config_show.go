@@ -0,0 +1,115 @@
+package geoipupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/maxmind/geoipupdate/v7/internal/resolve"
+)
+
+// Sources of the values of the configuration, from the lowest precedence to
+// the highest.
+const (
+	ConfigSourceDefault = string(resolve.Default)
+	ConfigSourceFile    = string(resolve.File)
+	ConfigSourceEnv     = string(resolve.Env)
+	ConfigSourceFlag    = string(resolve.Flag)
+)
+
+// ConfigValue is a setting of the effective configuration.
+type ConfigValue struct {
+	// Key is the name of the setting in the YAML configuration format.
+	Key string `json:"key"`
+	// Value is the value of the setting, with secrets redacted, as it would
+	// be encoded in JSON.
+	Value any `json:"value"`
+	// Source is where the value comes from, one of the ConfigSource
+	// constants.
+	Source string `json:"source"`
+}
+
+// recordSource records config as it is once source has been read. It does
+// nothing if the sources of config aren't wanted.
+func recordSource(source resolve.Source, config *Config) {
+	if config.sources == nil {
+		return
+	}
+	snapshot := *config
+	// The proxy is only set from its parts once all sources have been read.
+	if proxy, err := parseProxy(config.proxyURL, config.proxyUserInfo); err == nil {
+		snapshot.Proxy = proxy
+	}
+	settings := configMap(&snapshot)
+	// Secrets are redacted by configMap, so that a source replacing one with
+	// another would go unnoticed. Their values are compared instead.
+	for key, secret := range map[string]any{
+		"host_auth":           config.HostAuth,
+		"license_key":         config.LicenseKey,
+		"notify":              config.Notify,
+		"proxy_user_password": config.proxyUserInfo,
+		"report_token":        config.ReportToken,
+		"report_url":          config.ReportURL,
+		"self_check_ip":       config.SelfCheckIP,
+	} {
+		if _, ok := settings[key]; ok {
+			settings[key] = fmt.Sprint(secret)
+		}
+	}
+	config.sources.Record(source, settings)
+}
+
+// ShowConfig returns the effective configuration NewConfig creates with
+// flagOptions, sorted by key, with secrets redacted and the source of each
+// value. The source of a value is the last source that changed it, so values
+// computed from others, e.g., the default LockFile, are defaults.
+func ShowConfig(flagOptions ...Option) ([]ConfigValue, error) {
+	sources := &resolve.Provenance{}
+	withSources := func(c *Config) error {
+		if c.sources == nil {
+			c.sources = sources
+			recordSource(resolve.Default, c)
+		}
+		return nil
+	}
+
+	config, err := NewConfig(append([]Option{withSources}, flagOptions...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := configMap(config)
+	values := make([]ConfigValue, 0, len(effective))
+	for key, value := range effective {
+		values = append(values, ConfigValue{
+			Key:    key,
+			Value:  value,
+			Source: string(sources.Source(key)),
+		})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values, nil
+}
+
+// configMap returns the settings of config, with secrets redacted, by their
+// key in the YAML configuration format. The labels are included, although
+// they aren't part of the configuration of reports.
+func configMap(config *Config) map[string]any {
+	encoded, err := json.Marshal(newReportConfig(config))
+	if err != nil {
+		// The configuration only holds types that can be encoded.
+		panic(fmt.Sprintf("encoding configuration: %s", err))
+	}
+	var m map[string]any
+	if err := json.Unmarshal(encoded, &m); err != nil {
+		panic(fmt.Sprintf("decoding configuration: %s", err))
+	}
+	if len(config.Labels) > 0 {
+		labels := map[string]any{}
+		for name, value := range config.Labels {
+			labels[name] = value
+		}
+		m["labels"] = labels
+	}
+	return m
+}
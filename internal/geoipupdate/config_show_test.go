@@ -0,0 +1,76 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowConfig(t *testing.T) {
+	databaseDirectory := t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "GeoIP.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(
+		"AccountID 123\n"+
+			"LicenseKey 000000000001\n"+
+			"EditionIDs GeoLite2-City\n"+
+			"Parallelism 2\n"+
+			"RetryFor 10m\n"+
+			"Proxy proxy.example.com\n"+
+			"ProxyUserPassword user:secret\n",
+	), 0o600))
+
+	t.Setenv("GEOIPUPDATE_RETRY_FOR", "15m")
+	// The flag sets the same value as the file, and still takes precedence.
+	t.Setenv("GEOIPUPDATE_PARALLELISM", "3")
+
+	values, err := ShowConfig(
+		WithConfigFile(configFile),
+		WithDatabaseDirectory(databaseDirectory),
+		WithParallelism(2),
+	)
+	require.NoError(t, err)
+
+	byKey := map[string]ConfigValue{}
+	for _, v := range values {
+		byKey[v.Key] = v
+	}
+	assert.IsNonDecreasing(t, keys(values))
+
+	tests := []struct {
+		key    string
+		value  any
+		source string
+	}{
+		{"account_id", float64(123), ConfigSourceFile},
+		{"license_key", redacted, ConfigSourceFile},
+		{"edition_ids", []any{"GeoLite2-City"}, ConfigSourceFile},
+		{"retry_for", "15m0s", ConfigSourceEnv},
+		{"parallelism", float64(2), ConfigSourceFlag},
+		{"database_directory", databaseDirectory, ConfigSourceFlag},
+		{"proxy", "http://proxy.example.com:1080", ConfigSourceFile},
+		{"proxy_user_password", redacted, ConfigSourceFile},
+		{"host", "https://updates.maxmind.com", ConfigSourceDefault},
+		// Computed from DatabaseDirectory.
+		{"lock_file", filepath.Join(databaseDirectory, ".geoipupdate.lock"), ConfigSourceDefault},
+		// Defaults to RetryFor.
+		{"write_retry_for", "15m0s", ConfigSourceDefault},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			require.Contains(t, byKey, test.key)
+			assert.Equal(t, test.value, byKey[test.key].Value)
+			assert.Equal(t, test.source, byKey[test.key].Source)
+		})
+	}
+}
+
+func keys(values []ConfigValue) []string {
+	keys := make([]string, 0, len(values))
+	for _, v := range values {
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
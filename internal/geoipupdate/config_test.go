@@ -83,9 +83,14 @@ EditionIDs GeoLite2-Country GeoLite2-City
 				DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
 				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City"},
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -136,9 +141,14 @@ ProductIds GeoLite2-Country GeoLite2-City
 				DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
 				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City"},
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -193,6 +203,8 @@ Parallelism 3
 				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City", "GeoIP2-City"},
 				LicenseKey:        "abcdefghi",
 				LockFile:          filepath.Clean("/usr/lock"),
+				LockType:          "flock",
+				StateFile:         filepath.Join("/home", ".geoipupdate.state"),
 				Proxy: &url.URL{
 					Scheme: "http",
 					User:   url.UserPassword("username", "password"),
@@ -204,6 +216,9 @@ Parallelism 3
 				URL:               "https://updates.example.com",
 				RetryFor:          10 * time.Minute,
 				Parallelism:       3,
+				WriteRetryFor:     10 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -212,7 +227,7 @@ Parallelism 3
 LicenseKey
 # Host updates.maxmind.com
 `,
-			Err: "invalid format on line 2",
+			Err: "invalid format on line 2: missing value",
 		},
 		{
 			Description: "Option is there multiple times",
@@ -320,9 +335,61 @@ Parallelism 2`,
 				EditionIDs:        []string{"GeoIP2-City"},
 				LicenseKey:        "abcd",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       4,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
+			},
+		},
+		{
+			Description: "Skipped editions added by flag",
+			Input: `AccountID 999999
+LicenseKey abcd
+EditionIDs GeoIP2-City GeoIP2-ISP
+ExcludeEditionIDs GeoIP2-ISP`,
+			Flags: []Option{WithSkippedEditionIDs([]string{"GeoIP2-City"})},
+			Output: &Config{
+				AccountID:         999999,
+				DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
+				EditionIDs:        []string{"GeoIP2-City", "GeoIP2-ISP"},
+				ExcludeEditionIDs: []string{"GeoIP2-ISP", "GeoIP2-City"},
+				LicenseKey:        "abcd",
+				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
+				URL:               "https://updates.maxmind.com",
+				RetryFor:          5 * time.Minute,
+				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
+			},
+		},
+		{
+			Description: "Databases encrypted with a key management service",
+			Input: `AccountID 999999
+LicenseKey abcd
+EditionIDs GeoIP2-City
+EncryptionKMS arn:aws:kms:us-east-1:123456789012:alias/geoip`,
+			Output: &Config{
+				AccountID:         999999,
+				DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
+				EditionIDs:        []string{"GeoIP2-City"},
+				EncryptionKMS:     "arn:aws:kms:us-east-1:123456789012:alias/geoip",
+				LicenseKey:        "abcd",
+				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
+				URL:               "https://updates.maxmind.com",
+				RetryFor:          5 * time.Minute,
+				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -337,9 +404,14 @@ EditionIDs GeoIP2-City`,
 				EditionIDs:        []string{"GeoIP2-City"},
 				LicenseKey:        "abcd",
 				LockFile:          filepath.Clean("/tmp/.geoipupdate.lock"),
+				LockType:          "flock",
+				StateFile:         filepath.Join("/tmp", ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -353,9 +425,14 @@ EditionIDs GeoIP2-City`,
 				EditionIDs:        []string{"GeoIP2-City"},
 				LicenseKey:        "abcd",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -373,9 +450,15 @@ SkipPeerVerification 1
 				EditionIDs:        []string{"GeoIP2-City"},
 				LicenseKey:        "abcd",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
+				deprecatedOptions: []string{"Protocol", "SkipHostnameVerification", "SkipPeerVerification"},
 			},
 		},
 		{
@@ -387,9 +470,14 @@ SkipPeerVerification 1
 				EditionIDs:        []string{"GeoIP2-City"},
 				LicenseKey:        "123",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -409,9 +497,14 @@ EditionIDs    GeoLite2-City      GeoLite2-Country
 				EditionIDs:        []string{"GeoLite2-City", "GeoLite2-Country"},
 				LicenseKey:        "456",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -424,9 +517,14 @@ EditionIDs    GeoLite2-City      GeoLite2-Country
 				EditionIDs:        []string{"GeoLite2-City", "GeoLite2-Country"},
 				LicenseKey:        "456",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				URL:               "https://updates.maxmind.com",
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 			},
 		},
 		{
@@ -452,18 +550,75 @@ EditionIDs    GeoLite2-City      GeoLite2-Country
 				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City"},
 				LicenseKey:        "000000000001",
 				LockFile:          "/tmp/lock",
+				LockType:          "flock",
+				StateFile:         filepath.Join("/tmp/db", ".geoipupdate.state"),
 				Parallelism:       3,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 				PreserveFileTimes: true,
 				Proxy: &url.URL{
 					Scheme: "http",
 					User:   url.UserPassword("username", "password"),
 					Host:   "127.0.0.1:8888",
 				},
-				RetryFor: 1 * time.Minute,
-				URL:      "https://updates.maxmind.com",
-				Verbose:  true,
+				RetryFor:      1 * time.Minute,
+				URL:           "https://updates.maxmind.com",
+				Verbose:       true,
+				WriteRetryFor: 1 * time.Minute,
+			},
+		},
+		{
+			Description: "WriteRetryFor set separately from RetryFor",
+			Input:       "AccountID\t\t123\nLicenseKey\t\t456\nEditionIDs\t\tGeoIP2-City\nRetryFor\t\t2m\nWriteRetryFor\t\t0s",
+			Output: &Config{
+				AccountID:         123,
+				DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
+				EditionIDs:        []string{"GeoIP2-City"},
+				LicenseKey:        "456",
+				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
+				RetryFor:          2 * time.Minute,
+				Parallelism:       1,
+				WriteRetryFor:     0,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
+				URL:               "https://updates.maxmind.com",
+			},
+		},
+		{
+			Description: "Edition groups and patterns are expanded",
+			Input: `AccountID 123
+LicenseKey 456
+EditionGroup geolite GeoLite2-ASN GeoLite2-City
+EditionGroup isp GeoIP2-ISP GeoIP2-*-Test
+EditionIDs geolite GeoLite2-City GeoIP2-* isp`,
+			Output: &Config{
+				AccountID:         123,
+				DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
+				EditionGroups: map[string][]string{
+					"geolite": {"GeoLite2-ASN", "GeoLite2-City"},
+					"isp":     {"GeoIP2-ISP", "GeoIP2-*-Test"},
+				},
+				EditionIDs:      []string{"GeoLite2-ASN", "GeoLite2-City", "GeoIP2-ISP"},
+				EditionPatterns: []string{"GeoIP2-*", "GeoIP2-*-Test"},
+				LicenseKey:      "456",
+				LockFile:        filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:        "flock",
+				StateFile:       filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
+				RetryFor:        5 * time.Minute,
+				Parallelism:     1,
+				WriteRetryFor:   5 * time.Minute,
+				WriteStrategy:   "rename",
+				OutputFormat:    "editions",
+				URL:             "https://updates.maxmind.com",
 			},
 		},
+		{
+			Description: "Invalid edition pattern",
+			Input:       "AccountID 123\nLicenseKey 456\nEditionIDs GeoIP2-[",
+			Err:         "invalid edition pattern 'GeoIP2-['",
+		},
 		{
 			Description: "Host scheme is used",
 			Input:       "AccountID\t\t123\nLicenseKey\t\t456\nEditionIDs\t\tGeoIP2-City\nHost\t\thttp://test",
@@ -473,8 +628,13 @@ EditionIDs    GeoLite2-City      GeoLite2-Country
 				EditionIDs:        []string{"GeoIP2-City"},
 				LicenseKey:        "456",
 				LockFile:          filepath.Clean(filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.lock")),
+				LockType:          "flock",
+				StateFile:         filepath.Join(vars.DefaultDatabaseDirectory, ".geoipupdate.state"),
 				RetryFor:          5 * time.Minute,
 				Parallelism:       1,
+				WriteRetryFor:     5 * time.Minute,
+				WriteStrategy:     "rename",
+				OutputFormat:      "editions",
 				URL:               "http://test",
 			},
 		},
@@ -508,29 +668,162 @@ func TestSetConfigFromFile(t *testing.T) {
 		{
 			Description: "All config file related variables",
 			Input: `AccountID 1
+			AlertAfterFailures 3
+			ArchiveDirectory /tmp/archive
+			ArchiveS3 s3://geoip-archive/
+			ArchiveS3StorageClass DEEP_ARCHIVE
+			CacheMaxAge 15m
+			ChecksumForensics 1
+			ConsistentSet 1
+			ConsumerLockTimeout 30s
 			DatabaseDirectory /tmp/db
+			Destination gs://geoip-fleet/databases
+			DestinationEncoding zstd
+			DisableSelfUpdate 1
+			EditionDependency GeoLite2-City GeoLite2-ASN
+			EditionDependency GeoLite2-Country GeoLite2-City
+			EditionGroup geolite GeoLite2-ASN GeoLite2-City
+			EditionGroup paid GeoIP2-*
 			EditionIDs GeoLite2-Country GeoLite2-City
+			EditionOrder largest-first
+			EncryptionKeyFile /tmp/encryption.key
+			ExcludeEditionIDs GeoLite2-City GeoIP2-*-Test
+			ExpectedCadence GeoLite2-Country=tue,fri GeoLite2-City=daily
+			FailFastThreshold 3
 			Host updates.maxmind.com
+			HostAuth mirror.example.com=bearer:token s3.us-east-1.amazonaws.com=sigv4:us-east-1
+			IntegrityFile /tmp/databases.mtree
+			Labels env=prod service=edge
+			LayerFile /tmp/databases.tar
 			LicenseKey 000000000001
 			LockFile /tmp/lock
+			LockType mtime
+			MaxDecompressedSize 512M
+			MaxDiskUsage 4G
+			MetadataCacheTTL 10m
+			MetricsFile /tmp/geoipupdate.prom
+			Middleware verify-md5 throttle:1M
+			MinUpdateInterval GeoIP2-ISP=720h
+			Notify nats://localhost/geoip.updates https://hooks.example.com/geoip
+			OCIPush registry.example.com/geoip
+			OCIPushEncoding gzip
+			OCIPushVerify 1
+			OutputFormat report
 			Parallelism 2
+			Peers http://seed-1:8080 https://seed-2
+			PIDFile /tmp/geoipupdate.pid
+			PostUpdateCommand /usr/local/bin/mmdb2dat --legacy
 			PreserveFileTimes 1
 			Proxy 127.0.0.1:8888
 			ProxyUserPassword username:password
+			ReportToken secret
+			ReportURL http://seed-1:8080/reports
 			RetryFor 1m
+			RetryPolicy http_429=backoff:1h
+			RunAsGroup geoip
+			RunAsUser geoipupdate
+			RunMode daemon
+			RunTimeout 20m
+			S3Mirror s3://geoip-mirror/databases/
+			S3Push s3://geoip-edge/databases
+			S3PushEncoding zstd
+			S3Region eu-west-1
+			Sandbox 1
+			Schedule 6h
+			ScheduleJitter 15m
+			SelfCheckIP https://checkip.amazonaws.com
+			SkipIfRunning 1
+			StateFile /tmp/state
+			TempDirectory /tmp/staging
+			TraceFile /tmp/trace.jsonl
+			UnavailableEditionPolicy delete
+			WriteRetryFor 2m
+			WriteStrategy copy
 	`,
 			Expected: Config{
-				AccountID:         1,
-				DatabaseDirectory: filepath.Clean("/tmp/db"),
+				AccountID:             1,
+				AlertAfterFailures:    3,
+				ArchiveDirectory:      filepath.Clean("/tmp/archive"),
+				ArchiveS3:             "s3://geoip-archive",
+				ArchiveS3StorageClass: "DEEP_ARCHIVE",
+				CacheMaxAge:           15 * time.Minute,
+				ChecksumForensics:     true,
+				ConsistentSet:         true,
+				ConsumerLockTimeout:   30 * time.Second,
+				DatabaseDirectory:     filepath.Clean("/tmp/db"),
+				Destination:           "gs://geoip-fleet/databases",
+				DestinationEncoding:   "zstd",
+				DisableSelfUpdate:     true,
+				EditionDependencies: map[string][]string{
+					"GeoLite2-City":    {"GeoLite2-ASN"},
+					"GeoLite2-Country": {"GeoLite2-City"},
+				},
+				EditionGroups: map[string][]string{
+					"geolite": {"GeoLite2-ASN", "GeoLite2-City"},
+					"paid":    {"GeoIP2-*"},
+				},
 				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City"},
-				LicenseKey:        "000000000001",
-				LockFile:          filepath.Clean("/tmp/lock"),
-				Parallelism:       2,
-				PreserveFileTimes: true,
-				proxyURL:          "127.0.0.1:8888",
-				proxyUserInfo:     "username:password",
-				RetryFor:          1 * time.Minute,
-				URL:               "https://updates.maxmind.com",
+				EditionOrder:      "largest-first",
+				EncryptionKeyFile: "/tmp/encryption.key",
+				ExcludeEditionIDs: []string{"GeoLite2-City", "GeoIP2-*-Test"},
+				ExpectedCadence: map[string]Cadence{
+					"GeoLite2-Country": {Weekdays: []time.Weekday{time.Tuesday, time.Friday}},
+					"GeoLite2-City":    {Every: 24 * time.Hour},
+				},
+				FailFastThreshold: 3,
+				HostAuth: map[string]HostAuth{
+					"mirror.example.com":         {Scheme: "bearer", Value: "token"},
+					"s3.us-east-1.amazonaws.com": {Scheme: "sigv4", Region: "us-east-1"},
+				},
+				IntegrityFile:            filepath.Clean("/tmp/databases.mtree"),
+				Labels:                   map[string]string{"env": "prod", "service": "edge"},
+				LayerFile:                filepath.Clean("/tmp/databases.tar"),
+				LicenseKey:               "000000000001",
+				LockFile:                 filepath.Clean("/tmp/lock"),
+				LockType:                 "mtime",
+				MaxDecompressedSize:      512 << 20,
+				MaxDiskUsage:             4 << 30,
+				MetadataCacheTTL:         10 * time.Minute,
+				Middleware:               []string{"verify-md5", "throttle:1M"},
+				MetricsFile:              filepath.Clean("/tmp/geoipupdate.prom"),
+				MinUpdateInterval:        map[string]time.Duration{"GeoIP2-ISP": 720 * time.Hour},
+				Notify:                   []string{"nats://localhost/geoip.updates", "https://hooks.example.com/geoip"},
+				OCIPush:                  "registry.example.com/geoip",
+				OCIPushEncoding:          "gzip",
+				OCIPushVerify:            true,
+				OutputFormat:             "report",
+				Parallelism:              2,
+				Peers:                    []string{"http://seed-1:8080", "https://seed-2"},
+				PIDFile:                  filepath.Clean("/tmp/geoipupdate.pid"),
+				PostUpdateCommand:        "/usr/local/bin/mmdb2dat --legacy",
+				PreserveFileTimes:        true,
+				proxyURL:                 "127.0.0.1:8888",
+				proxyUserInfo:            "username:password",
+				ReportToken:              "secret",
+				ReportURL:                "http://seed-1:8080/reports",
+				RetryFor:                 1 * time.Minute,
+				RetryPolicy:              map[string]RetryStrategy{"http_429": {Kind: "backoff", Wait: time.Hour}},
+				RunAsGroup:               "geoip",
+				RunAsUser:                "geoipupdate",
+				RunMode:                  RunModeDaemon,
+				RunTimeout:               20 * time.Minute,
+				S3Mirror:                 "s3://geoip-mirror/databases",
+				S3Push:                   "s3://geoip-edge/databases",
+				S3PushEncoding:           "zstd",
+				S3Region:                 "eu-west-1",
+				Sandbox:                  true,
+				Schedule:                 Schedule{Every: 6 * time.Hour},
+				ScheduleJitter:           15 * time.Minute,
+				SelfCheckIP:              "https://checkip.amazonaws.com",
+				SkipIfRunning:            true,
+				StateFile:                filepath.Clean("/tmp/state"),
+				TempDirectory:            filepath.Clean("/tmp/staging"),
+				TraceFile:                filepath.Clean("/tmp/trace.jsonl"),
+				UnavailableEditionPolicy: "delete",
+				URL:                      "https://updates.maxmind.com",
+				WriteRetryFor:            2 * time.Minute,
+				writeRetryForSet:         true,
+				WriteStrategy:            "copy",
 			},
 		},
 		{
@@ -553,6 +846,312 @@ func TestSetConfigFromFile(t *testing.T) {
 			Input:       "RetryFor 5",
 			Err:         "'5' is not a valid duration",
 		},
+		{
+			Description: "ConsumerLockTimeout needs to be non-negative",
+			Input:       "ConsumerLockTimeout -5s",
+			Err:         "'-5s' is not a valid duration",
+		},
+		{
+			Description: "CacheMaxAge needs to be non-negative",
+			Input:       "CacheMaxAge -5m",
+			Err:         "'-5m' is not a valid duration",
+		},
+		{
+			Description: "Invalid MaxDecompressedSize",
+			Input:       "MaxDecompressedSize 2GB",
+			Err:         "'2GB' is not a valid size",
+		},
+		{
+			Description: "Invalid LockType",
+			Input:       "LockType nfs",
+			Err:         "`LockType' must be flock, fcntl, mutex, mtime or lease, got 'nfs'",
+		},
+		{
+			Description: "MetadataCacheTTL needs to be non-negative",
+			Input:       "MetadataCacheTTL -5m",
+			Err:         "'-5m' is not a valid duration",
+		},
+		{
+			Description: "Invalid MinUpdateInterval",
+			Input:       "MinUpdateInterval GeoIP2-ISP=30d",
+			Err:         "`MinUpdateInterval': '30d' is not a valid duration",
+		},
+		{
+			Description: "MinUpdateInterval without an edition",
+			Input:       "MinUpdateInterval 720h",
+			Err:         "`MinUpdateInterval' must be a list of EditionID=duration pairs, got '720h'",
+		},
+		{
+			Description: "Invalid MaxDiskUsage",
+			Input:       "MaxDiskUsage -1",
+			Err:         "'-1' is not a valid size",
+		},
+		{
+			Description: "AlertAfterFailures needs to be non-negative",
+			Input:       "AlertAfterFailures -1",
+			Err:         "'-1' is not a valid number of failures",
+		},
+		{
+			Description: "FailFastThreshold needs to be non-negative",
+			Input:       "FailFastThreshold -1",
+			Err:         "'-1' is not a valid fail fast threshold",
+		},
+		{
+			Description: "Invalid ChecksumForensics",
+			Input:       "ChecksumForensics yes",
+			Err:         "`ChecksumForensics' must be 0 or 1",
+		},
+		{
+			Description: "Invalid ConsistentSet",
+			Input:       "ConsistentSet yes",
+			Err:         "`ConsistentSet' must be 0 or 1",
+		},
+		{
+			Description: "Invalid Destination",
+			Input:       "Destination s3://geoip-fleet",
+			Err: "`Destination' must be the URL of a supported object storage: " +
+				"unsupported destination 's3://geoip-fleet', expected gs://, azblob://, or an Azure container URL",
+		},
+		{
+			Description: "Invalid DestinationEncoding",
+			Input:       "DestinationEncoding xz",
+			Err: "`DestinationEncoding' must be one of [gzip none zstd]: " +
+				"unknown encoding 'xz', expected one of [gzip none zstd]",
+		},
+		{
+			Description: "Invalid DisableSelfUpdate",
+			Input:       "DisableSelfUpdate yes",
+			Err:         "`DisableSelfUpdate' must be 0 or 1",
+		},
+		{
+			Description: "Invalid HostAuth entry",
+			Input:       "HostAuth mirror.example.com",
+			Err:         "invalid `HostAuth' entry 'mirror.example.com': expected host=scheme",
+		},
+		{
+			Description: "Unknown HostAuth scheme",
+			Input:       "HostAuth mirror.example.com=digest",
+			Err:         "unknown `HostAuth' scheme 'digest' for mirror.example.com",
+		},
+		{
+			Description: "HostAuth header scheme needs a value",
+			Input:       "HostAuth mirror.example.com=header:X-Api-Key",
+			Err:         "the `HostAuth' header scheme of mirror.example.com requires a header name and value",
+		},
+		{
+			Description: "HostAuth can't be set for the MaxMind servers",
+			Input:       "HostAuth updates.maxmind.com=bearer:token",
+			Err:         "`HostAuth' can't be set for updates.maxmind.com",
+		},
+		{
+			Description: "Invalid Peers",
+			Input:       "Peers http://seed-1:8080 seed-2",
+			Err:         "`Peers' must be a list of HTTP URLs, got 'seed-2'",
+		},
+		{
+			Description: "Invalid Labels",
+			Input:       "Labels env=prod 1st=edge",
+			Err:         "`Labels' must be a list of name=value pairs, got '1st=edge'",
+		},
+		{
+			Description: "Invalid ExcludeEditionIDs",
+			Input:       "ExcludeEditionIDs GeoIP2-[",
+			Err:         "`ExcludeEditionIDs' contains the invalid pattern 'GeoIP2-['",
+		},
+		{
+			Description: "Invalid ExpectedCadence",
+			Input:       "ExpectedCadence GeoLite2-City=tuesday",
+			Err:         "`ExpectedCadence': invalid cadence 'tuesday': expected daily, weekly, or weekdays such as tue,fri",
+		},
+		{
+			Description: "ExpectedCadence without an edition",
+			Input:       "ExpectedCadence daily",
+			Err:         "`ExpectedCadence' must be a list of EditionID=cadence pairs, got 'daily'",
+		},
+		{
+			Description: "Invalid ReportURL",
+			Input:       "ReportURL seed-1:8080/reports",
+			Err:         "`ReportURL' must be an http or https URL, got 'seed-1:8080/reports'",
+		},
+		{
+			Description: "EditionGroup without editions",
+			Input:       "EditionGroup geolite",
+			Err:         "the edition group 'geolite' has no editions",
+		},
+		{
+			Description: "EditionGroup defined multiple times",
+			Input:       "EditionGroup geolite GeoLite2-ASN\nEditionGroup geolite GeoLite2-City",
+			Expected:    Config{EditionGroups: map[string][]string{"geolite": {"GeoLite2-ASN"}}},
+			Err:         "the edition group 'geolite' is defined multiple times",
+		},
+		{
+			Description: "EditionDependency without editions",
+			Input:       "EditionDependency GeoIP2-City",
+			Err:         "the dependencies of 'GeoIP2-City' have no editions",
+		},
+		{
+			Description: "EditionDependency defined multiple times",
+			Input:       "EditionDependency GeoIP2-City GeoIP2-ISP\nEditionDependency GeoIP2-City GeoIP2-ASN",
+			Expected:    Config{EditionDependencies: map[string][]string{"GeoIP2-City": {"GeoIP2-ISP"}}},
+			Err:         "the dependencies of 'GeoIP2-City' are defined multiple times",
+		},
+		{
+			Description: "EditionDependency on a pattern",
+			Input:       "EditionDependency GeoIP2-City GeoIP2-*",
+			Err:         "invalid edition ID 'GeoIP2-*' in `EditionDependency'",
+		},
+		{
+			Description: "EditionDependency cycle",
+			Input: "EditionDependency GeoIP2-City GeoIP2-ISP\n" +
+				"EditionDependency GeoIP2-ISP GeoIP2-ASN\n" +
+				"EditionDependency GeoIP2-ASN GeoIP2-City",
+			Expected: Config{EditionDependencies: map[string][]string{
+				"GeoIP2-City": {"GeoIP2-ISP"},
+				"GeoIP2-ISP":  {"GeoIP2-ASN"},
+			}},
+			Err: "'GeoIP2-ASN' and 'GeoIP2-City' depend on each other",
+		},
+		{
+			Description: "EditionGroup named after a pattern",
+			Input:       "EditionGroup GeoIP2-* GeoIP2-City",
+			Err:         "invalid edition group name 'GeoIP2-*'",
+		},
+		{
+			Description: "Reserved Labels",
+			Input:       "Labels profile=edge",
+			Err:         "`Labels' can't set the reserved label 'profile'",
+		},
+		{
+			Description: "Invalid Notify",
+			Input:       "Notify nats://localhost",
+			Err:         "`Notify' must be a list of notification targets: invalid NATS subject ''",
+		},
+		{
+			Description: "Invalid OCIMirror",
+			Input:       "OCIMirror registry.example.com",
+			Err:         "`OCIMirror' must be a registry/repository reference, got 'registry.example.com'",
+		},
+		{
+			Description: "Unknown Middleware",
+			Input:       "Middleware verify-md5 compress",
+			Err:         "`Middleware' must be a list of verify-md5 and throttle:<size> middlewares, got 'compress'",
+		},
+		{
+			Description: "Invalid throttle Middleware",
+			Input:       "Middleware throttle:0",
+			Err:         "`Middleware' must throttle to a positive size per second, got 'throttle:0'",
+		},
+		{
+			Description: "Invalid EncryptionKMS",
+			Input:       "EncryptionKMS vault://vault.example.com/geoip",
+			Err: "`EncryptionKMS' must be the key of a supported key management service: " +
+				"invalid Vault transit key 'vault://vault.example.com/geoip'",
+		},
+		{
+			Description: "Invalid OCIPushVerify",
+			Input:       "OCIPushVerify yes",
+			Err:         "`OCIPushVerify' must be 0 or 1",
+		},
+		{
+			Description: "Invalid OCIPushEncoding",
+			Input:       "OCIPushEncoding brotli",
+			Err: "`OCIPushEncoding' must be one of [gzip none zstd]: " +
+				"unknown encoding 'brotli', expected one of [gzip none zstd]",
+		},
+		{
+			Description: "Invalid S3Mirror",
+			Input:       "S3Mirror https://geoip-mirror.s3.amazonaws.com",
+			Err:         "`S3Mirror' must be an s3://bucket[/prefix] URL, got 'https://geoip-mirror.s3.amazonaws.com'",
+		},
+		{
+			Description: "Invalid S3Push",
+			Input:       "S3Push geoip-edge",
+			Err:         "`S3Push' must be an s3://bucket[/prefix] URL, got 'geoip-edge'",
+		},
+		{
+			Description: "Invalid S3PushEncoding",
+			Input:       "S3PushEncoding bzip2",
+			Err: "`S3PushEncoding' must be one of [gzip none zstd]: " +
+				"unknown encoding 'bzip2', expected one of [gzip none zstd]",
+		},
+		{
+			Description: "Invalid ArchiveS3",
+			Input:       "ArchiveS3 /var/lib/GeoIP/archive",
+			Err:         "`ArchiveS3' must be an s3://bucket[/prefix] URL, got '/var/lib/GeoIP/archive'",
+		},
+		{
+			Description: "Invalid ArchiveS3StorageClass",
+			Input:       "ArchiveS3StorageClass glacier",
+			Err: "`ArchiveS3StorageClass' must be one of STANDARD, STANDARD_IA, GLACIER_IR, " +
+				"GLACIER, DEEP_ARCHIVE, got 'glacier'",
+		},
+		{
+			Description: "Invalid OutputFormat",
+			Input:       "OutputFormat xml",
+			Err:         "`OutputFormat' must be editions, report or stream, got 'xml'",
+		},
+		{
+			Description: "Invalid Sandbox",
+			Input:       "Sandbox true",
+			Err:         "`Sandbox' must be 0 or 1",
+		},
+		{
+			Description: "Invalid SkipIfRunning",
+			Input:       "SkipIfRunning yes",
+			Err:         "`SkipIfRunning' must be 0 or 1",
+		},
+		{
+			Description: "RunTimeout needs a unit",
+			Input:       "RunTimeout 20",
+			Err:         "'20' is not a valid duration",
+		},
+		{
+			Description: "Invalid RunMode",
+			Input:       "RunMode service",
+			Err:         "invalid run mode 'service', expected once or daemon",
+		},
+		{
+			Description: "Invalid Schedule",
+			Input:       "Schedule 0 3 * *",
+			Err: "invalid schedule '0 3 * *': expected a duration, or a cron expression with 5 fields: " +
+				"minute, hour, day of month, month, day of week",
+		},
+		{
+			Description: "Negative ScheduleJitter",
+			Input:       "ScheduleJitter -1m",
+			Err:         "'-1m' is not a valid duration",
+		},
+		{
+			Description: "Invalid WriteStrategy",
+			Input:       "WriteStrategy move",
+			Err:         "`WriteStrategy' must be rename or copy, got 'move'",
+		},
+		{
+			Description: "Invalid EditionOrder",
+			Input:       "EditionOrder random",
+			Err:         "`EditionOrder' must be config, largest-first or smallest-first, got 'random'",
+		},
+		{
+			Description: "Invalid RetryPolicy class",
+			Input:       "RetryPolicy http_600=never",
+			Err: "`RetryPolicy': unknown error class 'http_600', expected checksum, disk, dns, network, " +
+				"other, proxy, read, timeout, tls, write, http_4xx, http_5xx or an HTTP status code such as http_429",
+		},
+		{
+			Description: "Invalid RetryPolicy strategy",
+			Input:       "RetryPolicy http_429=backoff:soon",
+			Err:         "`RetryPolicy': 'soon' is not a valid duration",
+		},
+		{
+			Description: "Invalid SelfCheckIP",
+			Input:       "SelfCheckIP checkip.amazonaws.com",
+			Err:         "`SelfCheckIP' must be an IP address or an http or https URL, got 'checkip.amazonaws.com'",
+		},
+		{
+			Description: "Invalid UnavailableEditionPolicy",
+			Input:       "UnavailableEditionPolicy archive",
+			Err:         "`UnavailableEditionPolicy' must be keep, delete or quarantine, got 'archive'",
+		},
 		{
 			Description: "RetryFor needs to be non-negative",
 			Input:       "RetryFor -5m",
@@ -568,6 +1167,35 @@ func TestSetConfigFromFile(t *testing.T) {
 			Input:       "Parallelism 0",
 			Err:         "parallelism should be greater than 0, got '0'",
 		},
+		{
+			Description: "Quoted values and comments at the end of lines",
+			Input: "AccountID 123 # the account\r\n" +
+				"DatabaseDirectory \"/var/lib/My GeoIP\"\r\n" +
+				"LockFile /var/lib/My\\ GeoIP/.lock  \r\n" +
+				"ProxyUserPassword 'user:pa\"ss#1'\r\n",
+			Expected: Config{
+				AccountID:         123,
+				DatabaseDirectory: filepath.Clean("/var/lib/My GeoIP"),
+				LockFile:          filepath.Clean("/var/lib/My GeoIP/.lock"),
+				proxyUserInfo:     `user:pa"ss#1`,
+			},
+		},
+		{
+			Description: "UTF-8 byte order mark and CRLF line endings",
+			Input:       "\ufeffAccountID 123\r\nLicenseKey 456\r\n",
+			Expected:    Config{AccountID: 123, LicenseKey: "456"},
+		},
+		{
+			Description: "UTF-16",
+			Input:       "\xff\xfeA\x00c\x00",
+			Err:         "the file is encoded in UTF-16; save it as UTF-8",
+		},
+		{
+			Description: "Unterminated quote",
+			Input:       "AccountID 123\nDatabaseDirectory \"/var/lib/GeoIP\n",
+			Expected:    Config{AccountID: 123},
+			Err:         "invalid format on line 2: unterminated quote",
+		},
 	}
 
 	for _, test := range tests {
@@ -588,6 +1216,153 @@ func TestSetConfigFromFile(t *testing.T) {
 	}
 }
 
+// TestSetConfigFromFileStrict tests that deprecated and unknown directives
+// are reported with their name and line number in strict mode.
+func TestSetConfigFromFileStrict(t *testing.T) {
+	tests := []struct {
+		Description string
+		Input       string
+		Err         string
+	}{
+		{
+			Description: "Valid config",
+			Input:       "AccountID 1\nEditionIDs GeoLite2-City\n",
+		},
+		{
+			Description: "Deprecated option",
+			Input:       "AccountID 1\nProtocol https\n",
+			Err:         "deprecated option `Protocol' on line 2",
+		},
+		{
+			Description: "Unknown option",
+			Input:       "AccountID 1\n\nEditionsIDs GeoLite2-City\n",
+			Err:         "unknown option `EditionsIDs' on line 3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			tempName := filepath.Join(t.TempDir(), "/GeoIP-test.conf")
+			require.NoError(t, os.WriteFile(tempName, []byte(test.Input), 0o600))
+
+			config := Config{strictConfig: true}
+
+			err := setConfigFromFile(&config, tempName)
+			if test.Err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.Err)
+			}
+		})
+	}
+}
+
+func TestSetConfigFromYAML(t *testing.T) {
+	tests := []struct {
+		Description string
+		Input       string
+		Expected    Config
+		Err         string
+	}{
+		{
+			Description: "Config file related variables",
+			Input: `account_id: 1
+license_key: "000000000001"
+edition_ids: [GeoLite2-Country, GeoLite2-City]
+database_directory: /tmp/db
+host: updates.maxmind.com
+preserve_file_times: true
+retry_for: 1m
+`,
+			Expected: Config{
+				AccountID:         1,
+				DatabaseDirectory: filepath.Clean("/tmp/db"),
+				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City"},
+				LicenseKey:        "000000000001",
+				PreserveFileTimes: true,
+				RetryFor:          1 * time.Minute,
+				URL:               "https://updates.maxmind.com",
+			},
+		},
+		{
+			Description: "Edition groups",
+			Input: `edition_groups:
+  geolite: [GeoLite2-ASN, GeoLite2-City]
+  paid: GeoIP2-*
+edition_ids: [geolite]
+`,
+			Expected: Config{
+				EditionGroups: map[string][]string{
+					"geolite": {"GeoLite2-ASN", "GeoLite2-City"},
+					"paid":    {"GeoIP2-*"},
+				},
+				EditionIDs: []string{"geolite"},
+			},
+		},
+		{
+			Description: "Edition groups not given as a mapping",
+			Input:       "edition_groups: [GeoLite2-ASN]",
+			Err:         "invalid `edition_groups' on line 1: expected a mapping",
+		},
+		{
+			Description: "Edition IDs as a string",
+			Input:       "edition_ids: GeoLite2-Country GeoLite2-City",
+			Expected: Config{
+				EditionIDs: []string{"GeoLite2-Country", "GeoLite2-City"},
+			},
+		},
+		{
+			Description: "Empty config",
+			Input:       "",
+			Expected:    Config{},
+		},
+		{
+			Description: "Unknown option",
+			Input:       "account_id: 1\nEditionIDs: GeoLite2-City",
+			Expected:    Config{AccountID: 1},
+			Err:         "unknown option `EditionIDs' on line 2",
+		},
+		{
+			Description: "Repeated option",
+			Input:       "account_id: 1\naccount_id: 2",
+			Expected:    Config{AccountID: 1},
+			Err:         "`account_id' is in the config multiple times",
+		},
+		{
+			Description: "Invalid boolean",
+			Input:       "preserve_file_times: 2",
+			Err:         "invalid `preserve_file_times' on line 1: expected true or false",
+		},
+		{
+			Description: "Missing value",
+			Input:       "database_directory:",
+			Err:         "invalid `database_directory' on line 1: expected a value",
+		},
+		{
+			Description: "Invalid value",
+			Input:       "retry_for: 5",
+			Err:         "'5' is not a valid duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			tempName := filepath.Join(t.TempDir(), "geoipupdate.yaml")
+			require.NoError(t, os.WriteFile(tempName, []byte(test.Input), 0o600))
+
+			var config Config
+
+			err := setConfigFromFile(&config, tempName)
+			if test.Err == "" {
+				require.NoError(t, err, test.Description)
+			} else {
+				require.EqualError(t, err, test.Err, test.Description)
+			}
+			assert.Equal(t, test.Expected, config, test.Description)
+		})
+	}
+}
+
 func TestSetConfigFromEnv(t *testing.T) {
 	tests := []struct {
 		Description            string
@@ -600,34 +1375,153 @@ func TestSetConfigFromEnv(t *testing.T) {
 		{
 			Description: "All config related environment variables",
 			Env: map[string]string{
-				"GEOIPUPDATE_ACCOUNT_ID":          "1",
-				"GEOIPUPDATE_ACCOUNT_ID_FILE":     "",
-				"GEOIPUPDATE_DB_DIR":              "/tmp/db",
-				"GEOIPUPDATE_EDITION_IDS":         "GeoLite2-Country GeoLite2-City",
-				"GEOIPUPDATE_HOST":                "updates.maxmind.com",
-				"GEOIPUPDATE_LICENSE_KEY":         "000000000001",
-				"GEOIPUPDATE_LICENSE_KEY_FILE":    "",
-				"GEOIPUPDATE_LOCK_FILE":           "/tmp/lock",
-				"GEOIPUPDATE_PARALLELISM":         "2",
-				"GEOIPUPDATE_PRESERVE_FILE_TIMES": "1",
-				"GEOIPUPDATE_PROXY":               "127.0.0.1:8888",
-				"GEOIPUPDATE_PROXY_USER_PASSWORD": "username:password",
-				"GEOIPUPDATE_RETRY_FOR":           "1m",
-				"GEOIPUPDATE_VERBOSE":             "1",
+				"GEOIPUPDATE_ACCOUNT_ID":                 "1",
+				"GEOIPUPDATE_ACCOUNT_ID_FILE":            "",
+				"GEOIPUPDATE_ALERT_AFTER_FAILURES":       "2",
+				"GEOIPUPDATE_ARCHIVE_DIR":                "/tmp/archive",
+				"GEOIPUPDATE_ARCHIVE_S3":                 "s3://geoip-archive",
+				"GEOIPUPDATE_ARCHIVE_S3_STORAGE_CLASS":   "GLACIER_IR",
+				"GEOIPUPDATE_CACHE_MAX_AGE":              "1h",
+				"GEOIPUPDATE_CHECKSUM_FORENSICS":         "1",
+				"GEOIPUPDATE_CONSISTENT_SET":             "1",
+				"GEOIPUPDATE_CONSUMER_LOCK_TIMEOUT":      "30s",
+				"GEOIPUPDATE_DB_DIR":                     "/tmp/db",
+				"GEOIPUPDATE_DESTINATION":                "azblob://geoipfleet/databases",
+				"GEOIPUPDATE_DESTINATION_ENCODING":       "gzip",
+				"GEOIPUPDATE_DISABLE_SELF_UPDATE":        "1",
+				"GEOIPUPDATE_EDITION_IDS":                "GeoLite2-Country GeoLite2-City",
+				"GEOIPUPDATE_EDITION_ORDER":              "smallest-first",
+				"GEOIPUPDATE_ENCRYPTION_KEY_FILE":        "/tmp/encryption.key",
+				"GEOIPUPDATE_EXCLUDE_EDITION_IDS":        "GeoLite2-City",
+				"GEOIPUPDATE_EXPECTED_CADENCE":           "GeoLite2-Country=weekly",
+				"GEOIPUPDATE_FAIL_FAST_THRESHOLD":        "2",
+				"GEOIPUPDATE_HOST":                       "updates.maxmind.com",
+				"GEOIPUPDATE_HOST_AUTH":                  "mirror.example.com=header:X-Api-Key:secret",
+				"GEOIPUPDATE_INTEGRITY_FILE":             "/tmp/databases.mtree",
+				"GEOIPUPDATE_LABELS":                     "env=staging",
+				"GEOIPUPDATE_LAYER_FILE":                 "/tmp/databases.tar",
+				"GEOIPUPDATE_LICENSE_KEY":                "000000000001",
+				"GEOIPUPDATE_LICENSE_KEY_FILE":           "",
+				"GEOIPUPDATE_LOCK_FILE":                  "/tmp/lock",
+				"GEOIPUPDATE_LOCK_TYPE":                  "mtime",
+				"GEOIPUPDATE_MAX_DECOMPRESSED_SIZE":      "1073741824",
+				"GEOIPUPDATE_MAX_DISK_USAGE":             "8g",
+				"GEOIPUPDATE_METADATA_CACHE_TTL":         "5m",
+				"GEOIPUPDATE_METRICS_FILE":               "/tmp/geoipupdate.prom",
+				"GEOIPUPDATE_MIDDLEWARE":                 "throttle:512k",
+				"GEOIPUPDATE_MIN_UPDATE_INTERVAL":        "GeoIP2-ISP=168h",
+				"GEOIPUPDATE_NOTIFY":                     "arn:aws:sns:us-east-1:123456789012:geoip",
+				"GEOIPUPDATE_OCI_PUSH":                   "registry.example.com/geoip",
+				"GEOIPUPDATE_OCI_PUSH_ENCODING":          "gzip",
+				"GEOIPUPDATE_OCI_PUSH_VERIFY":            "1",
+				"GEOIPUPDATE_OUTPUT_FORMAT":              "report",
+				"GEOIPUPDATE_PARALLELISM":                "2",
+				"GEOIPUPDATE_PEERS":                      "http://seed-1:8080",
+				"GEOIPUPDATE_PID_FILE":                   "/tmp/geoipupdate.pid",
+				"GEOIPUPDATE_POST_UPDATE_COMMAND":        "/usr/local/bin/mmdb2dat",
+				"GEOIPUPDATE_PRESERVE_FILE_TIMES":        "1",
+				"GEOIPUPDATE_PROXY":                      "127.0.0.1:8888",
+				"GEOIPUPDATE_PROXY_USER_PASSWORD":        "username:password",
+				"GEOIPUPDATE_REPORT_TOKEN":               "secret",
+				"GEOIPUPDATE_REPORT_URL":                 "https://seed.example.com/reports",
+				"GEOIPUPDATE_RETRY_FOR":                  "1m",
+				"GEOIPUPDATE_RETRY_POLICY":               "http_5xx=never",
+				"GEOIPUPDATE_RUN_AS_GROUP":               "65534",
+				"GEOIPUPDATE_RUN_AS_USER":                "65534",
+				"GEOIPUPDATE_RUN_MODE":                   "daemon",
+				"GEOIPUPDATE_RUN_TIMEOUT":                "20m",
+				"GEOIPUPDATE_S3_MIRROR":                  "s3://geoip-mirror",
+				"GEOIPUPDATE_S3_PUSH":                    "s3://geoip-edge/",
+				"GEOIPUPDATE_S3_PUSH_ENCODING":           "none",
+				"GEOIPUPDATE_S3_REGION":                  "eu-west-1",
+				"GEOIPUPDATE_SANDBOX":                    "1",
+				"GEOIPUPDATE_SCHEDULE":                   "24h",
+				"GEOIPUPDATE_SCHEDULE_JITTER":            "30m",
+				"GEOIPUPDATE_SELF_CHECK_IP":              "2001:db8::1",
+				"GEOIPUPDATE_SKIP_IF_RUNNING":            "1",
+				"GEOIPUPDATE_STATE_FILE":                 "/tmp/state",
+				"GEOIPUPDATE_TEMP_DIR":                   "/tmp/staging",
+				"GEOIPUPDATE_TRACE_FILE":                 "/tmp/trace.jsonl",
+				"GEOIPUPDATE_UNAVAILABLE_EDITION_POLICY": "quarantine",
+				"GEOIPUPDATE_VERBOSE":                    "1",
+				"GEOIPUPDATE_WRITE_RETRY_FOR":            "2m",
+				"GEOIPUPDATE_WRITE_STRATEGY":             "copy",
 			},
 			Expected: Config{
-				AccountID:         1,
-				DatabaseDirectory: "/tmp/db",
-				EditionIDs:        []string{"GeoLite2-Country", "GeoLite2-City"},
-				LicenseKey:        "000000000001",
-				LockFile:          "/tmp/lock",
-				Parallelism:       2,
-				PreserveFileTimes: true,
-				proxyURL:          "127.0.0.1:8888",
-				proxyUserInfo:     "username:password",
-				RetryFor:          1 * time.Minute,
-				URL:               "https://updates.maxmind.com",
-				Verbose:           true,
+				AccountID:             1,
+				AlertAfterFailures:    2,
+				ArchiveDirectory:      "/tmp/archive",
+				ArchiveS3:             "s3://geoip-archive",
+				ArchiveS3StorageClass: "GLACIER_IR",
+				CacheMaxAge:           time.Hour,
+				ChecksumForensics:     true,
+				ConsistentSet:         true,
+				ConsumerLockTimeout:   30 * time.Second,
+				DatabaseDirectory:     "/tmp/db",
+				Destination:           "azblob://geoipfleet/databases",
+				DestinationEncoding:   "gzip",
+				DisableSelfUpdate:     true,
+				EditionIDs:            []string{"GeoLite2-Country", "GeoLite2-City"},
+				EditionOrder:          "smallest-first",
+				EncryptionKeyFile:     "/tmp/encryption.key",
+				ExcludeEditionIDs:     []string{"GeoLite2-City"},
+				ExpectedCadence: map[string]Cadence{
+					"GeoLite2-Country": {Every: 7 * 24 * time.Hour},
+				},
+				FailFastThreshold: 2,
+				HostAuth: map[string]HostAuth{
+					"mirror.example.com": {Scheme: "header", Name: "X-Api-Key", Value: "secret"},
+				},
+				IntegrityFile:            "/tmp/databases.mtree",
+				Labels:                   map[string]string{"env": "staging"},
+				LayerFile:                "/tmp/databases.tar",
+				LicenseKey:               "000000000001",
+				LockFile:                 "/tmp/lock",
+				LockType:                 "mtime",
+				MaxDecompressedSize:      1 << 30,
+				MaxDiskUsage:             8 << 30,
+				MetadataCacheTTL:         5 * time.Minute,
+				Middleware:               []string{"throttle:512k"},
+				MetricsFile:              "/tmp/geoipupdate.prom",
+				MinUpdateInterval:        map[string]time.Duration{"GeoIP2-ISP": 168 * time.Hour},
+				Notify:                   []string{"arn:aws:sns:us-east-1:123456789012:geoip"},
+				OCIPush:                  "registry.example.com/geoip",
+				OCIPushEncoding:          "gzip",
+				OCIPushVerify:            true,
+				OutputFormat:             "report",
+				Parallelism:              2,
+				Peers:                    []string{"http://seed-1:8080"},
+				PIDFile:                  "/tmp/geoipupdate.pid",
+				PostUpdateCommand:        "/usr/local/bin/mmdb2dat",
+				PreserveFileTimes:        true,
+				proxyURL:                 "127.0.0.1:8888",
+				proxyUserInfo:            "username:password",
+				ReportToken:              "secret",
+				ReportURL:                "https://seed.example.com/reports",
+				RetryFor:                 1 * time.Minute,
+				RetryPolicy:              map[string]RetryStrategy{"http_5xx": {Kind: "never"}},
+				RunAsGroup:               "65534",
+				RunAsUser:                "65534",
+				RunMode:                  RunModeDaemon,
+				RunTimeout:               20 * time.Minute,
+				S3Mirror:                 "s3://geoip-mirror",
+				S3Push:                   "s3://geoip-edge",
+				S3PushEncoding:           "none",
+				S3Region:                 "eu-west-1",
+				Sandbox:                  true,
+				Schedule:                 Schedule{Every: 24 * time.Hour},
+				ScheduleJitter:           30 * time.Minute,
+				SelfCheckIP:              "2001:db8::1",
+				SkipIfRunning:            true,
+				StateFile:                "/tmp/state",
+				TempDirectory:            "/tmp/staging",
+				TraceFile:                "/tmp/trace.jsonl",
+				UnavailableEditionPolicy: "quarantine",
+				URL:                      "https://updates.maxmind.com",
+				Verbose:                  true,
+				WriteRetryFor:            2 * time.Minute,
+				writeRetryForSet:         true,
+				WriteStrategy:            "copy",
 			},
 		},
 		{
@@ -795,13 +1689,17 @@ func TestSetConfigFromFlags(t *testing.T) {
 			Description: "All option flag related config set",
 			Flags: []Option{
 				WithDatabaseDirectory("/tmp/db"),
+				WithEditionIDs([]string{"GeoLite2-City"}),
 				WithOutput,
 				WithParallelism(2),
+				WithStrictConfig,
 				WithVerbose,
 			},
 			Expected: Config{
 				DatabaseDirectory: filepath.Clean("/tmp/db"),
+				EditionIDs:        []string{"GeoLite2-City"},
 				Output:            true,
+				strictConfig:      true,
 				Parallelism:       2,
 				Verbose:           true,
 			},
@@ -873,6 +1771,61 @@ func TestValidateConfig(t *testing.T) {
 			},
 			Err: "the `LicenseKey` option is required",
 		},
+		{
+			Description: "AccountID and LicenseKey not required with S3Mirror",
+			Config: Config{
+				EditionIDs: []string{"GeoLite2-Country", "GeoLite2-City"},
+				S3Mirror:   "s3://geoip-mirror",
+			},
+		},
+		{
+			Description: "AccountID and LicenseKey not required with OCIMirror",
+			Config: Config{
+				EditionIDs: []string{"GeoLite2-Country", "GeoLite2-City"},
+				OCIMirror:  "registry.example.com/geoip",
+			},
+		},
+		{
+			Description: "EncryptionKeyFile and EncryptionKMS are exclusive",
+			Config: Config{
+				AccountID:         1,
+				LicenseKey:        "000000000001",
+				EditionIDs:        []string{"GeoLite2-Country"},
+				EncryptionKeyFile: "/tmp/encryption.key",
+				EncryptionKMS:     "vault://vault.example.com/transit/geoip",
+			},
+			Err: "only one of `EncryptionKeyFile` and `EncryptionKMS` can be set",
+		},
+		{
+			Description: "Destination can't be set with encryption",
+			Config: Config{
+				AccountID:     1,
+				LicenseKey:    "000000000001",
+				EditionIDs:    []string{"GeoLite2-Country"},
+				Destination:   "gs://geoip-fleet",
+				EncryptionKMS: "vault://vault.example.com/transit/geoip",
+			},
+			Err: "`Destination` can't be set with `EncryptionKeyFile` or `EncryptionKMS`, " +
+				"as the databases written to it aren't encrypted",
+		},
+		{
+			Description: "S3Mirror and OCIMirror are exclusive",
+			Config: Config{
+				EditionIDs: []string{"GeoLite2-Country", "GeoLite2-City"},
+				OCIMirror:  "registry.example.com/geoip",
+				S3Mirror:   "s3://geoip-mirror",
+			},
+			Err: "only one of `S3Mirror` and `OCIMirror` can be set",
+		},
+		{
+			Description: "S3Push can't be the S3Mirror",
+			Config: Config{
+				EditionIDs: []string{"GeoLite2-Country"},
+				S3Mirror:   "s3://geoip-mirror",
+				S3Push:     "s3://geoip-mirror",
+			},
+			Err: "`S3Push` can't be the `S3Mirror` databases are downloaded from",
+		},
 		{
 			Description: "Valid AccountID + LicenseKey combination",
 			Config: Config{
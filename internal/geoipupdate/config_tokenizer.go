@@ -0,0 +1,116 @@
+package geoipupdate
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	errUnterminatedQuote = errors.New("unterminated quote")
+	errMissingValue      = errors.New("missing value")
+)
+
+// parseConfigLine parses a line of a configuration file in the GeoIP.conf
+// format into the name of its directive and its value, the other tokens of
+// the line joined with single spaces. It returns an empty key for lines
+// without a directive, e.g., comments.
+func parseConfigLine(line string) (key, value string, err error) {
+	tokens, err := tokenizeConfigLine(line)
+	if err != nil {
+		return "", "", err
+	}
+	switch len(tokens) {
+	case 0:
+		return "", "", nil
+	case 1:
+		return "", "", errMissingValue
+	default:
+		return tokens[0], strings.Join(tokens[1:], " "), nil
+	}
+}
+
+// tokenizeConfigLine splits line into whitespace-separated tokens, the way
+// a shell would, so that values such as paths can hold spaces:
+//
+//   - Text between single quotes is taken literally.
+//   - Text between double quotes is taken literally, except for \" which is
+//     a double quote.
+//   - Outside of quotes, a backslash followed by whitespace, a quote, or #
+//     escapes it. Other backslashes are kept, so that Windows paths such as
+//     C:\GeoIP and \\server\share need no escaping.
+//   - A # at the start of a token starts a comment running to the end of
+//     the line, so that # can still be part of values, e.g., of passwords.
+//
+// Carriage returns are whitespace, so files with CRLF line endings are read
+// like others.
+func tokenizeConfigLine(line string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	// inToken is true once the current token has started, which it may
+	// have while still being empty, e.g., with "".
+	inToken := false
+	endToken := func() {
+		if inToken {
+			tokens = append(tokens, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case isConfigSpace(c):
+			endToken()
+		case c == '#' && !inToken:
+			return tokens, nil
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errUnterminatedQuote
+			}
+			token.WriteString(line[i+1 : i+1+end])
+			inToken = true
+			i += end + 1
+		case c == '"':
+			inToken = true
+			for i++; ; i++ {
+				if i >= len(line) {
+					return nil, errUnterminatedQuote
+				}
+				if line[i] == '"' {
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '"' {
+					i++
+				}
+				token.WriteByte(line[i])
+			}
+		case c == '\\' && i+1 < len(line) && isConfigEscapable(line[i+1]):
+			i++
+			token.WriteByte(line[i])
+			inToken = true
+		default:
+			token.WriteByte(c)
+			inToken = true
+		}
+	}
+	endToken()
+	return tokens, nil
+}
+
+// isConfigSpace returns whether c separates the tokens of configuration
+// lines.
+func isConfigSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\v', '\f':
+		return true
+	default:
+		return false
+	}
+}
+
+// isConfigEscapable returns whether a backslash escapes c outside of quotes.
+func isConfigEscapable(c byte) bool {
+	return isConfigSpace(c) || c == '#' || c == '"' || c == '\''
+}
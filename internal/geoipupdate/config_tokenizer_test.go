@@ -0,0 +1,159 @@
+package geoipupdate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeConfigLine(t *testing.T) {
+	tests := []struct {
+		description string
+		line        string
+		expected    []string
+		err         error
+	}{
+		{
+			description: "blank",
+			line:        " \t\r",
+		},
+		{
+			description: "comment",
+			line:        "  # DatabaseDirectory /var/lib/GeoIP",
+		},
+		{
+			description: "CRLF and trailing spaces",
+			line:        "AccountID 123  \t\r",
+			expected:    []string{"AccountID", "123"},
+		},
+		{
+			description: "comment at the end of the line",
+			line:        "EditionIDs GeoLite2-City GeoLite2-ASN # the free ones",
+			expected:    []string{"EditionIDs", "GeoLite2-City", "GeoLite2-ASN"},
+		},
+		{
+			description: "# within a value",
+			line:        "ProxyUserPassword user:pa#ss",
+			expected:    []string{"ProxyUserPassword", "user:pa#ss"},
+		},
+		{
+			description: "double quotes",
+			line:        `DatabaseDirectory "C:\Program Files\GeoIP"`,
+			expected:    []string{"DatabaseDirectory", `C:\Program Files\GeoIP`},
+		},
+		{
+			description: "escaped double quote",
+			line:        `ProxyUserPassword "user:pa\"ss"`,
+			expected:    []string{"ProxyUserPassword", `user:pa"ss`},
+		},
+		{
+			description: "single quotes",
+			line:        `DatabaseDirectory 'C:\GeoIP\' # trailing backslash`,
+			expected:    []string{"DatabaseDirectory", `C:\GeoIP\`},
+		},
+		{
+			description: "escaped spaces",
+			line:        `LockFile /var/lib/My\ GeoIP/.lock`,
+			expected:    []string{"LockFile", "/var/lib/My GeoIP/.lock"},
+		},
+		{
+			description: "escaped #",
+			line:        `ProxyUserPassword user: \#secret`,
+			expected:    []string{"ProxyUserPassword", "user:", "#secret"},
+		},
+		{
+			description: "UNC path",
+			line:        `DatabaseDirectory \\server\share\GeoIP`,
+			expected:    []string{"DatabaseDirectory", `\\server\share\GeoIP`},
+		},
+		{
+			description: "adjacent quotes",
+			line:        `PostUpdateCommand a"b c"'d'`,
+			expected:    []string{"PostUpdateCommand", "ab cd"},
+		},
+		{
+			description: "empty quotes",
+			line:        `Proxy ""`,
+			expected:    []string{"Proxy", ""},
+		},
+		{
+			description: "unterminated double quote",
+			line:        `DatabaseDirectory "C:\GeoIP`,
+			err:         errUnterminatedQuote,
+		},
+		{
+			description: "unterminated single quote",
+			line:        `DatabaseDirectory 'C:\GeoIP`,
+			err:         errUnterminatedQuote,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tokens, err := tokenizeConfigLine(test.line)
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, tokens)
+		})
+	}
+}
+
+// quoteConfigToken returns token quoted so that tokenizeConfigLine reads it
+// back as is.
+func quoteConfigToken(token string) string {
+	parts := strings.Split(token, "'")
+	for i, part := range parts {
+		parts[i] = "'" + part + "'"
+	}
+	return strings.Join(parts, `\'`)
+}
+
+func FuzzTokenizeConfigLine(f *testing.F) {
+	for _, seed := range []string{
+		"AccountID 123\r",
+		"EditionIDs GeoLite2-City GeoLite2-ASN # comment",
+		`DatabaseDirectory "C:\Program Files\GeoIP"`,
+		`LockFile /var/lib/My\ GeoIP/.lock`,
+		`ProxyUserPassword 'user:pa"ss' \#`,
+		`Proxy ""`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		tokens, err := tokenizeConfigLine(line)
+		if err != nil {
+			return
+		}
+
+		quoted := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			quoted = append(quoted, quoteConfigToken(token))
+		}
+		again, err := tokenizeConfigLine(strings.Join(quoted, " "))
+		require.NoError(t, err)
+		assert.Equal(t, tokens, again)
+	})
+}
+
+// FuzzSetConfigFromGeoIPConf tests that no configuration file makes the
+// parsers panic.
+func FuzzSetConfigFromGeoIPConf(f *testing.F) {
+	for _, seed := range []string{
+		"AccountID 123\r\nLicenseKey 456\r\nEditionIDs GeoLite2-City\r\n",
+		"EditionGroup geolite GeoLite2-ASN\nEditionIDs geolite # comment\n",
+		"DatabaseDirectory \"/var/lib/My GeoIP\"\nLockFile '/tmp/lock'\n",
+		"EditionDependency GeoIP2-City GeoIP2-ISP\nMinUpdateInterval GeoIP2-ISP=720h\n",
+		"PostUpdateCommand \"\"\nProxy \"\"\nParallelism ''\n",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(_ *testing.T, content string) {
+		_ = setConfigFromGeoIPConf(&Config{}, strings.NewReader(content)) //nolint:errcheck // only panics matter
+		_, _, _ = MigrateConfig(strings.NewReader(content))               //nolint:errcheck // only panics matter
+	})
+}
@@ -0,0 +1,259 @@
+package geoipupdate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// directiveKind is how the value of a directive is represented in YAML.
+type directiveKind int
+
+const (
+	kindString directiveKind = iota
+	kindInt
+	kindBool
+	kindList
+	// kindMap is a mapping of names to lists, for directives that can be
+	// repeated, one line per name.
+	kindMap
+)
+
+// yamlDirective maps a key of the YAML configuration format to the
+// equivalent GeoIP.conf directive.
+type yamlDirective struct {
+	key       string
+	directive string
+	kind      directiveKind
+}
+
+// yamlDirectives lists the keys of the YAML configuration format in the
+// order they are written in.
+var yamlDirectives = []yamlDirective{
+	{"account_id", "AccountID", kindInt},
+	{"license_key", "LicenseKey", kindString},
+	{"edition_groups", "EditionGroup", kindMap},
+	{"edition_dependencies", "EditionDependency", kindMap},
+	{"edition_ids", "EditionIDs", kindList},
+	{"edition_order", "EditionOrder", kindString},
+	{"encryption_key_file", "EncryptionKeyFile", kindString},
+	{"encryption_kms", "EncryptionKMS", kindString},
+	{"exclude_edition_ids", "ExcludeEditionIDs", kindList},
+	{"database_directory", "DatabaseDirectory", kindString},
+	{"database_directories", "DatabaseDirectories", kindList},
+	{"host", "Host", kindString},
+	{"host_auth", "HostAuth", kindList},
+	{"proxy", "Proxy", kindString},
+	{"proxy_user_password", "ProxyUserPassword", kindString},
+	{"preserve_file_times", "PreserveFileTimes", kindBool},
+	{"lock_file", "LockFile", kindString},
+	{"lock_type", "LockType", kindString},
+	{"state_file", "StateFile", kindString},
+	{"pid_file", "PIDFile", kindString},
+	{"run_as_user", "RunAsUser", kindString},
+	{"run_as_group", "RunAsGroup", kindString},
+	{"sandbox", "Sandbox", kindBool},
+	{"parallelism", "Parallelism", kindInt},
+	{"retry_for", "RetryFor", kindString},
+	{"retry_policy", "RetryPolicy", kindList},
+	{"write_retry_for", "WriteRetryFor", kindString},
+	{"run_timeout", "RunTimeout", kindString},
+	{"run_mode", "RunMode", kindString},
+	{"schedule", "Schedule", kindString},
+	{"schedule_jitter", "ScheduleJitter", kindString},
+	{"fail_fast_threshold", "FailFastThreshold", kindInt},
+	{"skip_if_running", "SkipIfRunning", kindBool},
+	{"archive_directory", "ArchiveDirectory", kindString},
+	{"archive_s3", "ArchiveS3", kindString},
+	{"archive_s3_storage_class", "ArchiveS3StorageClass", kindString},
+	{"consumer_lock_timeout", "ConsumerLockTimeout", kindString},
+	{"cache_max_age", "CacheMaxAge", kindString},
+	{"middleware", "Middleware", kindList},
+	{"min_update_interval", "MinUpdateInterval", kindList},
+	{"metadata_cache_ttl", "MetadataCacheTTL", kindString},
+	{"checksum_forensics", "ChecksumForensics", kindBool},
+	{"consistent_set", "ConsistentSet", kindBool},
+	{"max_decompressed_size", "MaxDecompressedSize", kindString},
+	{"max_disk_usage", "MaxDiskUsage", kindString},
+	{"write_strategy", "WriteStrategy", kindString},
+	{"temp_directory", "TempDirectory", kindString},
+	{"trace_file", "TraceFile", kindString},
+	{"unavailable_edition_policy", "UnavailableEditionPolicy", kindString},
+	{"disable_self_update", "DisableSelfUpdate", kindBool},
+	{"output_format", "OutputFormat", kindString},
+	{"metrics_file", "MetricsFile", kindString},
+	{"layer_file", "LayerFile", kindString},
+	{"integrity_file", "IntegrityFile", kindString},
+	{"destination", "Destination", kindString},
+	{"destination_encoding", "DestinationEncoding", kindString},
+	{"s3_mirror", "S3Mirror", kindString},
+	{"s3_push", "S3Push", kindString},
+	{"s3_push_encoding", "S3PushEncoding", kindString},
+	{"s3_region", "S3Region", kindString},
+	{"oci_mirror", "OCIMirror", kindString},
+	{"oci_push", "OCIPush", kindString},
+	{"oci_push_encoding", "OCIPushEncoding", kindString},
+	{"oci_push_verify", "OCIPushVerify", kindBool},
+	{"peers", "Peers", kindList},
+	{"notify", "Notify", kindList},
+	{"alert_after_failures", "AlertAfterFailures", kindInt},
+	{"expected_cadence", "ExpectedCadence", kindList},
+	{"labels", "Labels", kindList},
+	{"report_token", "ReportToken", kindString},
+	{"report_url", "ReportURL", kindString},
+	{"post_update_command", "PostUpdateCommand", kindString},
+	{"self_check_ip", "SelfCheckIP", kindString},
+}
+
+// isYAMLConfig returns whether the configuration file at path uses the YAML
+// format rather than the GeoIP.conf one.
+func isYAMLConfig(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// setConfigFromYAML sets Config fields based on a YAML configuration file.
+func setConfigFromYAML(config *Config, r io.Reader) error {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("parsing YAML: %w", err)
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected a mapping on line %d", root.Line)
+	}
+
+	keysSeen := map[string]struct{}{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, node := root.Content[i], root.Content[i+1]
+
+		d, ok := yamlDirectiveFor(key.Value)
+		if !ok {
+			return fmt.Errorf("unknown option `%s' on line %d", key.Value, key.Line)
+		}
+
+		if _, ok := keysSeen[d.key]; ok {
+			return fmt.Errorf("`%s' is in the config multiple times", d.key)
+		}
+		keysSeen[d.key] = struct{}{}
+
+		value, err := d.value(node)
+		if err != nil {
+			return fmt.Errorf("invalid `%s' on line %d: %w", d.key, node.Line, err)
+		}
+
+		for _, line := range strings.Split(value, "\n") {
+			if err := setConfigFromDirective(config, d.directive, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func yamlDirectiveFor(key string) (yamlDirective, bool) {
+	for _, d := range yamlDirectives {
+		if d.key == key {
+			return d, true
+		}
+	}
+	return yamlDirective{}, false
+}
+
+// value returns the value of node in the GeoIP.conf format. The value of a
+// kindMap directive has one line per entry.
+func (d yamlDirective) value(node *yaml.Node) (string, error) {
+	if d.kind == kindMap {
+		if node.Kind != yaml.MappingNode {
+			return "", errors.New("expected a mapping")
+		}
+		lines := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i]
+			members, err := yamlDirective{kind: kindList}.value(node.Content[i+1])
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", name.Value, err)
+			}
+			lines = append(lines, name.Value+" "+members)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	if d.kind == kindList && node.Kind == yaml.SequenceNode {
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", errors.New("expected a list of strings")
+			}
+			values = append(values, item.Value)
+		}
+		return strings.Join(values, " "), nil
+	}
+
+	if node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return "", errors.New("expected a value")
+	}
+
+	if d.kind == kindBool {
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return "", errors.New("expected true or false")
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	}
+
+	return node.Value, nil
+}
+
+// yamlNode returns the YAML representation of a value in the GeoIP.conf
+// format.
+func (d yamlDirective) yamlNode(value string) *yaml.Node {
+	switch d.kind {
+	case kindInt:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	case kindBool:
+		b := "false"
+		if value == "1" {
+			b = "true"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: b}
+	case kindList:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, v := range strings.Fields(value) {
+			node.Content = append(
+				node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v},
+			)
+		}
+		return node
+	case kindMap:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, line := range strings.Split(value, "\n") {
+			name, members, _ := strings.Cut(line, " ")
+			node.Content = append(
+				node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+				yamlDirective{kind: kindList}.yamlNode(members),
+			)
+		}
+		return node
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	}
+}
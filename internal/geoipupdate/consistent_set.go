@@ -0,0 +1,153 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// stagingDirectory is the name of the directory of the DatabaseDirectory the
+// databases are updated in with ConsistentSet.
+const stagingDirectory = ".geoipupdate.staging"
+
+// stagedSet updates the databases in a staging directory of the
+// DatabaseDirectory, and only installs them once every edition of the run
+// succeeded, so that consumers never see the new build of one edition
+// along with the old build of another one that failed to update. Each run
+// starts with hard links to the installed databases, which the writer
+// replaces rather than modifies, so only the databases that changed are
+// moved into place.
+type stagedSet struct {
+	dir        string
+	stagingDir string
+	installed  *database.LocalFileWriter
+	// installedWriter and stagingWriter are the writers of dir and
+	// stagingDir, wrapped by the writer middlewares.
+	installedWriter database.Writer
+	stagingWriter   database.Writer
+	verbose         bool
+	// running is true during runs.
+	running bool
+}
+
+// newStagedSet returns the stagedSet of config, writing to each directory
+// with the writer returned by newWriter wrapped by middlewares.
+func newStagedSet(
+	config *Config,
+	newWriter func(dir string) (*database.LocalFileWriter, error),
+	middlewares []database.WriterMiddleware,
+) (*stagedSet, error) {
+	s := &stagedSet{
+		dir:        config.DatabaseDirectory,
+		stagingDir: filepath.Join(config.DatabaseDirectory, stagingDirectory),
+		verbose:    config.Verbose,
+	}
+	installed, err := newWriter(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	staging, err := newWriter(s.stagingDir)
+	if err != nil {
+		return nil, err
+	}
+	s.installed = installed
+	s.installedWriter = database.ChainWriter(installed, middlewares...)
+	s.stagingWriter = database.ChainWriter(staging, middlewares...)
+	return s, nil
+}
+
+func (s *stagedSet) current() string {
+	if s.running {
+		return s.stagingDir
+	}
+	return s.dir
+}
+
+func (s *stagedSet) writer() database.Writer {
+	if s.running {
+		return s.stagingWriter
+	}
+	return s.installedWriter
+}
+
+// begin links the installed databases of editionIDs into the staging
+// directory.
+func (s *stagedSet) begin(ctx context.Context, editionIDs []string) error {
+	if err := os.MkdirAll(s.stagingDir, 0o750); err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+	for _, editionID := range editionIDs {
+		err := linkDatabase(
+			ctx,
+			database.FilePath(s.dir, editionID),
+			database.FilePath(s.stagingDir, editionID),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	s.running = true
+	return nil
+}
+
+func (s *stagedSet) end() {
+	s.running = false
+}
+
+// publish installs the databases of the run once every database that
+// changed is valid.
+func (s *stagedSet) publish(ctx context.Context, editions []database.ReadResult) error {
+	var editionIDs []string
+	for _, edition := range editions {
+		editionIDs = append(editionIDs, edition.EditionID)
+		if edition.NewHash == edition.OldHash || edition.Unavailable {
+			continue
+		}
+		path := database.FilePath(s.stagingDir, edition.EditionID)
+		if err := database.Validate(path); err != nil {
+			return fmt.Errorf("validating %s before publishing the set: %w", path, err)
+		}
+	}
+	if err := s.installed.Publish(ctx, s.stagingDir, editionIDs); err != nil {
+		return err
+	}
+	if s.verbose {
+		log.Printf("Published the databases of %s", s.stagingDir)
+	}
+	return nil
+}
+
+// linkDatabase makes the database at dst a hard link to the one at src, or a
+// copy of it if hard links aren't supported. It is removed if there is no
+// database at src.
+func linkDatabase(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	srcInfo, err := os.Stat(src)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing %s: %w", dst, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", src, err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err == nil && os.SameFile(srcInfo, dstInfo) {
+		return nil
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", dst, err)
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return syncDatabase(ctx, src, dst)
+}
@@ -0,0 +1,94 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestStagedSet(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := &Config{DatabaseDirectory: dir, ConsistentSet: true}
+	s, err := newStagedSet(
+		config,
+		func(dir string) (*database.LocalFileWriter, error) {
+			return database.NewLocalFileWriter(dir, false, false)
+		},
+		nil,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, dir, s.current())
+
+	editionIDs := []string{"GeoLite2-City", "GeoLite2-ASN"}
+	oldCity := emptyMMDB(t, 1)
+	for _, editionID := range editionIDs {
+		require.NoError(t, os.WriteFile(database.FilePath(dir, editionID), oldCity, 0o600))
+	}
+
+	// A run that fails installs nothing.
+	require.NoError(t, s.begin(ctx, editionIDs))
+	stagingDir := filepath.Join(dir, stagingDirectory)
+	assert.Equal(t, stagingDir, s.current())
+	newCity := emptyMMDB(t, 2)
+	writeTestDatabase(t, s.writer(), "GeoLite2-City", newCity)
+	s.end()
+	assert.Equal(t, dir, s.current())
+	assertDatabase(t, database.FilePath(dir, "GeoLite2-City"), oldCity)
+
+	// A run that succeeds installs the databases that changed.
+	require.NoError(t, s.begin(ctx, editionIDs))
+	assertDatabase(t, database.FilePath(stagingDir, "GeoLite2-City"), oldCity)
+	writeTestDatabase(t, s.writer(), "GeoLite2-City", newCity)
+	require.NoError(t, s.publish(ctx, []database.ReadResult{
+		{EditionID: "GeoLite2-City", OldHash: "old", NewHash: "new"},
+		{EditionID: "GeoLite2-ASN", OldHash: "asn", NewHash: "asn"},
+	}))
+	s.end()
+	assertDatabase(t, database.FilePath(dir, "GeoLite2-City"), newCity)
+	assertDatabase(t, database.FilePath(dir, "GeoLite2-ASN"), oldCity)
+
+	// The databases deleted in the staging directory are deleted once the
+	// run succeeds.
+	require.NoError(t, s.begin(ctx, editionIDs))
+	require.NoError(t, os.Remove(database.FilePath(stagingDir, "GeoLite2-ASN")))
+	assert.FileExists(t, database.FilePath(dir, "GeoLite2-ASN"))
+	require.NoError(t, s.publish(ctx, []database.ReadResult{
+		{EditionID: "GeoLite2-City", OldHash: "new", NewHash: "new"},
+		{EditionID: "GeoLite2-ASN", OldHash: "asn", NewHash: database.ZeroMD5, Unavailable: true},
+	}))
+	s.end()
+	assert.NoFileExists(t, database.FilePath(dir, "GeoLite2-ASN"))
+	assertDatabase(t, database.FilePath(dir, "GeoLite2-City"), newCity)
+}
+
+// writeTestDatabase writes content as the database of editionID with w.
+func writeTestDatabase(t *testing.T, w database.Writer, editionID string, content []byte) {
+	sum := md5.Sum(content)
+	err := w.Write(
+		context.Background(),
+		editionID,
+		io.NopCloser(bytes.NewReader(content)),
+		hex.EncodeToString(sum[:]),
+		time.Time{},
+	)
+	require.NoError(t, err)
+}
+
+// assertDatabase asserts that the database at path holds content.
+func assertDatabase(t *testing.T, path string, content []byte) {
+	got, err := os.ReadFile(filepath.Clean(path))
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
@@ -0,0 +1,543 @@
+package geoipupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+var (
+	// ErrProfileRequired is returned when no profile is given to a daemon
+	// managing several.
+	ErrProfileRequired = errors.New("the daemon has several profiles, a profile is required")
+	// ErrUnknownProfile is returned for profiles the daemon doesn't manage.
+	ErrUnknownProfile = errors.New("unknown profile")
+)
+
+// Profile is a configuration managed by a Daemon, updated on its own
+// schedule.
+type Profile struct {
+	// Name identifies the profile. It may only be empty if the daemon
+	// manages a single profile.
+	Name string
+	// Load loads the configuration, initially and when reloading it.
+	Load func() (*Config, error)
+	// Interval is the time between two runs. If it is 0, the runs follow
+	// the Schedule of the configuration.
+	Interval time.Duration
+}
+
+// Daemon runs updates periodically, and on demand through its control API,
+// for one or more profiles.
+type Daemon struct {
+	// run runs an update with config, returning its editions and
+	// warnings. It defaults to running an Updater.
+	run      func(ctx context.Context, config *Config) ([]database.ReadResult, []Warning, error)
+	profiles []*profileState
+}
+
+// profileState is the state of a profile of a Daemon.
+type profileState struct {
+	Profile
+	trigger  chan struct{}
+	errorLog *errorLog
+
+	mu         sync.Mutex
+	config     *Config
+	status     DaemonStatus
+	lastReport *report
+}
+
+// DaemonStatus describes the state of a profile of a Daemon.
+type DaemonStatus struct {
+	Profile      string    `json:"profile,omitempty"`
+	Running      bool      `json:"running"`
+	LastStarted  time.Time `json:"last_started"`
+	LastFinished time.Time `json:"last_finished"`
+	LastSuccess  time.Time `json:"last_success"`
+	LastError    string    `json:"last_error,omitempty"`
+	NextRun      time.Time `json:"next_run"`
+	// Schedule is when the profile runs: an interval, or a cron
+	// expression.
+	Schedule      string    `json:"schedule"`
+	ConfigLoaded  time.Time `json:"config_loaded"`
+	ConfigHash    string    `json:"config_hash"`
+	EditionIDs    []string  `json:"edition_ids"`
+	RunsCompleted int       `json:"runs_completed"`
+	// RunsFailed counts every failed run, including those whose error
+	// wasn't logged because it repeated the previous one.
+	RunsFailed int `json:"runs_failed"`
+	// Warnings are those of the last run, e.g., cadence anomalies, which
+	// are reported apart from LastError.
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// NewDaemon returns a Daemon running updates for profiles, each on its own
+// schedule. Profiles must have distinct names and lock files.
+func NewDaemon(profiles []Profile) (*Daemon, error) {
+	if len(profiles) == 0 {
+		return nil, errors.New("at least one profile is required")
+	}
+
+	d := &Daemon{
+		run: func(ctx context.Context, config *Config) ([]database.ReadResult, []Warning, error) {
+			u, err := NewUpdater(config)
+			if err != nil {
+				return nil, nil, fmt.Errorf("initializing updater: %w", err)
+			}
+			if config.CheckOnly {
+				editions, err := u.Check(ctx)
+				return editions, u.Warnings(), err
+			}
+			editions, err := u.RunEditions(ctx)
+			return editions, u.Warnings(), err
+		},
+	}
+	lockFiles := map[string]string{}
+	for _, p := range profiles {
+		p := p
+		if p.Name == "" && len(profiles) > 1 {
+			return nil, errors.New("profiles must be named when there are several")
+		}
+		if _, err := d.profile(p.Name); err == nil {
+			return nil, fmt.Errorf("duplicate profile '%s'", p.Name)
+		}
+		if p.Interval < 0 {
+			return nil, fmt.Errorf("the interval of profile '%s' can't be negative, got %s", p.Name, p.Interval)
+		}
+
+		load := p.Load
+		p.Load = func() (*Config, error) {
+			config, err := load()
+			if err != nil {
+				return nil, err
+			}
+			config.Profile = p.Name
+			return config, nil
+		}
+		config, err := p.Load()
+		if err != nil {
+			if p.Name == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("profile '%s': %w", p.Name, err)
+		}
+		// Profiles sharing a lock file would block each other.
+		if other, ok := lockFiles[config.LockFile]; ok {
+			return nil, fmt.Errorf(
+				"profiles '%s' and '%s' share the lock file %s",
+				other,
+				p.Name,
+				config.LockFile,
+			)
+		}
+		lockFiles[config.LockFile] = p.Name
+
+		state := &profileState{
+			Profile:  p,
+			trigger:  make(chan struct{}, 1),
+			errorLog: newErrorLog(profileLogf(p.Name)),
+			config:   config,
+			status: DaemonStatus{
+				Profile:      p.Name,
+				ConfigLoaded: config.getClock().Now().In(time.UTC),
+				ConfigHash:   configHash(config),
+				EditionIDs:   config.EditionIDs,
+			},
+		}
+		state.status.Schedule = state.schedule().String()
+		d.profiles = append(d.profiles, state)
+	}
+	return d, nil
+}
+
+// profile returns the profile named name. The name may be empty if the
+// daemon manages a single profile.
+func (d *Daemon) profile(name string) (*profileState, error) {
+	if name == "" {
+		if len(d.profiles) != 1 {
+			return nil, ErrProfileRequired
+		}
+		return d.profiles[0], nil
+	}
+	for _, p := range d.profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrUnknownProfile, name)
+}
+
+// Run runs an update of each profile immediately, and then on the schedule
+// of the profile or when triggered, until ctx is done. Failed updates are
+// logged and retried at the next run.
+func (d *Daemon) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range d.profiles {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.runProfile(ctx, p)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Daemon) runProfile(ctx context.Context, p *profileState) {
+	timer := p.clock().NewTimer(0)
+	defer func() {
+		timer.Stop()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+		case <-p.trigger:
+			timer.Stop()
+		}
+
+		d.runOnce(ctx, p)
+
+		// The configuration, and so the clock and the schedule, may have
+		// been reloaded.
+		p.mu.Lock()
+		clock := p.config.getClock()
+		now := clock.Now()
+		next := nextRun(p.schedule(), p.config.ScheduleJitter, now)
+		p.status.NextRun = next.In(time.UTC)
+		p.mu.Unlock()
+		timer = clock.NewTimer(next.Sub(now))
+	}
+}
+
+// clock returns the Clock of the current configuration of p.
+func (p *profileState) clock() Clock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config.getClock()
+}
+
+// schedule returns the schedule of p: its Interval, if set, or the Schedule
+// of its current configuration otherwise. p.mu must be held.
+func (p *profileState) schedule() Schedule {
+	switch {
+	case p.Interval > 0:
+		return Schedule{Every: p.Interval}
+	case !p.config.Schedule.IsZero():
+		return p.config.Schedule
+	default:
+		return DefaultSchedule
+	}
+}
+
+func (d *Daemon) runOnce(ctx context.Context, p *profileState) {
+	p.mu.Lock()
+	config := p.config
+	p.status.Running = true
+	p.status.LastStarted = config.getClock().Now().In(time.UTC)
+	p.status.NextRun = time.Time{}
+	p.mu.Unlock()
+
+	runID := newRunID()
+	editions, warnings, err := d.run(withRunID(ctx, runID), config)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Running = false
+	p.status.LastFinished = config.getClock().Now().In(time.UTC)
+	p.status.RunsCompleted++
+	p.status.Warnings = warnings
+	if err != nil {
+		p.errorLog.failed(err, p.status.LastFinished)
+		p.status.LastError = err.Error()
+		p.status.RunsFailed++
+		return
+	}
+	p.errorLog.succeeded()
+	p.status.LastError = ""
+	p.status.LastSuccess = p.status.LastFinished
+	r := newReport(config, runID, editions, warnings)
+	p.lastReport = &r
+}
+
+// profileLogf returns a function logging messages prefixed with the name
+// of the profile, if any.
+func profileLogf(name string) func(format string, args ...any) {
+	if name == "" {
+		return log.Printf
+	}
+	return func(format string, args ...any) {
+		log.Printf("[%s] "+format, append([]any{name}, args...)...)
+	}
+}
+
+// RunNow triggers a run of the profile name, which starts once its current
+// run, if any, is done. Triggering a run while one is already pending has
+// no effect.
+func (d *Daemon) RunNow(name string) error {
+	p, err := d.profile(name)
+	if err != nil {
+		return err
+	}
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Status returns the current state of the profile name.
+func (d *Daemon) Status(name string) (DaemonStatus, error) {
+	p, err := d.profile(name)
+	if err != nil {
+		return DaemonStatus{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status, nil
+}
+
+// Statuses returns the current state of all the profiles.
+func (d *Daemon) Statuses() []DaemonStatus {
+	statuses := make([]DaemonStatus, 0, len(d.profiles))
+	for _, p := range d.profiles {
+		p.mu.Lock()
+		statuses = append(statuses, p.status)
+		p.mu.Unlock()
+	}
+	return statuses
+}
+
+// Reload reloads the configuration of the profile name, which is used from
+// its next run on. The current configuration is kept if the new one is
+// invalid.
+func (d *Daemon) Reload(name string) error {
+	p, err := d.profile(name)
+	if err != nil {
+		return err
+	}
+	config, err := p.Load()
+	if err != nil {
+		return fmt.Errorf("reloading configuration: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+	p.status.ConfigLoaded = config.getClock().Now().In(time.UTC)
+	p.status.ConfigHash = configHash(config)
+	p.status.EditionIDs = config.EditionIDs
+	p.status.Schedule = p.schedule().String()
+	return nil
+}
+
+// controlError is the body of failed control API requests.
+type controlError struct {
+	Error string `json:"error"`
+}
+
+// ControlHandler returns the handler of the control API of d:
+//
+//   - POST /run triggers a run.
+//   - GET /status returns the DaemonStatus.
+//   - GET /report returns the report, as with the "report" output format,
+//     of the last successful run.
+//   - POST /reload reloads the configuration.
+//   - GET /profiles returns the DaemonStatus of every profile.
+//
+// The profile is given by the "profile" query parameter, which may be
+// omitted if the daemon manages a single profile. Responses are JSON
+// objects. Failed requests return an object whose "error" member describes
+// the error.
+func (d *Daemon) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		name := r.URL.Query().Get("profile")
+		if err := d.RunNow(name); err != nil {
+			writeControlError(w, err)
+			return
+		}
+		status, err := d.Status(name)
+		if err != nil {
+			writeControlError(w, err)
+			return
+		}
+		writeControlResponse(w, http.StatusAccepted, status)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		status, err := d.Status(r.URL.Query().Get("profile"))
+		if err != nil {
+			writeControlError(w, err)
+			return
+		}
+		writeControlResponse(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("/profiles", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeControlResponse(w, http.StatusOK, d.Statuses())
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		p, err := d.profile(r.URL.Query().Get("profile"))
+		if err != nil {
+			writeControlError(w, err)
+			return
+		}
+		p.mu.Lock()
+		lastReport := p.lastReport
+		p.mu.Unlock()
+		if lastReport == nil {
+			writeControlResponse(w, http.StatusNotFound, controlError{Error: "no run has succeeded yet"})
+			return
+		}
+		writeControlResponse(w, http.StatusOK, lastReport)
+	})
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		name := r.URL.Query().Get("profile")
+		if err := d.Reload(name); err != nil {
+			writeControlError(w, err)
+			return
+		}
+		status, err := d.Status(name)
+		if err != nil {
+			writeControlError(w, err)
+			return
+		}
+		writeControlResponse(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		writeControlResponse(w, http.StatusNotFound, controlError{Error: "unknown request"})
+	})
+	return mux
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeControlResponse(w, http.StatusMethodNotAllowed, controlError{Error: "method not allowed"})
+	return false
+}
+
+// writeControlError writes err, with a status code depending on its kind.
+func writeControlError(w http.ResponseWriter, err error) {
+	status := http.StatusUnprocessableEntity
+	switch {
+	case errors.Is(err, ErrProfileRequired):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrUnknownProfile):
+		status = http.StatusNotFound
+	}
+	writeControlResponse(w, status, controlError{Error: err.Error()})
+}
+
+func writeControlResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("writing control response: %s", err)
+	}
+}
+
+// ControlSocket returns the path of the control socket of the daemon using
+// config, next to its lock file.
+func ControlSocket(config *Config) string {
+	return config.LockFile + ".sock"
+}
+
+// ControlSocket returns the path of the control socket of d with the
+// initial configuration of its first profile.
+func (d *Daemon) ControlSocket() string {
+	p := d.profiles[0]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ControlSocket(p.config)
+}
+
+// PIDFiles returns the distinct PID files of the initial configurations of
+// the profiles of d.
+func (d *Daemon) PIDFiles() []string {
+	var paths []string
+	for _, p := range d.profiles {
+		p.mu.Lock()
+		path := p.config.PIDFile
+		p.mu.Unlock()
+		if path != "" && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// DropPrivileges calls DropPrivileges with the initial configurations of the
+// profiles of d. Reloaded configurations can't change the user the daemon
+// runs as.
+func (d *Daemon) DropPrivileges(paths ...string) error {
+	return DropPrivileges(d.initialConfigs(), paths...)
+}
+
+// Sandbox calls Sandbox with the initial configurations of the profiles of
+// d. Reloaded configurations can't change the sandbox.
+func (d *Daemon) Sandbox(paths ...string) error {
+	return Sandbox(d.initialConfigs(), paths...)
+}
+
+// initialConfigs returns the configurations the profiles of d were loaded
+// with.
+func (d *Daemon) initialConfigs() []*Config {
+	configs := make([]*Config, 0, len(d.profiles))
+	for _, p := range d.profiles {
+		p.mu.Lock()
+		configs = append(configs, p.config)
+		p.mu.Unlock()
+	}
+	return configs
+}
+
+// ListenControl listens on the Unix socket at path, replacing a stale socket
+// left by a daemon that didn't shut down cleanly. Only the user running the
+// daemon can connect to it.
+func ListenControl(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("removing stale control socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("setting control socket permissions: %w", err)
+	}
+	return l, nil
+}
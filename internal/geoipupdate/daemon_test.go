@@ -0,0 +1,260 @@
+package geoipupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestDaemon(t *testing.T) {
+	tempDir := t.TempDir()
+
+	editionIDs := []string{"GeoLite2-City"}
+	var loadErr error
+	load := func() (*Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return &Config{
+			EditionIDs: editionIDs,
+			LockFile:   filepath.Join(tempDir, ".geoipupdate.lock"),
+		}, nil
+	}
+
+	d, err := NewDaemon([]Profile{{Load: load, Interval: time.Hour}})
+	require.NoError(t, err)
+
+	runs := make(chan []string)
+	runErr := errors.New("unavailable")
+	d.run = func(_ context.Context, config *Config) ([]database.ReadResult, []Warning, error) {
+		runs <- config.EditionIDs
+		if runErr != nil {
+			return nil, nil, runErr
+		}
+		return []database.ReadResult{
+			{EditionID: config.EditionIDs[0], OldHash: "A", NewHash: "B"},
+		}, []Warning{{Code: WarningClockSkew, Message: "skewed", EditionID: config.EditionIDs[0]}}, nil
+	}
+
+	socket := d.ControlSocket()
+	require.Equal(t, filepath.Join(tempDir, ".geoipupdate.lock.sock"), socket)
+	listener, err := ListenControl(socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: d.ControlHandler(), ReadHeaderTimeout: time.Second}
+	go func() {
+		assert.ErrorIs(t, server.Serve(listener), http.ErrServerClosed)
+	}()
+	defer server.Close()
+
+	// Only one daemon can listen on the socket.
+	_, err = ListenControl(socket)
+	require.Error(t, err)
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	request := func(method, path string, body any) int {
+		req, err := http.NewRequest(method, "http://daemon"+path, nil)
+		require.NoError(t, err)
+		res, err := httpClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		if body != nil {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(body))
+		}
+		return res.StatusCode
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	// The first run starts immediately.
+	require.Equal(t, []string{"GeoLite2-City"}, <-runs)
+	require.Eventually(t, func() bool { return !d.Statuses()[0].NextRun.IsZero() }, time.Second, time.Millisecond)
+
+	var status DaemonStatus
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/status", &status))
+	assert.Empty(t, status.Profile)
+	assert.False(t, status.Running)
+	assert.Equal(t, "unavailable", status.LastError)
+	assert.True(t, status.LastSuccess.IsZero())
+	assert.Equal(t, 1, status.RunsCompleted)
+	assert.Equal(t, 1, status.RunsFailed)
+
+	var controlErr controlError
+	require.Equal(t, http.StatusNotFound, request(http.MethodGet, "/report", &controlErr))
+	assert.Equal(t, "no run has succeeded yet", controlErr.Error)
+
+	// An invalid configuration is rejected and the current one kept.
+	loadErr = errors.New("invalid")
+	require.Equal(t, http.StatusUnprocessableEntity, request(http.MethodPost, "/reload", &controlErr))
+	assert.Equal(t, "reloading configuration: invalid", controlErr.Error)
+
+	loadErr = nil
+	editionIDs = []string{"GeoLite2-ASN"}
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/reload", &status))
+	assert.Equal(t, []string{"GeoLite2-ASN"}, status.EditionIDs)
+
+	runErr = nil
+	require.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/run", nil))
+	require.Equal(t, http.StatusAccepted, request(http.MethodPost, "/run", nil))
+	require.Equal(t, []string{"GeoLite2-ASN"}, <-runs)
+	require.Eventually(t, func() bool { return d.Statuses()[0].RunsCompleted == 2 }, time.Second, time.Millisecond)
+
+	var r report
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/report", &r))
+	require.Len(t, r.Editions, 1)
+	assert.Equal(t, "GeoLite2-ASN", r.Editions[0].EditionID)
+	assert.Equal(t, "B", r.Editions[0].NewHash)
+	require.Len(t, r.Warnings, 1)
+	assert.Equal(t, WarningClockSkew, r.Warnings[0].Code)
+
+	status, err = d.Status("")
+	require.NoError(t, err)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, status.LastFinished, status.LastSuccess)
+
+	cancel()
+	<-done
+}
+
+func TestDaemonProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+	profile := func(name string, interval time.Duration) Profile {
+		return Profile{
+			Name: name,
+			Load: func() (*Config, error) {
+				return &Config{
+					EditionIDs: []string{name},
+					LockFile:   filepath.Join(tempDir, name+".lock"),
+				}, nil
+			},
+			Interval: interval,
+		}
+	}
+
+	_, err := NewDaemon([]Profile{profile("edge", time.Hour), profile("edge", time.Hour)})
+	require.EqualError(t, err, "duplicate profile 'edge'")
+
+	_, err = NewDaemon([]Profile{profile("edge", time.Hour), profile("", time.Hour)})
+	require.EqualError(t, err, "profiles must be named when there are several")
+
+	shared := profile("ci", time.Hour)
+	shared.Load = profile("edge", time.Hour).Load
+	_, err = NewDaemon([]Profile{profile("edge", time.Hour), shared})
+	require.EqualError(
+		t,
+		err,
+		"profiles 'edge' and 'ci' share the lock file "+filepath.Join(tempDir, "edge.lock"),
+	)
+
+	d, err := NewDaemon([]Profile{
+		profile("edge", 10*time.Millisecond),
+		profile("ci", time.Hour),
+	})
+	require.NoError(t, err)
+
+	runs := make(chan string, 10)
+	d.run = func(_ context.Context, config *Config) ([]database.ReadResult, []Warning, error) {
+		runs <- config.Profile
+		return nil, nil, nil
+	}
+
+	_, err = d.Status("")
+	require.ErrorIs(t, err, ErrProfileRequired)
+	_, err = d.Status("other")
+	require.ErrorIs(t, err, ErrUnknownProfile)
+	require.ErrorIs(t, d.RunNow("other"), ErrUnknownProfile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	// Each profile runs on its own schedule.
+	counts := map[string]int{}
+	for counts["edge"] < 3 || counts["ci"] < 1 {
+		counts[<-runs]++
+	}
+	cancel()
+	<-done
+	close(runs)
+	for name := range runs {
+		counts[name]++
+	}
+	assert.Equal(t, 1, counts["ci"])
+
+	statuses := d.Statuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "edge", statuses[0].Profile)
+	assert.Equal(t, []string{"edge"}, statuses[0].EditionIDs)
+	assert.Equal(t, "ci", statuses[1].Profile)
+	assert.Equal(t, 1, statuses[1].RunsCompleted)
+}
+
+// TestDaemonSchedule tests that profiles without an interval run on the
+// Schedule of their configuration, delayed by up to ScheduleJitter.
+func TestDaemonSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 17, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	schedule, err := parseSchedule("30 3 * * *")
+	require.NoError(t, err)
+
+	config := &Config{
+		EditionIDs:     []string{"GeoLite2-City"},
+		LockFile:       filepath.Join(t.TempDir(), ".geoipupdate.lock"),
+		Schedule:       schedule,
+		ScheduleJitter: 10 * time.Minute,
+	}
+	require.NoError(t, WithClock(clock)(config))
+
+	d, err := NewDaemon([]Profile{{Load: func() (*Config, error) { return config, nil }}})
+	require.NoError(t, err)
+
+	runs := make(chan time.Time)
+	d.run = func(ctx context.Context, config *Config) ([]database.ReadResult, []Warning, error) {
+		select {
+		case runs <- config.getClock().Now():
+		case <-ctx.Done():
+		}
+		return nil, nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	// The first run starts immediately, and the next ones at 3:30.
+	require.Equal(t, start, <-runs)
+	for day := 2; day <= 3; day++ {
+		due := time.Date(2024, 1, day, 3, 30, 0, 0, time.UTC)
+		started := <-runs
+		assert.False(t, started.Before(due), started)
+		assert.True(t, started.Before(due.Add(10*time.Minute)), started)
+	}
+
+	cancel()
+	<-done
+}
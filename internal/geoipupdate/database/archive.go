@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveTimeFormat is the format of the build times in the names of
+// archived copies. It has the full time so that several builds of a day are
+// all kept.
+const archiveTimeFormat = "20060102T150405Z"
+
+// ArchiveUploader uploads the archived copies of databases to remote
+// storage, e.g., Amazon S3 Glacier.
+type ArchiveUploader interface {
+	// UploadArchive uploads the database at path, whose MD5 sum is md5Sum,
+	// as name. It fails if name already holds another database, and does
+	// nothing if it holds this one.
+	UploadArchive(ctx context.Context, name, path, md5Sum string) error
+}
+
+// WithArchiveUploader makes the writer upload the database being replaced
+// with u before the new one is moved into place, named as in the archive
+// directory.
+func WithArchiveUploader(u ArchiveUploader) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.archiveUploader = u
+	}
+}
+
+// archive copies the database at path into the archive directory, and
+// uploads it with the archive uploader, if any. The copy is named after the
+// edition and the build time of the database. Nothing is done if there is
+// no database at path yet or if that build has already been archived. An
+// archived copy of the same name with another MD5 sum is an error.
+func (w *LocalFileWriter) archive(ctx context.Context, editionID, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading database information: %w", err)
+	}
+
+	name := w.archiveName(editionID, path, info)
+	var md5Sum string
+	if cached, ok := w.cachedHash(editionID); ok &&
+		cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		md5Sum = cached.MD5
+	} else {
+		md5Sum, err = fileMD5(ctx, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.archiveDir != "" {
+		if err := w.archiveLocally(ctx, path, filepath.Join(w.archiveDir, name), md5Sum); err != nil {
+			return err
+		}
+	}
+
+	if w.archiveUploader != nil {
+		if err := w.archiveUploader.UploadArchive(ctx, name, path, md5Sum); err != nil {
+			return fmt.Errorf("uploading archived copy: %w", err)
+		}
+		if w.verbose {
+			log.Printf("Uploaded %s as %s", path, name)
+		}
+	}
+
+	return nil
+}
+
+// archiveLocally copies the database at path, whose MD5 sum is md5Sum, to
+// archivePath.
+func (w *LocalFileWriter) archiveLocally(ctx context.Context, path, archivePath, md5Sum string) error {
+	archived, err := fileMD5(ctx, archivePath)
+	switch {
+	case err == nil:
+		if !strings.EqualFold(archived, md5Sum) {
+			return fmt.Errorf(
+				"%s already holds another build (md5 %s rather than %s)",
+				archivePath,
+				archived,
+				md5Sum,
+			)
+		}
+		if w.verbose {
+			log.Printf("Database %s already archived at %s", path, archivePath)
+		}
+		return nil
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	if err := copyFile(ctx, path, archivePath); err != nil {
+		return err
+	}
+
+	if w.verbose {
+		log.Printf("Archived %s to %s", path, archivePath)
+	}
+
+	return nil
+}
+
+// archivePath returns the path of the archived copy of the database at
+// path, described by info.
+func (w *LocalFileWriter) archivePath(editionID, path string, info os.FileInfo) string {
+	return filepath.Join(w.archiveDir, w.archiveName(editionID, path, info))
+}
+
+// archiveName returns the name of the archived copy of the database at
+// path, described by info, e.g., GeoIP2-City_20240223T143000Z.mmdb.
+func (w *LocalFileWriter) archiveName(editionID, path string, info os.FileInfo) string {
+	built, err := buildTime(path)
+	if err != nil {
+		// The database can't be parsed, e.g., it was truncated. We still
+		// want to keep it around, so fall back to its modification time.
+		if w.verbose {
+			log.Printf("Unable to read build date of %s, using modification time: %s", path, err)
+		}
+		built = info.ModTime()
+	}
+
+	return fmt.Sprintf("%s_%s%s", editionID, built.UTC().Format(archiveTimeFormat), extension)
+}
+
+// fileMD5 returns the MD5 sum of the file at path, read until ctx is done.
+func fileMD5(ctx context.Context, path string) (string, error) {
+	//nolint:gosec // we really need to read this file.
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	md5Hash := md5.New()
+	if _, err := io.Copy(md5Hash, &contextReader{ctx: ctx, Reader: f}); err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	return byteToString(md5Hash.Sum(nil)), nil
+}
+
+// copyFile copies src to dst. The content is written to a temporary file
+// first so that dst is never left partially written, nor written at all if
+// ctx is done first.
+func copyFile(ctx context.Context, src, dst string) (err error) {
+	//nolint:gosec // we really need to read this file.
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", src, err)
+	}
+	defer in.Close()
+
+	fw, err := newFileWriter(dst + tempExtension)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := fw.close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("closing file writer: %w", closeErr))
+		}
+	}()
+
+	if err = fw.write(ctx, in); err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	if err = fw.syncAndRename(ctx, dst); err != nil {
+		return fmt.Errorf("renaming copy of %s: %w", src, err)
+	}
+
+	if err = syncDir(filepath.Dir(dst)); err != nil {
+		return fmt.Errorf("syncing directory: %w", err)
+	}
+
+	return nil
+}
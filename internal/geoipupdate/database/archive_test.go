@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLocalFileWriterArchive tests that the database being replaced is
+// archived before the new one is moved into place.
+func TestLocalFileWriterArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	archiveDir := filepath.Join(tempDir, "archive")
+
+	fw, err := NewLocalFileWriter(tempDir, false, false, WithArchiveDirectory(archiveDir))
+	require.NoError(t, err)
+
+	// Nothing to archive on the first write.
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("database content")),
+		"cfa36ddc8279b5483a5aa25e9a6151f4",
+		time.Time{},
+	)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(archiveDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// The old database isn't a valid MMDB, so the modification time is
+	// used to name the archived copy.
+	modTime := time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(fw.getFilePath("GeoIP2-City"), modTime, modTime))
+
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("new database content")),
+		"f8e36749e12c5ab2d2441f7fb1a80c4f",
+		time.Time{},
+	)
+	require.NoError(t, err)
+
+	archived, err := os.ReadFile(filepath.Join(archiveDir, "GeoIP2-City_20240223T000000Z.mmdb"))
+	require.NoError(t, err)
+	require.Equal(t, "database content", string(archived))
+
+	current, err := os.ReadFile(fw.getFilePath("GeoIP2-City"))
+	require.NoError(t, err)
+	require.Equal(t, "new database content", string(current))
+}
+
+// TestLocalFileWriterArchiveBuildDate tests that archived copies of valid
+// databases are named after their build date.
+func TestLocalFileWriterArchiveBuildDate(t *testing.T) {
+	tempDir := t.TempDir()
+	archiveDir := filepath.Join(tempDir, "archive")
+
+	fw, err := NewLocalFileWriter(tempDir, false, false, WithArchiveDirectory(archiveDir))
+	require.NoError(t, err)
+
+	buildEpoch := time.Date(2023, 11, 7, 14, 0, 0, 0, time.UTC)
+	writeTestMMDB(t, fw.getFilePath("GeoLite2-ASN"), buildEpoch)
+
+	err = fw.Write(
+		context.Background(),
+		"GeoLite2-ASN",
+		io.NopCloser(strings.NewReader("database content")),
+		"cfa36ddc8279b5483a5aa25e9a6151f4",
+		time.Time{},
+	)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(archiveDir, "GeoLite2-ASN_20231107T140000Z.mmdb"))
+	require.NoError(t, err)
+}
+
+// TestLocalFileWriterArchiveSameDay tests that several builds of a day are
+// all archived, and that an archived copy is never replaced by another
+// build.
+func TestLocalFileWriterArchiveSameDay(t *testing.T) {
+	tempDir := t.TempDir()
+	archiveDir := filepath.Join(tempDir, "archive")
+
+	fw, err := NewLocalFileWriter(tempDir, false, false, WithArchiveDirectory(archiveDir))
+	require.NoError(t, err)
+
+	path := fw.getFilePath("GeoIP2-City")
+	write := func(content string) error {
+		sum := md5.Sum([]byte(content))
+		return fw.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(strings.NewReader(content)),
+			hex.EncodeToString(sum[:]),
+			time.Time{},
+		)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("morning build"), 0o600))
+	morning := time.Date(2024, 2, 23, 8, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, morning, morning))
+	require.NoError(t, write("afternoon build"))
+
+	afternoon := time.Date(2024, 2, 23, 15, 30, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, afternoon, afternoon))
+	require.NoError(t, write("evening build"))
+
+	for name, content := range map[string]string{
+		"GeoIP2-City_20240223T080000Z.mmdb": "morning build",
+		"GeoIP2-City_20240223T153000Z.mmdb": "afternoon build",
+	} {
+		archived, err := os.ReadFile(filepath.Join(archiveDir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(archived))
+	}
+
+	// Another build with the same build time isn't archived over the
+	// archived copy, nor installed.
+	require.NoError(t, os.WriteFile(path, []byte("other morning build"), 0o600))
+	require.NoError(t, os.Chtimes(path, morning, morning))
+	err = write("new build")
+	require.ErrorContains(t, err, "GeoIP2-City_20240223T080000Z.mmdb already holds another build")
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "other morning build", string(current))
+}
+
+// testUploader records the archived copies it uploads.
+type testUploader struct {
+	uploads map[string]string
+}
+
+func (u *testUploader) UploadArchive(_ context.Context, name, path, md5Sum string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	u.uploads[name] = md5Sum + " " + string(content)
+	return nil
+}
+
+// TestLocalFileWriterArchiveUploader tests that the database being replaced
+// is uploaded with the archive uploader, with or without an archive
+// directory.
+func TestLocalFileWriterArchiveUploader(t *testing.T) {
+	tempDir := t.TempDir()
+	uploader := &testUploader{uploads: map[string]string{}}
+
+	fw, err := NewLocalFileWriter(tempDir, false, false, WithArchiveUploader(uploader))
+	require.NoError(t, err)
+
+	path := fw.getFilePath("GeoIP2-City")
+	require.NoError(t, os.WriteFile(path, []byte("database content"), 0o600))
+	modTime := time.Date(2024, 2, 23, 8, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("new database content")),
+		"f8e36749e12c5ab2d2441f7fb1a80c4f",
+		time.Time{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"GeoIP2-City_20240223T080000Z.mmdb": "cfa36ddc8279b5483a5aa25e9a6151f4 database content",
+	}, uploader.uploads)
+}
+
+// writeTestMMDB writes an empty MMDB with the given build epoch to path.
+func writeTestMMDB(t *testing.T, path string, buildEpoch time.Time) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		BuildEpoch:   buildEpoch.Unix(),
+		DatabaseType: "Test",
+		RecordSize:   24,
+	})
+	require.NoError(t, err)
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+const (
+	consumerLockExtension = ".lock"
+	consumerLockRetry     = 100 * time.Millisecond
+)
+
+// consumerLock is an exclusive lock on the sentinel file that consumers of
+// a database hold a shared lock on while they are reading it. Holding it
+// guarantees that no cooperating consumer is using the database.
+type consumerLock struct {
+	lock *flock.Flock
+}
+
+// acquireConsumerLock waits up to timeout for all consumers to release their
+// shared lock on the sentinel file of the database at path.
+func (w *LocalFileWriter) acquireConsumerLock(path string) (*consumerLock, error) {
+	lock := flock.New(path + consumerLockExtension)
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.consumerLockTimeout)
+	defer cancel()
+
+	ok, err := lock.TryLockContext(ctx, consumerLockRetry)
+	if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("acquiring consumer lock at %s: %w", lock.Path(), err)
+	}
+	if !ok {
+		return nil, fmt.Errorf(
+			"database %s still in use by a consumer after %s",
+			path,
+			w.consumerLockTimeout,
+		)
+	}
+
+	if w.verbose {
+		log.Printf("Acquired consumer lock at %s", lock.Path())
+	}
+
+	return &consumerLock{lock: lock}, nil
+}
+
+// release releases the consumer lock.
+func (c *consumerLock) release() error {
+	if err := c.lock.Unlock(); err != nil {
+		return fmt.Errorf("releasing consumer lock at %s: %w", c.lock.Path(), err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLocalFileWriterConsumerLock tests that a database isn't replaced while
+// a consumer holds a shared lock on its sentinel file.
+func TestLocalFileWriterConsumerLock(t *testing.T) {
+	tempDir := t.TempDir()
+
+	fw, err := NewLocalFileWriter(
+		tempDir,
+		false,
+		false,
+		WithConsumerLockTimeout(200*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	path := fw.getFilePath("GeoIP2-City")
+	require.NoError(t, os.WriteFile(path, []byte("old content"), 0o600))
+
+	consumer := flock.New(path + consumerLockExtension)
+	require.NoError(t, consumer.RLock())
+
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("database content")),
+		"cfa36ddc8279b5483a5aa25e9a6151f4",
+		time.Time{},
+	)
+	require.ErrorContains(t, err, "still in use by a consumer")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "old content", string(content))
+
+	// Once the consumer is done, the database can be replaced.
+	require.NoError(t, consumer.Unlock())
+
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("database content")),
+		"cfa36ddc8279b5483a5aa25e9a6151f4",
+		time.Time{},
+	)
+	require.NoError(t, err)
+
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "database content", string(content))
+}
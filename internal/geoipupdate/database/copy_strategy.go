@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// copyToTarget copies the content written by src to a file at path, which
+// is usually on a network file system. The copy is synced and then read
+// back to make sure its hash matches h, since some network file systems
+// may report success for writes that never made it to storage. The copy is
+// abandoned once ctx is done.
+//
+// The returned fileWriter holds the copy. The caller is responsible for
+// closing it.
+func copyToTarget(ctx context.Context, src *fileWriter, path, h string) (*fileWriter, error) {
+	if err := syncFile(ctx, src.file); err != nil {
+		return nil, fmt.Errorf("syncing temporary file: %w", err)
+	}
+
+	//nolint:gosec // we really need to read this file.
+	in, err := os.Open(src.file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("opening temporary file: %w", err)
+	}
+	defer in.Close()
+
+	target, err := newFileWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := target.write(ctx, in); err != nil {
+		return nil, errors.Join(err, target.close())
+	}
+
+	if err := target.validateHash(h); err != nil {
+		return nil, errors.Join(err, target.close())
+	}
+
+	if err := syncFile(ctx, target.file); err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("syncing copied file: %w", err),
+			target.close(),
+		)
+	}
+
+	if err := validateFileHash(ctx, path, h); err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("verifying copied file: %w", err),
+			target.close(),
+		)
+	}
+
+	return target, nil
+}
+
+// validateFileHash reads the file at path and validates its hash against a
+// known value, until ctx is done.
+func validateFileHash(ctx context.Context, path, h string) error {
+	fileHash, err := fileMD5(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(h, fileHash) {
+		return fmt.Errorf("md5 of %s (%s) does not match expected md5 (%s)", path, fileHash, h)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLocalFileWriterCopyStrategy tests that databases written with the copy
+// strategy are staged in the temporary directory and that no temporary files
+// are left behind.
+func TestLocalFileWriterCopyStrategy(t *testing.T) {
+	tests := []struct {
+		description string
+		newMD5      string
+		checkErr    func(require.TestingT, error, ...interface{}) //nolint:revive // support older versions
+	}{
+		{
+			description: "success",
+			newMD5:      "cfa36ddc8279b5483a5aa25e9a6151f4",
+			checkErr:    require.NoError,
+		},
+		{
+			description: "hash does not match",
+			newMD5:      "badhash",
+			checkErr:    require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			databaseDir := t.TempDir()
+			tempDir := t.TempDir()
+
+			fw, err := NewLocalFileWriter(databaseDir, false, false, WithCopyStrategy(tempDir))
+			require.NoError(t, err)
+
+			err = fw.Write(
+				context.Background(),
+				"GeoIP2-City",
+				io.NopCloser(strings.NewReader("database content")),
+				test.newMD5,
+				time.Time{},
+			)
+			test.checkErr(t, err)
+
+			if err == nil {
+				content, err := os.ReadFile(filepath.Join(databaseDir, "GeoIP2-City.mmdb"))
+				require.NoError(t, err)
+				require.Equal(t, "database content", string(content))
+			}
+
+			for _, dir := range []string{databaseDir, tempDir} {
+				entries, err := os.ReadDir(dir)
+				require.NoError(t, err)
+				for _, entry := range entries {
+					require.NotContains(t, entry.Name(), tempExtension)
+				}
+			}
+		})
+	}
+}
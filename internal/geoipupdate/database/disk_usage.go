@@ -0,0 +1,262 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DiskUsageError is returned when writing a database would exceed the disk
+// usage budget set with WithMaxDiskUsage, even after removing every
+// archived copy.
+type DiskUsageError struct {
+	// Budget is the disk usage budget.
+	Budget int64
+	// Usage is the disk usage the write would result in.
+	Usage int64
+}
+
+func (e *DiskUsageError) Error() string {
+	return fmt.Sprintf(
+		"writing the database would use %d bytes of disk, more than the budget of %d bytes",
+		e.Usage,
+		e.Budget,
+	)
+}
+
+// WithMaxDiskUsage sets the number of bytes the files of the database
+// directory and of the archive directory can use. Before writing a database,
+// and while writing it, the oldest archived copies are removed as needed to
+// stay within budget. If that isn't enough, the write fails with a
+// *DiskUsageError.
+func WithMaxDiskUsage(budget int64) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.maxDiskUsage = budget
+	}
+}
+
+// diskBudget tracks the room reserved by a database write. The writes of a
+// LocalFileWriter, e.g., parallel downloads, share its budget: each is only
+// admitted if it fits along with the room reserved by the others.
+type diskBudget struct {
+	w *LocalFileWriter
+	// reserved is the room reserved by the write, for the new database and
+	// the archived copy of the installed one.
+	reserved int64
+	// database is the room reserved for the new database.
+	database int64
+}
+
+// reserveDisk checks that the database of editionID, to be written to path,
+// can be written within the disk usage budget, assuming it is about the size
+// of the installed database, and reserves room for it. The returned budget
+// is nil if there is none, and must be released once the write is done.
+func (w *LocalFileWriter) reserveDisk(editionID, path string) (*diskBudget, error) {
+	if w.maxDiskUsage <= 0 {
+		return nil, nil //nolint:nilnil // there is no budget.
+	}
+
+	w.diskMu.Lock()
+	defer w.diskMu.Unlock()
+
+	usage, err := w.diskUsage()
+	if err != nil {
+		return nil, err
+	}
+	w.diskUsed = usage
+	b := &diskBudget{w: w}
+
+	var installed, archived int64
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading database information: %w", err)
+	default:
+		installed = info.Size()
+		// The installed database is about to be archived.
+		if w.archiveDir != "" {
+			if _, err := os.Stat(w.archivePath(editionID, path, info)); err != nil {
+				archived = installed
+			}
+		}
+	}
+
+	if err := b.grow(archived + installed); err != nil {
+		return nil, err
+	}
+	b.database = installed
+	return b, nil
+}
+
+// reserve makes sure that room is reserved for a new database of n bytes.
+func (b *diskBudget) reserve(n int64) error {
+	b.w.diskMu.Lock()
+	defer b.w.diskMu.Unlock()
+	if n <= b.database {
+		return nil
+	}
+	if err := b.grow(n - b.database); err != nil {
+		return err
+	}
+	b.database = n
+	return nil
+}
+
+// grow reserves n more bytes, removing the oldest archived copies if
+// needed. The lock of the writer must be held.
+func (b *diskBudget) grow(n int64) error {
+	w := b.w
+	for w.diskUsed+w.diskReserved+n > w.maxDiskUsage {
+		freed, err := w.pruneArchive()
+		if err != nil {
+			return err
+		}
+		if freed == 0 {
+			return &DiskUsageError{Budget: w.maxDiskUsage, Usage: w.diskUsed + w.diskReserved + n}
+		}
+		w.diskUsed -= freed
+	}
+	w.diskReserved += n
+	b.reserved += n
+	return nil
+}
+
+// release gives the room reserved by the write back. What it wrote is then
+// part of the disk usage of the files.
+func (b *diskBudget) release() {
+	if b == nil {
+		return
+	}
+	w := b.w
+	w.diskMu.Lock()
+	defer w.diskMu.Unlock()
+	w.diskReserved -= b.reserved
+	if usage, err := w.diskUsage(); err == nil {
+		w.diskUsed = usage
+	} else {
+		// Assume the write used all of its room until the next write
+		// reads the usage again.
+		w.diskUsed += b.reserved
+	}
+	b.reserved = 0
+}
+
+// reader returns a reader of r that fails with a *DiskUsageError once
+// more bytes are read than fit within the budget.
+func (b *diskBudget) reader(r io.Reader) io.Reader {
+	if b == nil {
+		return r
+	}
+	return &budgetReader{Reader: r, budget: b}
+}
+
+// budgetReader reserves room for the bytes read from Reader.
+type budgetReader struct {
+	io.Reader
+	budget *diskBudget
+	n      int64
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	if reserveErr := r.budget.reserve(r.n); reserveErr != nil {
+		return n, reserveErr
+	}
+	return n, err
+}
+
+// diskUsage returns the size of the files of the database directory and of
+// the archive directory. Temporary files are left out, as the writes in
+// progress reserve room for them.
+func (w *LocalFileWriter) diskUsage() (int64, error) {
+	dirs := []string{w.dir}
+	if w.archiveDir != "" && filepath.Clean(w.archiveDir) != filepath.Clean(w.dir) {
+		dirs = append(dirs, w.archiveDir)
+	}
+
+	var usage int64
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return 0, fmt.Errorf("reading disk usage of %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), tempExtension) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return 0, fmt.Errorf("reading disk usage of %s: %w", dir, err)
+			}
+			usage += info.Size()
+		}
+	}
+	return usage, nil
+}
+
+// pruneArchive removes the archived copy with the oldest build time and
+// returns its size. It returns 0 if there is none.
+func (w *LocalFileWriter) pruneArchive() (int64, error) {
+	if w.archiveDir == "" {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(w.archiveDir)
+	if err != nil {
+		return 0, fmt.Errorf("reading archive directory: %w", err)
+	}
+
+	type archived struct {
+		name  string
+		built time.Time
+	}
+	var copies []archived
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, extension) {
+			continue
+		}
+		base := strings.TrimSuffix(name, extension)
+		i := strings.LastIndex(base, "_")
+		if i < 0 {
+			continue
+		}
+		built, err := time.Parse(archiveTimeFormat, base[i+1:])
+		if err != nil {
+			continue
+		}
+		copies = append(copies, archived{name: name, built: built})
+	}
+	if len(copies) == 0 {
+		return 0, nil
+	}
+
+	oldest := slices.MinFunc(copies, func(a, b archived) int {
+		if c := a.built.Compare(b.built); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	path := filepath.Join(w.archiveDir, oldest.name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("reading archived database information: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("removing archived database: %w", err)
+	}
+	log.Printf("Removed the archived database %s to stay within the disk usage budget", path)
+
+	return info.Size(), nil
+}
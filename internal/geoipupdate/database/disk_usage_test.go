@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLocalFileWriterMaxDiskUsage tests that the oldest archived copies are
+// removed to stay within the disk usage budget.
+func TestLocalFileWriterMaxDiskUsage(t *testing.T) {
+	tempDir := t.TempDir()
+	archiveDir := filepath.Join(tempDir, "archive")
+
+	// The archived copies and the database, including the archived copy it
+	// is about to get, use 232 bytes. The new database is assumed to be as
+	// large as the installed one, 16 bytes.
+	fw, err := NewLocalFileWriter(
+		tempDir,
+		false,
+		false,
+		WithArchiveDirectory(archiveDir),
+		WithMaxDiskUsage(240),
+	)
+	require.NoError(t, err)
+
+	older := filepath.Join(archiveDir, "GeoIP2-City_20240101T000000Z.mmdb")
+	newer := filepath.Join(archiveDir, "GeoIP2-City_20240201T000000Z.mmdb")
+	for _, path := range []string{newer, older} {
+		require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", 100)), 0o600))
+	}
+	installed := fw.getFilePath("GeoIP2-City")
+	require.NoError(t, os.WriteFile(installed, []byte("database content"), 0o600))
+	modTime := time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(installed, modTime, modTime))
+
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("new database content")),
+		"f8e36749e12c5ab2d2441f7fb1a80c4f",
+		time.Time{},
+	)
+	require.NoError(t, err)
+
+	require.NoFileExists(t, older)
+	require.FileExists(t, newer)
+	require.FileExists(t, filepath.Join(archiveDir, "GeoIP2-City_20240223T000000Z.mmdb"))
+
+	current, err := os.ReadFile(installed)
+	require.NoError(t, err)
+	require.Equal(t, "new database content", string(current))
+}
+
+// TestLocalFileWriterMaxDiskUsageExceeded tests that databases that don't fit
+// within the disk usage budget are not written.
+func TestLocalFileWriterMaxDiskUsageExceeded(t *testing.T) {
+	t.Run("before writing", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		fw, err := NewLocalFileWriter(tempDir, false, false, WithMaxDiskUsage(24))
+		require.NoError(t, err)
+
+		installed := fw.getFilePath("GeoIP2-City")
+		require.NoError(t, os.WriteFile(installed, []byte("database content"), 0o600))
+
+		err = fw.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(strings.NewReader("new database content")),
+			"f8e36749e12c5ab2d2441f7fb1a80c4f",
+			time.Time{},
+		)
+		var diskErr *DiskUsageError
+		require.ErrorAs(t, err, &diskErr)
+		require.Equal(t, &DiskUsageError{Budget: 24, Usage: 32}, diskErr)
+		require.NoFileExists(t, installed+tempExtension)
+
+		current, err := os.ReadFile(installed)
+		require.NoError(t, err)
+		require.Equal(t, "database content", string(current))
+	})
+
+	t.Run("while writing", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		fw, err := NewLocalFileWriter(tempDir, false, false, WithMaxDiskUsage(10))
+		require.NoError(t, err)
+
+		err = fw.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(strings.NewReader("database content")),
+			"cfa36ddc8279b5483a5aa25e9a6151f4",
+			time.Time{},
+		)
+		var diskErr *DiskUsageError
+		require.ErrorAs(t, err, &diskErr)
+		require.NoFileExists(t, fw.getFilePath("GeoIP2-City"))
+		require.NoFileExists(t, fw.getFilePath("GeoIP2-City")+tempExtension)
+	})
+}
+
+// blockingReader returns content, then waits for release before returning
+// io.EOF.
+type blockingReader struct {
+	content *strings.Reader
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if r.content.Len() > 0 {
+		return r.content.Read(p)
+	}
+	if r.read != nil {
+		close(r.read)
+		r.read = nil
+	}
+	<-r.release
+	return 0, io.EOF
+}
+
+// TestLocalFileWriterMaxDiskUsageParallel tests that parallel writes share
+// the disk usage budget, so that they can't exceed it together while each
+// fits within it alone.
+func TestLocalFileWriterMaxDiskUsageParallel(t *testing.T) {
+	tempDir := t.TempDir()
+
+	fw, err := NewLocalFileWriter(tempDir, false, false, WithMaxDiskUsage(40))
+	require.NoError(t, err)
+
+	content := strings.Repeat("x", 30)
+	sum := md5.Sum([]byte(content))
+	write := func(editionID string, r io.Reader) error {
+		return fw.Write(
+			context.Background(),
+			editionID,
+			io.NopCloser(r),
+			hex.EncodeToString(sum[:]),
+			time.Time{},
+		)
+	}
+
+	// The first write has read its database, but isn't done yet.
+	first := &blockingReader{
+		content: strings.NewReader(content),
+		read:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	read := first.read
+	done := make(chan error)
+	go func() {
+		done <- write("GeoIP2-City", first)
+	}()
+	<-read
+
+	err = write("GeoIP2-ISP", strings.NewReader(content))
+	var diskErr *DiskUsageError
+	require.ErrorAs(t, err, &diskErr)
+	require.Equal(t, &DiskUsageError{Budget: 40, Usage: 60}, diskErr)
+	require.NoFileExists(t, fw.getFilePath("GeoIP2-ISP"))
+
+	close(first.release)
+	require.NoError(t, <-done)
+	require.FileExists(t, fw.getFilePath("GeoIP2-City"))
+
+	// The room of the first write is given back once it is done.
+	require.Equal(t, int64(0), fw.diskReserved)
+	require.Equal(t, int64(30), fw.diskUsed)
+}
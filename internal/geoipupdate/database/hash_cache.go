@@ -0,0 +1,168 @@
+package database
+
+import (
+	"hash"
+	"io"
+	"log"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	// hashProgressInterval is how often the progress of hashing a database
+	// is logged in verbose mode.
+	hashProgressInterval = 5 * time.Second
+	// checksumPrefix names the algorithm of the checksums of FileHash, so
+	// that it can change without mistaking old checksums for new ones.
+	checksumPrefix = "xxh64:"
+	// backgroundHashBuffers is how many writes a backgroundHash can fall
+	// behind before it is given up.
+	backgroundHashBuffers = 256
+)
+
+// HashCache caches the hashes of the installed databases so that GetHash
+// doesn't compute the MD5 sums of large databases while they don't change.
+type HashCache interface {
+	// FileHash returns the cached hashes of the database of editionID.
+	FileHash(editionID string) (FileHash, bool)
+	// SetFileHash caches the hashes of the database of editionID.
+	SetFileHash(editionID string, h FileHash) error
+}
+
+// FileHash holds the hashes of a database file as of when it had Size and
+// ModTime. A file whose size and modification time didn't change is
+// assumed not to have changed. Otherwise, a file of the same size is only
+// read to compute its Checksum, xxHash64, a fast non-cryptographic hash,
+// which tells whether it changed without waiting for its MD5 sum.
+type FileHash struct {
+	MD5      string
+	Checksum string
+	Size     int64
+	ModTime  time.Time
+}
+
+// WithHashCache makes GetHash use cache, and Write record the hashes of the
+// databases it installs in it.
+func WithHashCache(cache HashCache) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.hashCache = cache
+	}
+}
+
+// cachedHash returns the cached hashes of the database of editionID.
+func (w *LocalFileWriter) cachedHash(editionID string) (FileHash, bool) {
+	if w.hashCache == nil {
+		return FileHash{}, false
+	}
+	return w.hashCache.FileHash(editionID)
+}
+
+// cacheHash caches h as the hashes of the database of editionID.
+func (w *LocalFileWriter) cacheHash(editionID string, h FileHash) {
+	if w.hashCache == nil {
+		return
+	}
+	if err := w.hashCache.SetFileHash(editionID, h); err != nil {
+		log.Printf("Caching MD5 sum of %s: %s", editionID, err)
+	}
+}
+
+// newChecksum returns a hash computing the checksum of FileHash.
+func newChecksum() hash.Hash64 {
+	return xxhash.New()
+}
+
+// checksumString returns the checksum of FileHash computed by h.
+func checksumString(h hash.Hash64) string {
+	return checksumPrefix + byteToString(h.Sum(nil))
+}
+
+// backgroundHash writes what is written to it to a hash in another
+// goroutine, so that the MD5 sum of a database is computed in the same pass
+// as its checksum without slowing it down. It is given up if it falls
+// behind by more than backgroundHashBuffers writes, e.g., when the
+// database is read from the page cache faster than MD5 is computed.
+type backgroundHash struct {
+	h       hash.Hash
+	pending chan []byte
+	free    chan []byte
+	done    chan struct{}
+	given   bool
+}
+
+func newBackgroundHash(h hash.Hash) *backgroundHash {
+	b := &backgroundHash{
+		h:       h,
+		pending: make(chan []byte, backgroundHashBuffers),
+		free:    make(chan []byte, backgroundHashBuffers),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < backgroundHashBuffers; i++ {
+		b.free <- nil
+	}
+	go func() {
+		defer close(b.done)
+		for p := range b.pending {
+			// A hash.Hash never returns an error.
+			_, _ = b.h.Write(p)
+			b.free <- p
+		}
+	}()
+	return b
+}
+
+func (b *backgroundHash) Write(p []byte) (int, error) {
+	if b.given {
+		return len(p), nil
+	}
+	select {
+	case buf := <-b.free:
+		b.pending <- append(buf[:0], p...)
+	default:
+		b.given = true
+		close(b.pending)
+	}
+	return len(p), nil
+}
+
+// sum returns the hash of what was written, once it is computed, or false
+// if it was given up. b can't be written to afterwards.
+func (b *backgroundHash) sum() ([]byte, bool) {
+	// Once given up, the goroutine ends after the pending writes.
+	if b.given {
+		return nil, false
+	}
+	close(b.pending)
+	<-b.done
+	return b.h.Sum(nil), true
+}
+
+// hashProgress logs how much of the database at path, of size bytes, was
+// read from Reader every hashProgressInterval.
+type hashProgress struct {
+	io.Reader
+	path string
+	size int64
+	n    int64
+	next time.Time
+}
+
+func newHashProgress(r io.Reader, path string, size int64) *hashProgress {
+	return &hashProgress{
+		Reader: r,
+		path:   path,
+		size:   size,
+		next:   time.Now().Add(hashProgressInterval),
+	}
+}
+
+func (r *hashProgress) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	if now := time.Now(); now.After(r.next) && r.size > 0 {
+		log.Printf("Hashing %s: %d%% (%d of %d bytes)", r.path, r.n*100/r.size, r.n, r.size)
+		r.next = now.Add(hashProgressInterval)
+	}
+	return n, err
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mapHashCache is a HashCache holding the hashes in memory.
+type mapHashCache map[string]FileHash
+
+func (c mapHashCache) FileHash(editionID string) (FileHash, bool) {
+	h, ok := c[editionID]
+	return h, ok
+}
+
+func (c mapHashCache) SetFileHash(editionID string, h FileHash) error {
+	c[editionID] = h
+	return nil
+}
+
+// TestLocalFileWriterHashCache tests that MD5 sums are cached while the
+// databases don't change, including those of the databases written.
+func TestLocalFileWriterHashCache(t *testing.T) {
+	cache := mapHashCache{}
+	fw, err := NewLocalFileWriter(t.TempDir(), false, false, WithHashCache(cache))
+	require.NoError(t, err)
+	path := fw.getFilePath("GeoIP2-City")
+
+	// Missing databases aren't cached.
+	hash, err := fw.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, ZeroMD5, hash)
+	require.Empty(t, cache)
+
+	require.NoError(t, os.WriteFile(path, []byte("database content"), 0o600))
+	hash, err = fw.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, "cfa36ddc8279b5483a5aa25e9a6151f4", hash)
+	require.Equal(t, "cfa36ddc8279b5483a5aa25e9a6151f4", cache["GeoIP2-City"].MD5)
+	require.Equal(t, "xxh64:98c041128cbaa752", cache["GeoIP2-City"].Checksum)
+
+	// The cached MD5 sum is used while the file doesn't change.
+	cached := cache["GeoIP2-City"]
+	cached.MD5 = "cached"
+	cache["GeoIP2-City"] = cached
+	hash, err = fw.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, "cached", hash)
+
+	// A file that was only touched has the same checksum.
+	modTime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	hash, err = fw.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, "cached", hash)
+	require.True(t, modTime.Equal(cache["GeoIP2-City"].ModTime))
+
+	// A file of the same size with another content doesn't, and its MD5
+	// sum is computed in the same pass.
+	require.NoError(t, os.WriteFile(path, []byte("database CONTENT"), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime.Add(time.Minute), modTime.Add(time.Minute)))
+	hash, err = fw.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, "d7bda1dd6f5786afa1128c338e5bcb30", hash)
+	require.Equal(t, hash, cache["GeoIP2-City"].MD5)
+
+	// The hashes of the databases written are cached.
+	err = fw.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("new database content")),
+		"f8e36749e12c5ab2d2441f7fb1a80c4f",
+		time.Time{},
+	)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, FileHash{
+		MD5:      "f8e36749e12c5ab2d2441f7fb1a80c4f",
+		Checksum: cache["GeoIP2-City"].Checksum,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}, cache["GeoIP2-City"])
+	require.NotEqual(t, "xxh64:98c041128cbaa752", cache["GeoIP2-City"].Checksum)
+}
+
+// blockingHash is an MD5 hash whose writes wait for unblock to be closed.
+type blockingHash struct {
+	hash.Hash
+	unblock chan struct{}
+}
+
+func (h *blockingHash) Write(p []byte) (int, error) {
+	<-h.unblock
+	return h.Hash.Write(p)
+}
+
+// TestBackgroundHash tests that a backgroundHash computes the hash of what
+// is written to it, unless it falls too far behind.
+func TestBackgroundHash(t *testing.T) {
+	b := newBackgroundHash(md5.New())
+	for i := 0; i < 2*backgroundHashBuffers; i++ {
+		_, err := b.Write([]byte("database content"))
+		require.NoError(t, err)
+	}
+	// The MD5 sum may have fallen behind on a busy machine.
+	if sum, ok := b.sum(); ok {
+		expected := md5.Sum([]byte(strings.Repeat("database content", 2*backgroundHashBuffers)))
+		require.Equal(t, expected[:], sum)
+	}
+
+	unblock := make(chan struct{})
+	b = newBackgroundHash(&blockingHash{Hash: md5.New(), unblock: unblock})
+	for i := 0; i <= backgroundHashBuffers; i++ {
+		_, err := b.Write([]byte("database content"))
+		require.NoError(t, err)
+	}
+	close(unblock)
+	_, ok := b.sum()
+	require.False(t, ok)
+}
+
+// TestLocalFileWriterGetHashCancel tests that hashing stops once the
+// context is done.
+func TestLocalFileWriterGetHashCancel(t *testing.T) {
+	fw, err := NewLocalFileWriter(t.TempDir(), false, false)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fw.getFilePath("GeoIP2-City"), []byte("database content"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fw.GetHash(ctx, "GeoIP2-City")
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -2,6 +2,7 @@
 package database
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
@@ -12,20 +13,175 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	extension     = ".mmdb"
 	tempExtension = ".temporary"
+	badExtension  = ".bad"
 )
 
+// Write strategies supported by LocalFileWriter.
+const (
+	// WriteStrategyRename writes the database to a temporary file in the
+	// database directory and renames it into place.
+	WriteStrategyRename = "rename"
+	// WriteStrategyCopy writes the database to a temporary file on local
+	// disk, copies it to the database directory, verifies the copy, and
+	// renames it into place. It is meant for network file systems.
+	WriteStrategyCopy = "copy"
+)
+
+// ChecksumMismatchError is returned by LocalFileWriter.Write if the MD5 sum
+// of the database read isn't the expected one.
+type ChecksumMismatchError struct {
+	// Expected is the MD5 sum the database was expected to have and Actual
+	// the one it has.
+	Expected string
+	Actual   string
+	// Bytes is the size of the database read.
+	Bytes int64
+	// BadFile is the path the database was kept at if WithKeepBadFiles is
+	// set.
+	BadFile string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf(
+		"md5 of new database (%s) does not match expected md5 (%s)",
+		e.Actual,
+		e.Expected,
+	)
+}
+
+// DowngradeError is returned by LocalFileWriter.Write if the database read
+// was built before the installed one, e.g., because a mirror or a CDN cache
+// served a stale file.
+type DowngradeError struct {
+	// Installed and New are the build times of the installed database and
+	// of the database read.
+	Installed time.Time
+	New       time.Time
+}
+
+func (e *DowngradeError) Error() string {
+	return fmt.Sprintf(
+		"refusing to replace the database built at %s with an older one built at %s",
+		e.Installed.Format(time.RFC3339),
+		e.New.Format(time.RFC3339),
+	)
+}
+
+// ConflictError is returned by LocalFileWriter.Write with WithFence if
+// another writer installed a build of the database at least as recent as
+// the one read while it was written, which is then not installed.
+type ConflictError struct {
+	// Installed and New are the build times of the database installed by
+	// the other writer and of the database read.
+	Installed time.Time
+	New       time.Time
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(
+		"another writer installed the database built at %s, keeping it rather than the one built at %s",
+		e.Installed.Format(time.RFC3339),
+		e.New.Format(time.RFC3339),
+	)
+}
+
 // LocalFileWriter is a database.Writer that stores the database to the
 // local file system.
 type LocalFileWriter struct {
-	dir              string
-	preserveFileTime bool
-	verbose          bool
+	dir                 string
+	allowDowngrade      bool
+	archiveDir          string
+	archiveUploader     ArchiveUploader
+	consumerLockTimeout time.Duration
+	fence               func() error
+	hashCache           HashCache
+	keepBadFiles        bool
+	maxDiskUsage        int64
+	preserveFileTime    bool
+	strategy            string
+	tempDir             string
+	verbose             bool
+
+	// diskMu guards diskUsed and diskReserved, and the removal of archived
+	// copies to stay within maxDiskUsage.
+	diskMu sync.Mutex
+	// diskUsed is the disk usage of the files when last read.
+	diskUsed int64
+	// diskReserved is the room reserved by the writes in progress.
+	diskReserved int64
+}
+
+// LocalFileWriterOption is an option for configuring LocalFileWriter.
+type LocalFileWriterOption func(*LocalFileWriter)
+
+// WithArchiveDirectory sets a directory where the database being replaced
+// is copied to before the new one is moved into place. Archived copies are
+// named after the edition and the build time of the replaced database.
+func WithArchiveDirectory(dir string) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.archiveDir = dir
+	}
+}
+
+// WithConsumerLockTimeout enables the consumer lock protocol. Before
+// replacing a database, the writer waits up to timeout for an exclusive lock
+// on the database's sentinel file, e.g., GeoIP2-City.mmdb.lock. Consumers
+// that hold a shared lock on that file while reading the database are
+// guaranteed that it isn't swapped out from under them.
+func WithConsumerLockTimeout(timeout time.Duration) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.consumerLockTimeout = timeout
+	}
+}
+
+// WithAllowDowngrade makes the writer replace databases with databases
+// built before them rather than return a *DowngradeError.
+func WithAllowDowngrade() LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.allowDowngrade = true
+	}
+}
+
+// WithFence makes the writer call fence right before moving each database
+// into place, failing if it does, e.g., because the writer lost the lock
+// excluding other writers sharing the database directory. The database is
+// then only moved into place if it was built after the installed one,
+// which other writers may have replaced, and a *ConflictError is returned
+// otherwise.
+func WithFence(fence func() error) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.fence = fence
+	}
+}
+
+// WithKeepBadFiles makes the writer keep databases whose MD5 sum doesn't
+// match, renamed with a .bad suffix, e.g., GeoIP2-City.mmdb.bad, rather than
+// delete them, so corrupted downloads can be investigated. Only the last one
+// is kept for each edition.
+func WithKeepBadFiles() LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.keepBadFiles = true
+	}
+}
+
+// WithCopyStrategy makes the writer use WriteStrategyCopy. Databases are
+// first written to tempDir, which should be on local disk. If tempDir is
+// empty, the default directory for temporary files is used.
+func WithCopyStrategy(tempDir string) LocalFileWriterOption {
+	return func(w *LocalFileWriter) {
+		w.strategy = WriteStrategyCopy
+		w.tempDir = tempDir
+		if w.tempDir == "" {
+			w.tempDir = os.TempDir()
+		}
+	}
 }
 
 // NewLocalFileWriter create a LocalFileWriter.
@@ -33,29 +189,48 @@ func NewLocalFileWriter(
 	databaseDir string,
 	preserveFileTime bool,
 	verbose bool,
+	options ...LocalFileWriterOption,
 ) (*LocalFileWriter, error) {
 	err := os.MkdirAll(filepath.Dir(databaseDir), 0o750)
 	if err != nil {
 		return nil, fmt.Errorf("creating database directory: %w", err)
 	}
 
-	return &LocalFileWriter{
+	w := &LocalFileWriter{
 		dir:              databaseDir,
 		preserveFileTime: preserveFileTime,
+		strategy:         WriteStrategyRename,
 		verbose:          verbose,
-	}, nil
+	}
+
+	for _, opt := range options {
+		opt(w)
+	}
+
+	if w.archiveDir != "" {
+		if err := os.MkdirAll(w.archiveDir, 0o750); err != nil {
+			return nil, fmt.Errorf("creating archive directory: %w", err)
+		}
+	}
+
+	return w, nil
 }
 
 // Write writes the database to a file. The database content will be read from
-// reader.
+// reader. Once ctx is done, reading and syncing are abandoned and the
+// installed database is left as is.
 func (w *LocalFileWriter) Write(
+	ctx context.Context,
 	editionID string,
 	reader io.ReadCloser,
 	newMD5 string,
 	lastModified time.Time,
 ) (err error) {
 	defer func() {
-		_, _ = io.Copy(io.Discard, reader) //nolint:errcheck // Best effort.
+		// Draining a slow reader would delay the cancellation.
+		if ctx.Err() == nil {
+			_, _ = io.Copy(io.Discard, reader) //nolint:errcheck // Best effort.
+		}
 		if closeErr := reader.Close(); closeErr != nil {
 			err = errors.Join(
 				err,
@@ -66,8 +241,21 @@ func (w *LocalFileWriter) Write(
 
 	databaseFilePath := w.getFilePath(editionID)
 
+	tempPath := databaseFilePath + tempExtension
+	if w.strategy == WriteStrategyCopy {
+		tempPath = filepath.Join(w.tempDir, filepath.Base(tempPath))
+	}
+
+	// make sure the database fits within the disk usage budget before
+	// writing it.
+	budget, err := w.reserveDisk(editionID, databaseFilePath)
+	if err != nil {
+		return fmt.Errorf("checking disk usage for %s: %w", editionID, err)
+	}
+	defer budget.release()
+
 	// Write into a temporary file.
-	fw, err := newFileWriter(databaseFilePath + tempExtension)
+	fw, err := newFileWriter(tempPath)
 	if err != nil {
 		return fmt.Errorf("setting up database writer for %s: %w", editionID, err)
 	}
@@ -80,18 +268,88 @@ func (w *LocalFileWriter) Write(
 		}
 	}()
 
-	if err = fw.write(reader); err != nil {
+	if err = fw.write(ctx, budget.reader(reader)); err != nil {
 		return fmt.Errorf("writing to the temp file for %s: %w", editionID, err)
 	}
 
 	// make sure the hash of the temp file matches the expected hash.
 	if err = fw.validateHash(newMD5); err != nil {
+		var mismatch *ChecksumMismatchError
+		if w.keepBadFiles && errors.As(err, &mismatch) {
+			badPath := strings.TrimSuffix(tempPath, tempExtension) + badExtension
+			if keepErr := fw.keep(badPath); keepErr != nil {
+				err = errors.Join(err, keepErr)
+			} else {
+				mismatch.BadFile = badPath
+			}
+		}
 		return fmt.Errorf("validating hash for %s: %w", editionID, err)
 	}
 
+	// make sure the new database isn't older than the one it replaces. With
+	// a fence, this is checked right before the database is moved into
+	// place.
+	if !w.allowDowngrade && w.fence == nil {
+		if err = checkDowngrade(databaseFilePath, tempPath); err != nil {
+			return fmt.Errorf("checking build date of %s: %w", editionID, err)
+		}
+	}
+
+	// when copying, stage a verified copy next to the database. It is the
+	// file that gets moved into place.
+	staged := fw
+	if w.strategy == WriteStrategyCopy {
+		staged, err = copyToTarget(ctx, fw, databaseFilePath+tempExtension, newMD5)
+		if err != nil {
+			return fmt.Errorf("copying database for %s: %w", editionID, err)
+		}
+		defer func() {
+			if closeErr := staged.close(); closeErr != nil {
+				err = errors.Join(
+					err,
+					fmt.Errorf("closing copied file writer: %w", closeErr),
+				)
+			}
+		}()
+	}
+
+	// keep a copy of the database we are about to replace.
+	if w.archiveDir != "" || w.archiveUploader != nil {
+		if err = w.archive(ctx, editionID, databaseFilePath); err != nil {
+			return fmt.Errorf("archiving database for %s: %w", editionID, err)
+		}
+	}
+
+	// wait until no consumer is using the database before replacing it.
+	if w.consumerLockTimeout > 0 {
+		var lock *consumerLock
+		lock, err = w.acquireConsumerLock(databaseFilePath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if releaseErr := lock.release(); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+		}()
+	}
+
+	// make sure this writer may still write, and that no other writer
+	// installed a build at least as recent in the meantime.
+	if w.fence != nil {
+		if err = w.fence(); err != nil {
+			return fmt.Errorf("fencing the write of %s: %w", editionID, err)
+		}
+		if !w.allowDowngrade {
+			if err = checkConflict(databaseFilePath, tempPath); err != nil {
+				return fmt.Errorf("checking build date of %s: %w", editionID, err)
+			}
+		}
+	}
+
 	// move the temoporary database file into its final location and
 	// sync the directory.
-	if err = fw.syncAndRename(databaseFilePath); err != nil {
+	if err = staged.syncAndRename(ctx, databaseFilePath); err != nil {
 		return fmt.Errorf("renaming temp file: %w", err)
 	}
 
@@ -107,6 +365,18 @@ func (w *LocalFileWriter) Write(
 		}
 	}
 
+	// remember the hash of the new database so that it isn't read again.
+	if w.hashCache != nil {
+		if info, statErr := os.Stat(databaseFilePath); statErr == nil {
+			w.cacheHash(editionID, FileHash{
+				MD5:      byteToString(staged.md5Writer.Sum(nil)),
+				Checksum: checksumString(staged.checksum),
+				Size:     info.Size(),
+				ModTime:  info.ModTime(),
+			})
+		}
+	}
+
 	if w.verbose {
 		log.Printf("Database %s successfully updated: %+v", editionID, newMD5)
 	}
@@ -114,8 +384,10 @@ func (w *LocalFileWriter) Write(
 	return nil
 }
 
-// GetHash returns the hash of the current database file.
-func (w *LocalFileWriter) GetHash(editionID string) (string, error) {
+// GetHash returns the hash of the current database file, which is read
+// until ctx is done unless its hash is cached. A database that can't be
+// read in full is treated as missing so that it is downloaded again.
+func (w *LocalFileWriter) GetHash(ctx context.Context, editionID string) (string, error) {
 	databaseFilePath := w.getFilePath(editionID)
 	//nolint:gosec // we really need to read this file.
 	database, err := os.Open(databaseFilePath)
@@ -135,21 +407,113 @@ func (w *LocalFileWriter) GetHash(editionID string) (string, error) {
 		}
 	}()
 
+	info, err := database.Stat()
+	if err != nil {
+		return unreadableHash(ctx, databaseFilePath, err)
+	}
+	cached, ok := w.cachedHash(editionID)
+	if ok && cached.Size == info.Size() {
+		if cached.ModTime.Equal(info.ModTime()) {
+			if w.verbose {
+				log.Printf("Using cached MD5 sum for %s: %s", databaseFilePath, cached.MD5)
+			}
+			return cached.MD5, nil
+		}
+		// The file may have been touched, or copied over with the same
+		// content, which its checksum tells faster than its MD5 sum. The
+		// MD5 sum is computed in the same pass in case the content
+		// changed.
+		if cached.Checksum != "" {
+			start := time.Now()
+			checksum := newChecksum()
+			md5Hash := newBackgroundHash(md5.New())
+			err := w.hashFile(ctx, database, info.Size(), checksum, md5Hash)
+			md5Sum, ok := md5Hash.sum()
+			if err != nil {
+				return unreadableHash(ctx, databaseFilePath, err)
+			}
+			if checksumString(checksum) == cached.Checksum {
+				if w.verbose {
+					log.Printf("Database %s is unchanged, using cached MD5 sum: %s", databaseFilePath, cached.MD5)
+				}
+				cached.ModTime = info.ModTime()
+				w.cacheHash(editionID, cached)
+				return cached.MD5, nil
+			}
+			if ok {
+				return w.cacheComputedHash(editionID, databaseFilePath, info, md5Sum, checksum, start), nil
+			}
+			// The MD5 sum fell behind, so the file is read again.
+		}
+	}
+
+	start := time.Now()
 	md5Hash := md5.New()
-	if _, err := io.Copy(md5Hash, database); err != nil {
-		return "", fmt.Errorf("calculating database hash: %w", err)
+	checksum := newChecksum()
+	if err := w.hashFile(ctx, database, info.Size(), md5Hash, checksum); err != nil {
+		return unreadableHash(ctx, databaseFilePath, err)
+	}
+	return w.cacheComputedHash(editionID, databaseFilePath, info, md5Hash.Sum(nil), checksum, start), nil
+}
+
+// cacheComputedHash caches md5Sum and checksum, computed since start, as
+// the hashes of the database of editionID at path, described by info, and
+// returns its MD5 sum.
+func (w *LocalFileWriter) cacheComputedHash(
+	editionID, path string,
+	info os.FileInfo,
+	md5Sum []byte,
+	checksum hash.Hash64,
+	start time.Time,
+) string {
+	result := byteToString(md5Sum)
+	if w.verbose {
+		log.Printf("Calculated MD5 sum for %s in %s: %s", path, time.Since(start), result)
 	}
+	w.cacheHash(editionID, FileHash{
+		MD5:      result,
+		Checksum: checksumString(checksum),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	})
+	return result
+}
 
-	result := byteToString(md5Hash.Sum(nil))
+// hashFile writes the content of f, of size bytes, to hashes until ctx is
+// done, logging the progress in verbose mode.
+func (w *LocalFileWriter) hashFile(ctx context.Context, f *os.File, size int64, hashes ...io.Writer) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking: %w", err)
+	}
+	var reader io.Reader = &contextReader{ctx: ctx, Reader: f}
 	if w.verbose {
-		log.Printf("Calculated MD5 sum for %s: %s", databaseFilePath, result)
+		reader = newHashProgress(reader, f.Name(), size)
+	}
+	if _, err := io.Copy(io.MultiWriter(hashes...), reader); err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+	return nil
+}
+
+// unreadableHash returns the result of GetHash for the database at path
+// that couldn't be read because of err: the error of ctx if it is done,
+// and otherwise ZeroMD5, so that the database is downloaded again.
+func unreadableHash(ctx context.Context, path string, err error) (string, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, ctxErr)
 	}
-	return result, nil
+	log.Printf("Database %s is unreadable, downloading it again: %s", path, err)
+	return ZeroMD5, nil
 }
 
 // getFilePath construct the file path for a database edition.
 func (w *LocalFileWriter) getFilePath(editionID string) string {
-	return filepath.Join(w.dir, editionID) + extension
+	return FilePath(w.dir, editionID)
+}
+
+// FilePath returns the path of the database of an edition in dir.
+func FilePath(dir, editionID string) string {
+	return filepath.Join(dir, editionID) + extension
 }
 
 // fileWriter is used to write the content of a database into a file.
@@ -158,6 +522,11 @@ type fileWriter struct {
 	file *os.File
 	// md5Writer is used to verify the integrity of the received data.
 	md5Writer hash.Hash
+	// checksum is the fast checksum of the data, cached to tell whether
+	// the database changed.
+	checksum hash.Hash64
+	// n is the number of bytes written.
+	n int64
 }
 
 // newFileWriter initializes a new fileWriter struct.
@@ -172,6 +541,7 @@ func newFileWriter(path string) (*fileWriter, error) {
 	return &fileWriter{
 		file:      file,
 		md5Writer: md5.New(),
+		checksum:  newChecksum(),
 	}, nil
 }
 
@@ -192,27 +562,43 @@ func (w *fileWriter) close() error {
 	return nil
 }
 
-// write writes the content of r to the file.
-func (w *fileWriter) write(r io.Reader) error {
-	writer := io.MultiWriter(w.md5Writer, w.file)
-	if _, err := io.Copy(writer, r); err != nil {
+// write writes the content of r to the file until ctx is done.
+func (w *fileWriter) write(ctx context.Context, r io.Reader) error {
+	writer := io.MultiWriter(w.md5Writer, w.checksum, w.file)
+	n, err := io.Copy(writer, &contextReader{ctx: ctx, Reader: r})
+	w.n += n
+	if err != nil {
 		return fmt.Errorf("writing database: %w", err)
 	}
 	return nil
 }
 
-// validateHash validates the hash of the file against a known value.
+// validateHash validates the hash of the file against a known value. If it
+// doesn't match, a *ChecksumMismatchError is returned.
 func (w *fileWriter) validateHash(h string) error {
 	tempFileHash := byteToString(w.md5Writer.Sum(nil))
 	if !strings.EqualFold(h, tempFileHash) {
-		return fmt.Errorf("md5 of new database (%s) does not match expected md5 (%s)", tempFileHash, h)
+		return &ChecksumMismatchError{Expected: h, Actual: tempFileHash, Bytes: w.n}
 	}
 	return nil
 }
 
-// syncAndRename syncs the content of the file to storage and renames it.
-func (w *fileWriter) syncAndRename(name string) error {
-	if err := w.file.Sync(); err != nil {
+// keep closes the file and renames it to name, so it isn't deleted by
+// close.
+func (w *fileWriter) keep(name string) error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+	if err := os.Rename(w.file.Name(), name); err != nil {
+		return fmt.Errorf("keeping bad database: %w", err)
+	}
+	return nil
+}
+
+// syncAndRename syncs the content of the file to storage and renames it,
+// unless ctx is done first.
+func (w *fileWriter) syncAndRename(ctx context.Context, name string) error {
+	if err := syncFile(ctx, w.file); err != nil {
 		return fmt.Errorf("syncing temporary file: %w", err)
 	}
 	if err := w.file.Close(); err != nil {
@@ -224,6 +610,39 @@ func (w *fileWriter) syncAndRename(name string) error {
 	return nil
 }
 
+// syncFile syncs the content of f to storage. It returns the error of ctx
+// if ctx is done first, e.g., so that a network file system that is slow
+// to respond doesn't block shutdown, and the sync completes in the
+// background.
+func syncFile(ctx context.Context, f *os.File) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f.Sync()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextReader stops reading from Reader once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
 // syncDir syncs the content of a directory to storage.
 func syncDir(path string) error {
 	// fsync the directory. https://austingroupbugs.net/view.php?id=672
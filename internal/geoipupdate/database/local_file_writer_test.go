@@ -1,8 +1,14 @@
 package database
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -73,6 +79,7 @@ func TestLocalFileWriterWrite(t *testing.T) {
 			require.NoError(t, err)
 
 			err = fw.Write(
+				context.Background(),
 				test.editionID,
 				test.reader,
 				test.newMD5,
@@ -101,16 +108,226 @@ func TestLocalFileWriterGetHash(t *testing.T) {
 	fw, err := NewLocalFileWriter(tempDir, false, false)
 	require.NoError(t, err)
 
-	err = fw.Write(editionID, reader, newMD5, lastModified)
+	err = fw.Write(context.Background(), editionID, reader, newMD5, lastModified)
 	require.NoError(t, err)
 
 	// returns the correct hash for an existing database.
-	hash, err := fw.GetHash(editionID)
+	hash, err := fw.GetHash(context.Background(), editionID)
 	require.NoError(t, err)
 	require.Equal(t, hash, newMD5)
 
 	// returns a zero hash for a non existing edition.
-	hash, err = fw.GetHash("NewEdition")
+	hash, err = fw.GetHash(context.Background(), "NewEdition")
 	require.NoError(t, err)
 	require.Equal(t, ZeroMD5, hash)
 }
+
+// TestLocalFileWriterGetHashUnreadable tests that an unreadable database is
+// treated as missing.
+func TestLocalFileWriterGetHashUnreadable(t *testing.T) {
+	tempDir := t.TempDir()
+
+	fw, err := NewLocalFileWriter(tempDir, false, false)
+	require.NoError(t, err)
+
+	// Reading a directory fails after opening it.
+	require.NoError(t, os.Mkdir(fw.getFilePath("GeoIP2-City"), 0o750))
+
+	hash, err := fw.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, ZeroMD5, hash)
+}
+
+// TestLocalFileWriterKeepBadFiles tests that databases whose hash doesn't
+// match are kept, and only with WithKeepBadFiles.
+func TestLocalFileWriterKeepBadFiles(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		tempDir := t.TempDir()
+
+		var options []LocalFileWriterOption
+		if keep {
+			options = append(options, WithKeepBadFiles())
+		}
+		fw, err := NewLocalFileWriter(tempDir, false, false, options...)
+		require.NoError(t, err)
+
+		err = fw.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(strings.NewReader("database content")),
+			"badhash",
+			time.Time{},
+		)
+		var mismatch *ChecksumMismatchError
+		require.ErrorAs(t, err, &mismatch)
+		require.Equal(t, "badhash", mismatch.Expected)
+		require.Equal(t, "cfa36ddc8279b5483a5aa25e9a6151f4", mismatch.Actual)
+		require.Equal(t, int64(16), mismatch.Bytes)
+
+		badFile := filepath.Join(tempDir, "GeoIP2-City.mmdb.bad")
+		if !keep {
+			require.Empty(t, mismatch.BadFile)
+			require.NoFileExists(t, badFile)
+			continue
+		}
+		require.Equal(t, badFile, mismatch.BadFile)
+		content, err := os.ReadFile(badFile)
+		require.NoError(t, err)
+		require.Equal(t, "database content", string(content))
+		require.NoFileExists(t, fw.getFilePath("GeoIP2-City")+tempExtension)
+	}
+}
+
+// TestLocalFileWriterDowngrade tests that databases are only replaced with
+// older builds with WithAllowDowngrade.
+func TestLocalFileWriterDowngrade(t *testing.T) {
+	installed := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	stale := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, allow := range []bool{false, true} {
+		tempDir := t.TempDir()
+
+		var options []LocalFileWriterOption
+		if allow {
+			options = append(options, WithAllowDowngrade())
+		}
+		fw, err := NewLocalFileWriter(tempDir, false, false, options...)
+		require.NoError(t, err)
+
+		path := fw.getFilePath("GeoIP2-City")
+		writeTestMMDB(t, path, installed)
+
+		stalePath := filepath.Join(t.TempDir(), "stale.mmdb")
+		writeTestMMDB(t, stalePath, stale)
+		content, err := os.ReadFile(stalePath)
+		require.NoError(t, err)
+		sum := md5.Sum(content)
+
+		err = fw.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(bytes.NewReader(content)),
+			hex.EncodeToString(sum[:]),
+			time.Time{},
+		)
+		if allow {
+			require.NoError(t, err)
+			built, err := buildTime(path)
+			require.NoError(t, err)
+			require.Equal(t, stale, built)
+			continue
+		}
+		var downgrade *DowngradeError
+		require.ErrorAs(t, err, &downgrade)
+		require.Equal(t, installed, downgrade.Installed)
+		require.Equal(t, stale, downgrade.New)
+		built, err := buildTime(path)
+		require.NoError(t, err)
+		require.Equal(t, installed, built)
+	}
+}
+
+// TestLocalFileWriterFence tests that databases aren't moved into place if
+// the fence fails, or if another writer installed a build at least as
+// recent.
+func TestLocalFileWriterFence(t *testing.T) {
+	older := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tempDir := t.TempDir()
+	var fenceErr error
+	fw, err := NewLocalFileWriter(tempDir, false, false, WithFence(func() error { return fenceErr }))
+	require.NoError(t, err)
+	path := fw.getFilePath("GeoIP2-City")
+
+	write := func(built time.Time) error {
+		dbPath := filepath.Join(t.TempDir(), "new.mmdb")
+		writeTestMMDB(t, dbPath, built)
+		content, err := os.ReadFile(dbPath)
+		require.NoError(t, err)
+		sum := md5.Sum(content)
+		return fw.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(bytes.NewReader(content)),
+			hex.EncodeToString(sum[:]),
+			time.Time{},
+		)
+	}
+
+	fenceErr = errors.New("lock lost")
+	require.ErrorIs(t, write(older), fenceErr)
+	require.NoFileExists(t, path)
+
+	fenceErr = nil
+	require.NoError(t, write(older))
+
+	// Another writer installed a more recent build in the meantime.
+	writeTestMMDB(t, path, newer)
+	var conflict *ConflictError
+	require.ErrorAs(t, write(older), &conflict)
+	require.Equal(t, newer, conflict.Installed)
+	require.Equal(t, older, conflict.New)
+	require.ErrorAs(t, write(newer), &conflict)
+	built, err := buildTime(path)
+	require.NoError(t, err)
+	require.Equal(t, newer, built)
+}
+
+// endlessReader reads zeros forever, calling onRead before each read.
+type endlessReader struct {
+	onRead func()
+}
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	r.onRead()
+	clear(p)
+	return len(p), nil
+}
+
+// TestLocalFileWriterCancel tests that writes are abandoned once their
+// context is done, without draining the reader or touching the installed
+// database.
+func TestLocalFileWriterCancel(t *testing.T) {
+	for _, strategy := range []string{WriteStrategyRename, WriteStrategyCopy} {
+		t.Run(strategy, func(t *testing.T) {
+			databaseDir := t.TempDir()
+			tempDir := t.TempDir()
+
+			var options []LocalFileWriterOption
+			if strategy == WriteStrategyCopy {
+				options = append(options, WithCopyStrategy(tempDir))
+			}
+			fw, err := NewLocalFileWriter(databaseDir, false, false, options...)
+			require.NoError(t, err)
+
+			path := fw.getFilePath("GeoIP2-City")
+			require.NoError(t, os.WriteFile(path, []byte("installed"), 0o600))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			reads := 0
+			reader := endlessReader{onRead: func() {
+				reads++
+				if reads == 10 {
+					cancel()
+				}
+			}}
+
+			err = fw.Write(ctx, "GeoIP2-City", io.NopCloser(reader), "", time.Time{})
+			require.ErrorIs(t, err, context.Canceled)
+			require.Equal(t, 10, reads)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, "installed", string(content))
+			for _, dir := range []string{databaseDir, tempDir} {
+				entries, err := os.ReadDir(dir)
+				require.NoError(t, err)
+				for _, entry := range entries {
+					require.NotEqual(t, tempExtension, filepath.Ext(entry.Name()))
+				}
+			}
+		})
+	}
+}
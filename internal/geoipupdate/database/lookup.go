@@ -0,0 +1,61 @@
+package database
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// LookupResult is the result of looking up an IP address in a database, with
+// the fields of the record common to the MaxMind databases that tell
+// whether it is plausible.
+type LookupResult struct {
+	IP string `json:"ip"`
+	// Found is true if the database has a record for IP.
+	Found bool `json:"found"`
+	// Network is the network of the record.
+	Network string `json:"network,omitempty"`
+	// Country is the ISO code of the country of the record.
+	Country string `json:"country,omitempty"`
+	// City is the English name of the city of the record.
+	City string `json:"city,omitempty"`
+	// ASN is the autonomous system number of the record.
+	ASN uint `json:"asn,omitempty"`
+	// Error is why the lookup couldn't be done.
+	Error string `json:"error,omitempty"`
+}
+
+// lookupRecord holds the fields of LookupResult in a record.
+type lookupRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN uint `maxminddb:"autonomous_system_number"`
+}
+
+// Lookup looks ip up in the database at path.
+func Lookup(path string, ip net.IP) (LookupResult, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return LookupResult{}, fmt.Errorf("opening database %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	var record lookupRecord
+	network, found, err := reader.LookupNetwork(ip, &record)
+	if err != nil {
+		return LookupResult{}, fmt.Errorf("looking up %s in %s: %w", ip, path, err)
+	}
+	result := LookupResult{IP: ip.String(), Found: found}
+	if found {
+		result.Network = network.String()
+		result.Country = record.Country.ISOCode
+		result.City = record.City.Names["en"]
+		result.ASN = record.ASN
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "GeoIP2-City", RecordSize: 24})
+	require.NoError(t, err)
+	_, network, err := net.ParseCIDR("81.2.69.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+		"city": mmdbtype.Map{
+			"names": mmdbtype.Map{"en": mmdbtype.String("London")},
+		},
+	}))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	result, err := Lookup(path, net.ParseIP("81.2.69.142"))
+	require.NoError(t, err)
+	assert.Equal(t, LookupResult{
+		IP:      "81.2.69.142",
+		Found:   true,
+		Network: "81.2.69.0/24",
+		Country: "GB",
+		City:    "London",
+	}, result)
+
+	result, err = Lookup(path, net.ParseIP("1.1.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, LookupResult{IP: "1.1.1.1"}, result)
+
+	_, err = Lookup(filepath.Join(t.TempDir(), "missing.mmdb"), net.ParseIP("1.1.1.1"))
+	require.Error(t, err)
+}
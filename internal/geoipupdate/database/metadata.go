@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Validate returns an error if the file at path isn't an MMDB file with a
+// readable metadata section, as is the case of truncated databases.
+func Validate(path string) error {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+	return reader.Close()
+}
+
+// checkDowngrade returns a *DowngradeError if the database at newPath was
+// built before the one at installedPath. Databases whose build time can't be
+// read, e.g., because there is no installed database yet, aren't compared.
+func checkDowngrade(installedPath, newPath string) error {
+	installed, err := buildTime(installedPath)
+	if err != nil {
+		return nil //nolint:nilerr // there is nothing to compare to.
+	}
+	built, err := buildTime(newPath)
+	if err != nil {
+		return nil //nolint:nilerr // see above.
+	}
+	if built.Before(installed) {
+		return &DowngradeError{Installed: installed, New: built}
+	}
+	return nil
+}
+
+// checkConflict returns a *ConflictError unless the database at newPath was
+// built after the one at installedPath. Databases whose build time can't be
+// read aren't compared.
+func checkConflict(installedPath, newPath string) error {
+	installed, err := buildTime(installedPath)
+	if err != nil {
+		return nil //nolint:nilerr // there is nothing to compare to.
+	}
+	built, err := buildTime(newPath)
+	if err != nil {
+		return nil //nolint:nilerr // see above.
+	}
+	if !built.After(installed) {
+		return &ConflictError{Installed: installed, New: built}
+	}
+	return nil
+}
+
+// buildTime returns the build time recorded in the metadata section of the
+// MMDB file at path.
+func buildTime(path string) (time.Time, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("opening database %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	//nolint:gosec // build epochs fit in an int64.
+	return time.Unix(int64(reader.Metadata.BuildEpoch), 0).In(time.UTC), nil
+}
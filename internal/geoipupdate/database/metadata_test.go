@@ -0,0 +1,22 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	writeTestMMDB(t, path, time.Now())
+	require.NoError(t, Validate(path))
+
+	// A database truncated, e.g., by a crash, lacks its metadata section.
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, content[:len(content)/2], 0o600))
+	require.Error(t, Validate(path))
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// WriterMiddleware wraps a Writer to add a behavior to it, e.g., to observe
+// the databases written, rather than adding it to the Writer itself.
+type WriterMiddleware func(Writer) Writer
+
+// ChainWriter returns w wrapped by middlewares. The first middleware is the
+// outermost one, i.e., the first to see the databases written.
+func ChainWriter(w Writer, middlewares ...WriterMiddleware) Writer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		w = middlewares[i](w)
+	}
+	return w
+}
+
+// WriteStats describes a database written through ObserveWrites.
+type WriteStats struct {
+	EditionID string
+	// Bytes is the size of the database read by the writer.
+	Bytes    int64
+	Duration time.Duration
+	// Err is the error writing the database, if any.
+	Err error
+}
+
+// ObserveWrites returns a WriterMiddleware calling observe after each
+// database is written, e.g., to export metrics about them.
+func ObserveWrites(observe func(WriteStats)) WriterMiddleware {
+	return func(next Writer) Writer {
+		return &observingWriter{Writer: next, observe: observe}
+	}
+}
+
+type observingWriter struct {
+	Writer
+	observe func(WriteStats)
+}
+
+func (w *observingWriter) Write(
+	ctx context.Context,
+	editionID string,
+	reader io.ReadCloser,
+	md5 string,
+	lastModified time.Time,
+) error {
+	counter := &countingReader{ReadCloser: reader}
+	start := time.Now()
+	err := w.Writer.Write(ctx, editionID, counter, md5, lastModified)
+	w.observe(WriteStats{
+		EditionID: editionID,
+		Bytes:     counter.n,
+		Duration:  time.Since(start),
+		Err:       err,
+	})
+	return err
+}
+
+// countingReader counts the bytes read from the wrapped reader.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
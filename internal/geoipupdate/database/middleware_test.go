@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestObserveWrites tests that the databases written through a chain of
+// middlewares are observed by each of them, in order.
+func TestObserveWrites(t *testing.T) {
+	tempDir := t.TempDir()
+	fw, err := NewLocalFileWriter(tempDir, false, false)
+	require.NoError(t, err)
+
+	var observed []string
+	var stats WriteStats
+	w := ChainWriter(
+		fw,
+		ObserveWrites(func(s WriteStats) {
+			observed = append(observed, "outer")
+			stats = s
+		}),
+		ObserveWrites(func(WriteStats) { observed = append(observed, "inner") }),
+	)
+
+	err = w.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("database content")),
+		"cfa36ddc8279b5483a5aa25e9a6151f4",
+		time.Time{},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inner", "outer"}, observed)
+	assert.Equal(t, "GeoIP2-City", stats.EditionID)
+	assert.Equal(t, int64(len("database content")), stats.Bytes)
+	require.NoError(t, stats.Err)
+
+	hash, err := w.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	assert.Equal(t, "cfa36ddc8279b5483a5aa25e9a6151f4", hash)
+
+	err = w.Write(
+		context.Background(),
+		"GeoIP2-City",
+		io.NopCloser(strings.NewReader("corrupted")),
+		"cfa36ddc8279b5483a5aa25e9a6151f4",
+		time.Time{},
+	)
+	require.Error(t, err)
+	assert.Equal(t, err, stats.Err)
+}
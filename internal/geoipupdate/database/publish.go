@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Publish installs the databases of editionIDs staged in dir, e.g., by
+// another LocalFileWriter, in the directory of w. Those that are the same
+// files as the installed ones, e.g., hard links to them, are left as is, and
+// the installed databases missing from dir are removed. Each database is
+// moved into place with the consumer lock and the fence of w, if any.
+func (w *LocalFileWriter) Publish(ctx context.Context, dir string, editionIDs []string) error {
+	published := false
+	for _, editionID := range editionIDs {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("publishing %s: %w", editionID, err)
+		}
+		staged := FilePath(dir, editionID)
+		target := w.getFilePath(editionID)
+
+		stagedInfo, err := os.Stat(staged)
+		removed := errors.Is(err, os.ErrNotExist)
+		if err != nil && !removed {
+			return fmt.Errorf("checking staged database %s: %w", staged, err)
+		}
+		targetInfo, err := os.Stat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			if removed {
+				continue
+			}
+		} else if err != nil {
+			return fmt.Errorf("checking database %s: %w", target, err)
+		} else if !removed && os.SameFile(stagedInfo, targetInfo) {
+			continue
+		}
+
+		if err := w.publish(staged, target, removed); err != nil {
+			return fmt.Errorf("publishing %s: %w", editionID, err)
+		}
+		published = true
+		if w.verbose {
+			log.Printf("Database %s successfully published", editionID)
+		}
+	}
+	if !published {
+		return nil
+	}
+	if err := syncDir(w.dir); err != nil {
+		return fmt.Errorf("syncing database directory: %w", err)
+	}
+	return nil
+}
+
+// publish moves the database at staged to target, or removes the database
+// at target if removed.
+func (w *LocalFileWriter) publish(staged, target string, removed bool) (err error) {
+	if w.consumerLockTimeout > 0 {
+		var lock *consumerLock
+		lock, err = w.acquireConsumerLock(target)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if releaseErr := lock.release(); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+		}()
+	}
+	if w.fence != nil {
+		if err = w.fence(); err != nil {
+			return fmt.Errorf("fencing: %w", err)
+		}
+	}
+
+	if removed {
+		if err = os.Remove(target); err != nil {
+			return fmt.Errorf("removing %s: %w", target, err)
+		}
+		return nil
+	}
+	if err = os.Rename(staged, target); err != nil {
+		return fmt.Errorf("moving %s into place: %w", staged, err)
+	}
+	return nil
+}
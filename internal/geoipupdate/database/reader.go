@@ -20,6 +20,67 @@ type ReadResult struct {
 	NewHash    string    `json:"new_hash"`
 	ModifiedAt time.Time `json:"modified_at"`
 	CheckedAt  time.Time `json:"checked_at"`
+	// Cached is true if the API was not contacted because the installed
+	// database was checked less than CacheMaxAge ago, at CheckedAt.
+	Cached bool `json:"cached,omitempty"`
+	// Unavailable is true if the edition is no longer available to the
+	// account, e.g., because it was removed from it. NewHash is then the
+	// ZeroMD5 if its database was deleted or quarantined.
+	Unavailable bool `json:"unavailable,omitempty"`
+	// Outdated is true, for check-only runs, if the installed database
+	// isn't the latest build.
+	Outdated bool `json:"outdated,omitempty"`
+	// UpdateID identifies the update of the edition to the new database. It
+	// is the same for every run and host, and only set for editions that
+	// were updated.
+	UpdateID string `json:"update_id,omitempty"`
+	// ChecksumMismatches are the downloads of the run whose MD5 sum didn't
+	// match before the edition was updated. They are only recorded with
+	// ChecksumForensics.
+	ChecksumMismatches []ChecksumMismatch `json:"checksum_mismatches,omitempty"`
+	// PropagationLag is the time between the upstream publication of the
+	// database, ModifiedAt, and its installation. It is only set for
+	// editions that were updated.
+	PropagationLag time.Duration `json:"-"`
+	// ClockSkew is the difference between the clock of the server and the
+	// local clock when the edition was checked, if known.
+	ClockSkew time.Duration `json:"-"`
+	// LastModified is the Last-Modified time of the download, whereas
+	// ModifiedAt is the build time. It is only set for editions that were
+	// updated.
+	LastModified time.Time `json:"-"`
+	// Host is where the new database was downloaded from, e.g.,
+	// updates.maxmind.com, or peers. It is only set for editions that were
+	// updated.
+	Host string `json:"-"`
+	// PostUpdate is the result of the post-update command run for the new
+	// database, if any.
+	PostUpdate *CommandResult `json:"post_update,omitempty"`
+	// SelfCheck is the result of looking up the IP address of the host in
+	// the new database, if enabled.
+	SelfCheck *LookupResult `json:"self_check,omitempty"`
+}
+
+// CommandResult is the result of a command run for an edition.
+type CommandResult struct {
+	Succeeded bool `json:"succeeded"`
+	// Error is why the command failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ChecksumMismatch is the forensic record of a download whose MD5 sum didn't
+// match, e.g., to report corrupted downloads upstream.
+type ChecksumMismatch struct {
+	ExpectedMD5 string `json:"expected_md5"`
+	ActualMD5   string `json:"actual_md5"`
+	// Bytes is the size of the database received.
+	Bytes int64 `json:"bytes"`
+	// BadFile is where the database received was kept.
+	BadFile   string `json:"bad_file,omitempty"`
+	FromPeers bool   `json:"from_peers,omitempty"`
+	// Header is the header of the response the database was received with,
+	// without cookies.
+	Header map[string]string `json:"header,omitempty"`
 }
 
 // MarshalJSON is a custom json marshaler that strips out zero time fields.
@@ -27,12 +88,14 @@ func (r ReadResult) MarshalJSON() ([]byte, error) {
 	type partialResult ReadResult
 	s := &struct {
 		partialResult
-		ModifiedAt int64 `json:"modified_at,omitempty"`
-		CheckedAt  int64 `json:"checked_at,omitempty"`
+		ModifiedAt     int64 `json:"modified_at,omitempty"`
+		CheckedAt      int64 `json:"checked_at,omitempty"`
+		PropagationLag int64 `json:"propagation_lag_seconds,omitempty"`
 	}{
-		partialResult: partialResult(r),
-		ModifiedAt:    0,
-		CheckedAt:     0,
+		partialResult:  partialResult(r),
+		ModifiedAt:     0,
+		CheckedAt:      0,
+		PropagationLag: int64(r.PropagationLag.Seconds()),
 	}
 
 	if !r.ModifiedAt.IsZero() {
@@ -56,8 +119,9 @@ func (r *ReadResult) UnmarshalJSON(data []byte) error {
 	type partialResult ReadResult
 	s := &struct {
 		partialResult
-		ModifiedAt int64 `json:"modified_at,omitempty"`
-		CheckedAt  int64 `json:"checked_at,omitempty"`
+		ModifiedAt     int64 `json:"modified_at,omitempty"`
+		CheckedAt      int64 `json:"checked_at,omitempty"`
+		PropagationLag int64 `json:"propagation_lag_seconds,omitempty"`
 	}{}
 
 	err := json.Unmarshal(data, &s)
@@ -68,6 +132,7 @@ func (r *ReadResult) UnmarshalJSON(data []byte) error {
 	result := ReadResult(s.partialResult)
 	result.ModifiedAt = time.Unix(s.ModifiedAt, 0).In(time.UTC)
 	result.CheckedAt = time.Unix(s.CheckedAt, 0).In(time.UTC)
+	result.PropagationLag = time.Duration(s.PropagationLag) * time.Second
 	*r = result
 
 	return nil
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"io"
 	"time"
 )
@@ -10,7 +11,9 @@ import (
 const ZeroMD5 = "00000000000000000000000000000000"
 
 // Writer provides an interface for writing a database to a target location.
+// Write returns an error wrapping the error of ctx once it is done, without
+// waiting for the database to be written.
 type Writer interface {
-	Write(string, io.ReadCloser, string, time.Time) error
-	GetHash(editionID string) (string, error)
+	Write(context.Context, string, io.ReadCloser, string, time.Time) error
+	GetHash(ctx context.Context, editionID string) (string, error)
 }
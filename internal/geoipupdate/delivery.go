@@ -0,0 +1,73 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// CheckDelivery checks, without writing any database, that an update could
+// deliver the databases it downloads, e.g., along with Plan: that the
+// directories databases are written to are writable, and that the OCIPush
+// repository, if any, accepts pushes with the configured credentials.
+func (u *Updater) CheckDelivery(ctx context.Context) error {
+	dirs := append([]string{u.config.DatabaseDirectory}, u.config.DatabaseDirectories...)
+	if u.config.WriteStrategy == database.WriteStrategyCopy {
+		tempDir := u.config.TempDirectory
+		if tempDir == "" {
+			tempDir = os.TempDir()
+		}
+		dirs = append(dirs, tempDir)
+	}
+	if u.config.ArchiveDirectory != "" {
+		dirs = append(dirs, u.config.ArchiveDirectory)
+	}
+
+	var errs error
+	for _, dir := range dirs {
+		if err := probeDirectory(dir); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	if u.pusher != nil {
+		if err := u.pusher.CheckPush(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("checking pushes to %s: %w", u.config.OCIPush, err))
+		}
+	}
+	return errs
+}
+
+// probeDirectory checks that files can be written to dir by creating and
+// removing one. Directories that don't exist yet, which are created when
+// needed, are checked through their closest existing parent.
+func probeDirectory(dir string) error {
+	existing := dir
+	for {
+		_, err := os.Stat(existing)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if !errors.Is(err, os.ErrNotExist) || parent == existing {
+			return fmt.Errorf("checking that %s is writable: %w", dir, err)
+		}
+		existing = parent
+	}
+
+	f, err := os.CreateTemp(existing, ".geoipupdate-probe-*")
+	if err != nil {
+		return fmt.Errorf("checking that %s is writable: %w", dir, err)
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing probe file: %w", err)
+	}
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("removing probe file: %w", err)
+	}
+	return nil
+}
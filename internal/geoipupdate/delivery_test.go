@@ -0,0 +1,38 @@
+package geoipupdate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdaterCheckDelivery tests that the directories the databases are
+// written to are probed without leaving files behind.
+func TestUpdaterCheckDelivery(t *testing.T) {
+	tempDir := t.TempDir()
+	readOnly := filepath.Join(tempDir, "read-only")
+	require.NoError(t, os.Mkdir(readOnly, 0o500))
+
+	u := &Updater{config: &Config{
+		DatabaseDirectory: filepath.Join(tempDir, "databases"),
+		ArchiveDirectory:  filepath.Join(tempDir, "archive", "geoip"),
+		WriteStrategy:     "copy",
+		TempDirectory:     tempDir,
+	}}
+	require.NoError(t, u.CheckDelivery(context.Background()))
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("the permissions of directories aren't enforced")
+	}
+	u.config.ArchiveDirectory = readOnly
+	err = u.CheckDelivery(context.Background())
+	require.ErrorContains(t, err, "checking that "+readOnly+" is writable")
+}
@@ -0,0 +1,181 @@
+package geoipupdate
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/blob"
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// destinationWriter returns a WriterMiddleware uploading each database
+// written to bucket, the Destination, once it is installed locally.
+//
+// Databases are uploaded with enc, the DestinationEncoding, as objects
+// named after the edition, e.g., GeoIP2-City.mmdb.gz. As the bucket only
+// knows the MD5 sum of encoded objects, that of the MMDB file is uploaded
+// next to them, e.g., as GeoIP2-City.mmdb.gz.md5.
+//
+// A database whose installed build isn't in the Destination, e.g., after
+// setting one, is downloaded again to upload it.
+func destinationWriter(bucket blob.Bucket, tempDir string, enc encoding.Encoding) database.WriterMiddleware {
+	return func(next database.Writer) database.Writer {
+		return &destination{Writer: next, bucket: bucket, tempDir: tempDir, enc: enc}
+	}
+}
+
+type destination struct {
+	database.Writer
+	bucket  blob.Bucket
+	tempDir string
+	enc     encoding.Encoding
+}
+
+func (d *destination) Write(
+	ctx context.Context,
+	editionID string,
+	reader io.ReadCloser,
+	md5sum string,
+	lastModified time.Time,
+) (err error) {
+	// The database is kept in a temporary file as it is written, to upload
+	// it once it is installed.
+	f, err := os.CreateTemp(d.tempDir, "geoipupdate-destination-*.mmdb")
+	if err != nil {
+		// Close the reader, as the next writer would have.
+		return errors.Join(
+			fmt.Errorf("creating temporary file for %s: %w", editionID, err),
+			reader.Close(),
+		)
+	}
+	defer func() {
+		err = errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}()
+
+	tee := &teeReadCloser{ReadCloser: reader, file: f, hash: md5.New()}
+	if err := d.Writer.Write(ctx, editionID, tee, md5sum, lastModified); err != nil {
+		return err
+	}
+	if tee.err != nil {
+		return fmt.Errorf("writing temporary file for %s: %w", editionID, tee.err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("reading temporary file for %s: %w", editionID, err)
+	}
+	mmdbSum := tee.hash.Sum(nil)
+	if d.enc.IsNone() {
+		if err := d.bucket.Put(ctx, editionID+".mmdb", f, tee.n, mmdbSum); err != nil {
+			return fmt.Errorf("uploading %s to the destination: %w", editionID, err)
+		}
+		return nil
+	}
+
+	encoded, err := os.CreateTemp(d.tempDir, "geoipupdate-destination-*.mmdb"+d.enc.Extension)
+	if err != nil {
+		return fmt.Errorf("creating temporary file for %s: %w", editionID, err)
+	}
+	defer func() {
+		err = errors.Join(err, encoded.Close(), os.Remove(encoded.Name()))
+	}()
+	size, sum, err := d.encode(encoded, f)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", editionID, err)
+	}
+	name := d.objectName(editionID)
+	if err := d.bucket.Put(ctx, name, encoded, size, sum); err != nil {
+		return fmt.Errorf("uploading %s to the destination: %w", editionID, err)
+	}
+	// The MD5 sum of the MMDB file is uploaded last, for GetHash to only
+	// find the build once it is fully uploaded.
+	hexSum := hex.EncodeToString(mmdbSum)
+	hexSumMD5 := md5.Sum([]byte(hexSum))
+	err = d.bucket.Put(ctx, name+".md5", strings.NewReader(hexSum), int64(len(hexSum)), hexSumMD5[:])
+	if err != nil {
+		return fmt.Errorf("uploading the MD5 sum of %s to the destination: %w", editionID, err)
+	}
+	return nil
+}
+
+// encode writes what is read from src, encoded with the DestinationEncoding,
+// to dst, returning its size and MD5 sum, with dst positioned at its start.
+func (d *destination) encode(dst *os.File, src io.Reader) (int64, []byte, error) {
+	hash := md5.New()
+	w, err := d.enc.NewWriter(io.MultiWriter(dst, hash))
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return 0, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, nil, err
+	}
+	size, err := dst.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return 0, nil, err
+	}
+	return size, hash.Sum(nil), nil
+}
+
+// objectName returns the name of the object of editionID in the bucket.
+func (d *destination) objectName(editionID string) string {
+	return editionID + ".mmdb" + d.enc.Extension
+}
+
+func (d *destination) GetHash(ctx context.Context, editionID string) (string, error) {
+	hash, err := d.Writer.GetHash(ctx, editionID)
+	if err != nil || hash == database.ZeroMD5 {
+		return hash, err
+	}
+	name, want := d.objectName(editionID), hash
+	if !d.enc.IsNone() {
+		// The MD5 sum of the MMDB file is held by the object next to the
+		// encoded one.
+		name += ".md5"
+		sum := md5.Sum([]byte(hash))
+		want = hex.EncodeToString(sum[:])
+	}
+	sum, err := d.bucket.MD5(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("checking %s in the destination: %w", editionID, err)
+	}
+	if hex.EncodeToString(sum) != want {
+		return database.ZeroMD5, nil
+	}
+	return hash, nil
+}
+
+// teeReadCloser copies what is read from the wrapped reader to a file and
+// a hash. Errors writing the file are kept in err rather than failing the
+// reads, for the database to still be installed.
+type teeReadCloser struct {
+	io.ReadCloser
+	file *os.File
+	hash hash.Hash
+	n    int64
+	err  error
+}
+
+func (r *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && r.err == nil {
+		// A hash.Hash never returns an error.
+		_, _ = r.hash.Write(p[:n])
+		_, r.err = r.file.Write(p[:n])
+		r.n += int64(n)
+	}
+	return n, err
+}
@@ -0,0 +1,145 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+type mockBucket struct {
+	objects map[string][]byte
+	putErr  error
+}
+
+func (b *mockBucket) Put(_ context.Context, name string, body io.Reader, size int64, md5sum []byte) error {
+	if b.putErr != nil {
+		return b.putErr
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(content)
+	if int64(len(content)) != size || string(sum[:]) != string(md5sum) {
+		return errors.New("bad digest")
+	}
+	b.objects[name] = content
+	return nil
+}
+
+func (b *mockBucket) MD5(_ context.Context, name string) ([]byte, error) {
+	content, ok := b.objects[name]
+	if !ok {
+		return nil, nil
+	}
+	sum := md5.Sum(content)
+	return sum[:], nil
+}
+
+func TestDestinationWriter(t *testing.T) {
+	ctx := context.Background()
+	bucket := &mockBucket{objects: map[string][]byte{}}
+	var installed string
+	next := &mockWriter{
+		// The MD5 sum of "database" is 11e0eed8d3696c0a632f822df385ab3c.
+		md5s: map[string]string{
+			"GeoLite2-ASN":  database.ZeroMD5,
+			"GeoLite2-City": "11e0eed8d3696c0a632f822df385ab3c",
+		},
+		writeFunc: func(_ string, reader io.ReadCloser, _ string, _ time.Time) error {
+			b, err := io.ReadAll(reader)
+			installed = string(b)
+			return err
+		},
+	}
+	none, err := encoding.Lookup(encoding.None)
+	require.NoError(t, err)
+	w := database.ChainWriter(next, destinationWriter(bucket, t.TempDir(), none))
+
+	// The installed build isn't in the destination yet, so it is
+	// downloaded again.
+	hash, err := w.GetHash(ctx, "GeoLite2-City")
+	require.NoError(t, err)
+	assert.Equal(t, database.ZeroMD5, hash)
+
+	hash, err = w.GetHash(ctx, "GeoLite2-ASN")
+	require.NoError(t, err)
+	assert.Equal(t, database.ZeroMD5, hash)
+
+	reader := io.NopCloser(strings.NewReader("database"))
+	require.NoError(t, w.Write(ctx, "GeoLite2-City", reader, "11e0eed8d3696c0a632f822df385ab3c", time.Now()))
+	assert.Equal(t, "database", installed)
+	assert.Equal(t, "database", string(bucket.objects["GeoLite2-City.mmdb"]))
+
+	hash, err = w.GetHash(ctx, "GeoLite2-City")
+	require.NoError(t, err)
+	assert.Equal(t, "11e0eed8d3696c0a632f822df385ab3c", hash)
+
+	bucket.putErr = errors.New("unavailable")
+	reader = io.NopCloser(strings.NewReader("database"))
+	err = w.Write(ctx, "GeoLite2-City", reader, "11e0eed8d3696c0a632f822df385ab3c", time.Now())
+	assert.EqualError(t, err, "uploading GeoLite2-City to the destination: unavailable")
+
+	// Nothing is uploaded if the database isn't installed.
+	next.writeFunc = func(string, io.ReadCloser, string, time.Time) error {
+		return errors.New("checksum mismatch")
+	}
+	bucket.putErr = nil
+	reader = io.NopCloser(strings.NewReader("corrupt"))
+	err = w.Write(ctx, "GeoLite2-ASN", reader, "11e0eed8d3696c0a632f822df385ab3c", time.Now())
+	assert.EqualError(t, err, "checksum mismatch")
+	assert.NotContains(t, bucket.objects, "GeoLite2-ASN.mmdb")
+}
+
+// TestDestinationWriterEncoding tests that the databases are uploaded with
+// the DestinationEncoding, next to the MD5 sum of their MMDB file.
+func TestDestinationWriterEncoding(t *testing.T) {
+	ctx := context.Background()
+	bucket := &mockBucket{objects: map[string][]byte{}}
+	next := &mockWriter{
+		md5s: map[string]string{"GeoLite2-City": "11e0eed8d3696c0a632f822df385ab3c"},
+		writeFunc: func(_ string, reader io.ReadCloser, _ string, _ time.Time) error {
+			_, err := io.ReadAll(reader)
+			return err
+		},
+	}
+	zstd, err := encoding.Lookup(encoding.Zstd)
+	require.NoError(t, err)
+	w := database.ChainWriter(next, destinationWriter(bucket, t.TempDir(), zstd))
+
+	hash, err := w.GetHash(ctx, "GeoLite2-City")
+	require.NoError(t, err)
+	assert.Equal(t, database.ZeroMD5, hash)
+
+	reader := io.NopCloser(strings.NewReader("database"))
+	require.NoError(t, w.Write(ctx, "GeoLite2-City", reader, "11e0eed8d3696c0a632f822df385ab3c", time.Now()))
+	assert.NotContains(t, bucket.objects, "GeoLite2-City.mmdb")
+	assert.Equal(t, "11e0eed8d3696c0a632f822df385ab3c", string(bucket.objects["GeoLite2-City.mmdb.zst.md5"]))
+
+	decoder, err := zstd.NewReader(bytes.NewReader(bucket.objects["GeoLite2-City.mmdb.zst"]))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "database", string(decoded))
+
+	hash, err = w.GetHash(ctx, "GeoLite2-City")
+	require.NoError(t, err)
+	assert.Equal(t, "11e0eed8d3696c0a632f822df385ab3c", hash)
+
+	// Another build is downloaded again.
+	next.md5s["GeoLite2-City"] = "a0f1490a20d0211c997b44bc357e1972"
+	hash, err = w.GetHash(ctx, "GeoLite2-City")
+	require.NoError(t, err)
+	assert.Equal(t, database.ZeroMD5, hash)
+}
@@ -0,0 +1,159 @@
+package geoipupdate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// addEditionDependency records in config that the edition editionID is
+// updated after the editions of dependencies.
+func addEditionDependency(config *Config, editionID string, dependencies []string) error {
+	if editionID == "" || isEditionPattern(editionID) {
+		return fmt.Errorf("invalid edition ID '%s' in `EditionDependency'", editionID)
+	}
+	if len(dependencies) == 0 {
+		return fmt.Errorf("the dependencies of '%s' have no editions", editionID)
+	}
+	if _, ok := config.EditionDependencies[editionID]; ok {
+		return fmt.Errorf("the dependencies of '%s' are defined multiple times", editionID)
+	}
+	for _, dependency := range dependencies {
+		if isEditionPattern(dependency) {
+			return fmt.Errorf("invalid edition ID '%s' in `EditionDependency'", dependency)
+		}
+		if dependency == editionID || dependsOn(config.EditionDependencies, dependency, editionID) {
+			return fmt.Errorf("'%s' and '%s' depend on each other", editionID, dependency)
+		}
+	}
+	if config.EditionDependencies == nil {
+		config.EditionDependencies = map[string][]string{}
+	}
+	config.EditionDependencies[editionID] = dependencies
+	return nil
+}
+
+// dependsOn returns whether the edition editionID depends on the edition
+// dependency, directly or not.
+func dependsOn(dependencies map[string][]string, editionID, dependency string) bool {
+	seen := map[string]bool{}
+	queue := []string{editionID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, d := range dependencies[id] {
+			if d == dependency {
+				return true
+			}
+			if !seen[d] {
+				seen[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+	return false
+}
+
+// sortDependencies moves the editions of editionIDs that others depend on
+// before them, keeping the order of editionIDs otherwise. As the job
+// processor starts the editions in order, the editions an edition waits for
+// are always started before it, whatever the Parallelism.
+func sortDependencies(editionIDs []string, dependencies map[string][]string) []string {
+	if len(dependencies) == 0 {
+		return editionIDs
+	}
+	placed := map[string]bool{}
+	sorted := make([]string, 0, len(editionIDs))
+	var place func(editionID string)
+	place = func(editionID string) {
+		if placed[editionID] {
+			return
+		}
+		placed[editionID] = true
+		for _, dependency := range dependencies[editionID] {
+			if slices.Contains(editionIDs, dependency) {
+				place(dependency)
+			}
+		}
+		sorted = append(sorted, editionID)
+	}
+	for _, editionID := range editionIDs {
+		place(editionID)
+	}
+	return sorted
+}
+
+// editionBarrier holds back the editions of a run until the editions they
+// depend on, per EditionDependencies, are done, so that their new databases
+// are always installed after those of their dependencies. An edition whose
+// dependency failed to update isn't updated either, so that it is never
+// newer than it.
+type editionBarrier struct {
+	dependencies map[string][]string
+	done         map[string]chan struct{}
+	// logf, if set, logs the editions being held back.
+	logf func(format string, args ...any)
+
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+// newEditionBarrier returns the editionBarrier of the run updating
+// editionIDs. The dependencies that aren't part of the run are ignored.
+func newEditionBarrier(dependencies map[string][]string, editionIDs []string) *editionBarrier {
+	b := &editionBarrier{
+		dependencies: dependencies,
+		done:         map[string]chan struct{}{},
+		failed:       map[string]bool{},
+	}
+	for _, editionID := range editionIDs {
+		b.done[editionID] = make(chan struct{})
+	}
+	return b
+}
+
+// wait blocks until the dependencies of editionID are done. It returns an
+// error if one of them failed, or if ctx is done first.
+func (b *editionBarrier) wait(ctx context.Context, editionID string) error {
+	for _, dependency := range b.dependencies[editionID] {
+		done, ok := b.done[dependency]
+		if !ok {
+			continue
+		}
+		select {
+		case <-done:
+		default:
+			if b.logf != nil {
+				b.logf("Waiting for %s before updating %s", dependency, editionID)
+			}
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return fmt.Errorf("waiting for %s before updating %s: %w", dependency, editionID, ctx.Err())
+			}
+		}
+
+		b.mu.Lock()
+		failed := b.failed[dependency]
+		b.mu.Unlock()
+		if failed {
+			return fmt.Errorf("not updating %s as %s failed to update", editionID, dependency)
+		}
+	}
+	return nil
+}
+
+// finish marks editionID as done, failed if err isn't nil.
+func (b *editionBarrier) finish(editionID string, err error) {
+	done, ok := b.done[editionID]
+	if !ok {
+		return
+	}
+	if err != nil {
+		b.mu.Lock()
+		b.failed[editionID] = true
+		b.mu.Unlock()
+	}
+	close(done)
+}
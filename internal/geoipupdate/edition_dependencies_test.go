@@ -0,0 +1,97 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortDependencies(t *testing.T) {
+	dependencies := map[string][]string{
+		"GeoIP2-City":      {"GeoIP2-ISP", "GeoLite2-ASN"},
+		"GeoIP2-ISP":       {"GeoIP2-Domain"},
+		"GeoLite2-Country": {"GeoIP2-Enterprise"},
+	}
+
+	tests := []struct {
+		description string
+		editionIDs  []string
+		expected    []string
+	}{
+		{
+			description: "dependencies first",
+			editionIDs:  []string{"GeoIP2-City", "GeoLite2-ASN", "GeoIP2-ISP", "GeoIP2-Domain"},
+			expected:    []string{"GeoIP2-Domain", "GeoIP2-ISP", "GeoLite2-ASN", "GeoIP2-City"},
+		},
+		{
+			description: "order kept",
+			editionIDs:  []string{"GeoIP2-Domain", "GeoLite2-ASN", "GeoIP2-ISP", "GeoIP2-City"},
+			expected:    []string{"GeoIP2-Domain", "GeoLite2-ASN", "GeoIP2-ISP", "GeoIP2-City"},
+		},
+		{
+			description: "dependencies outside the run ignored",
+			editionIDs:  []string{"GeoLite2-Country", "GeoIP2-City", "GeoIP2-ISP"},
+			expected:    []string{"GeoLite2-Country", "GeoIP2-ISP", "GeoIP2-City"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expected, sortDependencies(test.editionIDs, dependencies))
+		})
+	}
+}
+
+func TestEditionBarrier(t *testing.T) {
+	dependencies := map[string][]string{
+		"GeoIP2-City":    {"GeoIP2-ISP"},
+		"GeoIP2-Country": {"GeoIP2-City"},
+		"GeoLite2-City":  {"GeoLite2-ASN", "GeoIP2-Domain"},
+	}
+	editionIDs := []string{"GeoIP2-ISP", "GeoIP2-City", "GeoIP2-Country", "GeoLite2-ASN", "GeoLite2-City"}
+	b := newEditionBarrier(dependencies, editionIDs)
+	ctx := context.Background()
+
+	// Editions without dependencies in the run aren't held back.
+	require.NoError(t, b.wait(ctx, "GeoIP2-ISP"))
+	require.NoError(t, b.wait(ctx, "GeoLite2-ASN"))
+
+	var mu sync.Mutex
+	var finished []string
+	var wg sync.WaitGroup
+	for _, editionID := range []string{"GeoIP2-Country", "GeoIP2-City"} {
+		editionID := editionID
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := b.wait(ctx, editionID)
+			assert.NoError(t, err)
+			mu.Lock()
+			finished = append(finished, editionID)
+			mu.Unlock()
+			b.finish(editionID, err)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Empty(t, finished, "editions updated before their dependencies")
+	mu.Unlock()
+	b.finish("GeoIP2-ISP", nil)
+	wg.Wait()
+	assert.Equal(t, []string{"GeoIP2-City", "GeoIP2-Country"}, finished)
+
+	// An edition isn't updated after a failure of one of its dependencies.
+	b.finish("GeoLite2-ASN", errors.New("download failed"))
+	require.EqualError(t, b.wait(ctx, "GeoLite2-City"), "not updating GeoLite2-City as GeoLite2-ASN failed to update")
+
+	// The wait ends with the context.
+	b = newEditionBarrier(dependencies, editionIDs)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, b.wait(ctx, "GeoIP2-City"), context.DeadlineExceeded)
+}
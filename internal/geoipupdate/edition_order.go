@@ -0,0 +1,79 @@
+package geoipupdate
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// The values of EditionOrder.
+const (
+	// EditionOrderConfig updates the editions in the order they are
+	// configured in. It is the default.
+	EditionOrderConfig = "config"
+	// EditionOrderLargestFirst starts with the editions whose installed
+	// databases are the largest, so that they don't end up downloaded
+	// alone at the end of parallel runs.
+	EditionOrderLargestFirst = "largest-first"
+	// EditionOrderSmallestFirst starts with the editions whose installed
+	// databases are the smallest, so that most editions are updated as
+	// early as possible.
+	EditionOrderSmallestFirst = "smallest-first"
+)
+
+// validateEditionOrder checks that value, the value of the setting name, is
+// a known order.
+func validateEditionOrder(name, value string) error {
+	switch value {
+	case EditionOrderConfig, EditionOrderLargestFirst, EditionOrderSmallestFirst:
+		return nil
+	default:
+		return fmt.Errorf(
+			"`%s' must be %s, %s or %s, got '%s'",
+			name,
+			EditionOrderConfig,
+			EditionOrderLargestFirst,
+			EditionOrderSmallestFirst,
+			value,
+		)
+	}
+}
+
+// sortEditions sorts editionIDs according to EditionOrder, using the sizes
+// of the installed databases as those of the next builds. Editions without
+// an installed database come last, in their configured order, as their
+// size isn't known.
+func (u *Updater) sortEditions(editionIDs []string) {
+	if u.config.EditionOrder == "" || u.config.EditionOrder == EditionOrderConfig {
+		return
+	}
+
+	sizes := make(map[string]int64, len(editionIDs))
+	for _, editionID := range editionIDs {
+		info, err := os.Stat(database.FilePath(u.databaseDir(), editionID))
+		if err != nil {
+			sizes[editionID] = -1
+			continue
+		}
+		sizes[editionID] = info.Size()
+	}
+
+	slices.SortStableFunc(editionIDs, func(a, b string) int {
+		sizeA, sizeB := sizes[a], sizes[b]
+		switch {
+		case sizeA < 0 && sizeB < 0:
+			return 0
+		case sizeA < 0:
+			return 1
+		case sizeB < 0:
+			return -1
+		case u.config.EditionOrder == EditionOrderSmallestFirst:
+			return cmp.Compare(sizeA, sizeB)
+		default:
+			return cmp.Compare(sizeB, sizeA)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+func TestOrderEditions(t *testing.T) {
+	tempDir := t.TempDir()
+	sizes := map[string]int{
+		"GeoLite2-ASN":     8,
+		"GeoLite2-City":    64,
+		"GeoLite2-Country": 16,
+		"GeoIP2-ISP":       32,
+	}
+	for editionID, size := range sizes {
+		path := filepath.Join(tempDir, editionID+".mmdb")
+		require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o600))
+	}
+	store := state.New(filepath.Join(tempDir, ".geoipupdate.state"))
+	require.NoError(t, store.Update("GeoLite2-Country", func(e *state.Edition) {
+		e.Pending = true
+	}))
+	editionIDs := []string{
+		"GeoLite2-ASN",
+		"GeoIP2-Domain",
+		"GeoLite2-Country",
+		"GeoLite2-City",
+		"GeoIP2-ISP",
+	}
+
+	tests := []struct {
+		order    string
+		expected []string
+	}{
+		{
+			order: "",
+			expected: []string{
+				"GeoLite2-Country", "GeoLite2-ASN", "GeoIP2-Domain", "GeoLite2-City", "GeoIP2-ISP",
+			},
+		},
+		{
+			order: EditionOrderLargestFirst,
+			expected: []string{
+				"GeoLite2-Country", "GeoLite2-City", "GeoIP2-ISP", "GeoLite2-ASN", "GeoIP2-Domain",
+			},
+		},
+		{
+			order: EditionOrderSmallestFirst,
+			expected: []string{
+				"GeoLite2-Country", "GeoLite2-ASN", "GeoIP2-ISP", "GeoLite2-City", "GeoIP2-Domain",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.order, func(t *testing.T) {
+			u := &Updater{config: &Config{
+				DatabaseDirectory: tempDir,
+				EditionOrder:      test.order,
+			}}
+			ordered := u.orderEditions(store, append([]string(nil), editionIDs...))
+			assert.Equal(t, test.expected, ordered)
+		})
+	}
+}
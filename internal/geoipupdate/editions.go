@@ -0,0 +1,269 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// KnownEditionIDs returns the edition IDs and groups configured in
+// configFile and the environment, along with the edition IDs recorded in
+// the state file, sorted. It is meant for suggestions and ignores any
+// error.
+func KnownEditionIDs(configFile string) []string {
+	config := &Config{
+		DatabaseDirectory: filepath.Clean(vars.DefaultDatabaseDirectory),
+	}
+	if configFile != "" {
+		_ = setConfigFromFile(config, configFile) //nolint:errcheck // best effort
+	}
+	_ = setConfigFromEnv(config) //nolint:errcheck // best effort
+
+	var editionIDs []string
+	for _, id := range config.EditionIDs {
+		if !isEditionPattern(id) {
+			editionIDs = append(editionIDs, id)
+		}
+	}
+	// Groups can be given instead of their editions.
+	for name := range config.EditionGroups {
+		editionIDs = append(editionIDs, name)
+	}
+
+	stateFile := config.StateFile
+	if stateFile == "" {
+		stateFile = filepath.Join(config.DatabaseDirectory, ".geoipupdate.state")
+	}
+	if store, err := state.Open(stateFile); err == nil {
+		editionIDs = append(editionIDs, store.EditionIDs()...)
+	}
+
+	slices.Sort(editionIDs)
+	return slices.Compact(editionIDs)
+}
+
+// isEditionPattern returns whether id is a pattern, e.g., GeoIP2-*, rather
+// than an edition ID.
+func isEditionPattern(id string) bool {
+	return strings.ContainsAny(id, `*?[\`)
+}
+
+// addEditionGroup adds the edition group name, holding members, to config.
+func addEditionGroup(config *Config, name string, members []string) error {
+	if name == "" || isEditionPattern(name) {
+		return fmt.Errorf("invalid edition group name '%s'", name)
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("the edition group '%s' has no editions", name)
+	}
+	if _, ok := config.EditionGroups[name]; ok {
+		return fmt.Errorf("the edition group '%s' is defined multiple times", name)
+	}
+	if config.EditionGroups == nil {
+		config.EditionGroups = map[string][]string{}
+	}
+	config.EditionGroups[name] = members
+	return nil
+}
+
+// expandEditionIDs replaces the groups of the EditionIDs of config by their
+// editions, and moves the patterns to EditionPatterns.
+func expandEditionIDs(config *Config) error {
+	var editionIDs, patterns []string
+	for _, id := range config.EditionIDs {
+		members, ok := config.EditionGroups[id]
+		if !ok {
+			members = []string{id}
+		}
+		for _, member := range members {
+			if !isEditionPattern(member) {
+				if !slices.Contains(editionIDs, member) {
+					editionIDs = append(editionIDs, member)
+				}
+				continue
+			}
+			if _, err := path.Match(member, ""); err != nil {
+				return fmt.Errorf("invalid edition pattern '%s'", member)
+			}
+			if !slices.Contains(patterns, member) {
+				patterns = append(patterns, member)
+			}
+		}
+	}
+	config.EditionIDs = editionIDs
+	config.EditionPatterns = append(config.EditionPatterns, patterns...)
+	return nil
+}
+
+// parseExcludeEditionIDs parses value, the value of the setting name, as a
+// list of edition IDs and patterns.
+func parseExcludeEditionIDs(name, value string) ([]string, error) {
+	excluded := strings.Fields(value)
+	for _, id := range excluded {
+		if _, err := path.Match(id, ""); err != nil {
+			return nil, fmt.Errorf("`%s' contains the invalid pattern '%s'", name, id)
+		}
+	}
+	return excluded, nil
+}
+
+// wantsEdition returns whether editionID is one of the EditionIDs of config
+// or matches one of its EditionPatterns, and isn't excluded.
+func (c *Config) wantsEdition(editionID string) bool {
+	if c.isExcluded(editionID) {
+		return false
+	}
+	if slices.Contains(c.EditionIDs, editionID) {
+		return true
+	}
+	for _, pattern := range c.EditionPatterns {
+		if ok, _ := path.Match(pattern, editionID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded returns whether editionID is one of the ExcludeEditionIDs of
+// config or matches one of them.
+func (c *Config) isExcluded(editionID string) bool {
+	for _, excluded := range c.ExcludeEditionIDs {
+		if ok, _ := path.Match(excluded, editionID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// editionLister is implemented by the update clients of mirrors, which can
+// list the editions they hold.
+type editionLister interface {
+	EditionIDs(ctx context.Context) ([]string, error)
+}
+
+// EditionsFunc returns the editions a run updates, e.g., depending on
+// feature flags. It is called at the start of each run, and may return
+// edition groups and patterns as well as edition IDs.
+type EditionsFunc func(ctx context.Context) ([]string, error)
+
+// WithEditionsFunc returns an Option that has the editions of each run given
+// by f rather than by EditionIDs.
+func WithEditionsFunc(f EditionsFunc) Option {
+	return func(c *Config) error {
+		c.editionsFunc = f
+		return nil
+	}
+}
+
+// resolveEditionIDs returns the editions of a run: the EditionIDs, or those
+// given by the EditionsFunc, followed by the editions matching the
+// EditionPatterns. The patterns are matched against the editions listed by
+// the mirror or, as the MaxMind servers don't list editions, against those
+// of store. Patterns matching no edition raise a WarningUnmatchedPattern.
+// The ExcludeEditionIDs are left out.
+func (u *Updater) resolveEditionIDs(ctx context.Context, store *state.Store) ([]string, error) {
+	editionIDs := slices.Clone(u.config.EditionIDs)
+	patterns := u.config.EditionPatterns
+	if u.config.editionsFunc != nil {
+		var err error
+		editionIDs, patterns, err = u.editionsFromFunc(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(patterns) > 0 {
+		var err error
+		editionIDs, err = u.matchEditionPatterns(ctx, store, editionIDs, patterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(u.config.ExcludeEditionIDs) == 0 {
+		return editionIDs, nil
+	}
+	var included []string
+	for _, editionID := range editionIDs {
+		if !u.config.isExcluded(editionID) {
+			included = append(included, editionID)
+			continue
+		}
+		if u.config.Verbose {
+			u.logf("Skipping the excluded edition %s", editionID)
+		}
+	}
+	if len(included) == 0 {
+		return nil, errors.New("all the editions are excluded by `ExcludeEditionIDs`")
+	}
+	return included, nil
+}
+
+// editionsFromFunc returns the edition IDs and patterns of the editions
+// given by the EditionsFunc of the configuration, with its groups expanded.
+func (u *Updater) editionsFromFunc(ctx context.Context) ([]string, []string, error) {
+	editionIDs, err := u.config.editionsFunc(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting the editions to update: %w", err)
+	}
+	if len(editionIDs) == 0 {
+		return nil, nil, errors.New("no editions to update")
+	}
+	editions := &Config{
+		EditionIDs:    editionIDs,
+		EditionGroups: u.config.EditionGroups,
+	}
+	if err := expandEditionIDs(editions); err != nil {
+		return nil, nil, err
+	}
+	return editions.EditionIDs, editions.EditionPatterns, nil
+}
+
+// matchEditionPatterns appends the editions matching patterns to editionIDs.
+func (u *Updater) matchEditionPatterns(
+	ctx context.Context,
+	store *state.Store,
+	editionIDs []string,
+	patterns []string,
+) ([]string, error) {
+
+	var available []string
+	if lister, ok := u.updateClient.(editionLister); ok {
+		var err error
+		available, err = lister.EditionIDs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing editions: %w", err)
+		}
+	} else {
+		available = store.EditionIDs()
+	}
+
+	for _, pattern := range patterns {
+		matched := false
+		for _, editionID := range available {
+			if ok, _ := path.Match(pattern, editionID); !ok {
+				continue
+			}
+			matched = true
+			if !slices.Contains(editionIDs, editionID) {
+				editionIDs = append(editionIDs, editionID)
+			}
+		}
+		if !matched {
+			u.warn(Warning{
+				Code:    WarningUnmatchedPattern,
+				Message: fmt.Sprintf("no available edition matches the pattern '%s'", pattern),
+			})
+		}
+	}
+	if len(editionIDs) == 0 {
+		return nil, errors.New("no available edition matches the edition patterns")
+	}
+	return editionIDs, nil
+}
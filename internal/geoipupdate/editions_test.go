@@ -0,0 +1,154 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+func TestKnownEditionIDs(t *testing.T) {
+	tempDir := t.TempDir()
+	stateFile := filepath.Join(tempDir, ".geoipupdate.state")
+
+	configFile := filepath.Join(tempDir, "GeoIP.conf")
+	require.NoError(t, os.WriteFile(
+		configFile,
+		[]byte("EditionGroup geolite GeoLite2-ASN\nEditionIDs GeoLite2-Country GeoLite2-City GeoIP2-*\n"+
+			"StateFile "+stateFile+"\n"),
+		0o600,
+	))
+
+	store := state.New(stateFile)
+	require.NoError(t, store.Update("GeoLite2-ASN", func(*state.Edition) {}))
+	require.NoError(t, store.Update("GeoLite2-City", func(*state.Edition) {}))
+
+	require.Equal(
+		t,
+		[]string{"GeoLite2-ASN", "GeoLite2-City", "GeoLite2-Country", "geolite"},
+		KnownEditionIDs(configFile),
+	)
+}
+
+// listingUpdateClient is an update client of a mirror listing its
+// editions.
+type listingUpdateClient struct {
+	mockUpdateClient
+	editionIDs []string
+}
+
+func (c *listingUpdateClient) EditionIDs(context.Context) ([]string, error) {
+	return c.editionIDs, nil
+}
+
+func TestResolveEditionIDs(t *testing.T) {
+	store := state.New(filepath.Join(t.TempDir(), ".geoipupdate.state"))
+	require.NoError(t, store.Update("GeoIP2-City", func(*state.Edition) {}))
+	require.NoError(t, store.Update("GeoLite2-ASN", func(*state.Edition) {}))
+
+	config := &Config{
+		EditionIDs:      []string{"GeoLite2-City"},
+		EditionPatterns: []string{"GeoIP2-*", "GeoLite2-*", "GeoIP2-*-Test"},
+	}
+	ctx := context.Background()
+
+	// The MaxMind servers don't list editions, so the patterns are matched
+	// against the editions of the state file.
+	u := &Updater{config: config, updateClient: &mockUpdateClient{}, warnings: &warningList{}}
+	editionIDs, err := u.resolveEditionIDs(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoLite2-City", "GeoIP2-City", "GeoLite2-ASN"}, editionIDs)
+	require.Len(t, u.warnings.warnings, 1)
+	assert.Equal(t, WarningUnmatchedPattern, u.warnings.warnings[0].Code)
+
+	u = &Updater{
+		config: config,
+		updateClient: &listingUpdateClient{
+			editionIDs: []string{"GeoIP2-City", "GeoIP2-ISP-Test", "GeoLite2-City"},
+		},
+		warnings: &warningList{},
+	}
+	editionIDs, err = u.resolveEditionIDs(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoLite2-City", "GeoIP2-City", "GeoIP2-ISP-Test"}, editionIDs)
+	assert.Empty(t, u.warnings.warnings)
+
+	u = &Updater{
+		config:       &Config{EditionPatterns: []string{"GeoIP2-*"}},
+		updateClient: &listingUpdateClient{},
+		warnings:     &warningList{},
+	}
+	_, err = u.resolveEditionIDs(ctx, store)
+	require.EqualError(t, err, "no available edition matches the edition patterns")
+
+	// Excluded editions are left out, whether they match a pattern or not.
+	u = &Updater{
+		config: &Config{
+			EditionIDs:        []string{"GeoLite2-City", "GeoLite2-Country"},
+			EditionPatterns:   []string{"GeoIP2-*"},
+			ExcludeEditionIDs: []string{"GeoLite2-Country", "GeoIP2-*-Test"},
+		},
+		updateClient: &listingUpdateClient{
+			editionIDs: []string{"GeoIP2-City", "GeoIP2-ISP-Test"},
+		},
+		warnings: &warningList{},
+	}
+	editionIDs, err = u.resolveEditionIDs(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoLite2-City", "GeoIP2-City"}, editionIDs)
+
+	u.config.ExcludeEditionIDs = []string{"*"}
+	_, err = u.resolveEditionIDs(ctx, store)
+	require.EqualError(t, err, "all the editions are excluded by `ExcludeEditionIDs`")
+}
+
+func TestResolveEditionIDsFunc(t *testing.T) {
+	store := state.New(filepath.Join(t.TempDir(), ".geoipupdate.state"))
+	ctx := context.Background()
+
+	var editions []string
+	var editionsErr error
+	u := &Updater{
+		config: &Config{
+			// The editions given by the function replace these.
+			EditionIDs: []string{"GeoLite2-ASN"},
+			EditionGroups: map[string][]string{
+				"city": {"GeoIP2-City", "GeoLite2-City"},
+			},
+			ExcludeEditionIDs: []string{"GeoIP2-ISP-Test"},
+			editionsFunc: func(context.Context) ([]string, error) {
+				return editions, editionsErr
+			},
+		},
+		updateClient: &listingUpdateClient{
+			editionIDs: []string{"GeoIP2-ISP", "GeoIP2-ISP-Test"},
+		},
+		warnings: &warningList{},
+	}
+
+	// Groups and patterns are expanded, and exclusions applied.
+	editions = []string{"city", "GeoIP2-ISP*"}
+	editionIDs, err := u.resolveEditionIDs(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoIP2-City", "GeoLite2-City", "GeoIP2-ISP"}, editionIDs)
+
+	// The function is called for each run.
+	editions = []string{"GeoLite2-Country"}
+	editionIDs, err = u.resolveEditionIDs(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoLite2-Country"}, editionIDs)
+
+	editions = nil
+	_, err = u.resolveEditionIDs(ctx, store)
+	require.EqualError(t, err, "no editions to update")
+
+	editionsErr = errors.New("feature flags unavailable")
+	_, err = u.resolveEditionIDs(ctx, store)
+	require.EqualError(t, err, "getting the editions to update: feature flags unavailable")
+}
@@ -0,0 +1,94 @@
+package geoipupdate
+
+import (
+	"time"
+)
+
+// repeatedErrorLogInterval is how often an error repeated by consecutive
+// runs of a daemon profile is logged again.
+const repeatedErrorLogInterval = time.Hour
+
+// errorLog logs the errors of the runs of a daemon profile, collapsing the
+// identical errors of consecutive runs into a line every interval, with
+// their count, so that an outage, e.g., DNS being down all weekend, doesn't
+// flood the logs.
+type errorLog struct {
+	interval time.Duration
+	logf     func(format string, args ...any)
+
+	// last is the error of the last run, if it failed.
+	last string
+	// since is when last first occurred, and count how many times it
+	// occurred since.
+	since time.Time
+	count int
+	// loggedAt is when last was last logged, and suppressed how many times
+	// it occurred since.
+	loggedAt   time.Time
+	suppressed int
+}
+
+// newErrorLog returns an errorLog logging with logf.
+func newErrorLog(logf func(format string, args ...any)) *errorLog {
+	return &errorLog{interval: repeatedErrorLogInterval, logf: logf}
+}
+
+// failed records that a run failed with err at now, logging it unless it
+// is the error of the previous run and was logged less than interval ago.
+func (l *errorLog) failed(err error, now time.Time) {
+	msg := err.Error()
+	if msg == l.last {
+		l.count++
+		if now.Sub(l.loggedAt) < l.interval {
+			l.suppressed++
+			return
+		}
+		l.logf(
+			"retrieving updates: %s (%d times since %s)",
+			msg,
+			l.count,
+			l.since.In(time.UTC).Format(time.RFC3339),
+		)
+		l.loggedAt = now
+		l.suppressed = 0
+		return
+	}
+
+	l.flush()
+	l.logf("retrieving updates: %s", msg)
+	l.last = msg
+	l.since = now
+	l.count = 1
+	l.loggedAt = now
+	l.suppressed = 0
+}
+
+// succeeded records that a run succeeded, logging the recovery if the
+// previous runs failed.
+func (l *errorLog) succeeded() {
+	if l.last == "" {
+		return
+	}
+	l.flush()
+	l.logf(
+		"retrieving updates succeeded after %d failed runs since %s",
+		l.count,
+		l.since.In(time.UTC).Format(time.RFC3339),
+	)
+	l.last = ""
+}
+
+// flush logs how many times the last error occurred since it was last
+// logged, if any.
+func (l *errorLog) flush() {
+	if l.suppressed == 0 {
+		return
+	}
+	l.logf(
+		"retrieving updates: %s (repeated %d times since %s)",
+		l.last,
+		l.suppressed,
+		l.loggedAt.In(time.UTC).Format(time.RFC3339),
+	)
+	l.suppressed = 0
+}
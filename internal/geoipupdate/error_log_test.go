@@ -0,0 +1,54 @@
+package geoipupdate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorLog(t *testing.T) {
+	var lines []string
+	l := newErrorLog(func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	})
+
+	start := time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)
+	dnsErr := errors.New("lookup updates.maxmind.com: no such host")
+
+	// Failures every 10 minutes are logged once an hour.
+	for i := 0; i <= 7; i++ {
+		l.failed(dnsErr, start.Add(time.Duration(i)*10*time.Minute))
+	}
+	assert.Equal(t, []string{
+		"retrieving updates: lookup updates.maxmind.com: no such host",
+		"retrieving updates: lookup updates.maxmind.com: no such host (7 times since 2024-01-06T00:00:00Z)",
+	}, lines)
+
+	// A different error is logged immediately, after the count of the
+	// previous one.
+	lines = nil
+	l.failed(dnsErr, start.Add(80*time.Minute))
+	l.failed(errors.New("unexpected HTTP status code 500"), start.Add(90*time.Minute))
+	l.failed(errors.New("unexpected HTTP status code 500"), start.Add(100*time.Minute))
+	assert.Equal(t, []string{
+		"retrieving updates: lookup updates.maxmind.com: no such host (repeated 2 times since 2024-01-06T01:00:00Z)",
+		"retrieving updates: unexpected HTTP status code 500",
+	}, lines)
+
+	// Recovering flushes the count and is logged.
+	lines = nil
+	l.succeeded()
+	l.succeeded()
+	assert.Equal(t, []string{
+		"retrieving updates: unexpected HTTP status code 500 (repeated 1 times since 2024-01-06T01:30:00Z)",
+		"retrieving updates succeeded after 2 failed runs since 2024-01-06T01:30:00Z",
+	}, lines)
+
+	// The next failure is logged.
+	lines = nil
+	l.failed(errors.New("unexpected HTTP status code 500"), start.Add(2*time.Hour))
+	assert.Len(t, lines, 1)
+}
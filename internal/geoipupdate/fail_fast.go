@@ -0,0 +1,85 @@
+package geoipupdate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// failFast skips the remaining editions of a run once the first ones to
+// finish all failed with the same kind of error, e.g., because the license
+// key is wrong or the proxy is down, as the others would most likely fail
+// the same way after retrying for RetryFor.
+type failFast struct {
+	threshold int
+	// cancel cancels the editions that are in flight or not started yet.
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	reason   string
+	failures int
+	firstErr error
+	// disarmed is whether an edition succeeded or failed differently.
+	disarmed bool
+	tripped  bool
+}
+
+func newFailFast(threshold int, cancel context.CancelFunc) *failFast {
+	return &failFast{threshold: threshold, cancel: cancel}
+}
+
+// succeeded records that an edition was updated successfully.
+func (f *failFast) succeeded() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.disarmed = true
+}
+
+// failed records that an edition failed with err after attempts.
+func (f *failFast) failed(attempts []state.Attempt, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.threshold == 0 || f.disarmed || f.tripped {
+		return
+	}
+
+	reason := attemptReason(nil, err)
+	if len(attempts) > 0 {
+		reason = attempts[len(attempts)-1].Reason
+	}
+	if f.failures > 0 && reason != f.reason {
+		f.disarmed = true
+		return
+	}
+
+	f.failures++
+	if f.failures == 1 {
+		f.reason = reason
+		f.firstErr = err
+	}
+	if f.failures >= f.threshold {
+		f.tripped = true
+		f.cancel()
+	}
+}
+
+// err returns the error the run fails with if the remaining editions were
+// skipped, and nil otherwise.
+func (f *failFast) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.tripped {
+		return nil
+	}
+	return fmt.Errorf(
+		"the first %d editions failed with the same error (%s), skipping the others: %w",
+		f.failures,
+		f.reason,
+		f.firstErr,
+	)
+}
@@ -0,0 +1,81 @@
+package geoipupdate
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// metadataMaxSize is the size of the end of a MaxMind DB file its readers
+// search for the metadata section, without which it can't be opened.
+const metadataMaxSize = 128 * 1024
+
+// Fetch downloads the latest build of editionID and writes its database to
+// w, leaving the installed databases, the lock and the state file
+// untouched. The database is written as it is downloaded, without a
+// temporary file, except for its end, which holds its metadata and is only
+// written once the database is checked against its MD5 sum: a database
+// failing the check is cut short and can't be opened.
+func (u *Updater) Fetch(ctx context.Context, editionID string, w io.Writer) error {
+	// Without a hash, the database is downloaded even if it is installed.
+	res, err := u.downloader.Download(ctx, editionID, "")
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", editionID, err)
+	}
+	defer res.Reader.Close()
+	if !res.UpdateAvailable {
+		return fmt.Errorf("downloading %s: no database received", editionID)
+	}
+
+	reader := &sizeLimitReader{ReadCloser: res.Reader, limit: u.maxDecompressedSize(res)}
+	hash := md5.New()
+	held := &holdBackWriter{w: w, n: metadataMaxSize}
+	n, err := io.Copy(io.MultiWriter(held, hash), reader)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", editionID, err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, res.MD5) {
+		return fmt.Errorf(
+			"fetching %s: %w",
+			editionID,
+			&database.ChecksumMismatchError{Expected: res.MD5, Actual: actual, Bytes: n},
+		)
+	}
+	if err := held.flush(); err != nil {
+		return fmt.Errorf("fetching %s: %w", editionID, err)
+	}
+	if u.config.Verbose {
+		u.logf("Fetched %s, %d bytes with the MD5 sum %s", editionID, n, res.MD5)
+	}
+	return nil
+}
+
+// holdBackWriter writes to w all but the last n bytes written to it, which
+// are only written by flush.
+type holdBackWriter struct {
+	w   io.Writer
+	n   int
+	buf []byte
+}
+
+func (h *holdBackWriter) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	if excess := len(h.buf) - h.n; excess > 0 {
+		if _, err := h.w.Write(h.buf[:excess]); err != nil {
+			return 0, err
+		}
+		h.buf = h.buf[:copy(h.buf, h.buf[excess:])]
+	}
+	return len(p), nil
+}
+
+func (h *holdBackWriter) flush() error {
+	_, err := h.w.Write(h.buf)
+	h.buf = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+)
+
+func TestFetch(t *testing.T) {
+	large := strings.Repeat("database", metadataMaxSize/4)
+	largeSum := md5.Sum([]byte(large))
+
+	tests := []struct {
+		description string
+		response    client.DownloadResponse
+		config      Config
+		// written is what w receives, the database if it is verified.
+		written string
+		err     string
+	}{
+		{
+			description: "verified database",
+			response: client.DownloadResponse{
+				// The MD5 sum of "database".
+				MD5:             "11e0eed8d3696c0a632f822df385ab3c",
+				Reader:          io.NopCloser(strings.NewReader("database")),
+				UpdateAvailable: true,
+			},
+			written: "database",
+		},
+		{
+			description: "verified large database",
+			response: client.DownloadResponse{
+				MD5:             hex.EncodeToString(largeSum[:]),
+				Reader:          io.NopCloser(strings.NewReader(large)),
+				UpdateAvailable: true,
+			},
+			written: large,
+		},
+		{
+			description: "checksum mismatch",
+			response: client.DownloadResponse{
+				MD5:             "11e0eed8d3696c0a632f822df385ab3c",
+				Reader:          io.NopCloser(strings.NewReader("corrupt")),
+				UpdateAvailable: true,
+			},
+			err: "fetching GeoLite2-City: md5 of new database " +
+				"(bc2f39d437ff13dff05f5cfda14327cc) does not match expected md5 " +
+				"(11e0eed8d3696c0a632f822df385ab3c)",
+		},
+		{
+			description: "checksum mismatch of a large database",
+			response: client.DownloadResponse{
+				MD5:             "11e0eed8d3696c0a632f822df385ab3c",
+				Reader:          io.NopCloser(strings.NewReader(large)),
+				UpdateAvailable: true,
+			},
+			// The end of the database, with its metadata, is held back.
+			written: large[:len(large)-metadataMaxSize],
+			err: "fetching GeoLite2-City: md5 of new database (" +
+				hex.EncodeToString(largeSum[:]) + ") does not match expected md5 " +
+				"(11e0eed8d3696c0a632f822df385ab3c)",
+		},
+		{
+			description: "too large",
+			response: client.DownloadResponse{
+				MD5:             "11e0eed8d3696c0a632f822df385ab3c",
+				Reader:          io.NopCloser(strings.NewReader("database")),
+				UpdateAvailable: true,
+			},
+			config: Config{MaxDecompressedSize: 4},
+			err: "fetching GeoLite2-City: the database is larger than the " +
+				"maximum decompressed size of 4 bytes",
+		},
+		{
+			description: "no database",
+			response: client.DownloadResponse{
+				Reader: io.NopCloser(strings.NewReader("")),
+			},
+			err: "downloading GeoLite2-City: no database received",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			config := test.config
+			u := &Updater{
+				config:     &config,
+				downloader: &mockUpdateClient{outputs: []client.DownloadResponse{test.response}},
+			}
+
+			var out bytes.Buffer
+			err := u.Fetch(context.Background(), "GeoLite2-City", &out)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, len(test.written), out.Len())
+			assert.Equal(t, test.written, out.String())
+		})
+	}
+}
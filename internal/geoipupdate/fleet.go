@@ -0,0 +1,280 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// maxReportSize is the size beyond which uploaded reports are rejected.
+const maxReportSize = 1 << 20
+
+// maxFleetHosts is the number of hosts a seed instance keeps the report of.
+// Once reached, the host that reported last the longest ago is forgotten
+// to make room for a new one.
+const maxFleetHosts = 10000
+
+// FleetStatus is the state of the hosts that uploaded their reports to a
+// seed instance, as served at /fleet-status.
+type FleetStatus struct {
+	// Editions are the builds installed on the hosts, by edition, sorted
+	// by edition ID.
+	Editions []FleetEdition `json:"editions"`
+	// Hosts are the hosts, sorted by name.
+	Hosts []FleetHost `json:"hosts"`
+}
+
+// FleetEdition lists the builds of an edition installed on the hosts.
+type FleetEdition struct {
+	EditionID string `json:"edition_id"`
+	// Builds are sorted from the most recent one.
+	Builds []FleetBuild `json:"builds"`
+}
+
+// FleetBuild is a build of an edition and the hosts it is installed on.
+type FleetBuild struct {
+	MD5 string `json:"md5"`
+	// BuildDate is zero if no host reported it, e.g., because the build
+	// was installed before its first upload.
+	BuildDate time.Time `json:"build_date"`
+	Hosts     []string  `json:"hosts"`
+}
+
+// FleetHost describes the last report uploaded by a host.
+type FleetHost struct {
+	Hostname   string            `json:"hostname"`
+	Version    string            `json:"version"`
+	RunID      string            `json:"run_id"`
+	ConfigHash string            `json:"config_hash"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	ReportedAt time.Time         `json:"reported_at"`
+	Warnings   int               `json:"warnings"`
+}
+
+// fleet collects the last report uploaded by each host. It is only kept in
+// memory, as hosts upload a new report with each run.
+type fleet struct {
+	// token is the bearer token uploads must be authenticated with, the
+	// ReportToken. Uploads are refused if it is empty.
+	token string
+	mu    sync.Mutex
+	hosts map[string]*fleetHost
+}
+
+// fleetHost is the last report of a host.
+type fleetHost struct {
+	FleetHost
+	// builds are the installed builds by edition ID.
+	builds map[string]fleetBuild
+}
+
+// fleetBuild is a build installed on a host.
+type fleetBuild struct {
+	md5       string
+	buildDate time.Time
+}
+
+func newFleet(token string) *fleet {
+	return &fleet{token: token, hosts: map[string]*fleetHost{}}
+}
+
+// receive records the report uploaded with r.
+func (f *fleet) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if f.token == "" {
+		http.Error(w, "uploading reports requires `ReportToken' to be set", http.StatusForbidden)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(f.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid report token", http.StatusUnauthorized)
+		return
+	}
+
+	var rep report
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportSize)).Decode(&rep); err != nil {
+		http.Error(w, "invalid report", http.StatusBadRequest)
+		return
+	}
+	if rep.Hostname == "" {
+		http.Error(w, "the report has no hostname", http.StatusBadRequest)
+		return
+	}
+	f.add(rep, time.Now().In(time.UTC))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// add records rep, received at now, as the last report of its host.
+func (f *fleet) add(rep report, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous := f.hosts[rep.Hostname]
+	if previous == nil && len(f.hosts) >= maxFleetHosts {
+		f.evictOldest()
+	}
+	h := &fleetHost{
+		FleetHost: FleetHost{
+			Hostname:   rep.Hostname,
+			Version:    rep.Version,
+			RunID:      rep.RunID,
+			ConfigHash: rep.ConfigHash,
+			Labels:     rep.Labels,
+			ReportedAt: now,
+			Warnings:   len(rep.Warnings),
+		},
+		builds: map[string]fleetBuild{},
+	}
+	for _, edition := range rep.Editions {
+		b := fleetBuild{md5: edition.NewHash}
+		// Unknown build dates are omitted, and so decoded as the epoch.
+		if edition.ModifiedAt.Unix() > 0 {
+			b.buildDate = edition.ModifiedAt
+		}
+		// The build date is only reported by the run installing the build.
+		if previous != nil && b.buildDate.IsZero() {
+			if p, ok := previous.builds[edition.EditionID]; ok && p.md5 == b.md5 {
+				b.buildDate = p.buildDate
+			}
+		}
+		h.builds[edition.EditionID] = b
+	}
+	f.hosts[rep.Hostname] = h
+}
+
+// evictOldest forgets the host that reported last the longest ago. f.mu
+// must be held.
+func (f *fleet) evictOldest() {
+	var oldest *fleetHost
+	for _, h := range f.hosts {
+		if oldest == nil || h.ReportedAt.Before(oldest.ReportedAt) {
+			oldest = h
+		}
+	}
+	if oldest != nil {
+		delete(f.hosts, oldest.Hostname)
+	}
+}
+
+// status returns the state of the hosts.
+func (f *fleet) status() FleetStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := FleetStatus{Editions: []FleetEdition{}, Hosts: []FleetHost{}}
+	hostnames := make([]string, 0, len(f.hosts))
+	for hostname := range f.hosts {
+		hostnames = append(hostnames, hostname)
+	}
+	slices.Sort(hostnames)
+
+	builds := map[string]map[string]*FleetBuild{}
+	for _, hostname := range hostnames {
+		h := f.hosts[hostname]
+		s.Hosts = append(s.Hosts, h.FleetHost)
+		for editionID, b := range h.builds {
+			if builds[editionID] == nil {
+				builds[editionID] = map[string]*FleetBuild{}
+			}
+			fb := builds[editionID][b.md5]
+			if fb == nil {
+				fb = &FleetBuild{MD5: b.md5}
+				builds[editionID][b.md5] = fb
+			}
+			if b.buildDate.After(fb.BuildDate) {
+				fb.BuildDate = b.buildDate
+			}
+			fb.Hosts = append(fb.Hosts, hostname)
+		}
+	}
+
+	for editionID, byMD5 := range builds {
+		e := FleetEdition{EditionID: editionID}
+		for _, b := range byMD5 {
+			e.Builds = append(e.Builds, *b)
+		}
+		slices.SortFunc(e.Builds, func(a, b FleetBuild) int {
+			if c := b.BuildDate.Compare(a.BuildDate); c != 0 {
+				return c
+			}
+			return len(b.Hosts) - len(a.Hosts)
+		})
+		s.Editions = append(s.Editions, e)
+	}
+	slices.SortFunc(s.Editions, func(a, b FleetEdition) int {
+		return strings.Compare(a.EditionID, b.EditionID)
+	})
+	return s
+}
+
+// serveStatus serves the state of the hosts as JSON.
+func (f *fleet) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck // the client may be gone.
+	_ = json.NewEncoder(w).Encode(f.status())
+}
+
+// validateReportURL checks that value, the value of the setting name, is
+// an http or https URL.
+func validateReportURL(name, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != schemeHTTPS) || u.Host == "" {
+		return fmt.Errorf("`%s' must be an http or https URL, got '%s'", name, notify.Redact(value))
+	}
+	return nil
+}
+
+// uploadReport uploads rep to ReportURL.
+func (u *Updater) uploadReport(ctx context.Context, rep report) error {
+	body, err := json.Marshal(rep)
+	if err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.config.ReportURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+	if u.config.ReportToken != "" {
+		req.Header.Set("Authorization", "Bearer "+u.config.ReportToken)
+	}
+
+	httpClient := u.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading report to %s: %w", notify.Redact(u.config.ReportURL), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf(
+			"uploading report to %s: unexpected HTTP status code %d",
+			notify.Redact(u.config.ReportURL),
+			response.StatusCode,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// TestSeedHandlerFleet tests that the seed aggregates the builds of the
+// reports uploaded by hosts.
+func TestSeedHandlerFleet(t *testing.T) {
+	server := httptest.NewServer(NewSeedHandler(&Config{
+		DatabaseDirectory: t.TempDir(),
+		ReportToken:       "secret",
+	}))
+	defer server.Close()
+
+	newBuild := time.Date(2024, 2, 27, 0, 0, 0, 0, time.UTC)
+	oldBuild := time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC)
+	uploadWith := func(token string, r report) int {
+		body, err := json.Marshal(r)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/reports", bytes.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	upload := func(r report) int {
+		return uploadWith("secret", r)
+	}
+
+	assert.Equal(t, http.StatusNoContent, upload(report{
+		Hostname: "edge-1",
+		Version:  "7.1.0",
+		Editions: []database.ReadResult{
+			{EditionID: "GeoIP2-City", NewHash: "B", ModifiedAt: newBuild},
+			{EditionID: "GeoIP2-ISP", NewHash: "I"},
+		},
+	}))
+	assert.Equal(t, http.StatusNoContent, upload(report{
+		Hostname: "edge-2",
+		Editions: []database.ReadResult{
+			{EditionID: "GeoIP2-City", NewHash: "A", ModifiedAt: oldBuild},
+		},
+		Warnings: []Warning{{Code: WarningStaleEdition}},
+	}))
+	// The build date of an edition that wasn't updated is kept.
+	assert.Equal(t, http.StatusNoContent, upload(report{
+		Hostname: "edge-2",
+		Editions: []database.ReadResult{
+			{EditionID: "GeoIP2-City", NewHash: "A"},
+		},
+	}))
+	assert.Equal(t, http.StatusBadRequest, upload(report{}))
+	// Reports without the token can't overwrite those of other hosts.
+	assert.Equal(t, http.StatusUnauthorized, uploadWith("", report{Hostname: "edge-1"}))
+	assert.Equal(t, http.StatusUnauthorized, uploadWith("wrong", report{Hostname: "edge-1"}))
+
+	res, err := http.Get(server.URL + "/fleet-status")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	var status FleetStatus
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+
+	assert.Equal(t, []FleetEdition{
+		{
+			EditionID: "GeoIP2-City",
+			Builds: []FleetBuild{
+				{MD5: "B", BuildDate: newBuild, Hosts: []string{"edge-1"}},
+				{MD5: "A", BuildDate: oldBuild, Hosts: []string{"edge-2"}},
+			},
+		},
+		{
+			EditionID: "GeoIP2-ISP",
+			Builds:    []FleetBuild{{MD5: "I", Hosts: []string{"edge-1"}}},
+		},
+	}, status.Editions)
+	require.Len(t, status.Hosts, 2)
+	assert.Equal(t, "edge-1", status.Hosts[0].Hostname)
+	assert.Equal(t, "7.1.0", status.Hosts[0].Version)
+	assert.Equal(t, "edge-2", status.Hosts[1].Hostname)
+	assert.Equal(t, 0, status.Hosts[1].Warnings)
+}
+
+// TestSeedHandlerWithoutReportToken tests that a seed without ReportToken
+// refuses uploads.
+func TestSeedHandlerWithoutReportToken(t *testing.T) {
+	server := httptest.NewServer(NewSeedHandler(&Config{}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/reports", strings.NewReader(`{"hostname":"edge-1"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer ")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+// TestFleetMaxHosts tests that the host that reported last the longest ago
+// is forgotten once maxFleetHosts is reached.
+func TestFleetMaxHosts(t *testing.T) {
+	f := newFleet("secret")
+	start := time.Date(2024, 2, 27, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxFleetHosts; i++ {
+		f.add(report{Hostname: fmt.Sprintf("edge-%d", i)}, start.Add(time.Duration(i)*time.Second))
+	}
+	// edge-0 reports again, so edge-1 is now the stalest host.
+	f.add(report{Hostname: "edge-0"}, start.Add(maxFleetHosts*time.Second))
+	require.Len(t, f.hosts, maxFleetHosts)
+
+	f.add(report{Hostname: "edge-new"}, start.Add((maxFleetHosts+1)*time.Second))
+	assert.Len(t, f.hosts, maxFleetHosts)
+	assert.Contains(t, f.hosts, "edge-0")
+	assert.Contains(t, f.hosts, "edge-new")
+	assert.NotContains(t, f.hosts, "edge-1")
+}
+
+// TestUpdaterUploadReport tests that the report of a run is uploaded to
+// ReportURL.
+func TestUpdaterUploadReport(t *testing.T) {
+	server := httptest.NewServer(NewSeedHandler(&Config{ReportToken: "secret"}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	u := &Updater{
+		config: &Config{
+			EditionIDs:  []string{"GeoLite2-City"},
+			LockFile:    filepath.Join(tempDir, ".geoipupdate.lock"),
+			Parallelism: 1,
+			ReportToken: "secret",
+			ReportURL:   server.URL + "/reports",
+		},
+		updateClient: &mockUpdateClient{outputs: []client.DownloadResponse{{
+			MD5:             "B",
+			Reader:          io.NopCloser(strings.NewReader("")),
+			UpdateAvailable: true,
+		}}},
+		writer: &mockWriter{md5s: map[string]string{"GeoLite2-City": "A"}},
+	}
+	require.NoError(t, u.Run(context.Background()))
+
+	res, err := http.Get(server.URL + "/fleet-status")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	var status FleetStatus
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+	require.Len(t, status.Editions, 1)
+	assert.Equal(t, "B", status.Editions[0].Builds[0].MD5)
+}
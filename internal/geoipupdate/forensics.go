@@ -0,0 +1,57 @@
+package geoipupdate
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// newChecksumMismatch returns the forensic record of err, the error of
+// writing the database of res.
+func newChecksumMismatch(
+	res client.DownloadResponse,
+	err *database.ChecksumMismatchError,
+) database.ChecksumMismatch {
+	m := database.ChecksumMismatch{
+		ExpectedMD5: err.Expected,
+		ActualMD5:   err.Actual,
+		Bytes:       err.Bytes,
+		BadFile:     err.BadFile,
+		FromPeers:   res.FromPeers,
+	}
+	for name, values := range res.Header {
+		if name == "Set-Cookie" {
+			continue
+		}
+		if m.Header == nil {
+			m.Header = map[string]string{}
+		}
+		m.Header[name] = strings.Join(values, ", ")
+	}
+	return m
+}
+
+// logChecksumMismatch logs the forensic record m of a download of
+// editionID.
+func (u *Updater) logChecksumMismatch(editionID string, m database.ChecksumMismatch) {
+	u.logf(
+		"Checksum mismatch for %s: expected %s, got %s after %d bytes",
+		editionID, m.ExpectedMD5, m.ActualMD5, m.Bytes,
+	)
+	if m.BadFile != "" {
+		u.logf("Kept the database received for %s at %s", editionID, m.BadFile)
+	}
+	if m.FromPeers {
+		u.logf("The database for %s was received from peers", editionID)
+	}
+	names := make([]string, 0, len(m.Header))
+	for name := range m.Header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		u.logf("Response header for %s: %s: %s", editionID, name, m.Header[name])
+	}
+}
@@ -4,11 +4,15 @@ package geoipupdate
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,152 +20,949 @@ import (
 
 	"github.com/maxmind/geoipupdate/v7/client"
 	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/blob"
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
 	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+	"github.com/maxmind/geoipupdate/v7/internal/oci"
 )
 
+// metadataCacheFile is the name of the file in the database directory the
+// latest builds of the editions are kept in for MetadataCacheTTL.
+const metadataCacheFile = ".geoipupdate.metadata"
+
+// progressExtension is appended to the lock file path to get the path of
+// the file where the instance holding the lock reports its progress.
+const progressExtension = ".progress"
+
 type updateClient interface {
 	Download(context.Context, string, string) (client.DownloadResponse, error)
 }
 
+// metadataBatcher is implemented by update clients that can check several
+// editions for updates with a single request.
+type metadataBatcher interface {
+	BatchMetadata(editionIDs []string)
+}
+
 // Updater uses config data to initiate a download or update
 // process for GeoIP databases.
 type Updater struct {
-	config       *Config
-	output       *log.Logger
+	config *Config
+	// downloader is the updateClient wrapped by the download middlewares.
+	// The updateClient is used if it is nil.
+	downloader updateClient
+	// fence fences the writes with the lease of the current run, if
+	// LockType is lease.
+	fence *runFence
+	// hashCache caches the hashes of the installed databases in the state
+	// of the current run.
+	hashCache *runHashCache
+	// editionIDs are the editions of the current or last run, resolved
+	// from the EditionIDs and EditionPatterns of config.
+	editionIDs []string
+	// httpClient is used for requests other than downloads, e.g., to
+	// upload reports.
+	httpClient *http.Client
+	// notifiers announce updated databases, one per Notify target.
+	notifiers []notify.Notifier
+	output    *outputWriter
+	// plan holds the downloads of the plan being applied, by edition ID.
+	plan map[string]PlannedEdition
+	// pusher is the repository updated databases are pushed to, if any.
+	pusher *oci.Repository
+	// runDirectory switches the directory of the databases for each run
+	// with DatabaseDirectories or ConsistentSet.
+	runDirectory runDirectory
+	// s3Pusher publishes the databases to S3Push, if set.
+	s3Pusher     *s3Pusher
 	updateClient updateClient
-	writer       database.Writer
+	// warnings are those of the current or last run.
+	warnings *warningList
+	writer   database.Writer
 }
 
 // NewUpdater initialized a new Updater struct.
 func NewUpdater(config *Config) (*Updater, error) {
-	transport := http.DefaultTransport
+	// The default transport is cloned so that the proxy only applies to
+	// the requests of the updater, e.g., when embedded in a program.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	if config.Proxy != nil {
-		proxyFunc := http.ProxyURL(config.Proxy)
-		transport.(*http.Transport).Proxy = proxyFunc
+		transport.Proxy = http.ProxyURL(config.Proxy)
 	}
 	httpClient := &http.Client{Transport: transport}
 
-	updateClient, err := client.New(
-		config.AccountID,
-		config.LicenseKey,
-		client.WithEndpoint(config.URL),
-		client.WithHTTPClient(httpClient),
-	)
+	// The databases pushed to OCIPush and S3Push are encrypted with the
+	// same key, and those downloaded from OCIMirror and S3Mirror are
+	// decrypted with it.
+	ociOptions := []oci.Option{oci.WithHTTPClient(httpClient)}
+	var encryptionKey *encryption.Key
+	if config.EncryptionKeyFile != "" {
+		key, err := encryption.ReadKeyFile(config.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encryptionKey = &key
+		ociOptions = append(ociOptions, oci.WithEncryptionKey(key))
+	}
+	var keyWrapper encryption.KeyWrapper
+	if config.EncryptionKMS != "" {
+		wrapper, err := encryption.NewKeyWrapper(config.EncryptionKMS, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		keyWrapper = wrapper
+		ociOptions = append(ociOptions, oci.WithKeyWrapper(wrapper))
+	}
+
+	var updateClient updateClient
+	switch {
+	case config.OCIMirror != "":
+		repo, err := oci.New(config.OCIMirror, ociOptions...)
+		if err != nil {
+			return nil, err
+		}
+		updateClient = repo
+	case config.S3Mirror != "":
+		auth, err := sigV4Auth(s3Region(config), httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("authenticating to the S3 mirror: %w", err)
+		}
+		updateClient = client.NewS3Reader(s3BucketURL(config, config.S3Mirror), auth, httpClient).
+			WithDecrypter(s3Decrypter(encryptionKey, keyWrapper))
+	default:
+		clientOptions, err := hostAuthOptions(config, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions = append(
+			clientOptions,
+			client.WithEndpoint(config.URL),
+			client.WithHTTPClient(httpClient),
+		)
+		if !config.withoutResume {
+			clientOptions = append(clientOptions, client.WithResumeDirectory(config.DatabaseDirectory))
+		}
+		if len(config.Peers) > 0 {
+			clientOptions = append(clientOptions, client.WithPeers(config.Peers))
+		}
+		if config.MetadataCacheTTL > 0 {
+			clientOptions = append(clientOptions, client.WithMetadataCache(
+				filepath.Join(config.DatabaseDirectory, metadataCacheFile),
+				config.MetadataCacheTTL,
+			))
+		}
+		if config.TraceFile != "" {
+			clientOptions = append(clientOptions, client.WithTrace(traceFile(config.TraceFile)))
+		}
+
+		updateClient, err = client.New(config.AccountID, config.LicenseKey, clientOptions...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var writerOptions []database.LocalFileWriterOption
+	if config.ArchiveDirectory != "" {
+		writerOptions = append(writerOptions, database.WithArchiveDirectory(config.ArchiveDirectory))
+	}
+	if config.WriteStrategy == database.WriteStrategyCopy {
+		writerOptions = append(writerOptions, database.WithCopyStrategy(config.TempDirectory))
+	}
+	if config.ConsumerLockTimeout > 0 {
+		writerOptions = append(writerOptions, database.WithConsumerLockTimeout(config.ConsumerLockTimeout))
+	}
+	if config.ChecksumForensics {
+		writerOptions = append(writerOptions, database.WithKeepBadFiles())
+	}
+	if config.AllowDowngrade {
+		writerOptions = append(writerOptions, database.WithAllowDowngrade())
+	}
+	if config.MaxDiskUsage > 0 {
+		writerOptions = append(writerOptions, database.WithMaxDiskUsage(config.MaxDiskUsage))
+	}
+	if config.ArchiveS3 != "" {
+		archiver, err := newS3Pusher(config, config.ArchiveS3, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("setting up the S3 archive: %w", err)
+		}
+		archiver.storageClass = archiveStorageClass(config)
+		writerOptions = append(writerOptions, database.WithArchiveUploader(archiver))
+	}
+	hashCache := &runHashCache{}
+	writerOptions = append(writerOptions, database.WithHashCache(hashCache))
+	var fence *runFence
+	if config.LockType == internal.LockTypeLease {
+		fence = &runFence{}
+		writerOptions = append(writerOptions, database.WithFence(fence.check))
+	}
+
+	downloadMiddleware, writerMiddleware, err := middlewares(config)
 	if err != nil {
 		return nil, err
 	}
+	if config.Destination != "" {
+		bucket, err := blob.New(config.Destination, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("setting up the destination: %w", err)
+		}
+		// The uploads come last, once the other middlewares accepted the
+		// databases.
+		// The encoding was validated with the configuration.
+		enc, _ := encoding.Lookup(config.DestinationEncoding)
+		writerMiddleware = append(writerMiddleware, destinationWriter(bucket, config.TempDirectory, enc))
+	}
+	newLocalWriter := func(dir string) (*database.LocalFileWriter, error) {
+		return database.NewLocalFileWriter(
+			dir,
+			config.PreserveFileTimes,
+			config.Verbose,
+			writerOptions...,
+		)
+	}
+	newWriter := func(dir string) (database.Writer, error) {
+		localWriter, err := newLocalWriter(dir)
+		if err != nil {
+			return nil, err
+		}
+		return database.ChainWriter(localWriter, writerMiddleware...), nil
+	}
 
-	writer, err := database.NewLocalFileWriter(
-		config.DatabaseDirectory,
-		config.PreserveFileTimes,
-		config.Verbose,
-	)
+	var runDir runDirectory
+	var writer database.Writer
+	switch {
+	case len(config.DatabaseDirectories) > 0:
+		runDir, err = newBlueGreen(config, newWriter)
+	case config.ConsistentSet:
+		runDir, err = newStagedSet(config, newLocalWriter, writerMiddleware)
+	default:
+		writer, err = newWriter(config.DatabaseDirectory)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if runDir != nil {
+		writer = runDir.writer()
+	}
+
+	var pusher *oci.Repository
+	if config.OCIPush != "" {
+		// The encoding was validated with the configuration.
+		enc, _ := encoding.Lookup(config.OCIPushEncoding)
+		pusher, err = oci.New(config.OCIPush, append(ociOptions, oci.WithEncoding(enc))...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var s3p *s3Pusher
+	if config.S3Push != "" {
+		s3p, err = newS3Pusher(config, config.S3Push, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		s3p.key = encryptionKey
+		s3p.keyWrapper = keyWrapper
+	}
+
+	var notifiers []notify.Notifier
+	for _, target := range config.Notify {
+		notifier, err := notify.New(target, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
 
 	return &Updater{
 		config:       config,
-		output:       log.New(os.Stdout, "", 0),
+		downloader:   client.Chain(updateClient, downloadMiddleware...),
+		fence:        fence,
+		hashCache:    hashCache,
+		httpClient:   httpClient,
+		notifiers:    notifiers,
+		output:       newOutputWriter(os.Stdout),
+		pusher:       pusher,
+		s3Pusher:     s3p,
+		runDirectory: runDir,
 		updateClient: updateClient,
 		writer:       writer,
 	}, nil
 }
 
+// databaseDir returns the directory the databases of the current run are
+// installed in: the DatabaseDirectory, or the directory of DatabaseDirectories
+// being updated or, between runs, the active one.
+func (u *Updater) databaseDir() string {
+	if u.runDirectory != nil {
+		return u.runDirectory.current()
+	}
+	return u.config.DatabaseDirectory
+}
+
 // Run starts the download or update process.
 func (u *Updater) Run(ctx context.Context) error {
-	fileLock, err := internal.NewFileLock(u.config.LockFile, u.config.Verbose)
+	_, err := u.RunEditions(ctx)
+	return err
+}
+
+// RunEditions is like Run, but also returns the processed editions, e.g., to
+// tell whether any was updated.
+func (u *Updater) RunEditions(ctx context.Context) ([]database.ReadResult, error) {
+	start := time.Now()
+	runID := runIDFrom(ctx)
+	if u.config.Verbose {
+		u.logf("Using configuration %s", configHash(u.config))
+	}
+	u.warnings = &warningList{}
+	// The deprecated options are logged by LintConfig.
+	for _, w := range deprecationWarnings(u.config) {
+		u.warnings.add(w)
+	}
+
+	fileLock, err := internal.NewLock(u.config.LockType, u.config.LockFile, u.config.Verbose)
 	if err != nil {
-		return fmt.Errorf("initializing file lock: %w", err)
+		return nil, fmt.Errorf("initializing file lock: %w", err)
 	}
+	progressFile := u.config.LockFile + progressExtension
 	if err := fileLock.Acquire(); err != nil {
-		return fmt.Errorf("acquiring file lock: %w", err)
+		if u.config.SkipIfRunning && errors.Is(err, internal.ErrLockHeld) {
+			u.logSkipped(progressFile)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("acquiring file lock: %w", err)
 	}
 	defer func() {
 		if err := fileLock.Release(); err != nil {
-			log.Printf("releasing file lock: %s", err)
+			u.logf("releasing file lock: %s", err)
 		}
 	}()
+	if fencedLock, ok := fileLock.(internal.FencedLock); ok && u.fence != nil {
+		u.fence.set(fencedLock)
+		defer u.fence.set(nil)
+	}
+
+	store, err := state.Open(u.config.StateFile)
+	if err != nil {
+		// A broken state file must not prevent updates.
+		u.logf("Ignoring state file: %s", err)
+		store = state.New(u.config.StateFile)
+	}
+	if u.hashCache != nil {
+		u.hashCache.set(store)
+		defer u.hashCache.set(nil)
+	}
+
+	if u.config.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.config.RunTimeout)
+		defer cancel()
+	}
 
-	jobProcessor := internal.NewJobProcessor(ctx, u.config.Parallelism)
+	// The editions are canceled separately so that failFast can skip them
+	// without affecting the rest of the run.
+	jobCtx, cancelJobs := context.WithCancel(ctx)
+	defer cancelJobs()
+	breaker := newFailFast(u.config.FailFastThreshold, cancelJobs)
+
+	jobProcessor := internal.NewJobProcessor(jobCtx, u.config.Parallelism)
+
+	u.editionIDs, err = u.resolveEditionIDs(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	editionIDs := u.orderEditions(store, u.editionIDs)
+	if u.runDirectory != nil {
+		if err := u.runDirectory.begin(ctx, editionIDs); err != nil {
+			return nil, err
+		}
+		u.writer = u.runDirectory.writer()
+		defer func() {
+			u.runDirectory.end()
+			u.writer = u.runDirectory.writer()
+		}()
+	}
+	if b, ok := u.updateClient.(metadataBatcher); ok {
+		b.BatchMetadata(editionIDs)
+	}
 
+	progress, err := state.NewProgressWriter(progressFile, editionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("initializing progress file: %w", err)
+	}
+	defer func() {
+		if err := progress.Remove(); err != nil {
+			u.logf("%s", err)
+		}
+	}()
+	downloader := u.downloader
+	if downloader == nil {
+		downloader = u.updateClient
+	}
+	selfCheck := u.newSelfCheck(ctx)
+	barrier := newEditionBarrier(u.config.EditionDependencies, editionIDs)
+	if u.config.Verbose {
+		barrier.logf = u.logf
+	}
 	var editions []database.ReadResult
+	started := map[string]bool{}
+	var failed int
+	var downloaded int64
 	var mu sync.Mutex
-	for _, editionID := range u.config.EditionIDs {
+	completed := func(edition database.ReadResult) {
+		mu.Lock()
+		editions = append(editions, edition)
+		mu.Unlock()
+		// The stream format outputs each edition as soon as it completes.
+		if u.config.Output && u.config.OutputFormat == OutputFormatStream {
+			if err := u.output.emit(edition); err != nil {
+				u.logf("%s", err)
+			}
+		}
+	}
+	for _, editionID := range editionIDs {
 		editionID := editionID
 		processFunc := func(ctx context.Context) error {
-			edition, err := u.downloadEdition(ctx, editionID, u.updateClient, u.writer)
-			if err != nil {
+			if err := barrier.wait(ctx, editionID); err != nil {
 				return err
 			}
+			mu.Lock()
+			started[editionID] = true
+			mu.Unlock()
 
-			edition.CheckedAt = time.Now().In(time.UTC)
+			if u.corruptEdition(ctx, store, editionID) {
+				// The corrupt database must not be used as the current one.
+				err := store.Update(editionID, func(e *state.Edition) {
+					e.Hash = ""
+					e.LastSuccess = time.Time{}
+				})
+				if err != nil {
+					return fmt.Errorf("updating state of %s: %w", editionID, err)
+				}
+			}
 
+			if edition := u.cachedEdition(ctx, store, editionID); edition != nil {
+				if err := progress.Complete(editionID); err != nil {
+					u.logf("%s", err)
+				}
+				completed(*edition)
+				return nil
+			}
+
+			err := store.Update(editionID, func(e *state.Edition) {
+				e.Pending = true
+				e.LastAttempt = u.config.getClock().Now().In(time.UTC)
+			})
+			if err != nil {
+				return fmt.Errorf("updating state of %s: %w", editionID, err)
+			}
+
+			var attempts []state.Attempt
+			edition, err := u.downloadEdition(
+				ctx,
+				editionID,
+				downloader,
+				u.writer,
+				func(a state.Attempt) { attempts = append(attempts, a) },
+			)
 			mu.Lock()
-			editions = append(editions, *edition)
+			for _, a := range attempts {
+				downloaded += a.Bytes
+			}
 			mu.Unlock()
+			if errors.Is(err, client.ErrEditionUnavailable) {
+				edition, err := u.unavailableEdition(ctx, store, editionID, attempts, err)
+				if err != nil {
+					return err
+				}
+				if err := progress.Complete(editionID); err != nil {
+					u.logf("%s", err)
+				}
+				completed(*edition)
+				return nil
+			}
+			if err != nil {
+				// The attempts tell why the update failed.
+				serr := store.Update(editionID, func(e *state.Edition) {
+					e.Attempts = attempts
+					// Editions canceled because of others aren't failing.
+					if !errors.Is(err, context.Canceled) {
+						recordFailure(e, err, u.config.getClock().Now())
+					}
+				})
+				if serr != nil {
+					u.logf("updating state of %s: %s", editionID, serr)
+				}
+				breaker.failed(attempts, err)
+				return err
+			}
+			breaker.succeeded()
+
+			u.warnClockSkew(editionID, edition.ClockSkew)
+			edition.CheckedAt = serverNow(u.config.getClock().Now(), edition.ClockSkew)
+			updated := edition.NewHash != edition.OldHash
+			if updated {
+				edition.UpdateID = updateID(editionID, edition.NewHash)
+				if !edition.ModifiedAt.IsZero() {
+					edition.PropagationLag = edition.CheckedAt.Sub(edition.ModifiedAt)
+				}
+			}
+
+			err = store.Update(editionID, func(e *state.Edition) {
+				e.Pending = false
+				e.Hash = edition.NewHash
+				e.LastSuccess = edition.CheckedAt
+				e.ClockSkew = edition.ClockSkew
+				e.Attempts = attempts
+				e.ConsecutiveFailures = 0
+				e.LastError = ""
+				if updated {
+					e.BuildDate = edition.ModifiedAt
+					e.LastModified = edition.LastModified
+					e.InstalledAt = edition.CheckedAt
+					e.AnnouncePending = len(u.notifiers) > 0
+					e.PostUpdatePending = u.config.PostUpdateCommand != ""
+					e.AddChange(newChange(edition, attempts))
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("updating state of %s: %w", editionID, err)
+			}
+			buildDate := store.Edition(editionID).BuildDate
+			if !u.warnCadence(editionID, buildDate, edition.CheckedAt) && !updated {
+				u.warnStaleEdition(editionID, buildDate, edition.CheckedAt)
+			}
+
+			if u.pusher != nil {
+				if err := u.push(ctx, store, edition); err != nil {
+					return err
+				}
+			}
+
+			if err := u.postUpdate(ctx, store, edition); err != nil {
+				return err
+			}
+			if updated && selfCheck != nil {
+				edition.SelfCheck = selfCheck(editionID)
+			}
+
+			if err := progress.Complete(editionID); err != nil {
+				u.logf("%s", err)
+			}
+
+			completed(*edition)
 			return nil
 		}
 
-		jobProcessor.Add(processFunc)
+		jobProcessor.Add(func(ctx context.Context) error {
+			err := processFunc(ctx)
+			barrier.finish(editionID, err)
+			if err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+			return err
+		})
 	}
 
 	// Run blocks until all jobs are processed or exits early after
 	// the first encountered error.
-	if err := jobProcessor.Run(ctx); err != nil {
-		return fmt.Errorf("running the job processor: %w", err)
+	err = jobProcessor.Run(jobCtx)
+
+	// The summary is logged, and ends the stream output, whether the run
+	// succeeded or not.
+	mu.Lock()
+	summary := newRunSummary(editionIDs, editions, failed, downloaded)
+	mu.Unlock()
+	defer func() {
+		summary.finish(start)
+		if u.config.Verbose {
+			u.logf("Run summary: %s", summary)
+		}
+		if u.config.Output && u.config.OutputFormat == OutputFormatStream {
+			if err := u.output.emit(summaryRecord{Summary: summary}); err != nil {
+				u.logf("%s", err)
+			}
+		}
+	}()
+
+	if u.config.MetricsFile != "" {
+		// The metrics are also useful when the run fails.
+		err := store.WriteMetrics(
+			u.config.MetricsFile,
+			u.editionIDs,
+			u.metricsLabels(),
+			configHash(u.config),
+		)
+		if err != nil {
+			u.logf("%s", err)
+		}
+	}
+
+	// The alerts matter most when the run failed.
+	if err := u.alert(ctx, store, runID); err != nil {
+		u.logf("%s", err)
+	}
+
+	if err != nil {
+		if err := breaker.err(); err != nil {
+			return nil, err
+		}
+		if u.config.RunTimeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, u.runTimeoutError(store, editionIDs, editions, started)
+		}
+		return nil, fmt.Errorf("running the job processor: %w", err)
+	}
+
+	if u.runDirectory != nil {
+		if err := u.runDirectory.publish(ctx, editions); err != nil {
+			return nil, err
+		}
 	}
 
-	if u.config.Output {
-		result, err := json.Marshal(editions)
+	if u.config.LayerFile != "" {
+		err := writeLayer(
+			u.config.LayerFile,
+			u.databaseDir(),
+			availableEditionIDs(u.editionIDs, editions),
+		)
 		if err != nil {
-			return fmt.Errorf("marshaling result log: %w", err)
+			return nil, fmt.Errorf("writing layer file: %w", err)
 		}
-		u.output.Print(string(result))
 	}
 
+	if u.s3Pusher != nil {
+		if err := u.pushS3(ctx, store, editions); err != nil {
+			return nil, err
+		}
+	}
+
+	if u.config.IntegrityFile != "" {
+		err := writeIntegrityFile(
+			u.config.IntegrityFile,
+			u.databaseDir(),
+			availableEditionIDs(u.editionIDs, editions),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("writing integrity file: %w", err)
+		}
+	}
+
+	if u.config.Output && u.config.OutputFormat != OutputFormatStream {
+		var output any = editions
+		if u.config.OutputFormat == OutputFormatReport {
+			r := newReport(u.config, runID, editions, u.Warnings())
+			summary.finish(start)
+			r.Summary = summary
+			output = r
+		}
+		if err := u.output.emit(output); err != nil {
+			return nil, err
+		}
+	}
+
+	if u.config.ReportURL != "" {
+		// The run succeeded even if its report can't be uploaded.
+		err := u.uploadReport(ctx, newReport(u.config, runID, editions, u.Warnings()))
+		if err != nil {
+			u.logf("%s", err)
+		}
+	}
+
+	if err := u.announce(ctx, store, runID, editions); err != nil {
+		return nil, fmt.Errorf("announcing updates: %w", err)
+	}
+
+	return editions, nil
+}
+
+// push pushes the installed database of edition to the OCIPush repository
+// unless it already holds it, e.g., because a previous push failed, and
+// reads it back with OCIPushVerify.
+func (u *Updater) push(ctx context.Context, store *state.Store, edition *database.ReadResult) error {
+	path := database.FilePath(u.databaseDir(), edition.EditionID)
+	modifiedAt, err := buildDate(store, path, edition)
+	if err != nil {
+		return fmt.Errorf("pushing %s: %w", edition.EditionID, err)
+	}
+
+	err = u.pusher.Push(ctx, edition.EditionID, path, edition.NewHash, modifiedAt)
+	if err != nil {
+		return fmt.Errorf("pushing %s to %s: %w", edition.EditionID, u.config.OCIPush, err)
+	}
+	if u.config.OCIPushVerify {
+		err := u.pusher.Verify(ctx, edition.EditionID, edition.NewHash)
+		if err != nil {
+			return fmt.Errorf("verifying %s in %s: %w", edition.EditionID, u.config.OCIPush, err)
+		}
+	}
 	return nil
 }
 
-// downloadEdition downloads the file with retries.
+// buildDate returns the build date of the database of edition installed at
+// path: its publication date if known, and otherwise its modification time.
+func buildDate(store *state.Store, path string, edition *database.ReadResult) (time.Time, error) {
+	if !edition.ModifiedAt.IsZero() {
+		return edition.ModifiedAt, nil
+	}
+	if d := store.Edition(edition.EditionID).BuildDate; !d.IsZero() {
+		return d, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading database: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// logf logs a message, prefixed with the profile of the configuration if
+// any.
+func (u *Updater) logf(format string, args ...any) {
+	if u.config.Profile != "" {
+		format = "[" + u.config.Profile + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// metricsLabels returns the labels added to the metrics: the Labels
+// setting and the profile, if any.
+func (u *Updater) metricsLabels() map[string]string {
+	labels := maps.Clone(u.config.Labels)
+	if u.config.Profile != "" {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels["profile"] = u.config.Profile
+	}
+	return labels
+}
+
+// logSkipped reports that the run was skipped because another instance
+// holds the lock, along with that instance's progress when available.
+func (u *Updater) logSkipped(progressFile string) {
+	p, err := state.ReadProgress(progressFile)
+	if err != nil {
+		u.logf("skipped: another instance running")
+		return
+	}
+	u.logf(
+		"skipped: another instance running since %s (pid %d, %d/%d editions completed)",
+		p.StartedAt.Format(time.RFC3339),
+		p.PID,
+		len(p.Completed),
+		len(p.Editions),
+	)
+}
+
+// runTimeoutError reports the editions that were canceled or never started
+// because RunTimeout was exceeded. Editions that were never started are
+// marked as pending so that the next run gives them priority.
+func (u *Updater) runTimeoutError(
+	store *state.Store,
+	editionIDs []string,
+	editions []database.ReadResult,
+	started map[string]bool,
+) error {
+	updated := map[string]bool{}
+	for _, edition := range editions {
+		updated[edition.EditionID] = true
+	}
+
+	var canceled, skipped []string
+	for _, editionID := range editionIDs {
+		switch {
+		case updated[editionID]:
+		case started[editionID]:
+			canceled = append(canceled, editionID)
+		default:
+			skipped = append(skipped, editionID)
+			err := store.Update(editionID, func(e *state.Edition) {
+				e.Pending = true
+			})
+			if err != nil {
+				u.logf("Updating state of %s: %s", editionID, err)
+			}
+		}
+	}
+
+	return fmt.Errorf(
+		"run timeout of %s exceeded; canceled editions: [%s]; skipped editions: [%s]",
+		u.config.RunTimeout,
+		strings.Join(canceled, ", "),
+		strings.Join(skipped, ", "),
+	)
+}
+
+// orderEditions returns editionIDs with the editions that a previous run
+// failed to update first, so that they are given priority, each group
+// being sorted according to EditionOrder. The editions others depend on
+// are then moved before them.
+func (u *Updater) orderEditions(store *state.Store, editionIDs []string) []string {
+	var pending, rest []string
+	for _, editionID := range editionIDs {
+		if store.Edition(editionID).Pending {
+			if u.config.Verbose {
+				u.logf("Resuming update of %s left unfinished by a previous run", editionID)
+			}
+			pending = append(pending, editionID)
+			continue
+		}
+		rest = append(rest, editionID)
+	}
+	u.sortEditions(pending)
+	u.sortEditions(rest)
+	return sortDependencies(append(pending, rest...), u.config.EditionDependencies)
+}
+
+// corruptEdition returns whether the installed database of editionID is
+// corrupt, e.g., truncated by a crash, while the state says it is current.
+// Corrupt databases are downloaded again like missing ones as their hash
+// doesn't match that of the latest build.
+func (u *Updater) corruptEdition(ctx context.Context, store *state.Store, editionID string) bool {
+	installed := store.Edition(editionID)
+	if installed.Pending || installed.Hash == "" {
+		return false
+	}
+	hash, err := u.writer.GetHash(ctx, editionID)
+	if err != nil || hash == installed.Hash || hash == database.ZeroMD5 {
+		return false
+	}
+
+	// The database may also have been replaced by a valid one.
+	err = database.Validate(database.FilePath(u.databaseDir(), editionID))
+	if err == nil {
+		if u.config.Verbose {
+			u.logf("Database %s was replaced since it was installed", editionID)
+		}
+		return false
+	}
+	u.logf("Database %s is corrupt, downloading it again: %s", editionID, err)
+	return true
+}
+
+// cachedEdition returns the result of editionID without contacting the API
+// if its installed database was checked less than CacheMaxAge ago, or
+// installed less than its MinUpdateInterval ago. It returns nil if the API
+// must be contacted.
+func (u *Updater) cachedEdition(ctx context.Context, store *state.Store, editionID string) *database.ReadResult {
+	// Applying a plan performs all of its downloads.
+	interval := u.config.MinUpdateInterval[editionID]
+	if (u.config.CacheMaxAge <= 0 && interval <= 0) || u.plan != nil {
+		return nil
+	}
+	cached := store.Edition(editionID)
+	if cached.Pending || cached.LastSuccess.IsZero() {
+		return nil
+	}
+	// A check in the future means that the clock was wrong at the time,
+	// or is now.
+	now := serverNow(u.config.getClock().Now(), cached.ClockSkew)
+	within := func(t time.Time, d time.Duration) bool {
+		age := now.Sub(t)
+		return !t.IsZero() && age >= 0 && age < d
+	}
+	checked := within(cached.LastSuccess, u.config.CacheMaxAge)
+	installed := within(cached.InstalledAt, interval)
+	if !checked && !installed {
+		return nil
+	}
+	// The database may have been removed or replaced since.
+	hash, err := u.writer.GetHash(ctx, editionID)
+	if err != nil || hash != cached.Hash {
+		return nil
+	}
+
+	if u.config.Verbose {
+		if installed {
+			u.logf(
+				"Database %s installed at %s, not checking for updates before %s",
+				editionID,
+				cached.InstalledAt,
+				cached.InstalledAt.Add(interval),
+			)
+		} else {
+			u.logf("Database %s checked at %s, not checking for updates", editionID, cached.LastSuccess)
+		}
+	}
+	return &database.ReadResult{
+		EditionID: editionID,
+		OldHash:   hash,
+		NewHash:   hash,
+		CheckedAt: cached.LastSuccess,
+		Cached:    true,
+	}
+}
+
+// downloadEdition downloads the file with retries. onAttempt, if not nil,
+// is called after each attempt.
 func (u *Updater) downloadEdition(
 	ctx context.Context,
 	editionID string,
 	uc updateClient,
 	w database.Writer,
+	onAttempt func(state.Attempt),
 ) (*database.ReadResult, error) {
-	editionHash, err := w.GetHash(editionID)
+	editionHash, err := w.GetHash(ctx, editionID)
 	if err != nil {
 		return nil, err
 	}
+	if u.plan != nil {
+		if err := u.checkPlanned(editionID, editionHash); err != nil {
+			return nil, err
+		}
+	}
 
-	// RetryFor value of 0 means that no retries should be performed.
-	// Max zero retries has to be set to achieve that
-	// because the backoff never stops if MaxElapsedTime is zero.
-	exp := backoff.NewExponentialBackOff()
-	exp.MaxElapsedTime = u.config.RetryFor
-	b := backoff.BackOff(exp)
-	if exp.MaxElapsedTime == 0 {
-		b = backoff.WithMaxRetries(exp, 0)
+	// Download and write errors are retried for RetryFor and WriteRetryFor
+	// respectively, a value of 0 meaning that no retries are performed,
+	// according to the RetryPolicy of their class.
+	clock := u.config.getClock()
+	rb := newRetryBackOff(ctx, u.config.RetryPolicy, clock)
+	retryable := func(body *readErrorRecorder, err error) error {
+		return rb.retryable(err, attemptReason(body, err), "RetryFor", u.config.RetryFor)
+	}
+	writeRetryable := func(body *readErrorRecorder, err error) error {
+		return rb.retryable(err, attemptReason(body, err), "WriteRetryFor", u.config.WriteRetryFor)
 	}
 
 	var edition *database.ReadResult
-	err = backoff.RetryNotify(
-		func() error {
+	var mismatches []database.ChecksumMismatch
+	var clockSkew time.Duration
+	attempts := 0
+	err = backoff.RetryNotifyWithTimer(
+		func() (err error) {
+			attempts++
+			attemptStart := time.Now()
+			var body *readErrorRecorder
+			defer func() {
+				a := newAttempt(time.Since(attemptStart), body, err)
+				u.logAttempt(editionID, attempts, a, err)
+				if onAttempt != nil {
+					onAttempt(a)
+				}
+			}()
+
 			res, err := uc.Download(ctx, editionID, editionHash)
 			if err != nil {
-				if internal.IsPermanentError(err) {
+				return retryable(nil, err)
+			}
+			defer res.Reader.Close()
+			if !res.ServerTime.IsZero() {
+				clockSkew = res.ServerTime.Sub(clock.Now())
+			}
+
+			if u.plan != nil {
+				if err := u.checkPlannedDownload(editionID, res); err != nil {
 					return backoff.Permanent(err)
 				}
-
-				return err
 			}
-			defer res.Reader.Close()
 
 			if !res.UpdateAvailable {
 				if u.config.Verbose {
-					log.Printf("No new updates available for %s", editionID)
-					log.Printf("Database %s up to date", editionID)
+					u.logf("No new updates available for %s", editionID)
+					u.logf("Database %s up to date", editionID)
 				}
 
 				edition = &database.ReadResult{
@@ -173,41 +974,130 @@ func (u *Updater) downloadEdition(
 			}
 
 			if u.config.Verbose {
-				log.Printf("Updates available for %s", editionID)
+				u.logf("Updates available for %s", editionID)
+				if res.FromPeers {
+					u.logf("Downloaded %s from peers", editionID)
+				}
 			}
 
+			builtAt := buildTime(res)
+			reader := &sizeLimitReader{ReadCloser: res.Reader, limit: u.maxDecompressedSize(res)}
+			body = &readErrorRecorder{ReadCloser: reader}
 			err = u.writer.Write(
+				ctx,
 				editionID,
-				res.Reader,
+				body,
 				res.MD5,
-				res.LastModified,
+				builtAt,
 			)
 			if err != nil {
-				if internal.IsPermanentError(err) {
+				// Another writer already installed a build at least as
+				// recent.
+				var conflictErr *database.ConflictError
+				if errors.As(err, &conflictErr) {
+					u.logConflict(editionID, conflictErr)
+					installedHash, err := u.writer.GetHash(ctx, editionID)
+					if err != nil {
+						return backoff.Permanent(fmt.Errorf("getting hash of %s: %w", editionID, err))
+					}
+					edition = &database.ReadResult{
+						EditionID:    editionID,
+						OldHash:      editionHash,
+						NewHash:      installedHash,
+						ModifiedAt:   conflictErr.Installed,
+						LastModified: res.LastModified,
+						Host:         res.Host,
+					}
+					return nil
+				}
+				// Retrying won't make the database newer or smaller, nor
+				// give the lock back.
+				var downgradeErr *database.DowngradeError
+				var sizeErr *decompressedSizeError
+				var diskErr *database.DiskUsageError
+				if errors.As(err, &downgradeErr) || errors.As(err, &sizeErr) ||
+					errors.As(err, &diskErr) || errors.Is(err, internal.ErrLockLost) {
 					return backoff.Permanent(err)
 				}
-
-				return err
+				var mismatchErr *database.ChecksumMismatchError
+				if u.config.ChecksumForensics && errors.As(err, &mismatchErr) {
+					mismatch := newChecksumMismatch(res, mismatchErr)
+					u.logChecksumMismatch(editionID, mismatch)
+					mismatches = append(mismatches, mismatch)
+				}
+				// If reading the response failed, this is a download error
+				// even though it surfaced while writing.
+				if body.err != nil {
+					return retryable(body, err)
+				}
+				return writeRetryable(body, err)
 			}
 
 			edition = &database.ReadResult{
-				EditionID:  editionID,
-				OldHash:    editionHash,
-				NewHash:    res.MD5,
-				ModifiedAt: res.LastModified,
+				EditionID:    editionID,
+				OldHash:      editionHash,
+				NewHash:      res.MD5,
+				ModifiedAt:   builtAt,
+				LastModified: res.LastModified,
+				Host:         res.Host,
+			}
+			if res.FromPeers {
+				edition.Host = "peers"
 			}
 			return nil
 		},
-		b,
+		// Stop retrying once the run is canceled, e.g., by RunTimeout.
+		backoff.WithContext(rb, ctx),
 		func(err error, d time.Duration) {
-			if u.config.Verbose {
-				log.Printf("Couldn't download %s, retrying in %v: %v", editionID, d, err)
+			if !u.config.Verbose {
+				return
 			}
+			schedule := fmt.Sprintf("%v of %s left", rb.remaining().Round(time.Millisecond), rb.budgetName)
+			if d < rb.untruncated {
+				schedule += fmt.Sprintf(", truncated from %v", rb.untruncated)
+			}
+			u.logf("Couldn't download %s, retrying in %v (%s): %v", editionID, d, schedule, err)
 		},
+		&backoffTimer{clock: clock},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	edition.ChecksumMismatches = mismatches
+	edition.ClockSkew = clockSkew
 	return edition, nil
 }
+
+// buildTime returns when the database of res was built. The build date of
+// the metadata endpoint is preferred, as the Last-Modified header of the
+// download may be when a mirror or CDN copied the database, unless the
+// header falls on that date and so gives the time of day. Both are compared
+// in UTC, the time zone of the build date.
+func buildTime(res client.DownloadResponse) time.Time {
+	if res.BuildDate.IsZero() {
+		return res.LastModified
+	}
+	lastModified := res.LastModified.In(time.UTC)
+	if lastModified.Truncate(24 * time.Hour).Equal(res.BuildDate) {
+		return lastModified
+	}
+	return res.BuildDate
+}
+
+// readErrorRecorder records the first error other than io.EOF returned by
+// the wrapped reader, and how many bytes were read.
+type readErrorRecorder struct {
+	io.ReadCloser
+	err error
+	n   int64
+}
+
+func (r *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,61 @@
+package geoipupdate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// runHashCache is the database.HashCache of the Updater. It caches the
+// hashes of the installed databases in the StateFile of the current run, so
+// that runs and checks don't compute the MD5 sums of large databases each
+// time. Nothing is cached outside runs.
+type runHashCache struct {
+	mu    sync.Mutex
+	store *state.Store
+}
+
+// set sets the state of the current run, or nil once it is over.
+func (c *runHashCache) set(store *state.Store) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// FileHash implements database.HashCache.
+func (c *runHashCache) FileHash(editionID string) (database.FileHash, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store == nil {
+		return database.FileHash{}, false
+	}
+	cached := c.store.Edition(editionID).FileHash
+	if cached == nil {
+		return database.FileHash{}, false
+	}
+	return database.FileHash{
+		MD5:      cached.MD5,
+		Checksum: cached.Checksum,
+		Size:     cached.Size,
+		ModTime:  cached.ModTime,
+	}, true
+}
+
+// SetFileHash implements database.HashCache.
+func (c *runHashCache) SetFileHash(editionID string, h database.FileHash) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Update(editionID, func(e *state.Edition) {
+		e.FileHash = &state.FileHash{
+			MD5:      h.MD5,
+			Checksum: h.Checksum,
+			Size:     h.Size,
+			ModTime:  h.ModTime.In(time.UTC),
+		}
+	})
+}
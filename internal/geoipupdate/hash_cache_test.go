@@ -0,0 +1,43 @@
+package geoipupdate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+func TestRunHashCache(t *testing.T) {
+	h := database.FileHash{
+		MD5:      "city-md5",
+		Checksum: "xxh64:98c041128cbaa752",
+		Size:     4,
+		ModTime:  time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+	cache := &runHashCache{}
+
+	// Nothing is cached outside runs.
+	require.NoError(t, cache.SetFileHash("GeoIP2-City", h))
+	_, ok := cache.FileHash("GeoIP2-City")
+	assert.False(t, ok)
+
+	path := filepath.Join(t.TempDir(), ".geoipupdate.state")
+	cache.set(state.New(path))
+	require.NoError(t, cache.SetFileHash("GeoIP2-City", h))
+	cache.set(nil)
+
+	// The hashes are kept in the state of the next runs.
+	store, err := state.Open(path)
+	require.NoError(t, err)
+	cache.set(store)
+	cached, ok := cache.FileHash("GeoIP2-City")
+	require.True(t, ok)
+	assert.Equal(t, h, cached)
+	_, ok = cache.FileHash("GeoIP2-ISP")
+	assert.False(t, ok)
+}
@@ -0,0 +1,65 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// newChange describes the update of edition, which took attempts.
+func newChange(edition *database.ReadResult, attempts []state.Attempt) state.Change {
+	c := state.Change{
+		Date:      edition.CheckedAt,
+		BuildDate: edition.ModifiedAt,
+		OldHash:   edition.OldHash,
+		NewHash:   edition.NewHash,
+		Host:      edition.Host,
+	}
+	if c.OldHash == database.ZeroMD5 {
+		c.OldHash = ""
+	}
+	for _, a := range attempts {
+		c.Duration += a.Duration
+	}
+	if len(attempts) > 0 {
+		c.Size = attempts[len(attempts)-1].Bytes
+	}
+	return c
+}
+
+// HistoryEntry is a past update of an edition.
+type HistoryEntry struct {
+	EditionID string `json:"edition_id"`
+	state.Change
+}
+
+// History returns the past updates of editionID, or of every edition if
+// empty, recorded in the StateFile of config, most recent first. Only the
+// last state.HistoryLength updates of each edition are kept.
+func History(config *Config, editionID string) ([]HistoryEntry, error) {
+	store, err := state.Open(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	editionIDs := store.EditionIDs()
+	if editionID != "" {
+		if !slices.Contains(editionIDs, editionID) {
+			return nil, fmt.Errorf("%s has never been updated with the state file %s", editionID, config.StateFile)
+		}
+		editionIDs = []string{editionID}
+	}
+
+	entries := []HistoryEntry{}
+	for _, id := range editionIDs {
+		for _, c := range store.Edition(id).History {
+			entries = append(entries, HistoryEntry{EditionID: id, Change: c})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b HistoryEntry) int {
+		return b.Date.Compare(a.Date)
+	})
+	return entries, nil
+}
@@ -0,0 +1,70 @@
+package geoipupdate
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+)
+
+// TestUpdaterHistory tests that updates, and only them, are recorded in the
+// history of their edition.
+func TestUpdaterHistory(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := &Config{
+		EditionIDs:  []string{"GeoLite2-City", "GeoLite2-Country"},
+		LockFile:    filepath.Join(tempDir, ".geoipupdate.lock"),
+		Parallelism: 1,
+		StateFile:   filepath.Join(tempDir, ".geoipupdate.state"),
+	}
+
+	buildDate := time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC)
+	u := &Updater{
+		config: config,
+		updateClient: &mockUpdateClient{outputs: []client.DownloadResponse{
+			{
+				BuildDate:       buildDate,
+				Host:            "updates.maxmind.com",
+				MD5:             "B",
+				Reader:          io.NopCloser(strings.NewReader("database")),
+				UpdateAvailable: true,
+			},
+			{Reader: io.NopCloser(strings.NewReader(""))},
+		}},
+		writer: &mockWriter{
+			md5s: map[string]string{"GeoLite2-City": "A", "GeoLite2-Country": "C"},
+			writeFunc: func(_ string, reader io.ReadCloser, _ string, _ time.Time) error {
+				_, err := io.Copy(io.Discard, reader)
+				return err
+			},
+		},
+	}
+	_, err := u.RunEditions(context.Background())
+	require.NoError(t, err)
+
+	entries, err := History(config, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "GeoLite2-City", entries[0].EditionID)
+	assert.Equal(t, "A", entries[0].OldHash)
+	assert.Equal(t, "B", entries[0].NewHash)
+	assert.Equal(t, buildDate, entries[0].BuildDate)
+	assert.Equal(t, int64(len("database")), entries[0].Size)
+	assert.Equal(t, "updates.maxmind.com", entries[0].Host)
+	assert.False(t, entries[0].Date.IsZero())
+
+	entries, err = History(config, "GeoLite2-Country")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = History(config, "GeoIP2-ISP")
+	require.EqualError(t, err, "GeoIP2-ISP has never been updated with the state file "+config.StateFile)
+}
@@ -0,0 +1,128 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/awscreds"
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+)
+
+// Authentication schemes of HostAuth.
+const (
+	// AuthSchemeBasic uses the account ID and license key, as for the
+	// MaxMind servers.
+	AuthSchemeBasic = "basic"
+	// AuthSchemeBearer sends a bearer token.
+	AuthSchemeBearer = "bearer"
+	// AuthSchemeHeader sends a custom header.
+	AuthSchemeHeader = "header"
+	// AuthSchemeSigV4 signs requests for Amazon S3 with the AWS
+	// credentials found as by the AWS SDKs, e.g., from the environment or
+	// the IAM role of the instance.
+	AuthSchemeSigV4 = "sigv4"
+)
+
+// maxMindHost is the host of the MaxMind servers, which always use the
+// account ID and license key.
+const maxMindHost = "updates.maxmind.com"
+
+// HostAuth is how requests to a host are authenticated.
+type HostAuth struct {
+	// Scheme is one of the AuthScheme constants.
+	Scheme string
+	// Name is the name of the header of the header scheme.
+	Name string
+	// Value is the token of the bearer scheme, or the header value of the
+	// header scheme.
+	Value string
+	// Region is the AWS region of the sigv4 scheme.
+	Region string
+}
+
+// parseHostAuth parses the value of the HostAuth setting, a space-separated
+// list of host=scheme[:parameters] entries.
+func parseHostAuth(value string) (map[string]HostAuth, error) {
+	hostAuth := map[string]HostAuth{}
+	for _, entry := range strings.Fields(value) {
+		host, spec, ok := strings.Cut(entry, "=")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid `HostAuth' entry '%s': expected host=scheme", entry)
+		}
+		if strings.EqualFold(host, maxMindHost) {
+			return nil, fmt.Errorf("`HostAuth' can't be set for %s", maxMindHost)
+		}
+
+		scheme, params, _ := strings.Cut(spec, ":")
+		var auth HostAuth
+		switch scheme {
+		case AuthSchemeBasic:
+			auth = HostAuth{Scheme: scheme}
+		case AuthSchemeBearer:
+			if params == "" {
+				return nil, fmt.Errorf("the `HostAuth' bearer scheme of %s requires a token", host)
+			}
+			auth = HostAuth{Scheme: scheme, Value: params}
+		case AuthSchemeHeader:
+			name, value, ok := strings.Cut(params, ":")
+			if !ok || name == "" {
+				return nil, fmt.Errorf(
+					"the `HostAuth' header scheme of %s requires a header name and value",
+					host,
+				)
+			}
+			auth = HostAuth{Scheme: scheme, Name: name, Value: value}
+		case AuthSchemeSigV4:
+			if params == "" {
+				return nil, fmt.Errorf("the `HostAuth' sigv4 scheme of %s requires a region", host)
+			}
+			auth = HostAuth{Scheme: scheme, Region: params}
+		default:
+			return nil, fmt.Errorf("unknown `HostAuth' scheme '%s' for %s", scheme, host)
+		}
+		hostAuth[host] = auth
+	}
+	return hostAuth, nil
+}
+
+// authenticator returns the client.Authenticator implementing a.
+func (a HostAuth) authenticator(config *Config, httpClient *http.Client) (client.Authenticator, error) {
+	switch a.Scheme {
+	case AuthSchemeBearer:
+		return client.BearerAuth(a.Value), nil
+	case AuthSchemeHeader:
+		return client.HeaderAuth(a.Name, a.Value), nil
+	case AuthSchemeSigV4:
+		return sigV4Auth(a.Region, httpClient)
+	default:
+		return client.BasicAuth(strconv.Itoa(config.AccountID), config.LicenseKey), nil
+	}
+}
+
+// hostAuthOptions returns the client options authenticating requests as
+// configured with HostAuth.
+func hostAuthOptions(config *Config, httpClient *http.Client) ([]client.Option, error) {
+	var options []client.Option
+	for host, auth := range config.HostAuth {
+		a, err := auth.authenticator(config, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("authenticating to %s: %w", host, err)
+		}
+		options = append(options, client.WithHostAuthenticator(host, a))
+	}
+	return options, nil
+}
+
+// sigV4Auth returns an authenticator signing requests for Amazon S3 in
+// region with the AWS credentials of the environment, as found by
+// awscreds.New.
+func sigV4Auth(region string, httpClient *http.Client) (client.Authenticator, error) {
+	creds, err := awscreds.New(httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &sigv4.Signer{Region: region, Service: "s3", Provider: creds}, nil
+}
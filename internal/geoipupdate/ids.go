@@ -0,0 +1,61 @@
+package geoipupdate
+
+import (
+	"context"
+	"crypto/rand"
+	//nolint:gosec // SHA-1 is what name-based UUIDs use, not for security.
+	"crypto/sha1"
+	"fmt"
+)
+
+// updateNamespace is the namespace of the name-based UUIDs of updates.
+var updateNamespace = [16]byte{
+	0x6b, 0x1d, 0x2e, 0x4f, 0x8a, 0x53, 0x4c, 0x0e,
+	0x9d, 0x77, 0x31, 0xc2, 0x5e, 0x90, 0xa4, 0x18,
+}
+
+// runIDKey is the context key of the ID of a run.
+type runIDKey struct{}
+
+// withRunID returns ctx with the ID of the run it is for.
+func withRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// runIDFrom returns the run ID set with withRunID, or a new one.
+func runIDFrom(ctx context.Context) string {
+	if runID, ok := ctx.Value(runIDKey{}).(string); ok {
+		return runID
+	}
+	return newRunID()
+}
+
+// newRunID returns a random (version 4) UUID identifying a run.
+func newRunID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %s", err))
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return formatUUID(id)
+}
+
+// updateID returns the name-based (version 5) UUID identifying the update of
+// editionID to the database with hash. It is the same for every run and
+// every host, so receivers of announcements can deduplicate them.
+func updateID(editionID, hash string) string {
+	//nolint:gosec // see the import.
+	h := sha1.New()
+	h.Write(updateNamespace[:])
+	h.Write([]byte(editionID + ":" + hash))
+	var id [16]byte
+	copy(id[:], h.Sum(nil))
+	id[6] = id[6]&0x0f | 0x50
+	id[8] = id[8]&0x3f | 0x80
+	return formatUUID(id)
+}
+
+func formatUUID(id [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
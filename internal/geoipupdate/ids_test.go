@@ -0,0 +1,21 @@
+package geoipupdate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDs(t *testing.T) {
+	runID := newRunID()
+	require.Regexp(t, `\A[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\z`, runID)
+	require.NotEqual(t, runID, newRunID())
+	require.Equal(t, runID, runIDFrom(withRunID(context.Background(), runID)))
+
+	id := updateID("GeoLite2-City", "B")
+	require.Regexp(t, `\A[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\z`, id)
+	require.Equal(t, id, updateID("GeoLite2-City", "B"))
+	require.NotEqual(t, id, updateID("GeoLite2-City", "C"))
+	require.NotEqual(t, id, updateID("GeoLite2-Country", "B"))
+}
@@ -0,0 +1,97 @@
+package geoipupdate
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// writeIntegrityFile writes a manifest of the installed databases of
+// editionIDs to path in the mtree(5) format, i.e., the path relative to
+// databaseDirectory, size, modification time and SHA-256 sum of each
+// database, so that file integrity monitoring can tell legitimate updates
+// from tampering. The manifest replaces the previous one atomically.
+func writeIntegrityFile(path, databaseDirectory string, editionIDs []string) error {
+	var names []string
+	for _, editionID := range editionIDs {
+		names = append(names, filepath.Base(database.FilePath(databaseDirectory, editionID)))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	tempPath := path + ".temporary"
+	//nolint:gosec // the manifest isn't sensitive.
+	f, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "#mtree\n# %s\n/set type=file\n", databaseDirectory)
+	for _, name := range names {
+		if err := writeIntegrityEntry(w, filepath.Join(databaseDirectory, name), name); err != nil {
+			_ = f.Close() //nolint:errcheck // we are already returning an error.
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("writing integrity file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("moving %s into place: %w", path, err)
+	}
+	return nil
+}
+
+// writeIntegrityEntry writes the mtree entry of the database at path to w
+// as name.
+func writeIntegrityEntry(w io.Writer, path, name string) error {
+	//nolint:gosec // we really need to read this file.
+	db, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	info, err := db.Stat()
+	if err != nil {
+		return fmt.Errorf("reading database: %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, db); err != nil {
+		return fmt.Errorf("hashing %s: %w", name, err)
+	}
+	_, err = fmt.Fprintf(
+		w,
+		"./%s size=%d time=%d.%09d sha256digest=%s\n",
+		name,
+		info.Size(),
+		info.ModTime().Unix(),
+		info.ModTime().Nanosecond(),
+		hex.EncodeToString(h.Sum(nil)),
+	)
+	if err != nil {
+		return fmt.Errorf("writing integrity entry of %s: %w", name, err)
+	}
+	return nil
+}
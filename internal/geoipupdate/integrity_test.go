@@ -0,0 +1,48 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteIntegrityFile(t *testing.T) {
+	tempDir := t.TempDir()
+	dbDir := filepath.Join(tempDir, "databases")
+	require.NoError(t, os.Mkdir(dbDir, 0o750))
+
+	modifiedAt := time.Date(2024, 2, 27, 10, 0, 0, 500, time.UTC)
+	for name, content := range map[string]string{
+		"GeoLite2-City.mmdb": "city",
+		"GeoLite2-ASN.mmdb":  "asn",
+	} {
+		path := filepath.Join(dbDir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(path, modifiedAt, modifiedAt))
+	}
+
+	path := filepath.Join(tempDir, "fim", "databases.mtree")
+	require.NoError(t, writeIntegrityFile(path, dbDir, []string{"GeoLite2-City", "GeoLite2-ASN"}))
+	manifest, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	mtime := fmt.Sprintf("%d.000000500", modifiedAt.Unix())
+	require.Equal(
+		t,
+		"#mtree\n# "+dbDir+"\n/set type=file\n"+
+			"./GeoLite2-ASN.mmdb size=3 time="+mtime+
+			" sha256digest=103a0c3e86e358b8fba38e65a3b012e8ba71cc06b70a50522845d39b6a2eb426\n"+
+			"./GeoLite2-City.mmdb size=4 time="+mtime+
+			" sha256digest=11a62c23412b77477a71481aa2dc7323bcc61d076c8449076c4c58a8356c1bb1\n",
+		string(manifest),
+	)
+
+	_, err = os.Stat(path + ".temporary")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.Error(t, writeIntegrityFile(path, dbDir, []string{"GeoLite2-Country"}))
+}
@@ -0,0 +1,95 @@
+package geoipupdate
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// layerTime is the modification time of the entries of a layer, fixed so
+// that the same databases always result in the same layer.
+var layerTime = time.Unix(0, 0).UTC()
+
+// writeLayer writes a tar archive of the installed databases of editionIDs
+// to path, e.g., for container builds. The entries are sorted and their
+// metadata fixed so that the same databases always result in the same
+// archive.
+func writeLayer(path, databaseDirectory string, editionIDs []string) error {
+	var names []string
+	for _, editionID := range editionIDs {
+		names = append(names, filepath.Base(database.FilePath(databaseDirectory, editionID)))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	tempPath := path + ".temporary"
+	//nolint:gosec // the databases aren't sensitive.
+	f, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	tw := tar.NewWriter(f)
+	for _, name := range names {
+		if err := addLayerEntry(tw, filepath.Join(databaseDirectory, name), name); err != nil {
+			_ = f.Close() //nolint:errcheck // we are already returning an error.
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("writing layer: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("moving %s into place: %w", path, err)
+	}
+	return nil
+}
+
+// addLayerEntry adds the database at path to tw as name.
+func addLayerEntry(tw *tar.Writer, path, name string) error {
+	//nolint:gosec // we really need to read this file.
+	db, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	info, err := db.Stat()
+	if err != nil {
+		return fmt.Errorf("reading database: %w", err)
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		ModTime:  layerTime,
+		Format:   tar.FormatUSTAR,
+	})
+	if err != nil {
+		return fmt.Errorf("writing layer entry of %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, db); err != nil {
+		return fmt.Errorf("writing layer entry of %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package geoipupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLayer(t *testing.T) {
+	tempDir := t.TempDir()
+	dbDir := filepath.Join(tempDir, "databases")
+	require.NoError(t, os.Mkdir(dbDir, 0o750))
+
+	databases := map[string]string{
+		"GeoLite2-City.mmdb": "city",
+		"GeoLite2-ASN.mmdb":  "asn",
+	}
+	for name, content := range databases {
+		require.NoError(t, os.WriteFile(filepath.Join(dbDir, name), []byte(content), 0o600))
+	}
+	editionIDs := []string{"GeoLite2-City", "GeoLite2-ASN"}
+
+	path := filepath.Join(tempDir, "layer", "databases.tar")
+	require.NoError(t, writeLayer(path, dbDir, editionIDs))
+	layer, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	tr := tar.NewReader(bytes.NewReader(layer))
+	var names []string
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		require.Equal(t, time.Unix(0, 0), header.ModTime)
+		require.Equal(t, int64(0o644), header.Mode)
+		require.Zero(t, header.Uid)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.Equal(t, databases[header.Name], string(content))
+	}
+	require.Equal(t, []string{"GeoLite2-ASN.mmdb", "GeoLite2-City.mmdb"}, names)
+
+	// The same databases result in the same layer.
+	now := time.Now()
+	for name := range databases {
+		require.NoError(t, os.Chtimes(filepath.Join(dbDir, name), now, now))
+	}
+	require.NoError(t, writeLayer(path, dbDir, []string{"GeoLite2-ASN", "GeoLite2-City"}))
+	rewritten, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, layer, rewritten)
+
+	require.Error(t, writeLayer(path, dbDir, []string{"GeoLite2-Country"}))
+}
@@ -0,0 +1,61 @@
+package geoipupdate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// runFence fences the writes of the databases with the lock of the current
+// run, if it is an internal.FencedLock, so that a run that lost its lease,
+// e.g., because its Lambda function was frozen, doesn't overwrite the
+// databases written by the run that took it over.
+type runFence struct {
+	mu   sync.Mutex
+	lock internal.FencedLock
+}
+
+// set sets the lock of the current run, or nil once it is released.
+func (f *runFence) set(lock internal.FencedLock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lock = lock
+}
+
+// check returns an error if the run lost its lock.
+func (f *runFence) check() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lock == nil {
+		return nil
+	}
+	return f.lock.Fence()
+}
+
+// token returns the fencing token of the current run, or 0.
+func (f *runFence) token() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lock == nil {
+		return 0
+	}
+	return f.lock.Token()
+}
+
+// logConflict logs that another writer installed a build of editionID at
+// least as recent as the one this run downloaded.
+func (u *Updater) logConflict(editionID string, conflict *database.ConflictError) {
+	var token uint64
+	if u.fence != nil {
+		token = u.fence.token()
+	}
+	u.logf(
+		"Conflict: another writer installed %s built at %s while this run, with token %d, wrote the build of %s; keeping it",
+		editionID,
+		conflict.Installed.Format(time.RFC3339),
+		token,
+		conflict.New.Format(time.RFC3339),
+	)
+}
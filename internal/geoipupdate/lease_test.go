@@ -0,0 +1,58 @@
+package geoipupdate
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// TestUpdaterLeaseConflict tests that the writes of runs with a lease lock
+// are fenced, and that a build installed by another writer is kept.
+func TestUpdaterLeaseConflict(t *testing.T) {
+	tempDir := t.TempDir()
+	installed := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	fence := &runFence{}
+	writer := &mockWriter{md5s: map[string]string{"GeoLite2-City": "A"}}
+	writer.writeFunc = func(editionID string, _ io.ReadCloser, _ string, built time.Time) error {
+		require.NoError(t, fence.check())
+		assert.Equal(t, uint64(1), fence.token())
+		// Another writer installed a more recent build in the meantime.
+		writer.md5s[editionID] = "C"
+		return &database.ConflictError{Installed: installed, New: built}
+	}
+
+	u := &Updater{
+		config: &Config{
+			EditionIDs:  []string{"GeoLite2-City"},
+			LockFile:    filepath.Join(tempDir, ".geoipupdate.lock"),
+			LockType:    internal.LockTypeLease,
+			Parallelism: 1,
+		},
+		fence: fence,
+		updateClient: &mockUpdateClient{outputs: []client.DownloadResponse{{
+			MD5:             "B",
+			Reader:          io.NopCloser(strings.NewReader("")),
+			UpdateAvailable: true,
+		}}},
+		writer: writer,
+	}
+	editions, err := u.RunEditions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, editions, 1)
+	assert.Equal(t, "A", editions[0].OldHash)
+	assert.Equal(t, "C", editions[0].NewHash)
+	assert.Equal(t, installed, editions[0].ModifiedAt)
+
+	// The fence is only set during runs.
+	assert.Zero(t, fence.token())
+}
@@ -0,0 +1,85 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// legacyProductIDs are the editions replacing the databases of GeoIP
+// Legacy (v1) product IDs, which geoipupdate 2.x configurations still list.
+var legacyProductIDs = map[string]string{
+	"106": "GeoIP2-Country",
+	"115": "GeoIP2-City",
+	"121": "GeoIP2-ISP",
+	"133": "GeoIP2-City",
+	"506": "GeoLite2-Country",
+	"517": "GeoLite2-ASN",
+	"533": "GeoLite2-City",
+}
+
+// legacyEditionID is a GeoIP Legacy product ID of EditionIDs, and the
+// edition replacing it, which is empty if there is none.
+type legacyEditionID struct {
+	productID string
+	editionID string
+}
+
+// legacyProductID returns the product ID of id if it is a GeoIP Legacy
+// product ID, e.g., 106 or GeoIP-106.
+func legacyProductID(id string) (string, bool) {
+	productID := strings.TrimPrefix(id, "GeoIP-")
+	if _, err := strconv.Atoi(productID); err != nil {
+		return "", false
+	}
+	return productID, true
+}
+
+// aliasLegacyEditionIDs replaces the GeoIP Legacy product IDs of the
+// EditionIDs of config by the editions replacing them, and drops those
+// without any. They are recorded so that they are warned about, or are an
+// error with strictConfig.
+func aliasLegacyEditionIDs(config *Config) error {
+	var editionIDs []string
+	for _, id := range config.EditionIDs {
+		productID, ok := legacyProductID(id)
+		if !ok {
+			if !slices.Contains(editionIDs, id) {
+				editionIDs = append(editionIDs, id)
+			}
+			continue
+		}
+		editionID := legacyProductIDs[productID]
+		if config.strictConfig {
+			if editionID == "" {
+				return fmt.Errorf("GeoIP Legacy product ID `%s' has no equivalent edition", id)
+			}
+			return fmt.Errorf("GeoIP Legacy product ID `%s' is deprecated; use %s instead", id, editionID)
+		}
+		config.legacyEditionIDs = append(config.legacyEditionIDs, legacyEditionID{
+			productID: id,
+			editionID: editionID,
+		})
+		if editionID != "" && !slices.Contains(editionIDs, editionID) {
+			editionIDs = append(editionIDs, editionID)
+		}
+	}
+	config.EditionIDs = editionIDs
+	return nil
+}
+
+// warning returns the message of the WarningDeprecatedOption about l.
+func (l legacyEditionID) warning() string {
+	if l.editionID == "" {
+		return fmt.Sprintf(
+			"GeoIP Legacy product ID `%s' has no equivalent edition and is ignored; remove it from the configuration",
+			l.productID,
+		)
+	}
+	return fmt.Sprintf(
+		"GeoIP Legacy product ID `%s' is deprecated and replaced by %s; use the edition ID in the configuration",
+		l.productID,
+		l.editionID,
+	)
+}
@@ -0,0 +1,67 @@
+package geoipupdate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasLegacyEditionIDs(t *testing.T) {
+	tests := []struct {
+		Description string
+		EditionIDs  []string
+		Strict      bool
+		Expected    []string
+		Warnings    []Warning
+		Err         string
+	}{
+		{
+			Description: "No legacy product IDs",
+			EditionIDs:  []string{"GeoIP2-City", "GeoLite2-ASN"},
+			Expected:    []string{"GeoIP2-City", "GeoLite2-ASN"},
+		},
+		{
+			Description: "Legacy product IDs",
+			EditionIDs:  []string{"106", "GeoIP-133", "GeoIP2-City", "999"},
+			Expected:    []string{"GeoIP2-Country", "GeoIP2-City"},
+			Warnings: []Warning{
+				{
+					Code: WarningDeprecatedOption,
+					Message: "GeoIP Legacy product ID `106' is deprecated and replaced by GeoIP2-Country; " +
+						"use the edition ID in the configuration",
+				},
+				{
+					Code: WarningDeprecatedOption,
+					Message: "GeoIP Legacy product ID `GeoIP-133' is deprecated and replaced by GeoIP2-City; " +
+						"use the edition ID in the configuration",
+				},
+				{
+					Code: WarningDeprecatedOption,
+					Message: "GeoIP Legacy product ID `999' has no equivalent edition and is ignored; " +
+						"remove it from the configuration",
+				},
+			},
+		},
+		{
+			Description: "Strict configuration",
+			EditionIDs:  []string{"GeoIP2-City", "533"},
+			Strict:      true,
+			Err:         "GeoIP Legacy product ID `533' is deprecated; use GeoLite2-City instead",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			config := &Config{EditionIDs: test.EditionIDs, strictConfig: test.Strict}
+			err := aliasLegacyEditionIDs(config)
+			if test.Err != "" {
+				require.EqualError(t, err, test.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, config.EditionIDs)
+			assert.Equal(t, test.Warnings, deprecationWarnings(config))
+		})
+	}
+}
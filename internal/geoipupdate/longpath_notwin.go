@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package geoipupdate
+
+// longPath returns path unchanged, as only Windows limits the length of
+// paths this way.
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,35 @@
+package geoipupdate
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the maximum length of paths in the Windows API, unless they
+// are in the extended-length form, e.g., \\?\C:\GeoIP or
+// \\?\UNC\server\share\GeoIP.
+const maxPath = 260
+
+// maxFileNameLength is about the length of the longest names of the files
+// geoipupdate creates in the directories it is configured with, e.g.,
+// GeoIP2-Enterprise.mmdb.temporary.
+const maxFileNameLength = 64
+
+// longPath returns path in the extended-length form if the files created in
+// it could exceed MAX_PATH. The os package only does this for long absolute
+// paths to drive letters, not for UNC paths, e.g., \\server\share\GeoIP.
+func longPath(path string) string {
+	if path == "" || strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs)+maxFileNameLength < maxPath {
+		return path
+	}
+
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
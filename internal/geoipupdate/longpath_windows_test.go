@@ -0,0 +1,100 @@
+package geoipupdate
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestLongPath(t *testing.T) {
+	long := strings.Repeat(`directory\`, 25) + "GeoIP"
+
+	tests := []struct {
+		description string
+		path        string
+		expected    string
+	}{
+		{
+			description: "short path",
+			path:        `C:\ProgramData\MaxMind\GeoIPUpdate\GeoIP`,
+			expected:    `C:\ProgramData\MaxMind\GeoIPUpdate\GeoIP`,
+		},
+		{
+			description: "short UNC path",
+			path:        `\\server\share\GeoIP`,
+			expected:    `\\server\share\GeoIP`,
+		},
+		{
+			description: "long path",
+			path:        `C:\` + long,
+			expected:    `\\?\C:\` + long,
+		},
+		{
+			description: "long UNC path",
+			path:        `\\server\share\` + long,
+			expected:    `\\?\UNC\server\share\` + long,
+		},
+		{
+			description: "long path with forward slashes",
+			path:        `C:/` + strings.ReplaceAll(long, `\`, "/"),
+			expected:    `\\?\C:\` + long,
+		},
+		{
+			description: "extended-length path",
+			path:        `\\?\C:\` + long,
+			expected:    `\\?\C:\` + long,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			require.Equal(t, test.expected, longPath(test.path))
+		})
+	}
+}
+
+// TestLongDatabaseDirectory tests that databases are locked and written in
+// directories whose paths exceed MAX_PATH.
+func TestLongDatabaseDirectory(t *testing.T) {
+	dir := longPath(filepath.Join(t.TempDir(), strings.Repeat(`directory\`, 25)+"GeoIP"))
+	require.True(t, strings.HasPrefix(dir, `\\?\`))
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+
+	lock, err := internal.NewFileLock(filepath.Join(dir, ".geoipupdate.lock"), false)
+	require.NoError(t, err)
+	require.NoError(t, lock.Acquire())
+	defer func() {
+		require.NoError(t, lock.Release())
+	}()
+
+	writer, err := database.NewLocalFileWriter(dir, false, false)
+	require.NoError(t, err)
+
+	// The installed database is replaced by the new one.
+	for _, db := range []struct{ content, md5 string }{
+		{"database content", "cfa36ddc8279b5483a5aa25e9a6151f4"},
+		{"new database content", "f8e36749e12c5ab2d2441f7fb1a80c4f"},
+	} {
+		err = writer.Write(
+			context.Background(),
+			"GeoIP2-City",
+			io.NopCloser(strings.NewReader(db.content)),
+			db.md5,
+			time.Time{},
+		)
+		require.NoError(t, err)
+	}
+
+	hash, err := writer.GetHash(context.Background(), "GeoIP2-City")
+	require.NoError(t, err)
+	require.Equal(t, "f8e36749e12c5ab2d2441f7fb1a80c4f", hash)
+}
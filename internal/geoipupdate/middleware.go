@@ -0,0 +1,80 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// The middlewares that can be stacked with the Middleware setting.
+const (
+	// middlewareVerifyMD5 checks the MD5 sum of the databases downloaded.
+	middlewareVerifyMD5 = "verify-md5"
+	// middlewareThrottle, followed by a size, e.g., throttle:1M, limits the
+	// bytes per second each database is downloaded at.
+	middlewareThrottle = "throttle"
+)
+
+// middleware is a middleware of the Middleware setting. It wraps either
+// the downloads or the writer.
+type middleware struct {
+	download client.Middleware
+	writer   database.WriterMiddleware
+}
+
+// parseMiddleware parses the value of the setting name, a space-separated
+// list of middlewares, e.g., `verify-md5 throttle:1M`.
+func parseMiddleware(name, value string) ([]string, error) {
+	entries := strings.Fields(value)
+	for _, entry := range entries {
+		if _, err := newMiddleware(entry); err != nil {
+			return nil, fmt.Errorf("`%s' %w", name, err)
+		}
+	}
+	return entries, nil
+}
+
+// newMiddleware returns the middleware of entry, an entry of the
+// Middleware setting.
+func newMiddleware(entry string) (middleware, error) {
+	name, arg, hasArg := strings.Cut(entry, ":")
+	switch name {
+	case middlewareVerifyMD5:
+		if !hasArg {
+			return middleware{download: client.VerifyMD5()}, nil
+		}
+	case middlewareThrottle:
+		rate, err := parseSize(arg)
+		if err == nil && rate > 0 {
+			return middleware{download: client.Throttle(rate)}, nil
+		}
+		return middleware{}, fmt.Errorf("must throttle to a positive size per second, got '%s'", entry)
+	}
+	return middleware{}, fmt.Errorf("must be a list of %s and %s:<size> middlewares, got '%s'",
+		middlewareVerifyMD5, middlewareThrottle, entry)
+}
+
+// middlewares returns the download and writer middlewares of config: those
+// of its Middleware setting followed by those set with
+// WithDownloadMiddleware and WithWriterMiddleware.
+func middlewares(config *Config) ([]client.Middleware, []database.WriterMiddleware, error) {
+	var download []client.Middleware
+	var writer []database.WriterMiddleware
+	for _, entry := range config.Middleware {
+		m, err := newMiddleware(entry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("`Middleware' %w", err)
+		}
+		if m.download != nil {
+			download = append(download, m.download)
+		}
+		if m.writer != nil {
+			writer = append(writer, m.writer)
+		}
+	}
+	download = append(download, config.downloadMiddleware...)
+	writer = append(writer, config.writerMiddleware...)
+	return download, writer, nil
+}
@@ -0,0 +1,67 @@
+package geoipupdate
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// TestUpdaterMiddleware tests that the downloads and the databases written
+// go through the middlewares of the Middleware setting, and then those set
+// with options.
+func TestUpdaterMiddleware(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var calls []string
+	config := &Config{
+		EditionIDs:  []string{"GeoLite2-City"},
+		LockFile:    filepath.Join(tempDir, ".geoipupdate.lock"),
+		Middleware:  []string{"verify-md5"},
+		Parallelism: 1,
+	}
+	require.NoError(t, WithDownloadMiddleware(func(next client.Downloader) client.Downloader {
+		return client.DownloaderFunc(func(ctx context.Context, editionID, md5 string) (client.DownloadResponse, error) {
+			calls = append(calls, "download "+editionID)
+			return next.Download(ctx, editionID, md5)
+		})
+	})(config))
+	require.NoError(t, WithWriterMiddleware(database.ObserveWrites(func(s database.WriteStats) {
+		calls = append(calls, "write "+s.EditionID)
+	}))(config))
+
+	downloadMiddleware, writerMiddleware, err := middlewares(config)
+	require.NoError(t, err)
+	require.Len(t, downloadMiddleware, 2)
+	require.Len(t, writerMiddleware, 1)
+
+	updateClient := &mockUpdateClient{outputs: []client.DownloadResponse{{
+		// The MD5 sum of "database" is 11e0eed8d3696c0a632f822df385ab3c.
+		MD5:             "00000000000000000000000000000001",
+		Reader:          io.NopCloser(strings.NewReader("database")),
+		UpdateAvailable: true,
+	}}}
+	u := &Updater{
+		config:       config,
+		downloader:   client.Chain(updateClient, downloadMiddleware...),
+		updateClient: updateClient,
+		writer: database.ChainWriter(&mockWriter{
+			md5s: map[string]string{"GeoLite2-City": "A"},
+			writeFunc: func(_ string, reader io.ReadCloser, _ string, _ time.Time) error {
+				_, err := io.Copy(io.Discard, reader)
+				return err
+			},
+		}, writerMiddleware...),
+	}
+	err = u.Run(context.Background())
+	require.ErrorContains(t, err, "the database of GeoLite2-City has the MD5 sum 11e0eed8d3696c0a632f822df385ab3c")
+	assert.Equal(t, []string{"download GeoLite2-City", "write GeoLite2-City"}, calls)
+}
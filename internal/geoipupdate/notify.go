@@ -0,0 +1,95 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+)
+
+// parseNotify parses the value of the setting name, a space-separated list
+// of notification targets.
+func parseNotify(name, value string) ([]string, error) {
+	targets := strings.Fields(value)
+	for _, target := range targets {
+		if err := notify.Validate(target); err != nil {
+			return nil, fmt.Errorf("`%s' must be a list of notification targets: %w", name, err)
+		}
+	}
+	return targets, nil
+}
+
+// announce announces the editions that were updated to the Notify targets,
+// along with those whose announcement failed in a previous run. An update
+// is announced until it has reached every target, and never again once it
+// has. A failing target doesn't prevent announcing to the others.
+func (u *Updater) announce(
+	ctx context.Context,
+	store *state.Store,
+	runID string,
+	editions []database.ReadResult,
+) error {
+	if len(u.notifiers) == 0 {
+		return nil
+	}
+
+	var errs error
+	for _, edition := range editions {
+		s := store.Edition(edition.EditionID)
+		if !s.AnnouncePending {
+			continue
+		}
+		if s.AnnouncedHash == edition.NewHash {
+			if err := u.announced(store, edition); err != nil {
+				errs = errors.Join(errs, err)
+			}
+			continue
+		}
+
+		a := notify.Announcement{
+			RunID:     runID,
+			UpdateID:  updateID(edition.EditionID, edition.NewHash),
+			EditionID: edition.EditionID,
+			MD5:       edition.NewHash,
+			Date:      s.BuildDate,
+			Labels:    u.config.Labels,
+		}
+		if u.config.OCIPush != "" {
+			a.URL = u.config.OCIPush + ":" + edition.EditionID
+		}
+		failed := false
+		for i, notifier := range u.notifiers {
+			if err := notifier.Notify(ctx, a); err != nil {
+				failed = true
+				errs = errors.Join(errs, fmt.Errorf(
+					"announcing %s to %s: %w",
+					edition.EditionID,
+					notify.Redact(u.config.Notify[i]),
+					err,
+				))
+			}
+		}
+		if !failed {
+			if err := u.announced(store, edition); err != nil {
+				errs = errors.Join(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// announced records that the update of edition was announced.
+func (u *Updater) announced(store *state.Store, edition database.ReadResult) error {
+	err := store.Update(edition.EditionID, func(e *state.Edition) {
+		e.AnnouncePending = false
+		e.AnnouncedHash = edition.NewHash
+	})
+	if err != nil {
+		return fmt.Errorf("updating state of %s: %w", edition.EditionID, err)
+	}
+	return nil
+}
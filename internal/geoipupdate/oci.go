@@ -0,0 +1,26 @@
+package geoipupdate
+
+import (
+	"fmt"
+
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/oci"
+)
+
+// validateOCIRepository checks that value, the value of the setting name,
+// is a valid OCI repository reference.
+func validateOCIRepository(name, value string) error {
+	if _, err := oci.New(value); err != nil {
+		return fmt.Errorf("`%s' must be a registry/repository reference, got '%s'", name, value)
+	}
+	return nil
+}
+
+// validateEncoding checks that value, the value of the setting name, is a
+// supported encoding.
+func validateEncoding(name, value string) error {
+	if _, err := encoding.Lookup(value); err != nil {
+		return fmt.Errorf("`%s' must be one of %v: %w", name, encoding.Names(), err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package geoipupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// outputWriter writes the records of the JSON output enabled by Output, one
+// per line. It is safe for concurrent use: each record is written whole,
+// with a single Write, so that the records of editions completing at the
+// same time are never interleaved.
+type outputWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newOutputWriter(w io.Writer) *outputWriter {
+	return &outputWriter{w: w}
+}
+
+// emit writes v as a JSON record.
+func (o *outputWriter) emit(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshaling result log: %w", err)
+	}
+	data = append(data, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.w.Write(data); err != nil {
+		return fmt.Errorf("writing result log: %w", err)
+	}
+	return nil
+}
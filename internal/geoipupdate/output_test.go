@@ -0,0 +1,118 @@
+package geoipupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// slowBuffer is a buffer whose writes yield between bytes, so that
+// concurrent writes would be interleaved if they weren't serialized.
+type slowBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *slowBuffer) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.mu.Lock()
+		b.buf.WriteByte(c)
+		b.mu.Unlock()
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+func TestOutputWriterConcurrent(t *testing.T) {
+	out := &slowBuffer{}
+	w := newOutputWriter(out)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, w.emit(map[string]string{
+				"edition_id": fmt.Sprintf("Edition-%d", i),
+				"padding":    strings.Repeat("x", i*10),
+			}))
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(&out.buf)
+	for scanner.Scan() {
+		var record map[string]string
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), scanner.Text())
+		seen[record["edition_id"]] = true
+	}
+	require.NoError(t, scanner.Err())
+	assert.Len(t, seen, 100)
+}
+
+// newBuildClient returns the new build of every edition.
+type newBuildClient struct{}
+
+func (newBuildClient) Download(_ context.Context, editionID, _ string) (client.DownloadResponse, error) {
+	return client.DownloadResponse{
+		MD5:             editionID + "-new",
+		Reader:          io.NopCloser(strings.NewReader("")),
+		UpdateAvailable: true,
+	}, nil
+}
+
+// TestUpdaterOutputStream tests that the stream format outputs each
+// edition on its own line, with a high parallelism, and then the summary of
+// the run.
+func TestUpdaterOutputStream(t *testing.T) {
+	var editionIDs []string
+	for i := 0; i < 200; i++ {
+		editionIDs = append(editionIDs, fmt.Sprintf("Edition-%d", i))
+	}
+	config := &Config{
+		EditionIDs:   editionIDs,
+		LockFile:     filepath.Join(t.TempDir(), ".geoipupdate.lock"),
+		Output:       true,
+		OutputFormat: OutputFormatStream,
+		Parallelism:  64,
+	}
+	out := &slowBuffer{}
+	u := &Updater{
+		config:       config,
+		output:       newOutputWriter(out),
+		updateClient: newBuildClient{},
+		writer:       &mockWriter{},
+	}
+	require.NoError(t, u.Run(context.Background()))
+
+	lines := strings.Split(strings.TrimSuffix(out.buf.String(), "\n"), "\n")
+	require.Len(t, lines, len(editionIDs)+1)
+	seen := map[string]bool{}
+	for _, line := range lines[:len(editionIDs)] {
+		var edition database.ReadResult
+		require.NoError(t, json.Unmarshal([]byte(line), &edition), line)
+		assert.Equal(t, edition.EditionID+"-new", edition.NewHash)
+		seen[edition.EditionID] = true
+	}
+	assert.Len(t, seen, len(editionIDs))
+
+	var last summaryRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[len(editionIDs)]), &last))
+	assert.Equal(t, len(editionIDs), last.Summary.Checked)
+	assert.Equal(t, len(editionIDs), last.Summary.Updated)
+}
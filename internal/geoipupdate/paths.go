@@ -0,0 +1,31 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// normalizePath returns path with a leading ~ expanded to the home
+// directory of the user, and cleaned, e.g., without trailing separators. On
+// Windows, its separators are also made backslashes and its drive letter
+// uppercase, e.g., c:/GeoIP/ becomes C:\GeoIP, so that the same path is
+// always written the same way, e.g., in the lock and state files.
+func normalizePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") ||
+		(filepath.Separator == '\\' && strings.HasPrefix(path, `~\`)) {
+		// The path is kept as is if the home directory isn't known, and
+		// fails later with an error naming it.
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	path = filepath.Clean(path)
+	if volume := filepath.VolumeName(path); len(volume) == 2 && volume[1] == ':' {
+		path = strings.ToUpper(volume) + path[2:]
+	}
+	return path
+}
@@ -0,0 +1,98 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := []struct {
+		description string
+		path        string
+		expected    string
+		goos        string
+	}{
+		{
+			description: "empty",
+			path:        "",
+			expected:    "",
+		},
+		{
+			description: "home directory",
+			path:        "~",
+			expected:    home,
+		},
+		{
+			description: "in the home directory",
+			path:        "~/GeoIP/",
+			expected:    filepath.Join(home, "GeoIP"),
+		},
+		{
+			description: "other user",
+			path:        "~geoip/GeoIP",
+			expected:    filepath.Clean("~geoip/GeoIP"),
+		},
+		{
+			description: "trailing separators",
+			path:        "/var/lib/GeoIP//",
+			expected:    filepath.Clean("/var/lib/GeoIP"),
+		},
+		{
+			description: "drive letter",
+			path:        `c:/Program Files/GeoIP/`,
+			expected:    `C:\Program Files\GeoIP`,
+			goos:        "windows",
+		},
+		{
+			description: "home directory with a backslash",
+			path:        `~\GeoIP`,
+			expected:    filepath.Join(home, "GeoIP"),
+			goos:        "windows",
+		},
+		{
+			description: "UNC path",
+			path:        `\\server\share\GeoIP\`,
+			expected:    `\\server\share\GeoIP`,
+			goos:        "windows",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if test.goos != "" && test.goos != runtime.GOOS {
+				t.Skipf("only on %s", test.goos)
+			}
+			assert.Equal(t, test.expected, normalizePath(test.path))
+		})
+	}
+}
+
+func TestNewConfigNormalizesPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	configFile := filepath.Join(t.TempDir(), "GeoIP.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte(
+		"\ufeffAccountID 123\r\n"+
+			"LicenseKey 456\r\n"+
+			"EditionIDs GeoLite2-City\r\n"+
+			"DatabaseDirectory ~/GeoIP/  \r\n"+
+			"LockFile ~/locks/geoipupdate.lock\r\n",
+	), 0o600))
+
+	config, err := NewConfig(WithConfigFile(configFile))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "GeoIP"), config.DatabaseDirectory)
+	assert.Equal(t, filepath.Join(home, "locks", "geoipupdate.lock"), config.LockFile)
+	assert.Equal(t, filepath.Join(home, "GeoIP", ".geoipupdate.state"), config.StateFile)
+}
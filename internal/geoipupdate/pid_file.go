@@ -0,0 +1,75 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+)
+
+// WritePIDFile writes the ID of the current process to the file at path,
+// e.g., for init scripts to supervise the daemon. It fails if the file
+// names another process that is still running. A file left by a process
+// that exited is replaced.
+func WritePIDFile(path string) error {
+	pid, err := readPIDFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Printf("Replacing the invalid PID file %s: %s", path, err)
+	case pid != os.Getpid() && internal.ProcessRunning(pid):
+		return fmt.Errorf("the process %d of the PID file %s is still running", pid, path)
+	default:
+		log.Printf("Replacing the stale PID file %s of the process %d", path, pid)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating PID file directory: %w", err)
+	}
+	// The file is written in full before being renamed, so that it is never
+	// read partially written.
+	tempPath := path + ".temporary"
+	content := strconv.Itoa(os.Getpid()) + "\n"
+	if err := os.WriteFile(tempPath, []byte(content), 0o644); err != nil { //nolint:gosec // PID files are public.
+		return fmt.Errorf("writing PID file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("moving PID file into place: %w", err)
+	}
+	return nil
+}
+
+// RemovePIDFile removes the PID file at path if it holds the ID of the
+// current process.
+func RemovePIDFile(path string) error {
+	pid, err := readPIDFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil || pid != os.Getpid() {
+		// Another process replaced it.
+		return nil //nolint:nilerr // the file isn't ours.
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing PID file: %w", err)
+	}
+	return nil
+}
+
+// readPIDFile returns the process ID in the PID file at path.
+func readPIDFile(path string) (int, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid process ID %q", bytes.TrimSpace(content))
+	}
+	return pid, nil
+}
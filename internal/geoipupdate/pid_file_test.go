@@ -0,0 +1,71 @@
+package geoipupdate
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIDFile(t *testing.T) {
+	ownPID := strconv.Itoa(os.Getpid()) + "\n"
+
+	tests := []struct {
+		Description string
+		Content     string
+		Err         string
+	}{
+		{
+			Description: "No PID file",
+		},
+		{
+			Description: "Stale PID file",
+			// The largest PID of Linux can't be in use.
+			Content: "4194305\n",
+		},
+		{
+			Description: "Invalid PID file",
+			Content:     "not a PID",
+		},
+		{
+			Description: "Own PID file",
+			Content:     ownPID,
+		},
+		{
+			Description: "PID file of a running process",
+			Content:     strconv.Itoa(os.Getppid()),
+			Err:         "is still running",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "run", "geoipupdate.pid")
+			if test.Content != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+				require.NoError(t, os.WriteFile(path, []byte(test.Content), 0o600))
+			}
+
+			err := WritePIDFile(path)
+			if test.Err != "" {
+				require.ErrorContains(t, err, test.Err)
+				require.NoError(t, RemovePIDFile(path))
+				content, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.Equal(t, test.Content, string(content), "the PID file of another process is kept")
+				return
+			}
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, ownPID, string(content))
+
+			require.NoError(t, RemovePIDFile(path))
+			require.NoFileExists(t, path)
+			require.NoError(t, RemovePIDFile(path))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package geoipupdate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// Plan lists the downloads an update would perform, to be reviewed before
+// being applied with Updater.Apply.
+type Plan struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Editions are the editions to download. Editions that are up to date
+	// are not listed.
+	Editions []PlannedEdition `json:"editions"`
+}
+
+// PlannedEdition is a download of a Plan.
+type PlannedEdition struct {
+	EditionID string `json:"edition_id"`
+	// OldHash is the MD5 sum of the installed database, or the zero hash if
+	// there is none.
+	OldHash string `json:"old_hash"`
+	// NewHash is the MD5 sum of the database to download.
+	NewHash string `json:"new_hash"`
+	// Date is the publication date of the database to download.
+	Date string `json:"date"`
+}
+
+// buildClient is implemented by update clients that can tell the latest
+// build of an edition without downloading it.
+type buildClient interface {
+	LatestBuild(context.Context, string) (client.Build, error)
+}
+
+// ReadPlan reads the plan at path.
+func ReadPlan(path string) (*Plan, error) {
+	//nolint:gosec // we really need to read this file.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("parsing plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// Plan returns the downloads an update of the configured editions would
+// perform, without performing them.
+func (u *Updater) Plan(ctx context.Context) (*Plan, error) {
+	bc, ok := u.updateClient.(buildClient)
+	if !ok {
+		return nil, errors.New("plans are not supported with `S3Mirror' or `OCIMirror'")
+	}
+
+	u.warnings = &warningList{}
+	store, err := state.Open(u.config.StateFile)
+	if err != nil {
+		// As with updates, a broken state file only loses the editions
+		// patterns match.
+		store = state.New(u.config.StateFile)
+	}
+	editionIDs, err := u.resolveEditionIDs(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := u.updateClient.(metadataBatcher); ok {
+		b.BatchMetadata(editionIDs)
+	}
+
+	plan := &Plan{
+		CreatedAt: time.Now().In(time.UTC),
+		Editions:  []PlannedEdition{},
+	}
+	for _, editionID := range editionIDs {
+		hash, err := u.writer.GetHash(ctx, editionID)
+		if err != nil {
+			return nil, err
+		}
+		build, err := bc.LatestBuild(ctx, editionID)
+		if err != nil {
+			return nil, fmt.Errorf("checking the latest build of %s: %w", editionID, err)
+		}
+		if build.MD5 == hash {
+			continue
+		}
+		plan.Editions = append(plan.Editions, PlannedEdition{
+			EditionID: editionID,
+			OldHash:   hash,
+			NewHash:   build.MD5,
+			Date:      build.Date,
+		})
+	}
+	return plan, nil
+}
+
+// Apply performs exactly the downloads of plan, like RunEditions. It fails
+// if an installed database or the latest build of an edition changed since
+// the plan was made.
+func (u *Updater) Apply(ctx context.Context, plan *Plan) ([]database.ReadResult, error) {
+	config := *u.config
+	config.EditionIDs = nil
+	config.EditionPatterns = nil
+	u.plan = map[string]PlannedEdition{}
+	for _, edition := range plan.Editions {
+		config.EditionIDs = append(config.EditionIDs, edition.EditionID)
+		u.plan[edition.EditionID] = edition
+	}
+	u.config = &config
+	return u.RunEditions(ctx)
+}
+
+// checkPlanned returns an error if the installed database of editionID, with
+// hash, differs from the one planned.
+func (u *Updater) checkPlanned(editionID, hash string) error {
+	if hash != u.plan[editionID].OldHash {
+		return fmt.Errorf(
+			"the installed database of %s changed since the plan was made",
+			editionID,
+		)
+	}
+	return nil
+}
+
+// checkPlannedDownload returns an error if res is not the download planned
+// for editionID.
+func (u *Updater) checkPlannedDownload(editionID string, res client.DownloadResponse) error {
+	if !res.UpdateAvailable || res.MD5 != u.plan[editionID].NewHash {
+		return fmt.Errorf(
+			"the latest build of %s changed since the plan was made",
+			editionID,
+		)
+	}
+	return nil
+}
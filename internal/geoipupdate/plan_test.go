@@ -0,0 +1,95 @@
+package geoipupdate
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+)
+
+// mockBuildClient serves builds, by edition ID, and their downloads.
+type mockBuildClient struct {
+	builds    map[string]client.Build
+	downloads []string
+}
+
+func (m *mockBuildClient) LatestBuild(_ context.Context, editionID string) (client.Build, error) {
+	return m.builds[editionID], nil
+}
+
+func (m *mockBuildClient) Download(
+	_ context.Context,
+	editionID,
+	hash string,
+) (client.DownloadResponse, error) {
+	build := m.builds[editionID]
+	if build.MD5 == hash {
+		return client.DownloadResponse{Reader: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	m.downloads = append(m.downloads, editionID)
+	return client.DownloadResponse{
+		MD5:             build.MD5,
+		Reader:          io.NopCloser(strings.NewReader("")),
+		UpdateAvailable: true,
+	}, nil
+}
+
+func TestPlanApply(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := &Config{
+		EditionIDs:  []string{"GeoLite2-ASN", "GeoLite2-City", "GeoLite2-Country"},
+		LockFile:    filepath.Join(tempDir, ".geoipupdate.lock"),
+		Parallelism: 1,
+	}
+	bc := &mockBuildClient{builds: map[string]client.Build{
+		"GeoLite2-ASN":     {Date: "2024-02-23", MD5: "A"},
+		"GeoLite2-City":    {Date: "2024-02-23", MD5: "C"},
+		"GeoLite2-Country": {Date: "2024-02-20", MD5: "D"},
+	}}
+	writer := &mockWriter{md5s: map[string]string{
+		"GeoLite2-ASN":     "A",
+		"GeoLite2-City":    "B",
+		"GeoLite2-Country": "",
+	}}
+	newUpdater := func() *Updater {
+		return &Updater{config: config, updateClient: bc, writer: writer}
+	}
+
+	plan, err := newUpdater().Plan(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []PlannedEdition{
+		{EditionID: "GeoLite2-City", OldHash: "B", NewHash: "C", Date: "2024-02-23"},
+		{EditionID: "GeoLite2-Country", OldHash: "", NewHash: "D", Date: "2024-02-20"},
+	}, plan.Editions)
+	require.Empty(t, bc.downloads)
+
+	editions, err := newUpdater().Apply(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, editions, 2)
+	require.Equal(t, []string{"GeoLite2-City", "GeoLite2-Country"}, bc.downloads)
+
+	// The latest build changed since the plan was made.
+	bc.downloads = nil
+	bc.builds["GeoLite2-City"] = client.Build{Date: "2024-02-27", MD5: "E"}
+	_, err = newUpdater().Apply(context.Background(), &Plan{Editions: plan.Editions[:1]})
+	require.ErrorContains(t, err, "the latest build of GeoLite2-City changed since the plan was made")
+
+	// The installed database changed since the plan was made.
+	writer.md5s["GeoLite2-City"] = "C"
+	_, err = newUpdater().Apply(context.Background(), &Plan{Editions: plan.Editions[:1]})
+	require.ErrorContains(
+		t,
+		err,
+		"the installed database of GeoLite2-City changed since the plan was made",
+	)
+
+	_, err = (&Updater{config: config, updateClient: &mockUpdateClient{}, writer: writer}).
+		Plan(context.Background())
+	require.EqualError(t, err, "plans are not supported with `S3Mirror' or `OCIMirror'")
+}
@@ -0,0 +1,79 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// postUpdate runs the PostUpdateCommand for edition if its update wasn't
+// processed by it yet, e.g., to convert the new database for a consumer
+// that only reads legacy .dat files. The result is recorded in edition. A
+// failing command doesn't fail the update, which is installed already: it
+// raises a WarningPostUpdateCommand and is run again by the next runs until
+// it succeeds.
+func (u *Updater) postUpdate(ctx context.Context, store *state.Store, edition *database.ReadResult) error {
+	if u.config.PostUpdateCommand == "" || !store.Edition(edition.EditionID).PostUpdatePending {
+		return nil
+	}
+
+	if err := u.runPostUpdateCommand(ctx, edition.EditionID); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		edition.PostUpdate = &database.CommandResult{Error: err.Error()}
+		u.warn(Warning{
+			Code:      WarningPostUpdateCommand,
+			EditionID: edition.EditionID,
+			Message:   err.Error(),
+		})
+		return nil
+	}
+	edition.PostUpdate = &database.CommandResult{Succeeded: true}
+
+	err := store.Update(edition.EditionID, func(e *state.Edition) {
+		e.PostUpdatePending = false
+	})
+	if err != nil {
+		return fmt.Errorf("updating state of %s: %w", edition.EditionID, err)
+	}
+	return nil
+}
+
+// runPostUpdateCommand runs the PostUpdateCommand with the path of the
+// database of editionID as last argument. The command also gets the edition
+// and the path from the GEOIPUPDATE_EDITION_ID and
+// GEOIPUPDATE_DATABASE_FILE environment variables.
+func (u *Updater) runPostUpdateCommand(ctx context.Context, editionID string) error {
+	args := strings.Fields(u.config.PostUpdateCommand)
+	path := database.FilePath(u.databaseDir(), editionID)
+
+	//nolint:gosec // the command is configured by the administrator.
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], path)...)
+	cmd.Env = append(
+		os.Environ(),
+		"GEOIPUPDATE_EDITION_ID="+editionID,
+		"GEOIPUPDATE_DATABASE_FILE="+path,
+	)
+	if u.config.Verbose {
+		u.logf("Running post-update command for %s: %s", editionID, strings.Join(cmd.Args, " "))
+	}
+	out, err := cmd.CombinedOutput()
+	out = bytes.TrimSpace(out)
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("running post-update command for %s: %w: %s", editionID, err, out)
+		}
+		return fmt.Errorf("running post-update command for %s: %w", editionID, err)
+	}
+	if u.config.Verbose && len(out) > 0 {
+		u.logf("Post-update command for %s: %s", editionID, out)
+	}
+	return nil
+}
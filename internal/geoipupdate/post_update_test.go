@@ -0,0 +1,89 @@
+package geoipupdate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// TestPostUpdate uses the test binary as the PostUpdateCommand, running
+// testPostUpdateChild, which converts databases to .dat files unless the
+// edition is GeoIP2-ISP.
+func TestPostUpdate(t *testing.T) {
+	if editionID := os.Getenv("GEOIPUPDATE_EDITION_ID"); editionID != "" {
+		testPostUpdateChild(editionID)
+		return
+	}
+
+	tempDir := t.TempDir()
+	u := &Updater{
+		config: &Config{
+			DatabaseDirectory: tempDir,
+			PostUpdateCommand: os.Args[0] + " -test.run=^TestPostUpdate$",
+		},
+		warnings: &warningList{},
+	}
+	store := state.New(filepath.Join(tempDir, ".geoipupdate.state"))
+	for _, editionID := range []string{"GeoLite2-City", "GeoIP2-ISP"} {
+		path := database.FilePath(tempDir, editionID)
+		require.NoError(t, os.WriteFile(path, []byte(editionID), 0o600))
+		require.NoError(t, store.Update(editionID, func(e *state.Edition) {
+			e.PostUpdatePending = true
+		}))
+	}
+
+	city := database.ReadResult{EditionID: "GeoLite2-City"}
+	require.NoError(t, u.postUpdate(context.Background(), store, &city))
+	assert.Equal(t, &database.CommandResult{Succeeded: true}, city.PostUpdate)
+	assert.False(t, store.Edition("GeoLite2-City").PostUpdatePending)
+	converted, err := os.ReadFile(filepath.Join(tempDir, "GeoLite2-City.dat"))
+	require.NoError(t, err)
+	assert.Equal(t, "GeoLite2-City", string(converted))
+
+	// The conversion is done once per update.
+	city.PostUpdate = nil
+	require.NoError(t, u.postUpdate(context.Background(), store, &city))
+	assert.Nil(t, city.PostUpdate)
+
+	// A failing command doesn't fail the update, and is run again by the
+	// next run.
+	isp := database.ReadResult{EditionID: "GeoIP2-ISP"}
+	require.NoError(t, u.postUpdate(context.Background(), store, &isp))
+	require.NotNil(t, isp.PostUpdate)
+	assert.False(t, isp.PostUpdate.Succeeded)
+	assert.Contains(t, isp.PostUpdate.Error, "running post-update command for GeoIP2-ISP: exit status 1")
+	assert.Contains(t, isp.PostUpdate.Error, "no legacy format for GeoIP2-ISP")
+	assert.True(t, store.Edition("GeoIP2-ISP").PostUpdatePending)
+	warnings := u.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarningPostUpdateCommand, warnings[0].Code)
+	assert.Equal(t, "GeoIP2-ISP", warnings[0].EditionID)
+}
+
+func testPostUpdateChild(editionID string) {
+	if editionID == "GeoIP2-ISP" {
+		fmt.Fprintf(os.Stderr, "no legacy format for %s\n", editionID)
+		os.Exit(1)
+	}
+	path := os.Args[len(os.Args)-1]
+	if path != os.Getenv("GEOIPUPDATE_DATABASE_FILE") {
+		fmt.Fprintf(os.Stderr, "unexpected path %s\n", path)
+		os.Exit(1)
+	}
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err == nil {
+		err = os.WriteFile(path[:len(path)-len(filepath.Ext(path))]+".dat", content, 0o600)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
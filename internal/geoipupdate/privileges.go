@@ -0,0 +1,75 @@
+package geoipupdate
+
+import (
+	"fmt"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// DropPrivileges switches the process to the RunAsUser and RunAsGroup of
+// configs, which must agree, if it runs as root. The files the
+// configurations write to, and paths, are given to them first, so that
+// updates can replace them. Directories are never given, as they may be
+// shared, e.g., the system temporary directory. It does nothing if none of
+// configs sets them.
+func DropPrivileges(configs []*Config, paths ...string) error {
+	var runAs *Config
+	verbose := false
+	for _, config := range configs {
+		verbose = verbose || config.Verbose
+		if config.RunAsUser == "" && config.RunAsGroup == "" {
+			continue
+		}
+		if runAs != nil &&
+			(config.RunAsUser != runAs.RunAsUser || config.RunAsGroup != runAs.RunAsGroup) {
+			return fmt.Errorf(
+				"all profiles must have the same `RunAsUser' and `RunAsGroup', got '%s:%s' and '%s:%s'",
+				runAs.RunAsUser,
+				runAs.RunAsGroup,
+				config.RunAsUser,
+				config.RunAsGroup,
+			)
+		}
+		runAs = config
+		paths = append(paths, config.outputPaths()...)
+	}
+	if runAs == nil {
+		return nil
+	}
+	return dropPrivileges(runAs.RunAsUser, runAs.RunAsGroup, paths, verbose)
+}
+
+// outputPaths returns the paths of the files updates with c create: the
+// databases of its editions, and its lock, state, metrics, layer, integrity
+// and trace files.
+func (c *Config) outputPaths() []string {
+	paths := []string{
+		c.LockFile,
+		c.LockFile + progressExtension,
+		c.StateFile,
+		c.MetricsFile,
+		c.LayerFile,
+		c.IntegrityFile,
+		c.TraceFile,
+	}
+	for _, dir := range append([]string{c.DatabaseDirectory}, c.DatabaseDirectories...) {
+		if dir == "" {
+			continue
+		}
+		for _, editionID := range c.EditionIDs {
+			// The databases matching a pattern are only known once it is
+			// resolved, and are given to the user as they are replaced.
+			if !isEditionPattern(editionID) {
+				paths = append(paths, database.FilePath(dir, editionID))
+			}
+		}
+	}
+	var set []string
+	for _, path := range paths {
+		// The progress file is unset with the lock file.
+		if path != "" && path != progressExtension {
+			set = append(set, path)
+		}
+	}
+	return set
+}
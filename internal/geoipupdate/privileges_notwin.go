@@ -0,0 +1,114 @@
+//go:build !windows
+// +build !windows
+
+package geoipupdate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropPrivileges switches to userName and groupName, either of which may be
+// empty, after giving them paths.
+func dropPrivileges(userName, groupName string, paths []string, verbose bool) error {
+	uid, gid, err := lookupRunAs(userName, groupName)
+	if err != nil {
+		return err
+	}
+
+	if os.Geteuid() != 0 {
+		if verbose {
+			log.Printf("Not running as root, ignoring `RunAsUser' and `RunAsGroup'")
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := chownOutput(path, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	// The group must be changed while still root. Since Go 1.16, these
+	// apply to all the threads of the process.
+	if err := unix.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setting supplementary groups: %w", err)
+	}
+	if err := unix.Setgid(gid); err != nil {
+		return fmt.Errorf("setting group ID to %d: %w", gid, err)
+	}
+	if err := unix.Setuid(uid); err != nil {
+		return fmt.Errorf("setting user ID to %d: %w", uid, err)
+	}
+	if verbose {
+		log.Printf("Running as user %d and group %d", uid, gid)
+	}
+	return nil
+}
+
+// lookupRunAs returns the IDs of userName and groupName, which may be names
+// or IDs. If userName is empty, the user stays root. If groupName is empty,
+// it defaults to the primary group of the user.
+func lookupRunAs(userName, groupName string) (uid, gid int, err error) {
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			var unknownErr user.UnknownUserError
+			if !errors.As(err, &unknownErr) {
+				return 0, 0, fmt.Errorf("looking up user '%s': %w", userName, err)
+			}
+			if u, err = user.LookupId(userName); err != nil {
+				return 0, 0, fmt.Errorf("looking up user '%s': %w", userName, err)
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("invalid ID of user '%s': %w", userName, err)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return 0, 0, fmt.Errorf("invalid group ID of user '%s': %w", userName, err)
+		}
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			var unknownErr user.UnknownGroupError
+			if !errors.As(err, &unknownErr) {
+				return 0, 0, fmt.Errorf("looking up group '%s': %w", groupName, err)
+			}
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return 0, 0, fmt.Errorf("looking up group '%s': %w", groupName, err)
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, fmt.Errorf("invalid ID of group '%s': %w", groupName, err)
+		}
+	}
+	return uid, gid, nil
+}
+
+// chownOutput gives the file path to uid and gid. Paths that don't exist
+// yet are skipped, and so are directories, which may be shared with other
+// programs.
+func chownOutput(path string, uid, gid int) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading information of %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil
+	}
+	if err := os.Lchown(path, uid, gid); err != nil {
+		return fmt.Errorf("changing owner of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+//go:build !windows
+// +build !windows
+
+package geoipupdate
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropPrivileges(t *testing.T) {
+	tests := []struct {
+		Description string
+		Configs     []*Config
+		Err         string
+	}{
+		{
+			Description: "Not set",
+			Configs:     []*Config{{}, {}},
+		},
+		{
+			Description: "Unknown user",
+			Configs:     []*Config{{RunAsUser: "geoipupdate-no-such-user"}},
+			Err:         "looking up user 'geoipupdate-no-such-user'",
+		},
+		{
+			Description: "Unknown group",
+			Configs:     []*Config{{RunAsGroup: "geoipupdate-no-such-group"}},
+			Err:         "looking up group 'geoipupdate-no-such-group'",
+		},
+		{
+			Description: "Different users",
+			Configs: []*Config{
+				{RunAsUser: "geoipupdate"},
+				{},
+				{RunAsUser: "nobody"},
+			},
+			Err: "all profiles must have the same `RunAsUser' and `RunAsGroup'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			err := DropPrivileges(test.Configs)
+			if test.Err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.Err)
+		})
+	}
+}
+
+func TestLookupRunAs(t *testing.T) {
+	current, err := user.Current()
+	require.NoError(t, err)
+	uid, err := strconv.Atoi(current.Uid)
+	require.NoError(t, err)
+	gid, err := strconv.Atoi(current.Gid)
+	require.NoError(t, err)
+
+	for _, name := range []string{current.Username, current.Uid} {
+		gotUID, gotGID, err := lookupRunAs(name, "")
+		require.NoError(t, err)
+		require.Equal(t, uid, gotUID)
+		require.Equal(t, gid, gotGID)
+	}
+
+	_, gotGID, err := lookupRunAs(current.Username, current.Gid)
+	require.NoError(t, err)
+	require.Equal(t, gid, gotGID)
+}
+
+func TestOutputPaths(t *testing.T) {
+	config := &Config{
+		DatabaseDirectory: "/var/lib/GeoIP",
+		ArchiveDirectory:  "/var/lib/GeoIP/archive",
+		TempDirectory:     "/tmp",
+		EditionIDs:        []string{"GeoLite2-City", "GeoIP2-*"},
+		LockFile:          "/var/lib/GeoIP/.geoipupdate.lock",
+		StateFile:         "/var/lib/GeoIP/.geoipupdate.state",
+	}
+	require.Equal(t, []string{
+		"/var/lib/GeoIP/.geoipupdate.lock",
+		"/var/lib/GeoIP/.geoipupdate.lock.progress",
+		"/var/lib/GeoIP/.geoipupdate.state",
+		"/var/lib/GeoIP/GeoLite2-City.mmdb",
+	}, config.outputPaths())
+}
+
+func TestChownOutput(t *testing.T) {
+	dir := t.TempDir()
+	databasePath := filepath.Join(dir, "GeoLite2-City.mmdb")
+	require.NoError(t, os.WriteFile(databasePath, []byte("database"), 0o600))
+
+	// Only root can give files to others, so they are otherwise given to
+	// their owner.
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 65534, 65534
+	}
+	require.NoError(t, chownOutput(dir, uid, gid))
+	require.NoError(t, chownOutput(databasePath, uid, gid))
+	require.NoError(t, chownOutput(filepath.Join(dir, "missing"), uid, gid))
+
+	info, err := os.Stat(databasePath)
+	require.NoError(t, err)
+	require.Equal(t, uint32(uid), info.Sys().(*syscall.Stat_t).Uid)
+
+	// Directories, which may be shared, are left to their owner.
+	info, err = os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, uint32(os.Getuid()), info.Sys().(*syscall.Stat_t).Uid)
+}
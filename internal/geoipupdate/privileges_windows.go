@@ -0,0 +1,10 @@
+package geoipupdate
+
+import (
+	"errors"
+)
+
+// dropPrivileges fails as Windows has no equivalent of switching users.
+func dropPrivileges(_, _ string, _ []string, _ bool) error {
+	return errors.New("`RunAsUser' and `RunAsGroup' are not supported on Windows")
+}
@@ -0,0 +1,393 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// Formats of the JSON output enabled by Output.
+const (
+	// OutputFormatEditions outputs the array of updated editions.
+	OutputFormatEditions = "editions"
+	// OutputFormatReport outputs an object describing the geoipupdate
+	// instance along with the updated editions.
+	OutputFormatReport = "report"
+	// OutputFormatStream outputs each edition as soon as it completes, as a
+	// line of JSON.
+	OutputFormatStream = "stream"
+)
+
+// redacted replaces secrets in the configuration of a report.
+const redacted = "[redacted]"
+
+// report is the JSON output of a run with the report format. It lets fleet
+// inventories find outdated or misconfigured instances from the output they
+// already collect.
+type report struct {
+	// RunID identifies the run, as in its announcements.
+	RunID string `json:"run_id"`
+	// Hostname is the name of the host of the run, e.g., to tell the
+	// reports uploaded to ReportURL apart. It is empty if unknown.
+	Hostname string   `json:"hostname"`
+	Version  string   `json:"version"`
+	Features []string `json:"features"`
+	// ConfigHash is the configHash of Config.
+	ConfigHash string                `json:"config_hash"`
+	Labels     map[string]string     `json:"labels,omitempty"`
+	Config     reportConfig          `json:"config"`
+	Editions   []database.ReadResult `json:"editions"`
+	// Warnings are those of the run, which succeeded nonetheless.
+	Warnings []Warning `json:"warnings"`
+	// Summary sums up the run, when it is output.
+	Summary *runSummary `json:"summary,omitempty"`
+}
+
+// reportConfig is the effective configuration of a run, with secrets
+// redacted. The keys are those of the YAML configuration format.
+type reportConfig struct {
+	AccountID           int                 `json:"account_id"`
+	LicenseKey          string              `json:"license_key"`
+	EditionGroups       map[string][]string `json:"edition_groups,omitempty"`
+	EditionDependencies map[string][]string `json:"edition_dependencies,omitempty"`
+	EditionIDs          []string            `json:"edition_ids"`
+	EditionOrder        string              `json:"edition_order,omitempty"`
+	ExcludeEditionIDs   []string            `json:"exclude_edition_ids,omitempty"`
+	DatabaseDirectory   string              `json:"database_directory"`
+	DatabaseDirectories []string            `json:"database_directories,omitempty"`
+	Host                string              `json:"host"`
+	HostAuth            map[string]string   `json:"host_auth,omitempty"`
+	Proxy               string              `json:"proxy,omitempty"`
+	ProxyUserPassword   string              `json:"proxy_user_password,omitempty"`
+	PreserveFileTimes   bool                `json:"preserve_file_times"`
+	LockFile            string              `json:"lock_file"`
+	LockType            string              `json:"lock_type"`
+	Notify              []string            `json:"notify,omitempty"`
+	AlertAfterFailures  int                 `json:"alert_after_failures,omitempty"`
+	ExpectedCadence     map[string]string   `json:"expected_cadence,omitempty"`
+	StateFile           string              `json:"state_file"`
+	PIDFile             string              `json:"pid_file,omitempty"`
+	RunAsUser           string              `json:"run_as_user,omitempty"`
+	RunAsGroup          string              `json:"run_as_group,omitempty"`
+	Sandbox             bool                `json:"sandbox"`
+	SelfCheckIP         string              `json:"self_check_ip,omitempty"`
+	Parallelism         int                 `json:"parallelism"`
+	PostUpdateCommand   string              `json:"post_update_command,omitempty"`
+	Profile             string              `json:"profile,omitempty"`
+	Peers               []string            `json:"peers,omitempty"`
+	RetryFor            string              `json:"retry_for"`
+	RetryPolicy         map[string]string   `json:"retry_policy,omitempty"`
+	WriteRetryFor       string              `json:"write_retry_for"`
+	RunTimeout          string              `json:"run_timeout"`
+	RunMode             string              `json:"run_mode,omitempty"`
+	Schedule            string              `json:"schedule,omitempty"`
+	ScheduleJitter      string              `json:"schedule_jitter,omitempty"`
+	FailFastThreshold   int                 `json:"fail_fast_threshold"`
+	SkipIfRunning       bool                `json:"skip_if_running"`
+	ArchiveDirectory    string              `json:"archive_directory,omitempty"`
+	ArchiveS3           string              `json:"archive_s3,omitempty"`
+	ArchiveS3Class      string              `json:"archive_s3_storage_class,omitempty"`
+	ConsumerLockTimeout string              `json:"consumer_lock_timeout"`
+	CacheMaxAge         string              `json:"cache_max_age"`
+	ChecksumForensics   bool                `json:"checksum_forensics"`
+	ConsistentSet       bool                `json:"consistent_set"`
+	MaxDecompressedSize int64               `json:"max_decompressed_size"`
+	MaxDiskUsage        int64               `json:"max_disk_usage"`
+	MetadataCacheTTL    string              `json:"metadata_cache_ttl"`
+	MinUpdateInterval   map[string]string   `json:"min_update_interval,omitempty"`
+	Middleware          []string            `json:"middleware,omitempty"`
+	WriteStrategy       string              `json:"write_strategy"`
+	TempDirectory       string              `json:"temp_directory,omitempty"`
+	TraceFile           string              `json:"trace_file,omitempty"`
+	UnavailablePolicy   string              `json:"unavailable_edition_policy,omitempty"`
+	DisableSelfUpdate   bool                `json:"disable_self_update"`
+	OutputFormat        string              `json:"output_format"`
+	MetricsFile         string              `json:"metrics_file,omitempty"`
+	LayerFile           string              `json:"layer_file,omitempty"`
+	IntegrityFile       string              `json:"integrity_file,omitempty"`
+	Destination         string              `json:"destination,omitempty"`
+	DestinationEncoding string              `json:"destination_encoding,omitempty"`
+	S3Mirror            string              `json:"s3_mirror,omitempty"`
+	S3Push              string              `json:"s3_push,omitempty"`
+	S3PushEncoding      string              `json:"s3_push_encoding,omitempty"`
+	S3Region            string              `json:"s3_region,omitempty"`
+	OCIMirror           string              `json:"oci_mirror,omitempty"`
+	OCIPush             string              `json:"oci_push,omitempty"`
+	OCIPushEncoding     string              `json:"oci_push_encoding,omitempty"`
+	OCIPushVerify       *bool               `json:"oci_push_verify,omitempty"`
+	EncryptionKeyFile   string              `json:"encryption_key_file,omitempty"`
+	EncryptionKMS       string              `json:"encryption_kms,omitempty"`
+	ReportToken         string              `json:"report_token,omitempty"`
+	ReportURL           string              `json:"report_url,omitempty"`
+}
+
+// newReport returns the report of the run runID with config that updated
+// editions and raised warnings.
+func newReport(
+	config *Config,
+	runID string,
+	editions []database.ReadResult,
+	warnings []Warning,
+) report {
+	if editions == nil {
+		editions = []database.ReadResult{}
+	}
+	if warnings == nil {
+		warnings = []Warning{}
+	}
+	// The report is still useful without the hostname.
+	hostname, _ := os.Hostname()
+	return report{
+		RunID:      runID,
+		Hostname:   hostname,
+		Version:    vars.Version,
+		Features:   enabledFeatures(config),
+		ConfigHash: configHash(config),
+		Labels:     config.Labels,
+		Config:     newReportConfig(config),
+		Editions:   editions,
+		Warnings:   warnings,
+	}
+}
+
+// configHash returns the hex-encoded SHA-256 of the compact JSON of the
+// effective configuration of config, as reported, so that instances whose
+// configurations drifted apart can be found. Secrets are redacted first, so
+// they don't change it, and neither do the labels.
+func configHash(config *Config) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(newReportConfig(config)); err != nil {
+		// The configuration only holds types that can be encoded.
+		panic(fmt.Sprintf("encoding configuration: %s", err))
+	}
+	sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func newReportConfig(config *Config) reportConfig {
+	c := reportConfig{
+		AccountID:           config.AccountID,
+		EditionGroups:       config.EditionGroups,
+		EditionDependencies: config.EditionDependencies,
+		EditionIDs:          append(slices.Clone(config.EditionIDs), config.EditionPatterns...),
+		EditionOrder:        config.EditionOrder,
+		ExcludeEditionIDs:   config.ExcludeEditionIDs,
+		DatabaseDirectory:   config.DatabaseDirectory,
+		DatabaseDirectories: config.DatabaseDirectories,
+		Host:                config.URL,
+		PreserveFileTimes:   config.PreserveFileTimes,
+		LockFile:            config.LockFile,
+		LockType:            config.LockType,
+		AlertAfterFailures:  config.AlertAfterFailures,
+		StateFile:           config.StateFile,
+		PIDFile:             config.PIDFile,
+		RunAsUser:           config.RunAsUser,
+		RunAsGroup:          config.RunAsGroup,
+		Sandbox:             config.Sandbox,
+		SelfCheckIP:         config.SelfCheckIP,
+		Parallelism:         config.Parallelism,
+		PostUpdateCommand:   config.PostUpdateCommand,
+		Profile:             config.Profile,
+		Peers:               config.Peers,
+		RetryFor:            config.RetryFor.String(),
+		WriteRetryFor:       config.WriteRetryFor.String(),
+		RunTimeout:          config.RunTimeout.String(),
+		RunMode:             config.RunMode,
+		FailFastThreshold:   config.FailFastThreshold,
+		SkipIfRunning:       config.SkipIfRunning,
+		ArchiveDirectory:    config.ArchiveDirectory,
+		ArchiveS3:           config.ArchiveS3,
+		ConsumerLockTimeout: config.ConsumerLockTimeout.String(),
+		CacheMaxAge:         config.CacheMaxAge.String(),
+		ChecksumForensics:   config.ChecksumForensics,
+		ConsistentSet:       config.ConsistentSet,
+		MaxDecompressedSize: config.MaxDecompressedSize,
+		MaxDiskUsage:        config.MaxDiskUsage,
+		MetadataCacheTTL:    config.MetadataCacheTTL.String(),
+		Middleware:          config.Middleware,
+		WriteStrategy:       config.WriteStrategy,
+		TempDirectory:       config.TempDirectory,
+		TraceFile:           config.TraceFile,
+		UnavailablePolicy:   config.UnavailableEditionPolicy,
+		DisableSelfUpdate:   config.DisableSelfUpdate,
+		OutputFormat:        config.OutputFormat,
+		MetricsFile:         config.MetricsFile,
+		LayerFile:           config.LayerFile,
+		IntegrityFile:       config.IntegrityFile,
+		Destination:         config.Destination,
+		DestinationEncoding: config.DestinationEncoding,
+		S3Mirror:            config.S3Mirror,
+		S3Push:              config.S3Push,
+		OCIMirror:           config.OCIMirror,
+		OCIPush:             config.OCIPush,
+		OCIPushEncoding:     config.OCIPushEncoding,
+		EncryptionKeyFile:   config.EncryptionKeyFile,
+		EncryptionKMS:       config.EncryptionKMS,
+	}
+	if config.S3Mirror != "" || config.S3Push != "" || config.ArchiveS3 != "" {
+		c.S3Region = s3Region(config)
+	}
+	if config.ArchiveS3 != "" {
+		c.ArchiveS3Class = archiveStorageClass(config)
+	}
+	if config.S3Push != "" {
+		c.S3PushEncoding = s3PushEncoding(config).Name
+	}
+	if config.OCIPush != "" {
+		verify := config.OCIPushVerify
+		c.OCIPushVerify = &verify
+	}
+	if config.LicenseKey != "" {
+		c.LicenseKey = redacted
+	}
+	// Only the schemes are reported, as the other parameters may be
+	// secrets.
+	if len(config.HostAuth) > 0 {
+		c.HostAuth = map[string]string{}
+		for host, auth := range config.HostAuth {
+			c.HostAuth[host] = auth.Scheme
+		}
+	}
+	if len(config.ExpectedCadence) > 0 {
+		c.ExpectedCadence = map[string]string{}
+		for editionID, cadence := range config.ExpectedCadence {
+			c.ExpectedCadence[editionID] = cadence.String()
+		}
+	}
+	if len(config.MinUpdateInterval) > 0 {
+		c.MinUpdateInterval = map[string]string{}
+		for editionID, interval := range config.MinUpdateInterval {
+			c.MinUpdateInterval[editionID] = interval.String()
+		}
+	}
+	if len(config.RetryPolicy) > 0 {
+		c.RetryPolicy = map[string]string{}
+		for class, strategy := range config.RetryPolicy {
+			c.RetryPolicy[class] = strategy.String()
+		}
+	}
+	if !config.Schedule.IsZero() {
+		c.Schedule = config.Schedule.String()
+	}
+	if config.ScheduleJitter > 0 {
+		c.ScheduleJitter = config.ScheduleJitter.String()
+	}
+	if config.ReportToken != "" {
+		c.ReportToken = redacted
+	}
+	if config.ReportURL != "" {
+		c.ReportURL = notify.Redact(config.ReportURL)
+	}
+	// The echo endpoint of SelfCheckIP may embed credentials.
+	if config.SelfCheckIP != "" && net.ParseIP(config.SelfCheckIP) == nil {
+		c.SelfCheckIP = notify.Redact(config.SelfCheckIP)
+	}
+	// Notification targets may embed credentials.
+	for _, target := range config.Notify {
+		c.Notify = append(c.Notify, notify.Redact(target))
+	}
+	if config.Proxy != nil {
+		proxy := *config.Proxy
+		if proxy.User != nil {
+			c.ProxyUserPassword = redacted
+			proxy.User = nil
+		}
+		c.Proxy = proxy.String()
+	}
+	return c
+}
+
+// enabledFeatures returns the sorted names of the optional features config
+// enables.
+func enabledFeatures(config *Config) []string {
+	features := []string{}
+	enabled := map[string]bool{
+		"alerts":               config.AlertAfterFailures > 0,
+		"archive":              config.ArchiveDirectory != "",
+		"archive-s3":           config.ArchiveS3 != "",
+		"blue-green":           len(config.DatabaseDirectories) > 0,
+		"cadence":              len(config.ExpectedCadence) > 0,
+		"cache-max-age":        config.CacheMaxAge > 0,
+		"check-only":           config.CheckOnly,
+		"checksum-forensics":   config.ChecksumForensics,
+		"consistent-set":       config.ConsistentSet,
+		"consumer-lock":        config.ConsumerLockTimeout > 0,
+		"disk-usage-budget":    config.MaxDiskUsage > 0,
+		"edition-dependencies": len(config.EditionDependencies) > 0,
+		"edition-groups":       len(config.EditionGroups) > 0,
+		"edition-order":        config.EditionOrder != "" && config.EditionOrder != EditionOrderConfig,
+		"edition-patterns":     len(config.EditionPatterns) > 0,
+		"encryption":           config.EncryptionKeyFile != "" || config.EncryptionKMS != "",
+		"encryption-kms":       config.EncryptionKMS != "",
+		"exclude-editions":     len(config.ExcludeEditionIDs) > 0,
+		"fail-fast":            config.FailFastThreshold > 0,
+		"metadata-cache":       config.MetadataCacheTTL > 0,
+		"metrics":              config.MetricsFile != "",
+		"middleware":           len(config.Middleware) > 0,
+		"min-update-interval":  len(config.MinUpdateInterval) > 0,
+		"oci-mirror":           config.OCIMirror != "",
+		"oci-push":             config.OCIPush != "",
+		"oci-push-verify":      config.OCIPush != "" && config.OCIPushVerify,
+		"copy-write-strategy":  config.WriteStrategy == database.WriteStrategyCopy,
+		"destination":          config.Destination != "",
+		"host-auth":            len(config.HostAuth) > 0,
+		"labels":               len(config.Labels) > 0,
+		"layer":                config.LayerFile != "",
+		"integrity":            config.IntegrityFile != "",
+		"notify":               len(config.Notify) > 0,
+		"parallel-downloads":   config.Parallelism > 1,
+		"peers":                len(config.Peers) > 0,
+		"pid-file":             config.PIDFile != "",
+		"post-update-command":  config.PostUpdateCommand != "",
+		"preserve-file-times":  config.PreserveFileTimes,
+		"proxy":                config.Proxy != nil,
+		"report-upload":        config.ReportURL != "",
+		"retry-policy":         len(config.RetryPolicy) > 0,
+		"run-as-user":          config.RunAsUser != "" || config.RunAsGroup != "",
+		"run-timeout":          config.RunTimeout > 0,
+		"schedule":             !config.Schedule.IsZero(),
+		"schedule-jitter":      config.ScheduleJitter > 0,
+		"s3-mirror":            config.S3Mirror != "",
+		"s3-push":              config.S3Push != "",
+		"sandbox":              config.Sandbox,
+		"self-check":           config.SelfCheckIP != "",
+		"self-update":          !config.DisableSelfUpdate,
+		"skip-if-running":      config.SkipIfRunning,
+		"trace":                config.TraceFile != "",
+		"unavailable-editions": config.UnavailableEditionPolicy != "" &&
+			config.UnavailableEditionPolicy != UnavailableEditionKeep,
+	}
+	for feature, on := range enabled {
+		if on {
+			features = append(features, feature)
+		}
+	}
+	slices.Sort(features)
+	return features
+}
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case OutputFormatEditions, OutputFormatReport, OutputFormatStream:
+		return nil
+	default:
+		return fmt.Errorf(
+			"`OutputFormat' must be %s, %s or %s, got '%s'",
+			OutputFormatEditions,
+			OutputFormatReport,
+			OutputFormatStream,
+			format,
+		)
+	}
+}
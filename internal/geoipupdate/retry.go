@@ -0,0 +1,241 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+)
+
+// The kinds of RetryStrategy.
+const (
+	// RetryNever fails the edition on the first error.
+	RetryNever = "never"
+	// RetryOnce retries right away, once.
+	RetryOnce = "once"
+	// RetryBackoff retries with an exponential backoff until the retry
+	// budget is spent.
+	RetryBackoff = "backoff"
+)
+
+// retryClasses are the classes of errors of RetryPolicy besides those of
+// HTTP status codes, which are the reasons of the attempts they fail.
+var retryClasses = []string{
+	"checksum",
+	"disk",
+	"dns",
+	"network",
+	"other",
+	"proxy",
+	"read",
+	"timeout",
+	"tls",
+	"write",
+}
+
+// httpRetryClass matches the classes of HTTP status codes, e.g., http_429
+// for a status code or http_5xx for all server errors.
+var httpRetryClass = regexp.MustCompile(`^http_([1-5](\d\d|xx))$`)
+
+// defaultRetryPolicy is the strategy of the classes of errors that aren't
+// in RetryPolicy.
+var defaultRetryPolicy = map[string]RetryStrategy{
+	// Retrying won't fix the request, e.g., the license key.
+	"http_4xx": {Kind: RetryNever},
+	// Retrying too soon would be rate limited again.
+	"http_429": {Kind: RetryBackoff, Wait: time.Minute},
+	// The download was most likely corrupted on the way.
+	"checksum": {Kind: RetryOnce},
+	// The disk won't free up by itself.
+	"disk": {Kind: RetryNever},
+}
+
+// RetryStrategy is how errors of a class are retried.
+type RetryStrategy struct {
+	// Kind is RetryNever, RetryOnce or RetryBackoff.
+	Kind string
+	// Wait is the minimum wait before each retry of RetryBackoff, e.g.,
+	// to back off for longer when rate limited.
+	Wait time.Duration
+}
+
+// parseRetryStrategy parses a retry strategy: never, once, backoff, or
+// backoff:duration for a backoff waiting at least duration.
+func parseRetryStrategy(value string) (RetryStrategy, error) {
+	kind, wait, hasWait := strings.Cut(value, ":")
+	switch {
+	case !hasWait && (kind == RetryNever || kind == RetryOnce || kind == RetryBackoff):
+		return RetryStrategy{Kind: kind}, nil
+	case kind == RetryBackoff:
+		dur, err := time.ParseDuration(wait)
+		if err != nil || dur < 0 {
+			return RetryStrategy{}, fmt.Errorf("'%s' is not a valid duration", wait)
+		}
+		return RetryStrategy{Kind: kind, Wait: dur}, nil
+	default:
+		return RetryStrategy{}, fmt.Errorf(
+			"invalid retry strategy '%s': expected never, once, backoff or backoff:duration",
+			value,
+		)
+	}
+}
+
+// String returns the strategy as parsed by parseRetryStrategy.
+func (s RetryStrategy) String() string {
+	if s.Wait > 0 {
+		return s.Kind + ":" + s.Wait.String()
+	}
+	return s.Kind
+}
+
+// parseRetryPolicy parses the value of the setting name, a space-separated
+// list of class=strategy pairs.
+func parseRetryPolicy(name, value string) (map[string]RetryStrategy, error) {
+	policy := map[string]RetryStrategy{}
+	for _, entry := range strings.Fields(value) {
+		class, strategy, ok := strings.Cut(entry, "=")
+		if !ok || class == "" {
+			return nil, fmt.Errorf("`%s' must be a list of class=strategy pairs, got '%s'", name, entry)
+		}
+		if !slices.Contains(retryClasses, class) && !httpRetryClass.MatchString(class) {
+			return nil, fmt.Errorf(
+				"`%s': unknown error class '%s', expected %s, http_4xx, http_5xx or an HTTP status code such as http_429",
+				name,
+				class,
+				strings.Join(retryClasses, ", "),
+			)
+		}
+		s, err := parseRetryStrategy(strategy)
+		if err != nil {
+			return nil, fmt.Errorf("`%s': %w", name, err)
+		}
+		policy[class] = s
+	}
+	return policy, nil
+}
+
+// retryStrategy returns the strategy of class, the reason of an attempt,
+// from policy or else defaultRetryPolicy. The strategy of the status code
+// of an HTTP error takes precedence over that of its class, e.g., http_4xx.
+func retryStrategy(policy map[string]RetryStrategy, class string) RetryStrategy {
+	classes := []string{class}
+	if httpRetryClass.MatchString(class) {
+		classes = append(classes, class[:len("http_")+1]+"xx")
+	}
+	for _, p := range []map[string]RetryStrategy{policy, defaultRetryPolicy} {
+		for _, c := range classes {
+			if s, ok := p[c]; ok {
+				return s
+			}
+		}
+	}
+	return RetryStrategy{Kind: RetryBackoff}
+}
+
+// retryBackOff is an exponential backoff whose sleeps are truncated so that
+// no retry happens after the retry budget of the last error, RetryFor or
+// WriteRetryFor, is spent, nor after the deadline of the context, e.g., of
+// RunTimeout. Errors are retried according to the RetryStrategy of their
+// class.
+type retryBackOff struct {
+	exp    *backoff.ExponentialBackOff
+	ctx    context.Context
+	clock  Clock
+	start  time.Time
+	policy map[string]RetryStrategy
+
+	// strategy is that of the last error.
+	strategy RetryStrategy
+	// retries are the numbers of retries of each class of errors.
+	retries map[string]int
+
+	// budgetName and budget are the name and the value of the retry budget
+	// of the last error.
+	budgetName string
+	budget     time.Duration
+	// untruncated is the last sleep before it was truncated.
+	untruncated time.Duration
+}
+
+// newRetryBackOff creates a retryBackOff retrying errors according to
+// policy, the RetryPolicy, measuring the time spent with clock.
+func newRetryBackOff(ctx context.Context, policy map[string]RetryStrategy, clock Clock) *retryBackOff {
+	exp := backoff.NewExponentialBackOff()
+	// The budget is enforced by retryBackOff rather than by stopping once
+	// the next sleep would exceed it.
+	exp.MaxElapsedTime = 0
+	return &retryBackOff{
+		exp:     exp,
+		ctx:     ctx,
+		clock:   clock,
+		start:   clock.Now(),
+		policy:  policy,
+		retries: map[string]int{},
+	}
+}
+
+// retryable marks err, of class, as permanent if it must not be retried,
+// either because of the strategy of its class or because budget, named
+// budgetName, has elapsed since the first attempt. Otherwise, the next sleep
+// is bounded by budget.
+func (b *retryBackOff) retryable(err error, class, budgetName string, budget time.Duration) error {
+	b.budgetName = budgetName
+	b.budget = budget
+	b.strategy = retryStrategy(b.policy, class)
+	if b.strategy.Kind == RetryNever ||
+		(b.strategy.Kind == RetryOnce && b.retries[class] > 0) ||
+		errors.Is(err, client.ErrEditionUnavailable) ||
+		b.elapsed() >= budget {
+		return backoff.Permanent(err)
+	}
+	b.retries[class]++
+	return err
+}
+
+// NextBackOff implements backoff.BackOff.
+func (b *retryBackOff) NextBackOff() time.Duration {
+	next := b.exp.NextBackOff()
+	switch b.strategy.Kind {
+	case RetryOnce:
+		next = 0
+	case RetryBackoff:
+		next = max(next, b.strategy.Wait)
+	}
+	b.untruncated = next
+
+	remaining := b.budget - b.elapsed()
+	if remaining <= 0 {
+		return backoff.Stop
+	}
+	next = min(next, remaining)
+
+	// A retry at the deadline would be canceled right away. Deadlines are
+	// in the time of the system clock.
+	if deadline, ok := b.ctx.Deadline(); ok && time.Until(deadline) <= next {
+		return backoff.Stop
+	}
+	return next
+}
+
+// Reset implements backoff.BackOff.
+func (b *retryBackOff) Reset() {
+	b.exp.Reset()
+	b.start = b.clock.Now()
+}
+
+// elapsed returns the time spent since the first attempt.
+func (b *retryBackOff) elapsed() time.Duration {
+	return b.clock.Now().Sub(b.start)
+}
+
+// remaining returns how much of the retry budget of the last error is left.
+func (b *retryBackOff) remaining() time.Duration {
+	return max(b.budget-b.elapsed(), 0)
+}
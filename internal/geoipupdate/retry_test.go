@@ -0,0 +1,87 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackOff(t *testing.T) {
+	errRetry := errors.New("connection reset")
+
+	b := newRetryBackOff(context.Background(), nil, systemClock{})
+	b.start = time.Now().Add(-900 * time.Millisecond)
+	require.Equal(t, errRetry, b.retryable(errRetry, "network", "RetryFor", time.Second))
+
+	// The initial interval is at least 250ms, more than what is left.
+	next := b.NextBackOff()
+	require.LessOrEqual(t, next, 100*time.Millisecond)
+	require.Greater(t, b.untruncated, next)
+
+	// The budget of a write error is separate.
+	require.Equal(t, errRetry, b.retryable(errRetry, "write", "WriteRetryFor", time.Minute))
+	require.Greater(t, b.NextBackOff(), 100*time.Millisecond)
+
+	var permanent *backoff.PermanentError
+	require.ErrorAs(t, b.retryable(errRetry, "network", "RetryFor", 0), &permanent)
+
+	// Retrying at the deadline of the context would be pointless.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	b = newRetryBackOff(ctx, nil, systemClock{})
+	require.Equal(t, errRetry, b.retryable(errRetry, "network", "RetryFor", time.Minute))
+	require.Equal(t, backoff.Stop, b.NextBackOff())
+}
+
+func TestRetryPolicy(t *testing.T) {
+	errRetry := errors.New("failed")
+	var permanent *backoff.PermanentError
+
+	b := newRetryBackOff(context.Background(), map[string]RetryStrategy{
+		"http_5xx": {Kind: RetryNever},
+		"http_401": {Kind: RetryOnce},
+	}, systemClock{})
+
+	// The defaults apply to the classes that aren't configured.
+	require.ErrorAs(t, b.retryable(errRetry, "http_404", "RetryFor", time.Hour), &permanent)
+	require.ErrorAs(t, b.retryable(errRetry, "disk", "WriteRetryFor", time.Hour), &permanent)
+	require.Equal(t, errRetry, b.retryable(errRetry, "http_429", "RetryFor", time.Hour))
+	require.Equal(t, time.Minute, b.NextBackOff())
+
+	// The strategy of a status code takes precedence over that of its
+	// class.
+	require.ErrorAs(t, b.retryable(errRetry, "http_503", "RetryFor", time.Hour), &permanent)
+	require.Equal(t, errRetry, b.retryable(errRetry, "http_401", "RetryFor", time.Hour))
+	require.Zero(t, b.NextBackOff())
+	require.ErrorAs(t, b.retryable(errRetry, "http_401", "RetryFor", time.Hour), &permanent)
+
+	// A checksum mismatch is retried right away, once.
+	require.Equal(t, errRetry, b.retryable(errRetry, "checksum", "WriteRetryFor", time.Hour))
+	require.Zero(t, b.NextBackOff())
+	require.ErrorAs(t, b.retryable(errRetry, "checksum", "WriteRetryFor", time.Hour), &permanent)
+}
+
+func TestParseRetryPolicy(t *testing.T) {
+	policy, err := parseRetryPolicy("RetryPolicy", "http_429=backoff:2m dns=once http_5xx=backoff")
+	require.NoError(t, err)
+	require.Equal(t, map[string]RetryStrategy{
+		"http_429": {Kind: RetryBackoff, Wait: 2 * time.Minute},
+		"dns":      {Kind: RetryOnce},
+		"http_5xx": {Kind: RetryBackoff},
+	}, policy)
+	require.Equal(t, "backoff:2m0s", policy["http_429"].String())
+
+	_, err = parseRetryPolicy("RetryPolicy", "tls")
+	require.EqualError(t, err, "`RetryPolicy' must be a list of class=strategy pairs, got 'tls'")
+
+	_, err = parseRetryPolicy("RetryPolicy", "tls=always")
+	require.EqualError(
+		t,
+		err,
+		"`RetryPolicy': invalid retry strategy 'always': expected never, once, backoff or backoff:duration",
+	)
+}
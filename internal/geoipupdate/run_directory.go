@@ -0,0 +1,26 @@
+package geoipupdate
+
+import (
+	"context"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// runDirectory switches the directory the databases are updated in for
+// each run, so that consumers only see the databases of a run once all of
+// its editions succeeded.
+type runDirectory interface {
+	// begin prepares the directory of the run, holding the installed
+	// databases of editionIDs.
+	begin(ctx context.Context, editionIDs []string) error
+	// current returns the directory of the current run, or the directory
+	// of the installed databases between runs.
+	current() string
+	// writer returns the writer of the current directory.
+	writer() database.Writer
+	// publish makes the databases of the run, which succeeded with
+	// editions, those installed.
+	publish(ctx context.Context, editions []database.ReadResult) error
+	// end ends the run, whether it succeeded or not.
+	end()
+}
@@ -0,0 +1,141 @@
+package geoipupdate
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+)
+
+// defaultS3Region is the region of S3Mirror, S3Push, and ArchiveS3 if
+// S3Region is not set.
+const defaultS3Region = "us-east-1"
+
+// defaultArchiveStorageClass is the storage class of the uploads to
+// ArchiveS3 if ArchiveS3StorageClass is not set.
+const defaultArchiveStorageClass = "GLACIER"
+
+// storageClasses are the Amazon S3 storage classes ArchiveS3 can use.
+var storageClasses = []string{"STANDARD", "STANDARD_IA", "GLACIER_IR", "GLACIER", "DEEP_ARCHIVE"}
+
+// parseS3URL validates value, the s3://bucket[/prefix] URL of the setting
+// name, returning it without a trailing slash.
+func parseS3URL(name, value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "s3" || u.Host == "" || u.RawQuery != "" {
+		return "", fmt.Errorf("`%s' must be an s3://bucket[/prefix] URL, got '%s'", name, value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// checkStorageClass checks that value, the value of the setting name, is
+// one of storageClasses.
+func checkStorageClass(name, value string) error {
+	if !slices.Contains(storageClasses, value) {
+		return fmt.Errorf(
+			"`%s' must be one of %s, got '%s'",
+			name,
+			strings.Join(storageClasses, ", "),
+			value,
+		)
+	}
+	return nil
+}
+
+// archiveStorageClass returns the storage class of the ArchiveS3 of config.
+func archiveStorageClass(config *Config) string {
+	if config.ArchiveS3StorageClass == "" {
+		return defaultArchiveStorageClass
+	}
+	return config.ArchiveS3StorageClass
+}
+
+// s3Region returns the region of the S3Mirror, S3Push, and ArchiveS3 of
+// config.
+func s3Region(config *Config) string {
+	if config.S3Region == "" {
+		return defaultS3Region
+	}
+	return config.S3Region
+}
+
+// s3BucketURL returns the HTTPS URL of s3URL, an s3://bucket[/prefix] URL
+// in the S3Region of config.
+func s3BucketURL(config *Config, s3URL string) string {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(s3URL, "s3://"), "/")
+	mirrorURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, s3Region(config))
+	if prefix != "" {
+		mirrorURL += "/" + prefix
+	}
+	return mirrorURL
+}
+
+// s3Decrypter returns the client.S3Decrypter of the archives of S3Mirror
+// encrypted by S3Push, with key or with data keys wrapped by wrapper, or nil
+// if neither is set.
+func s3Decrypter(key *encryption.Key, wrapper encryption.KeyWrapper) client.S3Decrypter {
+	if key == nil && wrapper == nil {
+		return nil
+	}
+	return func(ctx context.Context, entry client.S3ManifestEntry, body io.Reader) (io.Reader, error) {
+		k, err := s3DecryptionKey(ctx, key, wrapper, entry)
+		if err != nil {
+			return nil, err
+		}
+		return k.NewReader(body)
+	}
+}
+
+// s3DecryptionKey returns the key the archive of entry must be decrypted
+// with, as for the layers of OCIMirror.
+func s3DecryptionKey(
+	ctx context.Context,
+	key *encryption.Key,
+	wrapper encryption.KeyWrapper,
+	entry client.S3ManifestEntry,
+) (*encryption.Key, error) {
+	if entry.Encryption != encryption.Algorithm {
+		return nil, fmt.Errorf("the database of %s is encrypted with the unsupported %s", entry.EditionID, entry.Encryption)
+	}
+
+	if entry.EncryptionWrappedKey != "" {
+		if wrapper == nil {
+			return nil, fmt.Errorf(
+				"the database of %s is encrypted with a data key wrapped by %s, but no key management service is set",
+				entry.EditionID,
+				entry.EncryptionKEK,
+			)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(entry.EncryptionWrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the data key of %s: %w", entry.EditionID, err)
+		}
+		k, err := encryption.UnwrapKey(ctx, wrapper, encryption.WrappedKey{KEK: entry.EncryptionKEK, Ciphertext: ciphertext})
+		if err != nil {
+			return nil, err
+		}
+		if k.ID != entry.EncryptionKey {
+			return nil, fmt.Errorf("the data key of %s does not match its ID", entry.EditionID)
+		}
+		return &k, nil
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("the database of %s is encrypted, but no encryption key is set", entry.EditionID)
+	}
+	if entry.EncryptionKey != key.ID {
+		return nil, fmt.Errorf(
+			"the database of %s is encrypted with the key %s rather than %s",
+			entry.EditionID,
+			entry.EncryptionKey,
+			key.ID,
+		)
+	}
+	return key, nil
+}
@@ -0,0 +1,418 @@
+package geoipupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/awscreds"
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// errS3NotFound is returned when an object doesn't exist.
+var errS3NotFound = errors.New("not found")
+
+// s3Pusher publishes the installed databases to S3Push as a mirror that
+// S3Mirror can download from: a tar archive per build, as served by
+// MaxMind, and the client.S3Manifest listing them. It also uploads the
+// archived copies of databases to ArchiveS3.
+type s3Pusher struct {
+	bucketURL string
+	// archiveEncoding is the compression of the tar archives, the
+	// S3PushEncoding.
+	archiveEncoding encoding.Encoding
+	httpClient      *http.Client
+	// key, if not nil, encrypts the archives pushed.
+	key *encryption.Key
+	// keyWrapper, if not nil, wraps the data keys the archives pushed are
+	// encrypted with. It takes precedence over key.
+	keyWrapper encryption.KeyWrapper
+	signer     *sigv4.Signer
+	// storageClass is the storage class of uploads, e.g., GLACIER. The
+	// default of the bucket is used if it is empty.
+	storageClass string
+	tempDir      string
+}
+
+// newS3Pusher creates the s3Pusher of s3URL, the S3Push or ArchiveS3 of
+// config, signing requests with the AWS credentials of the environment, as
+// found by awscreds.New.
+func newS3Pusher(config *Config, s3URL string, httpClient *http.Client) (*s3Pusher, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	creds, err := awscreds.New(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("pushing to S3: %w", err)
+	}
+	return &s3Pusher{
+		bucketURL:       s3BucketURL(config, s3URL),
+		archiveEncoding: s3PushEncoding(config),
+		httpClient:      httpClient,
+		signer: &sigv4.Signer{
+			Region:   s3Region(config),
+			Service:  "s3",
+			Provider: creds,
+		},
+		tempDir: config.TempDirectory,
+	}, nil
+}
+
+// s3PushEncoding returns the compression of the archives pushed to the
+// S3Push of config.
+func s3PushEncoding(config *Config) encoding.Encoding {
+	name := config.S3PushEncoding
+	if name == "" {
+		name = encoding.Gzip
+	}
+	// The encoding was validated with the configuration.
+	e, _ := encoding.Lookup(name)
+	return e
+}
+
+// pushS3 publishes the installed databases of editions to S3Push. The
+// builds the mirror already holds aren't uploaded again, and the manifest
+// is only replaced once the archives it lists are uploaded.
+func (u *Updater) pushS3(ctx context.Context, store *state.Store, editions []database.ReadResult) error {
+	manifest, err := u.s3Pusher.manifest(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, edition := range editions {
+		if edition.Unavailable && edition.NewHash == database.ZeroMD5 {
+			continue
+		}
+		i := -1
+		for j, entry := range manifest.Databases {
+			if entry.EditionID == edition.EditionID {
+				i = j
+			}
+		}
+		entry := client.S3ManifestEntry{
+			EditionID: edition.EditionID,
+			MD5:       edition.NewHash,
+		}
+		key, err := u.s3Pusher.pushKey(ctx, &entry)
+		if err != nil {
+			return fmt.Errorf("encrypting %s: %w", edition.EditionID, err)
+		}
+		if i >= 0 && manifest.Databases[i].MD5 == edition.NewHash &&
+			s3KeyIdentity(manifest.Databases[i]) == s3KeyIdentity(entry) {
+			continue
+		}
+
+		path := database.FilePath(u.databaseDir(), edition.EditionID)
+		entry.ModifiedAt, err = buildDate(store, path, &edition)
+		if err != nil {
+			return fmt.Errorf("pushing %s: %w", edition.EditionID, err)
+		}
+		entry.Object, err = u.s3Pusher.pushArchive(ctx, path, entry, key)
+		if err != nil {
+			return fmt.Errorf("pushing %s to %s: %w", edition.EditionID, u.config.S3Push, err)
+		}
+		if u.config.Verbose {
+			u.logf("Pushed %s to %s", edition.EditionID, u.config.S3Push)
+		}
+
+		if i >= 0 {
+			manifest.Databases[i] = entry
+		} else {
+			manifest.Databases = append(manifest.Databases, entry)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("encoding mirror manifest: %w", err)
+	}
+	err = u.s3Pusher.putObject(ctx, client.S3ManifestKey, bytes.NewReader(body), int64(len(body)), nil)
+	if err != nil {
+		return fmt.Errorf("pushing the manifest to %s: %w", u.config.S3Push, err)
+	}
+	return nil
+}
+
+// manifest returns the manifest of the mirror, which is empty if the mirror
+// doesn't have one yet.
+func (p *s3Pusher) manifest(ctx context.Context) (*client.S3Manifest, error) {
+	req, err := p.newRequest(ctx, http.MethodGet, client.S3ManifestKey, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := p.do(req)
+	if errors.Is(err, errS3NotFound) {
+		return &client.S3Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting mirror manifest: %w", err)
+	}
+	defer response.Body.Close()
+
+	var manifest client.S3Manifest
+	if err := json.NewDecoder(response.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("parsing mirror manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// pushKey returns the key the archive of entry is encrypted with, which is
+// nil for unencrypted archives, recording it in entry. With a KeyWrapper,
+// it is a new data key.
+func (p *s3Pusher) pushKey(ctx context.Context, entry *client.S3ManifestEntry) (*encryption.Key, error) {
+	switch {
+	case p.keyWrapper != nil:
+		key, wrapped, err := encryption.NewDataKey(ctx, p.keyWrapper)
+		if err != nil {
+			return nil, err
+		}
+		entry.Encryption = encryption.Algorithm
+		entry.EncryptionKey = key.ID
+		entry.EncryptionKEK = wrapped.KEK
+		entry.EncryptionWrappedKey = base64.StdEncoding.EncodeToString(wrapped.Ciphertext)
+		return &key, nil
+	case p.key != nil:
+		entry.Encryption = encryption.Algorithm
+		entry.EncryptionKey = p.key.ID
+		return p.key, nil
+	default:
+		return nil, nil
+	}
+}
+
+// s3KeyIdentity returns what identifies the key of the archive of entry:
+// its key-encryption key for data keys, as each archive has its own, or
+// else the key itself. It is empty for unencrypted archives.
+func s3KeyIdentity(entry client.S3ManifestEntry) string {
+	if entry.EncryptionKEK != "" {
+		return entry.EncryptionKEK
+	}
+	return entry.EncryptionKey
+}
+
+// pushArchive uploads the database at path, as the build of entry, in a
+// tar archive like those served by MaxMind, e.g.,
+// GeoIP2-City/GeoIP2-City_20240102.tar.gz holding
+// GeoIP2-City_20240102/GeoIP2-City.mmdb, returning the key of the archive.
+// The extension of the archive follows its compression, e.g., .tar.zst
+// with zstd. The archive is encrypted with key, if not nil, once
+// compressed, and gets a .enc suffix.
+func (p *s3Pusher) pushArchive(
+	ctx context.Context,
+	path string,
+	entry client.S3ManifestEntry,
+	key *encryption.Key,
+) (string, error) {
+	//nolint:gosec // we really need to read this file.
+	db, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	info, err := db.Stat()
+	if err != nil {
+		return "", fmt.Errorf("reading database: %w", err)
+	}
+
+	// The archive is written to a temporary file first, as S3 needs the
+	// size of uploads.
+	ext := ".tar" + p.archiveEncoding.Extension
+	if key != nil {
+		ext += ".enc"
+	}
+	archive, err := os.CreateTemp(p.tempDir, "geoipupdate-s3-push-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		_ = archive.Close() //nolint:errcheck // the file is removed anyway.
+		_ = os.Remove(archive.Name())
+	}()
+
+	build := entry.EditionID + "_" + entry.ModifiedAt.UTC().Format("20060102")
+	var w io.Writer = archive
+	var ew io.WriteCloser
+	if key != nil {
+		ew, err = key.NewWriter(archive)
+		if err != nil {
+			return "", fmt.Errorf("encrypting archive: %w", err)
+		}
+		w = ew
+	}
+	zw, err := p.archiveEncoding.NewWriter(w)
+	if err != nil {
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     build + "/" + filepath.Base(path),
+		Mode:     0o644,
+		Size:     info.Size(),
+		ModTime:  entry.ModifiedAt,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if _, err := io.Copy(tw, db); err != nil {
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if ew != nil {
+		if err := ew.Close(); err != nil {
+			return "", fmt.Errorf("encrypting archive: %w", err)
+		}
+	}
+
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", fmt.Errorf("reading archive: %w", err)
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("reading archive: %w", err)
+	}
+	object := entry.EditionID + "/" + build + ext
+	if err := p.putObject(ctx, object, archive, size, nil); err != nil {
+		return "", err
+	}
+	return object, nil
+}
+
+// UploadArchive uploads the archived copy of a database, at path, as the
+// object name. Its MD5 sum is kept in the object metadata, as the ETag of
+// objects encrypted with SSE-KMS isn't their MD5 sum.
+func (p *s3Pusher) UploadArchive(ctx context.Context, name, path, md5Sum string) error {
+	req, err := p.newRequest(ctx, http.MethodHead, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	response, err := p.do(req)
+	switch {
+	case errors.Is(err, errS3NotFound):
+	case err != nil:
+		return fmt.Errorf("checking %s: %w", name, err)
+	default:
+		response.Body.Close()
+		archived := response.Header.Get("X-Amz-Meta-Md5")
+		if !strings.EqualFold(archived, md5Sum) {
+			return fmt.Errorf("%s already holds another build (md5 %s rather than %s)", name, archived, md5Sum)
+		}
+		return nil
+	}
+
+	sum, err := hex.DecodeString(md5Sum)
+	if err != nil {
+		return fmt.Errorf("invalid MD5 sum '%s'", md5Sum)
+	}
+	//nolint:gosec // we really need to read this file.
+	db, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	info, err := db.Stat()
+	if err != nil {
+		return fmt.Errorf("reading database: %w", err)
+	}
+	return p.putObject(ctx, name, db, info.Size(), http.Header{
+		"Content-Md5":    {base64.StdEncoding.EncodeToString(sum)},
+		"X-Amz-Meta-Md5": {md5Sum},
+	})
+}
+
+// putObject uploads the object at key, whose content of size bytes is read
+// from body, with the additional headers, if any.
+func (p *s3Pusher) putObject(
+	ctx context.Context,
+	key string,
+	body io.Reader,
+	size int64,
+	headers http.Header,
+) error {
+	req, err := p.newRequest(ctx, http.MethodPut, key, body, headers)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	response, err := p.do(req)
+	if err != nil {
+		return fmt.Errorf("putting %s: %w", key, err)
+	}
+	response.Body.Close()
+	return nil
+}
+
+// newRequest creates a signed request for the object at key, with the
+// additional headers, if any.
+func (p *s3Pusher) newRequest(
+	ctx context.Context,
+	method, key string,
+	body io.Reader,
+	headers http.Header,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.bucketURL+"/"+key, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request for %s: %w", key, err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+	if method == http.MethodPut && p.storageClass != "" {
+		req.Header.Set("X-Amz-Storage-Class", p.storageClass)
+	}
+	if err := p.signer.AuthenticateUnsignedPayload(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// do sends req, returning errS3NotFound if the object doesn't exist.
+func (p *s3Pusher) do(req *http.Request) (*http.Response, error) {
+	response, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, errS3NotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		//nolint:errcheck // we are already returning an error.
+		buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		return nil, fmt.Errorf("unexpected HTTP status code: %w", internal.HTTPError{
+			Body:       strings.TrimSpace(string(buf)),
+			StatusCode: response.StatusCode,
+		})
+	}
+	return response, nil
+}
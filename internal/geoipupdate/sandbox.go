@@ -0,0 +1,63 @@
+package geoipupdate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// errSandboxUnsupported is returned by the parts of the sandbox the system
+// doesn't support.
+var errSandboxUnsupported = errors.New("not supported")
+
+// Sandbox restricts the process if any of configs enables Sandbox: writes
+// are only allowed to the directories the configurations write to, and to
+// the directories of paths, and system calls geoipupdate has no use for,
+// e.g., ptrace(2), fail. It can't be undone and applies to child processes
+// as well.
+func Sandbox(configs []*Config, paths ...string) error {
+	enabled := false
+	verbose := false
+	for _, config := range configs {
+		enabled = enabled || config.Sandbox
+		verbose = verbose || config.Verbose
+	}
+	if !enabled {
+		return nil
+	}
+
+	// Profiles that don't enable the sandbox run in it all the same.
+	var dirs []string
+	for _, config := range configs {
+		dirs = append(dirs, config.writableDirectories()...)
+	}
+	for _, path := range paths {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	return sandbox(dirs, verbose)
+}
+
+// writableDirectories returns the directories updates with c write to.
+func (c *Config) writableDirectories() []string {
+	dirs := []string{c.DatabaseDirectory, c.ArchiveDirectory, c.TempDirectory}
+	dirs = append(dirs, c.DatabaseDirectories...)
+	// The archives pushed to S3Push are written to temporary files.
+	if c.TempDirectory == "" && (c.WriteStrategy == database.WriteStrategyCopy || c.S3Push != "") {
+		dirs = append(dirs, os.TempDir())
+	}
+	for _, file := range []string{c.LockFile, c.StateFile, c.MetricsFile, c.LayerFile, c.IntegrityFile, c.TraceFile} {
+		if file != "" {
+			dirs = append(dirs, filepath.Dir(file))
+		}
+	}
+
+	var set []string
+	for _, dir := range dirs {
+		if dir != "" {
+			set = append(set, dir)
+		}
+	}
+	return set
+}
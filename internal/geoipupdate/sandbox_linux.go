@@ -0,0 +1,294 @@
+package geoipupdate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deniedSyscalls are the system calls that fail with EPERM in the sandbox.
+// geoipupdate has no use for them, but they would help an attacker who
+// took control of it to compromise the system.
+var deniedSyscalls = []uintptr{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_ADJTIMEX,
+	unix.SYS_BPF,
+	unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_PTRACE,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+// x32SyscallBit is set in the numbers of the system calls of the x32 ABI,
+// which would otherwise bypass the filter on amd64.
+const x32SyscallBit = 0x40000000
+
+// sandbox restricts writes to dirs with Landlock, and denies deniedSyscalls
+// with seccomp. Parts the kernel or the build don't support are skipped
+// with a warning.
+func sandbox(dirs []string, verbose bool) error {
+	// The filter must be installed from the thread without new privileges.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	allThreads, err := setNoNewPrivs()
+	if err != nil {
+		return err
+	}
+
+	err = restrictWrites(dirs, allThreads)
+	switch {
+	case errors.Is(err, errSandboxUnsupported):
+		log.Printf("Warning [sandbox]: writes aren't restricted: %s", err)
+	case err != nil:
+		return err
+	case verbose:
+		log.Printf("Restricted writes to %s", strings.Join(dirs, ", "))
+	}
+
+	err = filterSyscalls()
+	switch {
+	case errors.Is(err, errSandboxUnsupported):
+		log.Printf("Warning [sandbox]: system calls aren't filtered: %s", err)
+	case err != nil:
+		return err
+	case verbose:
+		log.Printf("Filtered system calls")
+	}
+	return nil
+}
+
+// setNoNewPrivs prevents the process from gaining privileges, e.g., by
+// executing setuid programs, which Landlock and seccomp require. It returns
+// whether it could be set on all threads, which builds with cgo can't do.
+func setNoNewPrivs() (bool, error) {
+	_, _, errno := syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0)
+	if errno == 0 {
+		return true, nil
+	}
+	if errno != unix.ENOTSUP {
+		return false, fmt.Errorf("setting no new privileges: %w", errno)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return false, fmt.Errorf("setting no new privileges: %w", err)
+	}
+	return false, nil
+}
+
+// restrictWrites restricts writes to dirs with Landlock, creating those
+// that don't exist yet. Landlock restricts the threads that enable it, so
+// it can only be used if allThreads is true.
+func restrictWrites(dirs []string, allThreads bool) error {
+	// They couldn't be created afterwards.
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("creating directory: %w", err)
+		}
+	}
+
+	if !allThreads {
+		return fmt.Errorf("%w: builds with cgo can't restrict all threads", errSandboxUnsupported)
+	}
+
+	abi, _, errno := unix.Syscall(
+		unix.SYS_LANDLOCK_CREATE_RULESET,
+		0,
+		0,
+		unix.LANDLOCK_CREATE_RULESET_VERSION,
+	)
+	if errno != 0 {
+		if errno == unix.ENOSYS || errno == unix.EOPNOTSUPP {
+			return fmt.Errorf("%w: Landlock isn't enabled in the kernel", errSandboxUnsupported)
+		}
+		return fmt.Errorf("getting Landlock version: %w", errno)
+	}
+
+	// Reads and executions aren't restricted.
+	access := uint64(unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: access}
+	ruleset, _, errno := unix.Syscall(
+		unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)),
+		unsafe.Sizeof(attr),
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("creating Landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(ruleset)) //nolint:errcheck // nothing to do about it.
+
+	for _, dir := range dirs {
+		if err := allowWrites(int(ruleset), dir, access); err != nil {
+			return err
+		}
+	}
+
+	_, _, errno = syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("enforcing Landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// allowWrites adds a rule to ruleset allowing access beneath dir.
+func allowWrites(ruleset int, dir string, access uint64) error {
+	fd, err := unix.Open(dir, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", dir, err)
+	}
+	defer unix.Close(fd) //nolint:errcheck // nothing to do about it.
+
+	attr := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd), //nolint:gosec // file descriptors fit in 32 bits.
+	}
+	_, _, errno := unix.Syscall6(
+		unix.SYS_LANDLOCK_ADD_RULE,
+		uintptr(ruleset),
+		unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)),
+		0, 0, 0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("allowing writes to %s: %w", dir, errno)
+	}
+	return nil
+}
+
+// filterSyscalls makes deniedSyscalls fail with EPERM in all threads.
+func filterSyscalls() error {
+	filter, err := seccompFilter()
+	if err != nil {
+		return err
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)), //nolint:gosec // the filter is short.
+		Filter: &filter[0],
+	}
+	_, _, errno := unix.Syscall(
+		unix.SYS_SECCOMP,
+		unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_TSYNC,
+		uintptr(unsafe.Pointer(&prog)),
+	)
+	if errno != 0 {
+		return fmt.Errorf("installing seccomp filter: %w", errno)
+	}
+	return nil
+}
+
+// seccompFilter returns the BPF program denying deniedSyscalls, and the
+// system calls of other architectures.
+func seccompFilter() ([]unix.SockFilter, error) {
+	arch, ok := auditArch()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown architecture %s", errSandboxUnsupported, runtime.GOARCH)
+	}
+
+	const (
+		// The offsets of the fields of struct seccomp_data.
+		nrOffset   = 0
+		archOffset = 4
+		deny       = unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+	)
+	load := func(offset uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: offset}
+	}
+	ret := func(action uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: action}
+	}
+
+	filter := []unix.SockFilter{
+		load(archOffset),
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, K: arch},
+		ret(deny),
+		load(nrOffset),
+	}
+	// The jumps to the last instruction, denying the call, are added
+	// once its position is known.
+	var jumps []int
+	if runtime.GOARCH == "amd64" {
+		jumps = append(jumps, len(filter))
+		filter = append(filter, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K, K: x32SyscallBit})
+	}
+	for _, nr := range deniedSyscalls {
+		jumps = append(jumps, len(filter))
+		filter = append(filter, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: uint32(nr)})
+	}
+	filter = append(filter, ret(unix.SECCOMP_RET_ALLOW), ret(deny))
+	for _, i := range jumps {
+		filter[i].Jt = uint8(len(filter) - i - 2) //nolint:gosec // the filter is short.
+	}
+	return filter, nil
+}
+
+// auditArch returns the AUDIT_ARCH value of the architecture geoipupdate is
+// built for, as seen by seccomp.
+func auditArch() (uint32, bool) {
+	switch runtime.GOARCH {
+	case "386":
+		return unix.AUDIT_ARCH_I386, true
+	case "amd64":
+		return unix.AUDIT_ARCH_X86_64, true
+	case "arm":
+		return unix.AUDIT_ARCH_ARM, true
+	case "arm64":
+		return unix.AUDIT_ARCH_AARCH64, true
+	case "ppc64le":
+		return unix.AUDIT_ARCH_PPC64LE, true
+	case "riscv64":
+		return unix.AUDIT_ARCH_RISCV64, true
+	case "s390x":
+		return unix.AUDIT_ARCH_S390X, true
+	default:
+		return 0, false
+	}
+}
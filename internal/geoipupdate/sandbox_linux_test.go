@@ -0,0 +1,57 @@
+package geoipupdate
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// TestSandbox sandboxes a child process running testSandboxChild, as the
+// sandbox can't be undone.
+func TestSandbox(t *testing.T) {
+	if dir := os.Getenv("GEOIPUPDATE_TEST_SANDBOX_DIR"); dir != "" {
+		testSandboxChild(t, dir)
+		return
+	}
+
+	dir := t.TempDir()
+	//nolint:gosec // the test binary runs itself.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSandbox$", "-test.v")
+	cmd.Env = append(os.Environ(), "GEOIPUPDATE_TEST_SANDBOX_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func testSandboxChild(t *testing.T, dir string) {
+	databaseDir := filepath.Join(dir, "databases")
+	config := &Config{
+		DatabaseDirectory: databaseDir,
+		LockFile:          filepath.Join(databaseDir, ".geoipupdate.lock"),
+		Sandbox:           true,
+	}
+
+	allThreads, err := setNoNewPrivs()
+	require.NoError(t, err)
+	restrictErr := restrictWrites(config.writableDirectories(), allThreads)
+	if !errors.Is(restrictErr, errSandboxUnsupported) {
+		require.NoError(t, restrictErr)
+	}
+	require.NoError(t, filterSyscalls())
+
+	require.NoError(t, os.WriteFile(filepath.Join(databaseDir, "GeoLite2-City.mmdb"), nil, 0o600))
+	err = os.WriteFile(filepath.Join(dir, "outside"), nil, 0o600)
+	if restrictErr == nil {
+		require.ErrorIs(t, err, os.ErrPermission)
+	}
+
+	require.ErrorIs(t, unix.Unshare(unix.CLONE_NEWNS), unix.EPERM)
+}
+
+func TestSandboxDisabled(t *testing.T) {
+	require.NoError(t, Sandbox([]*Config{{}, {Sandbox: false}}))
+}
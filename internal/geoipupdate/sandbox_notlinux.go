@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package geoipupdate
+
+import (
+	"errors"
+)
+
+// sandbox fails as only Linux has Landlock and seccomp.
+func sandbox(_ []string, _ bool) error {
+	return errors.New("`Sandbox' is only supported on Linux")
+}
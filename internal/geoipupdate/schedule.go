@@ -0,0 +1,252 @@
+package geoipupdate
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// RunModeOnce runs a single update, the default.
+	RunModeOnce = "once"
+	// RunModeDaemon keeps running updates on the Schedule.
+	RunModeDaemon = "daemon"
+)
+
+// DefaultSchedule is the Schedule of daemons when none is set.
+var DefaultSchedule = Schedule{Every: 12 * time.Hour}
+
+// cronSearchLimit is how far ahead the next time matching a cron expression
+// is searched for. It spans a leap day.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// cronMacros are the shorthands of common cron expressions.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// monthNames are the abbreviations of the months in cron expressions,
+// indexed by time.Month minus one.
+var monthNames = []string{
+	"jan", "feb", "mar", "apr", "may", "jun",
+	"jul", "aug", "sep", "oct", "nov", "dec",
+}
+
+// Schedule is when a daemon runs updates: either every given interval, or
+// at the times matching a cron expression.
+type Schedule struct {
+	// Every is the interval between two runs. It is 0 if Cron is set.
+	Every time.Duration
+	// Cron is a cron expression with the five standard fields, minute,
+	// hour, day of the month, month, and day of the week, matched in local
+	// time.
+	Cron string
+	cron cronExpression
+}
+
+// cronExpression is a parsed cron expression, whose fields are bit sets of
+// the values they match.
+type cronExpression struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// anyDay and anyWeekday are true if the day of the month, respectively
+	// the day of the week, starts with *. When both are restricted, days
+	// matching either are matched, as with cron.
+	anyDay     bool
+	anyWeekday bool
+}
+
+// parseSchedule parses a schedule: a duration, e.g., 12h, or a cron
+// expression, e.g., "30 3 * * mon-fri" or @daily.
+func parseSchedule(value string) (Schedule, error) {
+	value = strings.TrimSpace(value)
+	if every, err := time.ParseDuration(value); err == nil {
+		if every <= 0 {
+			return Schedule{}, fmt.Errorf("invalid schedule '%s': the interval must be positive", value)
+		}
+		return Schedule{Every: every}, nil
+	}
+
+	expression := value
+	if macro, ok := cronMacros[strings.ToLower(value)]; ok {
+		expression = macro
+	}
+	cron, err := parseCronExpression(expression)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule '%s': %w", value, err)
+	}
+	s := Schedule{Cron: value, cron: cron}
+	if s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return Schedule{}, fmt.Errorf("invalid schedule '%s': it never matches", value)
+	}
+	return s, nil
+}
+
+// String returns the schedule as parsed by parseSchedule.
+func (s Schedule) String() string {
+	if s.Cron != "" {
+		return s.Cron
+	}
+	return s.Every.String()
+}
+
+// IsZero returns whether s is unset.
+func (s Schedule) IsZero() bool {
+	return s.Every == 0 && s.Cron == ""
+}
+
+// Next returns when the run following one started at after is due. It
+// returns the zero time if the cron expression matches no time in the next
+// five years.
+func (s Schedule) Next(after time.Time) time.Time {
+	if s.Cron == "" {
+		return after.Add(s.Every)
+	}
+	c := s.cron
+	limit := after.Add(cronSearchLimit)
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for !t.After(limit) {
+		switch {
+		case !hasBit(c.months, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !hasBit(c.hours, t.Hour()):
+			// Truncate would be off in time zones with a half-hour offset.
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !hasBit(c.minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay returns whether the day of t matches the day of the month and
+// day of the week fields of c.
+func (c cronExpression) matchesDay(t time.Time) bool {
+	day := hasBit(c.days, t.Day())
+	weekday := hasBit(c.weekdays, int(t.Weekday()))
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// nextRun returns when the run following one started at after is due on
+// schedule, delayed by a random duration of up to jitter.
+func nextRun(schedule Schedule, jitter time.Duration, after time.Time) time.Time {
+	next := schedule.Next(after)
+	if jitter > 0 {
+		//nolint:gosec // the delay doesn't need to be cryptographically random.
+		next = next.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+	return next
+}
+
+func hasBit(set uint64, i int) bool {
+	return set&(1<<uint(i)) != 0
+}
+
+// parseCronExpression parses the five fields of a cron expression.
+func parseCronExpression(expression string) (cronExpression, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return cronExpression{}, errors.New(
+			"expected a duration, or a cron expression with 5 fields: minute, hour, day of month, month, day of week",
+		)
+	}
+
+	var c cronExpression
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return cronExpression{}, fmt.Errorf("minute: %w", err)
+	}
+	if c.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return cronExpression{}, fmt.Errorf("hour: %w", err)
+	}
+	if c.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return cronExpression{}, fmt.Errorf("day of month: %w", err)
+	}
+	if c.months, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return cronExpression{}, fmt.Errorf("month: %w", err)
+	}
+	// Sunday is either 0 or 7.
+	if c.weekdays, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return cronExpression{}, fmt.Errorf("day of week: %w", err)
+	}
+	if hasBit(c.weekdays, 7) {
+		c.weekdays |= 1
+	}
+	c.anyDay = strings.HasPrefix(fields[2], "*")
+	c.anyWeekday = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField parses a field of a cron expression, a comma-separated list
+// of *, values, or ranges, e.g., 1-5, each optionally followed by a step,
+// e.g., */15, into the bit set of the values it matches between low and high.
+// The values may be given by their names, the first of which is low.
+func parseCronField(field string, low, high int, names []string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", stepPart)
+			}
+		}
+
+		start, end := low, high
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseCronValue(from, low, high, names); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseCronValue(to, low, high, names); err != nil {
+					return 0, err
+				}
+				if end < start {
+					return 0, fmt.Errorf("invalid range '%s'", rangePart)
+				}
+			} else if hasStep {
+				end = high
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			set |= 1 << uint(i)
+		}
+	}
+	return set, nil
+}
+
+// parseCronValue parses a value of a field of a cron expression, a number
+// between low and high, or one of names.
+func parseCronValue(value string, low, high int, names []string) (int, error) {
+	if i := slices.Index(names, strings.ToLower(value)); i >= 0 {
+		return low + i, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < low || i > high {
+		return 0, fmt.Errorf("invalid value '%s', expected %d to %d", value, low, high)
+	}
+	return i, nil
+}
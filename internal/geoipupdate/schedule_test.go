@@ -0,0 +1,149 @@
+package geoipupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		Input string
+		Every time.Duration
+		Err   string
+	}{
+		{Input: "12h", Every: 12 * time.Hour},
+		{Input: "30 3 * * mon-fri"},
+		{Input: "*/15 * * * *"},
+		{Input: "0 0 29 feb *"},
+		{Input: "@daily"},
+		{Input: "0 0 * * 7"},
+		{
+			Input: "-1h",
+			Err:   "invalid schedule '-1h': the interval must be positive",
+		},
+		{
+			Input: "daily",
+			Err: "invalid schedule 'daily': expected a duration, or a cron expression with 5 fields: " +
+				"minute, hour, day of month, month, day of week",
+		},
+		{
+			Input: "60 * * * *",
+			Err:   "invalid schedule '60 * * * *': minute: invalid value '60', expected 0 to 59",
+		},
+		{
+			Input: "0 5-3 * * *",
+			Err:   "invalid schedule '0 5-3 * * *': hour: invalid range '5-3'",
+		},
+		{
+			Input: "*/0 * * * *",
+			Err:   "invalid schedule '*/0 * * * *': minute: invalid step '0'",
+		},
+		{
+			Input: "0 0 * * someday",
+			Err:   "invalid schedule '0 0 * * someday': day of week: invalid value 'someday', expected 0 to 7",
+		},
+		{
+			Input: "0 0 31 feb *",
+			Err:   "invalid schedule '0 0 31 feb *': it never matches",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			s, err := parseSchedule(test.Input)
+			if test.Err != "" {
+				require.EqualError(t, err, test.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Every, s.Every)
+			if test.Every == 0 {
+				assert.Equal(t, test.Input, s.String())
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	monday := time.Date(2024, 1, 1, 10, 17, 42, 0, time.UTC)
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+
+	tests := []struct {
+		Description string
+		Schedule    string
+		After       time.Time
+		Expected    time.Time
+	}{
+		{
+			Description: "interval",
+			Schedule:    "12h",
+			After:       monday,
+			Expected:    monday.Add(12 * time.Hour),
+		},
+		{
+			Description: "every 15 minutes",
+			Schedule:    "*/15 * * * *",
+			After:       monday,
+			Expected:    time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			Description: "not the current minute",
+			Schedule:    "17 10 * * *",
+			After:       monday,
+			Expected:    time.Date(2024, 1, 2, 10, 17, 0, 0, time.UTC),
+		},
+		{
+			Description: "weekdays",
+			Schedule:    "30 3 * * sat,sun",
+			After:       monday,
+			Expected:    time.Date(2024, 1, 6, 3, 30, 0, 0, time.UTC),
+		},
+		{
+			Description: "day of month or day of week",
+			Schedule:    "0 0 15 * fri",
+			After:       monday,
+			Expected:    time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Description: "leap day",
+			Schedule:    "0 0 29 2 *",
+			After:       monday,
+			Expected:    time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Description: "end of year",
+			Schedule:    "@monthly",
+			After:       time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC),
+			Expected:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Description: "half-hour offset",
+			Schedule:    "0 4 * * *",
+			After:       time.Date(2024, 1, 1, 2, 45, 0, 0, kolkata),
+			Expected:    time.Date(2024, 1, 1, 4, 0, 0, 0, kolkata),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Description, func(t *testing.T) {
+			s, err := parseSchedule(test.Schedule)
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, s.Next(test.After))
+		})
+	}
+}
+
+func TestNextRunJitter(t *testing.T) {
+	schedule := Schedule{Every: time.Hour}
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, after.Add(time.Hour), nextRun(schedule, 0, after))
+	for i := 0; i < 100; i++ {
+		next := nextRun(schedule, 10*time.Minute, after)
+		assert.False(t, next.Before(after.Add(time.Hour)))
+		assert.True(t, next.Before(after.Add(time.Hour+10*time.Minute)))
+	}
+}
@@ -0,0 +1,67 @@
+package geoipupdate
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// NewSeedHandler returns the handler serving the installed databases of the
+// editions of config to peers, i.e., to instances with the Peers setting.
+// Databases are served at /<EditionID>.mmdb, with support for range
+// requests. Instances with the ReportURL setting may also upload the
+// reports of their runs to /reports, authenticated with the ReportToken of
+// config, and /fleet-status serves which build of each edition every host
+// has.
+func NewSeedHandler(config *Config) http.Handler {
+	fleet := newFleet(config.ReportToken)
+	databases := seedDatabases(config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reports":
+			fleet.receive(w, r)
+		case "/fleet-status":
+			fleet.serveStatus(w, r)
+		default:
+			databases.ServeHTTP(w, r)
+		}
+	})
+}
+
+// seedDatabases returns the handler serving the installed databases of the
+// editions of config.
+func seedDatabases(config *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		editionID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".mmdb")
+		if !ok || !config.wantsEdition(editionID) {
+			http.NotFound(w, r)
+			return
+		}
+
+		// With the rename write strategy, the open file keeps holding a
+		// whole build. Otherwise, peers detect mixed builds with the MD5
+		// sum.
+		f, err := os.Open(database.FilePath(config.activeDirectory(), editionID))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, "reading database", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", info.ModTime(), f)
+	})
+}
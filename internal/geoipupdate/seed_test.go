@@ -0,0 +1,57 @@
+package geoipupdate
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedHandler(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "GeoIP2-City.mmdb"), []byte("GeoIP2-City content"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "GeoIP2-ISP.mmdb"), []byte("GeoIP2-ISP content"), 0o600))
+
+	server := httptest.NewServer(NewSeedHandler(&Config{
+		DatabaseDirectory: dir,
+		EditionIDs:        []string{"GeoIP2-City", "GeoIP2-Country"},
+	}))
+	defer server.Close()
+
+	get := func(path, byteRange string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(body)
+	}
+
+	status, body := get("/GeoIP2-City.mmdb", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "GeoIP2-City content", body)
+
+	status, body = get("/GeoIP2-City.mmdb", "bytes=7-10")
+	assert.Equal(t, http.StatusPartialContent, status)
+	assert.Equal(t, "City", body)
+
+	// Only the databases of the configured editions are served.
+	status, _ = get("/GeoIP2-ISP.mmdb", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = get("/GeoIP2-Country.mmdb", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = get("/../GeoIP2-City.mmdb", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
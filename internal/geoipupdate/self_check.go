@@ -0,0 +1,112 @@
+package geoipupdate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/notify"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// maxEchoResponseSize is the size of the largest response read from the
+// echo endpoint of SelfCheckIP, which only holds an IP address.
+const maxEchoResponseSize = 1 << 10
+
+// parseSelfCheckIP parses the value of the setting name, an IP address or
+// the http or https URL of an endpoint responding with the IP address of
+// the client, e.g., https://checkip.amazonaws.com.
+func parseSelfCheckIP(name, value string) (string, error) {
+	if net.ParseIP(value) != nil {
+		return value, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != schemeHTTPS) || u.Host == "" {
+		return "", fmt.Errorf(
+			"`%s' must be an IP address or an http or https URL, got '%s'",
+			name,
+			notify.Redact(value),
+		)
+	}
+	return value, nil
+}
+
+// newSelfCheck returns the function looking up the IP address of the
+// SelfCheckIP in the new database of an edition, as a cheap check that it
+// is loadable and plausible, or nil if SelfCheckIP isn't set. The IP address
+// is resolved once per run, when the first edition is updated. A failure
+// raises a WarningSelfCheck without failing the update.
+func (u *Updater) newSelfCheck(ctx context.Context) func(editionID string) *database.LookupResult {
+	if u.config.SelfCheckIP == "" {
+		return nil
+	}
+	resolve := sync.OnceValues(func() (net.IP, error) {
+		ip, err := u.resolveSelfCheckIP(ctx)
+		if err != nil {
+			u.warn(Warning{Code: WarningSelfCheck, Message: err.Error()})
+		}
+		return ip, err
+	})
+	return func(editionID string) *database.LookupResult {
+		ip, err := resolve()
+		if err != nil {
+			return &database.LookupResult{Error: err.Error()}
+		}
+		result, err := database.Lookup(database.FilePath(u.databaseDir(), editionID), ip)
+		if err != nil {
+			u.warn(Warning{Code: WarningSelfCheck, EditionID: editionID, Message: err.Error()})
+			return &database.LookupResult{IP: ip.String(), Error: err.Error()}
+		}
+		if u.config.Verbose {
+			u.logf("Self-check of %s: %s found: %t %s", editionID, result.IP, result.Found, result.Network)
+		}
+		return &result
+	}
+}
+
+// resolveSelfCheckIP returns the IP address of the SelfCheckIP, requesting
+// it from its echo endpoint if it isn't an IP address.
+func (u *Updater) resolveSelfCheckIP(ctx context.Context) (net.IP, error) {
+	if ip := net.ParseIP(u.config.SelfCheckIP); ip != nil {
+		return ip, nil
+	}
+
+	endpoint := notify.Redact(u.config.SelfCheckIP)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.config.SelfCheckIP, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating self-check request: %w", err)
+	}
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+
+	httpClient := u.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting IP address from %s: %w", endpoint, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"requesting IP address from %s: unexpected HTTP status code %d",
+			endpoint,
+			response.StatusCode,
+		)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxEchoResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading IP address from %s: %w", endpoint, err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("%s responded with no IP address", endpoint)
+	}
+	return ip, nil
+}
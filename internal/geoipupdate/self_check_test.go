@@ -0,0 +1,96 @@
+package geoipupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestSelfCheck(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		fmt.Fprintln(w, "81.2.69.142")
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	writeEmptyMMDB(t, database.FilePath(tempDir, "GeoLite2-City"))
+	require.NoError(t, os.WriteFile(database.FilePath(tempDir, "GeoLite2-ASN"), []byte("corrupt"), 0o600))
+
+	u := &Updater{
+		config: &Config{
+			DatabaseDirectory: tempDir,
+			SelfCheckIP:       server.URL,
+		},
+		warnings: &warningList{},
+	}
+	selfCheck := u.newSelfCheck(context.Background())
+	require.NotNil(t, selfCheck)
+
+	assert.Equal(t, &database.LookupResult{IP: "81.2.69.142"}, selfCheck("GeoLite2-City"))
+
+	// The IP address is resolved once per run, and a failed lookup doesn't
+	// fail the update.
+	result := selfCheck("GeoLite2-ASN")
+	assert.Equal(t, "81.2.69.142", result.IP)
+	assert.Contains(t, result.Error, "opening database")
+	assert.Equal(t, int32(1), requests.Load())
+	warnings := u.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarningSelfCheck, warnings[0].Code)
+	assert.Equal(t, "GeoLite2-ASN", warnings[0].EditionID)
+}
+
+func TestSelfCheckEchoFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, "<html>not an IP address</html>")
+	}))
+	defer server.Close()
+
+	u := &Updater{
+		config:   &Config{DatabaseDirectory: t.TempDir(), SelfCheckIP: server.URL},
+		warnings: &warningList{},
+	}
+	selfCheck := u.newSelfCheck(context.Background())
+	result := selfCheck("GeoLite2-City")
+	assert.Equal(t, server.URL+" responded with no IP address", result.Error)
+	selfCheck("GeoLite2-ASN")
+	assert.Len(t, u.Warnings(), 1)
+}
+
+// writeEmptyMMDB writes an MMDB without records to path.
+func writeEmptyMMDB(t *testing.T, path string) {
+	require.NoError(t, os.WriteFile(path, emptyMMDB(t, 0), 0o600))
+}
+
+// emptyMMDB returns an MMDB without records built at buildEpoch, or now if
+// it is 0.
+func emptyMMDB(t *testing.T, buildEpoch int64) []byte {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		BuildEpoch:   buildEpoch,
+		DatabaseType: "Test",
+		RecordSize:   24,
+	})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = tree.WriteTo(&buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestSelfCheckDisabled(t *testing.T) {
+	u := &Updater{config: &Config{}}
+	assert.Nil(t, u.newSelfCheck(context.Background()))
+}
@@ -0,0 +1,61 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+)
+
+// defaultDecompressionRatio is how many times larger than their archives
+// databases can be if MaxDecompressedSize is not set. Databases are usually
+// two to three times larger.
+const defaultDecompressionRatio = 10
+
+// defaultMaxDecompressedSize is the size databases can't exceed if
+// MaxDecompressedSize is not set and the size of their archive is unknown,
+// e.g., for resumed downloads and chunked responses. It is larger than any
+// MaxMind database.
+const defaultMaxDecompressedSize = 4 << 30
+
+// decompressedSizeError is returned when a database is larger than the
+// maximum decompressed size.
+type decompressedSizeError struct {
+	limit int64
+}
+
+func (e *decompressedSizeError) Error() string {
+	return fmt.Sprintf("the database is larger than the maximum decompressed size of %d bytes", e.limit)
+}
+
+// maxDecompressedSize returns the size the database of res can't exceed.
+func (u *Updater) maxDecompressedSize(res client.DownloadResponse) int64 {
+	if u.config.MaxDecompressedSize > 0 {
+		return u.config.MaxDecompressedSize
+	}
+	if res.ArchiveSize > 0 {
+		return res.ArchiveSize * defaultDecompressionRatio
+	}
+	return defaultMaxDecompressedSize
+}
+
+// sizeLimitReader fails with a *decompressedSizeError once more than limit
+// bytes are read from the wrapped reader.
+type sizeLimitReader struct {
+	io.ReadCloser
+	limit int64
+	n     int64
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	// Reading one byte past the limit is enough to tell it is exceeded.
+	if left := r.limit - r.n + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if r.n > r.limit {
+		return n - int(r.n-r.limit), &decompressedSizeError{limit: r.limit}
+	}
+	return n, err
+}
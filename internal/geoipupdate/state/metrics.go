@@ -0,0 +1,225 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metric is a per-edition gauge of the metrics file.
+type metric struct {
+	name string
+	help string
+	// value returns the value of the gauge for an edition, and false if
+	// it is unknown.
+	value func(Edition) (float64, bool)
+	// label, if set, is the name of a label whose values are the keys of
+	// the map returned by values, which is used instead of value.
+	label  string
+	values func(Edition) map[string]float64
+}
+
+// configInfoMetric is the gauge whose config_hash label identifies the
+// effective configuration.
+const configInfoMetric = "geoipupdate_config_info"
+
+// metrics are the gauges written by WriteMetrics.
+var metrics = []metric{
+	{
+		name:  "geoipupdate_edition_build_timestamp_seconds",
+		help:  "When the installed database was published upstream.",
+		value: func(e Edition) (float64, bool) { return timestamp(e.BuildDate) },
+	},
+	{
+		name:  "geoipupdate_edition_installed_timestamp_seconds",
+		help:  "When the installed database was written.",
+		value: func(e Edition) (float64, bool) { return timestamp(e.InstalledAt) },
+	},
+	{
+		name:  "geoipupdate_edition_last_success_timestamp_seconds",
+		help:  "When the edition was last updated successfully.",
+		value: func(e Edition) (float64, bool) { return timestamp(e.LastSuccess) },
+	},
+	{
+		name: "geoipupdate_edition_clock_skew_seconds",
+		help: "Difference between the clock of the server and the local clock as of the last successful update.",
+		value: func(e Edition) (float64, bool) {
+			return e.ClockSkew.Seconds(), e.ClockSkew != 0
+		},
+	},
+	{
+		name: "geoipupdate_edition_last_update_attempts",
+		help: "Number of attempts of the last update, successful or not.",
+		value: func(e Edition) (float64, bool) {
+			return float64(len(e.Attempts)), len(e.Attempts) > 0
+		},
+	},
+	{
+		name: "geoipupdate_edition_last_attempt_duration_seconds",
+		help: "How long the last attempt at updating the edition took.",
+		value: func(e Edition) (float64, bool) {
+			a, ok := lastAttempt(e)
+			return a.Duration.Seconds(), ok
+		},
+	},
+	{
+		name: "geoipupdate_edition_last_attempt_received_bytes",
+		help: "How many bytes of the database the last attempt at updating the edition received.",
+		value: func(e Edition) (float64, bool) {
+			a, ok := lastAttempt(e)
+			return float64(a.Bytes), ok
+		},
+	},
+	{
+		name: "geoipupdate_edition_last_attempt_status_code",
+		help: "HTTP status code of the last attempt at updating the edition.",
+		value: func(e Edition) (float64, bool) {
+			a, ok := lastAttempt(e)
+			return float64(a.StatusCode), ok && a.StatusCode != 0
+		},
+	},
+	{
+		name:  "geoipupdate_edition_last_update_failed_attempts",
+		help:  "Number of failed attempts of the last update, by reason.",
+		label: "reason",
+		values: func(e Edition) map[string]float64 {
+			reasons := map[string]float64{}
+			for _, a := range e.Attempts {
+				if a.Reason != "" {
+					reasons[a.Reason]++
+				}
+			}
+			return reasons
+		},
+	},
+	{
+		name: "geoipupdate_edition_propagation_lag_seconds",
+		help: "Time between the upstream publication of the installed database and its installation.",
+		value: func(e Edition) (float64, bool) {
+			if e.BuildDate.IsZero() || e.InstalledAt.IsZero() {
+				return 0, false
+			}
+			return e.PropagationLag().Seconds(), true
+		},
+	},
+	{
+		name:  "geoipupdate_edition_last_check_timestamp_seconds",
+		help:  "When a check-only run last compared the installed database with the latest build.",
+		value: func(e Edition) (float64, bool) { return timestamp(e.LastCheck) },
+	},
+	{
+		name: "geoipupdate_edition_outdated",
+		help: "Whether the installed database wasn't the latest build when last checked.",
+		value: func(e Edition) (float64, bool) {
+			var outdated float64
+			if e.Outdated {
+				outdated = 1
+			}
+			return outdated, !e.LastCheck.IsZero()
+		},
+	},
+}
+
+// lastAttempt returns the last attempt at updating e, and false if there is
+// none.
+func lastAttempt(e Edition) (Attempt, bool) {
+	if len(e.Attempts) == 0 {
+		return Attempt{}, false
+	}
+	return e.Attempts[len(e.Attempts)-1], true
+}
+
+func timestamp(t time.Time) (float64, bool) {
+	return float64(t.Unix()), !t.IsZero()
+}
+
+// WriteMetrics writes the state of editionIDs to path in the Prometheus
+// text exposition format, e.g., for the node exporter textfile collector.
+// Editions are left out of the gauges whose values are unknown. labels are
+// added to every sample, along with the edition ID. If configHash is set,
+// it is the config_hash label of a geoipupdate_config_info gauge.
+func (s *Store) WriteMetrics(
+	path string,
+	editionIDs []string,
+	labels map[string]string,
+	configHash string,
+) error {
+	s.mu.Lock()
+	editions := make([]Edition, len(editionIDs))
+	for i, editionID := range editionIDs {
+		editions[i] = s.state.Editions[editionID]
+	}
+	s.mu.Unlock()
+
+	var extraLabels strings.Builder
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(&extraLabels, ",%s=%q", name, labels[name])
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+		for i, edition := range editions {
+			if m.label != "" {
+				values := m.values(edition)
+				keys := make([]string, 0, len(values))
+				for key := range values {
+					keys = append(keys, key)
+				}
+				slices.Sort(keys)
+				for _, key := range keys {
+					fmt.Fprintf(
+						&buf,
+						"%s{edition_id=%q,%s=%q%s} %s\n",
+						m.name,
+						editionIDs[i],
+						m.label,
+						key,
+						extraLabels.String(),
+						strconv.FormatFloat(values[key], 'f', -1, 64),
+					)
+				}
+				continue
+			}
+
+			value, ok := m.value(edition)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(
+				&buf,
+				"%s{edition_id=%q%s} %s\n",
+				m.name,
+				editionIDs[i],
+				extraLabels.String(),
+				strconv.FormatFloat(value, 'f', -1, 64),
+			)
+		}
+	}
+
+	if configHash != "" {
+		fmt.Fprintf(
+			&buf,
+			"# HELP %s %s\n# TYPE %s gauge\n%s{config_hash=%q%s} 1\n",
+			configInfoMetric,
+			"Hash of the effective configuration of the last run.",
+			configInfoMetric,
+			configInfoMetric,
+			configHash,
+			extraLabels.String(),
+		)
+	}
+
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteMetrics tests that the metrics file holds the gauges of the
+// requested editions whose values are known.
+func TestWriteMetrics(t *testing.T) {
+	dir := t.TempDir()
+	s := New(filepath.Join(dir, ".geoipupdate.state"))
+
+	buildDate := time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC)
+	installedAt := buildDate.Add(90 * time.Minute)
+	require.NoError(t, s.Update("GeoIP2-City", func(e *Edition) {
+		e.BuildDate = buildDate
+		e.InstalledAt = installedAt
+		e.LastSuccess = installedAt
+		e.Attempts = []Attempt{
+			{StatusCode: 503, Duration: 2 * time.Second, Reason: "http_503"},
+			{StatusCode: 200, Duration: 1500 * time.Millisecond, Bytes: 1024},
+		}
+	}))
+	require.NoError(t, s.Update("GeoIP2-Country", func(e *Edition) {
+		e.LastSuccess = installedAt
+		e.ClockSkew = -2 * time.Hour
+		e.LastCheck = installedAt
+		e.Outdated = true
+	}))
+	require.NoError(t, s.Update("GeoIP2-ISP", func(e *Edition) {
+		e.LastSuccess = installedAt
+	}))
+
+	path := filepath.Join(dir, "geoipupdate.prom")
+	require.NoError(t, s.WriteMetrics(path, []string{"GeoIP2-City", "GeoIP2-Country"}, nil, ""))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `# HELP geoipupdate_edition_build_timestamp_seconds When the installed database was published upstream.
+# TYPE geoipupdate_edition_build_timestamp_seconds gauge
+geoipupdate_edition_build_timestamp_seconds{edition_id="GeoIP2-City"} 1708682400
+# HELP geoipupdate_edition_installed_timestamp_seconds When the installed database was written.
+# TYPE geoipupdate_edition_installed_timestamp_seconds gauge
+geoipupdate_edition_installed_timestamp_seconds{edition_id="GeoIP2-City"} 1708687800
+# HELP geoipupdate_edition_last_success_timestamp_seconds When the edition was last updated successfully.
+# TYPE geoipupdate_edition_last_success_timestamp_seconds gauge
+geoipupdate_edition_last_success_timestamp_seconds{edition_id="GeoIP2-City"} 1708687800
+geoipupdate_edition_last_success_timestamp_seconds{edition_id="GeoIP2-Country"} 1708687800
+# HELP geoipupdate_edition_clock_skew_seconds Difference between the clock of the server and the local clock as of the last successful update.
+# TYPE geoipupdate_edition_clock_skew_seconds gauge
+geoipupdate_edition_clock_skew_seconds{edition_id="GeoIP2-Country"} -7200
+# HELP geoipupdate_edition_last_update_attempts Number of attempts of the last update, successful or not.
+# TYPE geoipupdate_edition_last_update_attempts gauge
+geoipupdate_edition_last_update_attempts{edition_id="GeoIP2-City"} 2
+# HELP geoipupdate_edition_last_attempt_duration_seconds How long the last attempt at updating the edition took.
+# TYPE geoipupdate_edition_last_attempt_duration_seconds gauge
+geoipupdate_edition_last_attempt_duration_seconds{edition_id="GeoIP2-City"} 1.5
+# HELP geoipupdate_edition_last_attempt_received_bytes How many bytes of the database the last attempt at updating the edition received.
+# TYPE geoipupdate_edition_last_attempt_received_bytes gauge
+geoipupdate_edition_last_attempt_received_bytes{edition_id="GeoIP2-City"} 1024
+# HELP geoipupdate_edition_last_attempt_status_code HTTP status code of the last attempt at updating the edition.
+# TYPE geoipupdate_edition_last_attempt_status_code gauge
+geoipupdate_edition_last_attempt_status_code{edition_id="GeoIP2-City"} 200
+# HELP geoipupdate_edition_last_update_failed_attempts Number of failed attempts of the last update, by reason.
+# TYPE geoipupdate_edition_last_update_failed_attempts gauge
+geoipupdate_edition_last_update_failed_attempts{edition_id="GeoIP2-City",reason="http_503"} 1
+# HELP geoipupdate_edition_propagation_lag_seconds Time between the upstream publication of the installed database and its installation.
+# TYPE geoipupdate_edition_propagation_lag_seconds gauge
+geoipupdate_edition_propagation_lag_seconds{edition_id="GeoIP2-City"} 5400
+# HELP geoipupdate_edition_last_check_timestamp_seconds When a check-only run last compared the installed database with the latest build.
+# TYPE geoipupdate_edition_last_check_timestamp_seconds gauge
+geoipupdate_edition_last_check_timestamp_seconds{edition_id="GeoIP2-Country"} 1708687800
+# HELP geoipupdate_edition_outdated Whether the installed database wasn't the latest build when last checked.
+# TYPE geoipupdate_edition_outdated gauge
+geoipupdate_edition_outdated{edition_id="GeoIP2-Country"} 1
+`, string(content))
+
+	// Labels are sorted by name.
+	require.NoError(t, s.WriteMetrics(
+		path,
+		[]string{"GeoIP2-Country"},
+		map[string]string{"profile": "edge", "env": "prod"},
+		"5f70bf18",
+	))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(
+		t,
+		string(content),
+		`geoipupdate_edition_last_success_timestamp_seconds{edition_id="GeoIP2-Country",env="prod",profile="edge"} 1708687800`,
+	)
+	require.Contains(
+		t,
+		string(content),
+		`geoipupdate_config_info{config_hash="5f70bf18",env="prod",profile="edge"} 1`,
+	)
+}
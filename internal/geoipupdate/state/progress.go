@@ -0,0 +1,99 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Progress describes the run currently holding the lock file. It is
+// written next to the lock file so that a concurrent invocation can report
+// what it is waiting for.
+type Progress struct {
+	// PID is the process ID of the running instance.
+	PID int `json:"pid"`
+	// StartedAt is when the running instance acquired the lock.
+	StartedAt time.Time `json:"started_at"`
+	// Editions are the editions the running instance is updating.
+	Editions []string `json:"editions"`
+	// Completed are the editions the running instance has finished.
+	Completed []string `json:"completed"`
+}
+
+// ProgressWriter keeps a progress file up to date. It is safe for
+// concurrent use.
+type ProgressWriter struct {
+	mu       sync.Mutex
+	path     string
+	progress Progress
+}
+
+// NewProgressWriter writes the initial progress of a run updating
+// editionIDs to path.
+func NewProgressWriter(path string, editionIDs []string) (*ProgressWriter, error) {
+	w := &ProgressWriter{
+		path: path,
+		progress: Progress{
+			PID:       os.Getpid(),
+			StartedAt: time.Now().In(time.UTC),
+			Editions:  editionIDs,
+			Completed: []string{},
+		},
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.save(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Complete records that editionID has been processed.
+func (w *ProgressWriter) Complete(editionID string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.progress.Completed = append(w.progress.Completed, editionID)
+	return w.save()
+}
+
+// Remove removes the progress file once the run is over.
+func (w *ProgressWriter) Remove() error {
+	if err := os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing progress file: %w", err)
+	}
+	return nil
+}
+
+// save persists the progress. The caller must hold the lock.
+func (w *ProgressWriter) save() error {
+	data, err := json.Marshal(w.progress)
+	if err != nil {
+		return fmt.Errorf("encoding progress: %w", err)
+	}
+
+	if err := writeFile(w.path, data); err != nil {
+		return fmt.Errorf("saving progress file: %w", err)
+	}
+	return nil
+}
+
+// ReadProgress reads the progress file at path.
+func ReadProgress(path string) (Progress, error) {
+	var p Progress
+
+	//nolint:gosec // we really need to read this file.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("reading progress file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("parsing progress file %s: %w", path, err)
+	}
+	return p, nil
+}
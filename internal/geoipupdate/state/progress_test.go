@@ -0,0 +1,35 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestProgressWriter tests that the progress written by a run can be read
+// by another one and is removed once the run is over.
+func TestProgressWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".geoipupdate.lock.progress")
+
+	w, err := NewProgressWriter(path, []string{"GeoIP2-City", "GeoIP2-Country"})
+	require.NoError(t, err)
+
+	p, err := ReadProgress(path)
+	require.NoError(t, err)
+	require.Equal(t, os.Getpid(), p.PID)
+	require.False(t, p.StartedAt.IsZero())
+	require.Equal(t, []string{"GeoIP2-City", "GeoIP2-Country"}, p.Editions)
+	require.Empty(t, p.Completed)
+
+	require.NoError(t, w.Complete("GeoIP2-Country"))
+
+	p, err = ReadProgress(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"GeoIP2-Country"}, p.Completed)
+
+	require.NoError(t, w.Remove())
+	_, err = ReadProgress(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
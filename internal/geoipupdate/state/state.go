@@ -0,0 +1,270 @@
+// Package state persists information about geoipupdate runs across
+// invocations.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// State is the persisted state of geoipupdate.
+type State struct {
+	// Editions holds the state of each edition that has been processed,
+	// keyed by edition ID.
+	Editions map[string]Edition `json:"editions"`
+}
+
+// Edition is the persisted state of an edition.
+type Edition struct {
+	// Pending is true from the moment an update of the edition starts
+	// until it completes successfully. An edition that is still pending
+	// at the start of a run was not updated by a previous run, e.g.,
+	// because that run failed or was interrupted.
+	Pending bool `json:"pending"`
+	// Hash is the MD5 of the database as of the last successful update.
+	Hash string `json:"hash,omitempty"`
+	// LastAttempt is when an update of the edition last started.
+	LastAttempt time.Time `json:"last_attempt"`
+	// LastSuccess is when the edition was last updated successfully.
+	LastSuccess time.Time `json:"last_success"`
+	// ClockSkew is the difference between the clock of the server and the
+	// local clock as of the last successful update, if known.
+	ClockSkew time.Duration `json:"clock_skew,omitempty"`
+	// BuildDate is when the installed database was published upstream.
+	BuildDate time.Time `json:"build_date"`
+	// LastModified is the Last-Modified time of the download of the
+	// installed database, which may differ from BuildDate, e.g., when
+	// downloaded from a mirror.
+	LastModified time.Time `json:"last_modified"`
+	// InstalledAt is when the installed database was written.
+	InstalledAt time.Time `json:"installed_at"`
+	// AnnouncePending is true from the moment the edition is updated until
+	// the update has been announced to every Notify target.
+	AnnouncePending bool `json:"announce_pending,omitempty"`
+	// AnnouncedHash is the MD5 of the database last announced.
+	AnnouncedHash string `json:"announced_hash,omitempty"`
+	// PostUpdatePending is true from the moment the edition is updated until
+	// the PostUpdateCommand succeeds for it.
+	PostUpdatePending bool `json:"post_update_pending,omitempty"`
+	// ConsecutiveFailures is the number of runs in a row that failed to
+	// update the edition, and LastError the error of the last one.
+	// FailingSince is when the last series of failures started, and is
+	// kept once the edition is updated again.
+	ConsecutiveFailures int       `json:"consecutive_failures,omitempty"`
+	FailingSince        time.Time `json:"failing_since"`
+	LastError           string    `json:"last_error,omitempty"`
+	// Alerted is true from the moment the failures of the edition have
+	// been announced until its recovery has.
+	Alerted bool `json:"alerted,omitempty"`
+	// LastCheck is when a check-only run last compared the installed
+	// database with the latest build, whose MD5 is LatestHash. Outdated is
+	// true if they differed, and OutdatedAnnounced if that was announced.
+	LastCheck         time.Time `json:"last_check"`
+	LatestHash        string    `json:"latest_hash,omitempty"`
+	Outdated          bool      `json:"outdated,omitempty"`
+	OutdatedAnnounced bool      `json:"outdated_announced,omitempty"`
+	// Attempts are those of the last update of the edition, successful or
+	// not, in order.
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// History are the last HistoryLength changes of the installed database,
+	// oldest first.
+	History []Change `json:"history,omitempty"`
+	// FileHash caches the hashes of the installed database, so that its
+	// MD5 sum isn't computed again while it doesn't change.
+	FileHash *FileHash `json:"file_hash,omitempty"`
+}
+
+// FileHash is the MD5 sum and the fast checksum of a database whose file
+// had Size and ModTime when they were computed.
+type FileHash struct {
+	MD5      string    `json:"md5"`
+	Checksum string    `json:"checksum,omitempty"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+}
+
+// HistoryLength is the number of changes kept in the History of an
+// edition.
+const HistoryLength = 50
+
+// Change describes an update of an edition to a new database.
+type Change struct {
+	// Date is when the new database was installed.
+	Date time.Time `json:"date"`
+	// BuildDate is when the new database was published upstream.
+	BuildDate time.Time `json:"build_date"`
+	// OldHash and NewHash are the MD5 sums of the replaced and new
+	// databases. OldHash is empty if there was no database.
+	OldHash string `json:"old_hash,omitempty"`
+	NewHash string `json:"new_hash"`
+	// Size is the size of the new database.
+	Size int64 `json:"size"`
+	// Duration is how long the update took, including failed attempts.
+	Duration time.Duration `json:"duration"`
+	// Host is where the new database was downloaded from, e.g.,
+	// updates.maxmind.com, or peers.
+	Host string `json:"host,omitempty"`
+}
+
+// AddChange adds c to the History of e, dropping the oldest changes beyond
+// HistoryLength.
+func (e *Edition) AddChange(c Change) {
+	e.History = append(e.History, c)
+	if len(e.History) > HistoryLength {
+		e.History = slices.Clone(e.History[len(e.History)-HistoryLength:])
+	}
+}
+
+// Attempt describes an attempt at downloading and installing an edition.
+type Attempt struct {
+	// StatusCode is the HTTP status code of the response, if known.
+	StatusCode int `json:"status_code,omitempty"`
+	// Duration is how long the attempt took.
+	Duration time.Duration `json:"duration"`
+	// Bytes is how many bytes of the database were received.
+	Bytes int64 `json:"bytes"`
+	// Reason is why the attempt failed, e.g., http_503, proxy, timeout,
+	// network, read, or write. It is empty if the attempt succeeded.
+	Reason string `json:"reason,omitempty"`
+}
+
+// PropagationLag returns the time it took for the installed database to be
+// installed once published upstream. It is 0 if either time is unknown.
+func (e Edition) PropagationLag() time.Duration {
+	if e.BuildDate.IsZero() || e.InstalledAt.IsZero() {
+		return 0
+	}
+	return e.InstalledAt.Sub(e.BuildDate)
+}
+
+// Store reads and writes State to a file. It is safe for concurrent use.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	state State
+}
+
+// Open opens the Store at path. A missing file results in an empty State.
+func Open(path string) (*Store, error) {
+	s := New(path)
+
+	//nolint:gosec // we really need to read this file.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("parsing state file %s: %w", path, err)
+	}
+	if s.state.Editions == nil {
+		s.state.Editions = map[string]Edition{}
+	}
+
+	return s, nil
+}
+
+// New returns a Store at path with an empty State, disregarding any
+// existing content of the file. If path is empty, the State is only kept
+// in memory.
+func New(path string) *Store {
+	return &Store{
+		path:  path,
+		state: State{Editions: map[string]Edition{}},
+	}
+}
+
+// Edition returns the state of an edition. The zero value is returned for
+// editions that have never been processed.
+func (s *Store) Edition(editionID string) Edition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.Editions[editionID]
+}
+
+// EditionIDs returns the IDs of the editions that have been processed,
+// sorted.
+func (s *Store) EditionIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	editionIDs := make([]string, 0, len(s.state.Editions))
+	for editionID := range s.state.Editions {
+		editionIDs = append(editionIDs, editionID)
+	}
+	slices.Sort(editionIDs)
+	return editionIDs
+}
+
+// Update applies f to the state of an edition and persists the result.
+func (s *Store) Update(editionID string, f func(*Edition)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	edition := s.state.Editions[editionID]
+	f(&edition)
+	s.state.Editions[editionID] = edition
+
+	return s.save()
+}
+
+// save persists the state. The caller must hold the lock.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	if err := writeFile(s.path, data); err != nil {
+		return fmt.Errorf("saving state file: %w", err)
+	}
+	return nil
+}
+
+// writeFile writes data to a temporary file and renames it to path so that
+// path is never left partially written.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	tempPath := path + ".temporary"
+	//nolint:gosec // these files aren't sensitive.
+	f, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("moving %s into place: %w", path, err)
+	}
+
+	return nil
+}
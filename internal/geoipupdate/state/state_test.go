@@ -0,0 +1,75 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStore tests that edition state is persisted across stores.
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".geoipupdate.state")
+
+	s, err := Open(path)
+	require.NoError(t, err)
+	require.Equal(t, Edition{}, s.Edition("GeoIP2-City"))
+
+	now := time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC)
+	err = s.Update("GeoIP2-City", func(e *Edition) {
+		e.Pending = true
+		e.LastAttempt = now
+	})
+	require.NoError(t, err)
+
+	s, err = Open(path)
+	require.NoError(t, err)
+	require.Equal(t, Edition{Pending: true, LastAttempt: now}, s.Edition("GeoIP2-City"))
+	require.Equal(t, []string{"GeoIP2-City"}, s.EditionIDs())
+
+	err = s.Update("GeoIP2-City", func(e *Edition) {
+		e.Pending = false
+		e.Hash = "cfa36ddc8279b5483a5aa25e9a6151f4"
+		e.LastSuccess = now
+	})
+	require.NoError(t, err)
+
+	s, err = Open(path)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		Edition{
+			Hash:        "cfa36ddc8279b5483a5aa25e9a6151f4",
+			LastAttempt: now,
+			LastSuccess: now,
+		},
+		s.Edition("GeoIP2-City"),
+	)
+
+	_, err = os.Stat(path + ".temporary")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestOpenCorrupt tests that a corrupt state file results in an error.
+func TestOpenCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".geoipupdate.state")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := Open(path)
+	require.ErrorContains(t, err, "parsing state file")
+}
+
+// TestEditionAddChange tests that only the last HistoryLength changes are
+// kept.
+func TestEditionAddChange(t *testing.T) {
+	var e Edition
+	start := time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < HistoryLength+2; i++ {
+		e.AddChange(Change{Date: start.AddDate(0, 0, 7*i)})
+	}
+	require.Len(t, e.History, HistoryLength)
+	require.Equal(t, start.AddDate(0, 0, 14), e.History[0].Date)
+	require.Equal(t, start.AddDate(0, 0, 7*(HistoryLength+1)), e.History[HistoryLength-1].Date)
+}
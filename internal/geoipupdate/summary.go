@@ -0,0 +1,71 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// runSummary sums up the editions of a run. Each edition of the run is
+// either checked, skipped or failed.
+type runSummary struct {
+	// Checked is the number of editions checked for updates, including
+	// the Updated ones.
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	// Skipped is the number of editions that weren't checked, e.g.,
+	// because of CacheMaxAge, or because the run was canceled first.
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+	// Bytes is the total size of the downloads of the run, including
+	// failed ones.
+	Bytes           int64         `json:"bytes"`
+	Duration        time.Duration `json:"-"`
+	DurationSeconds float64       `json:"duration_seconds"`
+}
+
+// newRunSummary returns the summary of a run of editionIDs that completed
+// editions, while failed editions failed, and that downloaded bytes.
+func newRunSummary(
+	editionIDs []string,
+	editions []database.ReadResult,
+	failed int,
+	bytes int64,
+) *runSummary {
+	s := &runSummary{Failed: failed, Bytes: bytes}
+	for _, edition := range editions {
+		if edition.Cached {
+			continue
+		}
+		s.Checked++
+		if edition.NewHash != edition.OldHash && !edition.Unavailable {
+			s.Updated++
+		}
+	}
+	s.Skipped = len(editionIDs) - s.Checked - s.Failed
+	return s
+}
+
+// finish sets the duration of a run that started at start.
+func (s *runSummary) finish(start time.Time) {
+	s.Duration = time.Since(start)
+	s.DurationSeconds = s.Duration.Seconds()
+}
+
+func (s *runSummary) String() string {
+	return fmt.Sprintf(
+		"%d editions checked, %d updated, %d skipped, %d failed, %d bytes downloaded in %s",
+		s.Checked,
+		s.Updated,
+		s.Skipped,
+		s.Failed,
+		s.Bytes,
+		s.Duration.Round(time.Millisecond),
+	)
+}
+
+// summaryRecord is the last record of the stream output format.
+type summaryRecord struct {
+	Summary *runSummary `json:"summary"`
+}
@@ -0,0 +1,43 @@
+package geoipupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+func TestRunSummary(t *testing.T) {
+	editionIDs := []string{
+		"GeoIP2-City",
+		"GeoIP2-Country",
+		"GeoIP2-ISP",
+		"GeoIP2-ASN",
+		"GeoIP2-Domain",
+		"GeoIP2-Anonymous-IP",
+	}
+	editions := []database.ReadResult{
+		{EditionID: "GeoIP2-City", OldHash: "a", NewHash: "b"},
+		{EditionID: "GeoIP2-Country", OldHash: "c", NewHash: "c"},
+		{EditionID: "GeoIP2-ISP", OldHash: "d", NewHash: "d", Cached: true},
+		{EditionID: "GeoIP2-ASN", OldHash: "e", NewHash: database.ZeroMD5, Unavailable: true},
+	}
+
+	s := newRunSummary(editionIDs, editions, 1, 1234)
+	s.Duration = 1500 * time.Millisecond
+	assert.Equal(t, &runSummary{
+		Checked:  3,
+		Updated:  1,
+		Skipped:  2,
+		Failed:   1,
+		Bytes:    1234,
+		Duration: 1500 * time.Millisecond,
+	}, s)
+	assert.Equal(
+		t,
+		"3 editions checked, 1 updated, 2 skipped, 1 failed, 1234 bytes downloaded in 1.5s",
+		s.String(),
+	)
+}
@@ -0,0 +1,24 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"os"
+)
+
+// traceFile appends the trace events of the requests to the TraceFile. It
+// is opened for each event, as the updater has no end of life, so that the
+// file may be rotated between runs of the daemon.
+type traceFile string
+
+func (f traceFile) Write(p []byte) (int, error) {
+	//nolint:gosec // the path is given by the user.
+	file, err := os.OpenFile(string(f), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("opening trace file: %w", err)
+	}
+	n, err := file.Write(p)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
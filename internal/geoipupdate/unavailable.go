@@ -0,0 +1,130 @@
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// The values of UnavailableEditionPolicy.
+const (
+	// UnavailableEditionKeep keeps the databases of unavailable editions in
+	// place. It is the default.
+	UnavailableEditionKeep = "keep"
+	// UnavailableEditionDelete deletes them.
+	UnavailableEditionDelete = "delete"
+	// UnavailableEditionQuarantine renames them with the quarantineSuffix so
+	// that they are no longer used but can still be recovered.
+	UnavailableEditionQuarantine = "quarantine"
+)
+
+// quarantineSuffix is appended to the file names of the quarantined
+// databases, e.g., GeoIP2-ISP.mmdb.unavailable.
+const quarantineSuffix = ".unavailable"
+
+// WarningEditionUnavailable is raised when an edition is no longer
+// available to the account, e.g., because it was removed from it.
+const WarningEditionUnavailable = "edition-unavailable"
+
+// validateUnavailableEditionPolicy checks that value, the value of the
+// setting name, is a known policy.
+func validateUnavailableEditionPolicy(name, value string) error {
+	switch value {
+	case UnavailableEditionKeep, UnavailableEditionDelete, UnavailableEditionQuarantine:
+		return nil
+	default:
+		return fmt.Errorf(
+			"`%s' must be %s, %s or %s, got '%s'",
+			name,
+			UnavailableEditionKeep,
+			UnavailableEditionDelete,
+			UnavailableEditionQuarantine,
+			value,
+		)
+	}
+}
+
+// unavailableEdition handles editionID being no longer available to the
+// account, as reported by cause: its database is handled according to
+// UnavailableEditionPolicy and a warning is raised, rather than failing the
+// run. It returns the result of the edition.
+func (u *Updater) unavailableEdition(
+	ctx context.Context,
+	store *state.Store,
+	editionID string,
+	attempts []state.Attempt,
+	cause error,
+) (*database.ReadResult, error) {
+	hash, err := u.writer.GetHash(ctx, editionID)
+	if err != nil {
+		return nil, fmt.Errorf("getting current hash of %s: %w", editionID, err)
+	}
+
+	path := database.FilePath(u.databaseDir(), editionID)
+	newHash := hash
+	message := fmt.Sprintf("%s is no longer available to the account", editionID)
+	if hash != database.ZeroMD5 {
+		switch u.config.UnavailableEditionPolicy {
+		case UnavailableEditionDelete:
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("deleting the database of %s: %w", editionID, err)
+			}
+			newHash = database.ZeroMD5
+			message += fmt.Sprintf("; %s was deleted", path)
+		case UnavailableEditionQuarantine:
+			if err := os.Rename(path, path+quarantineSuffix); err != nil {
+				return nil, fmt.Errorf("quarantining the database of %s: %w", editionID, err)
+			}
+			newHash = database.ZeroMD5
+			message += fmt.Sprintf("; %s was moved to %s", path, path+quarantineSuffix)
+		default:
+			message += fmt.Sprintf("; %s is no longer updated", path)
+		}
+	}
+	u.warn(Warning{Code: WarningEditionUnavailable, Message: message, EditionID: editionID})
+
+	checkedAt := u.config.getClock().Now().In(time.UTC)
+	err = store.Update(editionID, func(e *state.Edition) {
+		e.Pending = false
+		e.Attempts = attempts
+		e.LastError = cause.Error()
+		if newHash != hash {
+			e.Hash = ""
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("updating state of %s: %w", editionID, err)
+	}
+
+	return &database.ReadResult{
+		EditionID:   editionID,
+		OldHash:     hash,
+		NewHash:     newHash,
+		CheckedAt:   checkedAt,
+		Unavailable: true,
+	}, nil
+}
+
+// availableEditionIDs returns the editionIDs whose databases are still
+// installed, leaving out those of the unavailable editions of results that
+// were deleted or quarantined.
+func availableEditionIDs(editionIDs []string, results []database.ReadResult) []string {
+	removed := map[string]bool{}
+	for _, r := range results {
+		if r.Unavailable && r.NewHash == database.ZeroMD5 {
+			removed[r.EditionID] = true
+		}
+	}
+	var ids []string
+	for _, id := range editionIDs {
+		if !removed[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,107 @@
+package geoipupdate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/state"
+)
+
+// TestUpdaterUnavailableEdition tests that editions that are no longer
+// available to the account are reported as such, and their databases
+// handled according to UnavailableEditionPolicy, without failing the run.
+func TestUpdaterUnavailableEdition(t *testing.T) {
+	tests := []struct {
+		policy    string
+		kept      bool
+		newHash   string
+		remaining string
+	}{
+		{policy: "", kept: true, newHash: "A"},
+		{policy: UnavailableEditionKeep, kept: true, newHash: "A"},
+		{policy: UnavailableEditionDelete, newHash: database.ZeroMD5},
+		{
+			policy:    UnavailableEditionQuarantine,
+			newHash:   database.ZeroMD5,
+			remaining: "GeoIP2-ISP.mmdb" + quarantineSuffix,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "GeoIP2-ISP.mmdb")
+			require.NoError(t, os.WriteFile(path, []byte("database"), 0o600))
+
+			config := &Config{
+				DatabaseDirectory:        tempDir,
+				EditionIDs:               []string{"GeoIP2-ISP", "GeoLite2-City"},
+				LockFile:                 filepath.Join(tempDir, ".geoipupdate.lock"),
+				Parallelism:              1,
+				RetryFor:                 time.Minute,
+				StateFile:                filepath.Join(tempDir, ".geoipupdate.state"),
+				UnavailableEditionPolicy: test.policy,
+			}
+			downloads := 0
+			u := &Updater{
+				config: config,
+				updateClient: updateClientFunc(func(
+					_ context.Context,
+					editionID,
+					_ string,
+				) (client.DownloadResponse, error) {
+					if editionID == "GeoIP2-ISP" {
+						downloads++
+						return client.DownloadResponse{}, fmt.Errorf(
+							"response does not contain edition %s: %w",
+							editionID,
+							client.ErrEditionUnavailable,
+						)
+					}
+					return client.DownloadResponse{Reader: io.NopCloser(strings.NewReader(""))}, nil
+				}),
+				writer: &mockWriter{md5s: map[string]string{"GeoIP2-ISP": "A", "GeoLite2-City": "C"}},
+			}
+
+			editions, err := u.RunEditions(context.Background())
+			require.NoError(t, err)
+			require.Len(t, editions, 2)
+			assert.Equal(t, "GeoIP2-ISP", editions[0].EditionID)
+			assert.True(t, editions[0].Unavailable)
+			assert.Equal(t, "A", editions[0].OldHash)
+			assert.Equal(t, test.newHash, editions[0].NewHash)
+			assert.False(t, editions[1].Unavailable)
+			// The error isn't retried.
+			assert.Equal(t, 1, downloads)
+
+			warnings := u.Warnings()
+			require.Len(t, warnings, 1)
+			assert.Equal(t, WarningEditionUnavailable, warnings[0].Code)
+			assert.Equal(t, "GeoIP2-ISP", warnings[0].EditionID)
+
+			_, err = os.Stat(path)
+			assert.Equal(t, test.kept, err == nil)
+			if test.remaining != "" {
+				assert.FileExists(t, filepath.Join(tempDir, test.remaining))
+			}
+
+			store, err := state.Open(config.StateFile)
+			require.NoError(t, err)
+			e := store.Edition("GeoIP2-ISP")
+			assert.False(t, e.Pending)
+			assert.Zero(t, e.ConsecutiveFailures)
+			assert.Contains(t, e.LastError, "not available")
+		})
+	}
+}
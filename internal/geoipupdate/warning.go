@@ -0,0 +1,107 @@
+package geoipupdate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Codes of the warnings of runs. Those of LintConfig are documented with
+// the config validate command.
+const (
+	// WarningClockSkew is raised when the local clock is off by more than
+	// maxClockSkew.
+	WarningClockSkew = "clock-skew"
+	// WarningCadenceAnomaly is raised when an edition has no build as
+	// recent as its ExpectedCadence implies.
+	WarningCadenceAnomaly = "cadence-anomaly"
+	// WarningDeprecatedOption is raised for deprecated settings in the
+	// configuration file.
+	WarningDeprecatedOption = "deprecated-option"
+	// WarningPostUpdateCommand is raised when the PostUpdateCommand fails
+	// for an updated edition.
+	WarningPostUpdateCommand = "post-update-command-failed"
+	// WarningSelfCheck is raised when the IP address of the SelfCheckIP
+	// can't be resolved, or looked up in an updated edition.
+	WarningSelfCheck = "self-check-failed"
+	// WarningStaleEdition is raised when the installed build of an edition
+	// is older than staleEditionAge and no newer build is available
+	// upstream.
+	WarningStaleEdition = "stale-edition"
+	// WarningUnmatchedPattern is raised when a pattern of EditionPatterns
+	// matches no available edition.
+	WarningUnmatchedPattern = "unmatched-edition-pattern"
+)
+
+// staleEditionAge is the age of the installed build of an edition beyond
+// which it is reported as stale when no newer build is available. Databases
+// are published at least weekly.
+const staleEditionAge = 14 * 24 * time.Hour
+
+// Warning describes something that may need attention without being an
+// error, e.g., a risky setting or a wrong local clock, so that automation
+// can treat it differently.
+type Warning struct {
+	// Code identifies the kind of warning.
+	Code string `json:"code"`
+	// Message describes the warning.
+	Message string `json:"message"`
+	// EditionID is the edition the warning is about, if any.
+	EditionID string `json:"edition_id,omitempty"`
+}
+
+// warningList collects the warnings of a run. It is safe for concurrent
+// use.
+type warningList struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// add adds w to the list.
+func (l *warningList) add(w Warning) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, w)
+}
+
+// list returns the warnings added so far.
+func (l *warningList) list() []Warning {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Warning{}, l.warnings...)
+}
+
+// warn logs the warning w and records it as a warning of the current run.
+func (u *Updater) warn(w Warning) {
+	u.logf("Warning [%s]: %s", w.Code, w.Message)
+	u.warnings.add(w)
+}
+
+// Warnings returns the warnings of the last run, in the order they were
+// raised.
+func (u *Updater) Warnings() []Warning {
+	if u.warnings == nil {
+		return []Warning{}
+	}
+	return u.warnings.list()
+}
+
+// warnStaleEdition raises a WarningStaleEdition if the installed build of
+// editionID, published at buildDate, was older than staleEditionAge at now
+// while no newer build was available. Editions with an ExpectedCadence are
+// checked by warnCadence instead.
+func (u *Updater) warnStaleEdition(editionID string, buildDate, now time.Time) {
+	if buildDate.IsZero() || now.Sub(buildDate) <= staleEditionAge {
+		return
+	}
+	u.warn(Warning{
+		Code:      WarningStaleEdition,
+		EditionID: editionID,
+		Message: fmt.Sprintf(
+			"the installed build of %s was published %d days ago, at %s, and no newer build is available",
+			editionID,
+			int(now.Sub(buildDate)/(24*time.Hour)),
+			buildDate.In(time.UTC).Format(time.RFC3339),
+		),
+	})
+}
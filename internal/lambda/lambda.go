@@ -0,0 +1,149 @@
+// Package lambda runs a handler as an AWS Lambda function with a custom
+// runtime, e.g., provided.al2023, using the Lambda runtime API, so that
+// geoipupdate doesn't depend on the AWS SDK.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// RuntimeAPIEnv is the environment variable holding the host and port of
+// the runtime API, set by Lambda for custom runtimes.
+const RuntimeAPIEnv = "AWS_LAMBDA_RUNTIME_API"
+
+// maxEventSize is the size beyond which events are rejected. Lambda limits
+// synchronous invocations to 6MB.
+const maxEventSize = 6 << 20
+
+// Handler handles the invocation of the function with event, the JSON
+// payload of the invocation, and returns the value to respond with, which
+// is encoded in JSON. ctx is canceled at the deadline of the invocation.
+type Handler func(ctx context.Context, event json.RawMessage) (any, error)
+
+// Runtime gets the invocations of the function from the runtime API and
+// posts their responses.
+type Runtime struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewRuntime creates a Runtime using the runtime API at api, the value of
+// RuntimeAPIEnv.
+func NewRuntime(api string, httpClient *http.Client) *Runtime {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Runtime{
+		baseURL:    "http://" + api + "/2018-06-01/runtime",
+		httpClient: httpClient,
+	}
+}
+
+// InitError reports that the function failed to initialize, e.g., because
+// its configuration is invalid. Lambda then fails the invocation and starts
+// over with a new environment.
+func (r *Runtime) InitError(ctx context.Context, initErr error) error {
+	return r.postError(ctx, r.baseURL+"/init/error", initErr)
+}
+
+// Serve handles invocations with h, one at a time, until ctx is canceled
+// or the runtime API fails. Lambda freezes the process between
+// invocations, so what is initialized before Serve is reused by the
+// invocations of the same environment.
+func (r *Runtime) Serve(ctx context.Context, h Handler) error {
+	for {
+		if err := r.handleNext(ctx, h); err != nil {
+			return err
+		}
+	}
+}
+
+// handleNext waits for the next invocation and handles it with h.
+func (r *Runtime) handleNext(ctx context.Context, h Handler) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/invocation/next", nil)
+	if err != nil {
+		return fmt.Errorf("creating invocation request: %w", err)
+	}
+	response, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("getting next invocation: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting next invocation: unexpected HTTP status code %d", response.StatusCode)
+	}
+	requestID := response.Header.Get("Lambda-Runtime-Aws-Request-Id")
+	if requestID == "" {
+		return errors.New("getting next invocation: no request ID")
+	}
+	invocationURL := r.baseURL + "/invocation/" + requestID
+
+	event, err := io.ReadAll(io.LimitReader(response.Body, maxEventSize))
+	if err != nil {
+		return r.postError(ctx, invocationURL+"/error", fmt.Errorf("reading event: %w", err))
+	}
+
+	invocationCtx := ctx
+	if ms, err := strconv.ParseInt(response.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+		var cancel context.CancelFunc
+		invocationCtx, cancel = context.WithDeadline(ctx, time.UnixMilli(ms))
+		defer cancel()
+	}
+
+	result, err := h(invocationCtx, event)
+	if err != nil {
+		return r.postError(ctx, invocationURL+"/error", err)
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		return r.postError(ctx, invocationURL+"/error", fmt.Errorf("encoding response: %w", err))
+	}
+	return r.post(ctx, invocationURL+"/response", body, "")
+}
+
+// postError reports err to the runtime API at postURL.
+func (r *Runtime) postError(ctx context.Context, postURL string, err error) error {
+	body, jsonErr := json.Marshal(struct {
+		ErrorMessage string `json:"errorMessage"`
+		ErrorType    string `json:"errorType"`
+	}{
+		ErrorMessage: err.Error(),
+		ErrorType:    "geoipupdate.Error",
+	})
+	if jsonErr != nil {
+		return fmt.Errorf("encoding error: %w", jsonErr)
+	}
+	return r.post(ctx, postURL, body, "geoipupdate.Error")
+}
+
+// post posts body to the runtime API at postURL.
+func (r *Runtime) post(ctx context.Context, postURL string, body []byte, errorType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, postURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating runtime API request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "geoipupdate/"+vars.Version)
+	if errorType != "" {
+		req.Header.Set("Lambda-Runtime-Function-Error-Type", errorType)
+	}
+	response, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to the runtime API: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("posting to the runtime API: unexpected HTTP status code %d", response.StatusCode)
+	}
+	return nil
+}
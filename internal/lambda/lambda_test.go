@@ -0,0 +1,100 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRuntimeAPI serves invocations of events and records the responses
+// posted to it, by request ID.
+type testRuntimeAPI struct {
+	mu        sync.Mutex
+	events    []string
+	deadline  time.Time
+	responses map[string]string
+	errors    map[string]string
+	done      chan struct{}
+}
+
+func (a *testRuntimeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/2018-06-01/runtime")
+	switch {
+	case r.Method == http.MethodGet && path == "/invocation/next":
+		if len(a.events) == 0 {
+			close(a.done)
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		id := strconv.Itoa(len(a.responses) + len(a.errors) + 1)
+		w.Header().Set("Lambda-Runtime-Aws-Request-Id", id)
+		w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(a.deadline.UnixMilli(), 10))
+		_, _ = io.WriteString(w, a.events[0])
+		a.events = a.events[1:]
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/invocation/"):
+		id, kind, _ := strings.Cut(strings.TrimPrefix(path, "/invocation/"), "/")
+		body, _ := io.ReadAll(r.Body)
+		if kind == "error" {
+			a.errors[id] = string(body)
+		} else {
+			a.responses[id] = string(body)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && path == "/init/error":
+		body, _ := io.ReadAll(r.Body)
+		a.errors["init"] = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRuntimeServe(t *testing.T) {
+	api := &testRuntimeAPI{
+		events:    []string{`{"n":1}`, `{"fail":true}`},
+		deadline:  time.Now().Add(time.Hour).Truncate(time.Millisecond),
+		responses: map[string]string{},
+		errors:    map[string]string{},
+		done:      make(chan struct{}),
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	r := NewRuntime(strings.TrimPrefix(server.URL, "http://"), nil)
+	err := r.Serve(context.Background(), func(ctx context.Context, event json.RawMessage) (any, error) {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.Equal(t, api.deadline, deadline)
+
+		if strings.Contains(string(event), "fail") {
+			return nil, errors.New("update failed")
+		}
+		return map[string]json.RawMessage{"event": event}, nil
+	})
+	require.ErrorContains(t, err, "unexpected HTTP status code 410")
+	<-api.done
+
+	assert.Equal(t, map[string]string{"1": `{"event":{"n":1}}`}, api.responses)
+	assert.JSONEq(
+		t,
+		`{"errorMessage":"update failed","errorType":"geoipupdate.Error"}`,
+		api.errors["2"],
+	)
+
+	require.NoError(t, r.InitError(context.Background(), errors.New("invalid configuration")))
+	assert.Contains(t, api.errors["init"], "invalid configuration")
+}
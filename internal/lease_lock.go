@@ -0,0 +1,323 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+const (
+	// leaseDuration is how long a lease is valid after it was last
+	// renewed.
+	leaseDuration = 5 * time.Minute
+	// leaseRenewInterval is how often the holder of a lease renews it.
+	leaseRenewInterval = time.Minute
+	// leaseGuardExtension is appended to the path of the lease file for
+	// that of the file locked while the lease is replaced.
+	leaseGuardExtension = ".guard"
+	// leaseGuardTimeout is how long to wait for another process to be done
+	// replacing the lease.
+	leaseGuardTimeout = 10 * time.Second
+	leaseGuardRetry   = 10 * time.Millisecond
+)
+
+// ErrLockLost is returned by FencedLock.Fence when another process took
+// the lock over, e.g., because it expired while its holder was frozen.
+var ErrLockLost = errors.New("taken over by another process")
+
+// FencedLock is a Lock whose holder can check that it still holds it
+// before each write, so that a holder that lost it doesn't overwrite the
+// writes of the new holder.
+type FencedLock interface {
+	Lock
+	// Fence returns an error wrapping ErrLockLost if the lock isn't held
+	// anymore.
+	Fence() error
+	// Token returns the fencing token of the lock, which increases each
+	// time it is acquired, or 0 if it isn't held.
+	Token() uint64
+}
+
+// lease is the content of the lease file.
+type lease struct {
+	// Token is the fencing token of the last holder.
+	Token uint64 `json:"token"`
+	// Holder identifies the holder, e.g., for logging conflicts.
+	Holder string `json:"holder"`
+	// ExpiresAt is when the lease expires, or zero once released.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// leaseLock is a lock for many hosts sharing a network file system, e.g.,
+// Amazon EFS, that doesn't rely on its locks being held for long. The lock
+// file holds a lease that expires unless renewed, and a fencing token
+// incremented by each holder. The lease is replaced with renames, which are
+// atomic, and the file is never removed, so that tokens only increase.
+//
+// The lease is read, checked, and replaced while holding a lock on a guard
+// file next to it, so that a holder renewing or releasing its lease never
+// overwrites the lease of a process that took it over in between, which
+// would make the token go backwards.
+type leaseLock struct {
+	path    string
+	holder  string
+	verbose bool
+	// duration is how long the lease is valid after it was last renewed.
+	duration time.Duration
+	guard    *flock.Flock
+
+	mu    sync.Mutex
+	token uint64
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newLeaseLock(path string, verbose bool) (*leaseLock, error) {
+	var nonce [4]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("generating lease holder: %w", err)
+	}
+	//nolint:errcheck // the name is best effort.
+	host, _ := os.Hostname()
+	if verbose {
+		log.Printf("Initializing lease lock at %s", path)
+	}
+	return &leaseLock{
+		path: path,
+		// The PID isn't enough, e.g., in Lambda functions.
+		holder:   fmt.Sprintf("%s %d %s", host, os.Getpid(), hex.EncodeToString(nonce[:])),
+		verbose:  verbose,
+		duration: leaseDuration,
+		guard:    flock.New(path + leaseGuardExtension),
+	}, nil
+}
+
+// Acquire tries to acquire the lease.
+func (l *leaseLock) Acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop != nil {
+		return nil
+	}
+
+	var next lease
+	err := l.update(func(current lease) (lease, error) {
+		if current.Holder != l.holder && time.Now().Before(current.ExpiresAt) {
+			return lease{}, fmt.Errorf("lock %s %w (%s, token %d)", l.path, ErrLockHeld, current.Holder, current.Token)
+		}
+		next = lease{
+			Token:     current.Token + 1,
+			Holder:    l.holder,
+			ExpiresAt: time.Now().Add(l.duration),
+		}
+		return next, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.token = next.Token
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.renew(l.stop, l.done)
+
+	if l.verbose {
+		log.Printf("Acquired lease lock at %s with token %d", l.path, l.token)
+	}
+	return nil
+}
+
+// Fence returns an error wrapping ErrLockLost if another process holds the
+// lease, or if it expired.
+func (l *leaseLock) Fence() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fence()
+}
+
+func (l *leaseLock) fence() error {
+	if l.stop == nil {
+		return fmt.Errorf("lock %s isn't held", l.path)
+	}
+	current, err := l.read()
+	if err != nil {
+		return err
+	}
+	return l.check(current)
+}
+
+// check returns an error wrapping ErrLockLost if current, the lease in the
+// lease file, isn't the one held, or if it expired.
+func (l *leaseLock) check(current lease) error {
+	if current.Holder != l.holder || current.Token != l.token {
+		return fmt.Errorf(
+			"lock %s with token %d %w (%s, token %d)",
+			l.path,
+			l.token,
+			ErrLockLost,
+			current.Holder,
+			current.Token,
+		)
+	}
+	if !time.Now().Before(current.ExpiresAt) {
+		return fmt.Errorf("lock %s with token %d expired, it may be %w", l.path, l.token, ErrLockLost)
+	}
+	return nil
+}
+
+// Token returns the fencing token of the lease.
+func (l *leaseLock) Token() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// renew renews the lease until stop is closed, unless it was lost.
+func (l *leaseLock) renew(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			err := l.extend()
+			l.mu.Unlock()
+			if err != nil {
+				log.Printf("Renewing lease at %s: %s", l.path, err)
+			}
+		}
+	}
+}
+
+// extend extends the lease held, unless it was lost.
+func (l *leaseLock) extend() error {
+	return l.update(func(current lease) (lease, error) {
+		if err := l.check(current); err != nil {
+			return lease{}, err
+		}
+		return lease{
+			Token:     l.token,
+			Holder:    l.holder,
+			ExpiresAt: time.Now().Add(l.duration),
+		}, nil
+	})
+}
+
+// Release releases the lease, keeping its token for the next holder.
+func (l *leaseLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	token := l.token
+	err := l.update(func(current lease) (lease, error) {
+		if err := l.check(current); err != nil {
+			return lease{}, err
+		}
+		return lease{Token: token, Holder: l.holder}, nil
+	})
+	l.stop = nil
+	l.done = nil
+	l.token = 0
+	// A lease taken over by another process is theirs to release, and an
+	// expired one needs no release.
+	if errors.Is(err, ErrLockLost) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("releasing lease lock at %s: %w", l.path, err)
+	}
+	if l.verbose {
+		log.Printf("Lease lock %s with token %d successfully released", l.path, token)
+	}
+	return nil
+}
+
+// update replaces the lease with the one next returns given the current
+// one, while holding the guard lock, so that no other process replaces the
+// lease in between. Nothing is written if next returns an error.
+func (l *leaseLock) update(next func(current lease) (lease, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseGuardTimeout)
+	defer cancel()
+	ok, err := l.guard.TryLockContext(ctx, leaseGuardRetry)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("locking lease at %s: %w", l.path, err)
+	}
+	if !ok {
+		return fmt.Errorf("lease at %s still being replaced by another process after %s", l.path, leaseGuardTimeout)
+	}
+	defer l.guard.Unlock() //nolint:errcheck // the lease was already replaced.
+
+	current, err := l.read()
+	if err != nil {
+		return err
+	}
+	replacement, err := next(current)
+	if err != nil {
+		return err
+	}
+	return l.write(replacement)
+}
+
+// read reads the lease file, which is empty if it doesn't exist yet.
+func (l *leaseLock) read() (lease, error) {
+	data, err := os.ReadFile(filepath.Clean(l.path))
+	if errors.Is(err, os.ErrNotExist) {
+		return lease{}, nil
+	}
+	if err != nil {
+		return lease{}, fmt.Errorf("reading lease at %s: %w", l.path, err)
+	}
+	var current lease
+	if err := json.Unmarshal(data, &current); err != nil {
+		return lease{}, fmt.Errorf("parsing lease at %s: %w", l.path, err)
+	}
+	return current, nil
+}
+
+// write replaces the lease file with next.
+func (l *leaseLock) write(next lease) error {
+	data, err := json.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("encoding lease: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating lease: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("writing lease: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close() //nolint:errcheck // we are already returning an error.
+		return fmt.Errorf("syncing lease: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing lease: %w", err)
+	}
+	if err := os.Rename(f.Name(), l.path); err != nil {
+		return fmt.Errorf("moving lease into place: %w", err)
+	}
+	return nil
+}
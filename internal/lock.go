@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// The lock types, i.e., how geoipupdate processes exclude each other.
+const (
+	// LockTypeFlock locks the lock file with flock(2), or LockFileEx on
+	// Windows. It is the default.
+	LockTypeFlock = "flock"
+	// LockTypeFcntl locks the lock file with fcntl(2) record locks, which
+	// NFS supports through its lock manager. It isn't supported on Windows.
+	LockTypeFcntl = "fcntl"
+	// LockTypeMutex uses a named mutex derived from the path of the lock
+	// file. It is only supported on Windows.
+	LockTypeMutex = "mutex"
+	// LockTypeMtime uses the existence and the modification time of the
+	// lock file, for file systems without working locks. It is advisory
+	// only: two processes starting at the same time may both acquire it.
+	LockTypeMtime = "mtime"
+	// LockTypeLease uses a lease with a fencing token in the lock file, for
+	// many hosts sharing a network file system, e.g., Amazon EFS. The lock
+	// is a FencedLock.
+	LockTypeLease = "lease"
+)
+
+// Lock excludes other geoipupdate processes while it is held.
+type Lock interface {
+	// Acquire acquires the lock, returning an error wrapping ErrLockHeld
+	// if another process holds it.
+	Acquire() error
+	// Release releases the lock.
+	Release() error
+}
+
+// ValidateLockType returns an error if lockType is unknown or not supported
+// on this platform.
+func ValidateLockType(lockType string) error {
+	switch lockType {
+	case LockTypeFlock, LockTypeMtime, LockTypeLease:
+		return nil
+	case LockTypeFcntl:
+		if runtime.GOOS == "windows" {
+			return fmt.Errorf("`LockType' %s is not supported on Windows", lockType)
+		}
+		return nil
+	case LockTypeMutex:
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("`LockType' %s is only supported on Windows", lockType)
+		}
+		return nil
+	default:
+		return fmt.Errorf(
+			"`LockType' must be %s, %s, %s, %s or %s, got '%s'",
+			LockTypeFlock,
+			LockTypeFcntl,
+			LockTypeMutex,
+			LockTypeMtime,
+			LockTypeLease,
+			lockType,
+		)
+	}
+}
+
+// NewLock creates a lock of type lockType for the lock file at path. An
+// empty lockType is LockTypeFlock.
+func NewLock(lockType, path string, verbose bool) (Lock, error) {
+	if lockType == "" {
+		lockType = LockTypeFlock
+	}
+	if err := ValidateLockType(lockType); err != nil {
+		return nil, err
+	}
+
+	if lockType == LockTypeFlock {
+		return NewFileLock(path, verbose)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("creating lock file directory: %w", err)
+	}
+	switch lockType {
+	case LockTypeFcntl:
+		return newFcntlLock(path, verbose)
+	case LockTypeMutex:
+		return newMutexLock(path, verbose)
+	case LockTypeLease:
+		return newLeaseLock(path, verbose)
+	default:
+		return newMtimeLock(path, verbose), nil
+	}
+}
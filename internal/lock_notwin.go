@@ -0,0 +1,83 @@
+//go:build !windows
+// +build !windows
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// fcntlLock is a lock based on fcntl(2) record locks. Unlike flock(2)
+// locks, they are forwarded to the server by NFS clients.
+type fcntlLock struct {
+	path    string
+	verbose bool
+	file    *os.File
+}
+
+func newFcntlLock(path string, verbose bool) (Lock, error) {
+	if verbose {
+		log.Printf("Initializing fcntl lock at %s", path)
+	}
+	return &fcntlLock{path: path, verbose: verbose}, nil
+}
+
+// Acquire tries to acquire the lock.
+func (l *fcntlLock) Acquire() error {
+	if l.file != nil {
+		return nil
+	}
+
+	//nolint:gosec // the lock file is meant to be opened.
+	file, err := os.OpenFile(filepath.Clean(l.path), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening lock file at %s: %w", l.path, err)
+	}
+
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: 0, Start: 0, Len: 0}
+	if err := unix.FcntlFlock(file.Fd(), unix.F_SETLK, &lock); err != nil {
+		file.Close()
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EACCES) {
+			return fmt.Errorf("lock %s %w", l.path, ErrLockHeld)
+		}
+		return fmt.Errorf("acquiring fcntl lock at %s: %w", l.path, err)
+	}
+
+	l.file = file
+	if l.verbose {
+		log.Printf("Acquired fcntl lock at %s", l.path)
+	}
+	return nil
+}
+
+// Release releases the lock.
+func (l *fcntlLock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+
+	lock := unix.Flock_t{Type: unix.F_UNLCK, Whence: 0, Start: 0, Len: 0}
+	err := unix.FcntlFlock(l.file.Fd(), unix.F_SETLK, &lock)
+	// Closing the file releases the lock anyway.
+	err = errors.Join(err, l.file.Close())
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("releasing fcntl lock at %s: %w", l.path, err)
+	}
+	if l.verbose {
+		log.Printf("Fcntl lock %s successfully released", l.path)
+	}
+	return nil
+}
+
+// newMutexLock returns an error, as named mutexes are only supported on
+// Windows.
+func newMutexLock(string, bool) (Lock, error) {
+	return nil, errors.New("named mutexes are only supported on Windows")
+}
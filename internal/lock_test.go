@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	tests := []struct {
+		lockType string
+		// exclusiveInProcess is whether two locks of the same process
+		// exclude each other. fcntl locks are held by processes.
+		exclusiveInProcess bool
+	}{
+		{lockType: LockTypeFlock, exclusiveInProcess: true},
+		{lockType: LockTypeFcntl},
+		{lockType: LockTypeMutex, exclusiveInProcess: true},
+		{lockType: LockTypeMtime, exclusiveInProcess: true},
+		{lockType: LockTypeLease, exclusiveInProcess: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.lockType, func(t *testing.T) {
+			if err := ValidateLockType(test.lockType); err != nil {
+				t.Skip(err)
+			}
+			path := filepath.Join(t.TempDir(), "locks", ".geoipupdate.lock")
+
+			lock, err := NewLock(test.lockType, path, false)
+			require.NoError(t, err)
+			require.NoError(t, lock.Acquire())
+			// Acquiring a held lock again succeeds.
+			require.NoError(t, lock.Acquire())
+
+			other, err := NewLock(test.lockType, path, false)
+			require.NoError(t, err)
+			if test.exclusiveInProcess {
+				require.ErrorIs(t, other.Acquire(), ErrLockHeld)
+			}
+
+			require.NoError(t, lock.Release())
+			require.NoError(t, other.Acquire())
+			require.NoError(t, other.Release())
+		})
+	}
+}
+
+// TestMtimeLockStale tests that lock files left behind are taken over once
+// they are stale.
+func TestMtimeLockStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".geoipupdate.lock")
+	require.NoError(t, os.WriteFile(path, []byte("host 42\n"), 0o600))
+
+	lock, err := NewLock(LockTypeMtime, path, false)
+	require.NoError(t, err)
+	require.ErrorIs(t, lock.Acquire(), ErrLockHeld)
+
+	stale := time.Now().Add(-mtimeLockStaleAfter - time.Minute)
+	require.NoError(t, os.Chtimes(path, stale, stale))
+	require.NoError(t, lock.Acquire())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), info.ModTime(), time.Minute)
+
+	require.NoError(t, lock.Release())
+	require.NoFileExists(t, path)
+}
+
+// TestLeaseLock tests that lease tokens increase with each holder, and that
+// a holder whose lease was taken over is fenced off.
+func TestLeaseLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".geoipupdate.lock")
+
+	first, err := newLeaseLock(path, false)
+	require.NoError(t, err)
+	require.NoError(t, first.Acquire())
+	require.Equal(t, uint64(1), first.Token())
+	require.NoError(t, first.Fence())
+	require.NoError(t, first.Release())
+	require.Zero(t, first.Token())
+	require.Error(t, first.Fence())
+
+	second, err := newLeaseLock(path, false)
+	require.NoError(t, err)
+	require.NoError(t, second.Acquire())
+	require.Equal(t, uint64(2), second.Token())
+
+	// The lease expires if it isn't renewed, e.g., because its holder was
+	// frozen, and is then taken over.
+	require.NoError(t, second.write(lease{
+		Token:     second.token,
+		Holder:    second.holder,
+		ExpiresAt: time.Now().Add(-time.Second),
+	}))
+	require.ErrorIs(t, second.Fence(), ErrLockLost)
+
+	third, err := newLeaseLock(path, false)
+	require.NoError(t, err)
+	require.NoError(t, third.Acquire())
+	require.Equal(t, uint64(3), third.Token())
+	require.ErrorIs(t, second.Fence(), ErrLockLost)
+
+	// Releasing a lease taken over leaves it to its new holder.
+	require.NoError(t, second.Release())
+	require.NoError(t, third.Fence())
+	require.NoError(t, third.Release())
+}
+
+// TestLeaseLockConcurrentHolders tests that a holder renewing its lease as
+// another process takes it over never overwrites the lease of the new
+// holder, so that the fencing token never goes backwards.
+func TestLeaseLockConcurrentHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".geoipupdate.lock")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		l, err := newLeaseLock(path, false)
+		require.NoError(t, err)
+		// The leases expire as they are renewed, for the holders to keep
+		// taking them over from each other.
+		l.duration = time.Millisecond
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if l.Token() == 0 {
+					_ = l.Acquire()
+					continue
+				}
+				l.mu.Lock()
+				err := l.extend()
+				l.mu.Unlock()
+				if errors.Is(err, ErrLockLost) {
+					assert.NoError(t, l.Release())
+				}
+			}
+			assert.NoError(t, l.Release())
+		}()
+	}
+
+	var tokens []uint64
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader := &leaseLock{path: path}
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			current, err := reader.read()
+			if err == nil {
+				tokens = append(tokens, current.Token)
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-done
+
+	require.NotEmpty(t, tokens)
+	for i := 1; i < len(tokens); i++ {
+		require.GreaterOrEqual(t, tokens[i], tokens[i-1], "the fencing token went backwards")
+	}
+}
+
+func TestValidateLockType(t *testing.T) {
+	require.NoError(t, ValidateLockType(LockTypeFlock))
+	require.NoError(t, ValidateLockType(LockTypeMtime))
+	require.NoError(t, ValidateLockType(LockTypeLease))
+	require.EqualError(
+		t,
+		ValidateLockType("nfs"),
+		"`LockType' must be flock, fcntl, mutex, mtime or lease, got 'nfs'",
+	)
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// mutexLock is a lock based on a named mutex, which doesn't depend on the
+// file system the lock file is on. The lock is held as long as the mutex
+// exists, i.e., until the process holding it releases it or exits, so that
+// it isn't tied to the thread that created it.
+type mutexLock struct {
+	path    string
+	name    string
+	verbose bool
+	handle  windows.Handle
+}
+
+func newMutexLock(path string, verbose bool) (Lock, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving lock file path %s: %w", path, err)
+	}
+	// Mutex names can't contain backslashes and paths are case insensitive.
+	sum := sha256.Sum256([]byte(strings.ToLower(abs)))
+	name := `Global\geoipupdate-` + hex.EncodeToString(sum[:16])
+
+	if verbose {
+		log.Printf("Initializing mutex %s for %s", name, path)
+	}
+	return &mutexLock{path: path, name: name, verbose: verbose}, nil
+}
+
+// Acquire tries to acquire the lock.
+func (l *mutexLock) Acquire() error {
+	if l.handle != 0 {
+		return nil
+	}
+
+	name, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return fmt.Errorf("acquiring mutex %s: %w", l.name, err)
+	}
+	handle, err := windows.CreateMutex(nil, false, name)
+	if err != nil {
+		if handle != 0 {
+			//nolint:errcheck // we are already returning an error.
+			_ = windows.CloseHandle(handle)
+		}
+		if errors.Is(err, windows.ERROR_ALREADY_EXISTS) {
+			return fmt.Errorf("lock %s %w", l.path, ErrLockHeld)
+		}
+		return fmt.Errorf("acquiring mutex %s: %w", l.name, err)
+	}
+
+	l.handle = handle
+	if l.verbose {
+		log.Printf("Acquired mutex %s", l.name)
+	}
+	return nil
+}
+
+// Release releases the lock.
+func (l *mutexLock) Release() error {
+	if l.handle == 0 {
+		return nil
+	}
+
+	err := windows.CloseHandle(l.handle)
+	l.handle = 0
+	if err != nil {
+		return fmt.Errorf("releasing mutex %s: %w", l.name, err)
+	}
+	if l.verbose {
+		log.Printf("Mutex %s successfully released", l.name)
+	}
+	return nil
+}
+
+// newFcntlLock returns an error, as fcntl locks are not supported on
+// Windows.
+func newFcntlLock(string, bool) (Lock, error) {
+	return nil, errors.New("fcntl locks are not supported on Windows")
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// mtimeLockStaleAfter is how long after its last modification the lock
+	// file of an mtime lock is considered left behind by a process that
+	// died.
+	mtimeLockStaleAfter = 5 * time.Minute
+	// mtimeLockRefreshInterval is how often the holder of an mtime lock
+	// updates the modification time of the lock file.
+	mtimeLockRefreshInterval = time.Minute
+)
+
+// mtimeLock is a lock that doesn't rely on file system locks, which some
+// network file systems don't support or implement poorly. The lock is held
+// while the lock file exists and was modified recently, its holder updating
+// the modification time regularly. Creating the lock file is atomic, but
+// removing a stale one isn't, so the lock is advisory.
+type mtimeLock struct {
+	path    string
+	verbose bool
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newMtimeLock(path string, verbose bool) *mtimeLock {
+	if verbose {
+		log.Printf("Initializing mtime lock at %s", path)
+	}
+	return &mtimeLock{path: path, verbose: verbose}
+}
+
+// Acquire tries to acquire the lock.
+func (l *mtimeLock) Acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop != nil {
+		return nil
+	}
+
+	err := l.create()
+	if errors.Is(err, os.ErrExist) {
+		info, statErr := os.Stat(l.path)
+		switch {
+		case errors.Is(statErr, os.ErrNotExist):
+			// The holder released the lock in the meantime.
+		case statErr != nil:
+			return fmt.Errorf("reading lock file at %s: %w", l.path, statErr)
+		case time.Since(info.ModTime()) < mtimeLockStaleAfter:
+			return fmt.Errorf("lock %s %w", l.path, ErrLockHeld)
+		default:
+			log.Printf(
+				"Removing the lock file %s, last modified at %s, as stale",
+				l.path,
+				info.ModTime().Format(time.RFC3339),
+			)
+			if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("removing stale lock file at %s: %w", l.path, err)
+			}
+		}
+		err = l.create()
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("lock %s %w", l.path, ErrLockHeld)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("creating lock file at %s: %w", l.path, err)
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.refresh(l.stop, l.done)
+
+	if l.verbose {
+		log.Printf("Acquired mtime lock at %s", l.path)
+	}
+	return nil
+}
+
+// create creates the lock file, failing if it exists. It records the
+// holder of the lock for troubleshooting.
+func (l *mtimeLock) create() error {
+	//nolint:gosec // the lock file is meant to be created.
+	f, err := os.OpenFile(filepath.Clean(l.path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck // the name is best effort.
+	host, _ := os.Hostname()
+	_, err = fmt.Fprintf(f, "%s %d\n", host, os.Getpid())
+	return errors.Join(err, f.Close())
+}
+
+// refresh updates the modification time of the lock file until stop is
+// closed.
+func (l *mtimeLock) refresh(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(mtimeLockRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(l.path, now, now); err != nil {
+				log.Printf("Refreshing lock file at %s: %s", l.path, err)
+			}
+		}
+	}
+}
+
+// Release releases the lock.
+func (l *mtimeLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	l.stop = nil
+	l.done = nil
+
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("releasing mtime lock at %s: %w", l.path, err)
+	}
+	if l.verbose {
+		log.Printf("Mtime lock %s successfully released", l.path)
+	}
+	return nil
+}
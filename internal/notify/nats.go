@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// natsDefaultPort is the default port of NATS servers.
+const natsDefaultPort = "4222"
+
+// natsTimeout bounds NATS exchanges when the context has no deadline.
+const natsTimeout = 30 * time.Second
+
+// natsNotifier publishes announcements to a NATS subject, using the NATS
+// client protocol.
+type natsNotifier struct {
+	addr     string
+	host     string
+	subject  string
+	tls      bool
+	user     string
+	password string
+}
+
+func newNATSNotifier(u *url.URL) (*natsNotifier, error) {
+	subject := strings.TrimPrefix(u.Path, "/")
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("invalid NATS subject '%s'", subject)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("the NATS server is missing")
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = natsDefaultPort
+	}
+	n := &natsNotifier{
+		addr:    net.JoinHostPort(u.Hostname(), port),
+		host:    u.Hostname(),
+		subject: subject,
+		tls:     u.Scheme == "tls",
+	}
+	if u.User != nil {
+		n.user = u.User.Username()
+		n.password, _ = u.User.Password()
+	}
+	return n, nil
+}
+
+func (n *natsNotifier) Notify(ctx context.Context, a Announcement) error {
+	message, err := a.message()
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return fmt.Errorf("connecting to NATS: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(natsTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("setting NATS deadline: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	// The server starts with an INFO message.
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading NATS INFO: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected NATS message '%s'", strings.TrimSpace(line))
+	}
+
+	if n.tls {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: n.host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("starting NATS TLS: %w", err)
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	connect := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "geoipupdate",
+		"lang":     "go",
+		"version":  vars.Version,
+	}
+	switch {
+	case n.user != "" && n.password != "":
+		connect["user"] = n.user
+		connect["pass"] = n.password
+	case n.user != "":
+		connect["auth_token"] = n.user
+	}
+	options, err := json.Marshal(connect)
+	if err != nil {
+		return fmt.Errorf("encoding NATS CONNECT: %w", err)
+	}
+
+	// PING makes the server reply once it has processed the messages
+	// before it, reporting errors such as authorization failures.
+	_, err = fmt.Fprintf(
+		conn,
+		"CONNECT %s\r\nPUB %s %d\r\n%s\r\nPING\r\n",
+		options,
+		n.subject,
+		len(message),
+		message,
+	)
+	if err != nil {
+		return fmt.Errorf("publishing to NATS: %w", err)
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("reading NATS reply: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("publishing to NATS: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
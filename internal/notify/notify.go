@@ -0,0 +1,134 @@
+// Package notify announces updated databases to other systems, e.g., through
+// a message broker, so that they can react immediately instead of polling.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Events of announcements other than updates.
+const (
+	// EventFailing announces that an edition failed to update for several
+	// runs in a row.
+	EventFailing = "failing"
+	// EventRecovered announces that an edition was updated again after an
+	// EventFailing announcement.
+	EventRecovered = "recovered"
+	// EventOutdated announces, for check-only runs, that the installed
+	// database of an edition is not its latest build.
+	EventOutdated = "outdated"
+	// EventCurrent announces, for check-only runs, that the installed
+	// database of an edition is its latest build again after an
+	// EventOutdated announcement.
+	EventCurrent = "current"
+)
+
+// Announcement describes an updated database, or an alert about the updates
+// of an edition if Event is set.
+type Announcement struct {
+	// Event is the event announced, e.g., EventFailing. It is empty for
+	// updated databases.
+	Event string `json:"event,omitempty"`
+	// RunID identifies the run that made the announcement.
+	RunID string `json:"run_id"`
+	// UpdateID identifies the update of the edition to the new database. It
+	// is the same when the announcement is made again, e.g., because it
+	// failed for another target, so receivers can deduplicate announcements.
+	UpdateID  string `json:"update_id"`
+	EditionID string `json:"edition_id"`
+	// MD5 is the MD5 sum of the new database.
+	MD5 string `json:"md5"`
+	// Date is when the database was published by MaxMind, or, for alerts,
+	// when the edition started failing to update.
+	Date time.Time `json:"date"`
+	// URL is where the database can be downloaded from, if known.
+	URL string `json:"url,omitempty"`
+	// Labels are those of the Labels setting, if any.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Failures is the number of consecutive runs that failed to update the
+	// edition, and Error the error of the last one, for EventFailing.
+	Failures int    `json:"failures,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Notifier announces updated databases.
+type Notifier interface {
+	Notify(ctx context.Context, a Announcement) error
+}
+
+// New returns the Notifier for target, which is one of:
+//
+//   - nats://[user:password@]host[:port]/subject, or tls://... for NATS over
+//     TLS, publishing to a NATS subject. A user without a password is used
+//     as a token.
+//   - an SNS topic ARN, e.g., arn:aws:sns:us-east-1:123456789012:geoip,
+//     publishing with the credentials from the standard AWS environment
+//     variables.
+//   - an http:// or https:// URL, to which announcements are POSTed, e.g.,
+//     a webhook or the REST proxy of a Kafka cluster.
+func New(target string, httpClient *http.Client) (Notifier, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if strings.HasPrefix(target, "arn:") {
+		return newSNSNotifier(target, httpClient)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification target '%s'", Redact(target))
+	}
+	switch u.Scheme {
+	case "nats", "tls":
+		return newNATSNotifier(u)
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid notification target '%s'", Redact(target))
+		}
+		return &webhookNotifier{httpClient: httpClient, url: target}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification target '%s'", Redact(target))
+	}
+}
+
+// Validate checks that target is a valid notification target, without
+// requiring the credentials it may need.
+func Validate(target string) error {
+	if strings.HasPrefix(target, "arn:") {
+		_, err := parseTopicARN(target)
+		return err
+	}
+	_, err := New(target, nil)
+	return err
+}
+
+// Redact returns target without the credentials it may contain.
+func Redact(target string) string {
+	if strings.HasPrefix(target, "arn:") {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "[invalid]"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// message returns the JSON encoding of a.
+func (a Announcement) message() ([]byte, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, fmt.Errorf("encoding announcement: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/awscreds"
+	"github.com/maxmind/geoipupdate/v7/internal/sigv4"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// topicARN is an SNS topic ARN.
+type topicARN struct {
+	arn    string
+	region string
+}
+
+// parseTopicARN parses an ARN such as
+// arn:aws:sns:us-east-1:123456789012:geoip.
+func parseTopicARN(value string) (topicARN, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 6 ||
+		parts[0] != "arn" ||
+		!strings.HasPrefix(parts[1], "aws") ||
+		parts[2] != "sns" ||
+		parts[3] == "" ||
+		parts[4] == "" ||
+		parts[5] == "" {
+		return topicARN{}, fmt.Errorf("invalid SNS topic ARN '%s'", value)
+	}
+	return topicARN{arn: value, region: parts[3]}, nil
+}
+
+// snsNotifier publishes announcements to an SNS topic.
+type snsNotifier struct {
+	httpClient *http.Client
+	topic      topicARN
+	endpoint   string
+	signer     *sigv4.Signer
+}
+
+func newSNSNotifier(target string, httpClient *http.Client) (*snsNotifier, error) {
+	topic, err := parseTopicARN(target)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := awscreds.New(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("publishing to SNS: %w", err)
+	}
+
+	return &snsNotifier{
+		httpClient: httpClient,
+		topic:      topic,
+		endpoint:   "https://sns." + topic.region + ".amazonaws.com/",
+		signer: &sigv4.Signer{
+			Region:   topic.region,
+			Service:  "sns",
+			Provider: creds,
+		},
+	}, nil
+}
+
+func (n *snsNotifier) Notify(ctx context.Context, a Announcement) error {
+	message, err := a.message()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"Action":   {"Publish"},
+		"Message":  {string(message)},
+		"TopicArn": {n.topic.arn},
+		"Version":  {"2010-03-31"},
+	}
+	// FIFO topics require a message group and deduplicate messages by ID.
+	if strings.HasSuffix(n.topic.arn, ".fifo") {
+		query.Set("MessageGroupId", a.EditionID)
+		query.Set("MessageDeduplicationId", a.UpdateID)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		n.endpoint+"?"+query.Encode(),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("creating SNS request: %w", err)
+	}
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+	if err := n.signer.Authenticate(req); err != nil {
+		return fmt.Errorf("signing SNS request: %w", err)
+	}
+
+	response, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("publishing to %s: %w", n.topic.arn, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		//nolint:errcheck // we are already returning an error.
+		buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		httpErr := internal.HTTPError{
+			Body:       string(buf),
+			StatusCode: response.StatusCode,
+		}
+		return fmt.Errorf("publishing to %s: unexpected HTTP status code: %w", n.topic.arn, httpErr)
+	}
+	return nil
+}
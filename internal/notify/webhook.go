@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// webhookNotifier POSTs announcements, encoded as JSON, to a URL. The
+// Idempotency-Key header holds the update ID of the announcement.
+type webhookNotifier struct {
+	httpClient *http.Client
+	url        string
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, a Announcement) error {
+	message, err := a.message()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", a.UpdateID)
+	req.Header.Add("User-Agent", "geoipupdate/"+vars.Version)
+
+	response, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to %s: %w", Redact(n.url), err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		//nolint:errcheck // we are already returning an error.
+		buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		httpErr := internal.HTTPError{
+			Body:       string(buf),
+			StatusCode: response.StatusCode,
+		}
+		return fmt.Errorf("posting to %s: unexpected HTTP status code: %w", Redact(n.url), httpErr)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentials are the credentials of a registry. They are empty for
+// anonymous access.
+type credentials struct {
+	username string
+	password string
+}
+
+// dockerCredentials returns the credentials of host stored in the Docker
+// configuration file, e.g., by docker login, if any. Credential helpers are
+// not supported.
+func dockerCredentials(host string) credentials {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return credentials{}
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return credentials{}
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return credentials{}
+	}
+
+	for _, key := range []string{host, "https://" + host, "http://" + host} {
+		entry, ok := config.Auths[key]
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return credentials{}
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return credentials{}
+		}
+		return credentials{username: username, password: password}
+	}
+	return credentials{}
+}
+
+// authorize returns the Authorization header answering challenge, the
+// WWW-Authenticate header of a response of the registry.
+func (r *Repository) authorize(ctx context.Context, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if r.credentials == (credentials{}) {
+			return "", errors.New("the registry requires credentials")
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(r.credentials.username, r.credentials.password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := r.token(ctx, params)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported registry authentication challenge '%s'", challenge)
+	}
+}
+
+// token gets a token from the authorization service of the registry, as
+// described by the parameters of a bearer challenge.
+func (r *Repository) token(ctx context.Context, params map[string]string) (string, error) {
+	realm, ok := params["realm"]
+	if !ok {
+		return "", errors.New("the registry authentication challenge has no realm")
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("parsing the registry authentication realm: %w", err)
+	}
+	query := u.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	scope, ok := params["scope"]
+	if !ok {
+		scope = fmt.Sprintf("repository:%s:pull,push", r.name)
+	}
+	query.Set("scope", scope)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	if r.credentials != (credentials{}) {
+		req.SetBasicAuth(r.credentials.username, r.credentials.password)
+	}
+
+	response, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("performing token request: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting a registry token: %w", statusError(response))
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("parsing token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", errors.New("the token response has no token")
+}
+
+// parseChallenge returns the lower-cased scheme and the parameters of a
+// WWW-Authenticate header, e.g., `Bearer realm="...",service="..."`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key != "" {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+	return strings.ToLower(scheme), params
+}
@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+)
+
+// emptyConfig is the content of the empty config of artifacts.
+var emptyConfig = []byte("{}")
+
+// descriptor describes content stored in the registry.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// manifest is an OCI image manifest.
+type manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// errManifestNotFound is returned by getManifest for unknown tags.
+var errManifestNotFound = errors.New("manifest not found")
+
+// getManifest returns the manifest tagged with tag.
+func (r *Repository) getManifest(ctx context.Context, tag string) (*manifest, error) {
+	response, err := r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, r.url("manifests/"+url.PathEscape(tag)), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating manifest request: %w", err)
+		}
+		req.Header.Set("Accept", mediaTypeManifest)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, fmt.Errorf("getting manifest %s: %w", tag, errManifestNotFound)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting manifest %s: %w", tag, statusError(response))
+	}
+	defer response.Body.Close()
+
+	var m manifest
+	if err := json.NewDecoder(io.LimitReader(response.Body, 4<<20)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", tag, err)
+	}
+	return &m, nil
+}
+
+// database returns the layer of a database manifest and its encoding.
+func (m *manifest) database() (descriptor, encoding.Encoding, error) {
+	if m.ArtifactType != MediaTypeDatabase || len(m.Layers) != 1 {
+		return descriptor{}, encoding.Encoding{}, errors.New("the manifest is not a database artifact")
+	}
+	layer := m.Layers[0]
+
+	name := encoding.None
+	if layer.MediaType != MediaTypeDatabase {
+		suffix, ok := strings.CutPrefix(layer.MediaType, MediaTypeDatabase+"+")
+		if !ok {
+			return descriptor{}, encoding.Encoding{}, errors.New("the manifest is not a database artifact")
+		}
+		name = suffix
+	}
+	e, err := encoding.Lookup(name)
+	if err != nil {
+		return descriptor{}, encoding.Encoding{}, fmt.Errorf("decoding the database: %w", err)
+	}
+	return layer, e, nil
+}
+
+// layerMediaType returns the media type of database layers encoded with e.
+func layerMediaType(e encoding.Encoding) string {
+	if e.IsNone() {
+		return MediaTypeDatabase
+	}
+	return MediaTypeDatabase + "+" + e.Name
+}
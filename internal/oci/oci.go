@@ -0,0 +1,244 @@
+// Package oci distributes databases through an OCI registry, as artifacts
+// compatible with ORAS.
+//
+// Each database is pushed as an image manifest with the MediaTypeDatabase
+// artifact type, whose single layer is the MMDB file, possibly encoded, e.g.,
+// compressed with gzip. The manifest is tagged
+// with the edition ID, which always points to the latest build, and with the
+// edition ID followed by the build date, e.g., GeoIP2-City-20240102.
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/maxmind/geoipupdate/v7/internal"
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+	"github.com/maxmind/geoipupdate/v7/internal/vars"
+)
+
+// Media types of the database artifacts.
+const (
+	// MediaTypeDatabase is the artifact type of database manifests, and the
+	// media type of their layer. Encoded layers have the name of their
+	// encoding as suffix, e.g., application/vnd.maxmind.mmdb.v1+gzip.
+	MediaTypeDatabase = "application/vnd.maxmind.mmdb.v1"
+	// mediaTypeManifest is the media type of OCI image manifests.
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	// mediaTypeEmpty is the media type of the empty config of artifacts.
+	mediaTypeEmpty = "application/vnd.oci.empty.v1+json"
+)
+
+// Annotations of the database manifests.
+const (
+	// AnnotationCreated is when the database was published by MaxMind.
+	AnnotationCreated = "org.opencontainers.image.created"
+	// AnnotationTitle is the file name of the layer, used by ORAS when
+	// pulling.
+	AnnotationTitle = "org.opencontainers.image.title"
+	// AnnotationEditionID is the edition ID of the database.
+	AnnotationEditionID = "com.maxmind.geoipupdate.edition_id"
+	// AnnotationMD5 is the MD5 sum of the MMDB file.
+	AnnotationMD5 = "com.maxmind.geoipupdate.md5"
+	// AnnotationEncoding is the encoding of the layer, if any.
+	AnnotationEncoding = "com.maxmind.geoipupdate.encoding"
+	// AnnotationRawDigest is the digest of the MMDB file of encoded layers,
+	// whose digest is that of the encoded file.
+	AnnotationRawDigest = "com.maxmind.geoipupdate.raw_digest"
+	// AnnotationEncryption is the encryption of the layer, if any, e.g.,
+	// aes-256-gcm. Layers are encoded before being encrypted.
+	AnnotationEncryption = "com.maxmind.geoipupdate.encryption"
+	// AnnotationEncryptionKey is the ID of the key encrypted layers were
+	// encrypted with.
+	AnnotationEncryptionKey = "com.maxmind.geoipupdate.encryption_key"
+	// AnnotationEncryptionKEK identifies the key-encryption key, including
+	// its version if known, that wrapped the data key of layers encrypted
+	// with a key management service.
+	AnnotationEncryptionKEK = "com.maxmind.geoipupdate.encryption_kek"
+	// AnnotationEncryptionWrappedKey is the base64-encoded wrapped data key
+	// of layers encrypted with a key management service.
+	AnnotationEncryptionWrappedKey = "com.maxmind.geoipupdate.encryption_wrapped_key"
+)
+
+// Repository is a repository of an OCI registry holding databases.
+//
+// After creation, it is valid for concurrent use.
+type Repository struct {
+	credentials credentials
+	// encoding is the encoding of the databases pushed.
+	encoding   encoding.Encoding
+	httpClient *http.Client
+	// key, if not nil, encrypts the databases pushed and decrypts those
+	// downloaded.
+	key *encryption.Key
+	// keyWrapper, if not nil, wraps the data keys of the databases pushed
+	// and unwraps those of the databases downloaded.
+	keyWrapper encryption.KeyWrapper
+	// name is the name of the repository in the registry.
+	name string
+	// registryURL is the base URL of the registry, e.g.,
+	// https://registry.example.com.
+	registryURL string
+
+	mu sync.Mutex
+	// authorization is the Authorization header obtained from the last
+	// challenge of the registry.
+	authorization string
+}
+
+// Option is a function type that modifies the behavior of the Repository.
+type Option func(*Repository)
+
+// WithHTTPClient sets the HTTP client used to talk to the registry.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(r *Repository) {
+		r.httpClient = httpClient
+	}
+}
+
+// WithCredentials sets the credentials used to authenticate to the
+// registry. By default, those of the registry in the Docker configuration
+// file are used, if any.
+func WithCredentials(username, password string) Option {
+	return func(r *Repository) {
+		r.credentials = credentials{username: username, password: password}
+	}
+}
+
+// WithEncoding sets the encoding of the databases pushed to the repository.
+// By default, databases are pushed as is. Databases are decoded when
+// downloaded whatever their encoding.
+func WithEncoding(e encoding.Encoding) Option {
+	return func(r *Repository) {
+		r.encoding = e
+	}
+}
+
+// WithEncryptionKey makes the databases pushed to the repository encrypted
+// with key, e.g., as the registry is shared, and the encrypted databases
+// downloaded decrypted with it. By default, databases are pushed
+// unencrypted, and encrypted databases can't be downloaded.
+func WithEncryptionKey(key encryption.Key) Option {
+	return func(r *Repository) {
+		r.key = &key
+	}
+}
+
+// WithKeyWrapper makes the databases pushed to the repository encrypted
+// with new data keys wrapped by w, e.g., with a key management service,
+// and stored along with them, and the databases downloaded decrypted with
+// the data keys w unwraps. It takes precedence over WithEncryptionKey for
+// the databases pushed.
+func WithKeyWrapper(w encryption.KeyWrapper) Option {
+	return func(r *Repository) {
+		r.keyWrapper = w
+	}
+}
+
+// New returns the repository designated by reference, e.g.,
+// registry.example.com/geoip/databases. The registry is accessed over
+// HTTPS unless reference starts with http://.
+func New(reference string, options ...Option) (*Repository, error) {
+	scheme := "https"
+	if rest, ok := strings.CutPrefix(reference, "http://"); ok {
+		scheme = "http"
+		reference = rest
+	} else {
+		reference = strings.TrimPrefix(reference, "https://")
+	}
+
+	host, name, ok := strings.Cut(strings.TrimSuffix(reference, "/"), "/")
+	if !ok || host == "" || name == "" {
+		return nil, fmt.Errorf("invalid OCI repository reference '%s': expected registry/repository", reference)
+	}
+
+	r := &Repository{
+		httpClient:  http.DefaultClient,
+		name:        name,
+		registryURL: scheme + "://" + host,
+	}
+	for _, opt := range options {
+		opt(r)
+	}
+	if r.credentials == (credentials{}) {
+		r.credentials = dockerCredentials(host)
+	}
+	return r, nil
+}
+
+// do sends the request made by newRequest to the registry, authenticating
+// as challenged by the registry. newRequest may be called again to retry
+// after a challenge.
+func (r *Repository) do(
+	ctx context.Context,
+	newRequest func() (*http.Request, error),
+) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "geoipupdate/"+vars.Version)
+
+	r.mu.Lock()
+	authorization := r.authorization
+	r.mu.Unlock()
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	response, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing registry request: %w", err)
+	}
+	if response.StatusCode != http.StatusUnauthorized {
+		return response, nil
+	}
+
+	challenge := response.Header.Get("WWW-Authenticate")
+	response.Body.Close()
+
+	authorization, err = r.authorize(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.authorization = authorization
+	r.mu.Unlock()
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "geoipupdate/"+vars.Version)
+	req.Header.Set("Authorization", authorization)
+
+	response, err = r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing registry request: %w", err)
+	}
+	return response, nil
+}
+
+// url returns the URL of path in the repository, e.g., "manifests/latest".
+func (r *Repository) url(path string) string {
+	return fmt.Sprintf("%s/v2/%s/%s", r.registryURL, r.name, path)
+}
+
+// statusError returns the error for an unexpected response, closing its
+// body.
+func statusError(response *http.Response) error {
+	defer response.Body.Close()
+	//nolint:errcheck // we are already returning an error.
+	buf, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+	return fmt.Errorf("unexpected HTTP status code: %w", internal.HTTPError{
+		Body:       string(buf),
+		StatusCode: response.StatusCode,
+	})
+}
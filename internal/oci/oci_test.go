@@ -0,0 +1,530 @@
+package oci
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+)
+
+func TestPushAndDownload(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := "GeoIP2-City content"
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	assert.Contains(t, registry.manifests, "GeoIP2-City")
+	assert.Contains(t, registry.manifests, "GeoIP2-City-20240102")
+
+	// Pushing the same build again is a no-op.
+	uploads := registry.uploads
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	assert.Equal(t, uploads, registry.uploads)
+
+	res, err := repo.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	require.True(t, res.UpdateAvailable)
+	assert.Equal(t, "md5-1", res.MD5)
+	assert.Equal(t, modifiedAt, res.LastModified)
+	got, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	require.NoError(t, res.Reader.Close())
+	assert.Equal(t, content, string(got))
+
+	res, err = repo.Download(ctx, "GeoIP2-City", "md5-1")
+	require.NoError(t, err)
+	assert.False(t, res.UpdateAvailable)
+
+	_, err = repo.Download(ctx, "GeoIP2-Country", "")
+	require.ErrorIs(t, err, errManifestNotFound)
+
+	// A corrupted blob is detected.
+	for digest := range registry.blobs {
+		if registry.blobs[digest] == content {
+			registry.blobs[digest] = "GeoIP2-City CONTENT"
+		}
+	}
+	res, err = repo.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	_, err = io.ReadAll(res.Reader)
+	require.EqualError(t, err, "the blob does not match its digest")
+	require.NoError(t, res.Reader.Close())
+
+	anonymous, err := New(registry.server.URL + "/geoip/databases")
+	require.NoError(t, err)
+	_, err = anonymous.Download(ctx, "GeoIP2-City", "")
+	require.ErrorContains(t, err, "unexpected HTTP status code")
+}
+
+func TestPushEncoded(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	gz, err := encoding.Lookup(encoding.Gzip)
+	require.NoError(t, err)
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+		WithEncoding(gz),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := strings.Repeat("GeoIP2-City content", 100)
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+
+	var m manifest
+	require.NoError(t, json.Unmarshal([]byte(registry.manifests["GeoIP2-City"]), &m))
+	assert.Equal(t, "gzip", m.Annotations[AnnotationEncoding])
+	require.Len(t, m.Layers, 1)
+	layer := m.Layers[0]
+	assert.Equal(t, MediaTypeDatabase+"+gzip", layer.MediaType)
+	assert.Equal(t, "GeoIP2-City.mmdb.gz", layer.Annotations[AnnotationTitle])
+	rawSum := sha256.Sum256([]byte(content))
+	assert.Equal(t, "sha256:"+hex.EncodeToString(rawSum[:]), layer.Annotations[AnnotationRawDigest])
+	assert.Less(t, layer.Size, int64(len(content)))
+
+	// Downloads are decoded, also by repositories pushing as is.
+	plain, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+	res, err := plain.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	got, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	require.NoError(t, res.Reader.Close())
+	assert.Equal(t, content, string(got))
+
+	// Changing the encoding pushes the build again.
+	require.NoError(t, plain.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	var pushed manifest
+	require.NoError(t, json.Unmarshal([]byte(registry.manifests["GeoIP2-City"]), &pushed))
+	assert.NotContains(t, pushed.Annotations, AnnotationEncoding)
+	assert.Equal(t, MediaTypeDatabase, pushed.Layers[0].MediaType)
+}
+
+func TestPushEncrypted(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	key, err := encryption.ParseKey([]byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="))
+	require.NoError(t, err)
+	gz, err := encoding.Lookup(encoding.Gzip)
+	require.NoError(t, err)
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+		WithEncoding(gz),
+		WithEncryptionKey(key),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := strings.Repeat("GeoIP2-City content", 100)
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+
+	var m manifest
+	require.NoError(t, json.Unmarshal([]byte(registry.manifests["GeoIP2-City"]), &m))
+	assert.Equal(t, encryption.Algorithm, m.Annotations[AnnotationEncryption])
+	assert.Equal(t, key.ID, m.Annotations[AnnotationEncryptionKey])
+	assert.Equal(t, "GeoIP2-City.mmdb.gz.enc", m.Layers[0].Annotations[AnnotationTitle])
+	for _, blob := range registry.blobs {
+		assert.NotContains(t, blob, "GeoIP2-City content")
+	}
+
+	res, err := repo.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	got, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	require.NoError(t, res.Reader.Close())
+	assert.Equal(t, content, string(got))
+
+	// Encrypted databases can't be downloaded without the key.
+	plain, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+	_, err = plain.Download(ctx, "GeoIP2-City", "")
+	require.EqualError(t, err, "the database of GeoIP2-City is encrypted, but no encryption key is set")
+
+	other, err := encryption.ParseKey([]byte("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="))
+	require.NoError(t, err)
+	otherRepo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+		WithEncryptionKey(other),
+	)
+	require.NoError(t, err)
+	_, err = otherRepo.Download(ctx, "GeoIP2-City", "")
+	require.EqualError(t, err, fmt.Sprintf(
+		"the database of GeoIP2-City is encrypted with the key %s rather than %s",
+		key.ID,
+		other.ID,
+	))
+
+	// Changing the key pushes the build again.
+	require.NoError(t, otherRepo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	require.NoError(t, json.Unmarshal([]byte(registry.manifests["GeoIP2-City"]), &m))
+	assert.Equal(t, other.ID, m.Annotations[AnnotationEncryptionKey])
+}
+
+// testKeyWrapper wraps keys by reversing them, with the version of its
+// key-encryption key.
+type testKeyWrapper struct {
+	version int
+}
+
+func (w *testKeyWrapper) Wrap(_ context.Context, dataKey []byte) (encryption.WrappedKey, error) {
+	wrapped := slices.Clone(dataKey)
+	slices.Reverse(wrapped)
+	return encryption.WrappedKey{KEK: fmt.Sprintf("kek@v%d", w.version), Ciphertext: wrapped}, nil
+}
+
+func (w *testKeyWrapper) Unwrap(_ context.Context, wrapped encryption.WrappedKey) ([]byte, error) {
+	key := slices.Clone(wrapped.Ciphertext)
+	slices.Reverse(key)
+	return key, nil
+}
+
+func TestPushKeyWrapper(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	wrapper := &testKeyWrapper{version: 1}
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+		WithKeyWrapper(wrapper),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := strings.Repeat("GeoIP2-City content", 100)
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	var m manifest
+	require.NoError(t, json.Unmarshal([]byte(registry.manifests["GeoIP2-City"]), &m))
+	assert.Equal(t, "kek@v1", m.Annotations[AnnotationEncryptionKEK])
+	assert.NotEmpty(t, m.Annotations[AnnotationEncryptionWrappedKey])
+
+	res, err := repo.Download(ctx, "GeoIP2-City", "")
+	require.NoError(t, err)
+	got, err := io.ReadAll(res.Reader)
+	require.NoError(t, err)
+	require.NoError(t, res.Reader.Close())
+	assert.Equal(t, content, string(got))
+
+	// Pushing the same build with the same key-encryption key is a no-op.
+	uploads := registry.uploads
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	assert.Equal(t, uploads, registry.uploads)
+
+	// The build is pushed again once the key-encryption key is rotated.
+	wrapper.version = 2
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, "md5-1", modifiedAt))
+	assert.Greater(t, registry.uploads, uploads)
+	require.NoError(t, json.Unmarshal([]byte(registry.manifests["GeoIP2-City"]), &m))
+	assert.Equal(t, "kek@v2", m.Annotations[AnnotationEncryptionKEK])
+
+	plain, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+	_, err = plain.Download(ctx, "GeoIP2-City", "")
+	require.EqualError(
+		t,
+		err,
+		"the database of GeoIP2-City is encrypted with a data key wrapped by kek@v2, "+
+			"but no key management service is set",
+	)
+}
+
+func TestEditionIDs(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for _, editionID := range []string{"GeoIP2-City", "GeoIP2-ISP-Test"} {
+		path := filepath.Join(t.TempDir(), editionID+".mmdb")
+		require.NoError(t, os.WriteFile(path, []byte(editionID), 0o600))
+		require.NoError(t, repo.Push(ctx, editionID, path, editionID, modifiedAt))
+	}
+
+	editionIDs, err := repo.EditionIDs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GeoIP2-City", "GeoIP2-ISP-Test"}, editionIDs)
+}
+
+func TestVerify(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := "GeoIP2-City content"
+	sum := md5.Sum([]byte(content))
+	md5sum := hex.EncodeToString(sum[:])
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	require.NoError(t, repo.Push(ctx, "GeoIP2-City", path, md5sum, modifiedAt))
+	require.NoError(t, repo.Verify(ctx, "GeoIP2-City", md5sum))
+	require.EqualError(
+		t,
+		repo.Verify(ctx, "GeoIP2-City", "other"),
+		"the repository holds the build "+md5sum+" of GeoIP2-City rather than other",
+	)
+
+	// The database doesn't match the MD5 sum it was pushed with.
+	require.NoError(t, repo.Push(ctx, "GeoIP2-ISP", path, "md5-1", modifiedAt))
+	require.EqualError(
+		t,
+		repo.Verify(ctx, "GeoIP2-ISP", "md5-1"),
+		"the database of GeoIP2-ISP read back has the MD5 sum "+md5sum+" rather than md5-1",
+	)
+
+	for digest := range registry.blobs {
+		if registry.blobs[digest] == content {
+			registry.blobs[digest] = "GeoIP2-City CONTENT"
+		}
+	}
+	require.EqualError(
+		t,
+		repo.Verify(ctx, "GeoIP2-City", md5sum),
+		"reading back GeoIP2-City: the blob does not match its digest",
+	)
+}
+
+func TestCheckPush(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	repo, err := New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "password"),
+	)
+	require.NoError(t, err)
+	require.NoError(t, repo.CheckPush(context.Background()))
+	assert.Equal(t, 0, registry.uploads)
+	assert.Equal(t, 1, registry.canceled)
+
+	repo, err = New(
+		registry.server.URL+"/geoip/databases",
+		WithCredentials("user", "wrong"),
+	)
+	require.NoError(t, err)
+	require.Error(t, repo.CheckPush(context.Background()))
+}
+
+func TestNew(t *testing.T) {
+	repo, err := New("registry.example.com/geoip/databases/")
+	require.NoError(t, err)
+	assert.Equal(t, "https://registry.example.com", repo.registryURL)
+	assert.Equal(t, "geoip/databases", repo.name)
+
+	_, err = New("registry.example.com")
+	require.EqualError(
+		t,
+		err,
+		"invalid OCI repository reference 'registry.example.com': expected registry/repository",
+	)
+}
+
+func TestDockerCredentials(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "config.json"),
+		[]byte(`{"auths": {"https://registry.example.com": {"auth": "dXNlcjpwYXNzd29yZA=="}}}`),
+		0o600,
+	))
+
+	assert.Equal(
+		t,
+		credentials{username: "user", password: "password"},
+		dockerCredentials("registry.example.com"),
+	)
+	assert.Equal(t, credentials{}, dockerCredentials("other.example.com"))
+}
+
+// testRegistry is a minimal OCI registry using token authentication.
+type testRegistry struct {
+	server    *httptest.Server
+	mu        sync.Mutex
+	blobs     map[string]string
+	manifests map[string]string
+	uploads   int
+	canceled  int
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		blobs:     map[string]string{},
+		manifests: map[string]string{},
+	}
+
+	const prefix = "/v2/geoip/databases/"
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if req.URL.Path == "/token" {
+			user, password, ok := req.BasicAuth()
+			if !ok || user != "user" || password != "password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:geoip/databases:pull,push", req.URL.Query().Get("scope"))
+			_, err := w.Write([]byte(`{"token": "secret"}`))
+			assert.NoError(t, err)
+			return
+		}
+
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set(
+				"WWW-Authenticate",
+				fmt.Sprintf(
+					`Bearer realm="%s/token",service="registry",scope="repository:geoip/databases:pull,push"`,
+					r.server.URL,
+				),
+			)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		path, ok := strings.CutPrefix(req.URL.Path, prefix)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		switch {
+		case path == "tags/list":
+			// Tags are listed one per page.
+			var tags []string
+			for tag := range r.manifests {
+				tags = append(tags, tag)
+			}
+			slices.Sort(tags)
+			i := 0
+			if last := req.URL.Query().Get("last"); last != "" {
+				i = slices.Index(tags, last) + 1
+			}
+			page := tags[i:]
+			if len(page) > 1 {
+				page = page[:1]
+				w.Header().Set("Link", fmt.Sprintf(`<%stags/list?last=%s>; rel="next"`, prefix, page[0]))
+			}
+			err := json.NewEncoder(w).Encode(map[string][]string{"tags": page})
+			assert.NoError(t, err)
+		case path == "blobs/uploads/" && req.Method == http.MethodPost:
+			w.Header().Set("Location", prefix+"blobs/uploads/1?session=1")
+			w.WriteHeader(http.StatusAccepted)
+		case path == "blobs/uploads/1" && req.Method == http.MethodPut:
+			assert.Equal(t, "1", req.URL.Query().Get("session"))
+			sum := sha256.Sum256(body)
+			digest := "sha256:" + hex.EncodeToString(sum[:])
+			if !assert.Equal(t, digest, req.URL.Query().Get("digest")) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.blobs[digest] = string(body)
+			r.uploads++
+			w.WriteHeader(http.StatusCreated)
+		case path == "blobs/uploads/1" && req.Method == http.MethodDelete:
+			r.canceled++
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasPrefix(path, "blobs/"):
+			blob, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if req.Method == http.MethodGet {
+				_, err := w.Write([]byte(blob))
+				assert.NoError(t, err)
+			}
+		case strings.HasPrefix(path, "manifests/"):
+			tag := strings.TrimPrefix(path, "manifests/")
+			if req.Method == http.MethodPut {
+				assert.Equal(t, mediaTypeManifest, req.Header.Get("Content-Type"))
+				var m manifest
+				if !assert.NoError(t, json.Unmarshal(body, &m)) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				r.manifests[tag] = string(body)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			m, ok := r.manifests[tag]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", mediaTypeManifest)
+			_, err := w.Write([]byte(m))
+			assert.NoError(t, err)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(r.server.Close)
+
+	return r
+}
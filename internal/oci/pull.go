@@ -0,0 +1,235 @@
+package oci
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+)
+
+// Download downloads the latest build of the edition from the repository.
+// It behaves like client.Client.Download.
+func (r *Repository) Download(
+	ctx context.Context,
+	editionID,
+	md5 string,
+) (client.DownloadResponse, error) {
+	m, err := r.getManifest(ctx, editionID)
+	if err != nil {
+		return client.DownloadResponse{}, err
+	}
+	layer, enc, err := m.database()
+	if err != nil {
+		return client.DownloadResponse{}, fmt.Errorf("%s: %w", editionID, err)
+	}
+
+	newMD5 := m.Annotations[AnnotationMD5]
+	if newMD5 == "" {
+		return client.DownloadResponse{}, fmt.Errorf("the manifest of %s has no MD5 annotation", editionID)
+	}
+	if newMD5 == md5 {
+		return client.DownloadResponse{
+			Reader:          io.NopCloser(strings.NewReader("")),
+			UpdateAvailable: false,
+		}, nil
+	}
+
+	var lastModified time.Time
+	if created := m.Annotations[AnnotationCreated]; created != "" {
+		lastModified, err = time.Parse(time.RFC3339, created)
+		if err != nil {
+			return client.DownloadResponse{}, fmt.Errorf("parsing the creation date of %s: %w", editionID, err)
+		}
+	}
+
+	key, err := r.decryptionKey(ctx, editionID, m)
+	if err != nil {
+		return client.DownloadResponse{}, err
+	}
+
+	body, err := r.getBlob(ctx, layer)
+	if err != nil {
+		return client.DownloadResponse{}, err
+	}
+	if key != nil || !enc.IsNone() {
+		var decrypted io.Reader = body
+		if key != nil {
+			decrypted, err = key.NewReader(body)
+			if err != nil {
+				body.Close()
+				return client.DownloadResponse{}, fmt.Errorf("decrypting %s: %w", editionID, err)
+			}
+		}
+		decoded, err := enc.NewReader(decrypted)
+		if err != nil {
+			body.Close()
+			return client.DownloadResponse{}, fmt.Errorf("decoding %s: %w", editionID, err)
+		}
+		body = &decodingReader{ReadCloser: decoded, blob: body}
+	}
+
+	_, host, _ := strings.Cut(r.registryURL, "://")
+	return client.DownloadResponse{
+		Host:            host,
+		LastModified:    lastModified.In(time.UTC),
+		MD5:             newMD5,
+		Reader:          body,
+		UpdateAvailable: true,
+	}, nil
+}
+
+// decryptionKey returns the key the database of the manifest m of the
+// edition must be decrypted with, or nil if it isn't encrypted.
+func (r *Repository) decryptionKey(ctx context.Context, editionID string, m *manifest) (*encryption.Key, error) {
+	algorithm := m.Annotations[AnnotationEncryption]
+	if algorithm == "" {
+		return nil, nil
+	}
+	if algorithm != encryption.Algorithm {
+		return nil, fmt.Errorf("the database of %s is encrypted with the unsupported %s", editionID, algorithm)
+	}
+
+	if wrappedKey := m.Annotations[AnnotationEncryptionWrappedKey]; wrappedKey != "" {
+		kek := m.Annotations[AnnotationEncryptionKEK]
+		if r.keyWrapper == nil {
+			return nil, fmt.Errorf(
+				"the database of %s is encrypted with a data key wrapped by %s, but no key management service is set",
+				editionID,
+				kek,
+			)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(wrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the data key of %s: %w", editionID, err)
+		}
+		key, err := encryption.UnwrapKey(ctx, r.keyWrapper, encryption.WrappedKey{KEK: kek, Ciphertext: ciphertext})
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %s: %w", editionID, err)
+		}
+		if key.ID != m.Annotations[AnnotationEncryptionKey] {
+			return nil, fmt.Errorf("the data key of %s does not match its ID", editionID)
+		}
+		return &key, nil
+	}
+
+	if r.key == nil {
+		return nil, fmt.Errorf("the database of %s is encrypted, but no encryption key is set", editionID)
+	}
+	if keyID := m.Annotations[AnnotationEncryptionKey]; keyID != r.key.ID {
+		return nil, fmt.Errorf(
+			"the database of %s is encrypted with the key %s rather than %s",
+			editionID,
+			keyID,
+			r.key.ID,
+		)
+	}
+	return r.key, nil
+}
+
+// Verify reads back the build of the edition the repository holds and checks
+// that it is the database with the MD5 sum md5sum, e.g., to catch pushes
+// corrupted on the way. The layer is also checked against its digest as it
+// is read.
+func (r *Repository) Verify(ctx context.Context, editionID, md5sum string) error {
+	res, err := r.Download(ctx, editionID, "")
+	if err != nil {
+		return err
+	}
+	defer res.Reader.Close()
+	if res.MD5 != md5sum {
+		return fmt.Errorf("the repository holds the build %s of %s rather than %s", res.MD5, editionID, md5sum)
+	}
+
+	h := md5.New()
+	if _, err := io.Copy(h, res.Reader); err != nil {
+		return fmt.Errorf("reading back %s: %w", editionID, err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != md5sum {
+		return fmt.Errorf(
+			"the database of %s read back has the MD5 sum %s rather than %s",
+			editionID,
+			sum,
+			md5sum,
+		)
+	}
+	return nil
+}
+
+// getBlob returns the content of the blob d describes, which is verified
+// against its digest as it is read.
+func (r *Repository) getBlob(ctx context.Context, d descriptor) (io.ReadCloser, error) {
+	digest, ok := strings.CutPrefix(d.Digest, "sha256:")
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest '%s'", d.Digest)
+	}
+
+	response, err := r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, r.url("blobs/"+d.Digest), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating blob request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting blob %s: %w", d.Digest, statusError(response))
+	}
+
+	return &verifyingReader{
+		ReadCloser: response.Body,
+		hash:       sha256.New(),
+		digest:     digest,
+		remaining:  d.Size,
+	}, nil
+}
+
+// decodingReader reads the decoded content of a blob, closing both the
+// decoder and the blob when closed.
+type decodingReader struct {
+	io.ReadCloser
+	blob io.Closer
+}
+
+func (d *decodingReader) Close() error {
+	return errors.Join(d.ReadCloser.Close(), d.blob.Close())
+}
+
+// verifyingReader checks that the content read from a blob matches its
+// digest and size, returning an error instead of io.EOF otherwise.
+type verifyingReader struct {
+	io.ReadCloser
+	hash      hash.Hash
+	digest    string
+	remaining int64
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	v.remaining -= int64(n)
+	if v.remaining < 0 {
+		return n, errors.New("the blob is larger than its descriptor")
+	}
+	if errors.Is(err, io.EOF) {
+		if v.remaining != 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+		if hex.EncodeToString(v.hash.Sum(nil)) != v.digest {
+			return n, errors.New("the blob does not match its digest")
+		}
+	}
+	return n, err
+}
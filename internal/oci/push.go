@@ -0,0 +1,390 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/encoding"
+	"github.com/maxmind/geoipupdate/v7/internal/encryption"
+)
+
+// Push pushes the database at path, the build of the edition with the MD5
+// sum md5 published at modifiedAt, to the repository, encoded with the
+// encoding of the repository and encrypted with its key, if any. Nothing is
+// pushed if the edition is already tagged with that build in that encoding
+// and encryption. With a KeyWrapper, the build is pushed again once the
+// key-encryption key is rotated, if the service tells its versions.
+func (r *Repository) Push(
+	ctx context.Context,
+	editionID,
+	path,
+	md5 string,
+	modifiedAt time.Time,
+) error {
+	current, err := r.getManifest(ctx, editionID)
+	if err != nil && !errors.Is(err, errManifestNotFound) {
+		return err
+	}
+	key, keyAnnotations, err := r.pushKey(ctx)
+	if err != nil {
+		return fmt.Errorf("encrypting %s: %w", editionID, err)
+	}
+	if current != nil && current.Annotations[AnnotationMD5] == md5 &&
+		current.Annotations[AnnotationEncoding] == r.encodingAnnotation() &&
+		keyIdentity(current.Annotations) == keyIdentity(keyAnnotations) {
+		return nil
+	}
+
+	layerPath, rawDigest := path, ""
+	if !r.encoding.IsNone() || key != nil {
+		layerPath, rawDigest, err = r.encodeFile(path, key)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(layerPath)
+	}
+
+	layer, err := r.pushFile(ctx, layerPath)
+	if err != nil {
+		return err
+	}
+	layer.MediaType = layerMediaType(r.encoding)
+	layer.Annotations = map[string]string{
+		AnnotationTitle: filepath.Base(path) + r.encoding.Extension + encryptionExtension(key),
+	}
+	if rawDigest != "" {
+		layer.Annotations[AnnotationRawDigest] = rawDigest
+	}
+
+	config, err := r.pushBlob(ctx, emptyConfig)
+	if err != nil {
+		return err
+	}
+	config.MediaType = mediaTypeEmpty
+
+	m := manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifest,
+		ArtifactType:  MediaTypeDatabase,
+		Config:        config,
+		Layers:        []descriptor{layer},
+		Annotations: map[string]string{
+			AnnotationCreated:   modifiedAt.UTC().Format(time.RFC3339),
+			AnnotationEditionID: editionID,
+			AnnotationMD5:       md5,
+		},
+	}
+	if name := r.encodingAnnotation(); name != "" {
+		m.Annotations[AnnotationEncoding] = name
+	}
+	for name, value := range keyAnnotations {
+		m.Annotations[name] = value
+	}
+	content, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	// The dated tag is pushed first so that the edition tag never points
+	// to a build that can't be found by date.
+	tags := []string{editionID + "-" + modifiedAt.UTC().Format("20060102"), editionID}
+	for _, tag := range tags {
+		if err := r.putManifest(ctx, tag, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodingAnnotation returns the AnnotationEncoding of the databases pushed,
+// which is empty for databases pushed as is.
+func (r *Repository) encodingAnnotation() string {
+	if r.encoding.IsNone() {
+		return ""
+	}
+	return r.encoding.Name
+}
+
+// pushKey returns the key the database pushed is encrypted with, which is
+// nil for unencrypted databases, along with the annotations recording it.
+// With a KeyWrapper, it is a new data key.
+func (r *Repository) pushKey(ctx context.Context) (*encryption.Key, map[string]string, error) {
+	switch {
+	case r.keyWrapper != nil:
+		key, wrapped, err := encryption.NewDataKey(ctx, r.keyWrapper)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &key, map[string]string{
+			AnnotationEncryption:           encryption.Algorithm,
+			AnnotationEncryptionKey:        key.ID,
+			AnnotationEncryptionKEK:        wrapped.KEK,
+			AnnotationEncryptionWrappedKey: base64.StdEncoding.EncodeToString(wrapped.Ciphertext),
+		}, nil
+	case r.key != nil:
+		return r.key, map[string]string{
+			AnnotationEncryption:    encryption.Algorithm,
+			AnnotationEncryptionKey: r.key.ID,
+		}, nil
+	default:
+		return nil, nil, nil
+	}
+}
+
+// keyIdentity returns what identifies the key of a database with
+// annotations: its key-encryption key for data keys, as each database has
+// its own, or else the key itself. It is empty for unencrypted databases.
+func keyIdentity(annotations map[string]string) string {
+	if kek := annotations[AnnotationEncryptionKEK]; kek != "" {
+		return kek
+	}
+	return annotations[AnnotationEncryptionKey]
+}
+
+// encryptionExtension returns the extension appended to the name of the
+// databases encrypted with key, if any.
+func encryptionExtension(key *encryption.Key) string {
+	if key == nil {
+		return ""
+	}
+	return ".enc"
+}
+
+// encodeFile encodes the file at path to a temporary file, encrypting it
+// with key if it isn't nil, returning its path along with the digest of the
+// file at path. The caller must remove the temporary file.
+func (r *Repository) encodeFile(path string, key *encryption.Key) (encodedPath, rawDigest string, err error) {
+	src, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", "", fmt.Errorf("opening database: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", filepath.Base(path)+"-*"+r.encoding.Extension)
+	if err != nil {
+		return "", "", fmt.Errorf("creating encoded database: %w", err)
+	}
+	defer func() {
+		if closeErr := dst.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing encoded database: %w", closeErr)
+		}
+		if err != nil {
+			_ = os.Remove(dst.Name())
+		}
+	}()
+
+	var encrypted io.Writer = dst
+	var ew io.WriteCloser
+	if key != nil {
+		ew, err = key.NewWriter(dst)
+		if err != nil {
+			return "", "", fmt.Errorf("encrypting database: %w", err)
+		}
+		encrypted = ew
+	}
+	enc := r.encoding
+	if enc.IsNone() {
+		// Databases are only encrypted.
+		enc, _ = encoding.Lookup(encoding.None)
+	}
+	w, err := enc.NewWriter(encrypted)
+	if err != nil {
+		return "", "", fmt.Errorf("encoding database: %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(w, io.TeeReader(src, h)); err != nil {
+		return "", "", fmt.Errorf("encoding database: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", "", fmt.Errorf("encoding database: %w", err)
+	}
+	if ew != nil {
+		if err := ew.Close(); err != nil {
+			return "", "", fmt.Errorf("encrypting database: %w", err)
+		}
+	}
+	return dst.Name(), "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pushFile pushes the content of the file at path as a blob.
+func (r *Repository) pushFile(ctx context.Context, path string) (descriptor, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return descriptor{}, fmt.Errorf("opening database: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return descriptor{}, fmt.Errorf("hashing database: %w", err)
+	}
+	d := descriptor{Digest: "sha256:" + hex.EncodeToString(h.Sum(nil)), Size: size}
+
+	err = r.uploadBlob(ctx, d, func() (io.Reader, error) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seeking database: %w", err)
+		}
+		// The file must outlive requests, which close their body.
+		return io.NopCloser(f), nil
+	})
+	if err != nil {
+		return descriptor{}, err
+	}
+	return d, nil
+}
+
+// pushBlob pushes content as a blob.
+func (r *Repository) pushBlob(ctx context.Context, content []byte) (descriptor, error) {
+	sum := sha256.Sum256(content)
+	d := descriptor{Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(content))}
+
+	err := r.uploadBlob(ctx, d, func() (io.Reader, error) {
+		return bytes.NewReader(content), nil
+	})
+	if err != nil {
+		return descriptor{}, err
+	}
+	return d, nil
+}
+
+// uploadBlob uploads the blob d describes, whose content is returned by
+// body, unless the repository already has it.
+func (r *Repository) uploadBlob(
+	ctx context.Context,
+	d descriptor,
+	body func() (io.Reader, error),
+) error {
+	response, err := r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodHead, r.url("blobs/"+d.Digest), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating blob request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	response, err = r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, r.url("blobs/uploads/"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating upload request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("starting upload of %s: %w", d.Digest, statusError(response))
+	}
+	response.Body.Close()
+
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("parsing upload location: %w", err)
+	}
+	query := location.Query()
+	query.Set("digest", d.Digest)
+	location.RawQuery = query.Encode()
+
+	response, err = r.do(ctx, func() (*http.Request, error) {
+		content, err := body()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodPut, location.String(), content)
+		if err != nil {
+			return nil, fmt.Errorf("creating upload request: %w", err)
+		}
+		req.ContentLength = d.Size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("uploading %s: %w", d.Digest, statusError(response))
+	}
+	response.Body.Close()
+	return nil
+}
+
+// CheckPush checks that the repository accepts pushes with the credentials
+// in use, without pushing anything: an upload is started and then canceled.
+func (r *Repository) CheckPush(ctx context.Context) error {
+	response, err := r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, r.url("blobs/uploads/"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating upload request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("starting upload: %w", statusError(response))
+	}
+	response.Body.Close()
+
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("parsing upload location: %w", err)
+	}
+	// Registries expire abandoned uploads, so failing to cancel it doesn't
+	// matter.
+	response, err = r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodDelete, location.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating upload cancellation request: %w", err)
+		}
+		return req, nil
+	})
+	if err == nil {
+		response.Body.Close()
+	}
+	return nil
+}
+
+// putManifest tags content, an encoded manifest, with tag.
+func (r *Repository) putManifest(ctx context.Context, tag string, content []byte) error {
+	response, err := r.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(
+			http.MethodPut,
+			r.url("manifests/"+url.PathEscape(tag)),
+			bytes.NewReader(content),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("creating manifest request: %w", err)
+		}
+		req.Header.Set("Content-Type", mediaTypeManifest)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("pushing manifest %s: %w", tag, statusError(response))
+	}
+	response.Body.Close()
+	return nil
+}
@@ -0,0 +1,78 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EditionIDs returns the IDs of the editions the repository holds, i.e.,
+// its tags, as the databases are tagged with their edition ID. The dated
+// tags of the builds are left out.
+func (r *Repository) EditionIDs(ctx context.Context) ([]string, error) {
+	var tags []string
+	next := r.url("tags/list")
+	for next != "" {
+		requestURL := next
+		response, err := r.do(ctx, func() (*http.Request, error) {
+			req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+			if err != nil {
+				return nil, fmt.Errorf("creating tag list request: %w", err)
+			}
+			return req, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("listing tags: %w", statusError(response))
+		}
+
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(io.LimitReader(response.Body, 4<<20)).Decode(&list)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing tag list: %w", err)
+		}
+		for _, tag := range list.Tags {
+			if !isDatedTag(tag) {
+				tags = append(tags, tag)
+			}
+		}
+
+		next = r.nextLink(response.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// isDatedTag returns whether tag is the dated tag of a build, e.g.,
+// GeoIP2-City-20240102.
+func isDatedTag(tag string) bool {
+	i := strings.LastIndexByte(tag, '-')
+	if i < 0 {
+		return false
+	}
+	_, err := time.Parse("20060102", tag[i+1:])
+	return err == nil
+}
+
+// nextLink returns the URL of the next page of a paginated list from the
+// Link header of a response, e.g., `</v2/geoip/tags/list?last=x>;
+// rel="next"`, or an empty string if it is the last page.
+func (r *Repository) nextLink(link string) string {
+	target, params, ok := strings.Cut(link, ";")
+	if !ok || !strings.Contains(params, `rel="next"`) {
+		return ""
+	}
+	target = strings.Trim(strings.TrimSpace(target), "<>")
+	if strings.HasPrefix(target, "/") {
+		return r.registryURL + target
+	}
+	return target
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package internal
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProcessRunning returns whether a process with the ID pid is running.
+func ProcessRunning(pid int) bool {
+	err := unix.Kill(pid, 0)
+	// The process exists but belongs to another user.
+	return err == nil || errors.Is(err, unix.EPERM)
+}
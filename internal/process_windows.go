@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code of processes that are still running.
+const stillActive = 259
+
+// ProcessRunning returns whether a process with the ID pid is running.
+func ProcessRunning(pid int) bool {
+	//nolint:gosec // process IDs fit in 32 bits on Windows.
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// The process exists but belongs to another user.
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck // nothing to do about it.
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
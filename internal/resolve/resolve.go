@@ -0,0 +1,136 @@
+// Package resolve resolves settings given by several sources in a fixed
+// order of precedence: defaults, then a configuration file, then environment
+// variables, then flags. A source overrides every setting it gives, whatever
+// the setting, and leaves the others as the sources of lower precedence set
+// them.
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Source is where settings come from.
+type Source string
+
+// Sources of settings.
+const (
+	Default Source = "default"
+	File    Source = "file"
+	Env     Source = "env"
+	Flag    Source = "flag"
+)
+
+// Order lists the sources from the lowest precedence to the highest.
+var Order = []Source{Default, File, Env, Flag}
+
+// Precedence returns the rank of s in Order, or -1 if s is unknown.
+func Precedence(s Source) int {
+	return slices.Index(Order, s)
+}
+
+// Layer sets the settings given by a source on a T.
+type Layer[T any] func(*T) error
+
+// Resolver sets the settings of a T from their sources, in Order.
+type Resolver[T any] struct {
+	layers  map[Source][]Layer[T]
+	early   []Source
+	observe func(Source, *T)
+}
+
+// New returns a Resolver with no layers.
+func New[T any]() *Resolver[T] {
+	return &Resolver[T]{layers: map[Source][]Layer[T]{}}
+}
+
+// Add adds layer to source. The layers of a source are applied in the order
+// they are added. It panics if source is unknown.
+func (r *Resolver[T]) Add(source Source, layer Layer[T]) *Resolver[T] {
+	if Precedence(source) < 0 {
+		panic(fmt.Sprintf("unknown source '%s'", source))
+	}
+	r.layers[source] = append(r.layers[source], layer)
+	return r
+}
+
+// ApplyEarly has the layers of source also applied right after the defaults,
+// so that they may locate the other sources, e.g., flags giving the
+// configuration file. The sources of lower precedence still override what
+// they set then, before they are applied again in their turn.
+func (r *Resolver[T]) ApplyEarly(source Source) *Resolver[T] {
+	r.early = append(r.early, source)
+	return r
+}
+
+// Observe has f called with v each time the layers of a source have been
+// applied to it, e.g., to record its Provenance.
+func (r *Resolver[T]) Observe(f func(source Source, v *T)) *Resolver[T] {
+	r.observe = f
+	return r
+}
+
+// Resolve applies the layers of each source to v, from the lowest precedence
+// to the highest. It stops at the first error.
+func (r *Resolver[T]) Resolve(v *T) error {
+	if err := r.apply(Default, v); err != nil {
+		return err
+	}
+	for _, source := range r.early {
+		if err := r.apply(source, v); err != nil {
+			return err
+		}
+	}
+	for _, source := range Order[1:] {
+		if err := r.apply(source, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver[T]) apply(source Source, v *T) error {
+	for _, layer := range r.layers[source] {
+		if err := layer(v); err != nil {
+			return err
+		}
+	}
+	if r.observe != nil {
+		r.observe(source, v)
+	}
+	return nil
+}
+
+// Provenance tracks the source of each setting from snapshots of the
+// settings taken as they are resolved. A nil Provenance records nothing.
+type Provenance struct {
+	sources   []Source
+	snapshots []map[string]any
+}
+
+// Record records settings, by key, as they are once the layers of source
+// have been applied.
+func (p *Provenance) Record(source Source, settings map[string]any) {
+	if p == nil {
+		return
+	}
+	p.sources = append(p.sources, source)
+	p.snapshots = append(p.snapshots, settings)
+}
+
+// Source returns the last source that changed the setting key, or Default
+// if none did. Settings computed from others once all sources are applied,
+// e.g., a path defaulting to one in a configured directory, are defaults.
+func (p *Provenance) Source(key string) Source {
+	source := Default
+	if p == nil {
+		return source
+	}
+	for i := 1; i < len(p.snapshots); i++ {
+		if !reflect.DeepEqual(p.snapshots[i-1][key], p.snapshots[i][key]) {
+			source = p.sources[i]
+		}
+	}
+	return source
+}
@@ -0,0 +1,114 @@
+package resolve
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type settings struct {
+	file    string
+	a, b, c string
+	applied []Source
+}
+
+func set(source Source, f func(*settings)) Layer[settings] {
+	return func(s *settings) error {
+		f(s)
+		s.applied = append(s.applied, source)
+		return nil
+	}
+}
+
+func TestResolveOrder(t *testing.T) {
+	r := New[settings]()
+	// Layers are added in an order other than that of precedence.
+	r.Add(Flag, set(Flag, func(s *settings) { s.c = "flag" }))
+	r.Add(Env, set(Env, func(s *settings) { s.b, s.c = "env", "env" }))
+	r.Add(File, set(File, func(s *settings) { s.a, s.b, s.c = "file", "file", "file" }))
+	r.Add(Default, set(Default, func(s *settings) { s.a, s.b, s.c = "default", "default", "default" }))
+
+	var s settings
+	require.NoError(t, r.Resolve(&s))
+	assert.Equal(t, "file", s.a)
+	assert.Equal(t, "env", s.b)
+	assert.Equal(t, "flag", s.c)
+	assert.Equal(t, Order, s.applied)
+}
+
+func TestResolveApplyEarly(t *testing.T) {
+	var fileSeen string
+	r := New[settings]().
+		Add(Default, func(s *settings) error {
+			s.file, s.a = "default.conf", "default"
+			return nil
+		}).
+		Add(File, func(s *settings) error {
+			fileSeen = s.file
+			s.a = "file"
+			return nil
+		}).
+		Add(Flag, func(s *settings) error {
+			s.file = "flag.conf"
+			return nil
+		}).
+		ApplyEarly(Flag)
+
+	var observed []Source
+	r.Observe(func(source Source, _ *settings) {
+		observed = append(observed, source)
+	})
+
+	var s settings
+	require.NoError(t, r.Resolve(&s))
+	assert.Equal(t, "flag.conf", fileSeen)
+	assert.Equal(t, "file", s.a)
+	assert.Equal(t, []Source{Default, Flag, File, Env, Flag}, observed)
+}
+
+func TestResolveError(t *testing.T) {
+	r := New[settings]().
+		Add(File, func(*settings) error { return errors.New("bad file") }).
+		Add(Env, func(s *settings) error {
+			s.b = "env"
+			return nil
+		})
+
+	var s settings
+	require.EqualError(t, r.Resolve(&s), "bad file")
+	assert.Empty(t, s.b)
+}
+
+func TestAddUnknownSource(t *testing.T) {
+	assert.Panics(t, func() {
+		New[settings]().Add("registry", func(*settings) error { return nil })
+	})
+}
+
+func TestPrecedence(t *testing.T) {
+	assert.Less(t, Precedence(Default), Precedence(File))
+	assert.Less(t, Precedence(File), Precedence(Env))
+	assert.Less(t, Precedence(Env), Precedence(Flag))
+	assert.Equal(t, -1, Precedence("registry"))
+}
+
+func TestProvenance(t *testing.T) {
+	p := &Provenance{}
+	p.Record(Default, map[string]any{"a": 1, "b": 1, "c": 1})
+	p.Record(Flag, map[string]any{"a": 1, "b": 1, "c": 2})
+	p.Record(File, map[string]any{"a": 3, "b": 3, "c": 3})
+	p.Record(Env, map[string]any{"a": 3, "b": 4, "c": 4})
+	// The flag sets the same value as the file and still takes precedence.
+	p.Record(Flag, map[string]any{"a": 3, "b": 4, "c": 2})
+
+	assert.Equal(t, File, p.Source("a"))
+	assert.Equal(t, Env, p.Source("b"))
+	assert.Equal(t, Flag, p.Source("c"))
+	assert.Equal(t, Default, p.Source("d"))
+
+	var nilProvenance *Provenance
+	nilProvenance.Record(File, map[string]any{"a": 1})
+	assert.Equal(t, Default, nilProvenance.Source("a"))
+}
@@ -0,0 +1,101 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// launchdLabel is the label of the launchd job.
+const launchdLabel = "com.maxmind.geoipupdate"
+
+// launchd schedules geoipupdate with a launchd job.
+type launchd struct {
+	dir string
+	run func(string, ...string) error
+}
+
+func launchdDirectory(system bool) (string, error) {
+	if system {
+		return "/Library/LaunchDaemons", nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating home directory: %w", err)
+	}
+	return filepath.Join(home, "Library", "LaunchAgents"), nil
+}
+
+func (l *launchd) path() string {
+	return filepath.Join(l.dir, launchdLabel+".plist")
+}
+
+// Install writes the job definition and loads it.
+func (l *launchd) Install(o Options) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	// Unload any existing job so that the new definition is picked up.
+	if _, err := os.Stat(l.path()); err == nil {
+		if err := l.run("launchctl", "unload", "-w", l.path()); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFile(l.path(), launchdPlist(o)); err != nil {
+		return err
+	}
+	return l.run("launchctl", "load", "-w", l.path())
+}
+
+// Uninstall unloads the job and removes its definition.
+func (l *launchd) Uninstall() error {
+	if _, err := os.Stat(l.path()); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no schedule installed at %s", l.path())
+	}
+
+	if err := l.run("launchctl", "unload", "-w", l.path()); err != nil {
+		return err
+	}
+	if err := os.Remove(l.path()); err != nil {
+		return fmt.Errorf("removing %s: %w", l.path(), err)
+	}
+	return nil
+}
+
+// launchdPlist returns the job definition. launchd has no native support
+// for random delays, so geoipupdate applies the splay itself.
+func launchdPlist(o Options) string {
+	args := append([]string{o.Binary}, o.Args...)
+	if o.Splay > 0 {
+		args = append(args, "--splay", o.Splay.String())
+	}
+
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString("\t\t<string>")
+		b.WriteString(xmlEscape(arg))
+		b.WriteString("</string>\n")
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>StartInterval</key>
+	<integer>%d</integer>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`, launchdLabel, b.String(), int64(o.Interval/time.Second))
+}
@@ -0,0 +1,104 @@
+// Package schedule installs and removes scheduler entries that run
+// geoipupdate periodically, using the scheduler native to the platform.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// name is the name under which geoipupdate is registered with the
+// scheduler.
+const name = "geoipupdate"
+
+// Options describes how geoipupdate is scheduled.
+type Options struct {
+	// Binary is the absolute path of the geoipupdate binary.
+	Binary string
+	// Args are the arguments geoipupdate is run with.
+	Args []string
+	// Interval is the time between two runs.
+	Interval time.Duration
+	// Splay is the maximum random delay added to each run so that many
+	// hosts don't update at the same time.
+	Splay time.Duration
+}
+
+func (o Options) validate() error {
+	if o.Binary == "" {
+		return errors.New("the geoipupdate binary path is required")
+	}
+	if o.Interval < time.Minute {
+		return fmt.Errorf("interval must be at least 1m, got %s", o.Interval)
+	}
+	if o.Splay < 0 {
+		return fmt.Errorf("splay can't be negative, got %s", o.Splay)
+	}
+	return nil
+}
+
+// Scheduler installs and removes the scheduler entry running geoipupdate.
+type Scheduler interface {
+	// Install creates the scheduler entry, replacing any existing one.
+	Install(Options) error
+	// Uninstall removes the scheduler entry.
+	Uninstall() error
+}
+
+// New returns the Scheduler for the current platform. System-wide
+// entries are created when running as root, per-user entries otherwise.
+func New() (Scheduler, error) {
+	system := os.Geteuid() == 0
+
+	switch runtime.GOOS {
+	case "linux":
+		dir, err := systemdUnitDirectory(system)
+		if err != nil {
+			return nil, err
+		}
+		return &systemd{dir: dir, user: !system, run: runCommand}, nil
+	case "darwin":
+		dir, err := launchdDirectory(system)
+		if err != nil {
+			return nil, err
+		}
+		return &launchd{dir: dir, run: runCommand}, nil
+	case "windows":
+		return &taskScheduler{run: runCommand}, nil
+	default:
+		return nil, fmt.Errorf("scheduling is not supported on %s", runtime.GOOS)
+	}
+}
+
+// runCommand runs a scheduler command, including its output in any error.
+func runCommand(command string, args ...string) error {
+	out, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(
+			"running %s %s: %w: %s",
+			command,
+			strings.Join(args, " "),
+			err,
+			strings.TrimSpace(string(out)),
+		)
+	}
+	return nil
+}
+
+// writeFile writes an entry definition, creating its directory if needed.
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+	//nolint:gosec // scheduler definitions must be readable by the scheduler.
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
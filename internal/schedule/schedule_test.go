@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testOptions = Options{
+	Binary:   "/usr/local/bin/geoipupdate",
+	Args:     []string{"-f", "/etc/My GeoIP.conf"},
+	Interval: 12 * time.Hour,
+	Splay:    time.Hour,
+}
+
+func TestSystemdUnits(t *testing.T) {
+	service, timer := systemdUnits(testOptions)
+
+	require.Contains(
+		t,
+		service,
+		"ExecStart=/usr/local/bin/geoipupdate -f \"/etc/My GeoIP.conf\"\n",
+	)
+	require.Contains(t, timer, "OnUnitActiveSec=43200s\n")
+	require.Contains(t, timer, "RandomizedDelaySec=3600s\n")
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := launchdPlist(testOptions)
+
+	require.Contains(t, plist, `		<string>/usr/local/bin/geoipupdate</string>
+		<string>-f</string>
+		<string>/etc/My GeoIP.conf</string>
+		<string>--splay</string>
+		<string>1h0m0s</string>
+`)
+	require.Contains(t, plist, "<integer>43200</integer>")
+}
+
+func TestTaskXML(t *testing.T) {
+	start := time.Date(2024, 2, 23, 10, 0, 0, 0, time.UTC)
+	task := taskXML(testOptions, start)
+
+	require.Contains(t, task, "<StartBoundary>2024-02-23T10:00:00</StartBoundary>")
+	require.Contains(t, task, "<Interval>PT43200S</Interval>")
+	require.Contains(t, task, "<RandomDelay>PT3600S</RandomDelay>")
+	require.Contains(t, task, "<Command>/usr/local/bin/geoipupdate</Command>")
+	require.Contains(t, task, `<Arguments>-f &#34;/etc/My GeoIP.conf&#34;</Arguments>`)
+}
+
+func TestOptionsValidate(t *testing.T) {
+	o := testOptions
+	o.Interval = time.Second
+	require.EqualError(t, o.validate(), "interval must be at least 1m, got 1s")
+
+	o = testOptions
+	o.Splay = -time.Second
+	require.EqualError(t, o.validate(), "splay can't be negative, got -1s")
+}
+
+// TestSystemdInstall tests that installing and uninstalling write and
+// remove the units and drive systemctl accordingly.
+func TestSystemdInstall(t *testing.T) {
+	dir := t.TempDir()
+	var commands [][]string
+	s := &systemd{
+		dir:  dir,
+		user: true,
+		run: func(command string, args ...string) error {
+			commands = append(commands, append([]string{command}, args...))
+			return nil
+		},
+	}
+
+	require.NoError(t, s.Install(testOptions))
+	require.FileExists(t, filepath.Join(dir, "geoipupdate.service"))
+	require.FileExists(t, filepath.Join(dir, "geoipupdate.timer"))
+
+	require.NoError(t, s.Uninstall())
+	_, err := os.Stat(filepath.Join(dir, "geoipupdate.timer"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(dir, "geoipupdate.service"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.Equal(
+		t,
+		[][]string{
+			{"systemctl", "--user", "daemon-reload"},
+			{"systemctl", "--user", "enable", "--now", "geoipupdate.timer"},
+			{"systemctl", "--user", "disable", "--now", "geoipupdate.timer"},
+			{"systemctl", "--user", "daemon-reload"},
+		},
+		commands,
+	)
+
+	require.EqualError(
+		t,
+		s.Uninstall(),
+		"no schedule installed at "+filepath.Join(dir, "geoipupdate.timer"),
+	)
+}
@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// systemd schedules geoipupdate with a systemd timer.
+type systemd struct {
+	dir  string
+	user bool
+	run  func(string, ...string) error
+}
+
+func systemdUnitDirectory(system bool) (string, error) {
+	if system {
+		return "/etc/systemd/system", nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locating user configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "systemd", "user"), nil
+}
+
+// Install writes the service and timer units and enables the timer.
+func (s *systemd) Install(o Options) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	service, timer := systemdUnits(o)
+	if err := writeFile(filepath.Join(s.dir, name+".service"), service); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.dir, name+".timer"), timer); err != nil {
+		return err
+	}
+
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return s.systemctl("enable", "--now", name+".timer")
+}
+
+// Uninstall disables the timer and removes the units.
+func (s *systemd) Uninstall() error {
+	timer := filepath.Join(s.dir, name+".timer")
+	if _, err := os.Stat(timer); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no schedule installed at %s", timer)
+	}
+
+	if err := s.systemctl("disable", "--now", name+".timer"); err != nil {
+		return err
+	}
+	for _, unit := range []string{name + ".timer", name + ".service"} {
+		path := filepath.Join(s.dir, unit)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+	}
+	return s.systemctl("daemon-reload")
+}
+
+func (s *systemd) systemctl(args ...string) error {
+	if s.user {
+		args = append([]string{"--user"}, args...)
+	}
+	return s.run("systemctl", args...)
+}
+
+// systemdUnits returns the content of the service and timer units.
+func systemdUnits(o Options) (service, timer string) {
+	command := make([]string, 0, len(o.Args)+1)
+	for _, arg := range append([]string{o.Binary}, o.Args...) {
+		command = append(command, systemdQuote(arg))
+	}
+
+	service = fmt.Sprintf(`[Unit]
+Description=Update GeoIP databases
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, strings.Join(command, " "))
+
+	timer = fmt.Sprintf(`[Unit]
+Description=Update GeoIP databases periodically
+
+[Timer]
+OnBootSec=%s
+OnUnitActiveSec=%s
+RandomizedDelaySec=%s
+
+[Install]
+WantedBy=timers.target
+`, systemdDuration(5*time.Minute), systemdDuration(o.Interval), systemdDuration(o.Splay))
+
+	return service, timer
+}
+
+func systemdDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d/time.Second))
+}
+
+// systemdQuote quotes arg for use in ExecStart if needed.
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\%$;") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`, `$`, `$$`)
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,118 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// taskScheduler schedules geoipupdate with a Windows scheduled task.
+type taskScheduler struct {
+	run func(string, ...string) error
+}
+
+// Install registers the task from its XML definition, replacing any
+// existing one.
+func (s *taskScheduler) Install(o Options) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", name+"-*.xml")
+	if err != nil {
+		return fmt.Errorf("creating task definition: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	// schtasks expects the definition to be encoded in UTF-16.
+	if _, err := f.Write(utf16LE(taskXML(o, time.Now()))); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing task definition: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing task definition: %w", err)
+	}
+
+	return s.run("schtasks", "/Create", "/TN", name, "/XML", f.Name(), "/F")
+}
+
+// Uninstall deletes the task.
+func (s *taskScheduler) Uninstall() error {
+	return s.run("schtasks", "/Delete", "/TN", name, "/F")
+}
+
+// taskXML returns the task definition, with the first run at start.
+func taskXML(o Options, start time.Time) string {
+	args := make([]string, 0, len(o.Args))
+	for _, arg := range o.Args {
+		args = append(args, windowsQuote(arg))
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Update GeoIP databases</Description>
+  </RegistrationInfo>
+  <Triggers>
+    <TimeTrigger>
+      <StartBoundary>%s</StartBoundary>
+      <Enabled>true</Enabled>
+      <Repetition>
+        <Interval>%s</Interval>
+      </Repetition>
+      <RandomDelay>%s</RandomDelay>
+    </TimeTrigger>
+  </Triggers>
+  <Settings>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <RunOnlyIfNetworkAvailable>true</RunOnlyIfNetworkAvailable>
+  </Settings>
+  <Actions>
+    <Exec>
+      <Command>%s</Command>
+      <Arguments>%s</Arguments>
+    </Exec>
+  </Actions>
+</Task>
+`,
+		start.Format("2006-01-02T15:04:05"),
+		isoDuration(o.Interval),
+		isoDuration(o.Splay),
+		xmlEscape(o.Binary),
+		xmlEscape(strings.Join(args, " ")),
+	)
+}
+
+// isoDuration formats d as an ISO 8601 duration with second precision.
+func isoDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%dS", int64(d/time.Second))
+}
+
+// windowsQuote quotes arg for the Windows command line if needed.
+func windowsQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	//nolint:errcheck // writing to a bytes.Buffer never fails.
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func utf16LE(s string) []byte {
+	// Start with the byte order mark.
+	b := []byte{0xff, 0xfe}
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r), byte(r>>8))
+	}
+	return b
+}
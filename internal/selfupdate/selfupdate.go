@@ -0,0 +1,382 @@
+// Package selfupdate replaces the geoipupdate binary with the latest release
+// published on GitHub, after verifying its signature and checksum.
+//
+// Releases ship a checksums file, signed with the release ed25519 key, which
+// lists the SHA-256 digest of every archive.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultURL is the GitHub API URL of the geoipupdate repository.
+const DefaultURL = "https://api.github.com/repos/maxmind/geoipupdate"
+
+// maxDownloadSize bounds the size of the files downloaded from a release.
+const maxDownloadSize = 200 << 20
+
+// ErrNoPublicKey is returned by New for builds without the release signing
+// key, such as distribution packages, which are meant to be updated by their
+// package manager.
+var ErrNoPublicKey = errors.New("this build of geoipupdate has no release signing key")
+
+// Release is a geoipupdate release.
+type Release struct {
+	// Version is the version of the release, e.g., "7.1.0".
+	Version string
+	// assets maps the names of the release files to their download URLs.
+	assets map[string]string
+}
+
+// Updater finds and installs geoipupdate releases.
+type Updater struct {
+	httpClient *http.Client
+	url        string
+	publicKey  ed25519.PublicKey
+	goos       string
+	goarch     string
+}
+
+// Option is a function type that modifies the behavior of the Updater.
+type Option func(*Updater)
+
+// WithHTTPClient sets the HTTP client used to talk to GitHub.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(u *Updater) {
+		u.httpClient = httpClient
+	}
+}
+
+// WithURL sets the GitHub API URL of the repository releases are looked up
+// in. It defaults to DefaultURL.
+func WithURL(url string) Option {
+	return func(u *Updater) {
+		u.url = strings.TrimSuffix(url, "/")
+	}
+}
+
+// New returns an Updater verifying releases with publicKey, a base64-encoded
+// ed25519 public key.
+func New(publicKey string, options ...Option) (*Updater, error) {
+	if publicKey == "" {
+		return nil, ErrNoPublicKey
+	}
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid release signing key")
+	}
+
+	u := &Updater{
+		httpClient: http.DefaultClient,
+		url:        DefaultURL,
+		publicKey:  ed25519.PublicKey(key),
+		goos:       runtime.GOOS,
+		goarch:     runtime.GOARCH,
+	}
+	for _, option := range options {
+		option(u)
+	}
+	return u, nil
+}
+
+// Latest returns the latest release.
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	body, err := u.get(ctx, u.url+"/releases/latest")
+	if err != nil {
+		return nil, fmt.Errorf("fetching latest release: %w", err)
+	}
+
+	var response struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("decoding latest release: %w", err)
+	}
+
+	release := &Release{
+		Version: strings.TrimPrefix(response.TagName, "v"),
+		assets:  map[string]string{},
+	}
+	for _, asset := range response.Assets {
+		release.assets[asset.Name] = asset.URL
+	}
+	return release, nil
+}
+
+// Install downloads the archive of release for the current platform,
+// verifies it, and atomically replaces binary with the geoipupdate binary it
+// contains.
+func (u *Updater) Install(ctx context.Context, release *Release, binary string) error {
+	checksumsName := fmt.Sprintf("geoipupdate_%s_checksums.txt", release.Version)
+	checksums, err := u.download(ctx, release, checksumsName)
+	if err != nil {
+		return err
+	}
+	signature, err := u.download(ctx, release, checksumsName+".sig")
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(u.publicKey, checksums, signature) {
+		return fmt.Errorf("invalid signature for %s", checksumsName)
+	}
+
+	archiveName := u.archiveName(release.Version)
+	expected, err := findChecksum(checksums, archiveName)
+	if err != nil {
+		return err
+	}
+	archive, err := u.download(ctx, release, archiveName)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(archive); hex.EncodeToString(sum[:]) != expected {
+		return fmt.Errorf("checksum mismatch for %s", archiveName)
+	}
+
+	data, err := u.extractBinary(archive)
+	if err != nil {
+		return fmt.Errorf("extracting %s: %w", archiveName, err)
+	}
+
+	return replace(binary, data)
+}
+
+// archiveName returns the name of the release archive for the platform of
+// u, following the naming of goreleaser.
+func (u *Updater) archiveName(version string) string {
+	arch := u.goarch
+	if arch == "arm" {
+		arch = "armv6"
+	}
+	ext := ".tar.gz"
+	if u.goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("geoipupdate_%s_%s_%s%s", version, u.goos, arch, ext)
+}
+
+func (u *Updater) download(ctx context.Context, release *Release, name string) ([]byte, error) {
+	url, ok := release.assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no file %s", release.Version, name)
+	}
+	body, err := u.get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", name, err)
+	}
+	return body, nil
+}
+
+func (u *Updater) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	response, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing HTTP request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if len(body) > maxDownloadSize {
+		return nil, errors.New("response body too large")
+	}
+	return body, nil
+}
+
+// findChecksum returns the hex-encoded SHA-256 digest of name listed in
+// checksums, in the format of sha256sum.
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("reading checksums: %w", err)
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// extractBinary returns the content of the geoipupdate binary in archive.
+func (u *Updater) extractBinary(archive []byte) ([]byte, error) {
+	name := "geoipupdate"
+	if u.goos == "windows" {
+		name += ".exe"
+		return extractZip(archive, name)
+	}
+	return extractTarGz(archive, name)
+}
+
+func extractTarGz(archive []byte, name string) ([]byte, error) {
+	gzReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("creating gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s not found", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || path.Base(header.Name) != name {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tarReader, maxDownloadSize))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		return data, nil
+	}
+}
+
+func extractZip(archive []byte, name string) ([]byte, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("reading zip archive: %w", err)
+	}
+	for _, f := range zipReader.File {
+		if f.FileInfo().IsDir() || path.Base(f.Name) != name {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", name, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(io.LimitReader(r, maxDownloadSize))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+// replace atomically replaces binary with data, keeping its permissions.
+// The new binary is written next to binary so that the final rename doesn't
+// cross file systems.
+func replace(binary string, data []byte) error {
+	info, err := os.Stat(binary)
+	if err != nil {
+		return fmt.Errorf("getting binary information: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(binary), ".geoipupdate-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		//nolint:errcheck // the file no longer exists once renamed.
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("syncing new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("setting new binary permissions: %w", err)
+	}
+
+	// Windows doesn't allow replacing a running executable, but allows
+	// renaming it.
+	if runtime.GOOS == "windows" {
+		old := binary + ".old"
+		//nolint:errcheck // a previous binary is usually not there.
+		_ = os.Remove(old)
+		if err := os.Rename(binary, old); err != nil {
+			return fmt.Errorf("moving current binary aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), binary); err != nil {
+		return fmt.Errorf("replacing binary: %w", err)
+	}
+	return nil
+}
+
+// IsNewer returns whether version latest is more recent than version
+// current. Both are of the form "major.minor.patch", optionally with a "v"
+// prefix and a pre-release suffix, which is ignored.
+func IsNewer(current, latest string) (bool, error) {
+	c, err := parseVersion(current)
+	if err != nil {
+		return false, err
+	}
+	l, err := parseVersion(latest)
+	if err != nil {
+		return false, err
+	}
+	for i := range c {
+		if l[i] != c[i] {
+			return l[i] > c[i], nil
+		}
+	}
+	return false, nil
+}
+
+func parseVersion(version string) ([3]int, error) {
+	var parsed [3]int
+	v := strings.TrimPrefix(version, "v")
+	v, _, _ = strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) != len(parsed) {
+		return parsed, fmt.Errorf("invalid version %q", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("invalid version %q", version)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
@@ -0,0 +1,198 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstall(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	archiveName := "geoipupdate_7.1.0_linux_amd64.tar.gz"
+	archive := tarGz(t, "geoipupdate_7.1.0_linux_amd64/geoipupdate", "new binary")
+	sum := sha256.Sum256(archive)
+	goodChecksums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), archiveName)
+
+	tests := []struct {
+		description string
+		checksums   string
+		signingKey  ed25519.PrivateKey
+		err         string
+	}{
+		{
+			description: "valid release",
+			checksums:   goodChecksums,
+			signingKey:  privateKey,
+		},
+		{
+			description: "signed with another key",
+			checksums:   goodChecksums,
+			signingKey:  otherKey,
+			err:         "invalid signature for geoipupdate_7.1.0_checksums.txt",
+		},
+		{
+			description: "checksum mismatch",
+			checksums:   fmt.Sprintf("%064d  %s\n", 0, archiveName),
+			signingKey:  privateKey,
+			err:         "checksum mismatch for " + archiveName,
+		},
+		{
+			description: "archive not listed",
+			checksums:   "",
+			signingKey:  privateKey,
+			err:         "no checksum for " + archiveName,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			files := map[string][]byte{
+				"geoipupdate_7.1.0_checksums.txt": []byte(test.checksums),
+				"geoipupdate_7.1.0_checksums.txt.sig": ed25519.Sign(
+					test.signingKey,
+					[]byte(test.checksums),
+				),
+				archiveName: archive,
+			}
+			server := releaseServer(t, "v7.1.0", files)
+			defer server.Close()
+
+			u, err := New(
+				base64.StdEncoding.EncodeToString(publicKey),
+				WithURL(server.URL),
+			)
+			require.NoError(t, err)
+			u.goos = "linux"
+			u.goarch = "amd64"
+
+			binary := filepath.Join(t.TempDir(), "geoipupdate")
+			require.NoError(t, os.WriteFile(binary, []byte("old binary"), 0o755))
+
+			release, err := u.Latest(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, "7.1.0", release.Version)
+
+			err = u.Install(context.Background(), release, binary)
+
+			content, readErr := os.ReadFile(binary)
+			require.NoError(t, readErr)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				require.Equal(t, "old binary", string(content))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "new binary", string(content))
+
+			info, err := os.Stat(binary)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+			entries, err := os.ReadDir(filepath.Dir(binary))
+			require.NoError(t, err)
+			require.Len(t, entries, 1, "temporary file removed")
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("")
+	require.ErrorIs(t, err, ErrNoPublicKey)
+
+	_, err = New("bm90IGEga2V5")
+	require.EqualError(t, err, "invalid release signing key")
+}
+
+func TestIsNewer(t *testing.T) {
+	tests := []struct {
+		current  string
+		latest   string
+		expected bool
+		err      string
+	}{
+		{current: "7.0.1", latest: "7.1.0", expected: true},
+		{current: "7.1.0", latest: "7.1.0", expected: false},
+		{current: "7.10.0", latest: "7.9.3", expected: false},
+		{current: "v6.1.0", latest: "7.0.0", expected: true},
+		{current: "7.1.0-beta.1", latest: "7.1.1", expected: true},
+		{current: "unknown", latest: "7.1.0", err: `invalid version "unknown"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.current+" "+test.latest, func(t *testing.T) {
+			newer, err := IsNewer(test.current, test.latest)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, newer)
+		})
+	}
+}
+
+// releaseServer serves a GitHub release made of files.
+func releaseServer(t *testing.T, tag string, files map[string][]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	type asset struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	}
+	var assets []asset
+	for name, content := range files {
+		content := content
+		assets = append(assets, asset{Name: name, URL: server.URL + "/download/" + name})
+		mux.HandleFunc("/download/"+name, func(w http.ResponseWriter, _ *http.Request) {
+			_, err := w.Write(content)
+			require.NoError(t, err)
+		})
+	}
+
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		err := json.NewEncoder(w).Encode(map[string]any{
+			"tag_name": tag,
+			"assets":   assets,
+		})
+		require.NoError(t, err)
+	})
+
+	return server
+}
+
+func tarGz(t *testing.T, name, content string) []byte {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o755,
+		Size:     int64(len(content)),
+		Typeflag: tar.TypeReg,
+	}))
+	_, err := tarWriter.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzWriter.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,186 @@
+// Package sigv4 signs requests to AWS services with Signature Version 4.
+package sigv4
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EmptyPayloadHash is the SHA-256 of an empty body.
+const EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// UnsignedPayload is the payload hash of requests whose body isn't signed,
+// e.g., large uploads to Amazon S3 over HTTPS.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Credentials are AWS credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only needed for temporary credentials.
+	SessionToken string
+}
+
+// CredentialsProvider provides the credentials of signatures, e.g., the
+// temporary credentials of an IAM role, renewed as they expire.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// Signer signs requests to an AWS service.
+type Signer struct {
+	// Region is the AWS region of the service, e.g., "us-east-1".
+	Region string
+	// Service is the name of the service in signatures, e.g., "s3".
+	Service         string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only needed for temporary credentials.
+	SessionToken string
+	// Provider, if set, provides the credentials of each signature instead
+	// of the fields above.
+	Provider CredentialsProvider
+	// Now returns the time of signatures. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Authenticate signs req, a request without a body, such as a GET request,
+// adding the Authorization and X-Amz-* headers. It must be called once all
+// other headers are set.
+func (s *Signer) Authenticate(req *http.Request) error {
+	return s.sign(req, EmptyPayloadHash)
+}
+
+// AuthenticatePayload signs req like Authenticate, for requests whose body
+// is payload, such as the POST requests of JSON APIs.
+func (s *Signer) AuthenticatePayload(req *http.Request, payload []byte) error {
+	sum := sha256.Sum256(payload)
+	return s.sign(req, hex.EncodeToString(sum[:]))
+}
+
+// AuthenticateUnsignedPayload signs req like Authenticate, leaving its body
+// out of the signature, so that it can be streamed. Only Amazon S3 supports
+// it.
+func (s *Signer) AuthenticateUnsignedPayload(req *http.Request) error {
+	return s.sign(req, UnsignedPayload)
+}
+
+// sign signs req, whose body has the SHA-256 payloadHash.
+func (s *Signer) sign(req *http.Request, payloadHash string) error {
+	creds := Credentials{
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+	}
+	if s.Provider != nil {
+		var err error
+		creds, err = s.Provider.Credentials(req.Context())
+		if err != nil {
+			return fmt.Errorf("getting AWS credentials: %w", err)
+		}
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		creds.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+	return nil
+}
+
+// canonicalQuery returns the query sorted and encoded as SigV4 expects.
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -3,6 +3,11 @@
 
 package vars
 
+import (
+	"os"
+	"path/filepath"
+)
+
 var (
 	// These match what you'd get building the C geoipupdate from source.
 
@@ -14,3 +19,17 @@ var (
 	// saving to the local file system.
 	DefaultDatabaseDirectory = "/usr/local/share/GeoIP"
 )
+
+// userDataDir returns $XDG_DATA_HOME, or its default, ~/.local/share, if it
+// isn't set or isn't an absolute path, as per the XDG Base Directory
+// Specification.
+func userDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
@@ -1,6 +1,7 @@
 package vars
 
 import (
+	"errors"
 	"os"
 )
 
@@ -10,3 +11,13 @@ var (
 	DefaultConfigFile        = os.Getenv("SYSTEMDRIVE") + `\ProgramData\MaxMind\GeoIPUpdate\GeoIP.conf`
 	DefaultDatabaseDirectory = os.Getenv("SYSTEMDRIVE") + `\ProgramData\MaxMind\GeoIPUpdate\GeoIP`
 )
+
+// userDataDir returns %LocalAppData%, where per-user data that doesn't roam
+// with the profile belongs.
+func userDataDir() (string, error) {
+	dir := os.Getenv("LocalAppData")
+	if dir == "" {
+		return "", errors.New("%LocalAppData% is not defined")
+	}
+	return dir, nil
+}
@@ -0,0 +1,57 @@
+package vars
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// UserConfigFile returns the configuration file of the current user, in the
+// GeoIP.conf format, e.g., $XDG_CONFIG_HOME/geoipupdate/config on Linux.
+func UserConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "geoipupdate", "config"), nil
+}
+
+// UserDatabaseDirectory returns the database directory of the current user,
+// e.g., $XDG_DATA_HOME/geoipupdate on Linux.
+func UserDatabaseDirectory() (string, error) {
+	dir, err := userDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "geoipupdate"), nil
+}
+
+// UseUserDefaults makes DefaultConfigFile and DefaultDatabaseDirectory those
+// of the current user, so that geoipupdate can run without privileges. The
+// lock and state files follow the database directory.
+func UseUserDefaults() error {
+	configFile, err := UserConfigFile()
+	if err != nil {
+		return err
+	}
+	databaseDirectory, err := UserDatabaseDirectory()
+	if err != nil {
+		return err
+	}
+	DefaultConfigFile = configFile
+	DefaultDatabaseDirectory = databaseDirectory
+	return nil
+}
+
+// PreferUserDefaults returns whether the defaults of the current user are to
+// be used without being asked for, which is when it isn't root and there is
+// no system-wide configuration file, so that existing setups running as
+// other users keep using the system-wide defaults.
+func PreferUserDefaults() bool {
+	// Geteuid returns -1 on Windows, where there is no such distinction.
+	if euid := os.Geteuid(); euid == 0 || euid == -1 {
+		return false
+	}
+	_, err := os.Stat(DefaultConfigFile)
+	return errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,115 @@
+// Package geoipupdate updates GeoIP2 and GeoLite2 databases from Go
+// programs, the way the geoipupdate program does: only the databases that
+// changed are downloaded, they are installed atomically, and the updates of
+// a database directory are serialized with a lock file. Unlike the program,
+// it reads neither a configuration file nor the environment, and is only
+// configured with options.
+//
+// To download databases without installing them, use the client package
+// instead.
+package geoipupdate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate"
+)
+
+// Client updates the databases of a set of editions.
+type Client struct {
+	updater *geoipupdate.Updater
+}
+
+// ReadResult is the outcome of the update of an edition.
+type ReadResult struct {
+	EditionID string `json:"edition_id"`
+	// OldHash is the MD5 sum of the database installed before the update,
+	// if any.
+	OldHash string `json:"old_hash"`
+	// NewHash is the MD5 sum of the database installed after the update.
+	NewHash string `json:"new_hash"`
+	// ModifiedAt is when the installed database was built.
+	ModifiedAt time.Time `json:"modified_at"`
+	// CheckedAt is when the edition was checked for updates.
+	CheckedAt time.Time `json:"checked_at"`
+	// Cached is true if the updates server was not contacted because the
+	// installed database was checked recently, as per WithCacheMaxAge.
+	Cached bool `json:"cached,omitempty"`
+	// Unavailable is true if the edition is no longer available to the
+	// account.
+	Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// Updated returns whether a new database was installed.
+func (r ReadResult) Updated() bool {
+	return r.NewHash != r.OldHash && !r.Unavailable
+}
+
+// NewClient returns a Client updating the databases of editionIDs, e.g.,
+// GeoLite2-City, with the credentials of the account accountID, into the
+// default database directory unless WithDatabaseDirectory is given. The
+// editionIDs are nil if the editions are given by WithEditionsFunc instead.
+func NewClient(
+	accountID int,
+	licenseKey string,
+	editionIDs []string,
+	options ...Option,
+) (*Client, error) {
+	configOptions := []geoipupdate.Option{
+		geoipupdate.WithoutEnvironment,
+		func(c *geoipupdate.Config) error {
+			c.AccountID = accountID
+			c.LicenseKey = licenseKey
+			return nil
+		},
+		geoipupdate.WithEditionIDs(editionIDs),
+	}
+	var o clientOptions
+	for _, option := range options {
+		option(&o)
+	}
+	configOptions = append(configOptions, o.config...)
+	if o.editionsFunc != nil {
+		if len(editionIDs) > 0 {
+			return nil, errors.New("editions can't be given both to NewClient and by WithEditionsFunc")
+		}
+		configOptions = append(configOptions, geoipupdate.WithEditionsFunc(
+			geoipupdate.EditionsFunc(o.editionsFunc),
+		))
+	}
+
+	config, err := geoipupdate.NewConfig(configOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	updater, err := geoipupdate.NewUpdater(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{updater: updater}, nil
+}
+
+// Update checks the editions for updates, and installs the new databases.
+// It returns the outcome of the update of each edition, in the order the
+// editions were processed. Canceling ctx stops the update, leaving the
+// databases that weren't updated yet as they were. Update must not be called
+// again before it returns.
+func (c *Client) Update(ctx context.Context) ([]ReadResult, error) {
+	editions, err := c.updater.RunEditions(ctx)
+	results := make([]ReadResult, 0, len(editions))
+	for _, edition := range editions {
+		results = append(results, ReadResult{
+			EditionID:   edition.EditionID,
+			OldHash:     edition.OldHash,
+			NewHash:     edition.NewHash,
+			ModifiedAt:  edition.ModifiedAt,
+			CheckedAt:   edition.CheckedAt,
+			Cached:      edition.Cached,
+			Unavailable: edition.Unavailable,
+		})
+	}
+	return results, err
+}
@@ -0,0 +1,158 @@
+package geoipupdate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/md5" //nolint:gosec // the updates API uses MD5 sums.
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer returns an updates server serving editionID, whose database
+// contains content.
+func newServer(t *testing.T, editionID, content string) *httptest.Server {
+	sum := md5.Sum([]byte(content)) //nolint:gosec // the updates API uses MD5 sums.
+	hash := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/geoip/updates/metadata"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(
+				w,
+				`{"databases":[{"edition_id":%q,"md5":%q,"date":"2024-02-23"}]}`,
+				editionID,
+				hash,
+			)
+		case strings.HasPrefix(r.URL.Path, "/geoip/databases/"+editionID):
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Last-Modified", "Fri, 23 Feb 2024 00:00:00 GMT")
+			gw := gzip.NewWriter(w)
+			tw := tar.NewWriter(gw)
+			assert.NoError(t, tw.WriteHeader(&tar.Header{
+				Name: editionID + ".mmdb",
+				Size: int64(len(content)),
+			}))
+			_, err := tw.Write([]byte(content))
+			assert.NoError(t, err)
+			assert.NoError(t, tw.Close())
+			assert.NoError(t, gw.Close())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientUpdate(t *testing.T) {
+	server := newServer(t, "GeoLite2-City", "GeoLite2-City content")
+	databaseDirectory := t.TempDir()
+	// The environment is ignored.
+	t.Setenv("GEOIPUPDATE_DB_DIR", t.TempDir())
+	t.Setenv("GEOIPUPDATE_EDITION_IDS", "GeoLite2-ASN")
+
+	client, err := NewClient(
+		123,
+		"testing",
+		[]string{"GeoLite2-City"},
+		WithHost(server.URL),
+		WithDatabaseDirectory(databaseDirectory),
+	)
+	require.NoError(t, err)
+
+	results, err := client.Update(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "GeoLite2-City", results[0].EditionID)
+	assert.True(t, results[0].Updated())
+	assert.Equal(t, time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC), results[0].ModifiedAt.UTC())
+
+	content, err := os.ReadFile(filepath.Join(databaseDirectory, "GeoLite2-City.mmdb"))
+	require.NoError(t, err)
+	assert.Equal(t, "GeoLite2-City content", string(content))
+
+	// The database is only downloaded once.
+	results, err = client.Update(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Updated())
+}
+
+func TestClientEditionsFunc(t *testing.T) {
+	server := newServer(t, "GeoLite2-City", "GeoLite2-City content")
+	databaseDirectory := t.TempDir()
+
+	calls := 0
+	editions := func(context.Context) ([]string, error) {
+		calls++
+		if calls > 1 {
+			return nil, errors.New("feature flags unavailable")
+		}
+		return []string{"GeoLite2-City"}, nil
+	}
+	client, err := NewClient(
+		123,
+		"testing",
+		nil,
+		WithEditionsFunc(editions),
+		WithHost(server.URL),
+		WithDatabaseDirectory(databaseDirectory),
+	)
+	require.NoError(t, err)
+	assert.Zero(t, calls, "the editions are only given when updating")
+
+	results, err := client.Update(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "GeoLite2-City", results[0].EditionID)
+	assert.FileExists(t, filepath.Join(databaseDirectory, "GeoLite2-City.mmdb"))
+
+	// The editions are given again by each update.
+	_, err = client.Update(context.Background())
+	require.ErrorContains(t, err, "getting the editions to update: feature flags unavailable")
+	assert.Equal(t, 2, calls)
+
+	_, err = NewClient(123, "testing", []string{"GeoLite2-City"}, WithEditionsFunc(editions))
+	require.EqualError(t, err, "editions can't be given both to NewClient and by WithEditionsFunc")
+}
+
+func TestClientUpdateCanceled(t *testing.T) {
+	server := newServer(t, "GeoLite2-City", "GeoLite2-City content")
+	databaseDirectory := t.TempDir()
+
+	client, err := NewClient(
+		123,
+		"testing",
+		[]string{"GeoLite2-City"},
+		WithHost(server.URL),
+		WithDatabaseDirectory(databaseDirectory),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Update(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(databaseDirectory, "GeoLite2-City.mmdb"))
+}
+
+func TestNewClientInvalidOptions(t *testing.T) {
+	_, err := NewClient(123, "testing", nil)
+	require.ErrorContains(t, err, "`EditionIDs` option is required")
+
+	_, err = NewClient(123, "testing", []string{"GeoLite2-City"}, WithParallelism(-1))
+	require.ErrorContains(t, err, "parallelism can't be negative")
+}
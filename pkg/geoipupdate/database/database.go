@@ -0,0 +1,79 @@
+// Package database is the database API of geoipupdate v6, kept so that
+// programs embedding geoipupdate can upgrade to v7 by changing their import
+// paths only, and then move to the client package at their own pace.
+//
+// Deprecated: Use the client package to download databases. This package
+// will be removed in v8.
+package database
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// ZeroMD5 is the default value provided as an MD5 hash for a non-existent
+// database.
+//
+// Deprecated: Pass an empty MD5 sum to client.Client.Download instead.
+const ZeroMD5 = database.ZeroMD5
+
+// Reader provides an interface for retrieving a database update.
+//
+// Deprecated: Use client.Client.
+type Reader interface {
+	Read(ctx context.Context, editionID, hash string) (*ReadResult, error)
+}
+
+// Writer provides an interface for writing a database read by a Reader to a
+// target location.
+//
+// Deprecated: Read client.DownloadResponse.Reader instead.
+type Writer interface {
+	Write(result *ReadResult) error
+	GetHash(editionID string) (string, error)
+}
+
+// ReadResult is the struct returned by a Reader's Read method.
+//
+// Deprecated: Use client.DownloadResponse.
+type ReadResult struct {
+	// reader is the content of the new database, if any.
+	reader     io.ReadCloser
+	EditionID  string    `json:"edition_id"`
+	OldHash    string    `json:"old_hash"`
+	NewHash    string    `json:"new_hash"`
+	ModifiedAt time.Time `json:"modified_at"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// MarshalJSON is a custom json marshaler that strips out zero time fields,
+// as in the output of geoipupdate.
+func (r ReadResult) MarshalJSON() ([]byte, error) {
+	return database.ReadResult{
+		EditionID:  r.EditionID,
+		OldHash:    r.OldHash,
+		NewHash:    r.NewHash,
+		ModifiedAt: r.ModifiedAt,
+		CheckedAt:  r.CheckedAt,
+	}.MarshalJSON()
+}
+
+// UnmarshalJSON is a custom json unmarshaler that converts timestamps to go
+// time fields.
+func (r *ReadResult) UnmarshalJSON(data []byte) error {
+	var result database.ReadResult
+	if err := result.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*r = ReadResult{
+		EditionID:  result.EditionID,
+		OldHash:    result.OldHash,
+		NewHash:    result.NewHash,
+		ModifiedAt: result.ModifiedAt,
+		CheckedAt:  result.CheckedAt,
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/maxmind/geoipupdate/v7/client"
+	"github.com/maxmind/geoipupdate/v7/internal"
+)
+
+// HTTPReader is a Reader that uses an HTTP client to retrieve databases.
+//
+// Deprecated: Use client.Client.
+type HTTPReader struct {
+	client client.Client
+	// err is the error creating client, returned by Read as NewHTTPReader
+	// can't return errors.
+	err      error
+	retryFor time.Duration
+	verbose  bool
+	// trace, if not nil, receives the events of the requests instead of
+	// the verbose messages.
+	trace io.Writer
+}
+
+// HTTPReaderOption configures an HTTPReader.
+//
+// Deprecated: Use client.Option.
+type HTTPReaderOption func(*HTTPReader)
+
+// WithTrace makes an HTTPReader write the events of its requests to w, as
+// client.WithTrace does, instead of its verbose messages.
+//
+// Deprecated: Use client.WithTrace.
+func WithTrace(w io.Writer) HTTPReaderOption {
+	return func(r *HTTPReader) {
+		r.trace = w
+	}
+}
+
+// NewHTTPReader creates a Reader that downloads database updates via HTTP
+// from path, e.g., https://updates.maxmind.com, through proxy if it isn't
+// nil. Failed downloads are retried for retryFor. The options, e.g.,
+// WithTrace, are an addition to the v6 API.
+//
+// Deprecated: Use client.New with client.WithEndpoint and
+// client.WithHTTPClient.
+func NewHTTPReader(
+	proxy *url.URL,
+	path string,
+	accountID int,
+	licenseKey string,
+	retryFor time.Duration,
+	verbose bool,
+	options ...HTTPReaderOption,
+) Reader {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	r := &HTTPReader{
+		retryFor: retryFor,
+		verbose:  verbose,
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	clientOptions := []client.Option{
+		client.WithEndpoint(path),
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+	}
+	if r.trace != nil {
+		clientOptions = append(clientOptions, client.WithTrace(r.trace))
+	}
+	r.client, r.err = client.New(accountID, licenseKey, clientOptions...)
+	return r
+}
+
+// Read attempts to fetch database updates for a specific editionID. It takes
+// an editionID and its previously downloaded hash if available as arguments
+// and returns a ReadResult struct as a response. The database, if the
+// result has a new hash, must be written with a Writer.
+func (r *HTTPReader) Read(ctx context.Context, editionID, hash string) (*ReadResult, error) {
+	if r.err != nil {
+		return nil, fmt.Errorf("creating client: %w", r.err)
+	}
+
+	var bo backoff.BackOff = &backoff.StopBackOff{}
+	if r.retryFor > 0 {
+		exp := backoff.NewExponentialBackOff()
+		exp.MaxElapsedTime = r.retryFor
+		bo = exp
+	}
+
+	var res client.DownloadResponse
+	err := backoff.RetryNotify(
+		func() error {
+			var err error
+			res, err = r.client.Download(ctx, editionID, hash)
+			if err != nil && internal.IsPermanentError(err) {
+				return backoff.Permanent(err)
+			}
+			return err
+		},
+		backoff.WithContext(bo, ctx),
+		func(err error, d time.Duration) {
+			r.logf("Couldn't download %s, retrying in %v: %v", editionID, d, err)
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting update for %s: %w", editionID, err)
+	}
+
+	result := &ReadResult{
+		EditionID: editionID,
+		OldHash:   hash,
+		NewHash:   hash,
+		CheckedAt: time.Now().In(time.UTC),
+	}
+	if !res.UpdateAvailable {
+		res.Reader.Close()
+		r.logf("No new updates available for %s", editionID)
+		return result, nil
+	}
+
+	r.logf("Updates available for %s", editionID)
+	result.reader = res.Reader
+	result.NewHash = res.MD5
+	result.ModifiedAt = res.LastModified
+	return result, nil
+}
+
+// logf logs in verbose mode, unless the requests are traced.
+func (r *HTTPReader) logf(format string, args ...any) {
+	if r.verbose && r.trace == nil {
+		log.Printf(format, args...)
+	}
+}
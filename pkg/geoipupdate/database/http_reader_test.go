@@ -0,0 +1,126 @@
+package database
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestV6API tests that the v6 API still downloads and writes databases.
+func TestV6API(t *testing.T) {
+	content := "GeoLite2-City content"
+	sum := md5.Sum([]byte(content)) //nolint:gosec // MD5 is what the API uses.
+	newHash := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/geoip/updates/metadata") {
+			fmt.Fprintf(
+				w,
+				`{"databases":[{"edition_id":"GeoLite2-City","md5":%q,"date":"2024-02-23"}]}`,
+				newHash,
+			)
+			return
+		}
+
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		assert.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: "GeoLite2-City.mmdb",
+			Size: int64(len(content)),
+			Mode: 0o644,
+		}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+		assert.NoError(t, tw.Close())
+		assert.NoError(t, gw.Close())
+		w.Header().Set("Last-Modified", "Fri, 23 Feb 2024 00:00:00 GMT")
+		_, err = w.Write(buf.Bytes())
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	reader := NewHTTPReader(nil, server.URL, 42, "000000000001", 0, false)
+	writer, err := NewLocalFileWriter(dir, false, false)
+	require.NoError(t, err)
+
+	res, err := reader.Read(ctx, "GeoLite2-City", ZeroMD5)
+	require.NoError(t, err)
+	require.Equal(t, ZeroMD5, res.OldHash)
+	require.Equal(t, newHash, res.NewHash)
+	require.True(t, time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC).Equal(res.ModifiedAt))
+	require.NoError(t, writer.Write(res))
+
+	written, err := os.ReadFile(filepath.Join(dir, "GeoLite2-City.mmdb"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(written))
+	hash, err := writer.GetHash("GeoLite2-City")
+	require.NoError(t, err)
+	require.Equal(t, newHash, hash)
+
+	// Up-to-date databases aren't written.
+	res, err = reader.Read(ctx, "GeoLite2-City", hash)
+	require.NoError(t, err)
+	require.Equal(t, hash, res.NewHash)
+	require.NoError(t, writer.Write(res))
+
+	encoded, err := json.Marshal(res)
+	require.NoError(t, err)
+	var decoded ReadResult
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.Equal(t, res.EditionID, decoded.EditionID)
+	require.Equal(t, res.CheckedAt.Unix(), decoded.CheckedAt.Unix())
+}
+
+func TestNewHTTPReaderInvalidAccountID(t *testing.T) {
+	reader := NewHTTPReader(nil, "https://updates.maxmind.com", 0, "000000000001", 0, false)
+	_, err := reader.Read(context.Background(), "GeoLite2-City", ZeroMD5)
+	require.ErrorContains(t, err, "invalid account ID")
+}
+
+func TestHTTPReaderTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"databases":[{"edition_id":"GeoLite2-City","md5":"a","date":"2024-02-23"}]}`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	reader := NewHTTPReader(nil, server.URL, 42, "000000000001", 0, true, WithTrace(&out))
+	_, err := reader.Read(context.Background(), "GeoLite2-City", "a")
+	require.NoError(t, err)
+
+	var stages []string
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event struct {
+			EditionID string  `json:"edition_id"`
+			Attempt   int     `json:"attempt"`
+			Stage     string  `json:"stage"`
+			Elapsed   float64 `json:"elapsed_seconds"`
+			Duration  float64 `json:"duration_seconds"`
+		}
+		require.NoError(t, decoder.Decode(&event))
+		require.Equal(t, "GeoLite2-City", event.EditionID)
+		require.Equal(t, 1, event.Attempt)
+		require.GreaterOrEqual(t, event.Elapsed, event.Duration)
+		stages = append(stages, event.Stage)
+	}
+	require.Equal(t, []string{"connect", "first_byte", "done"}, stages)
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/maxmind/geoipupdate/v7/internal/geoipupdate/database"
+)
+
+// LocalFileWriter is a Writer that writes databases to a directory.
+//
+// Deprecated: Write client.DownloadResponse.Reader to a file instead.
+type LocalFileWriter struct {
+	writer *database.LocalFileWriter
+}
+
+// NewLocalFileWriter creates a LocalFileWriter writing databases to
+// databaseDir. If preserveFileTime is true, their modification times are
+// set to their build times.
+//
+// Deprecated: Write client.DownloadResponse.Reader to a file instead.
+func NewLocalFileWriter(
+	databaseDir string,
+	preserveFileTime bool,
+	verbose bool,
+) (*LocalFileWriter, error) {
+	w, err := database.NewLocalFileWriter(databaseDir, preserveFileTime, verbose)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalFileWriter{writer: w}, nil
+}
+
+// Write writes the database of result, as returned by an HTTPReader, to a
+// file. It does nothing if the database is up to date.
+func (w *LocalFileWriter) Write(result *ReadResult) error {
+	if result.reader == nil {
+		if result.NewHash == result.OldHash {
+			return nil
+		}
+		return errors.New("the result has no database to write")
+	}
+	// The database can only be read once.
+	reader := result.reader
+	result.reader = nil
+
+	err := w.writer.Write(context.Background(), result.EditionID, reader, result.NewHash, result.ModifiedAt)
+	if err != nil {
+		return fmt.Errorf("writing database: %w", err)
+	}
+	return nil
+}
+
+// GetHash returns the hash of the current database file.
+func (w *LocalFileWriter) GetHash(editionID string) (string, error) {
+	return w.writer.GetHash(context.Background(), editionID)
+}